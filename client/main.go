@@ -7,15 +7,19 @@ import (
 	"io"
 	"io/ioutil"
 	"log"
+	"os"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
 	v3orcapb "github.com/cncf/xds/go/xds/data/orca/v3"
 	pb "github.com/mhbvr/manul/proto"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/proto"
 )
 
@@ -28,9 +32,20 @@ var (
 	serverAddr   = flag.String("addr", "localhost:8081", "Server address")
 	showMetrics  = flag.Bool("show-metrics", false, "Show ORCA metrics from trailers")
 	width        = flag.Uint("width", 0, "Width for scaling (0 = no scaling)")
-	algorithm    = flag.String("algorithm", "BILINEAR", "Scaling algorithm: NEAREST_NEIGHBOR, BILINEAR, CATMULL_ROM, APPROX_BILINEAR")
+	height       = flag.Uint("height", 0, "Height for scaling (0 = follow the aspect ratio of -width)")
+	fit          = flag.String("fit", "FIT", "Fit mode if both -width and -height are set: FIT (within the box), FILL (crop to the box) or STRETCH")
+	quality      = flag.Uint("quality", 0, "JPEG quality 1-100 of scaled or converted photos (0 = server default)")
+	algorithm    = flag.String("algorithm", "BILINEAR", "Scaling algorithm: NEAREST_NEIGHBOR, BILINEAR, CATMULL_ROM, APPROX_BILINEAR, AUTO (chosen by the server) or NONE (no scaling)")
+	sizePreset   = flag.String("size", "NO_PRESET", "Size preset replacing -width, -height, -fit, -algorithm and -quality: THUMB, MEDIUM or FULL (sizes set by the server)")
+	format       = flag.String("format", "ORIGINAL", "Output format: ORIGINAL (stored format, JPEG if scaled), JPEG or PNG")
+	chunked      = flag.Bool("chunked", false, "Get the photo in chunks with GetPhotoChunked, for photos over the gRPC message size limit")
+	chunkSize    = flag.Uint("chunk-size", 0, "Chunk size in bytes for -chunked (0 = server default)")
+	randomPhoto  = flag.Bool("random", false, "Get a random photo, with the scaling flags")
+	photoMeta    = flag.Bool("metadata", false, "Print the dimensions, size and format of the photo instead of getting it")
 	streamPhotos = flag.String("stream-photos", "", "Stream multiple photos (format: cat_id1:photo_id1,cat_id2:photo_id2,...)")
 	outputDir    = flag.String("output-dir", "/tmp", "Output directory for photos")
+	progressEach = flag.Int("progress-every", 50, "Print stream progress every N photos, and at least every second")
+	quiet        = flag.Bool("quiet", false, "Do not print saved photos and stream progress")
 )
 
 const ORCAMetadataKey = "endpoint-load-metrics-bin"
@@ -45,11 +60,40 @@ func getScalingAlgorithm(alg string) pb.ScalingAlgorithm {
 		return pb.ScalingAlgorithm_CATMULL_ROM
 	case "APPROX_BILINEAR":
 		return pb.ScalingAlgorithm_APPROX_BILINEAR
+	case "AUTO":
+		return pb.ScalingAlgorithm_AUTO
+	case "NONE":
+		return pb.ScalingAlgorithm_NONE
 	default:
+		log.Fatalf("Unknown scaling algorithm: %s", alg)
 		return pb.ScalingAlgorithm_NONE
 	}
 }
 
+func getFitMode(mode string) pb.FitMode {
+	value, ok := pb.FitMode_value[mode]
+	if !ok {
+		log.Fatalf("Unknown fit mode: %s", mode)
+	}
+	return pb.FitMode(value)
+}
+
+func getSizePreset(preset string) pb.SizePreset {
+	value, ok := pb.SizePreset_value[preset]
+	if !ok {
+		log.Fatalf("Unknown size preset: %s", preset)
+	}
+	return pb.SizePreset(value)
+}
+
+func getOutputFormat(format string) pb.OutputFormat {
+	value, ok := pb.OutputFormat_value[format]
+	if !ok {
+		log.Fatalf("Unknown output format: %s", format)
+	}
+	return pb.OutputFormat(value)
+}
+
 func main() {
 	flag.Parse()
 
@@ -63,6 +107,16 @@ func main() {
 		return
 	}
 
+	if *catID != 0 && *photoID != 0 && *photoMeta {
+		getPhotoMetadata(*catID, *photoID)
+		return
+	}
+
+	if *catID != 0 && *photoID != 0 && *chunked {
+		getCatPhotoChunked(*catID, *photoID)
+		return
+	}
+
 	if *catID != 0 && *photoID != 0 {
 		getCatPhoto(*catID, *photoID)
 		return
@@ -73,6 +127,11 @@ func main() {
 		return
 	}
 
+	if *randomPhoto {
+		getRandomPhoto()
+		return
+	}
+
 	// Show usage if no flags provided
 	flag.Usage()
 }
@@ -105,15 +164,23 @@ func listAllCats() {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
+	fmt.Println("Cat IDs:")
 	var trailer metadata.MD
-	resp, err := client.ListCats(ctx, &pb.ListCatsRequest{}, grpc.Trailer(&trailer))
-	if err != nil {
-		log.Fatalf("ListCats failed: %v", err)
-	}
+	req := &pb.ListCatsRequest{}
+	for {
+		resp, err := client.ListCats(ctx, req, grpc.Trailer(&trailer))
+		if err != nil {
+			log.Fatalf("ListCats failed: %v", err)
+		}
 
-	fmt.Println("Cat IDs:")
-	for _, catID := range resp.CatIds {
-		fmt.Printf("%d\n", catID)
+		for _, catID := range resp.CatIds {
+			fmt.Printf("%d\n", catID)
+		}
+
+		if resp.NextPageToken == "" {
+			break
+		}
+		req.PageToken = resp.NextPageToken
 	}
 
 	if *showMetrics {
@@ -126,15 +193,23 @@ func listPhotosForCat(catID uint64) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
+	fmt.Printf("Photo IDs for cat %d:\n", catID)
 	var trailer metadata.MD
-	resp, err := client.ListPhotos(ctx, &pb.ListPhotosRequest{CatId: catID}, grpc.Trailer(&trailer))
-	if err != nil {
-		log.Fatalf("ListPhotos failed: %v", err)
-	}
+	req := &pb.ListPhotosRequest{CatId: catID}
+	for {
+		resp, err := client.ListPhotos(ctx, req, grpc.Trailer(&trailer))
+		if err != nil {
+			log.Fatalf("ListPhotos failed: %v", err)
+		}
 
-	fmt.Printf("Photo IDs for cat %d:\n", catID)
-	for _, photoID := range resp.PhotoIds {
-		fmt.Printf("%d\n", photoID)
+		for _, photoID := range resp.PhotoIds {
+			fmt.Printf("%d\n", photoID)
+		}
+
+		if resp.NextPageToken == "" {
+			break
+		}
+		req.PageToken = resp.NextPageToken
 	}
 
 	if *showMetrics {
@@ -142,30 +217,74 @@ func listPhotosForCat(catID uint64) {
 	}
 }
 
+func photoFilename(catId, photoId uint64) string {
+	return fmt.Sprintf("%s/cat_%d_photo_%d.jpg", *outputDir, catId, photoId)
+}
+
 func saveFile(catId, photoId uint64, data []byte) {
-	filename := fmt.Sprintf("%s/cat_%d_photo_%d.jpg", *outputDir, catId, photoId)
+	filename := photoFilename(catId, photoId)
 	err := ioutil.WriteFile(filename, data, 0644)
 	if err != nil {
 		log.Printf("Failed to write file %s: %v", filename, err)
-	} else {
+	} else if !*quiet {
 		fmt.Printf("Cat %d, Photo %d saved to %s (%d bytes)\n",
 			catId, photoId, filename, len(data))
 	}
 }
 
+// saveStreamedFile saves a streamed photo and verifies that the received
+// and written sizes match the size sent by the server, so truncated
+// transfers are detected. Servers not sending the size are not verified.
+func saveStreamedFile(response *pb.GetPhotosStreamResponse) error {
+	if response.SizeBytes != 0 && uint64(len(response.PhotoData)) != response.SizeBytes {
+		return fmt.Errorf("received %d of %d bytes", len(response.PhotoData), response.SizeBytes)
+	}
+
+	filename := photoFilename(response.CatId, response.PhotoId)
+	if err := ioutil.WriteFile(filename, response.PhotoData, 0644); err != nil {
+		return fmt.Errorf("failed to write file %s: %v", filename, err)
+	}
+	if response.SizeBytes != 0 {
+		info, err := os.Stat(filename)
+		if err != nil {
+			return fmt.Errorf("failed to check file %s: %v", filename, err)
+		}
+		if uint64(info.Size()) != response.SizeBytes {
+			return fmt.Errorf("wrote %d of %d bytes to %s", info.Size(), response.SizeBytes, filename)
+		}
+	}
+
+	if !*quiet {
+		fmt.Printf("Cat %d, Photo %d saved to %s (%d bytes, %s)\n",
+			response.CatId, response.PhotoId, filename, len(response.PhotoData), response.Format)
+	}
+	return nil
+}
+
+// photoRequest returns a GetPhoto request with the scaling flags
+func photoRequest(catID, photoID uint64) *pb.GetPhotoRequest {
+	return &pb.GetPhotoRequest{
+		CatId:            catID,
+		PhotoId:          photoID,
+		Width:            uint32(*width),
+		ScalingAlgorithm: getScalingAlgorithm(*algorithm),
+		OutputFormat:     getOutputFormat(*format),
+		Height:           uint32(*height),
+		Fit:              getFitMode(*fit),
+		Quality:          uint32(*quality),
+		SizePreset:       getSizePreset(*sizePreset),
+	}
+}
+
 func getCatPhoto(catID, photoID uint64) {
 	client := getClient()
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
 	var trailer metadata.MD
-	resp, err := client.GetPhoto(ctx, &pb.GetPhotoRequest{
-		CatId:            catID,
-		PhotoId:          photoID,
-		Width:            uint32(*width),
-		ScalingAlgorithm: getScalingAlgorithm(*algorithm),
-	}, grpc.Trailer(&trailer))
+	resp, err := client.GetPhoto(ctx, photoRequest(catID, photoID), grpc.Trailer(&trailer))
 	if err != nil {
+		printErrorDetails(err)
 		log.Fatalf("GetPhoto failed: %v", err)
 	}
 
@@ -176,6 +295,108 @@ func getCatPhoto(catID, photoID uint64) {
 	}
 }
 
+func getRandomPhoto() {
+	client := getClient()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var trailer metadata.MD
+	resp, err := client.GetRandomPhoto(ctx, &pb.GetRandomPhotoRequest{Photo: photoRequest(0, 0)}, grpc.Trailer(&trailer))
+	if err != nil {
+		printErrorDetails(err)
+		log.Fatalf("GetRandomPhoto failed: %v", err)
+	}
+
+	saveFile(resp.CatId, resp.PhotoId, resp.Photo.GetPhotoData())
+
+	if *showMetrics {
+		printORCAMetrics(trailer)
+	}
+}
+
+func getPhotoMetadata(catID, photoID uint64) {
+	client := getClient()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	resp, err := client.GetPhotoMetadata(ctx, &pb.GetPhotoMetadataRequest{CatId: catID, PhotoId: photoID})
+	if err != nil {
+		printErrorDetails(err)
+		log.Fatalf("GetPhotoMetadata failed: %v", err)
+	}
+
+	fmt.Printf("Cat %d, Photo %d: %dx%d, %d bytes, %s\n", catID, photoID, resp.Width, resp.Height, resp.Size, resp.Format)
+}
+
+// getCatPhotoChunked gets a photo with GetPhotoChunked and reassembles it
+func getCatPhotoChunked(catID, photoID uint64) {
+	client := getClient()
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	stream, err := client.GetPhotoChunked(ctx, &pb.GetPhotoChunkedRequest{
+		Photo:     photoRequest(catID, photoID),
+		ChunkSize: uint32(*chunkSize),
+	})
+	if err != nil {
+		log.Fatalf("GetPhotoChunked failed: %v", err)
+	}
+
+	var data []byte
+	var totalSize uint64
+	chunks := 0
+	for {
+		chunk, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			printErrorDetails(err)
+			log.Fatalf("Failed to receive chunk: %v", err)
+		}
+
+		if chunks == 0 {
+			totalSize = chunk.TotalSize
+			data = make([]byte, 0, totalSize)
+		}
+		data = append(data, chunk.Data...)
+		chunks++
+	}
+
+	if uint64(len(data)) != totalSize {
+		log.Fatalf("Received %d bytes in %d chunks, want %d", len(data), chunks, totalSize)
+	}
+	if !*quiet {
+		fmt.Printf("Received %d bytes in %d chunks\n", len(data), chunks)
+	}
+
+	saveFile(catID, photoID, data)
+
+	if *showMetrics {
+		printORCAMetrics(stream.Trailer())
+	}
+}
+
+// printErrorDetails prints the structured details of a gRPC error
+func printErrorDetails(err error) {
+	for _, detail := range status.Convert(err).Details() {
+		switch d := detail.(type) {
+		case *errdetails.ErrorInfo:
+			fmt.Printf("Error info: reason=%s domain=%s\n", d.Reason, d.Domain)
+			keys := make([]string, 0, len(d.Metadata))
+			for key := range d.Metadata {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			for _, key := range keys {
+				fmt.Printf("  %s: %s\n", key, d.Metadata[key])
+			}
+		default:
+			fmt.Printf("Error detail: %v\n", detail)
+		}
+	}
+}
+
 func parsePhotoRequests(input string) ([]*pb.PhotoRequest, error) {
 	pairs := strings.Split(input, ",")
 	var requests []*pb.PhotoRequest
@@ -205,6 +426,46 @@ func parsePhotoRequests(input string) ([]*pb.PhotoRequest, error) {
 	return requests, nil
 }
 
+// streamProgress counts received stream responses and prints progress
+// every N responses or every second
+type streamProgress struct {
+	total     int
+	every     int
+	received  int
+	saved     int
+	bytes     int64
+	lastPrint time.Time
+}
+
+func newStreamProgress(total, every int) *streamProgress {
+	return &streamProgress{
+		total:     total,
+		every:     every,
+		lastPrint: time.Now(),
+	}
+}
+
+func (p *streamProgress) Add(response *pb.GetPhotosStreamResponse) {
+	p.received++
+	if response.Success {
+		p.saved++
+		p.bytes += int64(len(response.PhotoData))
+	}
+
+	if *quiet {
+		return
+	}
+	if (p.every > 0 && p.received%p.every == 0) || time.Since(p.lastPrint) >= time.Second {
+		p.Print()
+	}
+}
+
+func (p *streamProgress) Print() {
+	p.lastPrint = time.Now()
+	fmt.Printf("Saved %d/%d, %.1f MB, %d failed\n",
+		p.saved, p.total, float64(p.bytes)/(1<<20), p.received-p.saved)
+}
+
 func getPhotosStream(photoRequestsStr string) {
 	client := getClient()
 	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
@@ -236,6 +497,7 @@ func getPhotosStream(photoRequestsStr string) {
 	}
 
 	fmt.Printf("Streaming %d photos...\n", len(photoRequests))
+	progress := newStreamProgress(len(photoRequests), *progressEach)
 
 	for {
 		response, err := stream.Recv()
@@ -247,13 +509,21 @@ func getPhotosStream(photoRequestsStr string) {
 		}
 
 		if response.Success {
-			saveFile(response.CatId, response.PhotoId, response.PhotoData)
-		} else {
+			if err := saveStreamedFile(response); err != nil {
+				// Counted as failed
+				response.Success = false
+				response.ErrorMessage = err.Error()
+			}
+		}
+		if !response.Success {
 			fmt.Printf("Error Cat %d, Photo %d: %s\n",
 				response.CatId, response.PhotoId, response.ErrorMessage)
 		}
+
+		progress.Add(response)
 	}
 
+	progress.Print()
 	fmt.Println("Streaming completed.")
 	if *showMetrics {
 		printORCAMetrics(trailer)
@@ -0,0 +1,156 @@
+package loadrunner
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+
+	pb "github.com/mhbvr/manul/proto"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	listingTracer = otel.Tracer("listing_load_runner")
+)
+
+const defaultListCatsRatio = 0.5
+
+// CatListingLoad implements the Load interface calling ListCats and
+// ListPhotos, to load the listing paths separately from photo reads.
+type CatListingLoad struct {
+	*catPhotoData
+	Addr          string  `name:"addr" description:"Server address to connect, or comma-separated addresses with optional @weight to spread requests over, e.g. a:8081@3,b:8081@1" required:"true"`
+	Balancer      string  `name:"balancer" description:"gRPC load balancing policy"`
+	PinAddr       string  `name:"pin_addr" description:"Send all requests to this ip:port backend, bypassing resolver and balancer"`
+	Connections   int     `name:"connections" description:"Number of gRPC connections jobs are spread over round-robin, avoids HTTP/2 limits of a single connection at high QPS"`
+	ListCatsRatio float64 `name:"list_cats_ratio" description:"Fraction of jobs calling ListCats, the others call ListPhotos of a random cat (0 to 1)"`
+	PageSize      int     `name:"page_size" description:"Page size of listing requests (0 = server default)"`
+	FullScan      bool    `name:"full_scan" description:"Read all pages in each job instead of the first page only"`
+}
+
+// NewCatListingLoad creates a new listing load implementation.
+func NewCatListingLoad() Load {
+	return &CatListingLoad{
+		Connections:   1,
+		ListCatsRatio: defaultListCatsRatio,
+	}
+}
+
+func (l *CatListingLoad) Options() []OptionDescription {
+	return GetOptionDescriptions(l)
+}
+
+// Validate checks the ratio and page size options.
+func (l *CatListingLoad) Validate() error {
+	if l.ListCatsRatio < 0 || l.ListCatsRatio > 1 {
+		return fmt.Errorf("list_cats_ratio must be between 0 and 1, got %v", l.ListCatsRatio)
+	}
+	if l.PageSize < 0 {
+		return fmt.Errorf("page_size must not be negative, got %d", l.PageSize)
+	}
+	return nil
+}
+
+// Init creates the gRPC connection and fetches available cat IDs from the
+// server. Photo IDs are not fetched, listing them is the measured load.
+func (l *CatListingLoad) Init(ctx context.Context, options map[string]string) error {
+	if err := ParseOptions(options, l); err != nil {
+		return err
+	}
+
+	if err := ValidateOptions(l); err != nil {
+		return err
+	}
+
+	data, err := initCatData(ctx, l.Addr, l.Balancer, l.PinAddr, l.Connections)
+	if err != nil {
+		return err
+	}
+	l.catPhotoData = data
+	return nil
+}
+
+// Job executes a single ListCats or ListPhotos operation, reading one page
+// or all pages with FullScan.
+// Returns the duration of the operation and any error that occurred.
+func (l *CatListingLoad) Job(ctx context.Context) (time.Duration, error) {
+	ctx, span := listingTracer.Start(ctx, "list_job", trace.WithNewRoot())
+	defer span.End()
+
+	addr, client := l.client()
+	listCats := rand.Float64() < l.ListCatsRatio
+	var catID uint64
+	if !listCats {
+		catID = l.cats[rand.Intn(len(l.cats))]
+	}
+
+	start := time.Now()
+	var pages, ids int
+	var err error
+	if listCats {
+		pages, ids, err = l.listCats(ctx, client)
+	} else {
+		pages, ids, err = l.listPhotos(ctx, client, catID)
+	}
+	duration := time.Since(start)
+
+	span.SetAttributes(
+		attribute.Bool("list_cats", listCats),
+		attribute.Int("cat_id", int(catID)),
+		attribute.Int("pages", pages),
+		attribute.Int("ids", ids),
+		attribute.String("server_addr", addr),
+		attribute.Bool("success", err == nil),
+	)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+	} else {
+		span.SetStatus(codes.Ok, "")
+	}
+
+	return duration, err
+}
+
+// listCats reads ListCats pages and returns the number of pages and IDs read
+func (l *CatListingLoad) listCats(ctx context.Context, client pb.CatPhotosServiceClient) (pages, ids int, err error) {
+	req := &pb.ListCatsRequest{PageSize: int32(l.PageSize)}
+	for {
+		resp, err := client.ListCats(ctx, req)
+		if err != nil {
+			return pages, ids, err
+		}
+		pages++
+		ids += len(resp.CatIds)
+		if !l.FullScan || resp.NextPageToken == "" {
+			return pages, ids, nil
+		}
+		req.PageToken = resp.NextPageToken
+	}
+}
+
+// listPhotos reads ListPhotos pages of a cat and returns the number of
+// pages and IDs read
+func (l *CatListingLoad) listPhotos(ctx context.Context, client pb.CatPhotosServiceClient, catID uint64) (pages, ids int, err error) {
+	req := &pb.ListPhotosRequest{CatId: catID, PageSize: int32(l.PageSize)}
+	for {
+		resp, err := client.ListPhotos(ctx, req)
+		if err != nil {
+			return pages, ids, err
+		}
+		pages++
+		ids += len(resp.PhotoIds)
+		if !l.FullScan || resp.NextPageToken == "" {
+			return pages, ids, nil
+		}
+		req.PageToken = resp.NextPageToken
+	}
+}
+
+// Close closes the gRPC connection.
+func (l *CatListingLoad) Close() error {
+	return l.catPhotoData.close()
+}
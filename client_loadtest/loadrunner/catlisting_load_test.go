@@ -0,0 +1,126 @@
+package loadrunner
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"sync/atomic"
+	"testing"
+
+	pb "github.com/mhbvr/manul/proto"
+	"google.golang.org/grpc"
+)
+
+// listingServer serves cats 1 to 5 with photos 1 to 5 each, in pages of
+// page_size IDs, and counts the listing requests
+type listingServer struct {
+	pb.UnimplementedCatPhotosServiceServer
+	listCats   atomic.Int64
+	listPhotos atomic.Int64
+}
+
+// page returns the IDs of the page starting at token and the next token
+func page(token string, pageSize int32) ([]uint64, string) {
+	start, _ := strconv.Atoi(token)
+	size := int(pageSize)
+	if size <= 0 {
+		size = 5
+	}
+	end := min(start+size, 5)
+	var ids []uint64
+	for id := start + 1; id <= end; id++ {
+		ids = append(ids, uint64(id))
+	}
+	if end == 5 {
+		return ids, ""
+	}
+	return ids, strconv.Itoa(end)
+}
+
+func (s *listingServer) ListCats(ctx context.Context, req *pb.ListCatsRequest) (*pb.ListCatsResponse, error) {
+	s.listCats.Add(1)
+	ids, next := page(req.PageToken, req.PageSize)
+	return &pb.ListCatsResponse{CatIds: ids, NextPageToken: next}, nil
+}
+
+func (s *listingServer) ListPhotos(ctx context.Context, req *pb.ListPhotosRequest) (*pb.ListPhotosResponse, error) {
+	s.listPhotos.Add(1)
+	ids, next := page(req.PageToken, req.PageSize)
+	return &pb.ListPhotosResponse{PhotoIds: ids, NextPageToken: next}, nil
+}
+
+func startListingServer(t *testing.T) (*listingServer, string) {
+	t.Helper()
+	lis, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	server := &listingServer{}
+	grpcServer := grpc.NewServer()
+	pb.RegisterCatPhotosServiceServer(grpcServer, server)
+	go grpcServer.Serve(lis)
+	t.Cleanup(grpcServer.Stop)
+	return server, lis.Addr().String()
+}
+
+func TestCatListingLoad(t *testing.T) {
+	tests := []struct {
+		name           string
+		options        map[string]string
+		wantListCats   int64 // Requests of 10 jobs
+		wantListPhotos int64
+	}{
+		{"list cats", map[string]string{"list_cats_ratio": "1"}, 10, 0},
+		{"list photos", map[string]string{"list_cats_ratio": "0"}, 0, 10},
+		{"full scan", map[string]string{"list_cats_ratio": "1", "page_size": "2", "full_scan": "true"}, 30, 0},
+		{"first page", map[string]string{"list_cats_ratio": "0", "page_size": "2"}, 0, 10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, addr := startListingServer(t)
+			tt.options["addr"] = addr
+			load := NewCatListingLoad()
+			if err := load.Init(context.Background(), tt.options); err != nil {
+				t.Fatalf("Init() failed: %v", err)
+			}
+			defer load.Close()
+
+			// Init lists all cats and their photos
+			initCats, initPhotos := server.listCats.Load(), server.listPhotos.Load()
+			for i := 0; i < 10; i++ {
+				if _, err := load.Job(context.Background()); err != nil {
+					t.Fatalf("Job() failed: %v", err)
+				}
+			}
+
+			listCats := server.listCats.Load() - initCats
+			listPhotos := server.listPhotos.Load() - initPhotos
+			if listCats != tt.wantListCats || listPhotos != tt.wantListPhotos {
+				t.Errorf("Jobs sent %d ListCats and %d ListPhotos, want %d and %d",
+					listCats, listPhotos, tt.wantListCats, tt.wantListPhotos)
+			}
+		})
+	}
+}
+
+func TestCatListingLoad_Validate(t *testing.T) {
+	for _, options := range []map[string]string{
+		{"list_cats_ratio": "1.5"},
+		{"list_cats_ratio": "-0.1"},
+		{"page_size": "-1"},
+	} {
+		l := NewCatListingLoad().(*CatListingLoad)
+		if err := ParseOptions(options, l); err != nil {
+			t.Fatalf("ParseOptions(%v) failed: %v", options, err)
+		}
+		if err := l.Validate(); err == nil {
+			t.Errorf("Validate() with %v succeeded, want error", options)
+		}
+	}
+
+	l := NewCatListingLoad().(*CatListingLoad)
+	if err := l.Validate(); err != nil {
+		t.Errorf("Validate() of default options failed: %v", err)
+	}
+}
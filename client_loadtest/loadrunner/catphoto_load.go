@@ -18,10 +18,14 @@ var (
 // CatPhotoLoad implements the Load interface for cat photo load testing.
 type CatPhotoLoad struct {
 	*catPhotoData
-	Addr             string `name:"addr" description:"Server address to connect"`
+	bytesRecorder
+	Addr             string `name:"addr" description:"Server address to connect, or comma-separated addresses with optional @weight to spread requests over, e.g. a:8081@3,b:8081@1" required:"true"`
 	Balancer         string `name:"balancer" description:"gRPC load balancing policy"`
-	Width            uint32 `name:"width" description:"Target width for image scaling (0 = no scaling)"`
-	ScalingAlgorithm string `name:"scaling_algorithm" description:"Scaling algorithm: NEAREST_NEIGHBOR, BILINEAR, CATMULL_ROM, APPROX_BILINEAR"`
+	PinAddr          string `name:"pin_addr" description:"Send all requests to this ip:port backend, bypassing resolver and balancer"`
+	Connections      int    `name:"connections" description:"Number of gRPC connections jobs are spread over round-robin, avoids HTTP/2 limits of a single connection at high QPS"`
+	Width            uint32 `name:"width" description:"Target width for image scaling (0 = no scaling, or follow height)"`
+	Height           uint32 `name:"height" description:"Target height for image scaling (0 = no scaling, or follow width)"`
+	ScalingAlgorithm string `name:"scaling_algorithm" description:"Scaling algorithm of scaled photos: NEAREST_NEIGHBOR, BILINEAR, CATMULL_ROM, APPROX_BILINEAR, AUTO, NONE"`
 
 	// Parsed scaling algorithm enum value
 	scalingAlgo pb.ScalingAlgorithm
@@ -29,11 +33,28 @@ type CatPhotoLoad struct {
 
 // NewCatPhotoLoad creates a new CatPhotoLoad instance.
 func NewCatPhotoLoad() Load {
-	return &CatPhotoLoad{}
+	return &CatPhotoLoad{
+		Connections:      1,
+		ScalingAlgorithm: pb.ScalingAlgorithm_AUTO.String(),
+	}
 }
 
-func (l *CatPhotoLoad) Options() map[string]string {
-	return GetOptionsDesc(l)
+func (l *CatPhotoLoad) Options() []OptionDescription {
+	return GetOptionDescriptions(l)
+}
+
+// Validate checks the scaling algorithm against the proto enum.
+func (l *CatPhotoLoad) Validate() error {
+	if l.ScalingAlgorithm == "" {
+		l.scalingAlgo = pb.ScalingAlgorithm_AUTO
+		return nil
+	}
+	algo, err := parseScalingAlgorithm(l.ScalingAlgorithm)
+	if err != nil {
+		return err
+	}
+	l.scalingAlgo = algo
+	return nil
 }
 
 // Init creates the gRPC connection and fetches available cat and photo IDs from the server.
@@ -43,15 +64,11 @@ func (l *CatPhotoLoad) Init(ctx context.Context, options map[string]string) erro
 		return err
 	}
 
-	// Parse scaling algorithm if provided
-	if l.Width != 0 {
-		l.scalingAlgo, err = parseScalingAlgorithm(l.ScalingAlgorithm)
-		if err != nil {
-			return err
-		}
+	if err := ValidateOptions(l); err != nil {
+		return err
 	}
 
-	data, err := initCatPhotoData(ctx, l.Addr, l.Balancer)
+	data, err := initCatPhotoData(ctx, l.Addr, l.Balancer, l.PinAddr, l.Connections)
 	if err != nil {
 		return err
 	}
@@ -75,6 +92,9 @@ func (l *CatPhotoLoad) Job(ctx context.Context) (time.Duration, error) {
 	span.AddEvent("looking for photo", trace.WithAttributes(
 		attribute.Int("cat_id", int(catID)),
 		attribute.Int("photo_id", int(photoID)),
+		attribute.Int("width", int(l.Width)),
+		attribute.Int("height", int(l.Height)),
+		attribute.String("scaling_algorithm", l.scalingAlgo.String()),
 	))
 
 	start := time.Now()
@@ -82,16 +102,20 @@ func (l *CatPhotoLoad) Job(ctx context.Context) (time.Duration, error) {
 		CatId:   catID,
 		PhotoId: photoID,
 	}
-	if l.Width != 0 {
+	if l.Width != 0 || l.Height != 0 {
 		req.Width = l.Width
+		req.Height = l.Height
 		req.ScalingAlgorithm = l.scalingAlgo
 	}
-	_, err = l.client.GetPhoto(ctx, req)
+	addr, client := l.client()
+	resp, err := client.GetPhoto(ctx, req)
 	duration := time.Since(start)
 
+	span.SetAttributes(attribute.String("server_addr", addr), attribute.Bool("success", err == nil))
 	if err != nil {
 		span.SetStatus(codes.Error, err.Error())
 	} else {
+		l.addBytes(len(resp.PhotoData))
 		span.SetStatus(codes.Ok, "")
 	}
 
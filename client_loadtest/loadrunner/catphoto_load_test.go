@@ -0,0 +1,61 @@
+package loadrunner
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	pb "github.com/mhbvr/manul/proto"
+)
+
+func TestCatPhotoLoad_Scaling(t *testing.T) {
+	server, addr := startPhotoServer(t)
+
+	tests := []struct {
+		name    string
+		options map[string]string
+		want    *pb.GetPhotoRequest
+	}{
+		{"original", map[string]string{}, &pb.GetPhotoRequest{}},
+		{"thumbnail", map[string]string{"width": "100", "height": "50"},
+			&pb.GetPhotoRequest{Width: 100, Height: 50, ScalingAlgorithm: pb.ScalingAlgorithm_AUTO}},
+		{"height only", map[string]string{"height": "80", "scaling_algorithm": "bilinear"},
+			&pb.GetPhotoRequest{Height: 80, ScalingAlgorithm: pb.ScalingAlgorithm_BILINEAR}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.options["addr"] = addr
+			load := NewCatPhotoLoad()
+			if err := load.Init(context.Background(), tt.options); err != nil {
+				t.Fatalf("Init() failed: %v", err)
+			}
+			defer load.Close()
+
+			if _, err := load.Job(context.Background()); err != nil {
+				t.Fatalf("Job() failed: %v", err)
+			}
+			req := server.last.Load()
+			if req.Width != tt.want.Width || req.Height != tt.want.Height || req.ScalingAlgorithm != tt.want.ScalingAlgorithm {
+				t.Errorf("GetPhoto() request width=%d height=%d algorithm=%s, want width=%d height=%d algorithm=%s",
+					req.Width, req.Height, req.ScalingAlgorithm, tt.want.Width, tt.want.Height, tt.want.ScalingAlgorithm)
+			}
+		})
+	}
+}
+
+func TestCatPhotoLoad_Validate(t *testing.T) {
+	l := NewCatPhotoLoad().(*CatPhotoLoad)
+	if err := ParseOptions(map[string]string{"scaling_algorithm": "magic"}, l); err != nil {
+		t.Fatalf("ParseOptions() failed: %v", err)
+	}
+	err := l.Validate()
+	if err == nil || !strings.Contains(err.Error(), "magic") || !strings.Contains(err.Error(), "AUTO") {
+		t.Errorf("Validate() with an unknown algorithm = %v, want an error listing the valid algorithms", err)
+	}
+
+	l = NewCatPhotoLoad().(*CatPhotoLoad)
+	if err := l.Validate(); err != nil || l.scalingAlgo != pb.ScalingAlgorithm_AUTO {
+		t.Errorf("Validate() of default options = %v with algorithm %s, want AUTO", err, l.scalingAlgo)
+	}
+}
@@ -18,15 +18,23 @@ var (
 	streamTracer = otel.Tracer("stream_load_runner")
 )
 
+const (
+	defaultMinBatchSize = 1
+	defaultMaxBatchSize = 10
+)
+
 // CatPhotoStreamLoad implements the Load interface using streaming gRPC.
 type CatPhotoStreamLoad struct {
 	*catPhotoData
-	Addr             string `name:"addr" description:"Server address to connect"`
+	bytesRecorder
+	Addr             string `name:"addr" description:"Server address to connect, or comma-separated addresses with optional @weight to spread requests over, e.g. a:8081@3,b:8081@1" required:"true"`
 	Balancer         string `name:"balancer" description:"gRPC load balancing policy"`
+	PinAddr          string `name:"pin_addr" description:"Send all requests to this ip:port backend, bypassing resolver and balancer"`
+	Connections      int    `name:"connections" description:"Number of gRPC connections jobs are spread over round-robin, avoids HTTP/2 limits of a single connection at high QPS"`
 	MinBatchSize     int    `name:"min_batch_size" description:"Minimum number of photos to request per stream"`
 	MaxBatchSize     int    `name:"max_batch_size" description:"Maximum number of photos to request per stream"`
 	Width            uint32 `name:"width" description:"Target width for image scaling (0 = no scaling)"`
-	ScalingAlgorithm string `name:"scaling_algorithm" description:"Scaling algorithm: NEAREST_NEIGHBOR, BILINEAR, CATMULL_ROM, APPROX_BILINEAR"`
+	ScalingAlgorithm string `name:"scaling_algorithm" description:"Scaling algorithm: NEAREST_NEIGHBOR, BILINEAR, CATMULL_ROM, APPROX_BILINEAR, AUTO, NONE"`
 
 	// Parsed scaling algorithm enum value
 	scalingAlgo pb.ScalingAlgorithm
@@ -34,11 +42,29 @@ type CatPhotoStreamLoad struct {
 
 // NewCatPhotoStreamLoad creates a new streaming load implementation.
 func NewCatPhotoStreamLoad() Load {
-	return &CatPhotoStreamLoad{}
+	return &CatPhotoStreamLoad{
+		Connections:  1,
+		MinBatchSize: defaultMinBatchSize,
+		MaxBatchSize: defaultMaxBatchSize,
+	}
 }
 
-func (l *CatPhotoStreamLoad) Options() map[string]string {
-	return GetOptionsDesc(l)
+func (l *CatPhotoStreamLoad) Options() []OptionDescription {
+	return GetOptionDescriptions(l)
+}
+
+// Validate checks the batch size options.
+func (l *CatPhotoStreamLoad) Validate() error {
+	if l.MinBatchSize <= 0 {
+		return fmt.Errorf("min_batch_size must be positive, got %d", l.MinBatchSize)
+	}
+	if l.MaxBatchSize <= 0 {
+		return fmt.Errorf("max_batch_size must be positive, got %d", l.MaxBatchSize)
+	}
+	if l.MaxBatchSize < l.MinBatchSize {
+		return fmt.Errorf("max_batch_size (%d) must not be less than min_batch_size (%d)", l.MaxBatchSize, l.MinBatchSize)
+	}
+	return nil
 }
 
 // Init creates the gRPC connection and fetches available cat and photo IDs from the server.
@@ -48,6 +74,10 @@ func (l *CatPhotoStreamLoad) Init(ctx context.Context, options map[string]string
 		return err
 	}
 
+	if err := ValidateOptions(l); err != nil {
+		return err
+	}
+
 	// Parse scaling algorithm if provided
 	if l.Width != 0 {
 		l.scalingAlgo, err = parseScalingAlgorithm(l.ScalingAlgorithm)
@@ -56,7 +86,7 @@ func (l *CatPhotoStreamLoad) Init(ctx context.Context, options map[string]string
 		}
 	}
 
-	data, err := initCatPhotoData(ctx, l.Addr, l.Balancer)
+	data, err := initCatPhotoData(ctx, l.Addr, l.Balancer, l.PinAddr, l.Connections)
 	if err != nil {
 		return err
 	}
@@ -64,17 +94,21 @@ func (l *CatPhotoStreamLoad) Init(ctx context.Context, options map[string]string
 	return nil
 }
 
+// batchSize returns a random batch size between MinBatchSize and MaxBatchSize.
+// Sizes are clamped so that invalid options can never make rand.Intn panic.
+func (l *CatPhotoStreamLoad) batchSize() int {
+	minSize := max(l.MinBatchSize, 1)
+	maxSize := max(l.MaxBatchSize, minSize)
+	return minSize + rand.Intn(maxSize-minSize+1)
+}
+
 // Job executes a single streaming photo retrieval operation.
 // Returns the duration of the operation and any error that occurred.
 func (l *CatPhotoStreamLoad) Job(ctx context.Context) (time.Duration, error) {
 	ctx, span := streamTracer.Start(ctx, "get_cat_photos_stream", trace.WithNewRoot())
 	defer span.End()
 
-	// Calculate random batch size
-	batchSize := l.MinBatchSize
-	if l.MaxBatchSize > l.MinBatchSize {
-		batchSize = l.MinBatchSize + rand.Intn(l.MaxBatchSize-l.MinBatchSize+1)
-	}
+	batchSize := l.batchSize()
 
 	// Build a batch of random photo requests
 	photoRequests := make([]*pb.PhotoRequest, 0, batchSize)
@@ -103,8 +137,11 @@ func (l *CatPhotoStreamLoad) Job(ctx context.Context) (time.Duration, error) {
 		req.Width = l.Width
 		req.ScalingAlgorithm = l.scalingAlgo
 	}
-	stream, err := l.client.GetPhotosStream(ctx, req)
+	addr, client := l.client()
+	span.SetAttributes(attribute.String("server_addr", addr))
+	stream, err := client.GetPhotosStream(ctx, req)
 	if err != nil {
+		span.SetAttributes(attribute.Bool("success", false))
 		span.SetStatus(codes.Error, err.Error())
 		return time.Since(start), err
 	}
@@ -112,29 +149,36 @@ func (l *CatPhotoStreamLoad) Job(ctx context.Context) (time.Duration, error) {
 	// Receive all responses
 	var receivedCount int
 	var errorCount int
+	var receivedBytes int
 	for {
 		resp, err := stream.Recv()
 		if err == io.EOF {
 			break
 		}
 		if err != nil {
+			l.addBytes(receivedBytes)
+			span.SetAttributes(attribute.Bool("success", false))
 			span.SetStatus(codes.Error, err.Error())
 			return time.Since(start), err
 		}
 
 		receivedCount++
+		receivedBytes += len(resp.PhotoData)
 		if !resp.Success {
 			errorCount++
 		}
 	}
 
 	duration := time.Since(start)
+	l.addBytes(receivedBytes)
 
 	span.AddEvent("received responses", trace.WithAttributes(
 		attribute.Int("received_count", receivedCount),
 		attribute.Int("error_count", errorCount),
+		attribute.Int("received_bytes", receivedBytes),
 	))
 
+	span.SetAttributes(attribute.Bool("success", errorCount == 0))
 	if errorCount > 0 {
 		span.SetStatus(codes.Error, fmt.Sprintf("%d photos failed", errorCount))
 		return duration, fmt.Errorf("%d out of %d photos failed", errorCount, receivedCount)
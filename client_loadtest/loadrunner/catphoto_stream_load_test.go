@@ -0,0 +1,52 @@
+package loadrunner
+
+import (
+	"context"
+	"testing"
+)
+
+func TestCatPhotoStreamLoad_DefaultBatchSize(t *testing.T) {
+	l := NewCatPhotoStreamLoad().(*CatPhotoStreamLoad)
+	if err := ParseOptions(map[string]string{}, l); err != nil {
+		t.Fatalf("ParseOptions failed: %v", err)
+	}
+	if err := l.Validate(); err != nil {
+		t.Fatalf("Validate failed for default options: %v", err)
+	}
+
+	for i := 0; i < 100; i++ {
+		size := l.batchSize()
+		if size < defaultMinBatchSize || size > defaultMaxBatchSize {
+			t.Fatalf("batchSize() = %d, want in [%d, %d]", size, defaultMinBatchSize, defaultMaxBatchSize)
+		}
+	}
+}
+
+func TestCatPhotoStreamLoad_UnsetBatchSize(t *testing.T) {
+	// Zero values must not produce empty batches or panic
+	l := &CatPhotoStreamLoad{}
+	for i := 0; i < 100; i++ {
+		if size := l.batchSize(); size != 1 {
+			t.Fatalf("batchSize() = %d, want 1", size)
+		}
+	}
+}
+
+func TestCatPhotoStreamLoad_InvertedBatchSize(t *testing.T) {
+	l := &CatPhotoStreamLoad{MinBatchSize: 10, MaxBatchSize: 1}
+	for i := 0; i < 100; i++ {
+		if size := l.batchSize(); size != 10 {
+			t.Fatalf("batchSize() = %d, want 10", size)
+		}
+	}
+
+	// Init rejects inverted sizes before connecting to the server
+	l = NewCatPhotoStreamLoad().(*CatPhotoStreamLoad)
+	err := l.Init(context.Background(), map[string]string{
+		"min_batch_size": "10",
+		"max_batch_size": "1",
+	})
+	if err == nil {
+		t.Fatal("Init() expected error for max_batch_size < min_batch_size, got nil")
+	}
+}
@@ -0,0 +1,79 @@
+package loadrunner
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/status"
+)
+
+// ErrorSample is a job error with its gRPC status
+type ErrorSample struct {
+	Time    time.Time
+	Code    string
+	Message string
+}
+
+// errorSampler keeps the last job errors and logs them,
+// at most one log line per logInterval
+type errorSampler struct {
+	mu          sync.Mutex
+	samples     []ErrorSample // Ring buffer of the last errors
+	next        int           // Position of the next sample in the ring
+	size        int
+	logger      *log.Logger
+	logInterval time.Duration
+	lastLog     time.Time
+	suppressed  int // Errors not logged since lastLog
+}
+
+func newErrorSampler(size int, logInterval time.Duration, logger *log.Logger) *errorSampler {
+	return &errorSampler{
+		samples:     make([]ErrorSample, 0, size),
+		size:        size,
+		logger:      logger,
+		logInterval: logInterval,
+	}
+}
+
+func (s *errorSampler) Record(err error) {
+	st := status.Convert(err)
+	sample := ErrorSample{
+		Time:    time.Now(),
+		Code:    st.Code().String(),
+		Message: st.Message(),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.size > 0 {
+		if len(s.samples) < s.size {
+			s.samples = append(s.samples, sample)
+		} else {
+			s.samples[s.next] = sample
+		}
+		s.next = (s.next + 1) % s.size
+	}
+
+	if sample.Time.Sub(s.lastLog) < s.logInterval {
+		s.suppressed++
+		return
+	}
+	s.logger.Printf("Job error: %s: %s (%d more errors since last report)", sample.Code, sample.Message, s.suppressed)
+	s.lastLog = sample.Time
+	s.suppressed = 0
+}
+
+// Last returns the sampled errors, most recent first
+func (s *errorSampler) Last() []ErrorSample {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	res := make([]ErrorSample, 0, len(s.samples))
+	for i := 1; i <= len(s.samples); i++ {
+		res = append(res, s.samples[(s.next-i+len(s.samples))%len(s.samples)])
+	}
+	return res
+}
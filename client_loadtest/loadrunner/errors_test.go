@@ -0,0 +1,46 @@
+package loadrunner
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestErrorSampler(t *testing.T) {
+	var logs bytes.Buffer
+	sampler := newErrorSampler(3, time.Hour, log.New(&logs, "", 0))
+
+	sampler.Record(status.Error(codes.NotFound, "photo not found"))
+	for i := 0; i < 3; i++ {
+		sampler.Record(status.Error(codes.Unavailable, fmt.Sprintf("error %d", i)))
+	}
+	sampler.Record(errors.New("plain error"))
+
+	last := sampler.Last()
+	want := []struct{ code, message string }{
+		{codes.Unknown.String(), "plain error"},
+		{codes.Unavailable.String(), "error 2"},
+		{codes.Unavailable.String(), "error 1"},
+	}
+	if len(last) != len(want) {
+		t.Fatalf("Last() returned %d samples, want %d", len(last), len(want))
+	}
+	for i, w := range want {
+		if last[i].Code != w.code || last[i].Message != w.message {
+			t.Errorf("Last()[%d] = %s %q, want %s %q", i, last[i].Code, last[i].Message, w.code, w.message)
+		}
+	}
+
+	// Only the first error is logged within the log interval
+	lines := strings.Split(strings.TrimSpace(logs.String()), "\n")
+	if len(lines) != 1 || !strings.Contains(lines[0], "NotFound") {
+		t.Errorf("Logged %q, want one NotFound line", logs.String())
+	}
+}
@@ -8,8 +8,8 @@ import (
 // Load defines the interface for load testing operations.
 // Implementations provide initialization logic and job execution logic.
 type Load interface {
-	// OptionsInfo returns supported options with descriptions
-	Options() map[string]string
+	// Options returns supported options with descriptions and default values
+	Options() []OptionDescription
 
 	// Init initializes the load testing environment.
 	// This is called once before starting workers.
@@ -24,3 +24,9 @@ type Load interface {
 	// Close cleans up resources used by the load testing implementation.
 	Close() error
 }
+
+// BytesReporter is implemented by loads which report the number of bytes
+// received by jobs. The recorder is set before Init.
+type BytesReporter interface {
+	SetBytesRecorder(recorder func(int))
+}
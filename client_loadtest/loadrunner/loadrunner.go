@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"sync"
 	"time"
 
 	"github.com/mhbvr/manul/client_loadtest/worker"
@@ -22,9 +23,28 @@ type LoadRunner struct {
 	ctx    context.Context
 	cancel context.CancelCauseFunc
 
-	load        Load
-	loadOptions map[string]string
-	recorder    func(float64, bool)
+	load         Load
+	loadOptions  map[string]string
+	recorder     func(float64, bool)
+	bytes        func(int)
+	inFlightPool chan struct{}
+
+	// Stall watchdog settings, disabled when stallInterval is 0
+	stallInterval time.Duration
+	onStall       func(bool)
+
+	// Limits of the run, 0 means no limit
+	maxRequests int
+	maxDuration time.Duration
+
+	// Last config set, reported once the worker stopped at a limit
+	cfgMu sync.Mutex
+	cfg   worker.WorkerConfig
+
+	// Error sampling settings, sampler is nil when disabled
+	errorSamples     int
+	errorLogInterval time.Duration
+	errorSampler     *errorSampler
 
 	startTime time.Time
 	logger    *log.Logger
@@ -34,6 +54,8 @@ type LoadRunnerInfo struct {
 	StartTime   time.Time
 	MaxInFlight int
 	WorkerCfg   *worker.WorkerConfig
+	LastErrors  []ErrorSample   // Most recent first, empty if error sampling is disabled
+	Progress    worker.Progress // Requests and run time toward the limits
 }
 
 type Option func(*LoadRunner)
@@ -44,13 +66,15 @@ func NewLoadRunner(ctx context.Context,
 	load Load,
 	opts ...Option) (*LoadRunner, error) {
 
-	ctx, cancel := context.WithCancelCause(context.Background())
+	// Cancelling the parent context stops the worker like Close
+	ctx, cancel := context.WithCancelCause(ctx)
 	res := &LoadRunner{
 		ctx:         ctx,
 		cancel:      cancel,
 		maxInFlight: maxInFlight,
 		startTime:   time.Now(),
 		load:        load,
+		cfg:         *cfg,
 		logger:      log.New(io.Discard, "", 0),
 	}
 
@@ -58,6 +82,16 @@ func NewLoadRunner(ctx context.Context,
 		opt(res)
 	}
 
+	job := load.Job
+	if res.errorSamples > 0 || res.errorLogInterval > 0 {
+		res.errorSampler = newErrorSampler(res.errorSamples, res.errorLogInterval, res.logger)
+		job = res.sampledJob
+	}
+
+	if reporter, ok := load.(BytesReporter); ok && res.bytes != nil {
+		reporter.SetBytesRecorder(res.bytes)
+	}
+
 	// Initialize load
 	if err := load.Init(ctx, res.loadOptions); err != nil {
 		return nil, fmt.Errorf("failed to initialize load: %v", err)
@@ -75,9 +109,25 @@ func NewLoadRunner(ctx context.Context,
 		workerOpts = append(workerOpts, worker.WithRecorder(res.recorder))
 	}
 
+	if res.inFlightPool != nil {
+		workerOpts = append(workerOpts, worker.WithSharedInFlight(res.inFlightPool))
+	}
+
+	if res.stallInterval > 0 {
+		workerOpts = append(workerOpts, worker.WithStallWatchdog(res.stallInterval, res.onStall))
+	}
+
+	if res.maxRequests > 0 {
+		workerOpts = append(workerOpts, worker.WithMaxRequests(res.maxRequests))
+	}
+
+	if res.maxDuration > 0 {
+		workerOpts = append(workerOpts, worker.WithMaxDuration(res.maxDuration))
+	}
+
 	// Create worker
 	var err error
-	res.worker, err = worker.NewWorker(ctx, load.Job, workerOpts...)
+	res.worker, err = worker.NewWorker(ctx, job, workerOpts...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create worker: %v", err)
 	}
@@ -96,14 +146,79 @@ func WithRecorder(recorder func(float64, bool)) func(*LoadRunner) {
 	}
 }
 
+// WithBytesRecorder sets the function called with the number of bytes
+// received by each job, used only if the load is a BytesReporter
+func WithBytesRecorder(recorder func(int)) func(*LoadRunner) {
+	return func(lr *LoadRunner) {
+		lr.bytes = recorder
+	}
+}
+
+// WithSharedInFlight makes the runner share the in-flight limit with
+// other runners using the same pool
+func WithSharedInFlight(pool chan struct{}) func(*LoadRunner) {
+	return func(lr *LoadRunner) {
+		lr.inFlightPool = pool
+	}
+}
+
+// WithErrorSampling keeps the last n job errors for GetInfo and logs
+// job errors at most once per logInterval
+func WithErrorSampling(n int, logInterval time.Duration) func(*LoadRunner) {
+	return func(lr *LoadRunner) {
+		lr.errorSamples = n
+		lr.errorLogInterval = logInterval
+	}
+}
+
+// WithStallWatchdog reports the runner as stalled when no job can be
+// dispatched for interval, see worker.WithStallWatchdog
+func WithStallWatchdog(interval time.Duration, onStall func(bool)) func(*LoadRunner) {
+	return func(lr *LoadRunner) {
+		lr.stallInterval = interval
+		lr.onStall = onStall
+	}
+}
+
+// WithMaxRequests stops the runner after n requests, see
+// worker.WithMaxRequests
+func WithMaxRequests(n int) func(*LoadRunner) {
+	return func(lr *LoadRunner) {
+		lr.maxRequests = n
+	}
+}
+
+// WithMaxDuration stops the runner after running for d, see
+// worker.WithMaxDuration
+func WithMaxDuration(d time.Duration) func(*LoadRunner) {
+	return func(lr *LoadRunner) {
+		lr.maxDuration = d
+	}
+}
+
 func WithLoadOptions(options map[string]string) func(*LoadRunner) {
 	return func(lr *LoadRunner) {
 		lr.loadOptions = options
 	}
 }
 
+// sampledJob runs the load job and records its error
+func (lr *LoadRunner) sampledJob(ctx context.Context) (time.Duration, error) {
+	duration, err := lr.load.Job(ctx)
+	if err != nil {
+		lr.errorSampler.Record(err)
+	}
+	return duration, err
+}
+
 func (lr *LoadRunner) SetConfig(cfg *worker.WorkerConfig) error {
-	return lr.worker.SetConfig(cfg)
+	if err := lr.worker.SetConfig(cfg); err != nil {
+		return err
+	}
+	lr.cfgMu.Lock()
+	lr.cfg = *cfg
+	lr.cfgMu.Unlock()
+	return nil
 }
 
 func (lr *LoadRunner) GetInfo() (*LoadRunnerInfo, error) {
@@ -114,8 +229,20 @@ func (lr *LoadRunner) GetInfo() (*LoadRunnerInfo, error) {
 	}
 
 	res.WorkerCfg, err = lr.worker.GetConfig()
+	res.Progress = lr.worker.Progress()
 	if err != nil {
-		return nil, err
+		if !res.Progress.LimitReached {
+			return nil, err
+		}
+		// The worker stopped itself, report its last config
+		lr.cfgMu.Lock()
+		cfg := lr.cfg
+		lr.cfgMu.Unlock()
+		res.WorkerCfg = &cfg
+	}
+
+	if lr.errorSampler != nil {
+		res.LastErrors = lr.errorSampler.Last()
 	}
 
 	return res, nil
@@ -125,3 +252,12 @@ func (lr *LoadRunner) Close() {
 	lr.cancel(lrClosed)
 	lr.load.Close()
 }
+
+// CloseGraceful stops scheduling jobs, waits for in-flight jobs to finish
+// and closes the runner. If ctx is done first, the remaining jobs are
+// cancelled and the ctx error is returned.
+func (lr *LoadRunner) CloseGraceful(ctx context.Context) error {
+	err := lr.worker.Drain(ctx)
+	lr.Close()
+	return err
+}
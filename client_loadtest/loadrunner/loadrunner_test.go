@@ -0,0 +1,88 @@
+package loadrunner
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mhbvr/manul/client_loadtest/worker"
+)
+
+// fakeLoad parses its options and counts its jobs, each taking a millisecond
+type fakeLoad struct {
+	Addr      string `name:"addr" description:"Server address"`
+	BatchSize int    `name:"batch_size" description:"Batch size"`
+	jobs      atomic.Int64
+}
+
+func (l *fakeLoad) Options() []OptionDescription { return GetOptionDescriptions(l) }
+
+func (l *fakeLoad) Init(ctx context.Context, options map[string]string) error {
+	return ParseOptions(options, l)
+}
+
+func (l *fakeLoad) Job(ctx context.Context) (time.Duration, error) {
+	l.jobs.Add(1)
+	select {
+	case <-time.After(time.Millisecond):
+		return time.Millisecond, nil
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	}
+}
+
+func (l *fakeLoad) Close() error { return nil }
+
+func TestNewLoadRunner_ParentContext(t *testing.T) {
+	stopped := errors.New("parent stopped")
+	ctx, cancel := context.WithCancelCause(context.Background())
+	defer cancel(nil)
+
+	load := &fakeLoad{}
+	lr, err := NewLoadRunner(ctx, 1, &worker.WorkerConfig{InFlight: 1, Timeout: time.Second}, load)
+	if err != nil {
+		t.Fatalf("NewLoadRunner() failed: %v", err)
+	}
+	defer lr.Close()
+
+	time.Sleep(20 * time.Millisecond)
+	if load.jobs.Load() == 0 {
+		t.Fatal("No jobs executed before cancelling the parent context")
+	}
+
+	cancel(stopped)
+	if _, err := lr.GetInfo(); !errors.Is(err, stopped) {
+		t.Errorf("GetInfo() after cancelling the parent context = %v, want %v", err, stopped)
+	}
+
+	// A job dispatched before the cancel may still start
+	time.Sleep(10 * time.Millisecond)
+	jobs := load.jobs.Load()
+	time.Sleep(20 * time.Millisecond)
+	if got := load.jobs.Load(); got != jobs {
+		t.Errorf("Jobs after cancelling the parent context: %d, want %d", got, jobs)
+	}
+}
+
+func TestNewLoadRunner_LoadOptions(t *testing.T) {
+	load := &fakeLoad{}
+	lr, err := NewLoadRunner(context.Background(), 1, &worker.WorkerConfig{InFlight: 1, Timeout: time.Second}, load,
+		WithLoadOptions(map[string]string{"addr": "localhost:8081", "batch_size": "4"}))
+	if err != nil {
+		t.Fatalf("NewLoadRunner() failed: %v", err)
+	}
+	defer lr.Close()
+
+	if load.Addr != "localhost:8081" || load.BatchSize != 4 {
+		t.Errorf("Load options after Init: addr=%q batch_size=%d, want addr=%q batch_size=4", load.Addr, load.BatchSize, "localhost:8081")
+	}
+
+	// Invalid options fail the runner creation
+	_, err = NewLoadRunner(context.Background(), 1, &worker.WorkerConfig{InFlight: 1, Timeout: time.Second}, &fakeLoad{},
+		WithLoadOptions(map[string]string{"batch_size": "many"}))
+	if err == nil {
+		t.Error("NewLoadRunner() with an invalid option succeeded, want error")
+	}
+}
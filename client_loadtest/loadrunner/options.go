@@ -3,13 +3,16 @@ package loadrunner
 import (
 	"fmt"
 	"reflect"
+	"sort"
 	"strconv"
+	"strings"
 )
 
 // ParseOptions parses a map[string]string into a struct using field tags.
 // Supported tags:
 //   - `name:"option_name"` - the name of the option in the map
 //   - `description:"option description"` - documentation for the option
+//   - `required:"true"` - the option must be provided (checked by CheckOptions)
 //
 // Supported types: string, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, bool, float32, float64
 func ParseOptions(options map[string]string, target interface{}) error {
@@ -54,6 +57,46 @@ func ParseOptions(options map[string]string, target interface{}) error {
 	return nil
 }
 
+// CheckOptions verifies that every option in the map is supported by target
+// and that all required options are provided with a non-empty value.
+func CheckOptions(options map[string]string, target interface{}) error {
+	known := make(map[string]bool)
+	for _, desc := range GetOptionDescriptions(target) {
+		known[desc.Name] = true
+		if desc.Required && options[desc.Name] == "" {
+			return fmt.Errorf("required option %s is not set", desc.Name)
+		}
+	}
+
+	unknown := make([]string, 0)
+	for name := range options {
+		if !known[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("unknown options: %s", strings.Join(unknown, ", "))
+	}
+
+	return nil
+}
+
+// Validator is implemented by option structs that need to check parsed values,
+// e.g. value ranges or consistency between several options.
+type Validator interface {
+	Validate() error
+}
+
+// ValidateOptions calls Validate on target if it implements Validator.
+// It is supposed to be called after ParseOptions.
+func ValidateOptions(target interface{}) error {
+	if v, ok := target.(Validator); ok {
+		return v.Validate()
+	}
+	return nil
+}
+
 func setField(field reflect.Value, value string) error {
 	switch field.Kind() {
 	case reflect.String:
@@ -94,10 +137,18 @@ func setField(field reflect.Value, value string) error {
 	return nil
 }
 
-// GetOptionDesc returns a list of option descriptions
-// by inspecting the struct tags and current values.
-func GetOptionsDesc(target interface{}) map[string]string {
-	res := make(map[string]string)
+// OptionDescription describes a single load option.
+type OptionDescription struct {
+	Name         string `json:"name"`
+	Description  string `json:"description"`
+	DefaultValue string `json:"default_value"`
+	Required     bool   `json:"required"`
+}
+
+// GetOptionDescriptions returns a list of option descriptions in field order
+// by inspecting the struct tags. Current field values are reported as defaults.
+func GetOptionDescriptions(target interface{}) []OptionDescription {
+	res := make([]OptionDescription, 0)
 
 	v := reflect.ValueOf(target)
 	t := reflect.TypeOf(target)
@@ -114,12 +165,16 @@ func GetOptionsDesc(target interface{}) map[string]string {
 		field := t.Field(i)
 
 		optionName := field.Tag.Get("name")
-		if optionName == "" {
+		if optionName == "" || !field.IsExported() {
 			continue
 		}
 
-		description := field.Tag.Get("description")
-		res[optionName] = description
+		res = append(res, OptionDescription{
+			Name:         optionName,
+			Description:  field.Tag.Get("description"),
+			DefaultValue: fmt.Sprint(v.Field(i).Interface()),
+			Required:     field.Tag.Get("required") == "true",
+		})
 	}
 
 	return res
@@ -1,6 +1,7 @@
 package loadrunner
 
 import (
+	"fmt"
 	"testing"
 )
 
@@ -313,3 +314,76 @@ func TestParseOptions_NegativeUint(t *testing.T) {
 		t.Fatal("Expected error for negative uint, got nil")
 	}
 }
+
+type validatedOptions struct {
+	Min int `name:"min" description:"Minimum"`
+	Max int `name:"max" description:"Maximum"`
+}
+
+func (o *validatedOptions) Validate() error {
+	if o.Max < o.Min {
+		return fmt.Errorf("max < min")
+	}
+	return nil
+}
+
+func TestValidateOptions(t *testing.T) {
+	var target validatedOptions
+	if err := ParseOptions(map[string]string{"min": "1", "max": "10"}, &target); err != nil {
+		t.Fatalf("ParseOptions failed: %v", err)
+	}
+	if err := ValidateOptions(&target); err != nil {
+		t.Errorf("Expected valid options, got error: %v", err)
+	}
+
+	if err := ParseOptions(map[string]string{"min": "10", "max": "1"}, &target); err != nil {
+		t.Fatalf("ParseOptions failed: %v", err)
+	}
+	if err := ValidateOptions(&target); err == nil {
+		t.Error("Expected error for max < min, got nil")
+	}
+}
+
+func TestValidateOptions_NoValidator(t *testing.T) {
+	var target TestOptions
+	if err := ValidateOptions(&target); err != nil {
+		t.Errorf("Expected nil error for struct without Validate, got: %v", err)
+	}
+}
+
+type requiredOptions struct {
+	Addr  string `name:"addr" description:"Address" required:"true"`
+	Width int    `name:"width" description:"Width"`
+}
+
+func TestCheckOptions(t *testing.T) {
+	testCases := []struct {
+		name    string
+		options map[string]string
+		wantErr bool
+	}{
+		{"all known", map[string]string{"addr": "localhost:8081", "width": "100"}, false},
+		{"only required", map[string]string{"addr": "localhost:8081"}, false},
+		{"missing required", map[string]string{"width": "100"}, true},
+		{"empty required", map[string]string{"addr": ""}, true},
+		{"unknown option", map[string]string{"addr": "localhost:8081", "widht": "100"}, true},
+	}
+
+	for _, tc := range testCases {
+		err := CheckOptions(tc.options, &requiredOptions{})
+		if tc.wantErr && err == nil {
+			t.Errorf("%s: expected error, got nil", tc.name)
+		}
+		if !tc.wantErr && err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.name, err)
+		}
+	}
+}
+
+func TestGetOptionDescriptions_Required(t *testing.T) {
+	for _, desc := range GetOptionDescriptions(&requiredOptions{}) {
+		if desc.Required != (desc.Name == "addr") {
+			t.Errorf("Option '%s': unexpected Required = %t", desc.Name, desc.Required)
+		}
+	}
+}
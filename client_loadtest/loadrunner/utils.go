@@ -2,9 +2,15 @@ package loadrunner
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"maps"
 	"math/rand"
+	"net"
+	"slices"
+	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	pb "github.com/mhbvr/manul/proto"
@@ -15,22 +21,125 @@ import (
 	"google.golang.org/grpc/credentials/insecure"
 )
 
+// errNoPhotos is returned by Init of cat photo loads if the server has no
+// photos to request, e.g. a freshly deployed server with an empty database.
+var errNoPhotos = errors.New("server has no photos to request, add photos to its database first")
+
+// errNoCats is returned by Init of the listing load if the server has no cats.
+var errNoCats = errors.New("server has no cats to list photos of, add cats to its database first")
+
 // catPhotoData holds the common data for cat photo load implementations.
 type catPhotoData struct {
-	client pb.CatPhotosServiceClient
-	conn   *grpc.ClientConn
-	cats   []uint64
-	photos map[uint64][]uint64
+	backends    []*backend
+	totalWeight int
+	conns       []*grpc.ClientConn
+	cats        []uint64
+	photos      map[uint64][]uint64
+}
+
+// backend is a server address requests are sent to with a weight
+type backend struct {
+	addr    string
+	weight  int
+	clients []pb.CatPhotosServiceClient
+	next    atomic.Uint64 // Round-robin index into clients
+}
+
+// weightedAddr is an address of the addr option with its weight
+type weightedAddr struct {
+	addr   string
+	weight int
+}
+
+// parseWeightedAddrs parses a comma-separated list of server addresses,
+// each with an optional @weight suffix, e.g. "a:8081@3,b:8081@1".
+// Addresses without a weight have weight 1.
+func parseWeightedAddrs(addrs string) ([]weightedAddr, error) {
+	var res []weightedAddr
+	for _, addr := range strings.Split(addrs, ",") {
+		addr = strings.TrimSpace(addr)
+		weight := 1
+		if i := strings.LastIndexByte(addr, '@'); i >= 0 {
+			w, err := strconv.Atoi(addr[i+1:])
+			if err != nil || w < 1 {
+				return nil, fmt.Errorf("invalid weight in addr %q: must be a positive integer", addr)
+			}
+			addr, weight = addr[:i], w
+		}
+		if addr == "" {
+			return nil, fmt.Errorf("empty address in addr %q", addrs)
+		}
+		res = append(res, weightedAddr{addr: addr, weight: weight})
+	}
+	return res, nil
+}
+
+// bytesRecorder implements BytesReporter for the cat photo loads.
+type bytesRecorder struct {
+	recordBytes func(int)
+}
+
+// SetBytesRecorder sets the function called with the bytes received by a job.
+func (b *bytesRecorder) SetBytesRecorder(recorder func(int)) {
+	b.recordBytes = recorder
 }
 
-// initCatPhotoData initializes the gRPC connection and fetches cat/photo IDs.
-func initCatPhotoData(ctx context.Context, serverAddr string, balancer string) (*catPhotoData, error) {
+// addBytes reports n received bytes if a recorder is set.
+func (b *bytesRecorder) addBytes(n int) {
+	if b.recordBytes != nil {
+		b.recordBytes(n)
+	}
+}
+
+// pinnedTarget returns a gRPC target which connects only to pinAddr,
+// pinAddr must be an ip:port pair.
+func pinnedTarget(pinAddr string) (string, error) {
+	host, _, err := net.SplitHostPort(pinAddr)
+	if err != nil {
+		return "", fmt.Errorf("invalid pin_addr %q: %v", pinAddr, err)
+	}
+	if net.ParseIP(host) == nil {
+		return "", fmt.Errorf("invalid pin_addr %q: host must be an IP address", pinAddr)
+	}
+	return "passthrough:///" + pinAddr, nil
+}
+
+// newCatPhotoData creates the gRPC connections without fetching IDs.
+// serverAddr is a list of addresses with weights, see parseWeightedAddrs.
+// Each job picks an address with a probability proportional to its weight,
+// IDs are fetched from the first address. If pinAddr is set, all
+// requests go to this ip:port instead of the resolved serverAddr backends,
+// using pick_first balancing.
+//
+// Jobs are spread round-robin over connections independent gRPC
+// connections, so at high QPS a single HTTP/2 connection's flow control and
+// concurrent stream limit do not bottleneck the client. Each connection runs
+// its own balancer, so this does not replace load balancing across backends:
+// with pick_first every connection may still pick the same backend.
+func newCatPhotoData(serverAddr string, balancer string, pinAddr string, connections int) (*catPhotoData, error) {
 	var err error
+	if connections < 1 {
+		return nil, fmt.Errorf("invalid connections %d: must be at least 1", connections)
+	}
+	addrs, err := parseWeightedAddrs(serverAddr)
+	if err != nil {
+		return nil, err
+	}
 	grpcOpts := []grpc.DialOption{
 		grpc.WithTransportCredentials(insecure.NewCredentials()),
 		grpc.WithStatsHandler(otelgrpc.NewClientHandler()),
 	}
 
+	if pinAddr != "" {
+		if len(addrs) > 1 {
+			return nil, fmt.Errorf("pin_addr cannot be used with several addresses in addr")
+		}
+		if addrs[0].addr, err = pinnedTarget(pinAddr); err != nil {
+			return nil, err
+		}
+		balancer = "pick_first"
+	}
+
 	if balancer != "" {
 		cfg := fmt.Sprintf(`{"loadBalancingPolicy":"%s"}`, balancer)
 		grpcOpts = append(grpcOpts, grpc.WithDefaultServiceConfig(cfg))
@@ -41,46 +150,153 @@ func initCatPhotoData(ctx context.Context, serverAddr string, balancer string) (
 		cats:   make([]uint64, 0),
 	}
 
-	// Create new gRPC connection
-	data.conn, err = grpc.NewClient(serverAddr, grpcOpts...)
-	if err != nil {
-		return nil, fmt.Errorf("failed to connect to server: %v", err)
+	// Create new gRPC connections to each address
+	for _, addr := range addrs {
+		b := &backend{addr: addr.addr, weight: addr.weight}
+		for i := 0; i < connections; i++ {
+			conn, err := grpc.NewClient(addr.addr, grpcOpts...)
+			if err != nil {
+				data.close()
+				return nil, fmt.Errorf("failed to connect to server %s: %v", addr.addr, err)
+			}
+			data.conns = append(data.conns, conn)
+			b.clients = append(b.clients, pb.NewCatPhotosServiceClient(conn))
+		}
+		data.backends = append(data.backends, b)
+		data.totalWeight += b.weight
 	}
+	return data, nil
+}
 
-	data.client = pb.NewCatPhotosServiceClient(data.conn)
+// initCatPhotoData creates the gRPC connections like newCatPhotoData and
+// fetches the IDs of the cats with photos and of their photos.
+func initCatPhotoData(ctx context.Context, serverAddr string, balancer string, pinAddr string, connections int) (*catPhotoData, error) {
+	data, err := newCatPhotoData(serverAddr, balancer, pinAddr, connections)
+	if err != nil {
+		return nil, err
+	}
+	listClient := data.backends[0].client()
 
 	// Fetch available IDs
 	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
 	defer cancel()
 
 	// Get all cat IDs
-	catsResp, err := data.client.ListCats(ctx, &pb.ListCatsRequest{})
+	catIDs, err := listAllCats(ctx, listClient)
 	if err != nil {
-		data.conn.Close()
+		data.close()
 		return nil, err
 	}
 
 	// Get photo IDs for each cat, only keeping cats with photos
-	for _, catID := range catsResp.CatIds {
-		photosResp, err := data.client.ListPhotos(ctx, &pb.ListPhotosRequest{CatId: catID})
+	for _, catID := range catIDs {
+		photoIDs, err := listAllPhotos(ctx, listClient, catID)
 		if err != nil {
 			continue
 		}
-		if len(photosResp.PhotoIds) > 0 {
+		if len(photoIDs) > 0 {
 			data.cats = append(data.cats, catID)
-			data.photos[catID] = photosResp.PhotoIds
+			data.photos[catID] = photoIDs
 		}
 	}
 
+	if len(data.cats) == 0 {
+		data.close()
+		return nil, errNoPhotos
+	}
+
 	return data, nil
 }
 
-// close closes the gRPC connection.
+// initCatData creates the gRPC connections like newCatPhotoData and fetches
+// only the cat IDs, cats without photos included.
+func initCatData(ctx context.Context, serverAddr string, balancer string, pinAddr string, connections int) (*catPhotoData, error) {
+	data, err := newCatPhotoData(serverAddr, balancer, pinAddr, connections)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	data.cats, err = listAllCats(ctx, data.backends[0].client())
+	if err != nil {
+		data.close()
+		return nil, err
+	}
+	if len(data.cats) == 0 {
+		data.close()
+		return nil, errNoCats
+	}
+
+	return data, nil
+}
+
+// listAllCats returns the cat IDs of all ListCats pages
+func listAllCats(ctx context.Context, client pb.CatPhotosServiceClient) ([]uint64, error) {
+	var catIDs []uint64
+	req := &pb.ListCatsRequest{}
+	for {
+		resp, err := client.ListCats(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		catIDs = append(catIDs, resp.CatIds...)
+		if resp.NextPageToken == "" {
+			return catIDs, nil
+		}
+		req.PageToken = resp.NextPageToken
+	}
+}
+
+// listAllPhotos returns the photo IDs of a cat from all ListPhotos pages
+func listAllPhotos(ctx context.Context, client pb.CatPhotosServiceClient, catID uint64) ([]uint64, error) {
+	var photoIDs []uint64
+	req := &pb.ListPhotosRequest{CatId: catID}
+	for {
+		resp, err := client.ListPhotos(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		photoIDs = append(photoIDs, resp.PhotoIds...)
+		if resp.NextPageToken == "" {
+			return photoIDs, nil
+		}
+		req.PageToken = resp.NextPageToken
+	}
+}
+
+// client picks a backend by weight and returns its address and the client
+// of its next connection in round-robin order.
+func (d *catPhotoData) client() (string, pb.CatPhotosServiceClient) {
+	b := d.backends[0]
+	if len(d.backends) > 1 {
+		n := rand.Intn(d.totalWeight)
+		for _, b = range d.backends {
+			if n < b.weight {
+				break
+			}
+			n -= b.weight
+		}
+	}
+	return b.addr, b.client()
+}
+
+// client returns the client of the next connection in round-robin order.
+func (b *backend) client() pb.CatPhotosServiceClient {
+	i := b.next.Add(1) - 1
+	return b.clients[i%uint64(len(b.clients))]
+}
+
+// close closes the gRPC connections.
 func (d *catPhotoData) close() error {
-	if d.conn != nil {
-		return d.conn.Close()
+	var firstErr error
+	for _, conn := range d.conns {
+		if err := conn.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
 	}
-	return nil
+	return firstErr
 }
 
 // getRandomPhoto returns a random cat ID and photo ID.
@@ -97,6 +313,17 @@ func (d *catPhotoData) getRandomPhoto() (catID uint64, photoID uint64, err error
 	return catID, photoID, nil
 }
 
+// scalingAlgorithmNames returns the names of the ScalingAlgorithm enum
+// values in the order of the proto
+func scalingAlgorithmNames() []string {
+	values := slices.Sorted(maps.Keys(pb.ScalingAlgorithm_name))
+	names := make([]string, 0, len(values))
+	for _, value := range values {
+		names = append(names, pb.ScalingAlgorithm_name[value])
+	}
+	return names
+}
+
 // parseScalingAlgorithm converts a string to the corresponding ScalingAlgorithm enum.
 // Returns an error if the algorithm string is not a valid algorithm name.
 // Caller should check for empty string before calling this function.
@@ -106,7 +333,7 @@ func parseScalingAlgorithm(algorithm string) (pb.ScalingAlgorithm, error) {
 
 	value, ok := pb.ScalingAlgorithm_value[enumName]
 	if !ok {
-		return 0, fmt.Errorf("invalid scaling algorithm: %s (valid options: NEAREST_NEIGHBOR, BILINEAR, CATMULL_ROM, APPROX_BILINEAR)", algorithm)
+		return 0, fmt.Errorf("invalid scaling algorithm: %s (valid options: %s)", algorithm, strings.Join(scalingAlgorithmNames(), ", "))
 	}
 
 	return pb.ScalingAlgorithm(value), nil
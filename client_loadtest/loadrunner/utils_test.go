@@ -0,0 +1,248 @@
+package loadrunner
+
+import (
+	"context"
+	"errors"
+	"net"
+	"slices"
+	"sync/atomic"
+	"testing"
+
+	pb "github.com/mhbvr/manul/proto"
+	"google.golang.org/grpc"
+)
+
+func TestPinnedTarget(t *testing.T) {
+	tests := []struct {
+		pinAddr string
+		want    string
+		wantErr bool
+	}{
+		{pinAddr: "10.0.0.1:8081", want: "passthrough:///10.0.0.1:8081"},
+		{pinAddr: "[::1]:8081", want: "passthrough:///[::1]:8081"},
+		{pinAddr: "10.0.0.1", wantErr: true},
+		{pinAddr: "cat-photos.default:8081", wantErr: true},
+		{pinAddr: "k8s://cat-photos.default:8081", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := pinnedTarget(tt.pinAddr)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("pinnedTarget(%q) error = %v, wantErr %v", tt.pinAddr, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("pinnedTarget(%q) = %q, want %q", tt.pinAddr, got, tt.want)
+		}
+	}
+}
+
+func TestCatPhotoData_ClientRoundRobin(t *testing.T) {
+	b := &backend{addr: "localhost:8081", weight: 1}
+	for i := 0; i < 3; i++ {
+		b.clients = append(b.clients, pb.NewCatPhotosServiceClient(nil))
+	}
+	data := &catPhotoData{backends: []*backend{b}, totalWeight: 1}
+
+	for i := 0; i < 6; i++ {
+		if addr, got := data.client(); got != b.clients[i%3] || addr != b.addr {
+			t.Errorf("client() call %d returned %s client %p, want %s client %p", i, addr, got, b.addr, b.clients[i%3])
+		}
+	}
+}
+
+func TestParseWeightedAddrs(t *testing.T) {
+	tests := []struct {
+		addr    string
+		want    []weightedAddr
+		wantErr bool
+	}{
+		{addr: "localhost:8081", want: []weightedAddr{{"localhost:8081", 1}}},
+		{addr: "k8s://cat-photos.default:8081", want: []weightedAddr{{"k8s://cat-photos.default:8081", 1}}},
+		{addr: "a:8081@3, b:8081@1", want: []weightedAddr{{"a:8081", 3}, {"b:8081", 1}}},
+		{addr: "[::1]:8081@2,b:8081", want: []weightedAddr{{"[::1]:8081", 2}, {"b:8081", 1}}},
+		{addr: "a:8081@0", wantErr: true},
+		{addr: "a:8081@x", wantErr: true},
+		{addr: "a:8081,,b:8081", wantErr: true},
+		{addr: "@2", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseWeightedAddrs(tt.addr)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseWeightedAddrs(%q) error = %v, wantErr %v", tt.addr, err, tt.wantErr)
+			continue
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("parseWeightedAddrs(%q) = %v, want %v", tt.addr, got, tt.want)
+		}
+	}
+}
+
+func TestCatPhotoData_ClientWeights(t *testing.T) {
+	data := &catPhotoData{}
+	for _, addr := range []weightedAddr{{"a", 3}, {"b", 1}} {
+		data.backends = append(data.backends, &backend{
+			addr:    addr.addr,
+			weight:  addr.weight,
+			clients: []pb.CatPhotosServiceClient{pb.NewCatPhotosServiceClient(nil)},
+		})
+		data.totalWeight += addr.weight
+	}
+
+	picks := make(map[string]int)
+	for i := 0; i < 4000; i++ {
+		addr, _ := data.client()
+		picks[addr]++
+	}
+	if picks["a"] < 2700 || picks["a"] > 3300 {
+		t.Errorf("client() picked a %d and b %d times out of 4000, want about 3:1", picks["a"], picks["b"])
+	}
+}
+
+func TestInitCatPhotoData_InvalidConnections(t *testing.T) {
+	if _, err := initCatPhotoData(context.Background(), "localhost:8081", "", "", 0); err == nil {
+		t.Errorf("initCatPhotoData() with 0 connections succeeded, want an error")
+	}
+}
+
+// emptyServer serves an empty database
+type emptyServer struct {
+	pb.UnimplementedCatPhotosServiceServer
+}
+
+func (s *emptyServer) ListCats(ctx context.Context, req *pb.ListCatsRequest) (*pb.ListCatsResponse, error) {
+	return &pb.ListCatsResponse{}, nil
+}
+
+func TestInitCatPhotoData_EmptyDatabase(t *testing.T) {
+	lis, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	grpcServer := grpc.NewServer()
+	pb.RegisterCatPhotosServiceServer(grpcServer, &emptyServer{})
+	go grpcServer.Serve(lis)
+	t.Cleanup(grpcServer.Stop)
+
+	for _, tc := range []struct {
+		load Load
+		want error
+	}{
+		{NewCatPhotoLoad(), errNoPhotos},
+		{NewCatPhotoStreamLoad(), errNoPhotos},
+		{NewCatListingLoad(), errNoCats},
+	} {
+		err := tc.load.Init(context.Background(), map[string]string{"addr": lis.Addr().String()})
+		if !errors.Is(err, tc.want) {
+			t.Errorf("%T.Init() error = %v, want %v", tc.load, err, tc.want)
+		}
+	}
+}
+
+// catsServer serves cats without photos and counts the ListPhotos requests
+type catsServer struct {
+	pb.UnimplementedCatPhotosServiceServer
+	listPhotos atomic.Int64
+}
+
+func (s *catsServer) ListCats(ctx context.Context, req *pb.ListCatsRequest) (*pb.ListCatsResponse, error) {
+	return &pb.ListCatsResponse{CatIds: []uint64{1, 2}}, nil
+}
+
+func (s *catsServer) ListPhotos(ctx context.Context, req *pb.ListPhotosRequest) (*pb.ListPhotosResponse, error) {
+	s.listPhotos.Add(1)
+	return &pb.ListPhotosResponse{}, nil
+}
+
+func TestCatListingLoad_InitCatsWithoutPhotos(t *testing.T) {
+	lis, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	server := &catsServer{}
+	grpcServer := grpc.NewServer()
+	pb.RegisterCatPhotosServiceServer(grpcServer, server)
+	go grpcServer.Serve(lis)
+	t.Cleanup(grpcServer.Stop)
+
+	load := NewCatListingLoad()
+	if err := load.Init(context.Background(), map[string]string{"addr": lis.Addr().String()}); err != nil {
+		t.Fatalf("Init() failed: %v", err)
+	}
+	defer load.Close()
+	if n := server.listPhotos.Load(); n != 0 {
+		t.Errorf("Init() sent %d ListPhotos requests, want 0", n)
+	}
+
+	if _, err := load.Job(context.Background()); err != nil {
+		t.Errorf("Job() failed: %v", err)
+	}
+}
+
+func TestGetRandomPhoto_NoCats(t *testing.T) {
+	data := &catPhotoData{photos: make(map[uint64][]uint64)}
+	if _, _, err := data.getRandomPhoto(); err == nil {
+		t.Errorf("getRandomPhoto() without cats succeeded, want an error")
+	}
+}
+
+// photoServer serves one photo and counts the GetPhoto requests
+type photoServer struct {
+	pb.UnimplementedCatPhotosServiceServer
+	requests atomic.Int64
+	last     atomic.Pointer[pb.GetPhotoRequest]
+}
+
+func (s *photoServer) ListCats(ctx context.Context, req *pb.ListCatsRequest) (*pb.ListCatsResponse, error) {
+	return &pb.ListCatsResponse{CatIds: []uint64{1}}, nil
+}
+
+func (s *photoServer) ListPhotos(ctx context.Context, req *pb.ListPhotosRequest) (*pb.ListPhotosResponse, error) {
+	return &pb.ListPhotosResponse{PhotoIds: []uint64{1}}, nil
+}
+
+func (s *photoServer) GetPhoto(ctx context.Context, req *pb.GetPhotoRequest) (*pb.GetPhotoResponse, error) {
+	s.requests.Add(1)
+	s.last.Store(req)
+	return &pb.GetPhotoResponse{PhotoData: []byte("photo")}, nil
+}
+
+func startPhotoServer(t *testing.T) (*photoServer, string) {
+	t.Helper()
+	lis, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	server := &photoServer{}
+	grpcServer := grpc.NewServer()
+	pb.RegisterCatPhotosServiceServer(grpcServer, server)
+	go grpcServer.Serve(lis)
+	t.Cleanup(grpcServer.Stop)
+	return server, lis.Addr().String()
+}
+
+func TestCatPhotoLoad_WeightedAddrs(t *testing.T) {
+	first, firstAddr := startPhotoServer(t)
+	second, secondAddr := startPhotoServer(t)
+
+	load := NewCatPhotoLoad()
+	if err := load.Init(context.Background(), map[string]string{"addr": firstAddr + "@3," + secondAddr + "@1"}); err != nil {
+		t.Fatalf("Init() failed: %v", err)
+	}
+	defer load.Close()
+
+	for i := 0; i < 400; i++ {
+		if _, err := load.Job(context.Background()); err != nil {
+			t.Fatalf("Job() failed: %v", err)
+		}
+	}
+	if n := first.requests.Load(); n < 250 || n > 350 || n+second.requests.Load() != 400 {
+		t.Errorf("Servers got %d and %d requests, want about 300 and 100", n, second.requests.Load())
+	}
+
+	err := NewCatPhotoLoad().Init(context.Background(), map[string]string{"addr": firstAddr + "," + secondAddr, "pin_addr": "127.0.0.1:8081"})
+	if err == nil {
+		t.Errorf("Init() with pin_addr and several addresses succeeded, want error")
+	}
+}
@@ -4,11 +4,14 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"maps"
+	"math/rand"
 	"reflect"
 	"sort"
 	"sync"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
 	dto "github.com/prometheus/client_model/go"
 
 	_ "github.com/mhbvr/manul/k8s_grpc_resolver"
@@ -16,7 +19,7 @@ import (
 	"github.com/mhbvr/manul/client_loadtest/worker"
 )
 
-func generator(mode string) (func(float64) time.Duration, error) {
+func generator(mode string) (func(*rand.Rand, float64) time.Duration, error) {
 	switch mode {
 	case "asap":
 		return nil, nil
@@ -28,12 +31,33 @@ func generator(mode string) (func(float64) time.Duration, error) {
 	return nil, fmt.Errorf("unknown mode: %v", mode)
 }
 
+const (
+	// Number of last job errors kept for each runner
+	runnerErrorSamples = 10
+	// Min interval between job error log lines of a runner
+	runnerErrorLogInterval = 5 * time.Second
+
+	// Window of the observed QPS of all runners together
+	aggregateRateWindow = 30 * time.Second
+)
+
+// RunLimits stops a runner after a number of requests or a run time,
+// whichever comes first. Zero values mean no limit.
+type RunLimits struct {
+	MaxRequests int
+	MaxDuration time.Duration
+}
+
 type runnerInfo struct {
 	runner      *loadrunner.LoadRunner
 	id          string
 	loadType    string
 	loadOptions map[string]string
 	mode        string
+	limits      RunLimits
+
+	// Creation time of the runner, kept when the LoadRunner is recreated
+	startTime time.Time
 }
 
 // LoadConstructor is a function that creates a new Load instance
@@ -48,25 +72,52 @@ type LoadTester struct {
 	// Global max in flight for all runners
 	maxInFlight int
 
+	// In-flight pool shared by all runners, sized to maxInFlight
+	inFlightPool chan struct{}
+
+	// Max number of runners, 0 means no limit
+	maxRunners int
+
+	// Time without dispatched requests after which a runner is reported
+	// as stalled, 0 disables the watchdog
+	stallInterval time.Duration
+
 	// Multiple runner instances
 	runners      map[string]*runnerInfo
 	nextRunnerID int
 
 	metrics *Metrics
+
+	// Observed QPS of all runners, updated on each aggregate
+	rate *rateWindow
+
+	// Parent context of all runners, cancelled by Close
+	ctx    context.Context
+	cancel context.CancelFunc
 }
 
-func NewLoadTester(maxInFlight int) (*LoadTester, error) {
+func NewLoadTester(maxInFlight int, maxRunners int, stallInterval time.Duration, reg prometheus.Registerer) (*LoadTester, error) {
+	if maxInFlight <= 0 {
+		return nil, fmt.Errorf("invalid max in flight %d: must be positive", maxInFlight)
+	}
+
 	lt := &LoadTester{
-		loadRegistry: make(map[string]LoadConstructor),
-		maxInFlight:  maxInFlight,
-		runners:      make(map[string]*runnerInfo),
-		nextRunnerID: 0,
-		metrics:      NewMetrics(),
+		loadRegistry:  make(map[string]LoadConstructor),
+		maxInFlight:   maxInFlight,
+		maxRunners:    maxRunners,
+		stallInterval: stallInterval,
+		inFlightPool:  make(chan struct{}, maxInFlight),
+		runners:       make(map[string]*runnerInfo),
+		nextRunnerID:  0,
+		metrics:       NewMetrics(reg),
+		rate:          newRateWindow(aggregateRateWindow),
 	}
+	lt.ctx, lt.cancel = context.WithCancel(context.Background())
 
 	// Register available load types
 	lt.RegisterLoad(loadrunner.NewCatPhotoLoad)
 	lt.RegisterLoad(loadrunner.NewCatPhotoStreamLoad)
+	lt.RegisterLoad(loadrunner.NewCatListingLoad)
 
 	return lt, nil
 }
@@ -94,7 +145,7 @@ func (lt *LoadTester) GetAvailableLoadTypes() []string {
 }
 
 // GetLoadOptions returns the available options for a specific load type
-func (lt *LoadTester) GetLoadOptions(loadType string) (map[string]string, error) {
+func (lt *LoadTester) GetLoadOptions(loadType string) ([]loadrunner.OptionDescription, error) {
 	constructor, exists := lt.loadRegistry[loadType]
 	if !exists {
 		return nil, fmt.Errorf("unknown load type: %s", loadType)
@@ -104,58 +155,134 @@ func (lt *LoadTester) GetLoadOptions(loadType string) (map[string]string, error)
 	return load.Options(), nil
 }
 
+// CheckLoadOptions verifies that options are known for the load type
+// and that all required options are set
+func (lt *LoadTester) CheckLoadOptions(loadType string, options map[string]string) error {
+	constructor, exists := lt.loadRegistry[loadType]
+	if !exists {
+		return fmt.Errorf("unknown load type: %s", loadType)
+	}
+
+	return loadrunner.CheckOptions(options, constructor())
+}
+
 // GetMaxInFlight returns the global max in flight value
 func (lt *LoadTester) GetMaxInFlight() int {
 	return lt.maxInFlight
 }
 
-func (lt *LoadTester) AddRunner(
+// ValidateRunner checks a runner configuration without starting it:
+// the load type and mode must exist, the options must parse and the
+// load must initialize, which includes connecting to the server.
+func (lt *LoadTester) ValidateRunner(
+	ctx context.Context,
 	loadType string,
 	loadOptions map[string]string,
-	inFlight int,
-	qps float64,
-	timeout time.Duration,
 	mode string) error {
 
-	// Validate load type
 	constructor, exists := lt.loadRegistry[loadType]
 	if !exists {
 		return fmt.Errorf("unknown load type: %s", loadType)
 	}
 
-	generator, err := generator(mode)
-	if err != nil {
+	if _, err := generator(mode); err != nil {
 		return err
 	}
 
-	lt.mu.Lock()
-	defer lt.mu.Unlock()
+	if err := loadrunner.CheckOptions(loadOptions, constructor()); err != nil {
+		return err
+	}
 
-	runnerID := fmt.Sprintf("%s-%d", loadType, lt.nextRunnerID)
-	lt.nextRunnerID++
+	load := constructor()
+	if err := load.Init(ctx, loadOptions); err != nil {
+		return fmt.Errorf("failed to initialize load: %v", err)
+	}
+	return load.Close()
+}
+
+// GetMaxRunners returns the max number of runners, 0 means no limit
+func (lt *LoadTester) GetMaxRunners() int {
+	return lt.maxRunners
+}
+
+// newLoadRunner creates and starts a LoadRunner reporting metrics as runnerID
+func (lt *LoadTester) newLoadRunner(
+	runnerID string,
+	load loadrunner.Load,
+	loadOptions map[string]string,
+	cfg *worker.WorkerConfig,
+	limits RunLimits) (*loadrunner.LoadRunner, error) {
 
 	// Create logger for this runner
 	logger := log.New(log.Writer(), fmt.Sprintf("[%s] ", runnerID), log.LstdFlags)
 
-	// Create load implementation
-	load := constructor()
-
-	runner, err := loadrunner.NewLoadRunner(
-		context.Background(),
+	return loadrunner.NewLoadRunner(
+		lt.ctx,
 		lt.maxInFlight,
-		&worker.WorkerConfig{
-			InFlight:          inFlight,
-			IntervalGenerator: generator,
-			Qps:               qps,
-			Timeout:           timeout,
-		},
+		cfg,
 		load,
 		loadrunner.WithLoadOptions(loadOptions),
 		loadrunner.WithRecorder(func(durationSeconds float64, success bool) {
 			lt.metrics.RecordRequest(runnerID, durationSeconds, success)
 		}),
+		loadrunner.WithBytesRecorder(func(bytes int) {
+			lt.metrics.RecordBytes(runnerID, bytes)
+		}),
 		loadrunner.WithLogger(logger),
+		loadrunner.WithSharedInFlight(lt.inFlightPool),
+		loadrunner.WithErrorSampling(runnerErrorSamples, runnerErrorLogInterval),
+		loadrunner.WithStallWatchdog(lt.stallInterval, func(stalled bool) {
+			lt.metrics.RecordStall(runnerID, stalled)
+		}),
+		loadrunner.WithMaxRequests(limits.MaxRequests),
+		loadrunner.WithMaxDuration(limits.MaxDuration),
 	)
+}
+
+func (lt *LoadTester) AddRunner(
+	loadType string,
+	loadOptions map[string]string,
+	inFlight int,
+	qps float64,
+	timeout time.Duration,
+	mode string,
+	limits RunLimits) error {
+
+	// Validate load type
+	constructor, exists := lt.loadRegistry[loadType]
+	if !exists {
+		return fmt.Errorf("unknown load type: %s", loadType)
+	}
+
+	generator, err := generator(mode)
+	if err != nil {
+		return err
+	}
+
+	if err := loadrunner.CheckOptions(loadOptions, constructor()); err != nil {
+		return err
+	}
+
+	if limits.MaxRequests < 0 || limits.MaxDuration < 0 {
+		return fmt.Errorf("negative run limits: %+v", limits)
+	}
+
+	lt.mu.Lock()
+	defer lt.mu.Unlock()
+
+	if lt.maxRunners > 0 && len(lt.runners) >= lt.maxRunners {
+		return fmt.Errorf("too many runners: limit is %d", lt.maxRunners)
+	}
+
+	runnerID := fmt.Sprintf("%s-%d", loadType, lt.nextRunnerID)
+	lt.nextRunnerID++
+
+	runner, err := lt.newLoadRunner(runnerID, constructor(), loadOptions, &worker.WorkerConfig{
+		InFlight:          inFlight,
+		IntervalGenerator: generator,
+		Qps:               qps,
+		Timeout:           timeout,
+	}, limits)
 	if err != nil {
 		return err
 	}
@@ -166,6 +293,8 @@ func (lt *LoadTester) AddRunner(
 		loadType:    loadType,
 		loadOptions: loadOptions,
 		mode:        mode,
+		limits:      limits,
+		startTime:   time.Now(),
 	}
 	return nil
 }
@@ -184,7 +313,30 @@ func (lt *LoadTester) RemoveRunner(runnerID string) error {
 	return nil
 }
 
+// RemoveRunnerGraceful removes the runner after waiting for its in-flight
+// jobs to finish, or for ctx to be done. The runner is removed from the list
+// right away, so the wait does not block other runner changes.
+func (lt *LoadTester) RemoveRunnerGraceful(ctx context.Context, runnerID string) error {
+	lt.mu.Lock()
+	info, exists := lt.runners[runnerID]
+	if !exists {
+		lt.mu.Unlock()
+		return fmt.Errorf("runner %s not found", runnerID)
+	}
+	delete(lt.runners, runnerID)
+	lt.mu.Unlock()
+
+	if err := info.runner.CloseGraceful(ctx); err != nil {
+		return fmt.Errorf("runner %s closed before its jobs finished: %v", runnerID, err)
+	}
+	return nil
+}
+
+// UpdateRunner changes the runner configuration. If loadOptions is not nil
+// and differs from the current options, the underlying LoadRunner is
+// recreated with the new options, keeping the runner ID and its metrics.
 func (lt *LoadTester) UpdateRunner(runnerID string,
+	loadOptions map[string]string,
 	inFlight int,
 	qps float64,
 	timeout time.Duration,
@@ -195,25 +347,56 @@ func (lt *LoadTester) UpdateRunner(runnerID string,
 		return err
 	}
 
+	cfg := &worker.WorkerConfig{
+		InFlight:          inFlight,
+		IntervalGenerator: generator,
+		Qps:               qps,
+		Timeout:           timeout,
+	}
+
 	lt.mu.Lock()
-	defer lt.mu.Unlock()
 	info, exists := lt.runners[runnerID]
-
 	if !exists {
+		lt.mu.Unlock()
 		return fmt.Errorf("runner %s not found", runnerID)
 	}
 
-	err = info.runner.SetConfig(&worker.WorkerConfig{
-		InFlight:          inFlight,
-		IntervalGenerator: generator,
-		Qps:               qps,
-		Timeout:           timeout,
-	})
+	if loadOptions == nil || maps.Equal(loadOptions, info.loadOptions) {
+		defer lt.mu.Unlock()
+		err = info.runner.SetConfig(cfg)
+		if err == nil {
+			info.mode = mode
+		}
+		return err
+	}
+	oldRunner, loadType, limits := info.runner, info.loadType, info.limits
+	lt.mu.Unlock()
+
+	constructor := lt.loadRegistry[loadType]
+	if err := loadrunner.CheckOptions(loadOptions, constructor()); err != nil {
+		return err
+	}
+
+	// Start the new runner first, so the old one keeps working if the new
+	// options do not work. Its limits count from the restart. Init connects
+	// to the server, so other runner operations are not blocked meanwhile.
+	runner, err := lt.newLoadRunner(runnerID, constructor(), loadOptions, cfg, limits)
+	if err != nil {
+		return err
+	}
 
-	if err == nil {
-		info.mode = mode
+	lt.mu.Lock()
+	defer lt.mu.Unlock()
+	if lt.runners[runnerID] != info || info.runner != oldRunner {
+		runner.Close()
+		return fmt.Errorf("runner %s was removed or updated while starting with the new options", runnerID)
 	}
-	return err
+	oldRunner.Close()
+
+	info.runner = runner
+	info.loadOptions = loadOptions
+	info.mode = mode
+	return nil
 }
 
 type Status struct {
@@ -224,6 +407,8 @@ type Status struct {
 	OkRequests     int
 	ErrRequests    int
 	Mode           string
+	BytesReceived  int64
+	BytesPerSecond float64 // Average since the runner was added
 }
 
 func (lt *LoadTester) GetRunnersInfo(ctx context.Context) ([]*Status, error) {
@@ -233,6 +418,13 @@ func (lt *LoadTester) GetRunnersInfo(ctx context.Context) ([]*Status, error) {
 	res := make([]*Status, 0)
 
 	for _, info := range lt.runners {
+		// Read the runner first, so the counters of a finished runner
+		// include all its requests
+		lrInfo, err := info.runner.GetInfo()
+		if err != nil {
+			return nil, err
+		}
+
 		// Extract metrics from Prometheus counters
 		var successCount, errorCount int
 
@@ -250,9 +442,17 @@ func (lt *LoadTester) GetRunnersInfo(ctx context.Context) ([]*Status, error) {
 			errorCount += int(pb.GetCounter().GetValue())
 		}
 
-		lrInfo, err := info.runner.GetInfo()
-		if err != nil {
-			return nil, err
+		var bytesReceived int64
+		bytesMetric, err := lt.metrics.BytesCounter.GetMetricWithLabelValues(info.id)
+		if err == nil && bytesMetric != nil {
+			pb := &dto.Metric{}
+			bytesMetric.Write(pb)
+			bytesReceived = int64(pb.GetCounter().GetValue())
+		}
+
+		var bytesPerSecond float64
+		if elapsed := time.Since(info.startTime).Seconds(); elapsed > 0 {
+			bytesPerSecond = float64(bytesReceived) / elapsed
 		}
 
 		status := &Status{
@@ -263,6 +463,8 @@ func (lt *LoadTester) GetRunnersInfo(ctx context.Context) ([]*Status, error) {
 			OkRequests:     successCount,
 			ErrRequests:    errorCount,
 			Mode:           info.mode,
+			BytesReceived:  bytesReceived,
+			BytesPerSecond: bytesPerSecond,
 		}
 		res = append(res, status)
 	}
@@ -274,7 +476,93 @@ func (lt *LoadTester) GetRunnersInfo(ctx context.Context) ([]*Status, error) {
 	return res, nil
 }
 
+// AggregateStatus sums the status of all active runners
+type AggregateStatus struct {
+	Runners     int
+	OkRequests  int
+	ErrRequests int
+	Qps         float64       // Requests per second observed over QpsWindow
+	QpsWindow   time.Duration // 0 until two aggregates were made
+}
+
+// Aggregate sums the statuses returned by GetRunnersInfo. The QPS is
+// measured from the request counts of the previous calls, up to
+// aggregateRateWindow ago.
+func (lt *LoadTester) Aggregate(statuses []*Status) *AggregateStatus {
+	res := &AggregateStatus{Runners: len(statuses)}
+	counts := make(map[string]int, len(statuses))
+	for _, status := range statuses {
+		res.OkRequests += status.OkRequests
+		res.ErrRequests += status.ErrRequests
+		counts[status.Id] = status.OkRequests + status.ErrRequests
+	}
+	res.Qps, res.QpsWindow = lt.rate.update(time.Now(), counts)
+	return res
+}
+
+// rateWindow computes a request rate from snapshots of the request count
+// of each runner. Runners are compared with their own last count, so
+// removing a runner does not make the total go down.
+type rateWindow struct {
+	mu       sync.Mutex
+	window   time.Duration
+	last     map[string]int // Request count of each runner at lastTime
+	lastTime time.Time
+	deltas   []rateDelta // Requests between consecutive snapshots, oldest first
+}
+
+// rateDelta is the number of requests sent between two snapshots
+type rateDelta struct {
+	start, end time.Time
+	requests   int
+}
+
+func newRateWindow(window time.Duration) *rateWindow {
+	return &rateWindow{window: window}
+}
+
+// update records the request counts at now and returns the rate over the
+// snapshots ending in the window, and the time it covers
+func (rw *rateWindow) update(now time.Time, counts map[string]int) (float64, time.Duration) {
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+
+	if !rw.lastTime.IsZero() {
+		var requests int
+		for id, count := range counts {
+			// Runners added since the last snapshot started from 0
+			requests += max(count-rw.last[id], 0)
+		}
+		rw.deltas = append(rw.deltas, rateDelta{start: rw.lastTime, end: now, requests: requests})
+	}
+	rw.last = counts
+	rw.lastTime = now
+
+	// Keep the snapshots ending in the window, the oldest one may start before it
+	first := 0
+	for first < len(rw.deltas) && now.Sub(rw.deltas[first].end) > rw.window {
+		first++
+	}
+	rw.deltas = rw.deltas[first:]
+	if len(rw.deltas) == 0 {
+		return 0, 0
+	}
+
+	var requests int
+	for _, delta := range rw.deltas {
+		requests += delta.requests
+	}
+	span := now.Sub(rw.deltas[0].start)
+	if span <= 0 {
+		return 0, 0
+	}
+	return float64(requests) / span.Seconds(), span
+}
+
 func (lt *LoadTester) Close() error {
+	// Runners being started give up their Init
+	lt.cancel()
+
 	lt.mu.Lock()
 	defer lt.mu.Unlock()
 
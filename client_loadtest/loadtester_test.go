@@ -0,0 +1,356 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mhbvr/manul/client_loadtest/loadrunner"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// FakeLoad is a load which does not send any requests
+type FakeLoad struct {
+	Addr string `name:"addr" description:"Server address" required:"true"`
+}
+
+func newFakeLoad() loadrunner.Load {
+	return &FakeLoad{}
+}
+
+func (l *FakeLoad) Options() []loadrunner.OptionDescription {
+	return loadrunner.GetOptionDescriptions(l)
+}
+
+func (l *FakeLoad) Init(ctx context.Context, options map[string]string) error {
+	if err := loadrunner.ParseOptions(options, l); err != nil {
+		return err
+	}
+	if l.Addr == "unreachable" {
+		return errors.New("connection refused")
+	}
+	if l.Addr == "slow" {
+		slowInitStarted <- struct{}{}
+		select {
+		case <-slowInitRelease:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// FakeLoad.Init with addr "slow" signals slowInitStarted and returns once
+// slowInitRelease is closed or ctx is done
+var (
+	slowInitStarted = make(chan struct{}, 1)
+	slowInitRelease chan struct{}
+)
+
+func (l *FakeLoad) Job(ctx context.Context) (time.Duration, error) {
+	return 0, nil
+}
+
+func (l *FakeLoad) Close() error {
+	return nil
+}
+
+func newFakeLoadTester(t *testing.T) *LoadTester {
+	t.Helper()
+
+	lt, err := NewLoadTester(10, 2, 0, prometheus.NewRegistry())
+	if err != nil {
+		t.Fatalf("NewLoadTester() failed: %v", err)
+	}
+	lt.RegisterLoad(newFakeLoad)
+	t.Cleanup(func() { lt.Close() })
+	return lt
+}
+
+func TestNewLoadTester_InvalidMaxInFlight(t *testing.T) {
+	for _, maxInFlight := range []int{0, -1} {
+		if _, err := NewLoadTester(maxInFlight, 2, 0, prometheus.NewRegistry()); err == nil {
+			t.Errorf("NewLoadTester(%d) succeeded, want error", maxInFlight)
+		}
+	}
+}
+
+func TestUpdateRunner_LoadOptions(t *testing.T) {
+	lt := newFakeLoadTester(t)
+
+	if err := lt.AddRunner("FakeLoad", map[string]string{"addr": "first"}, 1, 10, time.Second, "static", RunLimits{}); err != nil {
+		t.Fatalf("AddRunner() failed: %v", err)
+	}
+	runnerID := "FakeLoad-0"
+
+	// Broken options keep the old runner
+	if err := lt.UpdateRunner(runnerID, map[string]string{"addr": "unreachable"}, 1, 10, time.Second, "static"); err == nil {
+		t.Errorf("UpdateRunner() with unreachable addr succeeded, want error")
+	}
+	if err := lt.UpdateRunner(runnerID, map[string]string{"port": "80"}, 1, 10, time.Second, "static"); err == nil {
+		t.Errorf("UpdateRunner() with unknown option succeeded, want error")
+	}
+
+	if err := lt.UpdateRunner(runnerID, map[string]string{"addr": "second"}, 2, 10, time.Second, "asap"); err != nil {
+		t.Fatalf("UpdateRunner() failed: %v", err)
+	}
+	// nil options only change the worker config
+	if err := lt.UpdateRunner(runnerID, nil, 3, 10, time.Second, "asap"); err != nil {
+		t.Fatalf("UpdateRunner() failed: %v", err)
+	}
+
+	statuses, err := lt.GetRunnersInfo(context.Background())
+	if err != nil {
+		t.Fatalf("GetRunnersInfo() failed: %v", err)
+	}
+	if len(statuses) != 1 {
+		t.Fatalf("Got %d runners, want 1", len(statuses))
+	}
+	status := statuses[0]
+	if status.Id != runnerID {
+		t.Errorf("Runner ID = %s, want %s", status.Id, runnerID)
+	}
+	if status.LoadOptions["addr"] != "second" {
+		t.Errorf("addr = %q, want %q", status.LoadOptions["addr"], "second")
+	}
+	if status.Mode != "asap" {
+		t.Errorf("Mode = %s, want asap", status.Mode)
+	}
+	if status.LoadRunnerInfo.WorkerCfg.InFlight != 3 {
+		t.Errorf("InFlight = %d, want 3", status.LoadRunnerInfo.WorkerCfg.InFlight)
+	}
+}
+
+func TestUpdateRunner_SlowInit(t *testing.T) {
+	lt := newFakeLoadTester(t)
+	if err := lt.AddRunner("FakeLoad", map[string]string{"addr": "first"}, 1, 10, time.Second, "static", RunLimits{}); err != nil {
+		t.Fatalf("AddRunner() failed: %v", err)
+	}
+
+	slowInitRelease = make(chan struct{})
+	errc := make(chan error, 1)
+	go func() {
+		errc <- lt.UpdateRunner("FakeLoad-0", map[string]string{"addr": "slow"}, 1, 10, time.Second, "static")
+	}()
+	<-slowInitStarted
+
+	// Runners are listed and removed while the new load initializes
+	listed := make(chan error, 1)
+	go func() {
+		_, err := lt.GetRunnersInfo(context.Background())
+		listed <- err
+	}()
+	select {
+	case err := <-listed:
+		if err != nil {
+			t.Fatalf("GetRunnersInfo() failed: %v", err)
+		}
+	case <-time.After(time.Second):
+		close(slowInitRelease)
+		t.Fatalf("GetRunnersInfo() blocked by UpdateRunner()")
+	}
+	if err := lt.RemoveRunner("FakeLoad-0"); err != nil {
+		t.Fatalf("RemoveRunner() failed: %v", err)
+	}
+
+	close(slowInitRelease)
+	if err := <-errc; err == nil {
+		t.Errorf("UpdateRunner() of a runner removed during Init succeeded, want error")
+	}
+	statuses, err := lt.GetRunnersInfo(context.Background())
+	if err != nil {
+		t.Fatalf("GetRunnersInfo() failed: %v", err)
+	}
+	if len(statuses) != 0 {
+		t.Errorf("Got %d runners after UpdateRunner() of a removed runner, want 0", len(statuses))
+	}
+}
+
+func TestClose_CancelsStartingRunner(t *testing.T) {
+	lt := newFakeLoadTester(t)
+
+	slowInitRelease = make(chan struct{})
+	defer close(slowInitRelease)
+	errc := make(chan error, 1)
+	go func() {
+		errc <- lt.AddRunner("FakeLoad", map[string]string{"addr": "slow"}, 1, 10, time.Second, "static", RunLimits{})
+	}()
+	<-slowInitStarted
+
+	go lt.Close()
+	select {
+	case err := <-errc:
+		if err == nil {
+			t.Errorf("AddRunner() during Close() succeeded, want error")
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Close() did not cancel the Init of a starting runner")
+	}
+}
+
+func TestAddRunner_MaxRunners(t *testing.T) {
+	lt := newFakeLoadTester(t)
+	options := map[string]string{"addr": "fake"}
+
+	for i := 0; i < lt.GetMaxRunners(); i++ {
+		if err := lt.AddRunner("FakeLoad", options, 1, 10, time.Second, "static", RunLimits{}); err != nil {
+			t.Fatalf("AddRunner() #%d failed: %v", i, err)
+		}
+	}
+	if err := lt.AddRunner("FakeLoad", options, 1, 10, time.Second, "static", RunLimits{}); err == nil {
+		t.Fatalf("AddRunner() over the limit succeeded, want error")
+	}
+
+	// Removing a runner frees a slot
+	if err := lt.RemoveRunner("FakeLoad-0"); err != nil {
+		t.Fatalf("RemoveRunner() failed: %v", err)
+	}
+	if err := lt.AddRunner("FakeLoad", options, 1, 10, time.Second, "static", RunLimits{}); err != nil {
+		t.Errorf("AddRunner() after remove failed: %v", err)
+	}
+}
+
+func TestAddRunner_MaxRequests(t *testing.T) {
+	lt := newFakeLoadTester(t)
+	if err := lt.AddRunner("FakeLoad", map[string]string{"addr": "fake"}, 2, 10, time.Second, "asap", RunLimits{MaxRequests: 20}); err != nil {
+		t.Fatalf("AddRunner() failed: %v", err)
+	}
+
+	// The runner stays listed with its last config after it stopped
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		statuses, err := lt.GetRunnersInfo(context.Background())
+		if err != nil {
+			t.Fatalf("GetRunnersInfo() failed: %v", err)
+		}
+		status := statuses[0]
+		if status.LoadRunnerInfo.Progress.Finished {
+			if status.OkRequests != 20 || status.LoadRunnerInfo.Progress.Requests != 20 {
+				t.Errorf("Runner finished after %d requests, %d successful, want 20", status.LoadRunnerInfo.Progress.Requests, status.OkRequests)
+			}
+			if status.LoadRunnerInfo.WorkerCfg.InFlight != 2 {
+				t.Errorf("WorkerCfg.InFlight = %d, want 2", status.LoadRunnerInfo.WorkerCfg.InFlight)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Runner not finished, progress %+v", status.LoadRunnerInfo.Progress)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if err := lt.AddRunner("FakeLoad", map[string]string{"addr": "fake"}, 1, 10, time.Second, "asap", RunLimits{MaxDuration: -time.Second}); err == nil {
+		t.Errorf("AddRunner() with a negative max duration succeeded, want error")
+	}
+}
+
+func TestRemoveRunnerGraceful(t *testing.T) {
+	lt := newFakeLoadTester(t)
+
+	if err := lt.AddRunner("FakeLoad", map[string]string{"addr": "fake"}, 1, 10, time.Second, "asap", RunLimits{}); err != nil {
+		t.Fatalf("AddRunner() failed: %v", err)
+	}
+	if err := lt.RemoveRunnerGraceful(context.Background(), "FakeLoad-0"); err != nil {
+		t.Fatalf("RemoveRunnerGraceful() failed: %v", err)
+	}
+	if err := lt.RemoveRunnerGraceful(context.Background(), "FakeLoad-0"); err == nil {
+		t.Errorf("RemoveRunnerGraceful() of a removed runner succeeded, want error")
+	}
+
+	statuses, err := lt.GetRunnersInfo(context.Background())
+	if err != nil {
+		t.Fatalf("GetRunnersInfo() failed: %v", err)
+	}
+	if len(statuses) != 0 {
+		t.Errorf("Got %d runners after removal, want 0", len(statuses))
+	}
+}
+
+// FakeBytesLoad is a FakeLoad reporting 100 received bytes per job
+type FakeBytesLoad struct {
+	FakeLoad
+	recordBytes func(int)
+}
+
+func newFakeBytesLoad() loadrunner.Load {
+	return &FakeBytesLoad{}
+}
+
+func (l *FakeBytesLoad) SetBytesRecorder(recorder func(int)) {
+	l.recordBytes = recorder
+}
+
+func (l *FakeBytesLoad) Job(ctx context.Context) (time.Duration, error) {
+	l.recordBytes(100)
+	return 0, nil
+}
+
+func TestGetRunnersInfo_BytesReceived(t *testing.T) {
+	lt := newFakeLoadTester(t)
+	lt.RegisterLoad(newFakeBytesLoad)
+
+	if err := lt.AddRunner("FakeBytesLoad", nil, 1, 100, time.Second, "static", RunLimits{}); err != nil {
+		t.Fatalf("AddRunner() failed: %v", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	statuses, err := lt.GetRunnersInfo(context.Background())
+	if err != nil {
+		t.Fatalf("GetRunnersInfo() failed: %v", err)
+	}
+	if len(statuses) != 1 {
+		t.Fatalf("got %d runners, want 1", len(statuses))
+	}
+
+	status := statuses[0]
+	if status.BytesReceived == 0 || status.BytesReceived%100 != 0 {
+		t.Errorf("BytesReceived = %d, want a positive multiple of 100", status.BytesReceived)
+	}
+	if status.BytesPerSecond <= 0 {
+		t.Errorf("BytesPerSecond = %f, want > 0", status.BytesPerSecond)
+	}
+}
+
+func TestRateWindow(t *testing.T) {
+	rw := newRateWindow(30 * time.Second)
+	start := time.Now()
+	at := func(seconds int) time.Time { return start.Add(time.Duration(seconds) * time.Second) }
+
+	if qps, window := rw.update(at(0), map[string]int{"a": 100}); qps != 0 || window != 0 {
+		t.Errorf("update() of the first snapshot = %v, %v, want no rate", qps, window)
+	}
+
+	// Runner b is added with 50 requests, a sends 100 more
+	if qps, window := rw.update(at(10), map[string]int{"a": 200, "b": 50}); qps != 15 || window != 10*time.Second {
+		t.Errorf("update() = %v, %v, want 15 QPS over 10s", qps, window)
+	}
+
+	// Removing runner a does not count as negative requests
+	if qps, window := rw.update(at(20), map[string]int{"b": 150}); qps != 12.5 || window != 20*time.Second {
+		t.Errorf("update() after removing a runner = %v, %v, want 12.5 QPS over 20s", qps, window)
+	}
+
+	// The first snapshots leave the window
+	if qps, window := rw.update(at(45), map[string]int{"b": 400}); qps != 10 || window != 35*time.Second {
+		t.Errorf("update() = %v, %v, want 10 QPS over 35s", qps, window)
+	}
+}
+
+func TestAggregate(t *testing.T) {
+	lt := newFakeLoadTester(t)
+	statuses := []*Status{
+		{Id: "a", OkRequests: 10, ErrRequests: 1},
+		{Id: "b", OkRequests: 20, ErrRequests: 2},
+	}
+	aggregate := lt.Aggregate(statuses)
+	if aggregate.Runners != 2 || aggregate.OkRequests != 30 || aggregate.ErrRequests != 3 || aggregate.QpsWindow != 0 {
+		t.Errorf("Aggregate() = %+v, want 2 runners, 30 ok, 3 failed, no QPS yet", aggregate)
+	}
+
+	statuses[0].OkRequests += 100
+	time.Sleep(10 * time.Millisecond)
+	if aggregate = lt.Aggregate(statuses); aggregate.Qps <= 0 || aggregate.QpsWindow <= 0 {
+		t.Errorf("Aggregate() = %+v, want a positive QPS", aggregate)
+	}
+}
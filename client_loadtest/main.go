@@ -5,7 +5,9 @@ import (
 	"log"
 	"net"
 	"net/http"
+	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/channelz/service"
@@ -15,17 +17,31 @@ func main() {
 	var (
 		webAddr      = flag.String("web_addr", "localhost:8080", "Web interface host:port")
 		channelzAddr = flag.String("channelz_addr", "localhost:8090", "Channelz gRPC server host:port")
-		maxInflight  = flag.Int("max-inflight", 10000, "Maximum number of in-flight requests per runner")
+		maxInflight  = flag.Int("max-inflight", 10000, "Maximum number of in-flight requests, shared across all runners")
+		maxRunners   = flag.Int("max-runners", 100, "Maximum number of runners (0 = no limit)")
+		webUser      = flag.String("web_user", "", "Basic auth user required for add/remove/update requests (empty = no auth)")
+		webPassword  = flag.String("web_password", "", "Basic auth password, used with -web_user")
+		tlsCert      = flag.String("tls_cert", "", "TLS certificate file for the web interface (empty = plain HTTP)")
+		tlsKey       = flag.String("tls_key", "", "TLS key file for the web interface, used with -tls_cert")
+		debugReplay  = flag.Bool("debug_replay", false, "Enable POST /debug/replay to re-issue GetPhoto requests seen in /tracez")
+		stallPeriod  = flag.Duration("stall_interval", 30*time.Second, "Report a runner as stalled after this long without sending requests (0 = disabled)")
 	)
 	flag.Parse()
 
+	if *webUser != "" && *webPassword == "" {
+		log.Fatal("-web_password is required when -web_user is set")
+	}
+	if (*tlsCert == "") != (*tlsKey == "") {
+		log.Fatal("-tls_cert and -tls_key must be set together")
+	}
+
 	zpagesHandler, cleanup, err := InitializeTracing()
 	if err != nil {
 		log.Fatalf("Failed to initialize tracing: %v", err)
 	}
 	defer cleanup()
 
-	loadTester, err := NewLoadTester(*maxInflight)
+	loadTester, err := NewLoadTester(*maxInflight, *maxRunners, *stallPeriod, prometheus.DefaultRegisterer)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -46,16 +62,22 @@ func main() {
 	}()
 
 	mux := http.NewServeMux()
-	mux.HandleFunc("GET /", webHandler.HandleIndex)
-	mux.HandleFunc("POST /add-runner", webHandler.HandleAddRunner)
-	mux.HandleFunc("POST /remove-runner", webHandler.HandleRemoveRunner)
-	mux.HandleFunc("POST /update-runner", webHandler.HandleUpdateRunner)
-	mux.HandleFunc("GET /api/load-options", webHandler.HandleGetLoadOptions)
+	webHandler.RegisterRoutes(mux)
 	mux.Handle("GET /metrics", promhttp.Handler())
 	mux.Handle("GET /tracez", zpagesHandler)
+	if *debugReplay {
+		mux.HandleFunc("POST /debug/replay", HandleReplay)
+	}
 
-	log.Printf("Starting load tester web interface on %s", *webAddr)
+	var handler http.Handler = mux
+	if *webUser != "" {
+		handler = RequireAuth(mux, *webUser, *webPassword)
+	}
 
-	http.Handle("/", mux)
-	log.Fatal(http.ListenAndServe(*webAddr, nil))
+	if *tlsCert != "" {
+		log.Printf("Starting load tester web interface on https://%s", *webAddr)
+		log.Fatal(http.ListenAndServeTLS(*webAddr, *tlsCert, *tlsKey, handler))
+	}
+	log.Printf("Starting load tester web interface on %s", *webAddr)
+	log.Fatal(http.ListenAndServe(*webAddr, handler))
 }
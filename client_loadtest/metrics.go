@@ -12,12 +12,19 @@ type Metrics struct {
 
 	// Request latency histogram
 	RequestLatency *prometheus.HistogramVec
+
+	// Received photo bytes counter
+	BytesCounter *prometheus.CounterVec
+
+	// Runners unable to dispatch requests
+	RunnerStalled *prometheus.GaugeVec
 }
 
-// NewMetrics creates and registers new Prometheus metrics
-func NewMetrics() *Metrics {
+// NewMetrics creates new Prometheus metrics and registers them in reg
+func NewMetrics(reg prometheus.Registerer) *Metrics {
+	factory := promauto.With(reg)
 	return &Metrics{
-		ResponseCounter: promauto.NewCounterVec(
+		ResponseCounter: factory.NewCounterVec(
 			prometheus.CounterOpts{
 				Name: "loadtester_requests_total",
 				Help: "Total number of requests sent by the load tester",
@@ -25,7 +32,7 @@ func NewMetrics() *Metrics {
 			[]string{"status", "runner_id"}, // "success" or "error", runner identifier
 		),
 
-		RequestLatency: promauto.NewHistogramVec(
+		RequestLatency: factory.NewHistogramVec(
 			prometheus.HistogramOpts{
 				Name: "loadtester_request_duration_seconds",
 				Help: "Request latency in seconds",
@@ -35,6 +42,22 @@ func NewMetrics() *Metrics {
 			},
 			[]string{"status", "runner_id"}, // "success" or "error", runner identifier
 		),
+
+		BytesCounter: factory.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "loadtester_bytes_received_total",
+				Help: "Total number of photo bytes received by the load tester",
+			},
+			[]string{"runner_id"},
+		),
+
+		RunnerStalled: factory.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "loadtester_runner_stalled",
+				Help: "1 if the runner has not dispatched requests for the stall interval despite its config, 0 otherwise",
+			},
+			[]string{"runner_id"},
+		),
 	}
 }
 
@@ -48,3 +71,17 @@ func (m *Metrics) RecordRequest(runnerID string, durationSeconds float64, succes
 	m.ResponseCounter.WithLabelValues(status, runnerID).Inc()
 	m.RequestLatency.WithLabelValues(status, runnerID).Observe(durationSeconds)
 }
+
+// RecordBytes records bytes received by a runner
+func (m *Metrics) RecordBytes(runnerID string, bytes int) {
+	m.BytesCounter.WithLabelValues(runnerID).Add(float64(bytes))
+}
+
+// RecordStall records whether a runner is stalled
+func (m *Metrics) RecordStall(runnerID string, stalled bool) {
+	value := 0.0
+	if stalled {
+		value = 1
+	}
+	m.RunnerStalled.WithLabelValues(runnerID).Set(value)
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	pb "github.com/mhbvr/manul/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+const (
+	// replayTimeout bounds all requests of one /debug/replay call
+	replayTimeout = 30 * time.Second
+	// Max number of requests of one /debug/replay call
+	maxReplayCount = 100
+)
+
+// parseReplayRequest builds the GetPhoto request from the cat_id, photo_id,
+// width and scaling_algorithm parameters, as found in trace attributes
+func parseReplayRequest(r *http.Request) (*pb.GetPhotoRequest, error) {
+	catID, err := strconv.ParseUint(r.FormValue("cat_id"), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cat_id: %v", err)
+	}
+
+	photoID, err := strconv.ParseUint(r.FormValue("photo_id"), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid photo_id: %v", err)
+	}
+
+	req := &pb.GetPhotoRequest{
+		CatId:   catID,
+		PhotoId: photoID,
+	}
+
+	if widthStr := r.FormValue("width"); widthStr != "" {
+		width, err := strconv.ParseUint(widthStr, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid width: %v", err)
+		}
+		req.Width = uint32(width)
+	}
+
+	if algo := r.FormValue("scaling_algorithm"); algo != "" {
+		value, ok := pb.ScalingAlgorithm_value[strings.ToUpper(algo)]
+		if !ok {
+			return nil, fmt.Errorf("invalid scaling_algorithm: %s", algo)
+		}
+		req.ScalingAlgorithm = pb.ScalingAlgorithm(value)
+	}
+
+	return req, nil
+}
+
+// HandleReplay re-issues a GetPhoto request seen in /tracez and reports timing.
+// Form or query parameters: addr (required), cat_id, photo_id (required),
+// width, scaling_algorithm and count (number of requests, default 1).
+// It is served for POST only, so RequireAuth protects it.
+func HandleReplay(w http.ResponseWriter, r *http.Request) {
+	addr := r.FormValue("addr")
+	if addr == "" {
+		http.Error(w, "addr parameter is required", http.StatusBadRequest)
+		return
+	}
+
+	req, err := parseReplayRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	count := 1
+	if countStr := r.FormValue("count"); countStr != "" {
+		count, err = strconv.Atoi(countStr)
+		if err != nil || count < 1 || count > maxReplayCount {
+			http.Error(w, fmt.Sprintf("count must be between 1 and %d", maxReplayCount), http.StatusBadRequest)
+			return
+		}
+	}
+
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		http.Error(w, "Failed to connect to server: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer conn.Close()
+	client := pb.NewCatPhotosServiceClient(conn)
+
+	ctx, cancel := context.WithTimeout(r.Context(), replayTimeout)
+	defer cancel()
+
+	w.Header().Set("Content-Type", "text/plain")
+	fmt.Fprintf(w, "GetPhoto %s cat_id=%d photo_id=%d width=%d scaling_algorithm=%s\n",
+		addr, req.CatId, req.PhotoId, req.Width, req.ScalingAlgorithm)
+
+	var total time.Duration
+	for i := 0; i < count; i++ {
+		start := time.Now()
+		resp, err := client.GetPhoto(ctx, req)
+		duration := time.Since(start)
+		total += duration
+
+		if err != nil {
+			fmt.Fprintf(w, "%d: error after %v: %v\n", i+1, duration, err)
+			continue
+		}
+		fmt.Fprintf(w, "%d: ok, %d bytes in %v\n", i+1, len(resp.PhotoData), duration)
+	}
+	fmt.Fprintf(w, "average: %v\n", total/time.Duration(count))
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	pb "github.com/mhbvr/manul/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// fakePhotoServer returns a 10 byte photo for cat 1, photo 2
+type fakePhotoServer struct {
+	pb.UnimplementedCatPhotosServiceServer
+}
+
+func (s *fakePhotoServer) GetPhoto(ctx context.Context, req *pb.GetPhotoRequest) (*pb.GetPhotoResponse, error) {
+	if req.CatId != 1 || req.PhotoId != 2 {
+		return nil, status.Errorf(codes.NotFound, "photo not found")
+	}
+	return &pb.GetPhotoResponse{PhotoData: make([]byte, 10)}, nil
+}
+
+func TestHandleReplay(t *testing.T) {
+	lis, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	grpcServer := grpc.NewServer()
+	pb.RegisterCatPhotosServiceServer(grpcServer, &fakePhotoServer{})
+	go grpcServer.Serve(lis)
+	t.Cleanup(grpcServer.Stop)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /debug/replay", HandleReplay)
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+		wantBody   string
+	}{
+		{"ok", "cat_id=1&photo_id=2&count=3", http.StatusOK, "3: ok, 10 bytes"},
+		{"scaled", "cat_id=1&photo_id=2&width=100&scaling_algorithm=bilinear", http.StatusOK, "width=100 scaling_algorithm=BILINEAR"},
+		{"rpc error", "cat_id=1&photo_id=3", http.StatusOK, "1: error"},
+		{"missing cat", "photo_id=2", http.StatusBadRequest, "invalid cat_id"},
+		{"bad algorithm", "cat_id=1&photo_id=2&scaling_algorithm=magic", http.StatusBadRequest, "invalid scaling_algorithm"},
+		{"bad count", "cat_id=1&photo_id=2&count=0", http.StatusBadRequest, "count must be"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := http.Post(server.URL+"/debug/replay", "application/x-www-form-urlencoded",
+				strings.NewReader("addr="+lis.Addr().String()+"&"+tt.query))
+			if err != nil {
+				t.Fatalf("POST /debug/replay failed: %v", err)
+			}
+			defer resp.Body.Close()
+
+			body, _ := io.ReadAll(resp.Body)
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d, body: %s", resp.StatusCode, tt.wantStatus, body)
+			}
+			if !strings.Contains(string(body), tt.wantBody) {
+				t.Errorf("body = %q, want it to contain %q", body, tt.wantBody)
+			}
+		})
+	}
+	// GET requests pass RequireAuth without credentials, replays are POST only
+	resp, err := http.Get(server.URL + "/debug/replay?addr=" + lis.Addr().String() + "&cat_id=1&photo_id=2")
+	if err != nil {
+		t.Fatalf("GET /debug/replay failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("GET /debug/replay status = %d, want %d", resp.StatusCode, http.StatusMethodNotAllowed)
+	}
+}
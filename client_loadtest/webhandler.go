@@ -1,7 +1,9 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"html/template"
 	"net/http"
 	"strconv"
@@ -10,6 +12,19 @@ import (
 	"go.opentelemetry.io/contrib/zpages"
 )
 
+// runnerFormFields are the runner form fields which are not load options
+var runnerFormFields = map[string]bool{
+	"runner_id": true,
+	"load_type": true,
+	"inflight":  true,
+	"mode":      true,
+	"qps":       true,
+	"timeout":   true,
+
+	"max_requests": true,
+	"max_duration": true,
+}
+
 type WebHandler struct {
 	loadTester      *LoadTester
 	template        *template.Template
@@ -21,6 +36,12 @@ func NewWebHandler(loadTester *LoadTester, zpagesProcessor *zpages.SpanProcessor
 		"add": func(a, b int) int {
 			return a + b
 		},
+		"mbps": func(bytesPerSecond float64) string {
+			return fmt.Sprintf("%.2f", bytesPerSecond/1e6)
+		},
+		"seconds": func(d time.Duration) time.Duration {
+			return d.Round(time.Second)
+		},
 	}
 	tmpl := template.Must(template.New("index").Funcs(funcMap).Parse(indexTemplate))
 	return &WebHandler{
@@ -30,6 +51,16 @@ func NewWebHandler(loadTester *LoadTester, zpagesProcessor *zpages.SpanProcessor
 	}
 }
 
+// RegisterRoutes registers the control panel handlers in mux
+func (wh *WebHandler) RegisterRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("GET /", wh.HandleIndex)
+	mux.HandleFunc("POST /add-runner", wh.HandleAddRunner)
+	mux.HandleFunc("POST /validate-runner", wh.HandleValidateRunner)
+	mux.HandleFunc("POST /remove-runner", wh.HandleRemoveRunner)
+	mux.HandleFunc("POST /update-runner", wh.HandleUpdateRunner)
+	mux.HandleFunc("GET /api/load-options", wh.HandleGetLoadOptions)
+}
+
 func (wh *WebHandler) HandleIndex(w http.ResponseWriter, r *http.Request) {
 	info, err := wh.loadTester.GetRunnersInfo(r.Context())
 	if err != nil {
@@ -37,14 +68,25 @@ func (wh *WebHandler) HandleIndex(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	var bytesPerSecond float64
+	for _, status := range info {
+		bytesPerSecond += status.BytesPerSecond
+	}
+
 	data := struct {
-		MaxInFlight int
-		LoadTypes   []string
-		RunnerInfo  []*Status
+		MaxInFlight    int
+		MaxRunners     int
+		LoadTypes      []string
+		RunnerInfo     []*Status
+		BytesPerSecond float64
+		Aggregate      *AggregateStatus
 	}{
-		MaxInFlight: wh.loadTester.GetMaxInFlight(),
-		LoadTypes:   wh.loadTester.GetAvailableLoadTypes(),
-		RunnerInfo:  info,
+		MaxInFlight:    wh.loadTester.GetMaxInFlight(),
+		MaxRunners:     wh.loadTester.GetMaxRunners(),
+		LoadTypes:      wh.loadTester.GetAvailableLoadTypes(),
+		RunnerInfo:     info,
+		BytesPerSecond: bytesPerSecond,
+		Aggregate:      wh.loadTester.Aggregate(info),
 	}
 
 	w.Header().Set("Content-Type", "text/html")
@@ -54,76 +96,145 @@ func (wh *WebHandler) HandleIndex(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func (wh *WebHandler) HandleAddRunner(w http.ResponseWriter, r *http.Request) {
+// validateTimeout bounds the connectivity check of /validate-runner
+const validateTimeout = 10 * time.Second
+
+// runnerForm holds the parsed add-runner form
+type runnerForm struct {
+	loadType    string
+	loadOptions map[string]string
+	inFlight    int
+	mode        string
+	qps         float64
+	timeout     time.Duration
+	limits      RunLimits
+}
+
+// parseRunnerForm parses and checks the add-runner form.
+// All returned errors are client errors.
+func (wh *WebHandler) parseRunnerForm(r *http.Request) (*runnerForm, error) {
 	var err error
 
 	if err = r.ParseForm(); err != nil {
-		http.Error(w, "Failed to parse form: "+err.Error(), http.StatusBadRequest)
-		return
+		return nil, fmt.Errorf("Failed to parse form: %v", err)
 	}
 
 	// Parse load type
-	loadType := r.FormValue("load_type")
-	if loadType == "" {
-		http.Error(w, "load_type is required", http.StatusBadRequest)
-		return
+	form := &runnerForm{loadType: r.FormValue("load_type")}
+	if form.loadType == "" {
+		return nil, fmt.Errorf("load_type is required")
 	}
 
 	// Get available options for this load type
-	availableOptions, err := wh.loadTester.GetLoadOptions(loadType)
+	availableOptions, err := wh.loadTester.GetLoadOptions(form.loadType)
 	if err != nil {
-		http.Error(w, "Invalid load type: "+err.Error(), http.StatusBadRequest)
-		return
+		return nil, fmt.Errorf("Invalid load type: %v", err)
 	}
 
-	// Parse load options from form
-	loadOptions := make(map[string]string)
-	for optionName := range availableOptions {
-		if value := r.FormValue(optionName); value != "" {
-			loadOptions[optionName] = value
+	// Parse load options from form, rejecting fields that are neither
+	// runner settings nor options of the selected load type
+	knownOptions := make(map[string]bool)
+	for _, option := range availableOptions {
+		knownOptions[option.Name] = true
+	}
+	form.loadOptions = make(map[string]string)
+	for name := range r.PostForm {
+		if runnerFormFields[name] {
+			continue
+		}
+		if !knownOptions[name] {
+			return nil, fmt.Errorf("Unknown option for load type %s: %s", form.loadType, name)
 		}
+		if value := r.PostForm.Get(name); value != "" {
+			form.loadOptions[name] = value
+		}
+	}
+
+	if err := wh.loadTester.CheckLoadOptions(form.loadType, form.loadOptions); err != nil {
+		return nil, fmt.Errorf("Invalid load options: %v", err)
 	}
 
 	// Parse inflight
-	var inFlight int = 1 // default
+	form.inFlight = 1 // default
 	if inflightStr := r.FormValue("inflight"); inflightStr != "" {
-		if inFlight, err = strconv.Atoi(inflightStr); err != nil {
-			http.Error(w, "Failed to parse inflight: "+err.Error(), http.StatusBadRequest)
-			return
+		if form.inFlight, err = strconv.Atoi(inflightStr); err != nil {
+			return nil, fmt.Errorf("Failed to parse inflight: %v", err)
 		}
 	}
 
 	// Parse mode
-	mode := r.FormValue("mode")
-	if mode == "" {
-		mode = "asap" // default
+	form.mode = r.FormValue("mode")
+	if form.mode == "" {
+		form.mode = "asap" // default
 	}
 
 	// Parse QPS
-	var qps float64 = 1.0 // default
+	form.qps = 1.0 // default
 	if qpsStr := r.FormValue("qps"); qpsStr != "" {
-		if qps, err = strconv.ParseFloat(qpsStr, 64); err != nil {
-			http.Error(w, "Failed to parse qps: "+err.Error(), http.StatusBadRequest)
-			return
+		if form.qps, err = strconv.ParseFloat(qpsStr, 64); err != nil {
+			return nil, fmt.Errorf("Failed to parse qps: %v", err)
 		}
 	}
 
 	// Parse timeout
-	var timeout time.Duration = 10 * time.Second // default
+	form.timeout = 10 * time.Second // default
 	if timeoutStr := r.FormValue("timeout"); timeoutStr != "" {
-		if timeout, err = time.ParseDuration(timeoutStr); err != nil {
-			http.Error(w, "Failed to parse timeout: "+err.Error(), http.StatusBadRequest)
-			return
+		if form.timeout, err = time.ParseDuration(timeoutStr); err != nil {
+			return nil, fmt.Errorf("Failed to parse timeout: %v", err)
+		}
+	}
+
+	// Parse run limits, empty means no limit
+	if maxRequestsStr := r.FormValue("max_requests"); maxRequestsStr != "" {
+		if form.limits.MaxRequests, err = strconv.Atoi(maxRequestsStr); err != nil {
+			return nil, fmt.Errorf("Failed to parse max_requests: %v", err)
+		}
+	}
+	if maxDurationStr := r.FormValue("max_duration"); maxDurationStr != "" {
+		if form.limits.MaxDuration, err = time.ParseDuration(maxDurationStr); err != nil {
+			return nil, fmt.Errorf("Failed to parse max_duration: %v", err)
 		}
 	}
+	if form.limits.MaxRequests < 0 || form.limits.MaxDuration < 0 {
+		return nil, fmt.Errorf("max_requests and max_duration must not be negative")
+	}
+
+	return form, nil
+}
 
-	if err := wh.loadTester.AddRunner(loadType, loadOptions, inFlight, qps, timeout, mode); err != nil {
+func (wh *WebHandler) HandleAddRunner(w http.ResponseWriter, r *http.Request) {
+	form, err := wh.parseRunnerForm(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := wh.loadTester.AddRunner(form.loadType, form.loadOptions, form.inFlight, form.qps, form.timeout, form.mode, form.limits); err != nil {
 		http.Error(w, "Failed to add runner: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 	http.Redirect(w, r, "/", http.StatusSeeOther)
 }
 
+// HandleValidateRunner checks the add-runner form without creating a runner
+func (wh *WebHandler) HandleValidateRunner(w http.ResponseWriter, r *http.Request) {
+	form, err := wh.parseRunnerForm(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), validateTimeout)
+	defer cancel()
+	if err := wh.loadTester.ValidateRunner(ctx, form.loadType, form.loadOptions, form.mode); err != nil {
+		http.Error(w, "Validation failed: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain")
+	fmt.Fprintln(w, "OK")
+}
+
 func (wh *WebHandler) HandleGetLoadOptions(w http.ResponseWriter, r *http.Request) {
 	loadType := r.URL.Query().Get("type")
 	if loadType == "" {
@@ -153,7 +264,24 @@ func (wh *WebHandler) HandleRemoveRunner(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	if err := wh.loadTester.RemoveRunner(runnerID); err != nil {
+	// With drain_timeout, in-flight jobs are given up to that long to finish
+	if drainStr := r.FormValue("drain_timeout"); drainStr != "" {
+		drainTimeout, err := time.ParseDuration(drainStr)
+		if err != nil {
+			http.Error(w, "Failed to parse drain_timeout: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if drainTimeout <= 0 {
+			http.Error(w, "drain_timeout must be positive, got "+drainStr, http.StatusBadRequest)
+			return
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), drainTimeout)
+		defer cancel()
+		if err := wh.loadTester.RemoveRunnerGraceful(ctx, runnerID); err != nil {
+			http.Error(w, "Failed to remove runner: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	} else if err := wh.loadTester.RemoveRunner(runnerID); err != nil {
 		http.Error(w, "Failed to remove runner: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -208,7 +336,21 @@ func (wh *WebHandler) HandleUpdateRunner(w http.ResponseWriter, r *http.Request)
 		}
 	}
 
-	if err := wh.loadTester.UpdateRunner(runnerID, inFlight, qps, timeout, mode); err != nil {
+	// Load options are only replaced when the form carries them
+	var loadOptions map[string]string
+	for name := range r.PostForm {
+		if runnerFormFields[name] {
+			continue
+		}
+		if loadOptions == nil {
+			loadOptions = make(map[string]string)
+		}
+		if value := r.PostForm.Get(name); value != "" {
+			loadOptions[name] = value
+		}
+	}
+
+	if err := wh.loadTester.UpdateRunner(runnerID, loadOptions, inFlight, qps, timeout, mode); err != nil {
 		http.Error(w, "Failed to update runner: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -241,7 +383,12 @@ const indexTemplate = `
         <h1>Cat Photo Load Tester Control Panel</h1>
         
         <div class="section stats">
-            <h2>Runner Management ({{len .RunnerInfo}} active)</h2>
+            <h2>Runner Management ({{len .RunnerInfo}}{{if .MaxRunners}}/{{.MaxRunners}}{{end}} active)</h2>
+            <p>
+                Total: <strong>{{.Aggregate.OkRequests}}</strong> successful, <strong>{{.Aggregate.ErrRequests}}</strong> failed |
+                {{if .Aggregate.QpsWindow}}Observed: <strong>{{printf "%.1f" .Aggregate.Qps}} QPS</strong> <em style="font-size: 0.9em; color: #666;">(all runners, last {{printf "%.1f" .Aggregate.QpsWindow.Seconds}}s)</em>{{else}}Observed QPS: <em style="font-size: 0.9em; color: #666;">refresh to measure</em>{{end}}
+            </p>
+            <p>Received: <strong>{{mbps .BytesPerSecond}} MB/s</strong> <em style="font-size: 0.9em; color: #666;">(sum of runner averages since start)</em></p>
             <div style="margin-bottom: 15px;">
                 <button type="button" onclick="showAddForm()">Add New Runner</button>
             </div>
@@ -275,6 +422,14 @@ const indexTemplate = `
                             <th>Request Timeout</th>
                             <td><input type="text" name="timeout" value="10s"></td>
                         </tr>
+                        <tr>
+                            <th>Max Requests</th>
+                            <td><input type="number" name="max_requests" min="0" placeholder="no limit"></td>
+                        </tr>
+                        <tr>
+                            <th>Max Duration</th>
+                            <td><input type="text" name="max_duration" placeholder="no limit"></td>
+                        </tr>
                         <tr>
                             <th>Load Type</th>
                             <td>
@@ -289,7 +444,9 @@ const indexTemplate = `
                     </table>
                     <div id="load-options-container"></div>
                     <button type="submit">Create Runner</button>
+                    <button type="button" onclick="validateRunner()">Validate</button>
                     <button type="button" onclick="hideAddForm()">Cancel</button>
+                    <p id="validate-result"></p>
                 </form>
             </div>
             <table>
@@ -302,8 +459,10 @@ const indexTemplate = `
                         <th>Mode</th>
                         <th>QPS</th>
                         <th>Timeout</th>
+                        <th>Progress</th>
                         <th>Successful</th>
                         <th>Failed</th>
+                        <th>Received</th>
                         <th>Actions</th>
                     </tr>
                 </thead>
@@ -325,13 +484,40 @@ const indexTemplate = `
                         <td>{{.Mode}}</td>
                         <td>{{if eq .Mode "asap"}}-{{else}}{{.LoadRunnerInfo.WorkerCfg.Qps}}{{end}}</td>
                         <td>{{.LoadRunnerInfo.WorkerCfg.Timeout}}</td>
+                        <td style="white-space: nowrap;">
+                            {{with .LoadRunnerInfo.Progress}}
+                            {{if or .MaxRequests .MaxDuration}}
+                                <div>{{.Requests}}{{if .MaxRequests}}/{{.MaxRequests}}{{end}} requests</div>
+                                <div>{{seconds .Elapsed}}{{if .MaxDuration}}/{{.MaxDuration}}{{end}}</div>
+                                {{if .Finished}}<strong>done</strong>{{else if .LimitReached}}<em>finishing</em>{{end}}
+                            {{else}}-{{end}}
+                            {{end}}
+                        </td>
                         <td>{{.OkRequests}}</td>
-                        <td>{{.ErrRequests}}</td>
+                        <td>
+                            {{if .LoadRunnerInfo.LastErrors}}
+                            <details>
+                                <summary>{{.ErrRequests}}</summary>
+                                <div style="font-size: 0.85em;">
+                                {{range .LoadRunnerInfo.LastErrors}}
+                                    <div>{{.Time.Format "15:04:05"}} <strong>{{.Code}}</strong> {{.Message}}</div>
+                                {{end}}
+                                </div>
+                            </details>
+                            {{else}}
+                            {{.ErrRequests}}
+                            {{end}}
+                        </td>
+                        <td>{{mbps .BytesPerSecond}} MB/s</td>
                         <td style="white-space: nowrap;">
-                            <button type="button" onclick="showEditForm('{{.Id}}', {{.LoadRunnerInfo.WorkerCfg.InFlight}}, '{{.Mode}}', {{.LoadRunnerInfo.WorkerCfg.Qps}}, '{{.LoadRunnerInfo.WorkerCfg.Timeout}}')" style="margin-right: 10px;">Edit</button><button type="submit" form="remove-form-{{.Id}}" onclick="return confirm('Remove runner {{.Id}}?')">Remove</button>
+                            <button type="button" onclick="showEditForm('{{.Id}}', {{.LoadRunnerInfo.WorkerCfg.InFlight}}, '{{.Mode}}', {{.LoadRunnerInfo.WorkerCfg.Qps}}, '{{.LoadRunnerInfo.WorkerCfg.Timeout}}', '{{.LoadType}}', {{.LoadOptions}})" style="margin-right: 10px;">Edit</button><button type="submit" form="remove-form-{{.Id}}" onclick="return confirm('Remove runner {{.Id}}?')">Remove</button><button type="submit" form="drain-form-{{.Id}}" onclick="return confirm('Remove runner {{.Id}} after its in-flight requests finish?')" style="margin-left: 10px;">Drain</button>
                             <form id="remove-form-{{.Id}}" method="post" action="/remove-runner" style="display: none;">
                                 <input type="hidden" name="runner_id" value="{{.Id}}">
                             </form>
+                            <form id="drain-form-{{.Id}}" method="post" action="/remove-runner" style="display: none;">
+                                <input type="hidden" name="runner_id" value="{{.Id}}">
+                                <input type="hidden" name="drain_timeout" value="{{.LoadRunnerInfo.WorkerCfg.Timeout}}">
+                            </form>
                         </td>
                     </tr>
                     {{end}}
@@ -372,6 +558,7 @@ const indexTemplate = `
                         <td><input type="text" id="edit-timeout" name="timeout"></td>
                     </tr>
                 </table>
+                <div id="edit-load-options-container"></div>
                 <button type="submit">Update Runner</button>
                 <button type="button" onclick="hideEditForm()">Cancel</button>
             </form>
@@ -381,14 +568,18 @@ const indexTemplate = `
             <h2>Usage</h2>
             <ul>
                 <li><strong>Add Runner:</strong> Click "Add New Runner" to create a new runner with default configuration</li>
-                <li><strong>Edit Runner:</strong> Click "Edit" next to any runner to modify its configuration</li>
+                <li><strong>Validate:</strong> Check the new runner configuration and server connectivity without generating load</li>
+                <li><strong>Edit Runner:</strong> Click "Edit" next to any runner to modify its configuration. Changing load options restarts the runner with the same ID</li>
                 <li><strong>Remove Runner:</strong> Click "Remove" to delete a runner (confirmation required)</li>
-                <li><strong>Server Address:</strong> Use traditional addresses (localhost:8081) or Kubernetes services (k8s://my-service.default:8080)</li>
-                <li><strong>In-Flight Requests:</strong> Per-runner limit of concurrent requests allowed</li>
+                <li><strong>Server Address:</strong> Use traditional addresses (localhost:8081) or Kubernetes services (k8s://my-service.default:8080). Separate several addresses with commas to spread requests over them, with an optional weight each (a:8081@3,b:8081@1)</li>
+                <li><strong>In-Flight Requests:</strong> Per-runner limit of concurrent requests allowed, all runners together are limited by the global max</li>
                 <li><strong>ASAP Mode:</strong> Send requests as fast as possible (limited only by In-Flight)</li>
                 <li><strong>Static Interval:</strong> Send requests at regular intervals based on Target QPS</li>
                 <li><strong>Exponential Distribution:</strong> Send requests with exponentially distributed intervals (average = Target QPS)</li>
                 <li><strong>Request Timeout:</strong> Maximum time to wait for each request (e.g., "10s", "500ms")</li>
+                <li><strong>Max Requests / Max Duration:</strong> Stop the runner after sending this many requests or running this long (e.g., "5m"), whichever comes first, for repeatable benchmarks. In-flight requests finish, then the runner shows as done until removed. Empty means no limit</li>
+                <li><strong>Observed QPS:</strong> Requests per second of all runners together, measured between page refreshes over the last 30s</li>
+                <li><strong>Failed:</strong> Click the failed count to see the last errors with their gRPC status</li>
                 <li><strong>Prometheus Metrics:</strong> Metrics are labeled with runner_id for per-runner analysis</li>
             </ul>
         </div>
@@ -403,11 +594,36 @@ const indexTemplate = `
             document.getElementById('add-form').style.display = 'none';
             document.getElementById('load-options-container').innerHTML = '';
             document.getElementById('load-type-select').value = '';
+            document.getElementById('validate-result').textContent = '';
+        }
+
+        function validateRunner() {
+            const form = document.getElementById('add-runner-form');
+            const result = document.getElementById('validate-result');
+            if (!form.reportValidity()) {
+                return;
+            }
+
+            result.style.color = '#666';
+            result.textContent = 'Validating...';
+            fetch('/validate-runner', { method: 'POST', body: new URLSearchParams(new FormData(form)) })
+                .then(response => response.text().then(text => {
+                    result.style.color = response.ok ? 'green' : 'red';
+                    result.textContent = response.ok ? 'Configuration is valid' : text;
+                }))
+                .catch(error => {
+                    result.style.color = 'red';
+                    result.textContent = 'Error validating runner: ' + error;
+                });
         }
 
         function loadOptionsForType(loadType) {
-            const container = document.getElementById('load-options-container');
+            renderLoadOptions(document.getElementById('load-options-container'), loadType, null);
+        }
 
+        // renderLoadOptions fills container with inputs for the options of loadType,
+        // prefilled from values or, if values is null, from the option defaults
+        function renderLoadOptions(container, loadType, values) {
             if (!loadType) {
                 container.innerHTML = '';
                 return;
@@ -418,9 +634,10 @@ const indexTemplate = `
                 .then(options => {
                     let html = '<table><tr><th colspan="2" style="background-color: #f0f0f0;">Load-Specific Options</th></tr>';
 
-                    for (const [key, description] of Object.entries(options)) {
-                        html += '<tr><th>' + key + '</th>';
-                        html += '<td><input type="text" name="' + key + '" placeholder="' + description + '" style="width: 100%;"></td></tr>';
+                    for (const option of options) {
+                        const value = values ? (values[option.name] || '') : option.default_value;
+                        html += '<tr><th>' + option.name + (option.required ? ' *' : '') + '</th>';
+                        html += '<td><input type="text" name="' + option.name + '" value="' + value + '" placeholder="' + option.description + '" title="' + option.description + '"' + (option.required ? ' required' : '') + ' style="width: 100%;"></td></tr>';
                     }
 
                     html += '</table>';
@@ -431,7 +648,7 @@ const indexTemplate = `
                 });
         }
 
-        function showEditForm(runnerId, inflight, mode, qps, timeout) {
+        function showEditForm(runnerId, inflight, mode, qps, timeout, loadType, loadOptions) {
             // Hide add form if it's open
             hideAddForm();
 
@@ -441,12 +658,14 @@ const indexTemplate = `
             document.getElementById('edit-mode').value = mode;
             document.getElementById('edit-qps').value = qps;
             document.getElementById('edit-timeout').value = timeout;
+            renderLoadOptions(document.getElementById('edit-load-options-container'), loadType, loadOptions || {});
             document.getElementById('edit-form').style.display = 'block';
             document.getElementById('edit-form').scrollIntoView({ behavior: 'smooth' });
         }
 
         function hideEditForm() {
             document.getElementById('edit-form').style.display = 'none';
+            document.getElementById('edit-load-options-container').innerHTML = '';
         }
     </script>
 </body>
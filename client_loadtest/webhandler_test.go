@@ -0,0 +1,179 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/mhbvr/manul/client_loadtest/loadrunner"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	loadTester, err := NewLoadTester(10, 2, 0, prometheus.NewRegistry())
+	if err != nil {
+		t.Fatalf("NewLoadTester() failed: %v", err)
+	}
+	t.Cleanup(func() { loadTester.Close() })
+
+	mux := http.NewServeMux()
+	NewWebHandler(loadTester, nil).RegisterRoutes(mux)
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestLoadOptionsRoute(t *testing.T) {
+	server := newTestServer(t)
+
+	resp, err := http.Get(server.URL + "/api/load-options?type=CatPhotoStreamLoad")
+	if err != nil {
+		t.Fatalf("GET /api/load-options failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET /api/load-options status = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", ct)
+	}
+
+	var options []loadrunner.OptionDescription
+	if err := json.NewDecoder(resp.Body).Decode(&options); err != nil {
+		t.Fatalf("Failed to decode options: %v", err)
+	}
+
+	byName := make(map[string]loadrunner.OptionDescription)
+	for _, option := range options {
+		byName[option.Name] = option
+	}
+	if addr, ok := byName["addr"]; !ok || !addr.Required {
+		t.Errorf("Expected required addr option, got %+v", options)
+	}
+	if batch, ok := byName["max_batch_size"]; !ok || batch.DefaultValue != "10" {
+		t.Errorf("Expected max_batch_size option with default 10, got %+v", options)
+	}
+}
+
+func TestLoadOptionsRoute_Errors(t *testing.T) {
+	server := newTestServer(t)
+
+	for _, url := range []string{"/api/load-options", "/api/load-options?type=NoSuchLoad"} {
+		resp, err := http.Get(server.URL + url)
+		if err != nil {
+			t.Fatalf("GET %s failed: %v", url, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("GET %s status = %d, want %d", url, resp.StatusCode, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestRequireAuth(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {})
+	server := httptest.NewServer(RequireAuth(mux, "admin", "secret"))
+	defer server.Close()
+
+	tests := []struct {
+		name       string
+		method     string
+		user       string
+		password   string
+		wantStatus int
+	}{
+		{"read without credentials", http.MethodGet, "", "", http.StatusOK},
+		{"mutation without credentials", http.MethodPost, "", "", http.StatusUnauthorized},
+		{"mutation with wrong password", http.MethodPost, "admin", "wrong", http.StatusUnauthorized},
+		{"mutation with credentials", http.MethodPost, "admin", "secret", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, server.URL+"/add-runner", nil)
+			if err != nil {
+				t.Fatalf("NewRequest() failed: %v", err)
+			}
+			if tt.user != "" {
+				req.SetBasicAuth(tt.user, tt.password)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("Request failed: %v", err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("Status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestValidateRunnerRoute(t *testing.T) {
+	server := newTestServer(t)
+
+	// Reserve a port and close it so nothing listens there
+	lis, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("Listen() failed: %v", err)
+	}
+	closedAddr := lis.Addr().String()
+	lis.Close()
+
+	tests := []struct {
+		name     string
+		form     url.Values
+		wantBody string
+	}{
+		{"missing load type", url.Values{}, "load_type is required"},
+		{"unknown load type", url.Values{"load_type": {"NoSuchLoad"}}, "Invalid load type"},
+		{"missing required option", url.Values{"load_type": {"CatPhotoLoad"}}, "Invalid load options"},
+		{"unknown mode", url.Values{"load_type": {"CatPhotoLoad"}, "addr": {closedAddr}, "mode": {"burst"}}, "unknown mode"},
+		{"unreachable server", url.Values{"load_type": {"CatPhotoLoad"}, "addr": {closedAddr}}, "Validation failed"},
+		{"invalid max requests", url.Values{"load_type": {"CatPhotoLoad"}, "addr": {closedAddr}, "max_requests": {"many"}}, "Failed to parse max_requests"},
+		{"negative max duration", url.Values{"load_type": {"CatPhotoLoad"}, "addr": {closedAddr}, "max_duration": {"-1m"}}, "must not be negative"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := http.PostForm(server.URL+"/validate-runner", tt.form)
+			if err != nil {
+				t.Fatalf("POST /validate-runner failed: %v", err)
+			}
+			defer resp.Body.Close()
+			body, _ := io.ReadAll(resp.Body)
+
+			if resp.StatusCode != http.StatusBadRequest {
+				t.Errorf("Status = %d, want %d", resp.StatusCode, http.StatusBadRequest)
+			}
+			if !strings.Contains(string(body), tt.wantBody) {
+				t.Errorf("Body = %q, want it to contain %q", body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestRemoveRunnerRoute_InvalidDrainTimeout(t *testing.T) {
+	server := newTestServer(t)
+
+	for _, drainTimeout := range []string{"soon", "0s", "-1s"} {
+		form := url.Values{"runner_id": {"CatPhotoLoad-0"}, "drain_timeout": {drainTimeout}}
+		resp, err := http.PostForm(server.URL+"/remove-runner", form)
+		if err != nil {
+			t.Fatalf("POST /remove-runner failed: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("POST /remove-runner with drain_timeout=%s status = %d, want %d", drainTimeout, resp.StatusCode, http.StatusBadRequest)
+		}
+	}
+}
@@ -7,6 +7,8 @@ import (
 	"io"
 	"log"
 	"math/rand"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"go.opentelemetry.io/otel"
@@ -16,16 +18,37 @@ import (
 )
 
 var (
-	workerClosed = errors.New("Worker closed")
-	tracer       = otel.Tracer("worker")
+	workerClosed      = errors.New("Worker closed")
+	workerDraining    = errors.New("Worker draining")
+	workerMaxRequests = errors.New("Worker sent max requests")
+	workerMaxDuration = errors.New("Worker ran for max duration")
+	tracer            = otel.Tracer("worker")
+)
+
+// TimeoutMode defines how a job exceeding the timeout is handled
+type TimeoutMode int
+
+const (
+	// SoftTimeout cancels the job context and waits for the job to return.
+	// The in-flight token is held until then, so a job ignoring its context
+	// blocks the token for as long as it runs.
+	SoftTimeout TimeoutMode = iota
+
+	// HardTimeout cancels the job context and abandons the job at the deadline:
+	// the job is recorded as failed and its token is freed immediately.
+	// Abandoned jobs keep running in the background, so jobs ignoring their
+	// context can accumulate without bound (goroutines, connections, memory)
+	// beyond the in-flight limit.
+	HardTimeout
 )
 
 // WorkerConfig defines the configuration for a Worker instance that is adjustable in runtime
 type WorkerConfig struct {
-	InFlight          int                         // Limit number of in-flight requests allowed
-	IntervalGenerator func(float64) time.Duration // Function that generates intervals between requests (nil for ASAP mode)
-	Qps               float64                     // Target queries per second
-	Timeout           time.Duration               // Timeout for individual job executions
+	InFlight          int                                     // Limit number of in-flight requests allowed
+	IntervalGenerator func(*rand.Rand, float64) time.Duration // Function that generates intervals between requests (nil for ASAP mode)
+	Qps               float64                                 // Target queries per second
+	Timeout           time.Duration                           // Timeout for individual job executions
+	TimeoutMode       TimeoutMode                             // Handling of jobs exceeding Timeout
 }
 
 func (cfg WorkerConfig) IsValid() error {
@@ -40,27 +63,42 @@ func (cfg WorkerConfig) IsValid() error {
 	if cfg.Timeout < 0 {
 		return fmt.Errorf("Timeout < 0")
 	}
+
+	if cfg.TimeoutMode != SoftTimeout && cfg.TimeoutMode != HardTimeout {
+		return fmt.Errorf("unknown TimeoutMode %d", cfg.TimeoutMode)
+	}
 	return nil
 }
 
 // StableIntervalGenerator produces (fixed) intervals.
-func StableIntervalGenerator(qps float64) time.Duration {
+func StableIntervalGenerator(_ *rand.Rand, qps float64) time.Duration {
 	if qps == 0 {
 		return 0
 	}
 	return time.Duration(float64(time.Second) / qps)
 }
 
-// ExponentialIntervalGenerator produces exponentially distributed intervals.
-func ExponentialIntervalGenerator(qps float64) time.Duration {
+// ExponentialIntervalGenerator produces exponentially distributed intervals
+// drawn from r, the source of the calling worker.
+func ExponentialIntervalGenerator(r *rand.Rand, qps float64) time.Duration {
 	if qps == 0 {
 		return 0
 	}
-	return time.Duration(rand.ExpFloat64() / qps * float64(time.Second))
+	return time.Duration(r.ExpFloat64() / qps * float64(time.Second))
 }
 
 type Option func(*Worker)
 
+// Progress reports how far a worker with a request or duration limit got
+type Progress struct {
+	Requests     int           // Jobs dispatched so far
+	MaxRequests  int           // Limit of dispatched jobs, 0 = no limit
+	Elapsed      time.Duration // Time since the worker started, until it stopped at a limit
+	MaxDuration  time.Duration // Limit of the run time, 0 = no limit
+	LimitReached bool          // No more jobs are dispatched because a limit was reached
+	Finished     bool          // A limit was reached and the in-flight jobs returned
+}
+
 // Worker manages concurrent execution of jobs with configurable rate limiting and timing modes.
 type Worker struct {
 	ctx         context.Context
@@ -70,11 +108,29 @@ type Worker struct {
 	cfg         WorkerConfig // Current configuration
 
 	tokens      chan struct{}          // Token bucket for in-flight limiting
+	shared      chan struct{}          // In-flight pool shared with other workers (nil if not shared)
 	cfgChan     chan WorkerConfig      // Channel for configuration updates
 	readCfgChan chan chan WorkerConfig // Channel for reading current configuration
 
+	drain     chan struct{}  // Closed to stop scheduling jobs
+	drainOnce sync.Once      // Closes drain once
+	loopDone  chan struct{}  // Closed when the loop returns
+	jobs      sync.WaitGroup // In-flight jobs
+
 	job      func(context.Context) (time.Duration, error) // Job function to execute
 	recorder func(float64, bool)                          // Recorder function for metrics
+	rand     *rand.Rand                                   // Source of the interval generator, used by the loop only
+
+	stallInterval time.Duration // Max time without dispatched jobs before a stall is reported (0 = disabled)
+	onStall       func(bool)    // Called when a stall is detected and when it ends
+
+	maxRequests  int           // Jobs dispatched before the worker stops (0 = no limit)
+	maxDuration  time.Duration // Time after which the worker stops (0 = no limit)
+	startTime    time.Time
+	dispatched   atomic.Int64
+	limitReached atomic.Bool
+	finished     chan struct{} // Closed when the worker stopped at a limit and its jobs returned
+	finishTime   time.Time     // Set before finished is closed
 
 	logger *log.Logger
 }
@@ -94,7 +150,11 @@ func NewWorker(ctx context.Context, job func(context.Context) (time.Duration, er
 		},
 		cfgChan:     make(chan WorkerConfig),
 		readCfgChan: make(chan chan WorkerConfig),
+		drain:       make(chan struct{}),
+		loopDone:    make(chan struct{}),
+		finished:    make(chan struct{}),
 		job:         job,
+		rand:        rand.New(rand.NewSource(rand.Int63())),
 		logger:      log.New(io.Discard, "", 0),
 	}
 
@@ -110,19 +170,41 @@ func NewWorker(ctx context.Context, job func(context.Context) (time.Duration, er
 		return nil, fmt.Errorf("cfg.InFlight > maxInFlight limit")
 	}
 
+	if res.stallInterval < 0 {
+		return nil, fmt.Errorf("stallInterval < 0")
+	}
+
+	if res.maxRequests < 0 {
+		return nil, fmt.Errorf("maxRequests < 0")
+	}
+
+	if res.maxDuration < 0 {
+		return nil, fmt.Errorf("maxDuration < 0")
+	}
+
 	res.tokens = make(chan struct{}, res.maxInFlight)
 	for i := 0; i < res.cfg.InFlight; i++ {
 		res.tokens <- struct{}{}
 	}
 
 	res.ctx, res.cancelCause = context.WithCancelCause(ctx)
+	res.startTime = time.Now()
 
-	res.logger.Printf("Starting worker: maxInflight: %d, inFlight: %d, Qps: %f, Timeout: %fs",
-		res.maxInFlight, res.cfg.InFlight, res.cfg.Qps, res.cfg.Timeout.Seconds())
+	res.logger.Printf("Starting worker: maxInflight: %d, inFlight: %d, Qps: %f, Timeout: %fs, hard timeout: %v",
+		res.maxInFlight, res.cfg.InFlight, res.cfg.Qps, res.cfg.Timeout.Seconds(), res.cfg.TimeoutMode == HardTimeout)
 
 	go func() {
 		err := res.loop()
+		close(res.loopDone)
 		res.logger.Printf("Worker terminated: %v", err)
+
+		if errors.Is(err, workerMaxRequests) || errors.Is(err, workerMaxDuration) {
+			// Let the in-flight jobs finish before cancelling their context
+			res.jobs.Wait()
+			res.finishTime = time.Now()
+			close(res.finished)
+			res.cancelCause(err)
+		}
 	}()
 
 	return res, nil
@@ -146,12 +228,70 @@ func WithMaxInFlight(maxInFlight int) func(w *Worker) {
 	}
 }
 
+// WithSharedInFlight limits in-flight jobs of all workers using the same pool
+// to the pool capacity. A slot is taken by sending to the pool and released by
+// receiving from it.
+func WithSharedInFlight(pool chan struct{}) func(w *Worker) {
+	return func(w *Worker) {
+		w.shared = pool
+	}
+}
+
+// WithRecorder sets a function called after each job with the duration
+// returned by the job in seconds and whether it succeeded. Jobs abandoned
+// at a hard timeout are recorded as failed with the timeout duration.
 func WithRecorder(recorder func(float64, bool)) func(w *Worker) {
 	return func(w *Worker) {
 		w.recorder = recorder
 	}
 }
 
+// WithStallWatchdog makes the worker log a warning and call onStall(true)
+// when no job is dispatched for interval although the config allows jobs,
+// e.g. because hanging jobs hold all tokens or all slots of the shared pool. onStall(false) is called when
+// jobs are dispatched again or the worker stops. onStall may be nil.
+func WithStallWatchdog(interval time.Duration, onStall func(bool)) func(w *Worker) {
+	return func(w *Worker) {
+		w.stallInterval = interval
+		w.onStall = onStall
+	}
+}
+
+// WithMaxRequests makes the worker stop after dispatching n jobs. The
+// worker closes itself when the last jobs return. 0 means no limit.
+func WithMaxRequests(n int) func(w *Worker) {
+	return func(w *Worker) {
+		w.maxRequests = n
+	}
+}
+
+// WithMaxDuration makes the worker stop dispatching jobs d after it started.
+// The worker closes itself when the in-flight jobs return. 0 means no limit.
+func WithMaxDuration(d time.Duration) func(w *Worker) {
+	return func(w *Worker) {
+		w.maxDuration = d
+	}
+}
+
+// Progress returns the dispatched jobs and run time with their limits
+func (w *Worker) Progress() Progress {
+	res := Progress{
+		Requests:     int(w.dispatched.Load()),
+		MaxRequests:  w.maxRequests,
+		Elapsed:      time.Since(w.startTime),
+		MaxDuration:  w.maxDuration,
+		LimitReached: w.limitReached.Load(),
+	}
+
+	select {
+	case <-w.finished:
+		res.Finished = true
+		res.Elapsed = w.finishTime.Sub(w.startTime)
+	default:
+	}
+	return res
+}
+
 // GetConfig returns a copy of the current configuration.
 func (w *Worker) GetConfig() (*WorkerConfig, error) {
 	respChan := make(chan WorkerConfig, 1)
@@ -159,6 +299,8 @@ func (w *Worker) GetConfig() (*WorkerConfig, error) {
 	select {
 	case <-w.ctx.Done():
 		return nil, context.Cause(w.ctx)
+	case <-w.loopDone:
+		return nil, workerDraining
 	case w.readCfgChan <- respChan:
 	}
 
@@ -184,6 +326,8 @@ func (w *Worker) SetConfig(cfg *WorkerConfig) error {
 	select {
 	case <-w.ctx.Done():
 		return context.Cause(w.ctx)
+	case <-w.loopDone:
+		return workerDraining
 	case w.cfgChan <- *cfg:
 	}
 	return nil
@@ -193,6 +337,35 @@ func (w *Worker) Close() {
 	w.cancelCause(workerClosed)
 }
 
+// Drain stops scheduling jobs and waits for in-flight jobs to return, then
+// closes the worker. If ctx is done first, the remaining jobs are cancelled
+// by closing the worker and the ctx error is returned.
+func (w *Worker) Drain(ctx context.Context) error {
+	defer w.Close()
+
+	w.drainOnce.Do(func() {
+		close(w.drain)
+	})
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-w.loopDone:
+	}
+
+	done := make(chan struct{})
+	go func() {
+		w.jobs.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-done:
+		return nil
+	}
+}
+
 // setTimer creates a timer channel based on the IntervalGenerator.
 // Returns nil for ASAP mode (when IntervalGenerator is nil or returns ≤0).
 func (w *Worker) setTimer() <-chan time.Time {
@@ -200,7 +373,7 @@ func (w *Worker) setTimer() <-chan time.Time {
 		return nil
 	}
 
-	interval := w.cfg.IntervalGenerator(w.cfg.Qps)
+	interval := w.cfg.IntervalGenerator(w.rand, w.cfg.Qps)
 	if interval <= 0 {
 		return nil
 	}
@@ -209,28 +382,131 @@ func (w *Worker) setTimer() <-chan time.Time {
 }
 
 // do executes the job function with the given timeout and returns a token when done.
-// This method handles the actual job execution and token management.
-func (w *Worker) do(ctx context.Context, timeout time.Duration) {
+// This method handles the actual job execution and token management. The slot in the
+// shared pool, if any, is taken by the loop before the job is dispatched.
+func (w *Worker) do(ctx context.Context, timeout time.Duration, mode TimeoutMode) {
+	defer w.jobs.Done()
 	defer func() {
 		w.tokens <- struct{}{}
 	}()
+	if w.shared != nil {
+		defer func() {
+			<-w.shared
+		}()
+	}
+
 	ctx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
-	duration, err := w.job(ctx)
+	var duration time.Duration
+	var err error
+	if mode == HardTimeout {
+		duration, err = w.runHard(ctx, timeout)
+	} else {
+		duration, err = w.job(ctx)
+	}
 
 	if w.recorder != nil {
 		w.recorder(duration.Seconds(), err == nil)
 	}
 }
 
+// runHard runs the job in a separate goroutine and returns at the deadline
+// even if the job does not, leaving the job running in the background.
+func (w *Worker) runHard(ctx context.Context, timeout time.Duration) (time.Duration, error) {
+	type result struct {
+		duration time.Duration
+		err      error
+	}
+	done := make(chan result, 1)
+
+	go func() {
+		duration, err := w.job(ctx)
+		done <- result{duration, err}
+	}()
+
+	select {
+	case res := <-done:
+		return res.duration, res.err
+	case <-ctx.Done():
+		return timeout, context.Cause(ctx)
+	}
+}
+
+// stallWatchdog tracks job dispatching in the loop to detect stalls
+type stallWatchdog struct {
+	w         *Worker
+	waitSince time.Time // Start of the current wait for a token
+	stalled   bool
+}
+
+// dispatched records a dispatched job, ending a stall
+func (d *stallWatchdog) dispatched() {
+	d.waitSince = time.Now()
+	if d.stalled {
+		d.w.logger.Printf("Worker recovered: jobs are dispatched again")
+		d.setStalled(false)
+	}
+}
+
+// reset restarts the interval, when the loop starts waiting for a token
+// or after a config change
+func (d *stallWatchdog) reset() {
+	d.waitSince = time.Now()
+}
+
+// check reports a stall if the loop has been waiting for a token or a
+// shared slot for the interval although the config allows jobs. Waiting for the rate limiting
+// timer is not a stall.
+func (d *stallWatchdog) check(waiting bool) {
+	idle := time.Since(d.waitSince)
+	if d.stalled || !waiting || d.w.cfg.InFlight == 0 || idle < d.w.stallInterval {
+		return
+	}
+	d.w.logger.Printf("WARNING: worker stalled, no in-flight token or shared slot freed for %v, %d in flight allowed",
+		idle.Round(time.Millisecond), d.w.cfg.InFlight)
+	d.setStalled(true)
+}
+
+func (d *stallWatchdog) setStalled(stalled bool) {
+	d.stalled = stalled
+	if d.w.onStall != nil {
+		d.w.onStall(stalled)
+	}
+}
+
+// stop ends a stall when the loop returns
+func (d *stallWatchdog) stop() {
+	if d.stalled {
+		d.setStalled(false)
+	}
+}
+
 // loop handles job scheduling, rate limiting, and configuration updates.
-// This method blocks until the context is cancelled.
+// This method blocks until the context is cancelled or the worker is drained.
 func (w *Worker) loop() error {
 	_, span := tracer.Start(w.ctx, "worker_loop")
 
+	watchdog := &stallWatchdog{w: w, waitSince: time.Now()}
+	defer watchdog.stop()
+	var watchdogTick <-chan time.Time
+	if w.stallInterval > 0 {
+		// Check twice per interval, a stall is reported within 1.5 intervals
+		ticker := time.NewTicker(max(w.stallInterval/2, time.Millisecond))
+		defer ticker.Stop()
+		watchdogTick = ticker.C
+	}
+
+	var deadline <-chan time.Time
+	if w.maxDuration > 0 {
+		deadlineTimer := time.NewTimer(w.maxDuration - time.Since(w.startTime))
+		defer deadlineTimer.Stop()
+		deadline = deadlineTimer.C
+	}
+
 	timer := w.setTimer()
 	var trigger chan struct{}
+	var sharedTrigger chan struct{} // The shared pool while a token waits for a slot
 	currentInFlight := w.cfg.InFlight
 
 	if timer == nil {
@@ -251,20 +527,56 @@ func (w *Worker) loop() error {
 			span.SetStatus(codes.Ok, "")
 			span.End()
 			return context.Cause(w.ctx)
+		case <-w.drain:
+			span.SetStatus(codes.Ok, "")
+			span.End()
+			return workerDraining
+		case <-deadline:
+			w.limitReached.Store(true)
+			span.AddEvent("max duration reached")
+			span.SetStatus(codes.Ok, "")
+			span.End()
+			return workerMaxDuration
 		case <-timer:
 			// Timer was set and expired
 			// We can aquire token now when available on the next loop
-			trigger = w.tokens
+			if sharedTrigger == nil {
+				trigger = w.tokens
+			}
+			watchdog.reset()
 		case <-trigger:
 			if currentInFlight > w.cfg.InFlight {
-				// Need to decrease in flight because of config change
+				// Need to decrease in flight because of config change,
+				// a token returned by a job over the new limit is dropped
 				// Skiping do() execution
 				currentInFlight--
 				continue
 			}
 
-			go w.do(w.ctx, w.cfg.Timeout)
+			if w.shared != nil {
+				// Wait for a slot in the shared pool holding the token,
+				// the job is dispatched when the slot is taken
+				trigger = nil
+				sharedTrigger = w.shared
+				continue
+			}
+			if w.dispatch(span, watchdog) {
+				return workerMaxRequests
+			}
+			trigger = w.tokens
+			if timer != nil {
+				// As we using timer we need to wait for the it
+				// before sending request. Disabling trigger
+				trigger = nil
+				timer = w.setTimer()
+			}
 
+		case sharedTrigger <- struct{}{}:
+			sharedTrigger = nil
+			if w.dispatch(span, watchdog) {
+				return workerMaxRequests
+			}
+			trigger = w.tokens
 			if timer != nil {
 				// As we using timer we need to wait for the it
 				// before sending request. Disabling trigger
@@ -289,14 +601,52 @@ func (w *Worker) loop() error {
 				currentInFlight++
 			}
 
+			if sharedTrigger != nil && currentInFlight > cfg.InFlight {
+				// Drop the token waiting for a shared slot
+				sharedTrigger = nil
+				currentInFlight--
+			}
+
+			// Decrease it by dropping the free tokens over the limit now,
+			// tokens held by in-flight jobs are dropped when they return
+		dropFree:
+			for currentInFlight > cfg.InFlight {
+				select {
+				case <-w.tokens:
+					currentInFlight--
+				default:
+					break dropFree
+				}
+			}
+
 			// Reset timers as interval generator or qps can changed
 			timer = w.setTimer()
-			if timer == nil {
+			watchdog.reset()
+			if timer == nil && sharedTrigger == nil {
 				// Need to wait for the timer first
 				trigger = w.tokens
 			}
 		case respChan := <-w.readCfgChan:
 			respChan <- w.cfg
+		case <-watchdogTick:
+			watchdog.check(trigger != nil || sharedTrigger != nil)
 		}
 	}
 }
+
+// dispatch starts a job with a token, and a shared slot if the pool is
+// shared, taken by the loop. It returns true if max requests are reached.
+func (w *Worker) dispatch(span trace.Span, watchdog *stallWatchdog) bool {
+	w.jobs.Add(1)
+	go w.do(w.ctx, w.cfg.Timeout, w.cfg.TimeoutMode)
+	watchdog.dispatched()
+
+	if n := w.dispatched.Add(1); w.maxRequests > 0 && n >= int64(w.maxRequests) {
+		w.limitReached.Store(true)
+		span.AddEvent("max requests reached", trace.WithAttributes(attribute.Int64("requests", n)))
+		span.SetStatus(codes.Ok, "")
+		span.End()
+		return true
+	}
+	return false
+}
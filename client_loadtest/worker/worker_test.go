@@ -3,6 +3,8 @@ package worker
 import (
 	"context"
 	"errors"
+	"math"
+	"math/rand"
 	"sync"
 	"sync/atomic"
 	"testing"
@@ -77,6 +79,22 @@ func TestNewWorker(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "negative maxRequests",
+			job:  validJob,
+			opts: []Option{
+				WithMaxRequests(-1),
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative maxDuration",
+			job:  validJob,
+			opts: []Option{
+				WithMaxDuration(-time.Second),
+			},
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -438,6 +456,66 @@ func TestExponentialIntervalVariability(t *testing.T) {
 	t.Logf("Exponential timing: executed %d jobs with varying intervals", len(jobTimes))
 }
 
+// TestExponentialIntervalDistribution tests that exponential intervals have
+// the expected mean and tail
+func TestExponentialIntervalDistribution(t *testing.T) {
+	t.Parallel()
+
+	const (
+		qps     = 100.0
+		samples = 100000
+	)
+	r := rand.New(rand.NewSource(1))
+	mean := time.Duration(float64(time.Second) / qps)
+
+	var sum time.Duration
+	above := 0
+	for i := 0; i < samples; i++ {
+		interval := ExponentialIntervalGenerator(r, qps)
+		if interval < 0 {
+			t.Fatalf("ExponentialIntervalGenerator() = %v, want >= 0", interval)
+		}
+		sum += interval
+		if interval > mean {
+			above++
+		}
+	}
+
+	// The mean is 1/qps and P(X > mean) = 1/e
+	gotMean := sum / samples
+	if diff := gotMean - mean; diff < -mean/50 || diff > mean/50 {
+		t.Errorf("Mean interval = %v, want %v ± 2%%", gotMean, mean)
+	}
+	gotTail := float64(above) / samples
+	if math.Abs(gotTail-1/math.E) > 0.01 {
+		t.Errorf("Fraction of intervals above the mean = %.3f, want %.3f ± 0.01", gotTail, 1/math.E)
+	}
+
+	if interval := ExponentialIntervalGenerator(r, 0); interval != 0 {
+		t.Errorf("ExponentialIntervalGenerator() with zero QPS = %v, want 0", interval)
+	}
+}
+
+// BenchmarkExponentialIntervalGenerator compares generating intervals from
+// the global source and from a source per worker, with parallel workers
+func BenchmarkExponentialIntervalGenerator(b *testing.B) {
+	b.Run("global", func(b *testing.B) {
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				_ = time.Duration(rand.ExpFloat64() / 100 * float64(time.Second))
+			}
+		})
+	})
+	b.Run("per worker", func(b *testing.B) {
+		b.RunParallel(func(pb *testing.PB) {
+			r := rand.New(rand.NewSource(rand.Int63()))
+			for pb.Next() {
+				_ = ExponentialIntervalGenerator(r, 100)
+			}
+		})
+	})
+}
+
 // TestZeroQPSHandling tests behavior with zero QPS
 func TestZeroQPSHandling(t *testing.T) {
 	t.Parallel()
@@ -528,6 +606,65 @@ func TestInFlightLimiting(t *testing.T) {
 	t.Logf("Max concurrent jobs: %d (limit: %d), total executed: %d", maxActiveJobs, expectedInFlight, totalExecuted)
 }
 
+// TestSharedInFlight tests that workers sharing a pool respect the combined limit
+func TestSharedInFlight(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+
+	var activeJobs int64
+	var maxActive int64
+	var totalJobs [2]int64
+
+	newJob := func(i int) func(context.Context) (time.Duration, error) {
+		return func(context.Context) (time.Duration, error) {
+			current := atomic.AddInt64(&activeJobs, 1)
+			defer atomic.AddInt64(&activeJobs, -1)
+
+			for {
+				max := atomic.LoadInt64(&maxActive)
+				if current <= max || atomic.CompareAndSwapInt64(&maxActive, max, current) {
+					break
+				}
+			}
+
+			atomic.AddInt64(&totalJobs[i], 1)
+			time.Sleep(20 * time.Millisecond) // Simulate work
+			return 20 * time.Millisecond, nil
+		}
+	}
+
+	sharedLimit := 4
+	pool := make(chan struct{}, sharedLimit)
+	for i := 0; i < 2; i++ {
+		// Each worker alone could use the whole pool
+		worker, err := NewWorker(ctx, newJob(i), WithConfig(WorkerConfig{
+			InFlight: sharedLimit,
+			Timeout:  time.Second,
+		}), WithMaxInFlight(10), WithSharedInFlight(pool))
+		if err != nil {
+			t.Fatalf("NewWorker() failed: %v", err)
+		}
+		defer worker.Close()
+	}
+
+	<-ctx.Done()
+
+	maxActiveJobs := atomic.LoadInt64(&maxActive)
+	if maxActiveJobs > int64(sharedLimit) {
+		t.Errorf("Max active jobs exceeded shared limit: got %d, want ≤ %d", maxActiveJobs, sharedLimit)
+	}
+
+	for i := range totalJobs {
+		if atomic.LoadInt64(&totalJobs[i]) == 0 {
+			t.Errorf("No jobs executed by worker %d", i)
+		}
+	}
+
+	t.Logf("Max concurrent jobs: %d (shared limit: %d)", maxActiveJobs, sharedLimit)
+}
+
 // TestDynamicInFlightIncrease tests increasing in-flight limit dynamically
 func TestDynamicInFlightIncrease(t *testing.T) {
 	t.Parallel()
@@ -683,6 +820,87 @@ func TestDynamicInFlightDecrease(t *testing.T) {
 	t.Logf("Dynamic decrease: max overall=%d, final active=%d (limit was decreased to 2)", maxOverall, currentActive)
 }
 
+// TestDynamicInFlightDecreaseTokens tests that rapid decreases leave as many tokens
+// as the in-flight limit, free tokens are dropped immediately and held ones on return
+func TestDynamicInFlightDecreaseTokens(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var activeJobs int64
+	release := make(chan struct{})
+	job := func(context.Context) (time.Duration, error) {
+		atomic.AddInt64(&activeJobs, 1)
+		defer atomic.AddInt64(&activeJobs, -1)
+		<-release
+		return 0, nil
+	}
+
+	// A rate limited config waiting for a long interval dispatches no jobs
+	idle := func(inFlight int) *WorkerConfig {
+		return &WorkerConfig{
+			InFlight:          inFlight,
+			IntervalGenerator: StableIntervalGenerator,
+			Qps:               0.001,
+			Timeout:           time.Second,
+		}
+	}
+
+	worker, err := NewWorker(ctx, job, WithConfig(*idle(10)), WithMaxInFlight(10))
+	if err != nil {
+		t.Fatalf("NewWorker() failed: %v", err)
+	}
+	defer worker.Close()
+
+	// All tokens are free
+	for _, inFlight := range []int{8, 5, 2} {
+		if err := worker.SetConfig(idle(inFlight)); err != nil {
+			t.Fatalf("SetConfig() failed: %v", err)
+		}
+	}
+	// GetConfig returns after the loop applied the previous updates
+	if _, err := worker.GetConfig(); err != nil {
+		t.Fatalf("GetConfig() failed: %v", err)
+	}
+	if got := len(worker.tokens); got != 2 {
+		t.Errorf("Free tokens after decreasing InFlight to 2 = %d, want 2", got)
+	}
+	worker.SetConfig(idle(4))
+	worker.GetConfig()
+	if got := len(worker.tokens); got != 4 {
+		t.Errorf("Free tokens after increasing InFlight to 4 = %d, want 4", got)
+	}
+
+	// All tokens are held by jobs
+	worker.SetConfig(&WorkerConfig{InFlight: 6, Timeout: time.Second})
+	for atomic.LoadInt64(&activeJobs) < 6 {
+		if ctx.Err() != nil {
+			t.Fatalf("Jobs not started: %d active, want 6", atomic.LoadInt64(&activeJobs))
+		}
+		time.Sleep(time.Millisecond)
+	}
+	for _, inFlight := range []int{4, 1, 3} {
+		if err := worker.SetConfig(idle(inFlight)); err != nil {
+			t.Fatalf("SetConfig() failed: %v", err)
+		}
+	}
+	close(release)
+	for atomic.LoadInt64(&activeJobs) > 0 || len(worker.tokens) != 3 {
+		if ctx.Err() != nil {
+			t.Fatalf("After jobs returned: %d active, %d free tokens, want 0 and 3",
+				atomic.LoadInt64(&activeJobs), len(worker.tokens))
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// No tokens beyond the limit appear later
+	time.Sleep(20 * time.Millisecond)
+	if got := len(worker.tokens); got != 3 {
+		t.Errorf("Free tokens = %d, want 3", got)
+	}
+}
+
 // TestJobTimeout tests that job timeouts are handled correctly
 func TestJobTimeout(t *testing.T) {
 	t.Parallel()
@@ -738,6 +956,91 @@ func TestJobTimeout(t *testing.T) {
 	t.Logf("Job timeout test: started=%d, completed=%d, timed_out=%d", started, completed, timedOut)
 }
 
+// TestSoftTimeout tests that a job ignoring its context holds the token in soft mode
+func TestSoftTimeout(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+
+	var jobStarted int64
+
+	job := func(ctx context.Context) (time.Duration, error) {
+		atomic.AddInt64(&jobStarted, 1)
+		time.Sleep(300 * time.Millisecond) // Ignores ctx
+		return 300 * time.Millisecond, nil
+	}
+
+	worker, err := NewWorker(ctx, job, WithConfig(WorkerConfig{
+		InFlight:    1,
+		Timeout:     50 * time.Millisecond,
+		TimeoutMode: SoftTimeout,
+	}))
+	if err != nil {
+		t.Fatalf("NewWorker() failed: %v", err)
+	}
+	defer worker.Close()
+
+	<-ctx.Done()
+
+	if started := atomic.LoadInt64(&jobStarted); started != 1 {
+		t.Errorf("Soft timeout: started %d jobs, want 1", started)
+	}
+}
+
+// TestHardTimeout tests that a job ignoring its context is abandoned at the deadline in hard mode
+func TestHardTimeout(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+
+	var jobStarted int64
+	var recordedFailures int64
+	var recordedSuccesses int64
+
+	job := func(ctx context.Context) (time.Duration, error) {
+		atomic.AddInt64(&jobStarted, 1)
+		time.Sleep(300 * time.Millisecond) // Ignores ctx
+		return 300 * time.Millisecond, nil
+	}
+
+	recorder := func(latency float64, success bool) {
+		if success {
+			atomic.AddInt64(&recordedSuccesses, 1)
+		} else {
+			atomic.AddInt64(&recordedFailures, 1)
+		}
+	}
+
+	worker, err := NewWorker(ctx, job, WithConfig(WorkerConfig{
+		InFlight:    1,
+		Timeout:     50 * time.Millisecond,
+		TimeoutMode: HardTimeout,
+	}), WithRecorder(recorder))
+	if err != nil {
+		t.Fatalf("NewWorker() failed: %v", err)
+	}
+	defer worker.Close()
+
+	<-ctx.Done()
+
+	started := atomic.LoadInt64(&jobStarted)
+	if started < 3 {
+		t.Errorf("Hard timeout: started %d jobs, want at least 3", started)
+	}
+
+	if failures := atomic.LoadInt64(&recordedFailures); failures == 0 {
+		t.Error("Hard timeout: expected abandoned jobs to be recorded as failures")
+	}
+
+	if successes := atomic.LoadInt64(&recordedSuccesses); successes != 0 {
+		t.Errorf("Hard timeout: recorded %d successes, want 0", successes)
+	}
+
+	t.Logf("Hard timeout test: started=%d", started)
+}
+
 // TestJobErrors tests that job errors don't crash the worker
 func TestJobErrors(t *testing.T) {
 	t.Parallel()
@@ -789,6 +1092,64 @@ func TestJobErrors(t *testing.T) {
 	t.Logf("Job error test: total_jobs=%d, errors=%d", totalJobs, totalErrors)
 }
 
+// TestRecorder tests that the recorder gets the duration and success of each job
+func TestRecorder(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// Job n takes n milliseconds, even jobs fail
+	var jobCount int64
+	job := func(context.Context) (time.Duration, error) {
+		n := atomic.AddInt64(&jobCount, 1)
+		if n%2 == 0 {
+			return time.Duration(n) * time.Millisecond, errors.New("test error")
+		}
+		return time.Duration(n) * time.Millisecond, nil
+	}
+
+	type record struct {
+		durationSeconds float64
+		success         bool
+	}
+	var mu sync.Mutex
+	var records []record
+	done := make(chan struct{})
+	recorder := func(durationSeconds float64, success bool) {
+		mu.Lock()
+		defer mu.Unlock()
+		records = append(records, record{durationSeconds, success})
+		if len(records) == 10 {
+			close(done)
+		}
+	}
+
+	worker, err := NewWorker(ctx, job, WithConfig(WorkerConfig{
+		InFlight: 1,
+		Timeout:  time.Second,
+	}), WithRecorder(recorder))
+	if err != nil {
+		t.Fatalf("NewWorker() failed: %v", err)
+	}
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		t.Fatalf("Recorded fewer than 10 jobs before the timeout")
+	}
+	worker.Drain(ctx)
+
+	mu.Lock()
+	defer mu.Unlock()
+	for _, r := range records[:10] {
+		n := int64(r.durationSeconds*1000 + 0.5)
+		if n < 1 || r.success != (n%2 == 1) {
+			t.Errorf("Recorded %v, success %v, want job duration and success", r.durationSeconds, r.success)
+		}
+	}
+}
+
 // TestWorkerClose tests that Close() stops the worker properly
 func TestWorkerClose(t *testing.T) {
 	t.Parallel()
@@ -834,6 +1195,320 @@ func TestWorkerClose(t *testing.T) {
 	t.Logf("Worker close test: jobs before close=%d, jobs after close=%d", initialCount, finalCount)
 }
 
+// TestWorkerDrain tests that Drain() stops scheduling and waits for in-flight jobs
+func TestWorkerDrain(t *testing.T) {
+	t.Parallel()
+
+	var started, cancelled int64
+	running := make(chan struct{}, 10)
+	release := make(chan struct{})
+	job := func(ctx context.Context) (time.Duration, error) {
+		atomic.AddInt64(&started, 1)
+		running <- struct{}{}
+		<-release
+		if ctx.Err() != nil {
+			atomic.AddInt64(&cancelled, 1)
+		}
+		return 0, nil
+	}
+
+	worker, err := NewWorker(context.Background(), job, WithConfig(WorkerConfig{
+		InFlight: 2,
+		Timeout:  time.Second,
+	}), WithMaxInFlight(2))
+	if err != nil {
+		t.Fatalf("NewWorker() failed: %v", err)
+	}
+	<-running
+	<-running
+
+	drained := make(chan error, 1)
+	go func() {
+		drained <- worker.Drain(context.Background())
+	}()
+
+	select {
+	case err := <-drained:
+		t.Fatalf("Drain() returned with jobs in flight: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	select {
+	case err := <-drained:
+		if err != nil {
+			t.Errorf("Drain() failed: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Drain() did not return after jobs finished")
+	}
+
+	if got := atomic.LoadInt64(&started); got != 2 {
+		t.Errorf("Jobs started = %d, want 2", got)
+	}
+	if got := atomic.LoadInt64(&cancelled); got != 0 {
+		t.Errorf("Jobs cancelled during drain = %d, want 0", got)
+	}
+	if _, err := worker.GetConfig(); err == nil {
+		t.Errorf("GetConfig() after Drain() succeeded, want error")
+	}
+}
+
+// TestMaxRequests tests that the worker runs exactly the max number of jobs and closes itself
+func TestMaxRequests(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var jobCount, cancelledJobs int64
+	job := func(ctx context.Context) (time.Duration, error) {
+		atomic.AddInt64(&jobCount, 1)
+		time.Sleep(5 * time.Millisecond)
+		if ctx.Err() != nil {
+			atomic.AddInt64(&cancelledJobs, 1)
+		}
+		return 5 * time.Millisecond, nil
+	}
+
+	worker, err := NewWorker(ctx, job, WithConfig(WorkerConfig{
+		InFlight: 4,
+		Timeout:  time.Second,
+	}), WithMaxInFlight(4), WithMaxRequests(25))
+	if err != nil {
+		t.Fatalf("NewWorker() failed: %v", err)
+	}
+	defer worker.Close()
+
+	select {
+	case <-worker.ctx.Done():
+	case <-ctx.Done():
+		t.Fatalf("Worker not closed after max requests, progress %+v", worker.Progress())
+	}
+	if cause := context.Cause(worker.ctx); !errors.Is(cause, workerMaxRequests) {
+		t.Errorf("Worker closed with %v, want %v", cause, workerMaxRequests)
+	}
+
+	if got := atomic.LoadInt64(&jobCount); got != 25 {
+		t.Errorf("Ran %d jobs, want 25", got)
+	}
+	if got := atomic.LoadInt64(&cancelledJobs); got != 0 {
+		t.Errorf("%d jobs cancelled, want the last jobs to finish", got)
+	}
+
+	progress := worker.Progress()
+	if progress.Requests != 25 || progress.MaxRequests != 25 || !progress.LimitReached || !progress.Finished {
+		t.Errorf("Progress() = %+v, want 25 of 25 requests, finished", progress)
+	}
+	if _, err := worker.GetConfig(); err == nil {
+		t.Errorf("GetConfig() after max requests succeeded, want error")
+	}
+}
+
+// TestMaxDuration tests that the worker stops dispatching jobs after the max duration
+func TestMaxDuration(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var jobCount int64
+	job := func(context.Context) (time.Duration, error) {
+		atomic.AddInt64(&jobCount, 1)
+		return 0, nil
+	}
+
+	worker, err := NewWorker(ctx, job, WithConfig(WorkerConfig{
+		InFlight:          1,
+		IntervalGenerator: StableIntervalGenerator,
+		Qps:               100,
+		Timeout:           time.Second,
+	}), WithMaxDuration(100*time.Millisecond))
+	if err != nil {
+		t.Fatalf("NewWorker() failed: %v", err)
+	}
+	defer worker.Close()
+
+	if progress := worker.Progress(); progress.LimitReached || progress.MaxDuration != 100*time.Millisecond {
+		t.Errorf("Progress() at start = %+v, want 100ms limit not reached", progress)
+	}
+
+	select {
+	case <-worker.ctx.Done():
+	case <-ctx.Done():
+		t.Fatalf("Worker not closed after max duration")
+	}
+	if cause := context.Cause(worker.ctx); !errors.Is(cause, workerMaxDuration) {
+		t.Errorf("Worker closed with %v, want %v", cause, workerMaxDuration)
+	}
+
+	jobs := atomic.LoadInt64(&jobCount)
+	if jobs < 3 || jobs > 11 {
+		t.Errorf("Ran %d jobs in 100ms at 100 QPS, want about 10", jobs)
+	}
+	progress := worker.Progress()
+	if !progress.Finished || progress.Requests != int(jobs) || progress.Elapsed < 100*time.Millisecond {
+		t.Errorf("Progress() = %+v, want finished after 100ms with %d requests", progress, jobs)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	if got := atomic.LoadInt64(&jobCount); got != jobs {
+		t.Errorf("Ran %d jobs after max duration, want none", got-jobs)
+	}
+}
+
+// TestWorkerDrainTimeout tests that Drain() cancels jobs still running when its context is done
+func TestWorkerDrainTimeout(t *testing.T) {
+	t.Parallel()
+
+	running := make(chan struct{}, 10)
+	finished := make(chan struct{}, 10)
+	job := func(ctx context.Context) (time.Duration, error) {
+		running <- struct{}{}
+		<-ctx.Done()
+		finished <- struct{}{}
+		return 0, ctx.Err()
+	}
+
+	worker, err := NewWorker(context.Background(), job, WithConfig(WorkerConfig{
+		InFlight: 1,
+		Timeout:  time.Minute,
+	}), WithMaxInFlight(1))
+	if err != nil {
+		t.Fatalf("NewWorker() failed: %v", err)
+	}
+	<-running
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := worker.Drain(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Drain() = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	select {
+	case <-finished:
+	case <-time.After(time.Second):
+		t.Errorf("Job was not cancelled after Drain() timed out")
+	}
+}
+
+// TestStallWatchdog tests that a worker with all tokens held by hanging jobs reports a stall
+func TestStallWatchdog(t *testing.T) {
+	t.Parallel()
+
+	release := make(chan struct{})
+	job := func(ctx context.Context) (time.Duration, error) {
+		<-release
+		return 0, nil
+	}
+
+	stalls := make(chan bool, 10)
+	worker, err := NewWorker(context.Background(), job, WithConfig(WorkerConfig{
+		InFlight: 1,
+		Timeout:  time.Minute,
+	}), WithMaxInFlight(1), WithStallWatchdog(50*time.Millisecond, func(stalled bool) {
+		stalls <- stalled
+	}))
+	if err != nil {
+		t.Fatalf("NewWorker() failed: %v", err)
+	}
+	defer worker.Close()
+
+	for _, want := range []bool{true, false} {
+		select {
+		case got := <-stalls:
+			if got != want {
+				t.Fatalf("onStall(%v), want onStall(%v)", got, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("onStall(%v) was not called", want)
+		}
+		// Free the token to end the stall
+		if want {
+			close(release)
+		}
+	}
+}
+
+// TestSharedInFlightFullPool tests that a worker waiting for a slot of a full
+// shared pool reports a stall and does not count the waiting job as dispatched
+func TestSharedInFlightFullPool(t *testing.T) {
+	t.Parallel()
+
+	pool := make(chan struct{}, 1)
+	pool <- struct{}{} // Held by another worker
+
+	var jobCount int64
+	job := func(ctx context.Context) (time.Duration, error) {
+		atomic.AddInt64(&jobCount, 1)
+		return 0, nil
+	}
+
+	stalls := make(chan bool, 10)
+	worker, err := NewWorker(context.Background(), job, WithConfig(WorkerConfig{
+		InFlight: 1,
+		Timeout:  time.Second,
+	}), WithMaxInFlight(1), WithSharedInFlight(pool), WithMaxRequests(1), WithStallWatchdog(20*time.Millisecond, func(stalled bool) {
+		stalls <- stalled
+	}))
+	if err != nil {
+		t.Fatalf("NewWorker() failed: %v", err)
+	}
+	defer worker.Close()
+
+	select {
+	case got := <-stalls:
+		if !got {
+			t.Fatalf("onStall(false), want onStall(true)")
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("onStall(true) was not called for a full shared pool")
+	}
+	if progress := worker.Progress(); progress.Requests != 0 || progress.LimitReached {
+		t.Errorf("Progress() with a full shared pool = %+v, want no requests", progress)
+	}
+
+	// Free the slot, the job is dispatched and the worker stops
+	<-pool
+	select {
+	case <-worker.finished:
+	case <-time.After(time.Second):
+		t.Fatalf("Worker not finished after the shared slot was freed, progress %+v", worker.Progress())
+	}
+	if got := atomic.LoadInt64(&jobCount); got != 1 {
+		t.Errorf("Ran %d jobs, want 1", got)
+	}
+}
+
+// TestStallWatchdogSlowQPS tests that waiting for the rate limiting timer is not a stall
+func TestStallWatchdogSlowQPS(t *testing.T) {
+	t.Parallel()
+
+	job := func(ctx context.Context) (time.Duration, error) {
+		return 0, nil
+	}
+
+	stalls := make(chan bool, 10)
+	worker, err := NewWorker(context.Background(), job, WithConfig(WorkerConfig{
+		InFlight:          1,
+		IntervalGenerator: StableIntervalGenerator,
+		Qps:               2,
+		Timeout:           time.Second,
+	}), WithMaxInFlight(1), WithStallWatchdog(20*time.Millisecond, func(stalled bool) {
+		stalls <- stalled
+	}))
+	if err != nil {
+		t.Fatalf("NewWorker() failed: %v", err)
+	}
+	defer worker.Close()
+
+	select {
+	case got := <-stalls:
+		t.Errorf("onStall(%v) called for a worker waiting for its timer", got)
+	case <-time.After(300 * time.Millisecond):
+	}
+}
+
 // TestContextCancellation tests that worker respects context cancellation
 func TestContextCancellation(t *testing.T) {
 	t.Parallel()
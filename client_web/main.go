@@ -8,11 +8,14 @@ import (
 	"log"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	pb "github.com/mhbvr/manul/proto"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
 )
 
 var (
@@ -32,15 +35,20 @@ type PageData struct {
 	Error   string
 }
 
+// pageSize is the number of cats or photos shown on a page
+const pageSize = 100
+
 type CatsPageData struct {
 	PageData
-	Cats []uint64
+	Cats          []uint64
+	NextPageToken string
 }
 
 type PhotosPageData struct {
 	PageData
-	CatID  uint64
-	Photos []uint64
+	CatID         uint64
+	Photos        []uint64
+	NextPageToken string
 }
 
 type PhotoFullViewData struct {
@@ -56,7 +64,7 @@ func NewWebServer(serverAddr string) (*WebServer, error) {
 		return nil, fmt.Errorf("failed to connect to gRPC server: %v", err)
 	}
 
-	client := pb.NewCatPhotosServiceClient(conn)
+	client := &retryingClient{pb.NewCatPhotosServiceClient(conn)}
 
 	// Load templates
 	templates, err := template.ParseGlob("templates/*.html")
@@ -89,10 +97,13 @@ func (ws *WebServer) handleHome(w http.ResponseWriter, r *http.Request) {
 }
 
 func (ws *WebServer) handleCats(w http.ResponseWriter, r *http.Request) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 	defer cancel()
 
-	resp, err := ws.grpcClient.ListCats(ctx, &pb.ListCatsRequest{})
+	resp, err := ws.grpcClient.ListCats(ctx, &pb.ListCatsRequest{
+		PageSize:  pageSize,
+		PageToken: r.URL.Query().Get("page_token"),
+	})
 	if err != nil {
 		data := CatsPageData{
 			PageData: PageData{
@@ -108,7 +119,8 @@ func (ws *WebServer) handleCats(w http.ResponseWriter, r *http.Request) {
 		PageData: PageData{
 			Title: "All Cats",
 		},
-		Cats: resp.CatIds,
+		Cats:          resp.CatIds,
+		NextPageToken: resp.NextPageToken,
 	}
 
 	if err := ws.templates.ExecuteTemplate(w, "cats.html", data); err != nil {
@@ -130,18 +142,25 @@ func (ws *WebServer) handlePhotos(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 	defer cancel()
 
-	resp, err := ws.grpcClient.ListPhotos(ctx, &pb.ListPhotosRequest{CatId: catID})
+	resp, err := ws.grpcClient.ListPhotos(ctx, &pb.ListPhotosRequest{
+		CatId:     catID,
+		PageSize:  pageSize,
+		PageToken: r.URL.Query().Get("page_token"),
+	})
 	if err != nil {
 		data := PhotosPageData{
 			PageData: PageData{
 				Title: fmt.Sprintf("Photos for Cat %d", catID),
-				Error: fmt.Sprintf("Failed to get photos: %v", err),
 			},
 			CatID: catID,
 		}
+		// A cat without photos is shown as an empty page, not as an error
+		if status.Code(err) != codes.NotFound {
+			data.Error = fmt.Sprintf("Failed to get photos: %v", err)
+		}
 		ws.templates.ExecuteTemplate(w, "photos.html", data)
 		return
 	}
@@ -150,8 +169,9 @@ func (ws *WebServer) handlePhotos(w http.ResponseWriter, r *http.Request) {
 		PageData: PageData{
 			Title: fmt.Sprintf("Photos for Cat %d", catID),
 		},
-		CatID:  catID,
-		Photos: resp.PhotoIds,
+		CatID:         catID,
+		Photos:        resp.PhotoIds,
+		NextPageToken: resp.NextPageToken,
 	}
 
 	if err := ws.templates.ExecuteTemplate(w, "photos.html", data); err != nil {
@@ -160,10 +180,32 @@ func (ws *WebServer) handlePhotos(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// acceptFormats returns the output formats of the image types in an HTTP
+// Accept header, ORIGINAL for wildcards. Types with q=0 are not accepted.
+func acceptFormats(header string) []pb.OutputFormat {
+	var res []pb.OutputFormat
+	for _, part := range strings.Split(header, ",") {
+		mediaType, params, _ := strings.Cut(part, ";")
+		if strings.ReplaceAll(params, " ", "") == "q=0" {
+			continue
+		}
+		switch strings.TrimSpace(mediaType) {
+		case "image/jpeg":
+			res = append(res, pb.OutputFormat_JPEG)
+		case "image/png":
+			res = append(res, pb.OutputFormat_PNG)
+		case "image/*", "*/*":
+			res = append(res, pb.OutputFormat_ORIGINAL)
+		}
+	}
+	return res
+}
+
 func (ws *WebServer) handlePhoto(w http.ResponseWriter, r *http.Request) {
 	catIDStr := r.URL.Query().Get("cat_id")
 	photoIDStr := r.URL.Query().Get("photo_id")
 	displayMode := r.URL.Query().Get("mode") // "thumb", "full", or empty (download)
+	formatStr := r.URL.Query().Get("format") // "jpeg", "png", or empty (stored format)
 
 	if catIDStr == "" || photoIDStr == "" {
 		http.Error(w, "Missing cat_id or photo_id parameter", http.StatusBadRequest)
@@ -182,26 +224,68 @@ func (ws *WebServer) handlePhoto(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	format := pb.OutputFormat_ORIGINAL
+	var accepted []pb.OutputFormat
+	if formatStr != "" {
+		value, ok := pb.OutputFormat_value[strings.ToUpper(formatStr)]
+		if !ok {
+			http.Error(w, "Invalid format parameter", http.StatusBadRequest)
+			return
+		}
+		format = pb.OutputFormat(value)
+	} else {
+		// Let the server pick a format the browser accepts
+		accepted = acceptFormats(r.Header.Get("Accept"))
+		w.Header().Set("Vary", "Accept")
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 	defer cancel()
 
 	resp, err := ws.grpcClient.GetPhoto(ctx, &pb.GetPhotoRequest{
-		CatId:   catID,
-		PhotoId: photoID,
+		CatId:        catID,
+		PhotoId:      photoID,
+		IfNoneMatch:   strings.Trim(r.Header.Get("If-None-Match"), `"`),
+		OutputFormat:  format,
+		AcceptFormats: accepted,
 	})
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to get photo: %v", err), http.StatusNotFound)
+		// Nothing is written yet, but the photo is an image or a download,
+		// so report the error with a status code instead of a page
+		code := http.StatusNotFound
+		switch status.Code(err) {
+		case codes.Unavailable:
+			code = http.StatusServiceUnavailable
+			w.Header().Set("Retry-After", "1")
+		case codes.Unimplemented:
+			code = http.StatusNotImplemented
+		}
+		http.Error(w, fmt.Sprintf("Failed to get photo: %v", err), code)
 		return
 	}
 
+	if resp.ContentHash != "" {
+		w.Header().Set("ETag", `"`+resp.ContentHash+`"`)
+	}
+	if resp.NotModified {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	contentType := resp.ContentType
+	if contentType == "" {
+		// Server without format support, photos used to be JPEG only
+		contentType = "image/jpeg"
+	}
+
 	switch displayMode {
 	case "thumb":
 		// Display as thumbnail (inline image)
-		w.Header().Set("Content-Type", "image/jpeg")
+		w.Header().Set("Content-Type", contentType)
 		w.Header().Set("Cache-Control", "public, max-age=3600")
 	case "full":
 		// Display full-size image inline
-		w.Header().Set("Content-Type", "image/jpeg")
+		w.Header().Set("Content-Type", contentType)
 		w.Header().Set("Cache-Control", "public, max-age=3600")
 	default:
 		// Download mode (original behavior)
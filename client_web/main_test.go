@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	pb "github.com/mhbvr/manul/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// emptyClient answers like a server with an empty database
+type emptyClient struct {
+	pb.CatPhotosServiceClient
+}
+
+func (c emptyClient) ListCats(ctx context.Context, req *pb.ListCatsRequest, opts ...grpc.CallOption) (*pb.ListCatsResponse, error) {
+	return &pb.ListCatsResponse{}, nil
+}
+
+func (c emptyClient) ListPhotos(ctx context.Context, req *pb.ListPhotosRequest, opts ...grpc.CallOption) (*pb.ListPhotosResponse, error) {
+	return nil, status.Errorf(codes.NotFound, "cat with ID %d not found", req.CatId)
+}
+
+func TestHandlers_EmptyDatabase(t *testing.T) {
+	templates, err := template.ParseGlob("templates/*.html")
+	if err != nil {
+		t.Fatalf("Failed to parse templates: %v", err)
+	}
+	ws := &WebServer{grpcClient: emptyClient{}, templates: templates}
+
+	tests := []struct {
+		path     string
+		handler  http.HandlerFunc
+		wantBody string
+	}{
+		{"/cats", ws.handleCats, "No cats found in the database"},
+		{"/photos?cat_id=5", ws.handlePhotos, "No photos found for Cat 5"},
+	}
+
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		tt.handler(rec, httptest.NewRequest("GET", tt.path, nil))
+		body := rec.Body.String()
+		if !strings.Contains(body, tt.wantBody) {
+			t.Errorf("GET %s body does not contain %q", tt.path, tt.wantBody)
+		}
+		if strings.Contains(body, `class="error"`) {
+			t.Errorf("GET %s shows an error for an empty database", tt.path)
+		}
+	}
+}
+
+func TestAcceptFormats(t *testing.T) {
+	tests := []struct {
+		header string
+		want   []pb.OutputFormat
+	}{
+		{"", nil},
+		{"image/avif,image/webp,image/apng,image/svg+xml,image/*,*/*;q=0.8",
+			[]pb.OutputFormat{pb.OutputFormat_ORIGINAL, pb.OutputFormat_ORIGINAL}},
+		{"image/png, image/jpeg;q=0.9", []pb.OutputFormat{pb.OutputFormat_PNG, pb.OutputFormat_JPEG}},
+		{"image/png;q=0, image/jpeg", []pb.OutputFormat{pb.OutputFormat_JPEG}},
+		{"text/html", nil},
+	}
+
+	for _, tt := range tests {
+		if got := acceptFormats(tt.header); !slices.Equal(got, tt.want) {
+			t.Errorf("acceptFormats(%q) = %v, want %v", tt.header, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	pb "github.com/mhbvr/manul/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// maxRetries is the number of retries of Unavailable errors
+	maxRetries = 2
+	// retryBackoff is the delay before the first retry, doubled for each next one
+	retryBackoff = 100 * time.Millisecond
+)
+
+// retryingClient retries ListCats, ListPhotos and GetPhoto calls failed with
+// Unavailable, so server restarts during deploys are not shown to users.
+// Other methods are passed through unchanged.
+type retryingClient struct {
+	pb.CatPhotosServiceClient
+}
+
+// retry calls f until it succeeds, fails with an error other than
+// Unavailable, retries are exhausted or ctx is done
+func retry(ctx context.Context, method string, f func() error) error {
+	backoff := retryBackoff
+	for attempt := 0; ; attempt++ {
+		err := f()
+		if status.Code(err) != codes.Unavailable || attempt == maxRetries {
+			return err
+		}
+
+		log.Printf("%s failed, retrying in %v: %v", method, backoff, err)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return err
+		}
+		backoff *= 2
+	}
+}
+
+func (c *retryingClient) ListCats(ctx context.Context, req *pb.ListCatsRequest, opts ...grpc.CallOption) (*pb.ListCatsResponse, error) {
+	var resp *pb.ListCatsResponse
+	err := retry(ctx, "ListCats", func() error {
+		var err error
+		resp, err = c.CatPhotosServiceClient.ListCats(ctx, req, opts...)
+		return err
+	})
+	return resp, err
+}
+
+func (c *retryingClient) ListPhotos(ctx context.Context, req *pb.ListPhotosRequest, opts ...grpc.CallOption) (*pb.ListPhotosResponse, error) {
+	var resp *pb.ListPhotosResponse
+	err := retry(ctx, "ListPhotos", func() error {
+		var err error
+		resp, err = c.CatPhotosServiceClient.ListPhotos(ctx, req, opts...)
+		return err
+	})
+	return resp, err
+}
+
+func (c *retryingClient) GetPhoto(ctx context.Context, req *pb.GetPhotoRequest, opts ...grpc.CallOption) (*pb.GetPhotoResponse, error) {
+	var resp *pb.GetPhotoResponse
+	err := retry(ctx, "GetPhoto", func() error {
+		var err error
+		resp, err = c.CatPhotosServiceClient.GetPhoto(ctx, req, opts...)
+		return err
+	})
+	return resp, err
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	pb "github.com/mhbvr/manul/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// flakyClient fails ListCats with the given codes, then succeeds
+type flakyClient struct {
+	pb.CatPhotosServiceClient
+	failures []codes.Code
+	calls    int
+}
+
+func (c *flakyClient) ListCats(ctx context.Context, req *pb.ListCatsRequest, opts ...grpc.CallOption) (*pb.ListCatsResponse, error) {
+	c.calls++
+	if c.calls <= len(c.failures) {
+		return nil, status.Error(c.failures[c.calls-1], "failed")
+	}
+	return &pb.ListCatsResponse{CatIds: []uint64{1}}, nil
+}
+
+func TestRetryingClient(t *testing.T) {
+	tests := []struct {
+		name      string
+		failures  []codes.Code
+		wantCode  codes.Code
+		wantCalls int
+	}{
+		{"success", nil, codes.OK, 1},
+		{"recovers", []codes.Code{codes.Unavailable, codes.Unavailable}, codes.OK, 3},
+		{"retries exhausted", []codes.Code{codes.Unavailable, codes.Unavailable, codes.Unavailable}, codes.Unavailable, 3},
+		{"not retried", []codes.Code{codes.NotFound}, codes.NotFound, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flaky := &flakyClient{failures: tt.failures}
+			client := &retryingClient{flaky}
+
+			_, err := client.ListCats(context.Background(), &pb.ListCatsRequest{})
+			if status.Code(err) != tt.wantCode {
+				t.Errorf("ListCats() error = %v, want code %v", err, tt.wantCode)
+			}
+			if flaky.calls != tt.wantCalls {
+				t.Errorf("ListCats() made %d calls, want %d", flaky.calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestRetryingClient_ContextDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	flaky := &flakyClient{failures: []codes.Code{codes.Unavailable, codes.Unavailable}}
+	client := &retryingClient{flaky}
+
+	if _, err := client.ListCats(ctx, &pb.ListCatsRequest{}); status.Code(err) != codes.Unavailable {
+		t.Errorf("ListCats() error = %v, want Unavailable", err)
+	}
+	if flaky.calls != 1 {
+		t.Errorf("ListCats() made %d calls with a done context, want 1", flaky.calls)
+	}
+}
@@ -1,5 +1,20 @@
 package manul
 
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand/v2"
+	"time"
+)
+
+// ErrDatabaseLocked is returned when a database cannot be opened because
+// another process holds its lock
+var ErrDatabaseLocked = errors.New("database locked by another process")
+
+// ErrNoPhotos is returned when a random photo is requested from an empty database
+var ErrNoPhotos = errors.New("database has no photos")
+
 // DBWriter provides an abstract interface for writing cat photo databases.
 // Different implementations can store data in different formats (file tree vs single bbolt file).
 type DBWriter interface {
@@ -9,10 +24,67 @@ type DBWriter interface {
 	// AddPhotosBatch adds multiple photos in a single transaction for better performance
 	AddPhotosBatch(photos []PhotoItem) error
 	
+	// DeletePhotos deletes photos in a single transaction where the backend supports it.
+	// The result reports for each key whether the photo existed and was deleted.
+	DeletePhotos(keys []PhotoKey) ([]bool, error)
+	
+	// DeleteCat deletes all photos of a cat and returns the number of deleted photos
+	DeleteCat(catID uint64) (int, error)
+	
 	// Close closes the database and releases resources
 	Close() error
 }
 
+// BatchWriter is implemented by writers which stream photos into a single
+// transaction, so callers do not have to hold a whole batch in a slice
+type BatchWriter interface {
+	// NewBatch starts a transaction, which may block other writers until it is finished
+	NewBatch() (Batch, error)
+}
+
+// Batch adds photos in a single transaction. It must be finished with
+// Commit or Abort, and must not be used after.
+type Batch interface {
+	// Add adds a photo to the transaction
+	Add(photo PhotoItem) error
+	
+	// Commit stores the added photos
+	Commit() error
+	
+	// Abort discards the added photos
+	Abort()
+}
+
+// NewBatch starts a batch of w. Writers which are not BatchWriters get a
+// batch holding the added photos until Commit writes them with AddPhotosBatch.
+func NewBatch(w DBWriter) (Batch, error) {
+	if bw, ok := w.(BatchWriter); ok {
+		return bw.NewBatch()
+	}
+	return &sliceBatch{writer: w}, nil
+}
+
+// sliceBatch is the batch of writers without native batches
+type sliceBatch struct {
+	writer DBWriter
+	photos []PhotoItem
+}
+
+func (b *sliceBatch) Add(photo PhotoItem) error {
+	b.photos = append(b.photos, photo)
+	return nil
+}
+
+func (b *sliceBatch) Commit() error {
+	photos := b.photos
+	b.photos = nil
+	return b.writer.AddPhotosBatch(photos)
+}
+
+func (b *sliceBatch) Abort() {
+	b.photos = nil
+}
+
 // DBReader provides an abstract interface for reading cat photo databases.
 // Different implementations can read data from different formats (file tree vs single bbolt file).
 type DBReader interface {
@@ -22,17 +94,135 @@ type DBReader interface {
 	// GetPhotoIDs returns all photo IDs for a specific cat
 	GetPhotoIDs(catID uint64) ([]uint64, error)
 	
+	// ListCatIDs returns up to limit cat IDs >= startCatID in ascending order
+	ListCatIDs(startCatID uint64, limit int) ([]uint64, error)
+	
+	// ListPhotoIDs returns up to limit photo IDs >= startPhotoID of a cat in ascending order
+	ListPhotoIDs(catID, startPhotoID uint64, limit int) ([]uint64, error)
+	
+	// CountPhotos returns the number of photos of a specific cat
+	CountPhotos(catID uint64) (uint64, error)
+	
+	// CountAllPhotos returns the number of photos of all cats
+	CountAllPhotos() (uint64, error)
+	
+	// ForEachPhoto calls fn for every photo in ascending cat ID, photo ID order, iterating
+	// the database instead of loading all keys first. An error returned by fn stops the
+	// iteration and is returned. fn must not write to the database.
+	ForEachPhoto(fn func(catID, photoID uint64) error) error
+	
 	// GetPhotoData retrieves photo binary data by cat ID and photo ID
 	GetPhotoData(catID, photoID uint64) ([]byte, error)
 	
+	// GetPhotoFormat returns the image format name of a photo (see PhotoFormat).
+	// Photos stored before format tags were added are detected from their data.
+	GetPhotoFormat(catID, photoID uint64) (string, error)
+	
+	// GetPhotoMeta returns the metadata stored at ingest (format, checksum, size, dimensions
+	// and creation time) without reading the photo data. For photos stored before that it is
+	// computed from the photo data, and stored if the database is writable.
+	GetPhotoMeta(catID, photoID uint64) (PhotoMeta, error)
+	
+	// GetPhotoInfo returns the metadata stored at ingest without reading the photo data
+	// or writing to the database. Fields not stored for older photos are zero.
+	GetPhotoInfo(catID, photoID uint64) (PhotoMeta, error)
+	
+	// PhotoExists reports whether a photo is stored, reading only its metadata.
+	// A missing photo is not an error.
+	PhotoExists(catID, photoID uint64) (bool, error)
+	
 	// Close closes the database and releases resources
 	Close() error
 }
 
+// ContextReader is implemented by readers whose photo data reads can be
+// canceled, e.g. reads over the network
+type ContextReader interface {
+	// GetPhotoDataContext is GetPhotoData giving up when ctx is done
+	GetPhotoDataContext(ctx context.Context, catID, photoID uint64) ([]byte, error)
+}
+
+// GetPhotoDataContext reads photo data from r, giving up when ctx is done
+// if r is a ContextReader
+func GetPhotoDataContext(ctx context.Context, r DBReader, catID, photoID uint64) ([]byte, error) {
+	if cr, ok := r.(ContextReader); ok {
+		return cr.GetPhotoDataContext(ctx, catID, photoID)
+	}
+	return r.GetPhotoData(catID, photoID)
+}
+
+// RandomReader is implemented by readers which pick a random photo by
+// positioning a cursor, without iterating all keys
+type RandomReader interface {
+	// GetRandomPhoto returns the key of a random photo, ErrNoPhotos if there is none
+	GetRandomPhoto() (catID, photoID uint64, err error)
+}
+
+// errStopIteration stops ForEachPhoto once the photo is found
+var errStopIteration = errors.New("stop iteration")
+
+// GetRandomPhoto returns the key of a random photo of r, ErrNoPhotos if
+// there is none. Readers which are not RandomReaders are iterated up to a
+// uniformly random photo, which takes time linear in the number of photos.
+func GetRandomPhoto(r DBReader) (catID, photoID uint64, err error) {
+	if rr, ok := r.(RandomReader); ok {
+		return rr.GetRandomPhoto()
+	}
+
+	count, err := r.CountAllPhotos()
+	if err != nil {
+		return 0, 0, err
+	}
+	if count == 0 {
+		return 0, 0, ErrNoPhotos
+	}
+
+	// Photos deleted since counting may leave fewer, the last one is taken then
+	skip := rand.Uint64N(count)
+	found := false
+	err = r.ForEachPhoto(func(c, p uint64) error {
+		catID, photoID, found = c, p, true
+		if skip == 0 {
+			return errStopIteration
+		}
+		skip--
+		return nil
+	})
+	if err != nil && !errors.Is(err, errStopIteration) {
+		return 0, 0, err
+	}
+	if !found {
+		return 0, 0, ErrNoPhotos
+	}
+	return catID, photoID, nil
+}
+
+// RandomID returns a uniformly random ID between first and last inclusive,
+// for RandomReaders sampling the ID range of their keys
+func RandomID(first, last uint64) uint64 {
+	if first == 0 && last == math.MaxUint64 {
+		return rand.Uint64()
+	}
+	return first + rand.Uint64N(last-first+1)
+}
+
+// DBReadWriter is a database opened for both reading and writing
+type DBReadWriter interface {
+	DBReader
+	DBWriter
+}
+
+// PhotoKey identifies a photo
+type PhotoKey struct {
+	CatID   uint64
+	PhotoID uint64
+}
+
 // PhotoItem represents a photo with its metadata and binary data
 type PhotoItem struct {
 	CatID     uint64
 	PhotoID   uint64
 	FilePath  string
 	PhotoData []byte
+	CreatedAt time.Time // Stored in PhotoMeta, zero if unknown
 }
\ No newline at end of file
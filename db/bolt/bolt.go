@@ -1,10 +1,14 @@
 package bolt
 
 import (
+	"bytes"
 	"encoding/binary"
 	"fmt"
+	"math"
+	"time"
 
 	"github.com/mhbvr/manul"
+	"github.com/mhbvr/manul/db/internal/boltfile"
 	bolt "go.etcd.io/bbolt"
 )
 
@@ -13,16 +17,39 @@ const (
 	photoBucket = "photos"
 )
 
+// Option configures opening of a BoltDB
+type Option func(*options)
+
+type options struct {
+	timeout time.Duration
+}
+
+// WithTimeout sets how long to wait for the database file lock.
+// Zero (the default) waits forever.
+func WithTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.timeout = timeout
+	}
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
 // BoltDB implements DBWriter interface using single bbolt file for everything
 type BoltDB struct {
 	db *bolt.DB
 }
 
 // New creates a new BoltDB
-func New(dbPath string) (*BoltDB, error) {
-	db, err := bolt.Open(dbPath, 0600, &bolt.Options{})
+func New(dbPath string, opts ...Option) (*BoltDB, error) {
+	db, err := boltfile.Open(dbPath, 0600, false, newOptions(opts).timeout)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open bbolt database: %w", err)
+		return nil, err
 	}
 
 	err = db.Update(func(tx *bolt.Tx) error {
@@ -55,12 +82,19 @@ func (w *BoltDB) generateKey(catID, photoID uint64) []byte {
 	return key
 }
 
+// catPrefix returns the key prefix of all photos of a cat
+func (w *BoltDB) catPrefix(catID uint64) []byte {
+	prefix := make([]byte, 8)
+	binary.BigEndian.PutUint64(prefix, catID)
+	return prefix
+}
+
 func (w *BoltDB) AddPhoto(catID, photoID uint64, photoData []byte) error {
 	key := w.generateKey(catID, photoID)
 
 	return w.db.Update(func(tx *bolt.Tx) error {
 		metaBucket := tx.Bucket([]byte(metaBucket))
-		if err := metaBucket.Put(key, []byte{}); err != nil {
+		if err := metaBucket.Put(key, manul.NewMetaValue(photoData, time.Time{})); err != nil {
 			return fmt.Errorf("failed to update meta bucket: %w", err)
 		}
 
@@ -74,24 +108,128 @@ func (w *BoltDB) AddPhoto(catID, photoID uint64, photoData []byte) error {
 }
 
 func (w *BoltDB) AddPhotosBatch(photos []manul.PhotoItem) error {
-	return w.db.Update(func(tx *bolt.Tx) error {
+	batch, err := w.NewBatch()
+	if err != nil {
+		return err
+	}
+	for _, photo := range photos {
+		if err := batch.Add(photo); err != nil {
+			batch.Abort()
+			return err
+		}
+	}
+	return batch.Commit()
+}
+
+// boltBatch adds photos in a single write transaction. bbolt references the
+// added photo data until the transaction is committed.
+type boltBatch struct {
+	w           *BoltDB
+	tx          *bolt.Tx
+	metaBucket  *bolt.Bucket
+	photoBucket *bolt.Bucket
+}
+
+// NewBatch starts a write transaction, see manul.BatchWriter
+func (w *BoltDB) NewBatch() (manul.Batch, error) {
+	tx, err := w.db.Begin(true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	return &boltBatch{
+		w:           w,
+		tx:          tx,
+		metaBucket:  tx.Bucket([]byte(metaBucket)),
+		photoBucket: tx.Bucket([]byte(photoBucket)),
+	}, nil
+}
+
+func (b *boltBatch) Add(photo manul.PhotoItem) error {
+	key := b.w.generateKey(photo.CatID, photo.PhotoID)
+
+	if err := b.metaBucket.Put(key, manul.NewMetaValue(photo.PhotoData, photo.CreatedAt)); err != nil {
+		return fmt.Errorf("failed to update meta bucket for cat_id=%d, photo_id=%d: %w", photo.CatID, photo.PhotoID, err)
+	}
+
+	if err := b.photoBucket.Put(key, photo.PhotoData); err != nil {
+		return fmt.Errorf("failed to update photo bucket for cat_id=%d, photo_id=%d: %w", photo.CatID, photo.PhotoID, err)
+	}
+
+	return nil
+}
+
+func (b *boltBatch) Commit() error {
+	if err := b.tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+func (b *boltBatch) Abort() {
+	b.tx.Rollback()
+}
+
+func (w *BoltDB) DeletePhotos(keys []manul.PhotoKey) ([]bool, error) {
+	deleted := make([]bool, len(keys))
+
+	err := w.db.Update(func(tx *bolt.Tx) error {
 		metaBucket := tx.Bucket([]byte(metaBucket))
 		photoBucket := tx.Bucket([]byte(photoBucket))
 
-		for _, photo := range photos {
-			key := w.generateKey(photo.CatID, photo.PhotoID)
-
-			if err := metaBucket.Put(key, []byte{}); err != nil {
-				return fmt.Errorf("failed to update meta bucket for cat_id=%d, photo_id=%d: %w", photo.CatID, photo.PhotoID, err)
+		for i, photoKey := range keys {
+			key := w.generateKey(photoKey.CatID, photoKey.PhotoID)
+			if metaBucket.Get(key) == nil {
+				continue
 			}
 
-			if err := photoBucket.Put(key, photo.PhotoData); err != nil {
-				return fmt.Errorf("failed to update photo bucket for cat_id=%d, photo_id=%d: %w", photo.CatID, photo.PhotoID, err)
+			if err := metaBucket.Delete(key); err != nil {
+				return fmt.Errorf("failed to delete meta for cat_id=%d, photo_id=%d: %w", photoKey.CatID, photoKey.PhotoID, err)
+			}
+			if err := photoBucket.Delete(key); err != nil {
+				return fmt.Errorf("failed to delete photo for cat_id=%d, photo_id=%d: %w", photoKey.CatID, photoKey.PhotoID, err)
 			}
+			deleted[i] = true
+		}
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+	return deleted, nil
+}
+
+func (w *BoltDB) DeleteCat(catID uint64) (int, error) {
+	prefix := w.catPrefix(catID)
+	count := 0
+
+	err := w.db.Update(func(tx *bolt.Tx) error {
+		metaBucket := tx.Bucket([]byte(metaBucket))
+		photoBucket := tx.Bucket([]byte(photoBucket))
+
+		// Collect keys first, deleting while iterating skips keys
+		var keys [][]byte
+		cursor := metaBucket.Cursor()
+		for key, _ := cursor.Seek(prefix); key != nil && bytes.HasPrefix(key, prefix); key, _ = cursor.Next() {
+			keys = append(keys, append([]byte{}, key...))
 		}
 
+		for _, key := range keys {
+			if err := metaBucket.Delete(key); err != nil {
+				return fmt.Errorf("failed to update meta bucket: %w", err)
+			}
+			if err := photoBucket.Delete(key); err != nil {
+				return fmt.Errorf("failed to update photo bucket: %w", err)
+			}
+		}
+		count = len(keys)
 		return nil
 	})
+
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
 }
 
 func (w *BoltDB) parseKey(key []byte) (catID, photoID uint64) {
@@ -141,12 +279,42 @@ func (w *BoltDB) GetPhotoIDs(catID uint64) ([]uint64, error) {
 			return fmt.Errorf("bucket %s not found", metaBucket)
 		}
 
+		// Keys start with the big-endian cat ID, so photos of a cat are adjacent
+		prefix := w.catPrefix(catID)
 		cursor := bucket.Cursor()
-		for key, _ := cursor.First(); key != nil; key, _ = cursor.Next() {
-			keyCatID, photoID := w.parseKey(key)
-			if keyCatID == catID {
-				photoIds = append(photoIds, photoID)
+		for key, _ := cursor.Seek(prefix); key != nil && bytes.HasPrefix(key, prefix); key, _ = cursor.Next() {
+			_, photoID := w.parseKey(key)
+			photoIds = append(photoIds, photoID)
+		}
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return photoIds, nil
+}
+
+func (w *BoltDB) ListCatIDs(startCatID uint64, limit int) ([]uint64, error) {
+	var catIds []uint64
+
+	err := w.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(metaBucket))
+		if bucket == nil {
+			return fmt.Errorf("bucket %s not found", metaBucket)
+		}
+
+		cursor := bucket.Cursor()
+		key, _ := cursor.Seek(w.catPrefix(startCatID))
+		for key != nil && len(catIds) < limit {
+			catID, _ := w.parseKey(key)
+			catIds = append(catIds, catID)
+			if catID == math.MaxUint64 {
+				break
 			}
+			// Skip the other photos of the cat
+			key, _ = cursor.Seek(w.catPrefix(catID + 1))
 		}
 		return nil
 	})
@@ -154,10 +322,129 @@ func (w *BoltDB) GetPhotoIDs(catID uint64) ([]uint64, error) {
 	if err != nil {
 		return nil, err
 	}
+	return catIds, nil
+}
+
+func (w *BoltDB) ListPhotoIDs(catID, startPhotoID uint64, limit int) ([]uint64, error) {
+	var photoIds []uint64
 
+	err := w.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(metaBucket))
+		if bucket == nil {
+			return fmt.Errorf("bucket %s not found", metaBucket)
+		}
+
+		prefix := w.catPrefix(catID)
+		cursor := bucket.Cursor()
+		for key, _ := cursor.Seek(w.generateKey(catID, startPhotoID)); key != nil && bytes.HasPrefix(key, prefix) && len(photoIds) < limit; key, _ = cursor.Next() {
+			_, photoID := w.parseKey(key)
+			photoIds = append(photoIds, photoID)
+		}
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
 	return photoIds, nil
 }
 
+func (w *BoltDB) CountPhotos(catID uint64) (uint64, error) {
+	var count uint64
+
+	err := w.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(metaBucket))
+		if bucket == nil {
+			return fmt.Errorf("bucket %s not found", metaBucket)
+		}
+
+		prefix := w.catPrefix(catID)
+		cursor := bucket.Cursor()
+		for key, _ := cursor.Seek(prefix); key != nil && bytes.HasPrefix(key, prefix); key, _ = cursor.Next() {
+			count++
+		}
+		return nil
+	})
+
+	return count, err
+}
+
+func (w *BoltDB) CountAllPhotos() (uint64, error) {
+	var count uint64
+
+	err := w.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(metaBucket))
+		if bucket == nil {
+			return fmt.Errorf("bucket %s not found", metaBucket)
+		}
+
+		cursor := bucket.Cursor()
+		for key, _ := cursor.First(); key != nil; key, _ = cursor.Next() {
+			count++
+		}
+		return nil
+	})
+
+	return count, err
+}
+
+// ForEachPhoto iterates the meta bucket in a single read transaction
+func (w *BoltDB) ForEachPhoto(fn func(catID, photoID uint64) error) error {
+	return w.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(metaBucket))
+		if bucket == nil {
+			return fmt.Errorf("bucket %s not found", metaBucket)
+		}
+
+		cursor := bucket.Cursor()
+		for key, _ := cursor.First(); key != nil; key, _ = cursor.Next() {
+			if err := fn(w.parseKey(key)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// GetRandomPhoto picks a random cat ID between the first and last ones, and
+// a random photo ID of the cat at or after it, seeking a cursor instead of
+// reading all keys. Photos after gaps in the IDs are picked more often.
+func (w *BoltDB) GetRandomPhoto() (catID, photoID uint64, err error) {
+	err = w.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(metaBucket))
+		if bucket == nil {
+			return fmt.Errorf("bucket %s not found", metaBucket)
+		}
+
+		cursor := bucket.Cursor()
+		first, _ := cursor.First()
+		if first == nil {
+			return manul.ErrNoPhotos
+		}
+		last, _ := cursor.Last()
+		firstCatID, _ := w.parseKey(first)
+		lastCatID, _ := w.parseKey(last)
+
+		// A cat is found at or before the last cat ID
+		key, _ := cursor.Seek(w.catPrefix(manul.RandomID(firstCatID, lastCatID)))
+		var firstPhotoID uint64
+		catID, firstPhotoID = w.parseKey(key)
+
+		// The last photo of the cat is the key before the next cat
+		if catID < math.MaxUint64 {
+			if next, _ := cursor.Seek(w.catPrefix(catID + 1)); next != nil {
+				last, _ = cursor.Prev()
+			}
+		}
+		_, lastPhotoID := w.parseKey(last)
+
+		key, _ = cursor.Seek(w.generateKey(catID, manul.RandomID(firstPhotoID, lastPhotoID)))
+		catID, photoID = w.parseKey(key)
+		return nil
+	})
+	return catID, photoID, err
+}
+
 func (w *BoltDB) GetPhotoData(catID, photoID uint64) ([]byte, error) {
 	key := w.generateKey(catID, photoID)
 	var photoData []byte
@@ -185,11 +472,94 @@ func (w *BoltDB) GetPhotoData(catID, photoID uint64) ([]byte, error) {
 	return photoData, nil
 }
 
+func (w *BoltDB) GetPhotoFormat(catID, photoID uint64) (string, error) {
+	meta, err := w.GetPhotoMeta(catID, photoID)
+	if err != nil {
+		return "", err
+	}
+	return meta.Format.String(), nil
+}
+
+func (w *BoltDB) GetPhotoMeta(catID, photoID uint64) (manul.PhotoMeta, error) {
+	value, err := w.metaValue(catID, photoID)
+	if err != nil {
+		return manul.PhotoMeta{}, err
+	}
+
+	return manul.ResolveMeta(value, func() ([]byte, error) {
+		return w.GetPhotoData(catID, photoID)
+	}, w.storeMeta(w.generateKey(catID, photoID), value))
+}
+
+func (w *BoltDB) GetPhotoInfo(catID, photoID uint64) (manul.PhotoMeta, error) {
+	value, err := w.metaValue(catID, photoID)
+	if err != nil {
+		return manul.PhotoMeta{}, err
+	}
+	return manul.StoredMeta(value), nil
+}
+
+// metaValue returns a copy of the meta value stored for a photo
+func (w *BoltDB) metaValue(catID, photoID uint64) ([]byte, error) {
+	key := w.generateKey(catID, photoID)
+	var value []byte
+
+	err := w.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(metaBucket))
+		if bucket == nil {
+			return fmt.Errorf("bucket %s not found", metaBucket)
+		}
+
+		v := bucket.Get(key)
+		if v == nil {
+			return fmt.Errorf("photo with cat_id=%d, photo_id=%d not found in database", catID, photoID)
+		}
+		value = append([]byte{}, v...)
+		return nil
+	})
+
+	return value, err
+}
+
+// storeMeta returns the function storing a recomputed meta value of key,
+// nil if the database is read-only. The value is stored only if key still
+// holds old, so a photo deleted or replaced meanwhile gets no meta back.
+func (w *BoltDB) storeMeta(key, old []byte) func([]byte) error {
+	if w.db.IsReadOnly() {
+		return nil
+	}
+	return func(value []byte) error {
+		return w.db.Update(func(tx *bolt.Tx) error {
+			bucket := tx.Bucket([]byte(metaBucket))
+			if !bytes.Equal(bucket.Get(key), old) {
+				return nil
+			}
+			return bucket.Put(key, value)
+		})
+	}
+}
+
+func (w *BoltDB) PhotoExists(catID, photoID uint64) (bool, error) {
+	key := w.generateKey(catID, photoID)
+	exists := false
+
+	err := w.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(metaBucket))
+		if bucket == nil {
+			return fmt.Errorf("bucket %s not found", metaBucket)
+		}
+		exists = bucket.Get(key) != nil
+		return nil
+	})
+
+	return exists, err
+}
+
 // NewReader creates a new BoltDB for reading (read-only mode)
-func NewReader(dbPath string) (*BoltDB, error) {
-	db, err := bolt.Open(dbPath, 0600, &bolt.Options{ReadOnly: true})
+func NewReader(dbPath string, opts ...Option) (*BoltDB, error) {
+	db, err := boltfile.Open(dbPath, 0600, true, newOptions(opts).timeout)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open bbolt database: %w", err)
+		return nil, err
 	}
 
 	return &BoltDB{
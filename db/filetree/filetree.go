@@ -1,14 +1,21 @@
 package filetree
 
 import (
+	"bytes"
 	"crypto/sha256"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
+	"math"
 	"os"
 	"path/filepath"
+	"syscall"
+	"time"
 
 	"github.com/mhbvr/manul"
+	"github.com/mhbvr/manul/db/internal/boltfile"
 	"github.com/ncw/directio"
 	bolt "go.etcd.io/bbolt"
 )
@@ -19,15 +26,126 @@ const (
 	dataDir    = "data"
 )
 
+// Option configures opening of a FileTreeDB
+type Option func(*options)
+
+type options struct {
+	timeout time.Duration
+	ioMode  IOMode
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{ioMode: IOAuto}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// WithTimeout sets how long to wait for the database file lock.
+// Zero (the default) waits forever.
+func WithTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.timeout = timeout
+	}
+}
+
+// IOMode is how photo files are read
+type IOMode string
+
+const (
+	// IODirect reads files with O_DIRECT, bypassing the page cache.
+	// Files smaller than a direct IO block are read buffered.
+	IODirect IOMode = "direct"
+	// IOBuffered reads files through the page cache
+	IOBuffered IOMode = "buffered"
+	// IOAuto probes O_DIRECT support once at open and uses direct IO if
+	// supported, with a fallback to buffered IO for files where it fails
+	IOAuto IOMode = "auto"
+)
+
+// ParseIOMode parses an IOMode name
+func ParseIOMode(name string) (IOMode, error) {
+	switch mode := IOMode(name); mode {
+	case IODirect, IOBuffered, IOAuto:
+		return mode, nil
+	}
+	return "", fmt.Errorf("unknown I/O mode: %s (must be 'direct', 'buffered', or 'auto')", name)
+}
+
+// WithIOMode sets how photo files are read, IOAuto by default
+func WithIOMode(mode IOMode) Option {
+	return func(o *options) {
+		o.ioMode = mode
+	}
+}
+
+// openDirect opens a file with O_DIRECT, replaced in tests
+var openDirect = directio.OpenFile
+
+// directIOUnsupported reports whether err means that the filesystem does
+// not support O_DIRECT, e.g. tmpfs and some overlay filesystems
+func directIOUnsupported(err error) bool {
+	return errors.Is(err, syscall.EINVAL) || errors.Is(err, syscall.EOPNOTSUPP)
+}
+
+// probeDirectIO reports whether the filesystem of dataPath supports
+// O_DIRECT, checked by opening the first photo file found. A database
+// without photo files is assumed to support it.
+func probeDirectIO(dataPath string) bool {
+	supported := true
+	filepath.WalkDir(dataPath, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return fs.SkipAll
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+		file, err := openDirect(path, os.O_RDONLY, 0)
+		if err == nil {
+			file.Close()
+		}
+		supported = !directIOUnsupported(err)
+		return fs.SkipAll
+	})
+	return supported
+}
+
 // FileTreeDB implements DBWriter interface using bbolt for metadata and filesystem for photos
 type FileTreeDB struct {
-	metaPath string
-	dataPath string
-	db       *bolt.DB
+	metaPath       string
+	dataPath       string
+	db             *bolt.DB
+	ioMode         IOMode // IODirect or IOBuffered
+	directFallback bool   // Read files buffered if O_DIRECT fails, for IOAuto
+}
+
+// newFileTreeDB returns a database on an opened meta file, resolving
+// IOAuto to the IO mode supported by the data directory
+func newFileTreeDB(metaPath, dataPath string, db *bolt.DB, o *options) *FileTreeDB {
+	w := &FileTreeDB{
+		metaPath: metaPath,
+		dataPath: dataPath,
+		db:       db,
+		ioMode:   o.ioMode,
+	}
+	if o.ioMode == IOAuto {
+		w.ioMode = IOBuffered
+		if probeDirectIO(dataPath) {
+			w.ioMode = IODirect
+		}
+		w.directFallback = true
+	}
+	return w
+}
+
+// IOMode returns how photo files are read, IODirect or IOBuffered
+func (w *FileTreeDB) IOMode() IOMode {
+	return w.ioMode
 }
 
 // New creates a new FileTreeDB for writing
-func New(dbDir string) (*FileTreeDB, error) {
+func New(dbDir string, opts ...Option) (*FileTreeDB, error) {
 	metaPath := filepath.Join(dbDir, metaFile)
 	dataPath := filepath.Join(dbDir, dataDir)
 
@@ -39,9 +157,10 @@ func New(dbDir string) (*FileTreeDB, error) {
 		return nil, fmt.Errorf("failed to create data directory: %w", err)
 	}
 
-	db, err := bolt.Open(metaPath, 0644, &bolt.Options{})
+	o := newOptions(opts)
+	db, err := boltfile.Open(metaPath, 0644, false, o.timeout)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open bbolt database: %w", err)
+		return nil, err
 	}
 
 	err = db.Update(func(tx *bolt.Tx) error {
@@ -53,11 +172,7 @@ func New(dbDir string) (*FileTreeDB, error) {
 		return nil, fmt.Errorf("failed to create bucket: %w", err)
 	}
 
-	return &FileTreeDB{
-		metaPath: metaPath,
-		dataPath: dataPath,
-		db:       db,
-	}, nil
+	return newFileTreeDB(metaPath, dataPath, db, o), nil
 }
 
 func (w *FileTreeDB) Close() error {
@@ -71,8 +186,23 @@ func (w *FileTreeDB) generateKey(catID, photoID uint64) []byte {
 	return key
 }
 
+// catPrefix returns the key prefix of all photos of a cat
+func (w *FileTreeDB) catPrefix(catID uint64) []byte {
+	prefix := make([]byte, 8)
+	binary.BigEndian.PutUint64(prefix, catID)
+	return prefix
+}
+
 func (w *FileTreeDB) generateFilename(catID, photoID uint64) string {
-	key := w.generateKey(catID, photoID)
+	return PhotoFilename(catID, photoID)
+}
+
+// PhotoFilename returns the name of the data file of a photo, the hex
+// SHA-256 of its key
+func PhotoFilename(catID, photoID uint64) string {
+	key := make([]byte, 16)
+	binary.BigEndian.PutUint64(key[:8], catID)
+	binary.BigEndian.PutUint64(key[8:], photoID)
 	hash := sha256.Sum256(key)
 	return fmt.Sprintf("%x", hash)
 }
@@ -89,7 +219,7 @@ func (w *FileTreeDB) AddPhoto(catID, photoID uint64, photoData []byte) error {
 
 	err := w.db.Update(func(tx *bolt.Tx) error {
 		bucket := tx.Bucket([]byte(metaBucket))
-		return bucket.Put(key, []byte{})
+		return bucket.Put(key, manul.NewMetaValue(photoData, time.Time{}))
 	})
 	if err != nil {
 		return fmt.Errorf("failed to update meta database: %w", err)
@@ -109,35 +239,169 @@ func (w *FileTreeDB) AddPhoto(catID, photoID uint64, photoData []byte) error {
 }
 
 func (w *FileTreeDB) AddPhotosBatch(photos []manul.PhotoItem) error {
-	// First update metadata in a single transaction
+	batch, err := w.NewBatch()
+	if err != nil {
+		return err
+	}
+	for _, photo := range photos {
+		if err := batch.Add(photo); err != nil {
+			batch.Abort()
+			return err
+		}
+	}
+	return batch.Commit()
+}
+
+// fileTreeBatch writes photo files as they are added, to temporary files
+// renamed on Commit, and stores their meta entries once the files are
+// renamed
+type fileTreeBatch struct {
+	w       *FileTreeDB
+	pending map[string]pendingPhoto // By photo path, the last photo added for a key wins
+}
+
+// pendingPhoto is the meta entry of a photo with a written temporary file
+type pendingPhoto struct {
+	key, meta []byte
+}
+
+// tempSuffix is appended to photo paths for files of uncommitted batches
+const tempSuffix = ".tmp"
+
+// NewBatch starts a batch, see manul.BatchWriter. The meta transaction
+// is only opened by Commit.
+func (w *FileTreeDB) NewBatch() (manul.Batch, error) {
+	return &fileTreeBatch{w: w, pending: make(map[string]pendingPhoto)}, nil
+}
+
+func (b *fileTreeBatch) Add(photo manul.PhotoItem) error {
+	photoPath := b.w.getPhotoPath(photo.CatID, photo.PhotoID)
+
+	if err := os.MkdirAll(filepath.Dir(photoPath), 0755); err != nil {
+		return fmt.Errorf("failed to create photo directory: %w", err)
+	}
+
+	if err := os.WriteFile(photoPath+tempSuffix, photo.PhotoData, 0644); err != nil {
+		return fmt.Errorf("failed to write photo file: %w", err)
+	}
+	b.pending[photoPath] = pendingPhoto{
+		key:  b.w.generateKey(photo.CatID, photo.PhotoID),
+		meta: manul.NewMetaValue(photo.PhotoData, photo.CreatedAt),
+	}
+
+	return nil
+}
+
+// Commit renames the photo files first, then stores the meta entries in a
+// single transaction, so a failure never leaves meta entries without files.
+// Files renamed before a failure are left under the previous meta entries
+// of their photos, or without meta entries for new photos.
+func (b *fileTreeBatch) Commit() error {
+	for photoPath := range b.pending {
+		if err := os.Rename(photoPath+tempSuffix, photoPath); err != nil {
+			b.Abort()
+			return fmt.Errorf("failed to rename photo file: %w", err)
+		}
+	}
+
+	err := b.w.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(metaBucket))
+		for _, photo := range b.pending {
+			if err := bucket.Put(photo.key, photo.meta); err != nil {
+				catID, photoID := b.w.parseKey(photo.key)
+				return fmt.Errorf("failed to update meta for cat_id=%d, photo_id=%d: %w", catID, photoID, err)
+			}
+		}
+		return nil
+	})
+	b.pending = nil
+	if err != nil {
+		return fmt.Errorf("failed to commit meta transaction: %w", err)
+	}
+	return nil
+}
+
+// Abort removes the temporary files not renamed yet
+func (b *fileTreeBatch) Abort() {
+	for photoPath := range b.pending {
+		os.Remove(photoPath + tempSuffix)
+	}
+	b.pending = nil
+}
+
+// DeletePhotos deletes meta entries in a single transaction, then the photo files.
+// A failure while removing files leaves orphan files which are never served.
+func (w *FileTreeDB) DeletePhotos(keys []manul.PhotoKey) ([]bool, error) {
+	deleted := make([]bool, len(keys))
+
 	err := w.db.Update(func(tx *bolt.Tx) error {
 		bucket := tx.Bucket([]byte(metaBucket))
-		for _, photo := range photos {
-			key := w.generateKey(photo.CatID, photo.PhotoID)
-			if err := bucket.Put(key, []byte{}); err != nil {
-				return fmt.Errorf("failed to update meta for cat_id=%d, photo_id=%d: %w", photo.CatID, photo.PhotoID, err)
+		for i, photoKey := range keys {
+			key := w.generateKey(photoKey.CatID, photoKey.PhotoID)
+			if bucket.Get(key) == nil {
+				continue
 			}
+			if err := bucket.Delete(key); err != nil {
+				return fmt.Errorf("failed to delete meta for cat_id=%d, photo_id=%d: %w", photoKey.CatID, photoKey.PhotoID, err)
+			}
+			deleted[i] = true
 		}
 		return nil
 	})
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	// Then write all photo files
-	for _, photo := range photos {
-		photoPath := w.getPhotoPath(photo.CatID, photo.PhotoID)
+	for i, photoKey := range keys {
+		if !deleted[i] {
+			continue
+		}
+		photoPath := w.getPhotoPath(photoKey.CatID, photoKey.PhotoID)
+		if err := os.Remove(photoPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return deleted, fmt.Errorf("failed to remove photo file %s: %w", photoPath, err)
+		}
+	}
+
+	return deleted, nil
+}
+
+// DeleteCat deletes the meta entries of a cat in a single transaction, then
+// the photo files, like DeletePhotos. A failure while removing files leaves
+// orphan files which are never served.
+func (w *FileTreeDB) DeleteCat(catID uint64) (int, error) {
+	prefix := w.catPrefix(catID)
+
+	var keys [][]byte
+	err := w.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(metaBucket))
+		if bucket == nil {
+			return fmt.Errorf("bucket %s not found", metaBucket)
+		}
 
-		if err := os.MkdirAll(filepath.Dir(photoPath), 0755); err != nil {
-			return fmt.Errorf("failed to create photo directory: %w", err)
+		cursor := bucket.Cursor()
+		for key, _ := cursor.Seek(prefix); key != nil && bytes.HasPrefix(key, prefix); key, _ = cursor.Next() {
+			keys = append(keys, append([]byte{}, key...))
+		}
+		for _, key := range keys {
+			if err := bucket.Delete(key); err != nil {
+				return fmt.Errorf("failed to update meta database: %w", err)
+			}
 		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
 
-		if err := os.WriteFile(photoPath, photo.PhotoData, 0644); err != nil {
-			return fmt.Errorf("failed to write photo file: %w", err)
+	for _, key := range keys {
+		catID, photoID := w.parseKey(key)
+		photoPath := w.getPhotoPath(catID, photoID)
+		if err := os.Remove(photoPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return len(keys), fmt.Errorf("failed to remove photo file %s: %w", photoPath, err)
 		}
 	}
 
-	return nil
+	return len(keys), nil
 }
 
 func (w *FileTreeDB) parseKey(key []byte) (catID, photoID uint64) {
@@ -187,12 +451,42 @@ func (w *FileTreeDB) GetPhotoIDs(catID uint64) ([]uint64, error) {
 			return fmt.Errorf("bucket %s not found", metaBucket)
 		}
 
+		// Keys start with the big-endian cat ID, so photos of a cat are adjacent
+		prefix := w.catPrefix(catID)
 		cursor := bucket.Cursor()
-		for key, _ := cursor.First(); key != nil; key, _ = cursor.Next() {
-			keyCatID, photoID := w.parseKey(key)
-			if keyCatID == catID {
-				photoIds = append(photoIds, photoID)
+		for key, _ := cursor.Seek(prefix); key != nil && bytes.HasPrefix(key, prefix); key, _ = cursor.Next() {
+			_, photoID := w.parseKey(key)
+			photoIds = append(photoIds, photoID)
+		}
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return photoIds, nil
+}
+
+func (w *FileTreeDB) ListCatIDs(startCatID uint64, limit int) ([]uint64, error) {
+	var catIds []uint64
+
+	err := w.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(metaBucket))
+		if bucket == nil {
+			return fmt.Errorf("bucket %s not found", metaBucket)
+		}
+
+		cursor := bucket.Cursor()
+		key, _ := cursor.Seek(w.catPrefix(startCatID))
+		for key != nil && len(catIds) < limit {
+			catID, _ := w.parseKey(key)
+			catIds = append(catIds, catID)
+			if catID == math.MaxUint64 {
+				break
 			}
+			// Skip the other photos of the cat
+			key, _ = cursor.Seek(w.catPrefix(catID + 1))
 		}
 		return nil
 	})
@@ -200,10 +494,129 @@ func (w *FileTreeDB) GetPhotoIDs(catID uint64) ([]uint64, error) {
 	if err != nil {
 		return nil, err
 	}
+	return catIds, nil
+}
+
+func (w *FileTreeDB) ListPhotoIDs(catID, startPhotoID uint64, limit int) ([]uint64, error) {
+	var photoIds []uint64
 
+	err := w.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(metaBucket))
+		if bucket == nil {
+			return fmt.Errorf("bucket %s not found", metaBucket)
+		}
+
+		prefix := w.catPrefix(catID)
+		cursor := bucket.Cursor()
+		for key, _ := cursor.Seek(w.generateKey(catID, startPhotoID)); key != nil && bytes.HasPrefix(key, prefix) && len(photoIds) < limit; key, _ = cursor.Next() {
+			_, photoID := w.parseKey(key)
+			photoIds = append(photoIds, photoID)
+		}
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
 	return photoIds, nil
 }
 
+func (w *FileTreeDB) CountPhotos(catID uint64) (uint64, error) {
+	var count uint64
+
+	err := w.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(metaBucket))
+		if bucket == nil {
+			return fmt.Errorf("bucket %s not found", metaBucket)
+		}
+
+		prefix := w.catPrefix(catID)
+		cursor := bucket.Cursor()
+		for key, _ := cursor.Seek(prefix); key != nil && bytes.HasPrefix(key, prefix); key, _ = cursor.Next() {
+			count++
+		}
+		return nil
+	})
+
+	return count, err
+}
+
+func (w *FileTreeDB) CountAllPhotos() (uint64, error) {
+	var count uint64
+
+	err := w.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(metaBucket))
+		if bucket == nil {
+			return fmt.Errorf("bucket %s not found", metaBucket)
+		}
+
+		cursor := bucket.Cursor()
+		for key, _ := cursor.First(); key != nil; key, _ = cursor.Next() {
+			count++
+		}
+		return nil
+	})
+
+	return count, err
+}
+
+// ForEachPhoto iterates the meta bucket in a single read transaction
+func (w *FileTreeDB) ForEachPhoto(fn func(catID, photoID uint64) error) error {
+	return w.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(metaBucket))
+		if bucket == nil {
+			return fmt.Errorf("bucket %s not found", metaBucket)
+		}
+
+		cursor := bucket.Cursor()
+		for key, _ := cursor.First(); key != nil; key, _ = cursor.Next() {
+			if err := fn(w.parseKey(key)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// GetRandomPhoto picks a random cat ID between the first and last ones, and
+// a random photo ID of the cat at or after it, seeking a cursor instead of
+// reading all keys. Photos after gaps in the IDs are picked more often.
+func (w *FileTreeDB) GetRandomPhoto() (catID, photoID uint64, err error) {
+	err = w.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(metaBucket))
+		if bucket == nil {
+			return fmt.Errorf("bucket %s not found", metaBucket)
+		}
+
+		cursor := bucket.Cursor()
+		first, _ := cursor.First()
+		if first == nil {
+			return manul.ErrNoPhotos
+		}
+		last, _ := cursor.Last()
+		firstCatID, _ := w.parseKey(first)
+		lastCatID, _ := w.parseKey(last)
+
+		// A cat is found at or before the last cat ID
+		key, _ := cursor.Seek(w.catPrefix(manul.RandomID(firstCatID, lastCatID)))
+		var firstPhotoID uint64
+		catID, firstPhotoID = w.parseKey(key)
+
+		// The last photo of the cat is the key before the next cat
+		if catID < math.MaxUint64 {
+			if next, _ := cursor.Seek(w.catPrefix(catID + 1)); next != nil {
+				last, _ = cursor.Prev()
+			}
+		}
+		_, lastPhotoID := w.parseKey(last)
+
+		key, _ = cursor.Seek(w.generateKey(catID, manul.RandomID(firstPhotoID, lastPhotoID)))
+		catID, photoID = w.parseKey(key)
+		return nil
+	})
+	return catID, photoID, err
+}
+
 func (w *FileTreeDB) GetPhotoData(catID, photoID uint64) ([]byte, error) {
 	key := w.generateKey(catID, photoID)
 
@@ -224,21 +637,33 @@ func (w *FileTreeDB) GetPhotoData(catID, photoID uint64) ([]byte, error) {
 		return nil, err
 	}
 
-	photoPath := w.getPhotoPath(catID, photoID)
+	return w.readPhotoFile(w.getPhotoPath(catID, photoID))
+}
 
-	// Open file with O_DIRECT flag
-	file, err := directio.OpenFile(photoPath, os.O_RDONLY, 0644)
+// readPhotoFile reads a photo file in the IO mode of the database. In
+// direct mode files smaller than a direct IO block are read with buffered
+// IO. With IOAuto files are read buffered too if O_DIRECT opens or reads
+// fail as unsupported.
+func (w *FileTreeDB) readPhotoFile(photoPath string) ([]byte, error) {
+	fileInfo, err := os.Stat(photoPath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open photo file %s: %w", photoPath, err)
+		return nil, fmt.Errorf("failed to stat photo file %s: %w", photoPath, err)
+	}
+	fileSize := fileInfo.Size()
+
+	if w.ioMode == IOBuffered || fileSize < directio.BlockSize {
+		return readBuffered(photoPath, fileSize)
 	}
-	defer file.Close()
 
-	// Get file size
-	fileInfo, err := file.Stat()
+	// Open file with O_DIRECT flag
+	file, err := openDirect(photoPath, os.O_RDONLY, 0644)
+	if w.directFallback && directIOUnsupported(err) {
+		return readBuffered(photoPath, fileSize)
+	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to stat photo file %s: %w", photoPath, err)
+		return nil, fmt.Errorf("failed to open photo file %s: %w", photoPath, err)
 	}
-	fileSize := fileInfo.Size()
+	defer file.Close()
 
 	// Allocate aligned block for reading
 	// Using 1MB block as approx photo size
@@ -248,6 +673,10 @@ func (w *FileTreeDB) GetPhotoData(catID, photoID uint64) ([]byte, error) {
 	// Read file in chunks
 	for {
 		n, err := io.ReadFull(file, block)
+		if w.directFallback && directIOUnsupported(err) {
+			// Some filesystems accept O_DIRECT opens but fail the reads
+			return readBuffered(photoPath, fileSize)
+		}
 		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
 			return nil, fmt.Errorf("failed to read photo file %s: %w", photoPath, err)
 		}
@@ -259,22 +688,277 @@ func (w *FileTreeDB) GetPhotoData(catID, photoID uint64) ([]byte, error) {
 		}
 	}
 
-	return photoData, nil
+	return checkSize(photoPath, photoData, fileSize)
+}
+
+// readBuffered reads a photo file with regular buffered IO
+func readBuffered(photoPath string, fileSize int64) ([]byte, error) {
+	photoData, err := os.ReadFile(photoPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read photo file %s: %w", photoPath, err)
+	}
+	return checkSize(photoPath, photoData, fileSize)
+}
+
+// checkSize trims alignment padding from photoData and
+// verifies that the whole file was read
+func checkSize(photoPath string, photoData []byte, fileSize int64) ([]byte, error) {
+	if int64(len(photoData)) < fileSize {
+		return nil, fmt.Errorf("short read of photo file %s: got %d bytes, want %d", photoPath, len(photoData), fileSize)
+	}
+	return photoData[:fileSize], nil
+}
+
+func (w *FileTreeDB) GetPhotoFormat(catID, photoID uint64) (string, error) {
+	meta, err := w.GetPhotoMeta(catID, photoID)
+	if err != nil {
+		return "", err
+	}
+	return meta.Format.String(), nil
+}
+
+func (w *FileTreeDB) GetPhotoMeta(catID, photoID uint64) (manul.PhotoMeta, error) {
+	value, err := w.metaValue(catID, photoID)
+	if err != nil {
+		return manul.PhotoMeta{}, err
+	}
+
+	return manul.ResolveMeta(value, func() ([]byte, error) {
+		return w.GetPhotoData(catID, photoID)
+	}, w.storeMeta(w.generateKey(catID, photoID), value))
+}
+
+func (w *FileTreeDB) GetPhotoInfo(catID, photoID uint64) (manul.PhotoMeta, error) {
+	value, err := w.metaValue(catID, photoID)
+	if err != nil {
+		return manul.PhotoMeta{}, err
+	}
+	return manul.StoredMeta(value), nil
+}
+
+// metaValue returns a copy of the meta value stored for a photo
+func (w *FileTreeDB) metaValue(catID, photoID uint64) ([]byte, error) {
+	key := w.generateKey(catID, photoID)
+	var value []byte
+
+	err := w.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(metaBucket))
+		if bucket == nil {
+			return fmt.Errorf("bucket %s not found", metaBucket)
+		}
+
+		v := bucket.Get(key)
+		if v == nil {
+			return fmt.Errorf("photo with cat_id=%d, photo_id=%d not found in database", catID, photoID)
+		}
+		value = append([]byte{}, v...)
+		return nil
+	})
+
+	return value, err
+}
+
+// storeMeta returns the function storing a recomputed meta value of key,
+// nil if the database is read-only. The value is stored only if key still
+// holds old, so a photo deleted or replaced meanwhile gets no meta back.
+func (w *FileTreeDB) storeMeta(key, old []byte) func([]byte) error {
+	if w.db.IsReadOnly() {
+		return nil
+	}
+	return func(value []byte) error {
+		return w.db.Update(func(tx *bolt.Tx) error {
+			bucket := tx.Bucket([]byte(metaBucket))
+			if !bytes.Equal(bucket.Get(key), old) {
+				return nil
+			}
+			return bucket.Put(key, value)
+		})
+	}
+}
+
+func (w *FileTreeDB) PhotoExists(catID, photoID uint64) (bool, error) {
+	key := w.generateKey(catID, photoID)
+	exists := false
+
+	err := w.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(metaBucket))
+		if bucket == nil {
+			return fmt.Errorf("bucket %s not found", metaBucket)
+		}
+		exists = bucket.Get(key) != nil
+		return nil
+	})
+
+	return exists, err
+}
+
+// Problem reasons
+const (
+	ProblemMissing = "data file missing" // Meta entry without a data file
+	ProblemEmpty   = "data file empty"   // Meta entry with an empty data file
+	ProblemOrphan  = "orphan file"       // Data file without a meta entry
+)
+
+// Problem is an inconsistency between the meta database and a data file
+type Problem struct {
+	CatID   uint64 // Zero for orphan files, their names are hashes of the IDs
+	PhotoID uint64
+	Path    string
+	Reason  string
+}
+
+// VerifyReport is the result of Verify and VerifyIntegrity
+type VerifyReport struct {
+	Checked  int       // Number of meta entries checked
+	Missing  int       // Entries without a data file
+	Empty    int       // Entries with an empty data file
+	Orphans  int       // Data files without a meta entry, found by VerifyIntegrity only
+	Problems []Problem // Problem entries, up to the maxProblems limit
+}
+
+func (r *VerifyReport) add(problem Problem, maxProblems int) {
+	if maxProblems < 0 || len(r.Problems) < maxProblems {
+		r.Problems = append(r.Problems, problem)
+	}
+}
+
+// Verify checks that every meta entry has a non-empty data file.
+// It only reads the database. At most maxProblems problems are listed
+// in the report, negative maxProblems lists all of them.
+func (w *FileTreeDB) Verify(maxProblems int) (*VerifyReport, error) {
+	return w.verify(maxProblems, false)
+}
+
+// VerifyIntegrity runs the checks of Verify and also finds the data files
+// without meta entries, including files left in the wrong shard directory
+// and temporary files of aborted batches. It reads the whole data directory
+// and holds the data file paths of all meta entries in memory.
+func (w *FileTreeDB) VerifyIntegrity(maxProblems int) (*VerifyReport, error) {
+	return w.verify(maxProblems, true)
+}
+
+func (w *FileTreeDB) verify(maxProblems int, orphans bool) (*VerifyReport, error) {
+	report := &VerifyReport{}
+	var expected map[string]struct{}
+	if orphans {
+		expected = make(map[string]struct{})
+	}
+
+	err := w.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(metaBucket))
+		if bucket == nil {
+			return fmt.Errorf("bucket %s not found", metaBucket)
+		}
+
+		cursor := bucket.Cursor()
+		for key, _ := cursor.First(); key != nil; key, _ = cursor.Next() {
+			report.Checked++
+			catID, photoID := w.parseKey(key)
+			photoPath := w.getPhotoPath(catID, photoID)
+			if expected != nil {
+				expected[photoPath] = struct{}{}
+			}
+
+			var reason string
+			fileInfo, err := os.Stat(photoPath)
+			switch {
+			case errors.Is(err, os.ErrNotExist):
+				report.Missing++
+				reason = ProblemMissing
+			case err != nil:
+				return fmt.Errorf("failed to stat photo file %s: %w", photoPath, err)
+			case fileInfo.Size() == 0:
+				report.Empty++
+				reason = ProblemEmpty
+			default:
+				continue
+			}
+
+			report.add(Problem{
+				CatID:   catID,
+				PhotoID: photoID,
+				Path:    photoPath,
+				Reason:  reason,
+			}, maxProblems)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !orphans {
+		return report, nil
+	}
+
+	err = filepath.WalkDir(w.dataPath, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			return nil
+		}
+		if _, ok := expected[path]; !ok {
+			report.Orphans++
+			report.add(Problem{Path: path, Reason: ProblemOrphan}, maxProblems)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to walk data directory: %w", err)
+	}
+	return report, nil
+}
+
+// RemoveOrphans removes the orphan files of problems returned by
+// VerifyIntegrity and returns the number of removed files. The database
+// must be opened for writing, so no other process adds photos meanwhile.
+func (w *FileTreeDB) RemoveOrphans(problems []Problem) (int, error) {
+	if w.db.IsReadOnly() {
+		return 0, fmt.Errorf("database is opened read-only")
+	}
+
+	removed := 0
+	for _, problem := range problems {
+		if problem.Reason != ProblemOrphan {
+			continue
+		}
+		if err := os.Remove(problem.Path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return removed, fmt.Errorf("failed to remove orphan file %s: %w", problem.Path, err)
+		}
+		removed++
+	}
+	return removed, nil
 }
 
 // NewReader creates a new FileTreeDB for reading (read-only mode)
-func NewReader(dbDir string) (*FileTreeDB, error) {
+func NewReader(dbDir string, opts ...Option) (*FileTreeDB, error) {
 	metaPath := filepath.Join(dbDir, metaFile)
 	dataPath := filepath.Join(dbDir, dataDir)
 
-	db, err := bolt.Open(metaPath, 0600, &bolt.Options{ReadOnly: true})
+	o := newOptions(opts)
+	db, err := boltfile.Open(metaPath, 0600, true, o.timeout)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open bbolt database: %w", err)
+		return nil, err
 	}
 
-	return &FileTreeDB{
-		metaPath: metaPath,
-		dataPath: dataPath,
-		db:       db,
-	}, nil
+	return newFileTreeDB(metaPath, dataPath, db, o), nil
+}
+
+// OpenWriter opens an existing FileTreeDB for writing. Unlike New, it fails
+// if the database does not exist instead of creating an empty one.
+func OpenWriter(dbDir string, opts ...Option) (*FileTreeDB, error) {
+	metaPath := filepath.Join(dbDir, metaFile)
+	dataPath := filepath.Join(dbDir, dataDir)
+
+	if _, err := os.Stat(metaPath); err != nil {
+		return nil, fmt.Errorf("no database in %s: %w", dbDir, err)
+	}
+
+	o := newOptions(opts)
+	db, err := boltfile.Open(metaPath, 0644, false, o.timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	return newFileTreeDB(metaPath, dataPath, db, o), nil
 }
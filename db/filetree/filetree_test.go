@@ -0,0 +1,859 @@
+package filetree
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/mhbvr/manul"
+	"github.com/ncw/directio"
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestGetPhotoData_Sizes(t *testing.T) {
+	db, err := New(t.TempDir())
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	defer db.Close()
+
+	sizes := []int{
+		0,
+		1,
+		directio.BlockSize - 1,
+		directio.BlockSize,
+		directio.BlockSize + 1,
+		1024*1024 + 1, // More than one read chunk
+	}
+
+	for i, size := range sizes {
+		photoID := uint64(i)
+		photoData := make([]byte, size)
+		for j := range photoData {
+			photoData[j] = byte(j % 251)
+		}
+
+		if err := db.AddPhoto(1, photoID, photoData); err != nil {
+			t.Fatalf("AddPhoto() with size %d failed: %v", size, err)
+		}
+
+		got, err := db.GetPhotoData(1, photoID)
+		if err != nil {
+			t.Errorf("GetPhotoData() with size %d failed: %v", size, err)
+			continue
+		}
+		if len(got) != size {
+			t.Errorf("GetPhotoData() returned %d bytes, want %d", len(got), size)
+			continue
+		}
+		if !bytes.Equal(got, photoData) {
+			t.Errorf("GetPhotoData() with size %d returned different data", size)
+		}
+	}
+}
+
+func TestIOMode(t *testing.T) {
+	dir := t.TempDir()
+	db, err := New(dir)
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	photoData := make([]byte, 2*directio.BlockSize)
+	for i := range photoData {
+		photoData[i] = byte(i % 251)
+	}
+	if err := db.AddPhoto(1, 1, photoData); err != nil {
+		t.Fatalf("AddPhoto() failed: %v", err)
+	}
+	db.Close()
+
+	autoMode := IOBuffered
+	if probeDirectIO(filepath.Join(dir, dataDir)) {
+		autoMode = IODirect
+	}
+
+	for _, mode := range []IOMode{IOBuffered, IOAuto, IODirect} {
+		want := mode
+		if mode == IOAuto {
+			want = autoMode
+		}
+		if mode == IODirect && autoMode != IODirect {
+			continue // No O_DIRECT support in the test directory
+		}
+
+		db, err := NewReader(dir, WithIOMode(mode))
+		if err != nil {
+			t.Fatalf("NewReader() failed: %v", err)
+		}
+		if got := db.IOMode(); got != want {
+			t.Errorf("IOMode() with %s = %s, want %s", mode, got, want)
+		}
+		if got, err := db.GetPhotoData(1, 1); err != nil || !bytes.Equal(got, photoData) {
+			t.Errorf("GetPhotoData() with %s = %d bytes, %v, want the photo", mode, len(got), err)
+		}
+		db.Close()
+	}
+}
+
+func TestGetPhotoData_DirectIOUnsupported(t *testing.T) {
+	db, err := New(t.TempDir())
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	defer db.Close()
+
+	photoData := make([]byte, 2*directio.BlockSize)
+	if err := db.AddPhoto(1, 1, photoData); err != nil {
+		t.Fatalf("AddPhoto() failed: %v", err)
+	}
+
+	defer func(open func(string, int, os.FileMode) (*os.File, error)) { openDirect = open }(openDirect)
+	for _, errno := range []syscall.Errno{syscall.EINVAL, syscall.EOPNOTSUPP} {
+		openDirect = func(name string, flag int, perm os.FileMode) (*os.File, error) {
+			return nil, &os.PathError{Op: "open", Path: name, Err: errno}
+		}
+
+		// Direct IO enabled explicitly fails, auto falls back to buffered IO
+		db.ioMode, db.directFallback = IODirect, false
+		if _, err := db.GetPhotoData(1, 1); !errors.Is(err, errno) {
+			t.Errorf("GetPhotoData() in direct mode with %v = %v, want the open error", errno, err)
+		}
+		db.directFallback = true
+		if got, err := db.GetPhotoData(1, 1); err != nil || !bytes.Equal(got, photoData) {
+			t.Errorf("GetPhotoData() in auto mode with %v = %d bytes, %v, want the photo", errno, len(got), err)
+		}
+	}
+}
+
+func TestParseIOMode(t *testing.T) {
+	for _, name := range []string{"direct", "buffered", "auto"} {
+		if mode, err := ParseIOMode(name); err != nil || string(mode) != name {
+			t.Errorf("ParseIOMode(%q) = %q, %v, want %q", name, mode, err, name)
+		}
+	}
+	if _, err := ParseIOMode("mmap"); err == nil {
+		t.Errorf("ParseIOMode(%q) succeeded, want error", "mmap")
+	}
+}
+
+func TestVerify(t *testing.T) {
+	db, err := New(t.TempDir())
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	defer db.Close()
+
+	for photoID := uint64(1); photoID <= 3; photoID++ {
+		if err := db.AddPhoto(1, photoID, []byte("photo")); err != nil {
+			t.Fatalf("AddPhoto() failed: %v", err)
+		}
+	}
+	if err := os.Remove(db.getPhotoPath(1, 2)); err != nil {
+		t.Fatalf("Remove() failed: %v", err)
+	}
+	if err := os.Truncate(db.getPhotoPath(1, 3), 0); err != nil {
+		t.Fatalf("Truncate() failed: %v", err)
+	}
+
+	report, err := db.Verify(-1)
+	if err != nil {
+		t.Fatalf("Verify() failed: %v", err)
+	}
+	if report.Checked != 3 || report.Missing != 1 || report.Empty != 1 {
+		t.Errorf("Verify() = checked %d, missing %d, empty %d, want 3, 1, 1",
+			report.Checked, report.Missing, report.Empty)
+	}
+	if len(report.Problems) != 2 || report.Problems[0].PhotoID != 2 || report.Problems[1].PhotoID != 3 {
+		t.Errorf("Verify() problems = %+v, want photos 2 and 3", report.Problems)
+	}
+
+	report, err = db.Verify(1)
+	if err != nil {
+		t.Fatalf("Verify() failed: %v", err)
+	}
+	if len(report.Problems) != 1 || report.Missing != 1 || report.Empty != 1 {
+		t.Errorf("Verify(1) = %+v, want one listed problem and full counts", report)
+	}
+}
+
+func TestVerifyIntegrity(t *testing.T) {
+	db, err := New(t.TempDir())
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	defer db.Close()
+
+	for photoID := uint64(1); photoID <= 3; photoID++ {
+		if err := db.AddPhoto(1, photoID, []byte("photo")); err != nil {
+			t.Fatalf("AddPhoto() failed: %v", err)
+		}
+	}
+	if err := os.Remove(db.getPhotoPath(1, 2)); err != nil {
+		t.Fatalf("Remove() failed: %v", err)
+	}
+	if err := os.Truncate(db.getPhotoPath(1, 3), 0); err != nil {
+		t.Fatalf("Truncate() failed: %v", err)
+	}
+	orphans := []string{
+		db.getPhotoPath(9, 9),
+		db.getPhotoPath(1, 1) + tempSuffix,
+	}
+	for _, path := range orphans {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("MkdirAll() failed: %v", err)
+		}
+		if err := os.WriteFile(path, []byte("orphan"), 0644); err != nil {
+			t.Fatalf("WriteFile() failed: %v", err)
+		}
+	}
+
+	report, err := db.VerifyIntegrity(-1)
+	if err != nil {
+		t.Fatalf("VerifyIntegrity() failed: %v", err)
+	}
+	if report.Checked != 3 || report.Missing != 1 || report.Empty != 1 || report.Orphans != 2 {
+		t.Errorf("VerifyIntegrity() = checked %d, missing %d, empty %d, orphans %d, want 3, 1, 1, 2",
+			report.Checked, report.Missing, report.Empty, report.Orphans)
+	}
+	want := map[Problem]bool{
+		{CatID: 1, PhotoID: 2, Path: db.getPhotoPath(1, 2), Reason: ProblemMissing}: true,
+		{CatID: 1, PhotoID: 3, Path: db.getPhotoPath(1, 3), Reason: ProblemEmpty}:   true,
+		{Path: orphans[0], Reason: ProblemOrphan}:                                   true,
+		{Path: orphans[1], Reason: ProblemOrphan}:                                   true,
+	}
+	if len(report.Problems) != len(want) {
+		t.Errorf("VerifyIntegrity() = %+v, want %d problems", report.Problems, len(want))
+	}
+	for _, problem := range report.Problems {
+		if !want[problem] {
+			t.Errorf("VerifyIntegrity() reported unexpected problem %+v", problem)
+		}
+	}
+
+	removed, err := db.RemoveOrphans(report.Problems)
+	if err != nil || removed != 2 {
+		t.Errorf("RemoveOrphans() = %d, %v, want 2", removed, err)
+	}
+	for _, path := range orphans {
+		if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("Orphan file %s exists after RemoveOrphans(): %v", path, err)
+		}
+	}
+	if _, err := db.GetPhotoData(1, 1); err != nil {
+		t.Errorf("GetPhotoData() of kept photo failed: %v", err)
+	}
+
+	report, err = db.VerifyIntegrity(-1)
+	if err != nil || report.Orphans != 0 || len(report.Problems) != 2 {
+		t.Errorf("VerifyIntegrity() after RemoveOrphans() = %+v, %v, want the missing and empty data files", report, err)
+	}
+}
+
+func TestOpenWriter(t *testing.T) {
+	dir := t.TempDir()
+	missing := filepath.Join(dir, "missing")
+	if db, err := OpenWriter(missing); err == nil {
+		db.Close()
+		t.Errorf("OpenWriter() of a missing database succeeded, want error")
+	}
+	if _, err := os.Stat(missing); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("OpenWriter() of a missing database created %s: %v", missing, err)
+	}
+
+	db, err := New(dir)
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	if err := db.AddPhoto(1, 1, []byte("photo")); err != nil {
+		t.Fatalf("AddPhoto() failed: %v", err)
+	}
+	db.Close()
+
+	db, err = OpenWriter(dir)
+	if err != nil {
+		t.Fatalf("OpenWriter() failed: %v", err)
+	}
+	defer db.Close()
+	if err := db.AddPhoto(1, 2, []byte("photo")); err != nil {
+		t.Errorf("AddPhoto() after OpenWriter() failed: %v", err)
+	}
+}
+
+func TestDeletePhotos(t *testing.T) {
+	db, err := New(t.TempDir())
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	defer db.Close()
+
+	for photoID := uint64(1); photoID <= 2; photoID++ {
+		if err := db.AddPhoto(1, photoID, []byte("photo")); err != nil {
+			t.Fatalf("AddPhoto() failed: %v", err)
+		}
+	}
+
+	deleted, err := db.DeletePhotos([]manul.PhotoKey{
+		{CatID: 1, PhotoID: 1},
+		{CatID: 1, PhotoID: 3}, // Does not exist
+		{CatID: 1, PhotoID: 1}, // Already deleted
+	})
+	if err != nil {
+		t.Fatalf("DeletePhotos() failed: %v", err)
+	}
+	want := []bool{true, false, false}
+	for i := range want {
+		if deleted[i] != want[i] {
+			t.Errorf("DeletePhotos() = %v, want %v", deleted, want)
+			break
+		}
+	}
+
+	if _, err := db.GetPhotoData(1, 1); err == nil {
+		t.Errorf("GetPhotoData() of deleted photo succeeded, want error")
+	}
+	if _, err := os.Stat(db.getPhotoPath(1, 1)); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Photo file of deleted photo exists: %v", err)
+	}
+	if _, err := db.GetPhotoData(1, 2); err != nil {
+		t.Errorf("GetPhotoData() of kept photo failed: %v", err)
+	}
+}
+
+func TestBatch(t *testing.T) {
+	db, err := New(t.TempDir())
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	defer db.Close()
+
+	if err := db.AddPhoto(1, 1, []byte("old")); err != nil {
+		t.Fatalf("AddPhoto() failed: %v", err)
+	}
+
+	// An aborted batch keeps existing photos and adds nothing
+	batch, err := db.NewBatch()
+	if err != nil {
+		t.Fatalf("NewBatch() failed: %v", err)
+	}
+	for _, photoID := range []uint64{1, 2} {
+		if err := batch.Add(manul.PhotoItem{CatID: 1, PhotoID: photoID, PhotoData: []byte("aborted")}); err != nil {
+			t.Fatalf("Add() failed: %v", err)
+		}
+	}
+	batch.Abort()
+
+	if got, err := db.GetPhotoData(1, 1); err != nil || string(got) != "old" {
+		t.Errorf("GetPhotoData() after Abort() = %q, %v, want %q", got, err, "old")
+	}
+	if exists, err := db.PhotoExists(1, 2); err != nil || exists {
+		t.Errorf("PhotoExists() of aborted photo = %v, %v, want false", exists, err)
+	}
+	if _, err := os.Stat(db.getPhotoPath(1, 2) + tempSuffix); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Temporary file of aborted photo exists: %v", err)
+	}
+
+	batch, err = db.NewBatch()
+	if err != nil {
+		t.Fatalf("NewBatch() failed: %v", err)
+	}
+	for _, photoID := range []uint64{1, 2} {
+		if err := batch.Add(manul.PhotoItem{CatID: 1, PhotoID: photoID, PhotoData: []byte("new")}); err != nil {
+			t.Fatalf("Add() failed: %v", err)
+		}
+	}
+	if err := batch.Commit(); err != nil {
+		t.Fatalf("Commit() failed: %v", err)
+	}
+
+	for _, photoID := range []uint64{1, 2} {
+		if got, err := db.GetPhotoData(1, photoID); err != nil || string(got) != "new" {
+			t.Errorf("GetPhotoData(1, %d) after Commit() = %q, %v, want %q", photoID, got, err, "new")
+		}
+	}
+}
+
+func TestBatch_DuplicateKeys(t *testing.T) {
+	db, err := New(t.TempDir())
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	defer db.Close()
+
+	batch, err := db.NewBatch()
+	if err != nil {
+		t.Fatalf("NewBatch() failed: %v", err)
+	}
+	for _, photo := range []manul.PhotoItem{
+		{CatID: 1, PhotoID: 1, PhotoData: []byte("first")},
+		{CatID: 1, PhotoID: 2, PhotoData: []byte("other")},
+		{CatID: 1, PhotoID: 1, PhotoData: []byte("last")},
+	} {
+		if err := batch.Add(photo); err != nil {
+			t.Fatalf("Add() failed: %v", err)
+		}
+	}
+
+	// Meta entries are stored by Commit
+	if exists, err := db.PhotoExists(1, 1); err != nil || exists {
+		t.Errorf("PhotoExists() before Commit() = %v, %v, want false", exists, err)
+	}
+	if err := batch.Commit(); err != nil {
+		t.Fatalf("Commit() failed: %v", err)
+	}
+
+	// The last photo added for a key wins, for its file and meta entry
+	got, err := db.GetPhotoData(1, 1)
+	if err != nil || string(got) != "last" {
+		t.Errorf("GetPhotoData() = %q, %v, want %q", got, err, "last")
+	}
+	meta, err := db.GetPhotoMeta(1, 1)
+	if err != nil || meta.SHA256 != manul.NewPhotoMeta([]byte("last")).SHA256 {
+		t.Errorf("GetPhotoMeta() = hash %s, %v, want the hash of the last photo", meta.Hash(), err)
+	}
+	if report, err := db.VerifyIntegrity(-1); err != nil || len(report.Problems) != 0 {
+		t.Errorf("VerifyIntegrity() = %+v, %v, want no problems", report, err)
+	}
+
+	// Batches written with AddPhotosBatch overwrite as well
+	err = db.AddPhotosBatch([]manul.PhotoItem{
+		{CatID: 1, PhotoID: 2, PhotoData: []byte("first")},
+		{CatID: 1, PhotoID: 2, PhotoData: []byte("last")},
+	})
+	if err != nil {
+		t.Fatalf("AddPhotosBatch() failed: %v", err)
+	}
+	if got, err := db.GetPhotoData(1, 2); err != nil || string(got) != "last" {
+		t.Errorf("GetPhotoData() = %q, %v, want %q", got, err, "last")
+	}
+}
+
+func TestDeleteCat(t *testing.T) {
+	db, err := New(t.TempDir())
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	defer db.Close()
+
+	photos := []manul.PhotoItem{
+		{CatID: 1, PhotoID: 1, PhotoData: []byte("photo")},
+		{CatID: 2, PhotoID: 1, PhotoData: []byte("photo")},
+		{CatID: 2, PhotoID: 2, PhotoData: []byte("photo")},
+		{CatID: 3, PhotoID: 1, PhotoData: []byte("photo")},
+	}
+	if err := db.AddPhotosBatch(photos); err != nil {
+		t.Fatalf("AddPhotosBatch() failed: %v", err)
+	}
+
+	count, err := db.DeleteCat(2)
+	if err != nil {
+		t.Fatalf("DeleteCat() failed: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("DeleteCat() = %d, want 2", count)
+	}
+
+	if photoIDs, _ := db.GetPhotoIDs(2); len(photoIDs) != 0 {
+		t.Errorf("GetPhotoIDs() after DeleteCat() = %v, want none", photoIDs)
+	}
+	if _, err := os.Stat(db.getPhotoPath(2, 1)); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Photo file of deleted cat exists: %v", err)
+	}
+	for _, catID := range []uint64{1, 3} {
+		if photoIDs, _ := db.GetPhotoIDs(catID); len(photoIDs) != 1 {
+			t.Errorf("GetPhotoIDs(%d) = %v, want one photo", catID, photoIDs)
+		}
+	}
+
+	if count, err := db.DeleteCat(2); err != nil || count != 0 {
+		t.Errorf("DeleteCat() of missing cat = %d, %v, want 0, nil", count, err)
+	}
+
+	// A photo file which cannot be removed leaves an orphan file, the meta
+	// entries are deleted first
+	photoPath := db.getPhotoPath(3, 1)
+	if err := os.Remove(photoPath); err != nil {
+		t.Fatalf("Failed to remove photo file: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Join(photoPath, "blocker"), 0755); err != nil {
+		t.Fatalf("Failed to create a non-empty directory: %v", err)
+	}
+	if _, err := db.DeleteCat(3); err == nil {
+		t.Errorf("DeleteCat() with an unremovable file succeeded, want error")
+	}
+	if photoIDs, _ := db.GetPhotoIDs(3); len(photoIDs) != 0 {
+		t.Errorf("GetPhotoIDs() after a failed file removal = %v, want none", photoIDs)
+	}
+}
+
+func TestGetPhotoMeta_CreatedAt(t *testing.T) {
+	db, err := New(t.TempDir())
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	defer db.Close()
+
+	createdAt := time.Date(2021, 6, 1, 12, 30, 0, 123, time.UTC)
+	err = db.AddPhotosBatch([]manul.PhotoItem{
+		{CatID: 1, PhotoID: 1, PhotoData: []byte("photo"), CreatedAt: createdAt},
+		{CatID: 1, PhotoID: 2, PhotoData: []byte("photo")},
+	})
+	if err != nil {
+		t.Fatalf("AddPhotosBatch() failed: %v", err)
+	}
+
+	meta, err := db.GetPhotoMeta(1, 1)
+	if err != nil {
+		t.Fatalf("GetPhotoMeta() failed: %v", err)
+	}
+	if !meta.CreatedAt.Equal(createdAt) {
+		t.Errorf("CreatedAt = %v, want %v", meta.CreatedAt, createdAt)
+	}
+	if meta.SHA256 != manul.NewPhotoMeta([]byte("photo")).SHA256 {
+		t.Errorf("SHA256 = %s, want the photo data hash", meta.Hash())
+	}
+
+	meta, err = db.GetPhotoMeta(1, 2)
+	if err != nil {
+		t.Fatalf("GetPhotoMeta() failed: %v", err)
+	}
+	if !meta.CreatedAt.IsZero() {
+		t.Errorf("CreatedAt = %v, want zero for unknown creation time", meta.CreatedAt)
+	}
+}
+
+func TestGetPhotoMeta_Backfill(t *testing.T) {
+	db, err := New(t.TempDir())
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	defer db.Close()
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 3, 2))); err != nil {
+		t.Fatalf("png.Encode() failed: %v", err)
+	}
+	if err := db.AddPhoto(1, 1, buf.Bytes()); err != nil {
+		t.Fatalf("AddPhoto() failed: %v", err)
+	}
+
+	// Replace the metadata with a version 1 value, 50 bytes without dimensions
+	createdAt := time.Date(2021, 6, 1, 12, 30, 0, 0, time.UTC)
+	v1 := manul.EncodeMeta(manul.PhotoMeta{Format: manul.FormatPNG, CreatedAt: createdAt})[:50]
+	v1[0] = 1
+	key := db.generateKey(1, 1)
+	err = db.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(metaBucket)).Put(key, v1)
+	})
+	if err != nil {
+		t.Fatalf("Failed to store version 1 metadata: %v", err)
+	}
+
+	// GetPhotoInfo returns the stored fields only and does not backfill
+	info, err := db.GetPhotoInfo(1, 1)
+	if err != nil {
+		t.Fatalf("GetPhotoInfo() failed: %v", err)
+	}
+	if info.HasDimensions() || info.Format != manul.FormatPNG || !info.CreatedAt.Equal(createdAt) {
+		t.Errorf("GetPhotoInfo() = %s %dx%d created at %v, want png without dimensions created at %v", info.Format, info.Width, info.Height, info.CreatedAt, createdAt)
+	}
+
+	meta, err := db.GetPhotoMeta(1, 1)
+	if err != nil {
+		t.Fatalf("GetPhotoMeta() failed: %v", err)
+	}
+	if meta.Width != 3 || meta.Height != 2 || !meta.CreatedAt.Equal(createdAt) {
+		t.Errorf("GetPhotoMeta() = %dx%d created at %v, want 3x2 created at %v", meta.Width, meta.Height, meta.CreatedAt, createdAt)
+	}
+
+	var stored []byte
+	db.db.View(func(tx *bolt.Tx) error {
+		stored = append(stored, tx.Bucket([]byte(metaBucket)).Get(key)...)
+		return nil
+	})
+	if !manul.MetaCurrent(stored) {
+		t.Errorf("Metadata was not backfilled, stored value has %d bytes", len(stored))
+	}
+}
+
+func TestStoreMeta_Changed(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(db *FileTreeDB) error
+	}{
+		{"deleted", func(db *FileTreeDB) error {
+			_, err := db.DeletePhotos([]manul.PhotoKey{{CatID: 1, PhotoID: 1}})
+			return err
+		}},
+		{"replaced", func(db *FileTreeDB) error {
+			return db.AddPhoto(1, 1, []byte("replaced photo"))
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, err := New(t.TempDir())
+			if err != nil {
+				t.Fatalf("New() failed: %v", err)
+			}
+			defer db.Close()
+
+			if err := db.AddPhoto(1, 1, []byte("photo")); err != nil {
+				t.Fatalf("AddPhoto() failed: %v", err)
+			}
+			old, err := db.metaValue(1, 1)
+			if err != nil {
+				t.Fatalf("metaValue() failed: %v", err)
+			}
+
+			// The photo changes between reading the data and storing the backfill
+			store := db.storeMeta(db.generateKey(1, 1), old)
+			if err := tt.change(db); err != nil {
+				t.Fatalf("Changing the photo failed: %v", err)
+			}
+			want, _ := db.metaValue(1, 1)
+			if err := store(manul.NewMetaValue([]byte("photo"), time.Time{})); err != nil {
+				t.Fatalf("storeMeta() failed: %v", err)
+			}
+
+			got, _ := db.metaValue(1, 1)
+			if !bytes.Equal(got, want) {
+				t.Errorf("Meta value after storeMeta() = %x, want %x", got, want)
+			}
+		})
+	}
+}
+
+func TestPhotoExists(t *testing.T) {
+	db, err := New(t.TempDir())
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	defer db.Close()
+
+	if err := db.AddPhoto(1, 1, []byte("photo")); err != nil {
+		t.Fatalf("AddPhoto() failed: %v", err)
+	}
+
+	tests := []struct {
+		catID, photoID uint64
+		want           bool
+	}{
+		{1, 1, true},
+		{1, 2, false},
+		{2, 1, false},
+	}
+	for _, tt := range tests {
+		got, err := db.PhotoExists(tt.catID, tt.photoID)
+		if err != nil {
+			t.Errorf("PhotoExists(%d, %d) failed: %v", tt.catID, tt.photoID, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("PhotoExists(%d, %d) = %v, want %v", tt.catID, tt.photoID, got, tt.want)
+		}
+	}
+}
+
+func TestCountPhotos(t *testing.T) {
+	db, err := New(t.TempDir())
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	defer db.Close()
+
+	for _, key := range []manul.PhotoKey{{CatID: 1, PhotoID: 1}, {CatID: 1, PhotoID: 2}, {CatID: 2, PhotoID: 1}} {
+		if err := db.AddPhoto(key.CatID, key.PhotoID, []byte("photo")); err != nil {
+			t.Fatalf("AddPhoto() failed: %v", err)
+		}
+	}
+
+	for catID, want := range map[uint64]uint64{1: 2, 2: 1, 3: 0} {
+		got, err := db.CountPhotos(catID)
+		if err != nil {
+			t.Fatalf("CountPhotos(%d) failed: %v", catID, err)
+		}
+		if got != want {
+			t.Errorf("CountPhotos(%d) = %d, want %d", catID, got, want)
+		}
+	}
+
+	total, err := db.CountAllPhotos()
+	if err != nil {
+		t.Fatalf("CountAllPhotos() failed: %v", err)
+	}
+	if total != 3 {
+		t.Errorf("CountAllPhotos() = %d, want 3", total)
+	}
+}
+
+func TestGetPhotoIDs(t *testing.T) {
+	db, err := New(t.TempDir())
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	defer db.Close()
+
+	// Cat IDs with shared key bytes, photos of other cats must not match
+	for _, key := range []manul.PhotoKey{{CatID: 0, PhotoID: 7}, {CatID: 1, PhotoID: 1}, {CatID: 1, PhotoID: 2}, {CatID: 2, PhotoID: 1}, {CatID: 256, PhotoID: 1}} {
+		if err := db.AddPhoto(key.CatID, key.PhotoID, []byte("photo")); err != nil {
+			t.Fatalf("AddPhoto() failed: %v", err)
+		}
+	}
+
+	got, err := db.GetPhotoIDs(1)
+	if err != nil {
+		t.Fatalf("GetPhotoIDs() failed: %v", err)
+	}
+	if len(got) != 2 || got[0] != 1 || got[1] != 2 {
+		t.Errorf("GetPhotoIDs(1) = %v, want [1 2]", got)
+	}
+
+	got, err = db.GetPhotoIDs(3)
+	if err != nil {
+		t.Fatalf("GetPhotoIDs() failed: %v", err)
+	}
+	if len(got) != 0 {
+		t.Errorf("GetPhotoIDs(3) = %v, want none", got)
+	}
+}
+
+func TestListIDs(t *testing.T) {
+	db, err := New(t.TempDir())
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	defer db.Close()
+
+	for _, key := range []manul.PhotoKey{{CatID: 1, PhotoID: 1}, {CatID: 1, PhotoID: 2}, {CatID: 1, PhotoID: 3}, {CatID: 2, PhotoID: 1}, {CatID: 5, PhotoID: 1}} {
+		if err := db.AddPhoto(key.CatID, key.PhotoID, []byte("photo")); err != nil {
+			t.Fatalf("AddPhoto() failed: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name string
+		list func() ([]uint64, error)
+		want []uint64
+	}{
+		{"cats from start", func() ([]uint64, error) { return db.ListCatIDs(0, 2) }, []uint64{1, 2}},
+		{"cats from middle", func() ([]uint64, error) { return db.ListCatIDs(3, 10) }, []uint64{5}},
+		{"cats past end", func() ([]uint64, error) { return db.ListCatIDs(6, 10) }, nil},
+		{"photos from start", func() ([]uint64, error) { return db.ListPhotoIDs(1, 0, 2) }, []uint64{1, 2}},
+		{"photos from middle", func() ([]uint64, error) { return db.ListPhotoIDs(1, 3, 2) }, []uint64{3}},
+		{"photos of other cat", func() ([]uint64, error) { return db.ListPhotoIDs(3, 0, 2) }, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.list()
+			if err != nil {
+				t.Fatalf("List failed: %v", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("List = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("List = %v, want %v", got, tt.want)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestForEachPhoto(t *testing.T) {
+	db, err := New(t.TempDir())
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	defer db.Close()
+
+	keys := []manul.PhotoKey{{CatID: 1, PhotoID: 1}, {CatID: 1, PhotoID: 2}, {CatID: 2, PhotoID: 1}, {CatID: 256, PhotoID: 1}}
+	for i := len(keys) - 1; i >= 0; i-- {
+		if err := db.AddPhoto(keys[i].CatID, keys[i].PhotoID, []byte("photo")); err != nil {
+			t.Fatalf("AddPhoto() failed: %v", err)
+		}
+	}
+
+	var got []manul.PhotoKey
+	err = db.ForEachPhoto(func(catID, photoID uint64) error {
+		got = append(got, manul.PhotoKey{CatID: catID, PhotoID: photoID})
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ForEachPhoto() failed: %v", err)
+	}
+	if len(got) != len(keys) {
+		t.Fatalf("ForEachPhoto() visited %v, want %v", got, keys)
+	}
+	for i := range keys {
+		if got[i] != keys[i] {
+			t.Errorf("ForEachPhoto() visited %v, want %v", got, keys)
+			break
+		}
+	}
+
+	// A callback error stops the iteration
+	errStop := errors.New("stop")
+	visited := 0
+	err = db.ForEachPhoto(func(catID, photoID uint64) error {
+		visited++
+		return errStop
+	})
+	if !errors.Is(err, errStop) || visited != 1 {
+		t.Errorf("ForEachPhoto() = %v after %d photos, want %v after 1", err, visited, errStop)
+	}
+}
+
+func TestGetRandomPhoto(t *testing.T) {
+	db, err := New(t.TempDir())
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	defer db.Close()
+
+	if _, _, err := db.GetRandomPhoto(); !errors.Is(err, manul.ErrNoPhotos) {
+		t.Errorf("GetRandomPhoto() of an empty database error = %v, want %v", err, manul.ErrNoPhotos)
+	}
+
+	// Cat IDs 1-3 are picked with equal probability, 2 and 3 both find cat 3
+	keys := []manul.PhotoKey{{CatID: 1, PhotoID: 1}, {CatID: 1, PhotoID: 2}, {CatID: 3, PhotoID: 7}}
+	for _, key := range keys {
+		if err := db.AddPhoto(key.CatID, key.PhotoID, []byte("photo")); err != nil {
+			t.Fatalf("AddPhoto() failed: %v", err)
+		}
+	}
+
+	seen := make(map[manul.PhotoKey]int)
+	for i := 0; i < 300; i++ {
+		catID, photoID, err := db.GetRandomPhoto()
+		if err != nil {
+			t.Fatalf("GetRandomPhoto() failed: %v", err)
+		}
+		seen[manul.PhotoKey{CatID: catID, PhotoID: photoID}]++
+	}
+	if len(seen) != len(keys) {
+		t.Errorf("GetRandomPhoto() returned %v, want each of %v", seen, keys)
+	}
+
+	// The largest IDs have no next cat or photo key
+	if err := db.AddPhoto(math.MaxUint64, math.MaxUint64, []byte("photo")); err != nil {
+		t.Fatalf("AddPhoto() failed: %v", err)
+	}
+	for i := 0; i < 10; i++ {
+		catID, photoID, err := db.GetRandomPhoto()
+		if err != nil {
+			t.Fatalf("GetRandomPhoto() failed: %v", err)
+		}
+		if exists, _ := db.PhotoExists(catID, photoID); !exists {
+			t.Errorf("GetRandomPhoto() = %d, %d, not a stored photo", catID, photoID)
+		}
+	}
+}
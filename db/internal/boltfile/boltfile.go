@@ -0,0 +1,27 @@
+// Package boltfile opens the bbolt files of the bolt and filetree databases
+package boltfile
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/mhbvr/manul"
+	bolt "go.etcd.io/bbolt"
+	bolterrors "go.etcd.io/bbolt/errors"
+)
+
+// Open opens a bbolt file, returning manul.ErrDatabaseLocked if the lock
+// held by another process is not released within timeout. Zero timeout
+// waits forever.
+func Open(path string, mode os.FileMode, readOnly bool, timeout time.Duration) (*bolt.DB, error) {
+	db, err := bolt.Open(path, mode, &bolt.Options{ReadOnly: readOnly, Timeout: timeout})
+	if errors.Is(err, bolterrors.ErrTimeout) {
+		return nil, fmt.Errorf("failed to open bbolt database %s: %w", path, manul.ErrDatabaseLocked)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open bbolt database: %w", err)
+	}
+	return db, nil
+}
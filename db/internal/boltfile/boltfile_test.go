@@ -0,0 +1,30 @@
+package boltfile
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mhbvr/manul"
+)
+
+func TestOpen_Locked(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "meta")
+	db, err := Open(path, 0600, false, 0)
+	if err != nil {
+		t.Fatalf("Open() failed: %v", err)
+	}
+	defer db.Close()
+
+	_, err = Open(path, 0600, false, 10*time.Millisecond)
+	if !errors.Is(err, manul.ErrDatabaseLocked) {
+		t.Errorf("Open() of a locked file error = %v, want manul.ErrDatabaseLocked", err)
+	}
+
+	// Errors other than the lock are returned as is
+	_, err = Open(filepath.Join(t.TempDir(), "missing", "meta"), 0600, false, 10*time.Millisecond)
+	if err == nil || errors.Is(err, manul.ErrDatabaseLocked) {
+		t.Errorf("Open() in a missing directory error = %v, want an open error", err)
+	}
+}
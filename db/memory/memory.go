@@ -0,0 +1,247 @@
+package memory
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"sort"
+	"sync"
+
+	"github.com/mhbvr/manul"
+)
+
+type photo struct {
+	data []byte
+	meta manul.PhotoMeta
+}
+
+// MemoryDB implements DBReader and DBWriter interfaces keeping all photos
+// in memory. It is meant for tests, nothing is persisted.
+type MemoryDB struct {
+	mu     sync.RWMutex
+	photos map[manul.PhotoKey]photo
+}
+
+// New creates an empty MemoryDB
+func New() *MemoryDB {
+	return &MemoryDB{
+		photos: make(map[manul.PhotoKey]photo),
+	}
+}
+
+func (m *MemoryDB) Close() error {
+	return nil
+}
+
+func (m *MemoryDB) AddPhoto(catID, photoID uint64, photoData []byte) error {
+	return m.AddPhotosBatch([]manul.PhotoItem{{CatID: catID, PhotoID: photoID, PhotoData: photoData}})
+}
+
+func (m *MemoryDB) AddPhotosBatch(photos []manul.PhotoItem) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, item := range photos {
+		meta := manul.NewPhotoMeta(item.PhotoData)
+		meta.CreatedAt = item.CreatedAt
+		m.photos[manul.PhotoKey{CatID: item.CatID, PhotoID: item.PhotoID}] = photo{
+			data: append([]byte(nil), item.PhotoData...),
+			meta: meta,
+		}
+	}
+	return nil
+}
+
+func (m *MemoryDB) DeletePhotos(keys []manul.PhotoKey) ([]bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	deleted := make([]bool, len(keys))
+	for i, key := range keys {
+		if _, ok := m.photos[key]; ok {
+			delete(m.photos, key)
+			deleted[i] = true
+		}
+	}
+	return deleted, nil
+}
+
+func (m *MemoryDB) DeleteCat(catID uint64) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	count := 0
+	for key := range m.photos {
+		if key.CatID == catID {
+			delete(m.photos, key)
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (m *MemoryDB) GetAllCatIDs() ([]uint64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	catIdsMap := make(map[uint64]bool)
+	for key := range m.photos {
+		catIdsMap[key.CatID] = true
+	}
+
+	var catIds []uint64
+	for catID := range catIdsMap {
+		catIds = append(catIds, catID)
+	}
+	sort.Slice(catIds, func(i, j int) bool { return catIds[i] < catIds[j] })
+	return catIds, nil
+}
+
+func (m *MemoryDB) GetPhotoIDs(catID uint64) ([]uint64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var photoIds []uint64
+	for key := range m.photos {
+		if key.CatID == catID {
+			photoIds = append(photoIds, key.PhotoID)
+		}
+	}
+	sort.Slice(photoIds, func(i, j int) bool { return photoIds[i] < photoIds[j] })
+	return photoIds, nil
+}
+
+func (m *MemoryDB) ListCatIDs(startCatID uint64, limit int) ([]uint64, error) {
+	catIds, err := m.GetAllCatIDs()
+	if err != nil {
+		return nil, err
+	}
+	return page(catIds, startCatID, limit), nil
+}
+
+func (m *MemoryDB) ListPhotoIDs(catID, startPhotoID uint64, limit int) ([]uint64, error) {
+	photoIds, err := m.GetPhotoIDs(catID)
+	if err != nil {
+		return nil, err
+	}
+	return page(photoIds, startPhotoID, limit), nil
+}
+
+// page returns up to limit sorted IDs starting from start
+func page(ids []uint64, start uint64, limit int) []uint64 {
+	i := sort.Search(len(ids), func(i int) bool { return ids[i] >= start })
+	ids = ids[i:]
+	if len(ids) > limit {
+		ids = ids[:limit]
+	}
+	return ids
+}
+
+func (m *MemoryDB) CountPhotos(catID uint64) (uint64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var count uint64
+	for key := range m.photos {
+		if key.CatID == catID {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (m *MemoryDB) CountAllPhotos() (uint64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return uint64(len(m.photos)), nil
+}
+
+// ForEachPhoto calls fn without holding the lock, on the photos stored
+// when it was called
+func (m *MemoryDB) ForEachPhoto(fn func(catID, photoID uint64) error) error {
+	m.mu.RLock()
+	keys := make([]manul.PhotoKey, 0, len(m.photos))
+	for key := range m.photos {
+		keys = append(keys, key)
+	}
+	m.mu.RUnlock()
+
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].CatID != keys[j].CatID {
+			return keys[i].CatID < keys[j].CatID
+		}
+		return keys[i].PhotoID < keys[j].PhotoID
+	})
+	for _, key := range keys {
+		if err := fn(key.CatID, key.PhotoID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetRandomPhoto picks a uniformly random photo
+func (m *MemoryDB) GetRandomPhoto() (catID, photoID uint64, err error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if len(m.photos) == 0 {
+		return 0, 0, manul.ErrNoPhotos
+	}
+	skip := rand.IntN(len(m.photos))
+	for key := range m.photos {
+		if skip == 0 {
+			return key.CatID, key.PhotoID, nil
+		}
+		skip--
+	}
+	return 0, 0, manul.ErrNoPhotos
+}
+
+func (m *MemoryDB) get(catID, photoID uint64) (photo, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	p, ok := m.photos[manul.PhotoKey{CatID: catID, PhotoID: photoID}]
+	if !ok {
+		return photo{}, fmt.Errorf("photo with cat_id=%d, photo_id=%d not found in database", catID, photoID)
+	}
+	return p, nil
+}
+
+func (m *MemoryDB) GetPhotoData(catID, photoID uint64) ([]byte, error) {
+	p, err := m.get(catID, photoID)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(nil), p.data...), nil
+}
+
+func (m *MemoryDB) GetPhotoFormat(catID, photoID uint64) (string, error) {
+	meta, err := m.GetPhotoMeta(catID, photoID)
+	if err != nil {
+		return "", err
+	}
+	return meta.Format.String(), nil
+}
+
+func (m *MemoryDB) GetPhotoMeta(catID, photoID uint64) (manul.PhotoMeta, error) {
+	p, err := m.get(catID, photoID)
+	if err != nil {
+		return manul.PhotoMeta{}, err
+	}
+	return p.meta, nil
+}
+
+// GetPhotoInfo returns the same metadata as GetPhotoMeta, it is always
+// stored in memory
+func (m *MemoryDB) GetPhotoInfo(catID, photoID uint64) (manul.PhotoMeta, error) {
+	return m.GetPhotoMeta(catID, photoID)
+}
+
+func (m *MemoryDB) PhotoExists(catID, photoID uint64) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	_, ok := m.photos[manul.PhotoKey{CatID: catID, PhotoID: photoID}]
+	return ok, nil
+}
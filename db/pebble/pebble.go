@@ -1,8 +1,14 @@
 package pebble
 
 import (
+	"bytes"
 	"encoding/binary"
+	"errors"
 	"fmt"
+	"math"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/cockroachdb/pebble"
 	"github.com/mhbvr/manul"
@@ -13,16 +19,70 @@ const (
 	photoPrefix = "photo:"
 )
 
+// lockRetryInterval is the delay between attempts to take a locked database
+const lockRetryInterval = 100 * time.Millisecond
+
+// Option configures opening of a PebbleDB
+type Option func(*options)
+
+type options struct {
+	timeout time.Duration
+}
+
+// WithTimeout sets how long to wait for the database lock.
+// Zero (the default) waits forever.
+func WithTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.timeout = timeout
+	}
+}
+
+// isLockError reports whether err means that the pebble LOCK file is held
+// by another process. Other errors, e.g. EACCES for a LOCK file without
+// permissions, fail the open immediately.
+func isLockError(err error) bool {
+	return errors.Is(err, syscall.EAGAIN) || errors.Is(err, syscall.EWOULDBLOCK)
+}
+
+// openPebble opens a pebble database retrying while it is locked by
+// another process, returns manul.ErrDatabaseLocked after the timeout
+func openPebble(dbPath string, pebbleOpts *pebble.Options, opts []Option) (*pebble.DB, error) {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	deadline := time.Now().Add(o.timeout)
+	for {
+		db, err := pebble.Open(dbPath, pebbleOpts)
+		if err == nil {
+			return db, nil
+		}
+		if !isLockError(err) {
+			return nil, fmt.Errorf("failed to open pebble database: %w", err)
+		}
+		if o.timeout > 0 && time.Now().After(deadline) {
+			return nil, fmt.Errorf("failed to open pebble database %s: %w", dbPath, manul.ErrDatabaseLocked)
+		}
+		time.Sleep(lockRetryInterval)
+	}
+}
+
 // PebbleDB implements DBWriter and DBReader interfaces using Pebble key-value storage
 type PebbleDB struct {
-	db *pebble.DB
+	db       *pebble.DB
+	readOnly bool
+
+	// writeMu serializes batch commits with the meta backfill, which has
+	// no transaction to compare and set the meta value in
+	writeMu sync.Mutex
 }
 
 // New creates a new PebbleDB for writing
-func New(dbPath string) (*PebbleDB, error) {
-	db, err := pebble.Open(dbPath, &pebble.Options{})
+func New(dbPath string, opts ...Option) (*PebbleDB, error) {
+	db, err := openPebble(dbPath, &pebble.Options{}, opts)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open pebble database: %w", err)
+		return nil, err
 	}
 
 	return &PebbleDB{
@@ -31,17 +91,18 @@ func New(dbPath string) (*PebbleDB, error) {
 }
 
 // NewReader creates a new PebbleDB for reading (read-only mode)
-func NewReader(dbPath string) (*PebbleDB, error) {
-	opts := &pebble.Options{
+func NewReader(dbPath string, opts ...Option) (*PebbleDB, error) {
+	pebbleOpts := &pebble.Options{
 		ReadOnly: true,
 	}
-	db, err := pebble.Open(dbPath, opts)
+	db, err := openPebble(dbPath, pebbleOpts, opts)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open pebble database: %w", err)
+		return nil, err
 	}
 
 	return &PebbleDB{
-		db: db,
+		db:       db,
+		readOnly: true,
 	}, nil
 }
 
@@ -87,7 +148,7 @@ func (p *PebbleDB) AddPhoto(catID, photoID uint64, photoData []byte) error {
 
 	// Add metadata entry
 	metaKey := p.metaKey(catID, photoID)
-	if err := batch.Set(metaKey, []byte{}, pebble.Sync); err != nil {
+	if err := batch.Set(metaKey, manul.NewMetaValue(photoData, time.Time{}), pebble.Sync); err != nil {
 		return fmt.Errorf("failed to set metadata: %w", err)
 	}
 
@@ -97,36 +158,144 @@ func (p *PebbleDB) AddPhoto(catID, photoID uint64, photoData []byte) error {
 		return fmt.Errorf("failed to set photo data: %w", err)
 	}
 
-	if err := batch.Commit(pebble.Sync); err != nil {
+	if err := p.commit(batch); err != nil {
 		return fmt.Errorf("failed to commit batch: %w", err)
 	}
 
 	return nil
 }
 
+// commit commits batch holding writeMu
+func (p *PebbleDB) commit(batch *pebble.Batch) error {
+	p.writeMu.Lock()
+	defer p.writeMu.Unlock()
+	return batch.Commit(pebble.Sync)
+}
+
 func (p *PebbleDB) AddPhotosBatch(photos []manul.PhotoItem) error {
-	batch := p.db.NewBatch()
+	batch, err := p.NewBatch()
+	if err != nil {
+		return err
+	}
+	for _, photo := range photos {
+		if err := batch.Add(photo); err != nil {
+			batch.Abort()
+			return err
+		}
+	}
+	return batch.Commit()
+}
+
+// pebbleBatch adds photos to a pebble batch, which copies the added data
+type pebbleBatch struct {
+	p     *PebbleDB
+	batch *pebble.Batch
+}
+
+// NewBatch starts a pebble batch, see manul.BatchWriter
+func (p *PebbleDB) NewBatch() (manul.Batch, error) {
+	return &pebbleBatch{p: p, batch: p.db.NewBatch()}, nil
+}
+
+func (b *pebbleBatch) Add(photo manul.PhotoItem) error {
+	// Add metadata entry
+	metaKey := b.p.metaKey(photo.CatID, photo.PhotoID)
+	if err := b.batch.Set(metaKey, manul.NewMetaValue(photo.PhotoData, photo.CreatedAt), pebble.NoSync); err != nil {
+		return fmt.Errorf("failed to set metadata for cat_id=%d, photo_id=%d: %w", photo.CatID, photo.PhotoID, err)
+	}
+
+	// Add photo data
+	photoKey := b.p.photoKey(photo.CatID, photo.PhotoID)
+	if err := b.batch.Set(photoKey, photo.PhotoData, pebble.NoSync); err != nil {
+		return fmt.Errorf("failed to set photo data for cat_id=%d, photo_id=%d: %w", photo.CatID, photo.PhotoID, err)
+	}
+
+	return nil
+}
+
+func (b *pebbleBatch) Commit() error {
+	defer b.batch.Close()
+	if err := b.p.commit(b.batch); err != nil {
+		return fmt.Errorf("failed to commit batch: %w", err)
+	}
+	return nil
+}
+
+func (b *pebbleBatch) Abort() {
+	b.batch.Close()
+}
+
+func (p *PebbleDB) DeletePhotos(keys []manul.PhotoKey) ([]bool, error) {
+	deleted := make([]bool, len(keys))
+
+	// Indexed batch so keys deleted earlier in the batch are not counted twice
+	batch := p.db.NewIndexedBatch()
 	defer batch.Close()
 
-	for _, photo := range photos {
-		// Add metadata entry
-		metaKey := p.metaKey(photo.CatID, photo.PhotoID)
-		if err := batch.Set(metaKey, []byte{}, pebble.NoSync); err != nil {
-			return fmt.Errorf("failed to set metadata for cat_id=%d, photo_id=%d: %w", photo.CatID, photo.PhotoID, err)
+	for i, photoKey := range keys {
+		metaKey := p.metaKey(photoKey.CatID, photoKey.PhotoID)
+
+		_, closer, err := batch.Get(metaKey)
+		if err == pebble.ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to get metadata for cat_id=%d, photo_id=%d: %w", photoKey.CatID, photoKey.PhotoID, err)
 		}
+		closer.Close()
 
-		// Add photo data
-		photoKey := p.photoKey(photo.CatID, photo.PhotoID)
-		if err := batch.Set(photoKey, photo.PhotoData, pebble.NoSync); err != nil {
-			return fmt.Errorf("failed to set photo data for cat_id=%d, photo_id=%d: %w", photo.CatID, photo.PhotoID, err)
+		if err := batch.Delete(metaKey, pebble.NoSync); err != nil {
+			return nil, fmt.Errorf("failed to delete metadata for cat_id=%d, photo_id=%d: %w", photoKey.CatID, photoKey.PhotoID, err)
 		}
+		if err := batch.Delete(p.photoKey(photoKey.CatID, photoKey.PhotoID), pebble.NoSync); err != nil {
+			return nil, fmt.Errorf("failed to delete photo data for cat_id=%d, photo_id=%d: %w", photoKey.CatID, photoKey.PhotoID, err)
+		}
+		deleted[i] = true
 	}
 
-	if err := batch.Commit(pebble.Sync); err != nil {
-		return fmt.Errorf("failed to commit batch: %w", err)
+	if err := p.commit(batch); err != nil {
+		return nil, fmt.Errorf("failed to commit batch: %w", err)
 	}
 
-	return nil
+	return deleted, nil
+}
+
+func (p *PebbleDB) DeleteCat(catID uint64) (int, error) {
+	lowerBound := p.metaKey(catID, 0)
+	upperBound := p.metaKey(catID, math.MaxUint64)
+
+	iter, err := p.db.NewIter(&pebble.IterOptions{
+		LowerBound: lowerBound,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to create iterator: %w", err)
+	}
+	defer iter.Close()
+
+	batch := p.db.NewBatch()
+	defer batch.Close()
+
+	count := 0
+	for iter.First(); iter.Valid() && bytes.Compare(iter.Key(), upperBound) <= 0; iter.Next() {
+		_, photoID := p.parseKey(iter.Key()[len(metaPrefix):])
+		if err := batch.Delete(p.metaKey(catID, photoID), pebble.NoSync); err != nil {
+			return 0, fmt.Errorf("failed to delete metadata for cat_id=%d, photo_id=%d: %w", catID, photoID, err)
+		}
+		if err := batch.Delete(p.photoKey(catID, photoID), pebble.NoSync); err != nil {
+			return 0, fmt.Errorf("failed to delete photo data for cat_id=%d, photo_id=%d: %w", catID, photoID, err)
+		}
+		count++
+	}
+
+	if err := iter.Error(); err != nil {
+		return 0, fmt.Errorf("iterator error: %w", err)
+	}
+
+	if err := p.commit(batch); err != nil {
+		return 0, fmt.Errorf("failed to commit batch: %w", err)
+	}
+
+	return count, nil
 }
 
 func (p *PebbleDB) GetAllCatIDs() ([]uint64, error) {
@@ -163,12 +332,34 @@ func (p *PebbleDB) GetAllCatIDs() ([]uint64, error) {
 	return catIds, nil
 }
 
+// catMetaBounds returns the iterator bounds of the metadata keys of a cat
+func (p *PebbleDB) catMetaBounds(catID uint64) (lower, upper []byte) {
+	lower = make([]byte, len(metaPrefix)+8)
+	copy(lower, metaPrefix)
+	binary.BigEndian.PutUint64(lower[len(metaPrefix):], catID)
+	return lower, prefixUpperBound(lower)
+}
+
+// prefixUpperBound returns the smallest key greater than all keys with
+// the prefix, nil if there is no such key
+func prefixUpperBound(prefix []byte) []byte {
+	upper := append([]byte{}, prefix...)
+	for i := len(upper) - 1; i >= 0; i-- {
+		upper[i]++
+		if upper[i] != 0 {
+			return upper[:i+1]
+		}
+	}
+	return nil
+}
+
 func (p *PebbleDB) GetPhotoIDs(catID uint64) ([]uint64, error) {
 	var photoIds []uint64
 
+	lower, upper := p.catMetaBounds(catID)
 	iter, err := p.db.NewIter(&pebble.IterOptions{
-		LowerBound: []byte(metaPrefix),
-		UpperBound: []byte(metaPrefix + "\xff"),
+		LowerBound: lower,
+		UpperBound: upper,
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to create iterator: %w", err)
@@ -176,15 +367,8 @@ func (p *PebbleDB) GetPhotoIDs(catID uint64) ([]uint64, error) {
 	defer iter.Close()
 
 	for iter.First(); iter.Valid(); iter.Next() {
-		key := iter.Key()
-		// Remove the prefix to get the original key
-		if len(key) >= len(metaPrefix)+16 {
-			baseKey := key[len(metaPrefix):]
-			keyCatID, photoID := p.parseKey(baseKey)
-			if keyCatID == catID {
-				photoIds = append(photoIds, photoID)
-			}
-		}
+		_, photoID := p.parseKey(iter.Key()[len(metaPrefix):])
+		photoIds = append(photoIds, photoID)
 	}
 
 	if err := iter.Error(); err != nil {
@@ -194,6 +378,167 @@ func (p *PebbleDB) GetPhotoIDs(catID uint64) ([]uint64, error) {
 	return photoIds, nil
 }
 
+func (p *PebbleDB) ListCatIDs(startCatID uint64, limit int) ([]uint64, error) {
+	var catIds []uint64
+
+	lower, _ := p.catMetaBounds(startCatID)
+	iter, err := p.db.NewIter(&pebble.IterOptions{
+		LowerBound: lower,
+		UpperBound: prefixUpperBound([]byte(metaPrefix)),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create iterator: %w", err)
+	}
+	defer iter.Close()
+
+	valid := iter.First()
+	for valid && len(catIds) < limit {
+		catID, _ := p.parseKey(iter.Key()[len(metaPrefix):])
+		catIds = append(catIds, catID)
+		// Skip the other photos of the cat, past the last cat the bound
+		// is after all meta keys
+		_, next := p.catMetaBounds(catID)
+		valid = iter.SeekGE(next)
+	}
+
+	if err := iter.Error(); err != nil {
+		return nil, fmt.Errorf("iterator error: %w", err)
+	}
+
+	return catIds, nil
+}
+
+func (p *PebbleDB) ListPhotoIDs(catID, startPhotoID uint64, limit int) ([]uint64, error) {
+	var photoIds []uint64
+
+	_, upper := p.catMetaBounds(catID)
+	iter, err := p.db.NewIter(&pebble.IterOptions{
+		LowerBound: p.metaKey(catID, startPhotoID),
+		UpperBound: upper,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create iterator: %w", err)
+	}
+	defer iter.Close()
+
+	for iter.First(); iter.Valid() && len(photoIds) < limit; iter.Next() {
+		_, photoID := p.parseKey(iter.Key()[len(metaPrefix):])
+		photoIds = append(photoIds, photoID)
+	}
+
+	if err := iter.Error(); err != nil {
+		return nil, fmt.Errorf("iterator error: %w", err)
+	}
+
+	return photoIds, nil
+}
+
+func (p *PebbleDB) CountPhotos(catID uint64) (uint64, error) {
+	return p.countKeys(p.catMetaBounds(catID))
+}
+
+func (p *PebbleDB) CountAllPhotos() (uint64, error) {
+	return p.countKeys([]byte(metaPrefix), prefixUpperBound([]byte(metaPrefix)))
+}
+
+// ForEachPhoto iterates the meta keys of a consistent snapshot of the database
+func (p *PebbleDB) ForEachPhoto(fn func(catID, photoID uint64) error) error {
+	iter, err := p.db.NewIter(&pebble.IterOptions{
+		LowerBound: []byte(metaPrefix),
+		UpperBound: prefixUpperBound([]byte(metaPrefix)),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create iterator: %w", err)
+	}
+	defer iter.Close()
+
+	for iter.First(); iter.Valid(); iter.Next() {
+		if err := fn(p.parseKey(iter.Key()[len(metaPrefix):])); err != nil {
+			return err
+		}
+	}
+
+	if err := iter.Error(); err != nil {
+		return fmt.Errorf("iterator error: %w", err)
+	}
+
+	return nil
+}
+
+// GetRandomPhoto picks a random cat ID between the first and last ones, and
+// a random photo ID of the cat at or after it, seeking an iterator instead
+// of reading all keys. Photos after gaps in the IDs are picked more often.
+func (p *PebbleDB) GetRandomPhoto() (catID, photoID uint64, err error) {
+	iter, err := p.db.NewIter(&pebble.IterOptions{
+		LowerBound: []byte(metaPrefix),
+		UpperBound: prefixUpperBound([]byte(metaPrefix)),
+	})
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to create iterator: %w", err)
+	}
+	defer iter.Close()
+
+	// The iterator reads a snapshot, seeks within its keys fail only on errors
+	key := func(valid bool) (catID, photoID uint64, err error) {
+		if !valid {
+			if err := iter.Error(); err != nil {
+				return 0, 0, fmt.Errorf("iterator error: %w", err)
+			}
+			return 0, 0, manul.ErrNoPhotos
+		}
+		catID, photoID = p.parseKey(iter.Key()[len(metaPrefix):])
+		return catID, photoID, nil
+	}
+
+	firstCatID, _, err := key(iter.First())
+	if err != nil {
+		return 0, 0, err
+	}
+	lastCatID, _, err := key(iter.Last())
+	if err != nil {
+		return 0, 0, err
+	}
+
+	// A cat is found at or before the last cat ID
+	lower, _ := p.catMetaBounds(manul.RandomID(firstCatID, lastCatID))
+	catID, firstPhotoID, err := key(iter.SeekGE(lower))
+	if err != nil {
+		return 0, 0, err
+	}
+
+	// The last photo of the cat is the key before the next cat
+	_, upper := p.catMetaBounds(catID)
+	_, lastPhotoID, err := key(iter.SeekLT(upper))
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return key(iter.SeekGE(p.metaKey(catID, manul.RandomID(firstPhotoID, lastPhotoID))))
+}
+
+// countKeys counts the keys in the range [lower, upper)
+func (p *PebbleDB) countKeys(lower, upper []byte) (uint64, error) {
+	iter, err := p.db.NewIter(&pebble.IterOptions{
+		LowerBound: lower,
+		UpperBound: upper,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to create iterator: %w", err)
+	}
+	defer iter.Close()
+
+	var count uint64
+	for iter.First(); iter.Valid(); iter.Next() {
+		count++
+	}
+
+	if err := iter.Error(); err != nil {
+		return 0, fmt.Errorf("iterator error: %w", err)
+	}
+
+	return count, nil
+}
+
 func (p *PebbleDB) GetPhotoData(catID, photoID uint64) ([]byte, error) {
 	photoKey := p.photoKey(catID, photoID)
 	
@@ -211,4 +556,84 @@ func (p *PebbleDB) GetPhotoData(catID, photoID uint64) ([]byte, error) {
 	copy(photoData, data)
 	
 	return photoData, nil
-}
\ No newline at end of file
+}
+
+func (p *PebbleDB) GetPhotoFormat(catID, photoID uint64) (string, error) {
+	meta, err := p.GetPhotoMeta(catID, photoID)
+	if err != nil {
+		return "", err
+	}
+	return meta.Format.String(), nil
+}
+
+func (p *PebbleDB) GetPhotoMeta(catID, photoID uint64) (manul.PhotoMeta, error) {
+	value, err := p.metaValue(catID, photoID)
+	if err != nil {
+		return manul.PhotoMeta{}, err
+	}
+
+	return manul.ResolveMeta(value, func() ([]byte, error) {
+		return p.GetPhotoData(catID, photoID)
+	}, p.storeMeta(p.metaKey(catID, photoID), value))
+}
+
+func (p *PebbleDB) GetPhotoInfo(catID, photoID uint64) (manul.PhotoMeta, error) {
+	value, err := p.metaValue(catID, photoID)
+	if err != nil {
+		return manul.PhotoMeta{}, err
+	}
+	return manul.StoredMeta(value), nil
+}
+
+// metaValue returns a copy of the meta value stored for a photo
+func (p *PebbleDB) metaValue(catID, photoID uint64) ([]byte, error) {
+	v, closer, err := p.db.Get(p.metaKey(catID, photoID))
+	if err != nil {
+		if err == pebble.ErrNotFound {
+			return nil, fmt.Errorf("photo with cat_id=%d, photo_id=%d not found in database", catID, photoID)
+		}
+		return nil, fmt.Errorf("failed to get metadata: %w", err)
+	}
+	defer closer.Close()
+	return append([]byte{}, v...), nil
+}
+
+// storeMeta returns the function storing a recomputed meta value of
+// metaKey, nil if the database is read-only. The value is stored only if
+// metaKey still holds old, so a photo deleted or replaced meanwhile gets
+// no meta back.
+func (p *PebbleDB) storeMeta(metaKey, old []byte) func([]byte) error {
+	if p.readOnly {
+		return nil
+	}
+	return func(value []byte) error {
+		p.writeMu.Lock()
+		defer p.writeMu.Unlock()
+
+		current, closer, err := p.db.Get(metaKey)
+		if err == pebble.ErrNotFound {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to get metadata: %w", err)
+		}
+		unchanged := bytes.Equal(current, old)
+		closer.Close()
+		if !unchanged {
+			return nil
+		}
+		return p.db.Set(metaKey, value, pebble.Sync)
+	}
+}
+
+func (p *PebbleDB) PhotoExists(catID, photoID uint64) (bool, error) {
+	_, closer, err := p.db.Get(p.metaKey(catID, photoID))
+	if err == pebble.ErrNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to get metadata: %w", err)
+	}
+	closer.Close()
+	return true, nil
+}
@@ -0,0 +1,76 @@
+package pebble
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/mhbvr/manul"
+)
+
+func TestIsLockError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{fmt.Errorf("lock: %w", syscall.EAGAIN), true},
+		{fmt.Errorf("lock: %w", syscall.EWOULDBLOCK), true},
+		{&os.PathError{Op: "open", Path: "LOCK", Err: syscall.EACCES}, false},
+		{errors.New("corrupt manifest"), false},
+	}
+	for _, tt := range tests {
+		if got := isLockError(tt.err); got != tt.want {
+			t.Errorf("isLockError(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+func TestStoreMeta_Changed(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(db *PebbleDB) error
+	}{
+		{"deleted", func(db *PebbleDB) error {
+			_, err := db.DeletePhotos([]manul.PhotoKey{{CatID: 1, PhotoID: 1}})
+			return err
+		}},
+		{"replaced", func(db *PebbleDB) error {
+			return db.AddPhoto(1, 1, []byte("replaced photo"))
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, err := New(t.TempDir())
+			if err != nil {
+				t.Fatalf("New() failed: %v", err)
+			}
+			defer db.Close()
+
+			if err := db.AddPhoto(1, 1, []byte("photo")); err != nil {
+				t.Fatalf("AddPhoto() failed: %v", err)
+			}
+			old, err := db.metaValue(1, 1)
+			if err != nil {
+				t.Fatalf("metaValue() failed: %v", err)
+			}
+
+			// The photo changes between reading the data and storing the backfill
+			store := db.storeMeta(db.metaKey(1, 1), old)
+			if err := tt.change(db); err != nil {
+				t.Fatalf("Changing the photo failed: %v", err)
+			}
+			want, _ := db.metaValue(1, 1)
+			if err := store(manul.NewMetaValue([]byte("photo"), time.Time{})); err != nil {
+				t.Fatalf("storeMeta() failed: %v", err)
+			}
+
+			got, _ := db.metaValue(1, 1)
+			if !bytes.Equal(got, want) {
+				t.Errorf("Meta value after storeMeta() = %x, want %x", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,211 @@
+package s3
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// emptySHA256 is the payload hash of requests without a body
+const emptySHA256 = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// retryDelay is the delay before the first retry, doubled for each next one
+const retryDelay = 100 * time.Millisecond
+
+var errNoSuchKey = errors.New("NoSuchKey: the specified key does not exist")
+
+// credentials are the access key requests are signed with, requests are
+// anonymous if accessKeyID is empty
+type credentials struct {
+	accessKeyID     string
+	secretAccessKey string
+	sessionToken    string
+}
+
+// client gets objects of a bucket with the S3 REST API
+type client struct {
+	bucket         string
+	endpoint       string
+	region         string
+	credentials    credentials
+	requestTimeout time.Duration
+	retries        int
+	httpClient     *http.Client
+}
+
+func newClient(bucket string, o *options) *client {
+	return &client{
+		bucket:         bucket,
+		endpoint:       o.endpoint,
+		region:         o.region,
+		credentials:    o.credentials,
+		requestTimeout: o.requestTimeout,
+		retries:        o.retries,
+		httpClient:     o.httpClient,
+	}
+}
+
+// objectURL returns the URL of an object, key must be escaped
+func (c *client) objectURL(escapedKey string) string {
+	if c.endpoint == "" {
+		return fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", c.bucket, c.region, escapedKey)
+	}
+	return fmt.Sprintf("%s/%s/%s", c.endpoint, escapePath(c.bucket), escapedKey)
+}
+
+// s3Error is the error response body of S3
+type s3Error struct {
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+// get returns the data of an object, retrying failed attempts until ctx is
+// done. A missing object is errNoSuchKey.
+func (c *client) get(ctx context.Context, key string) ([]byte, error) {
+	for attempt := 0; ; attempt++ {
+		data, retry, err := c.getOnce(ctx, key)
+		if err == nil || !retry || attempt >= c.retries {
+			return data, err
+		}
+
+		timer := time.NewTimer(retryDelay << attempt)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, fmt.Errorf("%w, last error: %v", ctx.Err(), err)
+		case <-timer.C:
+		}
+	}
+}
+
+// getOnce makes a single get request limited by the request timeout,
+// retry reports whether a failure may be temporary
+func (c *client) getOnce(ctx context.Context, key string) (data []byte, retry bool, err error) {
+	reqCtx := ctx
+	if c.requestTimeout > 0 {
+		var cancel context.CancelFunc
+		reqCtx, cancel = context.WithTimeout(ctx, c.requestTimeout)
+		defer cancel()
+	}
+
+	req, err := http.NewRequestWithContext(reqCtx, http.MethodGet, c.objectURL(escapePath(key)), nil)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to create request: %w", err)
+	}
+	c.sign(req, time.Now())
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		// Timed out attempts are retried, a done ctx is final
+		return nil, ctx.Err() == nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, ctx.Err() == nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode == http.StatusOK {
+		return body, false, nil
+	}
+
+	var s3Err s3Error
+	xml.Unmarshal(body, &s3Err)
+	if s3Err.Code == "NoSuchKey" {
+		return nil, false, errNoSuchKey
+	}
+	retry = resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests || s3Err.Code == "SlowDown"
+	if s3Err.Code == "" {
+		return nil, retry, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil, retry, fmt.Errorf("%s: %s (status %s)", s3Err.Code, s3Err.Message, resp.Status)
+}
+
+// sign adds AWS Signature Version 4 headers to a request without a body,
+// anonymous requests are not signed
+func (c *client) sign(req *http.Request, now time.Time) {
+	if c.credentials.accessKeyID == "" {
+		return
+	}
+
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", emptySHA256)
+	if c.credentials.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", c.credentials.sessionToken)
+	}
+
+	// All headers set so far and the host are signed
+	headers := map[string]string{"host": req.URL.Host}
+	if req.Host != "" {
+		headers["host"] = req.Host
+	}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&canonicalHeaders, "%s:%s\n", name, headers[name])
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		emptySHA256,
+	}, "\n")
+
+	scope := date + "/" + c.region + "/s3/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+c.credentials.secretAccessKey), date)
+	key = hmacSHA256(key, c.region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		c.credentials.accessKeyID, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// escapePath escapes an object key as S3 expects in signed paths, every
+// byte except unreserved characters and slashes is percent-encoded
+func escapePath(path string) string {
+	var b strings.Builder
+	for i := 0; i < len(path); i++ {
+		ch := path[i]
+		if ('A' <= ch && ch <= 'Z') || ('a' <= ch && ch <= 'z') || ('0' <= ch && ch <= '9') ||
+			ch == '-' || ch == '_' || ch == '.' || ch == '~' || ch == '/' {
+			b.WriteByte(ch)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", ch)
+		}
+	}
+	return b.String()
+}
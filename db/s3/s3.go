@@ -0,0 +1,225 @@
+package s3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/mhbvr/manul"
+	"github.com/mhbvr/manul/db/filetree"
+)
+
+// Option configures opening of an S3DB
+type Option func(*options)
+
+type options struct {
+	endpoint       string
+	region         string
+	prefix         string
+	credentials    credentials
+	timeout        time.Duration
+	requestTimeout time.Duration
+	retries        int
+	httpClient     *http.Client
+}
+
+// WithEndpoint sets the S3 endpoint URL, e.g. http://localhost:9000 for
+// MinIO. Objects are then addressed path-style (endpoint/bucket/key).
+// The default is the AWS endpoint of the region, addressed
+// virtual-hosted style (bucket.s3.region.amazonaws.com/key).
+func WithEndpoint(endpoint string) Option {
+	return func(o *options) {
+		o.endpoint = strings.TrimSuffix(endpoint, "/")
+	}
+}
+
+// WithRegion sets the region requests are signed for, us-east-1 by default
+func WithRegion(region string) Option {
+	return func(o *options) {
+		o.region = region
+	}
+}
+
+// WithPrefix sets the key prefix of photo objects
+func WithPrefix(prefix string) Option {
+	return func(o *options) {
+		o.prefix = strings.Trim(prefix, "/")
+	}
+}
+
+// WithCredentials signs requests with an access key, sessionToken is only
+// needed for temporary credentials. Requests are anonymous by default.
+func WithCredentials(accessKeyID, secretAccessKey, sessionToken string) Option {
+	return func(o *options) {
+		o.credentials = credentials{
+			accessKeyID:     accessKeyID,
+			secretAccessKey: secretAccessKey,
+			sessionToken:    sessionToken,
+		}
+	}
+}
+
+// WithTimeout sets how long to wait for the meta file lock.
+// Zero (the default) waits forever.
+func WithTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.timeout = timeout
+	}
+}
+
+// WithRequestTimeout limits the time of a single S3 request attempt,
+// 10 seconds by default
+func WithRequestTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.requestTimeout = timeout
+	}
+}
+
+// WithRetries sets how many times a failed S3 request is retried, 2 by
+// default. Network errors, timed out attempts, throttling and server
+// errors are retried.
+func WithRetries(retries int) Option {
+	return func(o *options) {
+		o.retries = retries
+	}
+}
+
+// WithHTTPClient sets the HTTP client of S3 requests
+func WithHTTPClient(client *http.Client) Option {
+	return func(o *options) {
+		o.httpClient = client
+	}
+}
+
+// S3DB implements DBReader interface using the bbolt meta file of a
+// filetree database for metadata and listings, and an S3 bucket for
+// photo data. The object of a photo is named like its filetree data
+// file, see filetree.PhotoFilename.
+type S3DB struct {
+	meta   *filetree.FileTreeDB
+	client *client
+	prefix string
+}
+
+// NewReader opens the meta file of the filetree database in metaDir
+// read-only, its data directory is not used, and reads photo data from
+// bucket
+func NewReader(metaDir, bucket string, opts ...Option) (*S3DB, error) {
+	o := &options{
+		region:         "us-east-1",
+		requestTimeout: 10 * time.Second,
+		retries:        2,
+		httpClient:     http.DefaultClient,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	if bucket == "" {
+		return nil, fmt.Errorf("S3 bucket must be set")
+	}
+
+	meta, err := filetree.NewReader(metaDir, filetree.WithTimeout(o.timeout))
+	if err != nil {
+		return nil, err
+	}
+
+	return &S3DB{
+		meta:   meta,
+		client: newClient(bucket, o),
+		prefix: o.prefix,
+	}, nil
+}
+
+func (d *S3DB) Close() error {
+	return d.meta.Close()
+}
+
+// objectKey returns the key of the object holding the photo data
+func (d *S3DB) objectKey(catID, photoID uint64) string {
+	if d.prefix == "" {
+		return filetree.PhotoFilename(catID, photoID)
+	}
+	return d.prefix + "/" + filetree.PhotoFilename(catID, photoID)
+}
+
+func (d *S3DB) GetAllCatIDs() ([]uint64, error) {
+	return d.meta.GetAllCatIDs()
+}
+
+func (d *S3DB) GetPhotoIDs(catID uint64) ([]uint64, error) {
+	return d.meta.GetPhotoIDs(catID)
+}
+
+func (d *S3DB) ListCatIDs(startCatID uint64, limit int) ([]uint64, error) {
+	return d.meta.ListCatIDs(startCatID, limit)
+}
+
+func (d *S3DB) ListPhotoIDs(catID, startPhotoID uint64, limit int) ([]uint64, error) {
+	return d.meta.ListPhotoIDs(catID, startPhotoID, limit)
+}
+
+func (d *S3DB) CountPhotos(catID uint64) (uint64, error) {
+	return d.meta.CountPhotos(catID)
+}
+
+func (d *S3DB) CountAllPhotos() (uint64, error) {
+	return d.meta.CountAllPhotos()
+}
+
+func (d *S3DB) ForEachPhoto(fn func(catID, photoID uint64) error) error {
+	return d.meta.ForEachPhoto(fn)
+}
+
+func (d *S3DB) GetRandomPhoto() (catID, photoID uint64, err error) {
+	return d.meta.GetRandomPhoto()
+}
+
+// GetPhotoData reads the photo data from S3, each attempt limited by the
+// request timeout
+func (d *S3DB) GetPhotoData(catID, photoID uint64) ([]byte, error) {
+	return d.GetPhotoDataContext(context.Background(), catID, photoID)
+}
+
+// GetPhotoDataContext reads the photo data from S3, giving up when ctx is
+// done. Photos without meta entries are not requested.
+func (d *S3DB) GetPhotoDataContext(ctx context.Context, catID, photoID uint64) ([]byte, error) {
+	exists, err := d.meta.PhotoExists(catID, photoID)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, fmt.Errorf("photo with cat_id=%d, photo_id=%d not found in database", catID, photoID)
+	}
+
+	data, err := d.client.get(ctx, d.objectKey(catID, photoID))
+	if errors.Is(err, errNoSuchKey) {
+		return nil, fmt.Errorf("photo with cat_id=%d, photo_id=%d not found in database: %w", catID, photoID, err)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get photo cat_id=%d, photo_id=%d from S3: %w", catID, photoID, err)
+	}
+	return data, nil
+}
+
+func (d *S3DB) GetPhotoFormat(catID, photoID uint64) (string, error) {
+	return d.meta.GetPhotoFormat(catID, photoID)
+}
+
+// GetPhotoMeta returns the metadata stored in the meta file. Photos added
+// before metadata was stored have no data file to compute it from and
+// fail.
+func (d *S3DB) GetPhotoMeta(catID, photoID uint64) (manul.PhotoMeta, error) {
+	return d.meta.GetPhotoMeta(catID, photoID)
+}
+
+func (d *S3DB) GetPhotoInfo(catID, photoID uint64) (manul.PhotoMeta, error) {
+	return d.meta.GetPhotoInfo(catID, photoID)
+}
+
+func (d *S3DB) PhotoExists(catID, photoID uint64) (bool, error) {
+	return d.meta.PhotoExists(catID, photoID)
+}
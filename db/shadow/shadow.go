@@ -0,0 +1,279 @@
+package shadow
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+
+	"github.com/mhbvr/manul"
+)
+
+// Result is the outcome of a shadow read of a photo
+type Result int
+
+const (
+	// Match means the secondary returned the same data as the primary
+	Match Result = iota
+	// Mismatch means the secondary returned different data
+	Mismatch
+	// SecondaryError means reading the photo from the secondary failed
+	SecondaryError
+	// Dropped means the photo was not compared because the queue was full
+	Dropped
+)
+
+var resultNames = map[Result]string{
+	Match:          "match",
+	Mismatch:       "mismatch",
+	SecondaryError: "secondary_error",
+	Dropped:        "dropped",
+}
+
+func (r Result) String() string {
+	return resultNames[r]
+}
+
+// Option configures a ShadowDB
+type Option func(*options)
+
+type options struct {
+	workers   int
+	queueSize int
+	observer  func(Result)
+	logger    *slog.Logger
+}
+
+// WithWorkers sets the number of goroutines reading from the secondary,
+// 2 by default
+func WithWorkers(workers int) Option {
+	return func(o *options) {
+		o.workers = workers
+	}
+}
+
+// WithQueueSize sets how many photos can wait for a shadow read, 1000 by
+// default. Photos read while the queue is full are not compared.
+func WithQueueSize(size int) Option {
+	return func(o *options) {
+		o.queueSize = size
+	}
+}
+
+// WithObserver sets a function called with the result of each shadow
+// read, e.g. to export metrics. It is called from the worker goroutines,
+// or from the reading goroutine for Dropped.
+func WithObserver(fn func(Result)) Option {
+	return func(o *options) {
+		o.observer = fn
+	}
+}
+
+// WithLogger sets the logger of failed and mismatching shadow reads,
+// slog.Default() by default
+func WithLogger(logger *slog.Logger) Option {
+	return func(o *options) {
+		o.logger = logger
+	}
+}
+
+// Stats counts the results of shadow reads
+type Stats struct {
+	Matches         uint64
+	Mismatches      uint64
+	SecondaryErrors uint64
+	Dropped         uint64
+}
+
+// shadowRead is a photo served from the primary waiting for comparison
+type shadowRead struct {
+	catID   uint64
+	photoID uint64
+	data    []byte
+}
+
+// ShadowDB implements DBReader interface serving all reads from a primary
+// database. Photo data read from the primary is also read from a secondary
+// database in the background and compared by checksum, to validate a new
+// backend with production traffic without affecting responses.
+type ShadowDB struct {
+	primary   manul.DBReader
+	secondary manul.DBReader
+	observer  func(Result)
+	logger    *slog.Logger
+
+	mu     sync.RWMutex // Guards closed and sends to queue
+	closed bool
+	queue  chan shadowRead
+	wg     sync.WaitGroup
+
+	counts [Dropped + 1]atomic.Uint64
+}
+
+// New creates a ShadowDB serving reads from primary and comparing photo
+// data with secondary. ShadowDB takes ownership of the databases and
+// closes them on Close.
+func New(primary, secondary manul.DBReader, opts ...Option) *ShadowDB {
+	o := &options{workers: 2, queueSize: 1000, logger: slog.Default()}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	s := &ShadowDB{
+		primary:   primary,
+		secondary: secondary,
+		observer:  o.observer,
+		logger:    o.logger,
+		queue:     make(chan shadowRead, o.queueSize),
+	}
+	for i := 0; i < max(o.workers, 1); i++ {
+		s.wg.Add(1)
+		go s.compareLoop()
+	}
+	return s
+}
+
+func (s *ShadowDB) GetAllCatIDs() ([]uint64, error) {
+	return s.primary.GetAllCatIDs()
+}
+
+func (s *ShadowDB) GetPhotoIDs(catID uint64) ([]uint64, error) {
+	return s.primary.GetPhotoIDs(catID)
+}
+
+func (s *ShadowDB) ListCatIDs(startCatID uint64, limit int) ([]uint64, error) {
+	return s.primary.ListCatIDs(startCatID, limit)
+}
+
+func (s *ShadowDB) ListPhotoIDs(catID, startPhotoID uint64, limit int) ([]uint64, error) {
+	return s.primary.ListPhotoIDs(catID, startPhotoID, limit)
+}
+
+func (s *ShadowDB) CountPhotos(catID uint64) (uint64, error) {
+	return s.primary.CountPhotos(catID)
+}
+
+func (s *ShadowDB) CountAllPhotos() (uint64, error) {
+	return s.primary.CountAllPhotos()
+}
+
+func (s *ShadowDB) ForEachPhoto(fn func(catID, photoID uint64) error) error {
+	return s.primary.ForEachPhoto(fn)
+}
+
+func (s *ShadowDB) GetRandomPhoto() (catID, photoID uint64, err error) {
+	return manul.GetRandomPhoto(s.primary)
+}
+
+// GetPhotoData returns the photo from the primary and queues it for
+// comparison with the secondary. The returned data is hashed in the
+// background and must not be modified.
+func (s *ShadowDB) GetPhotoData(catID, photoID uint64) ([]byte, error) {
+	data, err := s.primary.GetPhotoData(catID, photoID)
+	if err == nil {
+		s.enqueue(catID, photoID, data)
+	}
+	return data, err
+}
+
+// GetPhotoDataContext is GetPhotoData giving up when ctx is done if the
+// primary is a manul.ContextReader. The shadow read is not canceled.
+func (s *ShadowDB) GetPhotoDataContext(ctx context.Context, catID, photoID uint64) ([]byte, error) {
+	data, err := manul.GetPhotoDataContext(ctx, s.primary, catID, photoID)
+	if err == nil {
+		s.enqueue(catID, photoID, data)
+	}
+	return data, err
+}
+
+func (s *ShadowDB) GetPhotoFormat(catID, photoID uint64) (string, error) {
+	return s.primary.GetPhotoFormat(catID, photoID)
+}
+
+func (s *ShadowDB) GetPhotoMeta(catID, photoID uint64) (manul.PhotoMeta, error) {
+	return s.primary.GetPhotoMeta(catID, photoID)
+}
+
+func (s *ShadowDB) GetPhotoInfo(catID, photoID uint64) (manul.PhotoMeta, error) {
+	return s.primary.GetPhotoInfo(catID, photoID)
+}
+
+func (s *ShadowDB) PhotoExists(catID, photoID uint64) (bool, error) {
+	return s.primary.PhotoExists(catID, photoID)
+}
+
+// Stats returns the results of shadow reads so far
+func (s *ShadowDB) Stats() Stats {
+	return Stats{
+		Matches:         s.counts[Match].Load(),
+		Mismatches:      s.counts[Mismatch].Load(),
+		SecondaryErrors: s.counts[SecondaryError].Load(),
+		Dropped:         s.counts[Dropped].Load(),
+	}
+}
+
+// enqueue queues a photo for comparison without blocking, photos are
+// dropped if the queue is full
+func (s *ShadowDB) enqueue(catID, photoID uint64, data []byte) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		return
+	}
+
+	select {
+	case s.queue <- shadowRead{catID: catID, photoID: photoID, data: data}:
+	default:
+		s.record(Dropped)
+	}
+}
+
+func (s *ShadowDB) compareLoop() {
+	defer s.wg.Done()
+	for read := range s.queue {
+		s.record(s.compare(read))
+	}
+}
+
+// compare reads a photo from the secondary and compares its checksum with
+// the one of the primary data
+func (s *ShadowDB) compare(read shadowRead) Result {
+	data, err := s.secondary.GetPhotoData(read.catID, read.photoID)
+	if err != nil {
+		s.logger.Warn("Shadow read failed", "cat_id", read.catID, "photo_id", read.photoID, "error", err)
+		return SecondaryError
+	}
+
+	primarySum := sha256.Sum256(read.data)
+	secondarySum := sha256.Sum256(data)
+	if primarySum != secondarySum {
+		s.logger.Warn("Shadow read mismatch", "cat_id", read.catID, "photo_id", read.photoID,
+			"primary_bytes", len(read.data), "primary_sha256", fmt.Sprintf("%x", primarySum),
+			"secondary_bytes", len(data), "secondary_sha256", fmt.Sprintf("%x", secondarySum))
+		return Mismatch
+	}
+	return Match
+}
+
+func (s *ShadowDB) record(r Result) {
+	s.counts[r].Add(1)
+	if s.observer != nil {
+		s.observer(r)
+	}
+}
+
+// Close waits for the queued shadow reads and closes both databases
+func (s *ShadowDB) Close() error {
+	s.mu.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.queue)
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+
+	return errors.Join(s.primary.Close(), s.secondary.Close())
+}
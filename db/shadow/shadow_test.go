@@ -0,0 +1,121 @@
+package shadow
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"sync"
+	"testing"
+
+	"github.com/mhbvr/manul"
+	"github.com/mhbvr/manul/db/memory"
+)
+
+func TestShadowDB(t *testing.T) {
+	primary := memory.New()
+	secondary := memory.New()
+	for photoID := uint64(1); photoID <= 3; photoID++ {
+		if err := primary.AddPhoto(1, photoID, []byte("photo")); err != nil {
+			t.Fatalf("AddPhoto() failed: %v", err)
+		}
+	}
+	// Photo 2 differs in the secondary, photo 3 is missing there
+	secondary.AddPhoto(1, 1, []byte("photo"))
+	secondary.AddPhoto(1, 2, []byte("other"))
+
+	var mu sync.Mutex
+	observed := make(map[Result]int)
+	var logs bytes.Buffer
+	db := New(primary, secondary, WithLogger(slog.New(slog.NewJSONHandler(&logs, nil))), WithObserver(func(r Result) {
+		mu.Lock()
+		defer mu.Unlock()
+		observed[r]++
+	}))
+
+	for photoID := uint64(1); photoID <= 3; photoID++ {
+		data, err := db.GetPhotoData(1, photoID)
+		if err != nil || !bytes.Equal(data, []byte("photo")) {
+			t.Errorf("GetPhotoData(1, %d) = %q, %v, want the primary photo", photoID, data, err)
+		}
+	}
+	// Failed primary reads are not compared
+	if _, err := db.GetPhotoData(2, 1); err == nil {
+		t.Errorf("GetPhotoData() of a missing photo succeeded, want error")
+	}
+
+	// Close waits for the queued comparisons
+	if err := db.Close(); err != nil {
+		t.Fatalf("Close() failed: %v", err)
+	}
+	want := Stats{Matches: 1, Mismatches: 1, SecondaryErrors: 1}
+	if got := db.Stats(); got != want {
+		t.Errorf("Stats() = %+v, want %+v", got, want)
+	}
+	if observed[Match] != 1 || observed[Mismatch] != 1 || observed[SecondaryError] != 1 {
+		t.Errorf("Observed results = %v, want one match, mismatch and secondary error", observed)
+	}
+
+	// Mismatches and secondary errors are logged with the photo IDs
+	logged := make(map[float64]string)
+	for _, line := range bytes.Split(bytes.TrimSpace(logs.Bytes()), []byte("\n")) {
+		var record struct {
+			Msg     string  `json:"msg"`
+			CatID   float64 `json:"cat_id"`
+			PhotoID float64 `json:"photo_id"`
+		}
+		if err := json.Unmarshal(line, &record); err != nil {
+			t.Fatalf("Failed to parse log record %q: %v", line, err)
+		}
+		if record.CatID != 1 {
+			t.Errorf("Logged %q for cat_id %v, want 1", record.Msg, record.CatID)
+		}
+		logged[record.PhotoID] = record.Msg
+	}
+	if len(logged) != 2 || logged[2] != "Shadow read mismatch" || logged[3] != "Shadow read failed" {
+		t.Errorf("Logged records by photo_id = %v, want a mismatch of 2 and a failure of 3", logged)
+	}
+
+	// Reads after Close are not queued
+	db.GetPhotoData(1, 1)
+}
+
+// blockingReader blocks photo data reads until release is closed
+type blockingReader struct {
+	manul.DBReader
+	started chan struct{}
+	release chan struct{}
+}
+
+func (r *blockingReader) GetPhotoData(catID, photoID uint64) ([]byte, error) {
+	r.started <- struct{}{}
+	<-r.release
+	return r.DBReader.GetPhotoData(catID, photoID)
+}
+
+func TestShadowDB_Dropped(t *testing.T) {
+	primary := memory.New()
+	primary.AddPhoto(1, 1, []byte("photo"))
+	photos := memory.New()
+	photos.AddPhoto(1, 1, []byte("photo"))
+	secondary := &blockingReader{
+		DBReader: photos,
+		started:  make(chan struct{}, 3),
+		release:  make(chan struct{}),
+	}
+	db := New(primary, secondary, WithWorkers(1), WithQueueSize(1))
+
+	// The first read blocks the worker, the second fills the queue
+	db.GetPhotoData(1, 1)
+	<-secondary.started
+	db.GetPhotoData(1, 1)
+	db.GetPhotoData(1, 1)
+	if got := db.Stats().Dropped; got != 1 {
+		t.Errorf("Stats().Dropped = %d, want 1", got)
+	}
+
+	close(secondary.release)
+	db.Close()
+	if got := db.Stats(); got.Matches != 2 {
+		t.Errorf("Stats() = %+v, want 2 matches", got)
+	}
+}
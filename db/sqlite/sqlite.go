@@ -0,0 +1,401 @@
+package sqlite
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/mhbvr/manul"
+)
+
+// driverName is the database/sql driver used to open databases, see driver.go
+const driverName = "sqlite"
+
+// SQLite integers are signed, so IDs are stored as int64(id) and IDs above
+// math.MaxInt64 are negative. Ordered reads scan the stored values from 0
+// up first and then the negative ones, which is ascending ID order.
+
+// idRange is a range of stored IDs, bounds included
+type idRange struct {
+	from, to int64
+}
+
+// idRanges returns the ranges of stored IDs >= start in ascending ID order
+func idRanges(start uint64) []idRange {
+	if start > math.MaxInt64 {
+		return []idRange{{int64(start), -1}}
+	}
+	return []idRange{{int64(start), math.MaxInt64}, {math.MinInt64, -1}}
+}
+
+const schema = `
+CREATE TABLE IF NOT EXISTS photos (
+	cat_id   INTEGER NOT NULL,
+	photo_id INTEGER NOT NULL,
+	meta     BLOB NOT NULL,
+	data     BLOB NOT NULL
+);
+CREATE UNIQUE INDEX IF NOT EXISTS photos_ids ON photos (cat_id, photo_id);
+`
+
+// Option configures opening of a SQLiteDB
+type Option func(*options)
+
+type options struct {
+	timeout time.Duration
+}
+
+// WithTimeout sets how long statements wait for locks held by another
+// process. Zero (the default) waits forever.
+func WithTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.timeout = timeout
+	}
+}
+
+// openSQLite opens a SQLite file, creating the schema unless readOnly
+func openSQLite(path string, readOnly bool, opts []Option) (*sql.DB, error) {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	busyTimeout := int64(math.MaxInt32)
+	if o.timeout > 0 {
+		busyTimeout = o.timeout.Milliseconds()
+	}
+	query := url.Values{}
+	query.Add("_pragma", fmt.Sprintf("busy_timeout(%d)", busyTimeout))
+	if readOnly {
+		query.Set("mode", "ro")
+	} else {
+		// Readers are not blocked by the writer
+		query.Add("_pragma", "journal_mode(WAL)")
+	}
+
+	db, err := sql.Open(driverName, "file:"+path+"?"+query.Encode())
+	if err != nil {
+		return nil, fmt.Errorf("failed to open sqlite database: %w", err)
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to open sqlite database: %w", err)
+	}
+
+	if !readOnly {
+		if _, err := db.Exec(schema); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to create tables: %w", err)
+		}
+	}
+	return db, nil
+}
+
+// SQLiteDB implements DBWriter and DBReader interfaces using a single SQLite
+// file with a photos table, easy to inspect with the sqlite3 tool
+type SQLiteDB struct {
+	db       *sql.DB
+	readOnly bool
+}
+
+// New creates a new SQLiteDB
+func New(dbPath string, opts ...Option) (*SQLiteDB, error) {
+	db, err := openSQLite(dbPath, false, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SQLiteDB{
+		db: db,
+	}, nil
+}
+
+// NewReader creates a new SQLiteDB for reading (read-only mode)
+func NewReader(dbPath string, opts ...Option) (*SQLiteDB, error) {
+	db, err := openSQLite(dbPath, true, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SQLiteDB{
+		db:       db,
+		readOnly: true,
+	}, nil
+}
+
+func (s *SQLiteDB) Close() error {
+	return s.db.Close()
+}
+
+// notFoundError is the error of a missing photo
+func notFoundError(catID, photoID uint64) error {
+	return fmt.Errorf("photo with cat_id=%d, photo_id=%d not found in database", catID, photoID)
+}
+
+func (s *SQLiteDB) AddPhoto(catID, photoID uint64, photoData []byte) error {
+	return s.AddPhotosBatch([]manul.PhotoItem{{CatID: catID, PhotoID: photoID, PhotoData: photoData}})
+}
+
+func (s *SQLiteDB) AddPhotosBatch(photos []manul.PhotoItem) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare("INSERT OR REPLACE INTO photos (cat_id, photo_id, meta, data) VALUES (?, ?, ?, ?)")
+	if err != nil {
+		return fmt.Errorf("failed to prepare insert: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, photo := range photos {
+		_, err := stmt.Exec(int64(photo.CatID), int64(photo.PhotoID), manul.NewMetaValue(photo.PhotoData, photo.CreatedAt), photo.PhotoData)
+		if err != nil {
+			return fmt.Errorf("failed to insert photo cat_id=%d, photo_id=%d: %w", photo.CatID, photo.PhotoID, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+func (s *SQLiteDB) DeletePhotos(keys []manul.PhotoKey) ([]bool, error) {
+	deleted := make([]bool, len(keys))
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare("DELETE FROM photos WHERE cat_id = ? AND photo_id = ?")
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare delete: %w", err)
+	}
+	defer stmt.Close()
+
+	for i, key := range keys {
+		res, err := stmt.Exec(int64(key.CatID), int64(key.PhotoID))
+		if err != nil {
+			return nil, fmt.Errorf("failed to delete photo cat_id=%d, photo_id=%d: %w", key.CatID, key.PhotoID, err)
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return nil, fmt.Errorf("failed to delete photo cat_id=%d, photo_id=%d: %w", key.CatID, key.PhotoID, err)
+		}
+		deleted[i] = n > 0
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return deleted, nil
+}
+
+func (s *SQLiteDB) DeleteCat(catID uint64) (int, error) {
+	res, err := s.db.Exec("DELETE FROM photos WHERE cat_id = ?", int64(catID))
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete photos of cat_id=%d: %w", catID, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete photos of cat_id=%d: %w", catID, err)
+	}
+	return int(n), nil
+}
+
+// queryIDs returns the IDs selected by query
+func (s *SQLiteDB) queryIDs(query string, args ...any) ([]uint64, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query IDs: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []uint64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to read ID: %w", err)
+		}
+		ids = append(ids, uint64(id))
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query IDs: %w", err)
+	}
+	return ids, nil
+}
+
+// listIDs returns up to limit IDs >= start in ascending order, all of them
+// if limit is negative. query selects the IDs of a stored ID range, its
+// last parameters are the range bounds and the limit after args.
+func (s *SQLiteDB) listIDs(query string, start uint64, limit int, args ...any) ([]uint64, error) {
+	var ids []uint64
+	for _, r := range idRanges(start) {
+		remaining := -1 // No limit in SQLite
+		if limit >= 0 {
+			remaining = limit - len(ids)
+			if remaining <= 0 {
+				break
+			}
+		}
+		rangeIDs, err := s.queryIDs(query, slices.Concat(args, []any{r.from, r.to, remaining})...)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, rangeIDs...)
+	}
+	return ids, nil
+}
+
+const (
+	listCatsQuery   = "SELECT DISTINCT cat_id FROM photos WHERE cat_id BETWEEN ? AND ? ORDER BY cat_id LIMIT ?"
+	listPhotosQuery = "SELECT photo_id FROM photos WHERE cat_id = ? AND photo_id BETWEEN ? AND ? ORDER BY photo_id LIMIT ?"
+)
+
+func (s *SQLiteDB) GetAllCatIDs() ([]uint64, error) {
+	return s.listIDs(listCatsQuery, 0, -1)
+}
+
+func (s *SQLiteDB) GetPhotoIDs(catID uint64) ([]uint64, error) {
+	return s.listIDs(listPhotosQuery, 0, -1, int64(catID))
+}
+
+func (s *SQLiteDB) ListCatIDs(startCatID uint64, limit int) ([]uint64, error) {
+	return s.listIDs(listCatsQuery, startCatID, limit)
+}
+
+func (s *SQLiteDB) ListPhotoIDs(catID, startPhotoID uint64, limit int) ([]uint64, error) {
+	return s.listIDs(listPhotosQuery, startPhotoID, limit, int64(catID))
+}
+
+func (s *SQLiteDB) CountPhotos(catID uint64) (uint64, error) {
+	var count int64
+	if err := s.db.QueryRow("SELECT COUNT(*) FROM photos WHERE cat_id = ?", int64(catID)).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count photos: %w", err)
+	}
+	return uint64(count), nil
+}
+
+func (s *SQLiteDB) CountAllPhotos() (uint64, error) {
+	var count int64
+	if err := s.db.QueryRow("SELECT COUNT(*) FROM photos").Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count photos: %w", err)
+	}
+	return uint64(count), nil
+}
+
+// ForEachPhoto steps through the rows of a query on the ID index per range
+// of cat IDs. Photo IDs above math.MaxInt64 are sorted after the others of
+// their cat.
+func (s *SQLiteDB) ForEachPhoto(fn func(catID, photoID uint64) error) error {
+	for _, r := range idRanges(0) {
+		if err := s.forEachPhoto(r, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// forEachPhoto calls fn for the photos of the cats in r
+func (s *SQLiteDB) forEachPhoto(r idRange, fn func(catID, photoID uint64) error) error {
+	rows, err := s.db.Query("SELECT cat_id, photo_id FROM photos WHERE cat_id BETWEEN ? AND ? ORDER BY cat_id, photo_id < 0, photo_id",
+		r.from, r.to)
+	if err != nil {
+		return fmt.Errorf("failed to query IDs: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var catID, photoID int64
+		if err := rows.Scan(&catID, &photoID); err != nil {
+			return fmt.Errorf("failed to read IDs: %w", err)
+		}
+		if err := fn(uint64(catID), uint64(photoID)); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to query IDs: %w", err)
+	}
+	return nil
+}
+
+// getColumn returns a blob column of a photo
+func (s *SQLiteDB) getColumn(column string, catID, photoID uint64) ([]byte, error) {
+	var value []byte
+	err := s.db.QueryRow("SELECT "+column+" FROM photos WHERE cat_id = ? AND photo_id = ?",
+		int64(catID), int64(photoID)).Scan(&value)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, notFoundError(catID, photoID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get photo %s: %w", column, err)
+	}
+	// Scanned blobs are never nil for stored empty photos
+	if value == nil {
+		value = []byte{}
+	}
+	return value, nil
+}
+
+func (s *SQLiteDB) GetPhotoData(catID, photoID uint64) ([]byte, error) {
+	return s.getColumn("data", catID, photoID)
+}
+
+func (s *SQLiteDB) GetPhotoFormat(catID, photoID uint64) (string, error) {
+	meta, err := s.GetPhotoMeta(catID, photoID)
+	if err != nil {
+		return "", err
+	}
+	return meta.Format.String(), nil
+}
+
+func (s *SQLiteDB) GetPhotoMeta(catID, photoID uint64) (manul.PhotoMeta, error) {
+	value, err := s.getColumn("meta", catID, photoID)
+	if err != nil {
+		return manul.PhotoMeta{}, err
+	}
+
+	return manul.ResolveMeta(value, func() ([]byte, error) {
+		return s.GetPhotoData(catID, photoID)
+	}, s.storeMeta(catID, photoID))
+}
+
+func (s *SQLiteDB) GetPhotoInfo(catID, photoID uint64) (manul.PhotoMeta, error) {
+	value, err := s.getColumn("meta", catID, photoID)
+	if err != nil {
+		return manul.PhotoMeta{}, err
+	}
+	return manul.StoredMeta(value), nil
+}
+
+// storeMeta returns the function storing a recomputed meta value of a
+// photo, nil if the database is read-only
+func (s *SQLiteDB) storeMeta(catID, photoID uint64) func([]byte) error {
+	if s.readOnly {
+		return nil
+	}
+	return func(value []byte) error {
+		_, err := s.db.Exec("UPDATE photos SET meta = ? WHERE cat_id = ? AND photo_id = ?",
+			value, int64(catID), int64(photoID))
+		return err
+	}
+}
+
+func (s *SQLiteDB) PhotoExists(catID, photoID uint64) (bool, error) {
+	var exists bool
+	err := s.db.QueryRow("SELECT EXISTS (SELECT 1 FROM photos WHERE cat_id = ? AND photo_id = ?)",
+		int64(catID), int64(photoID)).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("failed to check photo: %w", err)
+	}
+	return exists, nil
+}
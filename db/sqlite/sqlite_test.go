@@ -0,0 +1,383 @@
+package sqlite
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/png"
+	"math"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/mhbvr/manul"
+)
+
+func newTestDB(t *testing.T) *SQLiteDB {
+	t.Helper()
+
+	db, err := New(filepath.Join(t.TempDir(), "photos.sqlite"))
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// largeID is above math.MaxInt64, stored as a negative SQLite integer
+const largeID = math.MaxInt64 + 1
+
+func TestAddPhotosBatch(t *testing.T) {
+	db := newTestDB(t)
+
+	if err := db.AddPhoto(1, 1, []byte("old")); err != nil {
+		t.Fatalf("AddPhoto() failed: %v", err)
+	}
+	// Later photos of a batch replace earlier ones and stored ones
+	err := db.AddPhotosBatch([]manul.PhotoItem{
+		{CatID: 1, PhotoID: 1, PhotoData: []byte("first")},
+		{CatID: 1, PhotoID: 1, PhotoData: []byte("new")},
+		{CatID: largeID, PhotoID: math.MaxUint64, PhotoData: []byte("large")},
+		{CatID: 2, PhotoID: 1, PhotoData: []byte{}},
+	})
+	if err != nil {
+		t.Fatalf("AddPhotosBatch() failed: %v", err)
+	}
+
+	tests := []struct {
+		catID, photoID uint64
+		want           string
+	}{
+		{1, 1, "new"},
+		{largeID, math.MaxUint64, "large"},
+		{2, 1, ""},
+	}
+	for _, tt := range tests {
+		got, err := db.GetPhotoData(tt.catID, tt.photoID)
+		if err != nil || string(got) != tt.want || got == nil {
+			t.Errorf("GetPhotoData(%d, %d) = %q, %v, want %q", tt.catID, tt.photoID, got, err, tt.want)
+		}
+	}
+
+	if _, err := db.GetPhotoData(1, 2); err == nil {
+		t.Errorf("GetPhotoData() of a missing photo succeeded, want error")
+	}
+}
+
+func TestNewReader(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "photos.sqlite")
+	db, err := New(path)
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	if err := db.AddPhoto(1, 1, []byte("photo")); err != nil {
+		t.Fatalf("AddPhoto() failed: %v", err)
+	}
+	db.Close()
+
+	reader, err := NewReader(path)
+	if err != nil {
+		t.Fatalf("NewReader() failed: %v", err)
+	}
+	defer reader.Close()
+
+	if got, err := reader.GetPhotoData(1, 1); err != nil || string(got) != "photo" {
+		t.Errorf("GetPhotoData() = %q, %v, want %q", got, err, "photo")
+	}
+	if err := reader.AddPhoto(1, 2, []byte("photo")); err == nil {
+		t.Errorf("AddPhoto() to a read-only database succeeded, want error")
+	}
+}
+
+func TestDeletePhotos(t *testing.T) {
+	db := newTestDB(t)
+
+	for _, photoID := range []uint64{1, 2, largeID} {
+		if err := db.AddPhoto(1, photoID, []byte("photo")); err != nil {
+			t.Fatalf("AddPhoto() failed: %v", err)
+		}
+	}
+
+	deleted, err := db.DeletePhotos([]manul.PhotoKey{
+		{CatID: 1, PhotoID: 1},
+		{CatID: 1, PhotoID: 3}, // Does not exist
+		{CatID: 1, PhotoID: 1}, // Already deleted
+		{CatID: 1, PhotoID: largeID},
+	})
+	if err != nil {
+		t.Fatalf("DeletePhotos() failed: %v", err)
+	}
+	if want := []bool{true, false, false, true}; !slices.Equal(deleted, want) {
+		t.Errorf("DeletePhotos() = %v, want %v", deleted, want)
+	}
+
+	if _, err := db.GetPhotoData(1, 1); err == nil {
+		t.Errorf("GetPhotoData() of deleted photo succeeded, want error")
+	}
+	if _, err := db.GetPhotoData(1, 2); err != nil {
+		t.Errorf("GetPhotoData() of kept photo failed: %v", err)
+	}
+}
+
+func TestDeleteCat(t *testing.T) {
+	db := newTestDB(t)
+
+	photos := []manul.PhotoItem{
+		{CatID: 1, PhotoID: 1, PhotoData: []byte("photo")},
+		{CatID: 2, PhotoID: 1, PhotoData: []byte("photo")},
+		{CatID: 2, PhotoID: 2, PhotoData: []byte("photo")},
+		{CatID: largeID, PhotoID: 1, PhotoData: []byte("photo")},
+	}
+	if err := db.AddPhotosBatch(photos); err != nil {
+		t.Fatalf("AddPhotosBatch() failed: %v", err)
+	}
+
+	for catID, want := range map[uint64]int{2: 2, largeID: 1, 3: 0} {
+		count, err := db.DeleteCat(catID)
+		if err != nil || count != want {
+			t.Errorf("DeleteCat(%d) = %d, %v, want %d", catID, count, err, want)
+		}
+	}
+
+	if catIDs, _ := db.GetAllCatIDs(); !slices.Equal(catIDs, []uint64{1}) {
+		t.Errorf("GetAllCatIDs() after DeleteCat() = %v, want [1]", catIDs)
+	}
+}
+
+func TestGetPhotoMeta_CreatedAt(t *testing.T) {
+	db := newTestDB(t)
+
+	createdAt := time.Date(2021, 6, 1, 12, 30, 0, 123, time.UTC)
+	err := db.AddPhotosBatch([]manul.PhotoItem{
+		{CatID: 1, PhotoID: 1, PhotoData: []byte("photo"), CreatedAt: createdAt},
+		{CatID: 1, PhotoID: 2, PhotoData: []byte("photo")},
+	})
+	if err != nil {
+		t.Fatalf("AddPhotosBatch() failed: %v", err)
+	}
+
+	meta, err := db.GetPhotoMeta(1, 1)
+	if err != nil {
+		t.Fatalf("GetPhotoMeta() failed: %v", err)
+	}
+	if !meta.CreatedAt.Equal(createdAt) {
+		t.Errorf("CreatedAt = %v, want %v", meta.CreatedAt, createdAt)
+	}
+	if meta.SHA256 != manul.NewPhotoMeta([]byte("photo")).SHA256 || meta.Size != 5 {
+		t.Errorf("GetPhotoMeta() = hash %s, size %d, want the photo data hash and size 5", meta.Hash(), meta.Size)
+	}
+
+	meta, err = db.GetPhotoMeta(1, 2)
+	if err != nil {
+		t.Fatalf("GetPhotoMeta() failed: %v", err)
+	}
+	if !meta.CreatedAt.IsZero() {
+		t.Errorf("CreatedAt = %v, want zero for unknown creation time", meta.CreatedAt)
+	}
+
+	if format, err := db.GetPhotoFormat(1, 1); err != nil || format != manul.FormatUnknown.String() {
+		t.Errorf("GetPhotoFormat() = %q, %v, want %q", format, err, manul.FormatUnknown.String())
+	}
+}
+
+func TestGetPhotoMeta_Backfill(t *testing.T) {
+	db := newTestDB(t)
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 3, 2))); err != nil {
+		t.Fatalf("png.Encode() failed: %v", err)
+	}
+	if err := db.AddPhoto(1, 1, buf.Bytes()); err != nil {
+		t.Fatalf("AddPhoto() failed: %v", err)
+	}
+
+	// Replace the metadata with a version 1 value, 50 bytes without dimensions
+	createdAt := time.Date(2021, 6, 1, 12, 30, 0, 0, time.UTC)
+	v1 := manul.EncodeMeta(manul.PhotoMeta{Format: manul.FormatPNG, CreatedAt: createdAt})[:50]
+	v1[0] = 1
+	if _, err := db.db.Exec("UPDATE photos SET meta = ? WHERE cat_id = 1 AND photo_id = 1", v1); err != nil {
+		t.Fatalf("Failed to store version 1 metadata: %v", err)
+	}
+
+	// GetPhotoInfo returns the stored fields only and does not backfill
+	info, err := db.GetPhotoInfo(1, 1)
+	if err != nil {
+		t.Fatalf("GetPhotoInfo() failed: %v", err)
+	}
+	if info.HasDimensions() || info.Format != manul.FormatPNG || !info.CreatedAt.Equal(createdAt) {
+		t.Errorf("GetPhotoInfo() = %s %dx%d created at %v, want png without dimensions created at %v", info.Format, info.Width, info.Height, info.CreatedAt, createdAt)
+	}
+
+	meta, err := db.GetPhotoMeta(1, 1)
+	if err != nil {
+		t.Fatalf("GetPhotoMeta() failed: %v", err)
+	}
+	if meta.Width != 3 || meta.Height != 2 || !meta.CreatedAt.Equal(createdAt) {
+		t.Errorf("GetPhotoMeta() = %dx%d created at %v, want 3x2 created at %v", meta.Width, meta.Height, meta.CreatedAt, createdAt)
+	}
+
+	stored, err := db.getColumn("meta", 1, 1)
+	if err != nil {
+		t.Fatalf("Failed to read metadata: %v", err)
+	}
+	if !manul.MetaCurrent(stored) {
+		t.Errorf("Metadata was not backfilled, stored value has %d bytes", len(stored))
+	}
+}
+
+func TestPhotoExists(t *testing.T) {
+	db := newTestDB(t)
+
+	for _, key := range []manul.PhotoKey{{CatID: 1, PhotoID: 1}, {CatID: largeID, PhotoID: largeID}} {
+		if err := db.AddPhoto(key.CatID, key.PhotoID, []byte("photo")); err != nil {
+			t.Fatalf("AddPhoto() failed: %v", err)
+		}
+	}
+
+	tests := []struct {
+		catID, photoID uint64
+		want           bool
+	}{
+		{1, 1, true},
+		{1, 2, false},
+		{2, 1, false},
+		{largeID, largeID, true},
+		{largeID, 1, false},
+	}
+	for _, tt := range tests {
+		got, err := db.PhotoExists(tt.catID, tt.photoID)
+		if err != nil {
+			t.Errorf("PhotoExists(%d, %d) failed: %v", tt.catID, tt.photoID, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("PhotoExists(%d, %d) = %v, want %v", tt.catID, tt.photoID, got, tt.want)
+		}
+	}
+}
+
+func TestCountPhotos(t *testing.T) {
+	db := newTestDB(t)
+
+	for _, key := range []manul.PhotoKey{{CatID: 1, PhotoID: 1}, {CatID: 1, PhotoID: 2}, {CatID: largeID, PhotoID: 1}} {
+		if err := db.AddPhoto(key.CatID, key.PhotoID, []byte("photo")); err != nil {
+			t.Fatalf("AddPhoto() failed: %v", err)
+		}
+	}
+
+	for catID, want := range map[uint64]uint64{1: 2, largeID: 1, 3: 0} {
+		got, err := db.CountPhotos(catID)
+		if err != nil {
+			t.Fatalf("CountPhotos(%d) failed: %v", catID, err)
+		}
+		if got != want {
+			t.Errorf("CountPhotos(%d) = %d, want %d", catID, got, want)
+		}
+	}
+
+	total, err := db.CountAllPhotos()
+	if err != nil {
+		t.Fatalf("CountAllPhotos() failed: %v", err)
+	}
+	if total != 3 {
+		t.Errorf("CountAllPhotos() = %d, want 3", total)
+	}
+}
+
+func TestListIDs(t *testing.T) {
+	db := newTestDB(t)
+
+	keys := []manul.PhotoKey{
+		{CatID: 1, PhotoID: 1}, {CatID: 1, PhotoID: 2}, {CatID: 1, PhotoID: largeID}, {CatID: 1, PhotoID: math.MaxUint64},
+		{CatID: 2, PhotoID: 1}, {CatID: 5, PhotoID: 1},
+		{CatID: largeID, PhotoID: 1}, {CatID: math.MaxUint64, PhotoID: 1},
+	}
+	for _, key := range keys {
+		if err := db.AddPhoto(key.CatID, key.PhotoID, []byte("photo")); err != nil {
+			t.Fatalf("AddPhoto() failed: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name string
+		list func() ([]uint64, error)
+		want []uint64
+	}{
+		{"all cats", db.GetAllCatIDs, []uint64{1, 2, 5, largeID, math.MaxUint64}},
+		{"all photos", func() ([]uint64, error) { return db.GetPhotoIDs(1) }, []uint64{1, 2, largeID, math.MaxUint64}},
+		{"photos of other cat", func() ([]uint64, error) { return db.GetPhotoIDs(3) }, nil},
+		{"cats from start", func() ([]uint64, error) { return db.ListCatIDs(0, 2) }, []uint64{1, 2}},
+		{"cats across ranges", func() ([]uint64, error) { return db.ListCatIDs(3, 2) }, []uint64{5, largeID}},
+		{"cats from large ID", func() ([]uint64, error) { return db.ListCatIDs(largeID+1, 10) }, []uint64{math.MaxUint64}},
+		{"cats past end", func() ([]uint64, error) { return db.ListCatIDs(6, 0) }, nil},
+		{"photos from start", func() ([]uint64, error) { return db.ListPhotoIDs(1, 0, 2) }, []uint64{1, 2}},
+		{"photos across ranges", func() ([]uint64, error) { return db.ListPhotoIDs(1, 2, 10) }, []uint64{2, largeID, math.MaxUint64}},
+		{"photos of other cat from start", func() ([]uint64, error) { return db.ListPhotoIDs(3, 0, 2) }, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.list()
+			if err != nil {
+				t.Fatalf("List failed: %v", err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("List = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestForEachPhoto(t *testing.T) {
+	db := newTestDB(t)
+
+	keys := []manul.PhotoKey{
+		{CatID: 1, PhotoID: 1}, {CatID: 1, PhotoID: 2}, {CatID: 1, PhotoID: largeID},
+		{CatID: 2, PhotoID: 1}, {CatID: largeID, PhotoID: 1}, {CatID: largeID, PhotoID: math.MaxUint64},
+	}
+	for i := len(keys) - 1; i >= 0; i-- {
+		if err := db.AddPhoto(keys[i].CatID, keys[i].PhotoID, []byte("photo")); err != nil {
+			t.Fatalf("AddPhoto() failed: %v", err)
+		}
+	}
+
+	var got []manul.PhotoKey
+	err := db.ForEachPhoto(func(catID, photoID uint64) error {
+		got = append(got, manul.PhotoKey{CatID: catID, PhotoID: photoID})
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ForEachPhoto() failed: %v", err)
+	}
+	if !slices.Equal(got, keys) {
+		t.Errorf("ForEachPhoto() visited %v, want %v", got, keys)
+	}
+
+	// A callback error stops the iteration
+	errStop := errors.New("stop")
+	visited := 0
+	err = db.ForEachPhoto(func(catID, photoID uint64) error {
+		visited++
+		return errStop
+	})
+	if !errors.Is(err, errStop) || visited != 1 {
+		t.Errorf("ForEachPhoto() = %v after %d photos, want %v after 1", err, visited, errStop)
+	}
+}
+
+func TestGetRandomPhoto(t *testing.T) {
+	db := newTestDB(t)
+
+	if _, _, err := manul.GetRandomPhoto(db); !errors.Is(err, manul.ErrNoPhotos) {
+		t.Errorf("GetRandomPhoto() of an empty database error = %v, want %v", err, manul.ErrNoPhotos)
+	}
+
+	if err := db.AddPhoto(largeID, 1, []byte("photo")); err != nil {
+		t.Fatalf("AddPhoto() failed: %v", err)
+	}
+	catID, photoID, err := manul.GetRandomPhoto(db)
+	if err != nil || catID != largeID || photoID != 1 {
+		t.Errorf("GetRandomPhoto() = %d, %d, %v, want %d, 1", catID, photoID, err, uint64(largeID))
+	}
+}
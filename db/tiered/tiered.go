@@ -0,0 +1,136 @@
+package tiered
+
+import (
+	"errors"
+	"log"
+
+	"github.com/mhbvr/manul"
+)
+
+// TieredDB implements DBReader interface on top of a fast cache tier
+// and a slow tier holding all photos. Photo reads check the fast tier first
+// and copy photos missing there from the slow tier.
+// Listings always come from the slow tier, the fast tier may be partial.
+type TieredDB struct {
+	fast       manul.DBReader
+	fastWriter manul.DBWriter
+	slow       manul.DBReader
+}
+
+// New creates a TieredDB reading from fast and slow and populating the
+// fast tier through fastWriter, usually the same database as fast.
+// TieredDB takes ownership of the databases and closes them on Close.
+func New(fast manul.DBReader, fastWriter manul.DBWriter, slow manul.DBReader) *TieredDB {
+	return &TieredDB{
+		fast:       fast,
+		fastWriter: fastWriter,
+		slow:       slow,
+	}
+}
+
+func (t *TieredDB) GetAllCatIDs() ([]uint64, error) {
+	return t.slow.GetAllCatIDs()
+}
+
+func (t *TieredDB) GetPhotoIDs(catID uint64) ([]uint64, error) {
+	return t.slow.GetPhotoIDs(catID)
+}
+
+func (t *TieredDB) ListCatIDs(startCatID uint64, limit int) ([]uint64, error) {
+	return t.slow.ListCatIDs(startCatID, limit)
+}
+
+func (t *TieredDB) ListPhotoIDs(catID, startPhotoID uint64, limit int) ([]uint64, error) {
+	return t.slow.ListPhotoIDs(catID, startPhotoID, limit)
+}
+
+func (t *TieredDB) CountPhotos(catID uint64) (uint64, error) {
+	return t.slow.CountPhotos(catID)
+}
+
+func (t *TieredDB) CountAllPhotos() (uint64, error) {
+	return t.slow.CountAllPhotos()
+}
+
+func (t *TieredDB) ForEachPhoto(fn func(catID, photoID uint64) error) error {
+	return t.slow.ForEachPhoto(fn)
+}
+
+func (t *TieredDB) GetRandomPhoto() (catID, photoID uint64, err error) {
+	return manul.GetRandomPhoto(t.slow)
+}
+
+// GetPhotoData returns the photo from the fast tier, or reads it from
+// the slow tier and adds it to the fast tier
+func (t *TieredDB) GetPhotoData(catID, photoID uint64) ([]byte, error) {
+	if data, err := t.fast.GetPhotoData(catID, photoID); err == nil {
+		return data, nil
+	}
+
+	data, err := t.slow.GetPhotoData(catID, photoID)
+	if err != nil {
+		return nil, err
+	}
+
+	// A failed write only costs another slow read next time
+	if err := t.populate(catID, photoID, data); err != nil {
+		log.Printf("Failed to add cat_id=%d, photo_id=%d to fast tier: %v", catID, photoID, err)
+	}
+	return data, nil
+}
+
+// populate adds a photo read from the slow tier to the fast tier,
+// keeping its creation time
+func (t *TieredDB) populate(catID, photoID uint64, data []byte) error {
+	meta, err := t.slow.GetPhotoMeta(catID, photoID)
+	if err != nil {
+		return err
+	}
+	return t.fastWriter.AddPhotosBatch([]manul.PhotoItem{{
+		CatID:     catID,
+		PhotoID:   photoID,
+		PhotoData: data,
+		CreatedAt: meta.CreatedAt,
+	}})
+}
+
+func (t *TieredDB) GetPhotoFormat(catID, photoID uint64) (string, error) {
+	meta, err := t.GetPhotoMeta(catID, photoID)
+	if err != nil {
+		return "", err
+	}
+	return meta.Format.String(), nil
+}
+
+// GetPhotoMeta returns the metadata from the fast tier if the photo is
+// cached there, otherwise from the slow tier
+func (t *TieredDB) GetPhotoMeta(catID, photoID uint64) (manul.PhotoMeta, error) {
+	if meta, err := t.fast.GetPhotoMeta(catID, photoID); err == nil {
+		return meta, nil
+	}
+	return t.slow.GetPhotoMeta(catID, photoID)
+}
+
+// GetPhotoInfo returns the stored metadata from the fast tier if the photo
+// is cached there, otherwise from the slow tier
+func (t *TieredDB) GetPhotoInfo(catID, photoID uint64) (manul.PhotoMeta, error) {
+	if meta, err := t.fast.GetPhotoInfo(catID, photoID); err == nil {
+		return meta, nil
+	}
+	return t.slow.GetPhotoInfo(catID, photoID)
+}
+
+func (t *TieredDB) PhotoExists(catID, photoID uint64) (bool, error) {
+	if exists, err := t.fast.PhotoExists(catID, photoID); err == nil && exists {
+		return true, nil
+	}
+	return t.slow.PhotoExists(catID, photoID)
+}
+
+func (t *TieredDB) Close() error {
+	errs := []error{t.slow.Close(), t.fast.Close()}
+	if any(t.fastWriter) != any(t.fast) {
+		errs = append(errs, t.fastWriter.Close())
+	}
+	return errors.Join(errs...)
+}
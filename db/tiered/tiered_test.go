@@ -0,0 +1,77 @@
+package tiered
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mhbvr/manul"
+	"github.com/mhbvr/manul/db/bolt"
+)
+
+func TestTieredDB(t *testing.T) {
+	dir := t.TempDir()
+
+	slow, err := bolt.New(filepath.Join(dir, "slow.db"))
+	if err != nil {
+		t.Fatalf("bolt.New() failed: %v", err)
+	}
+	fast, err := bolt.New(filepath.Join(dir, "fast.db"))
+	if err != nil {
+		t.Fatalf("bolt.New() failed: %v", err)
+	}
+
+	photo := []byte("photo data")
+	item := manul.PhotoItem{CatID: 1, PhotoID: 2, PhotoData: photo, CreatedAt: time.Unix(1600000000, 0)}
+	if err := slow.AddPhotosBatch([]manul.PhotoItem{item}); err != nil {
+		t.Fatalf("AddPhotosBatch() failed: %v", err)
+	}
+
+	db := New(fast, fast, slow)
+	defer db.Close()
+
+	// Listings come from the slow tier
+	catIDs, err := db.GetAllCatIDs()
+	if err != nil || len(catIDs) != 1 || catIDs[0] != 1 {
+		t.Errorf("GetAllCatIDs() = %v, %v, want [1]", catIDs, err)
+	}
+
+	if _, err := fast.GetPhotoData(1, 2); err == nil {
+		t.Fatalf("Photo is in the fast tier before the first read")
+	}
+
+	got, err := db.GetPhotoData(1, 2)
+	if err != nil {
+		t.Fatalf("GetPhotoData() failed: %v", err)
+	}
+	if !bytes.Equal(got, photo) {
+		t.Errorf("GetPhotoData() = %q, want %q", got, photo)
+	}
+
+	// The miss populated the fast tier
+	cached, err := fast.GetPhotoData(1, 2)
+	if err != nil {
+		t.Fatalf("Photo is not in the fast tier after a read: %v", err)
+	}
+	if !bytes.Equal(cached, photo) {
+		t.Errorf("Fast tier photo = %q, want %q", cached, photo)
+	}
+
+	slowMeta, err := slow.GetPhotoMeta(1, 2)
+	if err != nil {
+		t.Fatalf("GetPhotoMeta() failed: %v", err)
+	}
+	// The fast tier keeps the creation time
+	meta, err := db.GetPhotoMeta(1, 2)
+	if err != nil {
+		t.Fatalf("GetPhotoMeta() failed: %v", err)
+	}
+	if meta != slowMeta || meta.CreatedAt.IsZero() {
+		t.Errorf("GetPhotoMeta() = %+v, want %+v", meta, slowMeta)
+	}
+
+	if _, err := db.GetPhotoData(1, 3); err == nil {
+		t.Errorf("GetPhotoData() of a missing photo succeeded, want error")
+	}
+}
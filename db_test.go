@@ -0,0 +1,60 @@
+package manul_test
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/mhbvr/manul"
+	"github.com/mhbvr/manul/db/memory"
+)
+
+// plainReader hides the RandomReader implementation of its database
+type plainReader struct {
+	manul.DBReader
+}
+
+func TestGetRandomPhoto_Iterated(t *testing.T) {
+	db := memory.New()
+	r := plainReader{db}
+	if _, _, err := manul.GetRandomPhoto(r); !errors.Is(err, manul.ErrNoPhotos) {
+		t.Errorf("GetRandomPhoto() of an empty database error = %v, want %v", err, manul.ErrNoPhotos)
+	}
+
+	keys := []manul.PhotoKey{{CatID: 1, PhotoID: 1}, {CatID: 1, PhotoID: 2}, {CatID: 7, PhotoID: 3}}
+	for _, key := range keys {
+		if err := db.AddPhoto(key.CatID, key.PhotoID, []byte("photo")); err != nil {
+			t.Fatalf("AddPhoto() failed: %v", err)
+		}
+	}
+
+	seen := make(map[manul.PhotoKey]int)
+	for i := 0; i < 300; i++ {
+		catID, photoID, err := manul.GetRandomPhoto(r)
+		if err != nil {
+			t.Fatalf("GetRandomPhoto() failed: %v", err)
+		}
+		seen[manul.PhotoKey{CatID: catID, PhotoID: photoID}]++
+	}
+	if len(seen) != len(keys) {
+		t.Errorf("GetRandomPhoto() returned %v, want each of %v", seen, keys)
+	}
+}
+
+func TestRandomID(t *testing.T) {
+	tests := []struct {
+		first, last uint64
+	}{
+		{5, 5},
+		{1, 3},
+		{0, math.MaxUint64},
+		{math.MaxUint64 - 1, math.MaxUint64},
+	}
+	for _, tt := range tests {
+		for i := 0; i < 100; i++ {
+			if got := manul.RandomID(tt.first, tt.last); got < tt.first || got > tt.last {
+				t.Fatalf("RandomID(%d, %d) = %d, out of range", tt.first, tt.last, got)
+			}
+		}
+	}
+}
@@ -5,53 +5,136 @@ import (
 	"flag"
 	"fmt"
 	"image"
+	_ "image/gif"
 	"image/jpeg"
+	"image/png"
 	"log"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/mhbvr/manul"
 	"github.com/mhbvr/manul/db/bolt"
 	"github.com/mhbvr/manul/db/filetree"
 	"github.com/mhbvr/manul/db/pebble"
+	"github.com/mhbvr/manul/db/sqlite"
 	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
 )
 
+// sourceExtensions are the recognized photo file extensions, mapped to
+// whether a decoder for the format is available
+var sourceExtensions = map[string]bool{
+	".jpg":  true,
+	".jpeg": true,
+	".png":  true,
+	".gif":  true,
+	".webp": true,
+	".heic": false,
+	".heif": false,
+	".avif": false,
+}
+
+// stringList is a flag that can be repeated, each value can also be a
+// comma-separated list
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	for _, v := range strings.Split(value, ",") {
+		if v != "" {
+			*l = append(*l, v)
+		}
+	}
+	return nil
+}
+
 func main() {
+	var srcDirs stringList
+	flag.Var(&srcDirs, "src", "Source directory containing photo files, repeat the flag or separate directories with commas for several")
 	var (
-		dbType    = flag.String("type", "filetree", "Database type: filetree, bolt, or pebble")
-		dbPath    = flag.String("db", "", "Database path (directory for filetree, file for bolt/pebble)")
-		srcDir    = flag.String("src", "", "Source directory containing photo files")
-		batchSize = flag.Int("batch-size", 100, "Number of photos to process in each transaction")
-		scale     = flag.Float64("scale", 1.0, "Image scaling factor (0.0 to 1.0, where 1.0 = no scaling)")
+		dbType     = flag.String("type", "filetree", "Database type: filetree, bolt, pebble, or sqlite")
+		dbPath     = flag.String("db", "", "Database path (directory for filetree, file for bolt/pebble/sqlite)")
+		format     = flag.String("format", manul.DefaultFilenameFormat, "Naming scheme of photo files, {cat} and {photo} are the IDs, {ext} any extension, e.g. cat{cat}/photo{photo}.jpg")
+		pattern    = flag.String("pattern", "", "Regexp matching photo file paths relative to -src, with cat_id and photo_id named groups capturing the IDs, replaces -format")
+		batchSize  = flag.Int("batch-size", 100, "Number of photos to process in each transaction")
+		batchBytes = flag.Int64("batch-bytes", 256<<20, "Max total photo bytes added to a transaction (0 = no limit)")
+		scale      = flag.Float64("scale", 1.0, "Image scaling factor (0.0 to 1.0, where 1.0 = no scaling)")
+		storeMtime = flag.Bool("mtime", true, "Store source file modification time as photo creation time")
+		maxDim     = flag.Int("max-dimension", 0, "Max width or height of a stored photo in pixels, after -scale (0 = no limit)")
+		oversize   = flag.String("oversize", "reject", "What to do with photos over -max-dimension: reject or downscale")
+		quality    = flag.Int("quality", 0, "JPEG quality 1-100 of scaled and converted photos (0 = default, 75 for scaled and 90 for converted photos)")
+		timeout    = flag.Duration("open-timeout", 10*time.Second, "Max time to wait for the database lock held by another process")
+		workers    = flag.Int("workers", runtime.NumCPU(), "Number of goroutines reading, converting and scaling photos")
+		manifestTo = flag.String("manifest", "", "File recording the photos of each committed batch, photos listed there are skipped to resume an interrupted run (empty = disabled)")
+		skipExists = flag.Bool("skip-existing", false, "Skip photos already in the database, to resume an interrupted run without a manifest")
 	)
 	flag.Parse()
 
-	if *srcDir == "" {
+	if len(srcDirs) == 0 {
 		log.Fatal("Source directory must be specified with -src flag")
 	}
+
+	var parser *manul.FilenameParser
+	var err error
+	if *pattern != "" {
+		parser, err = manul.NewFilenameRegexpParser(*pattern)
+	} else {
+		parser, err = manul.NewFilenameParser(*format)
+	}
+	if err != nil {
+		log.Fatalf("Invalid photo file naming: %v", err)
+	}
 	
 	if *dbPath == "" {
 		log.Fatal("Database path must be specified with -db flag")
 	}
 
+	if *batchSize <= 0 {
+		log.Fatal("Batch size must be positive")
+	}
+
+	if *workers <= 0 {
+		log.Fatal("Number of workers must be positive")
+	}
+
 	if *scale <= 0.0 || *scale > 1.0 {
 		log.Fatal("Scale factor must be between 0.0 (exclusive) and 1.0 (inclusive)")
 	}
 
-	var writer manul.DBWriter
-	var err error
+	if *maxDim < 0 {
+		log.Fatal("Max dimension must not be negative")
+	}
+
+	if *quality < 0 || *quality > 100 {
+		clamped := min(max(*quality, 1), 100)
+		log.Printf("JPEG quality %d is out of range, using %d", *quality, clamped)
+		*quality = clamped
+	}
+
+	if *oversize != "reject" && *oversize != "downscale" {
+		log.Fatalf("Unknown -oversize value: %s (must be 'reject' or 'downscale')", *oversize)
+	}
+
+	var writer manul.DBReadWriter
 
 	switch *dbType {
 	case "filetree":
-		writer, err = filetree.New(*dbPath)
+		writer, err = filetree.New(*dbPath, filetree.WithTimeout(*timeout))
 	case "bolt":
-		writer, err = bolt.New(*dbPath)
+		writer, err = bolt.New(*dbPath, bolt.WithTimeout(*timeout))
 	case "pebble":
-		writer, err = pebble.New(*dbPath)
+		writer, err = pebble.New(*dbPath, pebble.WithTimeout(*timeout))
+	case "sqlite":
+		writer, err = sqlite.New(*dbPath, sqlite.WithTimeout(*timeout))
 	default:
-		log.Fatalf("Unknown database type: %s (must be 'filetree', 'bolt', or 'pebble')", *dbType)
+		log.Fatalf("Unknown database type: %s (must be 'filetree', 'bolt', 'pebble', or 'sqlite')", *dbType)
 	}
 
 	if err != nil {
@@ -60,135 +143,404 @@ func main() {
 	defer writer.Close()
 
 	fmt.Printf("Creating %s database at: %s\n", *dbType, *dbPath)
-	fmt.Printf("Scanning directory: %s\n", *srcDir)
 	if *scale < 1.0 {
 		fmt.Printf("Image scaling enabled: %.2f\n", *scale)
 	}
+	if *maxDim > 0 {
+		fmt.Printf("Max photo dimension: %d (oversize photos: %s)\n", *maxDim, *oversize)
+	}
 
-	var totalFiles, skippedFiles int
-	var filePaths []string
-
-	// Single scan: collect file paths and count files
-	err = filepath.Walk(*srcDir, func(path string, info os.FileInfo, err error) error {
+	var m *manifest
+	if *manifestTo != "" {
+		m, err = openManifest(*manifestTo)
 		if err != nil {
-			return err
+			log.Fatalf("Failed to open manifest: %v", err)
 		}
+		defer m.Close()
+		fmt.Printf("Using manifest %s with %d committed photos\n", *manifestTo, m.Len())
+	}
 
-		if info.IsDir() {
-			return nil
-		}
+	files, totalFiles, skippedFiles, err := scanSources(srcDirs, parser)
+	if err != nil {
+		log.Fatalf("Failed to scan source directory: %v", err)
+	}
 
-		totalFiles++
-		filename := info.Name()
-		if _, _, ok := GetIDs(filename); !ok {
-			skippedFiles++
-			fmt.Printf("Skipping %s: cannot extract cat_id and photo_id\n", filename)
-			return nil
-		}
+	files, ingestedFiles, err := skipIngested(files, m, writer, *skipExists)
+	if err != nil {
+		log.Fatalf("Failed to check for ingested photos: %v", err)
+	}
 
-		filePaths = append(filePaths, path)
-		return nil
+	fmt.Printf("Found %d files total, %d will be processed, %d skipped, %d already ingested\n", totalFiles, len(files), skippedFiles, ingestedFiles)
+	fmt.Printf("Using batch size: %d photos, %d bytes\n", *batchSize, *batchBytes)
+	fmt.Printf("Using %d workers\n", *workers)
+
+	// Files are read and scaled by the workers, photos are added to
+	// batches in the order they are ready
+	results := preparePhotos(files, *workers, prepareOptions{
+		scale:      *scale,
+		storeMtime: *storeMtime,
+		maxDim:     *maxDim,
+		oversize:   *oversize,
+		quality:    *quality,
+	})
+	report, err := ingest(writer, results, ingestOptions{
+		batchSize:  *batchSize,
+		batchBytes: *batchBytes,
+		manifest:   m,
 	})
-
 	if err != nil {
-		log.Fatalf("Failed to scan source directory: %v", err)
+		log.Fatalf("Ingest failed after %d photos, rerun with the same -manifest or with -skip-existing to resume: %v", report.Processed, err)
+	}
+	skippedFiles += report.DecodeFailures
+
+	fmt.Printf("\nDatabase build completed successfully:\n")
+	fmt.Printf("  Database type: %s\n", *dbType)
+	fmt.Printf("  Database path: %s\n", *dbPath)
+	fmt.Printf("  Total files found: %d\n", totalFiles)
+	fmt.Printf("  Files processed: %d\n", report.Processed)
+	fmt.Printf("  Files skipped: %d\n", skippedFiles)
+	if ingestedFiles > 0 {
+		fmt.Printf("  Files already ingested: %d\n", ingestedFiles)
+	}
+	if report.DecodeFailures > 0 {
+		fmt.Printf("  Files failed to decode: %d\n", report.DecodeFailures)
+	}
+	if len(report.Rejected) > 0 {
+		fmt.Printf("  Files rejected by max dimension: %d\n", len(report.Rejected))
+		for _, path := range report.Rejected {
+			fmt.Printf("    %s\n", path)
+		}
 	}
 
-	processedFiles := 0
-	fmt.Printf("Found %d files total, %d will be processed, %d skipped\n", totalFiles, len(filePaths), skippedFiles)
-	fmt.Printf("Using batch size: %d\n", *batchSize)
+	// Show database size/info
+	switch *dbType {
+	case "filetree":
+		fmt.Printf("  Database created in directory: %s\n", *dbPath)
+	case "bolt", "pebble", "sqlite":
+		if stat, err := os.Stat(*dbPath); err == nil {
+			fmt.Printf("  Database size: %d bytes\n", stat.Size())
+		}
+	}
+}
 
-	totalBatches := (len(filePaths) + *batchSize - 1) / *batchSize
+// skipIngested removes the files of photos in the manifest, if set, or
+// in db if skipExisting is set, and returns the remaining files and the
+// number of removed ones
+func skipIngested(files []sourceFile, m *manifest, db manul.DBReader, skipExisting bool) ([]sourceFile, int, error) {
+	if m == nil && !skipExisting {
+		return files, 0, nil
+	}
 
-	// Process files in batches
-	for i := 0; i < len(filePaths); i += *batchSize {
-		end := i + *batchSize
-		if end > len(filePaths) {
-			end = len(filePaths)
+	remaining := files[:0]
+	for _, file := range files {
+		if m != nil && m.Contains(file.catID, file.photoID) {
+			continue
+		}
+		if skipExisting {
+			exists, err := db.PhotoExists(file.catID, file.photoID)
+			if err != nil {
+				return nil, 0, fmt.Errorf("cat_id=%d photo_id=%d: %w", file.catID, file.photoID, err)
+			}
+			if exists {
+				continue
+			}
 		}
+		remaining = append(remaining, file)
+	}
+	return remaining, len(files) - len(remaining), nil
+}
 
-		batchPaths := filePaths[i:end]
-		batchNum := (i / *batchSize) + 1
+// ingestOptions configure how prepared photos are written
+type ingestOptions struct {
+	batchSize  int
+	batchBytes int64     // 0 = no limit
+	manifest   *manifest // Records the committed batches if set
+}
 
-		fmt.Printf("Processing batch %d/%d (%d photos)\n", batchNum, totalBatches, len(batchPaths))
+// ingestReport counts the outcome of the prepared photos
+type ingestReport struct {
+	Processed      int
+	DecodeFailures int
+	Rejected       []string
+}
 
-		// Read and process this batch
-		var batch []manul.PhotoItem
-		for _, path := range batchPaths {
-			filename := filepath.Base(path)
-			catID, photoID, ok := GetIDs(filename)
-			if !ok {
-				continue
+// ingest writes the prepared photos to writer in batches of up to
+// batchSize photos or batchBytes of photo data. The photos of each
+// committed batch are appended to the manifest. A file that cannot be read
+// stops the ingest, batches committed before are kept.
+func ingest(writer manul.DBWriter, results <-chan preparedPhoto, opts ingestOptions) (ingestReport, error) {
+	var report ingestReport
+	var batch manul.Batch
+	var batchKeys []manul.PhotoKey
+	var batchDataSize int64
+	batchNum := 0
+
+	// writeBatch commits the photos added to the batch
+	writeBatch := func() error {
+		batchNum++
+		fmt.Printf("Writing batch to DB %d (%d photos, %d bytes)\n", batchNum, len(batchKeys), batchDataSize)
+		if err := batch.Commit(); err != nil {
+			return fmt.Errorf("failed to process batch %d: %w", batchNum, err)
+		}
+		batch = nil
+		if opts.manifest != nil {
+			if err := opts.manifest.Append(batchKeys); err != nil {
+				return fmt.Errorf("failed to record batch %d in manifest: %w", batchNum, err)
+			}
+		}
+
+		report.Processed += len(batchKeys)
+		batchKeys = batchKeys[:0]
+		batchDataSize = 0
+		return nil
+	}
+
+	for res := range results {
+		switch {
+		case res.err != nil:
+			if batch != nil {
+				batch.Abort()
 			}
+			return report, fmt.Errorf("failed to process photo file %s: %w", res.path, res.err)
+		case res.rejected != "":
+			fmt.Printf("Rejecting %s: %s\n", res.path, res.rejected)
+			report.Rejected = append(report.Rejected, res.path)
+			continue
+		case res.decodeErr != nil:
+			// A file that cannot be decoded for conversion or scaling, e.g.
+			// a corrupt photo, is skipped instead of failing the whole run
+			fmt.Printf("Skipping %s: %v\n", res.path, res.decodeErr)
+			report.DecodeFailures++
+			continue
+		}
 
-			photoData, err := os.ReadFile(path)
+		// Photos are streamed into a batch, which holds them in memory only
+		// if the database has no native batches (see manul.NewBatch)
+		if batch == nil {
+			var err error
+			batch, err = manul.NewBatch(writer)
 			if err != nil {
-				log.Fatalf("Failed to read photo file %s: %v", path, err)
+				return report, fmt.Errorf("failed to start batch %d: %w", batchNum+1, err)
 			}
+		}
+		if err := batch.Add(res.item); err != nil {
+			batch.Abort()
+			return report, fmt.Errorf("failed to add photo file %s to batch %d: %w", res.path, batchNum+1, err)
+		}
+		batchKeys = append(batchKeys, manul.PhotoKey{CatID: res.item.CatID, PhotoID: res.item.PhotoID})
+		batchDataSize += int64(len(res.item.PhotoData))
+
+		fmt.Printf("  Added photo: cat_id=%d, photo_id=%d, size=%d bytes\n",
+			res.item.CatID, res.item.PhotoID, len(res.item.PhotoData))
 
-			// Scale the image if needed
-			if *scale < 1.0 {
-				scaledData, err := scaleImage(photoData, *scale)
-				if err != nil {
-					log.Fatalf("Failed to scale photo file %s: %v", path, err)
-				}
-				photoData = scaledData
+		if len(batchKeys) >= opts.batchSize || (opts.batchBytes > 0 && batchDataSize >= opts.batchBytes) {
+			if err := writeBatch(); err != nil {
+				return report, err
 			}
+		}
+	}
 
-			batch = append(batch, manul.PhotoItem{
-				CatID:     catID,
-				PhotoID:   photoID,
-				FilePath:  path,
-				PhotoData: photoData,
-			})
+	if batch != nil {
+		if err := writeBatch(); err != nil {
+			return report, err
+		}
+	}
+	return report, nil
+}
 
-			fmt.Printf("  Added photo: cat_id=%d, photo_id=%d, size=%d bytes\n",
-				catID, photoID, len(photoData))
+// prepareOptions configure how source files are prepared for the database
+type prepareOptions struct {
+	scale      float64
+	storeMtime bool
+	maxDim     int
+	oversize   string
+	quality    int
+}
+
+// preparedPhoto is a source file read and scaled for the database, or the
+// reason it is not added
+type preparedPhoto struct {
+	path      string
+	item      manul.PhotoItem
+	rejected  string // why the photo exceeds the max dimension, if it does
+	decodeErr error  // the photo cannot be decoded, the file is skipped
+	err       error  // reading the file failed, the run fails
+}
+
+// preparePhotos prepares the files on workers goroutines and returns their
+// results in the order they are ready. The channel is closed after all
+// files are prepared.
+func preparePhotos(files []sourceFile, workers int, opts prepareOptions) <-chan preparedPhoto {
+	jobs := make(chan sourceFile)
+	results := make(chan preparedPhoto, workers)
+
+	go func() {
+		for _, file := range files {
+			jobs <- file
 		}
+		close(jobs)
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for file := range jobs {
+				results <- preparePhoto(file, opts)
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	return results
+}
 
-		fmt.Printf("Writing batch to DB %d/%d (%d photos)\n", batchNum, totalBatches, len(batch))
-		if err := writer.AddPhotosBatch(batch); err != nil {
-			log.Fatalf("Failed to process batch %d: %v", batchNum, err)
+// preparePhoto reads a source file and converts and scales it as set by opts
+func preparePhoto(file sourceFile, opts prepareOptions) preparedPhoto {
+	path := file.path
+	res := preparedPhoto{path: path}
+
+	// Check the size from the image header before reading the whole file
+	if opts.maxDim > 0 && opts.oversize == "reject" {
+		width, height, err := imageDimensions(path)
+		if err != nil {
+			res.rejected = fmt.Sprintf("cannot check dimensions: %v", err)
+			return res
 		}
+		width, height = int(float64(width)*opts.scale), int(float64(height)*opts.scale)
+		if width > opts.maxDim || height > opts.maxDim {
+			res.rejected = fmt.Sprintf("%dx%d exceeds max dimension %d", width, height, opts.maxDim)
+			return res
+		}
+	}
 
-		processedFiles += len(batch)
+	photoData, err := os.ReadFile(path)
+	if err != nil {
+		res.err = fmt.Errorf("failed to read file: %w", err)
+		return res
 	}
 
-	fmt.Printf("\nDatabase build completed successfully:\n")
-	fmt.Printf("  Database type: %s\n", *dbType)
-	fmt.Printf("  Database path: %s\n", *dbPath)
-	fmt.Printf("  Total files found: %d\n", totalFiles)
-	fmt.Printf("  Files processed: %d\n", processedFiles)
-	fmt.Printf("  Files skipped: %d\n", skippedFiles)
+	// WebP is converted, the server only serves JPEG, PNG and GIF
+	if manul.DetectFormat(photoData) == manul.FormatWebP {
+		photoData, err = convertImage(photoData, opts.quality)
+		if err != nil {
+			res.decodeErr = err
+			return res
+		}
+	}
 
-	// Show database size/info
-	switch *dbType {
-	case "filetree":
-		fmt.Printf("  Database created in directory: %s\n", *dbPath)
-	case "bolt", "pebble":
-		if stat, err := os.Stat(*dbPath); err == nil {
-			fmt.Printf("  Database size: %d bytes\n", stat.Size())
+	var createdAt time.Time
+	if opts.storeMtime {
+		info, err := os.Stat(path)
+		if err != nil {
+			res.err = fmt.Errorf("failed to stat file: %w", err)
+			return res
+		}
+		createdAt = info.ModTime()
+	}
+
+	// Scale the image if needed
+	if opts.scale < 1.0 {
+		photoData, err = scaleImage(photoData, opts.scale, opts.quality)
+		if err != nil {
+			res.decodeErr = err
+			return res
 		}
 	}
+
+	if opts.maxDim > 0 && opts.oversize == "downscale" {
+		photoData, err = fitDimension(photoData, opts.maxDim, opts.quality)
+		if err != nil {
+			res.decodeErr = err
+			return res
+		}
+	}
+
+	res.item = manul.PhotoItem{
+		CatID:     file.catID,
+		PhotoID:   file.photoID,
+		FilePath:  path,
+		PhotoData: photoData,
+		CreatedAt: createdAt,
+	}
+	return res
 }
 
-func GetIDs(filename string) (catID, photoID uint64, ok bool) {
-	var cat, photo uint64
-	n, err := fmt.Sscanf(strings.ToLower(filename), "%d_%d.jpg", &cat, &photo)
-	if err != nil || n != 2 {
-		return 0, 0, false
+// sourceFile is a photo file found in a source directory
+type sourceFile struct {
+	path    string
+	catID   uint64
+	photoID uint64
+}
+
+// scanSources walks the source directories and returns the photo files
+// whose paths relative to their directory match parser and have a known
+// decoder. Files of a photo found before, in an earlier directory or with
+// another extension, are reported and skipped. total and skipped count the
+// files in all directories.
+func scanSources(dirs []string, parser *manul.FilenameParser) (files []sourceFile, total, skipped int, err error) {
+	found := make(map[manul.PhotoKey]string) // Path of each photo
+	for _, dir := range dirs {
+		fmt.Printf("Scanning directory: %s\n", dir)
+		err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+
+			if info.IsDir() {
+				return nil
+			}
+
+			total++
+			filename := info.Name()
+			rel, err := filepath.Rel(dir, path)
+			if err != nil {
+				return err
+			}
+			catID, photoID, ok := parser.Parse(filepath.ToSlash(rel))
+			if !ok {
+				skipped++
+				fmt.Printf("Skipping %s: cannot extract cat_id and photo_id\n", path)
+				return nil
+			}
+
+			if ext := strings.ToLower(filepath.Ext(filename)); !sourceExtensions[ext] {
+				skipped++
+				fmt.Printf("Skipping %s: no decoder for %s files\n", path, ext)
+				return nil
+			}
+
+			key := manul.PhotoKey{CatID: catID, PhotoID: photoID}
+			if first, ok := found[key]; ok {
+				skipped++
+				fmt.Printf("Skipping %s: cat_id=%d, photo_id=%d is already read from %s\n", path, catID, photoID, first)
+				return nil
+			}
+			found[key] = path
+
+			files = append(files, sourceFile{path: path, catID: catID, photoID: photoID})
+			return nil
+		})
+		if err != nil {
+			return nil, total, skipped, err
+		}
 	}
-	return cat, photo, true
+	return files, total, skipped, nil
 }
 
 // scaleImage scales an image by the given factor using bilinear interpolation
-func scaleImage(photoData []byte, scaleFactor float64) ([]byte, error) {
+// and encodes it as JPEG with quality, 0 means the default quality
+func scaleImage(photoData []byte, scaleFactor float64, quality int) ([]byte, error) {
 	if scaleFactor == 1.0 {
 		return photoData, nil
 	}
 
-	// Decode the JPEG image
-	img, err := jpeg.Decode(bytes.NewReader(photoData))
+	// Decode the image, the result is always JPEG
+	img, _, err := image.Decode(bytes.NewReader(photoData))
 	if err != nil {
 		return nil, fmt.Errorf("failed to decode image: %w", err)
 	}
@@ -204,11 +556,69 @@ func scaleImage(photoData []byte, scaleFactor float64) ([]byte, error) {
 	// Use bilinear interpolation for scaling
 	draw.BiLinear.Scale(scaledImg, scaledImg.Bounds(), img, bounds, draw.Over, nil)
 
-	// Encode the scaled image back to JPEG with default quality
+	// Encode the scaled image back to JPEG
 	var buf bytes.Buffer
-	if err := jpeg.Encode(&buf, scaledImg, nil); err != nil {
+	if err := jpeg.Encode(&buf, scaledImg, jpegOptions(quality, jpeg.DefaultQuality)); err != nil {
 		return nil, fmt.Errorf("failed to encode scaled image: %w", err)
 	}
 
 	return buf.Bytes(), nil
-}
\ No newline at end of file
+}
+
+// imageDimensions reads the image size from the file header only
+func imageDimensions(path string) (width, height int, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+
+	config, _, err := image.DecodeConfig(f)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to decode image header: %w", err)
+	}
+	return config.Width, config.Height, nil
+}
+
+// fitDimension downscales an image so neither side exceeds maxDim,
+// images within the limit are returned unchanged
+func fitDimension(photoData []byte, maxDim, quality int) ([]byte, error) {
+	config, _, err := image.DecodeConfig(bytes.NewReader(photoData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image header: %w", err)
+	}
+
+	longest := max(config.Width, config.Height)
+	if longest <= maxDim {
+		return photoData, nil
+	}
+	return scaleImage(photoData, float64(maxDim)/float64(longest), quality)
+}
+
+// jpegOptions returns the JPEG options for quality, 0 means defaultQuality
+func jpegOptions(quality, defaultQuality int) *jpeg.Options {
+	if quality == 0 {
+		quality = defaultQuality
+	}
+	return &jpeg.Options{Quality: quality}
+}
+
+// convertImage re-encodes an image as JPEG with quality, or as PNG if it
+// has transparent pixels
+func convertImage(photoData []byte, quality int) ([]byte, error) {
+	img, _, err := image.Decode(bytes.NewReader(photoData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	var buf bytes.Buffer
+	if opaque, ok := img.(interface{ Opaque() bool }); ok && !opaque.Opaque() {
+		err = png.Encode(&buf, img)
+	} else {
+		err = jpeg.Encode(&buf, img, jpegOptions(quality, 90))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode image: %w", err)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/mhbvr/manul"
+	"github.com/mhbvr/manul/db/memory"
+)
+
+func TestScaleImage_Formats(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 20, 10))
+	for x := 0; x < 20; x++ {
+		for y := 0; y < 10; y++ {
+			img.Set(x, y, color.RGBA{uint8(x * 10), uint8(y * 20), 100, 255})
+		}
+	}
+	encoders := map[string]func(*bytes.Buffer) error{
+		"jpeg": func(buf *bytes.Buffer) error { return jpeg.Encode(buf, img, nil) },
+		"png":  func(buf *bytes.Buffer) error { return png.Encode(buf, img) },
+		"gif":  func(buf *bytes.Buffer) error { return gif.Encode(buf, img, nil) },
+	}
+
+	for name, encode := range encoders {
+		var buf bytes.Buffer
+		if err := encode(&buf); err != nil {
+			t.Fatalf("Failed to encode %s: %v", name, err)
+		}
+		scaled, err := scaleImage(buf.Bytes(), 0.5, 0)
+		if err != nil {
+			t.Errorf("scaleImage() of %s failed: %v", name, err)
+			continue
+		}
+		if format := manul.DetectFormat(scaled); format != manul.FormatJPEG {
+			t.Errorf("scaleImage() of %s returned %v, want JPEG", name, format)
+		}
+		cfg, _, err := image.DecodeConfig(bytes.NewReader(scaled))
+		if err != nil || cfg.Width != 10 || cfg.Height != 5 {
+			t.Errorf("scaleImage() of %s returned %dx%d, %v, want 10x5", name, cfg.Width, cfg.Height, err)
+		}
+	}
+
+	if _, err := scaleImage([]byte("not an image"), 0.5, 0); err == nil {
+		t.Errorf("scaleImage() of invalid data succeeded, want error")
+	}
+}
+
+func TestPreparePhotos(t *testing.T) {
+	dir := t.TempDir()
+	var photo bytes.Buffer
+	if err := jpeg.Encode(&photo, image.NewRGBA(image.Rect(0, 0, 20, 10)), nil); err != nil {
+		t.Fatalf("Failed to encode photo: %v", err)
+	}
+	var files []sourceFile
+	for i := 1; i <= 20; i++ {
+		data := photo.Bytes()
+		if i == 7 {
+			data = []byte("corrupt")
+		}
+		path := filepath.Join(dir, fmt.Sprintf("1_%d.jpg", i))
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+		files = append(files, sourceFile{path: path, catID: 1, photoID: uint64(i)})
+	}
+	files = append(files, sourceFile{path: filepath.Join(dir, "1_21.jpg"), catID: 1, photoID: 21})
+
+	got := make(map[uint64]bool)
+	var decodeErrs, errs int
+	for res := range preparePhotos(files, 4, prepareOptions{scale: 0.5}) {
+		switch {
+		case res.err != nil:
+			errs++
+		case res.decodeErr != nil:
+			decodeErrs++
+		default:
+			got[res.item.PhotoID] = true
+			cfg, _, err := image.DecodeConfig(bytes.NewReader(res.item.PhotoData))
+			if err != nil || cfg.Width != 10 {
+				t.Errorf("Photo %s is %dx%d, %v, want 10x5", res.path, cfg.Width, cfg.Height, err)
+			}
+		}
+	}
+
+	// The corrupt photo is skipped, reading the missing one fails
+	if len(got) != 19 || got[7] || decodeErrs != 1 || errs != 1 {
+		t.Errorf("preparePhotos() prepared %d photos with %d decode errors and %d errors, want 19, 1 and 1", len(got), decodeErrs, errs)
+	}
+}
+
+func TestScanSources(t *testing.T) {
+	dirs := []string{t.TempDir(), t.TempDir()}
+	// 1_2 is found in both directories and 2_1 with two extensions
+	names := [][]string{
+		{"1_1.jpg", "notes.txt", "sub/1_2.png"},
+		{"1_2.jpg", "2_1.jpg", "2_1.png", "2_2.heic"},
+	}
+	for i, dir := range dirs {
+		for _, name := range names[i] {
+			path := filepath.Join(dir, name)
+			if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+				t.Fatalf("Failed to create %s: %v", filepath.Dir(path), err)
+			}
+			if err := os.WriteFile(path, []byte("photo"), 0o644); err != nil {
+				t.Fatalf("Failed to write %s: %v", path, err)
+			}
+		}
+	}
+
+	parser, err := manul.NewFilenameParser(manul.DefaultFilenameFormat)
+	if err != nil {
+		t.Fatalf("NewFilenameParser() failed: %v", err)
+	}
+	files, total, skipped, err := scanSources(dirs, parser)
+	if err != nil {
+		t.Fatalf("scanSources() failed: %v", err)
+	}
+	if len(files) != 3 || total != 7 || skipped != 4 {
+		t.Errorf("scanSources() = %d files, %d total, %d skipped, want 3, 7, 4", len(files), total, skipped)
+	}
+	for _, want := range []sourceFile{
+		{path: filepath.Join(dirs[0], "sub/1_2.png"), catID: 1, photoID: 2},
+		{path: filepath.Join(dirs[1], "2_1.jpg"), catID: 2, photoID: 1},
+	} {
+		if !slices.Contains(files, want) {
+			t.Errorf("scanSources() = %v, want it to contain %v", files, want)
+		}
+	}
+
+	if _, _, _, err := scanSources([]string{filepath.Join(dirs[0], "missing")}, parser); err == nil {
+		t.Errorf("scanSources() of a missing directory succeeded, want error")
+	}
+}
+
+// preparedResults returns a closed channel of results
+func preparedResults(results []preparedPhoto) <-chan preparedPhoto {
+	ch := make(chan preparedPhoto, len(results))
+	for _, res := range results {
+		ch <- res
+	}
+	close(ch)
+	return ch
+}
+
+func TestIngest_Resume(t *testing.T) {
+	db := memory.New()
+	m, err := openManifest(filepath.Join(t.TempDir(), "manifest"))
+	if err != nil {
+		t.Fatalf("openManifest() failed: %v", err)
+	}
+	defer m.Close()
+
+	var files []sourceFile
+	var results []preparedPhoto
+	for photoID := uint64(1); photoID <= 10; photoID++ {
+		file := sourceFile{path: fmt.Sprintf("1_%d.jpg", photoID), catID: 1, photoID: photoID}
+		files = append(files, file)
+		results = append(results, preparedPhoto{
+			path: file.path,
+			item: manul.PhotoItem{CatID: 1, PhotoID: photoID, PhotoData: []byte("photo")},
+		})
+	}
+
+	// The first run fails reading photo 6, after committing two batches
+	crashed := slices.Clone(results[:6])
+	crashed[5] = preparedPhoto{path: "1_6.jpg", err: errors.New("input/output error")}
+	report, err := ingest(db, preparedResults(crashed), ingestOptions{batchSize: 2, manifest: m})
+	if err == nil {
+		t.Fatalf("ingest() succeeded, want error")
+	}
+	if report.Processed != 4 || m.Len() != 4 {
+		t.Errorf("ingest() processed %d photos, %d in manifest, want 4", report.Processed, m.Len())
+	}
+
+	// The restart skips the photos of committed batches
+	remaining, ingested, err := skipIngested(slices.Clone(files), m, db, false)
+	if err != nil {
+		t.Fatalf("skipIngested() failed: %v", err)
+	}
+	if ingested != 4 || len(remaining) != 6 || remaining[0].photoID != 5 {
+		t.Fatalf("skipIngested() = %v, %d ingested, want photos 5 to 10", remaining, ingested)
+	}
+	report, err = ingest(db, preparedResults(results[4:]), ingestOptions{batchSize: 2, manifest: m})
+	if err != nil {
+		t.Fatalf("ingest() failed: %v", err)
+	}
+	if report.Processed != 6 || m.Len() != 10 {
+		t.Errorf("ingest() processed %d photos, %d in manifest, want 6 and 10", report.Processed, m.Len())
+	}
+	if count, err := db.CountAllPhotos(); err != nil || count != 10 {
+		t.Errorf("CountAllPhotos() = %d, %v, want 10", count, err)
+	}
+
+	// Without a manifest, -skip-existing checks the database
+	remaining, ingested, err = skipIngested(slices.Clone(files), nil, db, true)
+	if err != nil || len(remaining) != 0 || ingested != 10 {
+		t.Errorf("skipIngested() with skipExisting = %v, %d, %v, want all 10 ingested", remaining, ingested, err)
+	}
+}
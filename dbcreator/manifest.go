@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/mhbvr/manul"
+)
+
+// manifest records the photos committed to the database, one
+// "<cat_id> <photo_id>" line per photo, so an interrupted run can be
+// resumed without ingesting them again. The photos of a batch are appended
+// with a single write after the batch is committed.
+type manifest struct {
+	f         *os.File
+	committed map[manul.PhotoKey]bool
+}
+
+// openManifest reads the manifest at path, creating it if needed, and opens
+// it for appending. A last line cut short by a crash is removed.
+func openManifest(path string) (*manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	// Only complete lines were committed
+	complete := data[:bytes.LastIndexByte(data, '\n')+1]
+	m := &manifest{committed: make(map[manul.PhotoKey]bool)}
+	for i, line := range strings.Split(strings.TrimSuffix(string(complete), "\n"), "\n") {
+		if line == "" {
+			continue
+		}
+		key, err := parseManifestLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("invalid manifest line %d: %w", i+1, err)
+		}
+		m.committed[key] = true
+	}
+
+	m.f, err = os.OpenFile(path, os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.f.Truncate(int64(len(complete))); err != nil {
+		m.f.Close()
+		return nil, err
+	}
+	if _, err := m.f.Seek(int64(len(complete)), io.SeekStart); err != nil {
+		m.f.Close()
+		return nil, err
+	}
+	return m, nil
+}
+
+func parseManifestLine(line string) (manul.PhotoKey, error) {
+	fields := strings.Fields(line)
+	if len(fields) != 2 {
+		return manul.PhotoKey{}, fmt.Errorf("want <cat_id> <photo_id>, got %q", line)
+	}
+	catID, err := strconv.ParseUint(fields[0], 10, 64)
+	if err != nil {
+		return manul.PhotoKey{}, err
+	}
+	photoID, err := strconv.ParseUint(fields[1], 10, 64)
+	if err != nil {
+		return manul.PhotoKey{}, err
+	}
+	return manul.PhotoKey{CatID: catID, PhotoID: photoID}, nil
+}
+
+// Contains reports whether the photo was committed by a previous batch
+func (m *manifest) Contains(catID, photoID uint64) bool {
+	return m.committed[manul.PhotoKey{CatID: catID, PhotoID: photoID}]
+}
+
+// Len returns the number of committed photos
+func (m *manifest) Len() int {
+	return len(m.committed)
+}
+
+// Append records the photos of a committed batch and syncs the manifest
+// to disk
+func (m *manifest) Append(keys []manul.PhotoKey) error {
+	var buf bytes.Buffer
+	for _, key := range keys {
+		fmt.Fprintf(&buf, "%d %d\n", key.CatID, key.PhotoID)
+	}
+	if _, err := m.f.Write(buf.Bytes()); err != nil {
+		return err
+	}
+	if err := m.f.Sync(); err != nil {
+		return err
+	}
+	for _, key := range keys {
+		m.committed[key] = true
+	}
+	return nil
+}
+
+func (m *manifest) Close() error {
+	return m.f.Close()
+}
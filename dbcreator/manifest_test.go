@@ -0,0 +1,54 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mhbvr/manul"
+)
+
+func TestManifest(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "manifest")
+	m, err := openManifest(path)
+	if err != nil {
+		t.Fatalf("openManifest() failed: %v", err)
+	}
+	if err := m.Append([]manul.PhotoKey{{CatID: 1, PhotoID: 1}, {CatID: 1, PhotoID: 2}}); err != nil {
+		t.Fatalf("Append() failed: %v", err)
+	}
+	m.Close()
+
+	// A crash in the middle of an append leaves a partial line
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatalf("Failed to open manifest: %v", err)
+	}
+	f.WriteString("2 ")
+	f.Close()
+
+	m, err = openManifest(path)
+	if err != nil {
+		t.Fatalf("openManifest() after a partial append failed: %v", err)
+	}
+	if m.Len() != 2 || !m.Contains(1, 2) || m.Contains(2, 1) {
+		t.Errorf("Manifest has %d photos, want photos 1 and 2 of cat 1", m.Len())
+	}
+	if err := m.Append([]manul.PhotoKey{{CatID: 2, PhotoID: 1}}); err != nil {
+		t.Fatalf("Append() failed: %v", err)
+	}
+	m.Close()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read manifest: %v", err)
+	}
+	if want := "1 1\n1 2\n2 1\n"; string(data) != want {
+		t.Errorf("Manifest = %q, want %q", data, want)
+	}
+
+	os.WriteFile(path, []byte("1 1\nnot a photo\n"), 0o644)
+	if _, err := openManifest(path); err == nil {
+		t.Errorf("openManifest() of an invalid manifest succeeded, want error")
+	}
+}
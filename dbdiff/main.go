@@ -0,0 +1,228 @@
+package main
+
+import (
+	"crypto/sha256"
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"time"
+
+	"github.com/mhbvr/manul"
+	"github.com/mhbvr/manul/db/bolt"
+	"github.com/mhbvr/manul/db/filetree"
+	"github.com/mhbvr/manul/db/pebble"
+)
+
+// pageSize is the number of IDs read from a database at a time
+const pageSize = 1000
+
+// Difference kinds
+const (
+	diffMissing   = "missing"   // In the source only
+	diffExtra     = "extra"     // In the destination only
+	diffDifferent = "different" // In both with different photo data
+)
+
+// diffReport counts the compared photos and the differences found
+type diffReport struct {
+	Compared  int
+	Missing   int
+	Extra     int
+	Different int
+}
+
+func (r diffReport) differences() int {
+	return r.Missing + r.Extra + r.Different
+}
+
+// idIterator walks IDs in ascending order, reading a page at a time
+type idIterator struct {
+	list  func(start uint64, limit int) ([]uint64, error)
+	page  []uint64
+	start uint64
+	done  bool
+}
+
+func newIDIterator(list func(start uint64, limit int) ([]uint64, error)) *idIterator {
+	return &idIterator{list: list}
+}
+
+// peek returns the current ID, ok is false when all IDs are read
+func (it *idIterator) peek() (id uint64, ok bool, err error) {
+	if len(it.page) == 0 && !it.done {
+		it.page, err = it.list(it.start, pageSize)
+		if err != nil {
+			return 0, false, err
+		}
+		if len(it.page) < pageSize {
+			it.done = true
+		} else if last := it.page[len(it.page)-1]; last == math.MaxUint64 {
+			it.done = true
+		} else {
+			it.start = last + 1
+		}
+	}
+	if len(it.page) == 0 {
+		return 0, false, nil
+	}
+	return it.page[0], true, nil
+}
+
+func (it *idIterator) next() {
+	it.page = it.page[1:]
+}
+
+// emptyIterator returns an iterator without IDs
+func emptyIterator() *idIterator {
+	return &idIterator{done: true}
+}
+
+// mergeIDs calls visit for every ID of a or b in ascending order
+func mergeIDs(a, b *idIterator, visit func(id uint64, inA, inB bool) error) error {
+	for {
+		idA, okA, err := a.peek()
+		if err != nil {
+			return err
+		}
+		idB, okB, err := b.peek()
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case !okA && !okB:
+			return nil
+		case okA && (!okB || idA < idB):
+			a.next()
+			err = visit(idA, true, false)
+		case okB && (!okA || idB < idA):
+			b.next()
+			err = visit(idB, false, true)
+		default:
+			a.next()
+			b.next()
+			err = visit(idA, true, true)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// photoChecksum returns the SHA-256 checksum of the photo data
+func photoChecksum(db manul.DBReader, catID, photoID uint64) ([sha256.Size]byte, error) {
+	data, err := db.GetPhotoData(catID, photoID)
+	if err != nil {
+		return [sha256.Size]byte{}, err
+	}
+	return sha256.Sum256(data), nil
+}
+
+// diffDBs compares the photos of src and dst and calls report for every
+// difference. With deep set, photos in both databases are compared by the
+// checksums of their data.
+func diffDBs(src, dst manul.DBReader, deep bool, report func(kind string, catID, photoID uint64)) (diffReport, error) {
+	var res diffReport
+	photoIterator := func(db manul.DBReader, catID uint64, ok bool) *idIterator {
+		if !ok {
+			return emptyIterator()
+		}
+		return newIDIterator(func(start uint64, limit int) ([]uint64, error) {
+			return db.ListPhotoIDs(catID, start, limit)
+		})
+	}
+
+	err := mergeIDs(newIDIterator(src.ListCatIDs), newIDIterator(dst.ListCatIDs), func(catID uint64, inSrc, inDst bool) error {
+		return mergeIDs(photoIterator(src, catID, inSrc), photoIterator(dst, catID, inDst), func(photoID uint64, inSrc, inDst bool) error {
+			res.Compared++
+			switch {
+			case !inDst:
+				res.Missing++
+				report(diffMissing, catID, photoID)
+			case !inSrc:
+				res.Extra++
+				report(diffExtra, catID, photoID)
+			case deep:
+				srcSum, err := photoChecksum(src, catID, photoID)
+				if err != nil {
+					return fmt.Errorf("failed to read source photo cat_id=%d photo_id=%d: %w", catID, photoID, err)
+				}
+				dstSum, err := photoChecksum(dst, catID, photoID)
+				if err != nil {
+					return fmt.Errorf("failed to read destination photo cat_id=%d photo_id=%d: %w", catID, photoID, err)
+				}
+				if srcSum != dstSum {
+					res.Different++
+					report(diffDifferent, catID, photoID)
+				}
+			}
+			return nil
+		})
+	})
+	return res, err
+}
+
+// openDB opens a database read-only
+func openDB(dbPath, dbType string, timeout time.Duration) (manul.DBReader, error) {
+	switch dbType {
+	case "filetree":
+		return filetree.NewReader(dbPath, filetree.WithTimeout(timeout))
+	case "bolt":
+		return bolt.NewReader(dbPath, bolt.WithTimeout(timeout))
+	case "pebble":
+		return pebble.NewReader(dbPath, pebble.WithTimeout(timeout))
+	}
+	return nil, fmt.Errorf("unknown database type: %s (must be 'filetree', 'bolt', or 'pebble')", dbType)
+}
+
+func main() {
+	var (
+		srcPath  = flag.String("src", "", "Source database path (directory for filetree, file for bolt/pebble)")
+		srcType  = flag.String("src-type", "filetree", "Source database type: filetree, bolt, or pebble")
+		dstPath  = flag.String("dst", "", "Destination database path (directory for filetree, file for bolt/pebble)")
+		dstType  = flag.String("dst-type", "filetree", "Destination database type: filetree, bolt, or pebble")
+		deep     = flag.Bool("deep", false, "Compare the data checksums of photos in both databases")
+		list     = flag.Bool("list", false, "List differing photos")
+		maxDiffs = flag.Int("max-diffs", 1000, "Maximum number of differing photos to list (-1 = all)")
+		timeout  = flag.Duration("open-timeout", 10*time.Second, "Max time to wait for the database lock held by another process")
+	)
+	flag.Parse()
+
+	if *srcPath == "" || *dstPath == "" {
+		log.Fatal("Database paths must be specified with -src and -dst flags")
+	}
+
+	src, err := openDB(*srcPath, *srcType, *timeout)
+	if err != nil {
+		log.Fatalf("Failed to open source database: %v", err)
+	}
+	defer src.Close()
+
+	dst, err := openDB(*dstPath, *dstType, *timeout)
+	if err != nil {
+		log.Fatalf("Failed to open destination database: %v", err)
+	}
+	defer dst.Close()
+
+	listed := 0
+	report, err := diffDBs(src, dst, *deep, func(kind string, catID, photoID uint64) {
+		if *list && (*maxDiffs < 0 || listed < *maxDiffs) {
+			fmt.Printf("cat_id=%d photo_id=%d: %s\n", catID, photoID, kind)
+			listed++
+		}
+	})
+	if err != nil {
+		log.Fatalf("Comparison failed: %v", err)
+	}
+
+	fmt.Printf("Compared: %d, missing in destination: %d, extra in destination: %d, different: %d\n",
+		report.Compared, report.Missing, report.Extra, report.Different)
+
+	if report.differences() > 0 {
+		src.Close()
+		dst.Close()
+		os.Exit(1)
+	}
+}
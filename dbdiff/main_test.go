@@ -0,0 +1,78 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/mhbvr/manul"
+	"github.com/mhbvr/manul/db/memory"
+)
+
+func TestDiffDBs(t *testing.T) {
+	src := memory.New()
+	dst := memory.New()
+	add := func(db *memory.MemoryDB, catID, photoID uint64, data string) {
+		t.Helper()
+		if err := db.AddPhoto(catID, photoID, []byte(data)); err != nil {
+			t.Fatalf("AddPhoto() failed: %v", err)
+		}
+	}
+
+	add(src, 1, 1, "same")
+	add(dst, 1, 1, "same")
+	add(src, 1, 2, "source")
+	add(dst, 1, 2, "changed")
+	add(src, 1, 3, "missing")
+	add(src, 2, 1, "missing cat")
+	add(dst, 3, 1, "extra cat")
+
+	tests := []struct {
+		deep bool
+		want diffReport
+	}{
+		{false, diffReport{Compared: 5, Missing: 2, Extra: 1}},
+		{true, diffReport{Compared: 5, Missing: 2, Extra: 1, Different: 1}},
+	}
+
+	for _, tt := range tests {
+		var reported []manul.PhotoKey
+		got, err := diffDBs(src, dst, tt.deep, func(kind string, catID, photoID uint64) {
+			reported = append(reported, manul.PhotoKey{CatID: catID, PhotoID: photoID})
+		})
+		if err != nil {
+			t.Fatalf("diffDBs(deep=%v) failed: %v", tt.deep, err)
+		}
+		if got != tt.want {
+			t.Errorf("diffDBs(deep=%v) = %+v, want %+v", tt.deep, got, tt.want)
+		}
+		if len(reported) != got.differences() {
+			t.Errorf("diffDBs(deep=%v) reported %v, want %d differences", tt.deep, reported, got.differences())
+		}
+	}
+}
+
+func TestIDIterator_Pages(t *testing.T) {
+	ids := make([]uint64, 2*pageSize+1)
+	for i := range ids {
+		ids[i] = uint64(i) * 2
+	}
+	db := memory.New()
+	for _, id := range ids {
+		if err := db.AddPhoto(1, id, []byte("photo")); err != nil {
+			t.Fatalf("AddPhoto() failed: %v", err)
+		}
+	}
+
+	it := newIDIterator(func(start uint64, limit int) ([]uint64, error) {
+		return db.ListPhotoIDs(1, start, limit)
+	})
+	for _, want := range ids {
+		got, ok, err := it.peek()
+		if err != nil || !ok || got != want {
+			t.Fatalf("peek() = %d, %v, %v, want %d", got, ok, err, want)
+		}
+		it.next()
+	}
+	if _, ok, _ := it.peek(); ok {
+		t.Errorf("peek() after the last ID ok = true, want false")
+	}
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/mhbvr/manul"
+	"github.com/mhbvr/manul/db/bolt"
+	"github.com/mhbvr/manul/db/filetree"
+	"github.com/mhbvr/manul/db/pebble"
+	"github.com/mhbvr/manul/db/sqlite"
+)
+
+// migrateOptions configure a migration
+type migrateOptions struct {
+	batchSize  int
+	batchBytes int64 // 0 = no limit
+	// skipExisting skips photos already in the destination, so an
+	// interrupted migration can be resumed
+	skipExisting bool
+	// progress is called after each written batch with the totals so far
+	progress func(batch batchReport, total migrateReport)
+}
+
+// batchReport describes a written batch
+type batchReport struct {
+	Num    int
+	Photos int
+	Bytes  int64
+}
+
+// migrateReport counts the copied and skipped photos and written batches
+type migrateReport struct {
+	Photos  int
+	Bytes   int64
+	Skipped int
+	Batches int
+}
+
+// migrate copies all photos of src to dst, keeping their creation times.
+// Photos are streamed from src with ForEachPhoto and written in batches of
+// up to batchSize photos or batchBytes of photo data, so memory use does
+// not grow with the database size.
+func migrate(src manul.DBReader, dst manul.DBReadWriter, opts migrateOptions) (migrateReport, error) {
+	var res migrateReport
+	var batch manul.Batch
+	var current batchReport
+
+	commit := func() error {
+		current.Num = res.Batches + 1
+		if err := batch.Commit(); err != nil {
+			return fmt.Errorf("failed to write batch %d: %w", current.Num, err)
+		}
+		res.Batches++
+		res.Photos += current.Photos
+		res.Bytes += current.Bytes
+		if opts.progress != nil {
+			opts.progress(current, res)
+		}
+		batch = nil
+		current = batchReport{}
+		return nil
+	}
+
+	err := src.ForEachPhoto(func(catID, photoID uint64) error {
+		if opts.skipExisting {
+			exists, err := dst.PhotoExists(catID, photoID)
+			if err != nil {
+				return fmt.Errorf("failed to check destination photo cat_id=%d photo_id=%d: %w", catID, photoID, err)
+			}
+			if exists {
+				res.Skipped++
+				return nil
+			}
+		}
+
+		data, err := src.GetPhotoData(catID, photoID)
+		if err != nil {
+			return fmt.Errorf("failed to read photo cat_id=%d photo_id=%d: %w", catID, photoID, err)
+		}
+		meta, err := src.GetPhotoMeta(catID, photoID)
+		if err != nil {
+			return fmt.Errorf("failed to read metadata of photo cat_id=%d photo_id=%d: %w", catID, photoID, err)
+		}
+
+		if batch == nil {
+			batch, err = manul.NewBatch(dst)
+			if err != nil {
+				return fmt.Errorf("failed to start batch %d: %w", res.Batches+1, err)
+			}
+		}
+		err = batch.Add(manul.PhotoItem{
+			CatID:     catID,
+			PhotoID:   photoID,
+			PhotoData: data,
+			CreatedAt: meta.CreatedAt,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to add photo cat_id=%d photo_id=%d: %w", catID, photoID, err)
+		}
+		current.Photos++
+		current.Bytes += int64(len(data))
+
+		if current.Photos >= opts.batchSize || (opts.batchBytes > 0 && current.Bytes >= opts.batchBytes) {
+			return commit()
+		}
+		return nil
+	})
+	if err != nil {
+		if batch != nil {
+			batch.Abort()
+		}
+		return res, err
+	}
+
+	if batch != nil {
+		err = commit()
+	}
+	return res, err
+}
+
+// openReader opens a database read-only
+func openReader(dbPath, dbType string, timeout time.Duration) (manul.DBReader, error) {
+	switch dbType {
+	case "filetree":
+		return filetree.NewReader(dbPath, filetree.WithTimeout(timeout))
+	case "bolt":
+		return bolt.NewReader(dbPath, bolt.WithTimeout(timeout))
+	case "pebble":
+		return pebble.NewReader(dbPath, pebble.WithTimeout(timeout))
+	case "sqlite":
+		return sqlite.NewReader(dbPath, sqlite.WithTimeout(timeout))
+	}
+	return nil, fmt.Errorf("unknown database type: %s (must be 'filetree', 'bolt', 'pebble', or 'sqlite')", dbType)
+}
+
+// openWriter opens a database for reading and writing, creating it if needed
+func openWriter(dbPath, dbType string, timeout time.Duration) (manul.DBReadWriter, error) {
+	switch dbType {
+	case "filetree":
+		return filetree.New(dbPath, filetree.WithTimeout(timeout))
+	case "bolt":
+		return bolt.New(dbPath, bolt.WithTimeout(timeout))
+	case "pebble":
+		return pebble.New(dbPath, pebble.WithTimeout(timeout))
+	case "sqlite":
+		return sqlite.New(dbPath, sqlite.WithTimeout(timeout))
+	}
+	return nil, fmt.Errorf("unknown database type: %s (must be 'filetree', 'bolt', 'pebble', or 'sqlite')", dbType)
+}
+
+func main() {
+	var (
+		srcPath      = flag.String("src", "", "Source database path (directory for filetree, file for bolt/pebble/sqlite)")
+		srcType      = flag.String("src-type", "bolt", "Source database type: filetree, bolt, pebble, or sqlite")
+		dstPath      = flag.String("dst", "", "Destination database path (directory for filetree, file for bolt/pebble/sqlite)")
+		dstType      = flag.String("dst-type", "pebble", "Destination database type: filetree, bolt, pebble, or sqlite")
+		batchSize    = flag.Int("batch-size", 100, "Number of photos to write in each transaction")
+		batchBytes   = flag.Int64("batch-bytes", 256<<20, "Max total photo bytes written in a transaction (0 = no limit)")
+		skipExisting = flag.Bool("skip-existing", false, "Skip photos already in the destination, to resume an interrupted migration")
+		quiet        = flag.Bool("quiet", false, "Do not print progress after each batch")
+		timeout      = flag.Duration("open-timeout", 10*time.Second, "Max time to wait for the database lock held by another process")
+	)
+	flag.Parse()
+
+	if *srcPath == "" || *dstPath == "" {
+		log.Fatal("Database paths must be specified with -src and -dst flags")
+	}
+	if *srcPath == *dstPath {
+		log.Fatal("Source and destination databases must differ")
+	}
+	if *batchSize <= 0 {
+		log.Fatal("-batch-size must be positive")
+	}
+
+	src, err := openReader(*srcPath, *srcType, *timeout)
+	if err != nil {
+		log.Fatalf("Failed to open source database: %v", err)
+	}
+	defer src.Close()
+
+	dst, err := openWriter(*dstPath, *dstType, *timeout)
+	if err != nil {
+		log.Fatalf("Failed to open destination database: %v", err)
+	}
+	defer dst.Close()
+
+	fmt.Printf("Migrating %s database %s to %s database %s\n", *srcType, *srcPath, *dstType, *dstPath)
+	start := time.Now()
+	opts := migrateOptions{
+		batchSize:    *batchSize,
+		batchBytes:   *batchBytes,
+		skipExisting: *skipExisting,
+	}
+	if !*quiet {
+		opts.progress = func(batch batchReport, total migrateReport) {
+			fmt.Printf("Wrote batch %d (%d photos, %d bytes), %d photos copied, %d skipped\n",
+				batch.Num, batch.Photos, batch.Bytes, total.Photos, total.Skipped)
+		}
+	}
+
+	report, err := migrate(src, dst, opts)
+	if err != nil {
+		log.Fatalf("Migration failed after %d photos, rerun with -skip-existing to resume: %v", report.Photos, err)
+	}
+
+	fmt.Printf("\nMigration completed in %v:\n", time.Since(start).Round(time.Millisecond))
+	fmt.Printf("  Photos copied: %d\n", report.Photos)
+	fmt.Printf("  Bytes written: %d\n", report.Bytes)
+	fmt.Printf("  Batches: %d\n", report.Batches)
+	if *skipExisting {
+		fmt.Printf("  Photos skipped (already in destination): %d\n", report.Skipped)
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/mhbvr/manul"
+	"github.com/mhbvr/manul/db/memory"
+)
+
+func TestMigrate(t *testing.T) {
+	src := memory.New()
+	createdAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	var photos []manul.PhotoItem
+	for catID := uint64(1); catID <= 3; catID++ {
+		for photoID := uint64(1); photoID <= 3; photoID++ {
+			photos = append(photos, manul.PhotoItem{CatID: catID, PhotoID: photoID, PhotoData: []byte{byte(catID), byte(photoID)}, CreatedAt: createdAt})
+		}
+	}
+	if err := src.AddPhotosBatch(photos); err != nil {
+		t.Fatalf("AddPhotosBatch() failed: %v", err)
+	}
+
+	dst := memory.New()
+	var batches []batchReport
+	report, err := migrate(src, dst, migrateOptions{
+		batchSize: 4,
+		progress: func(batch batchReport, total migrateReport) {
+			batches = append(batches, batch)
+		},
+	})
+	if err != nil {
+		t.Fatalf("migrate() failed: %v", err)
+	}
+	want := migrateReport{Photos: 9, Bytes: 18, Batches: 3}
+	if report != want {
+		t.Errorf("migrate() = %+v, want %+v", report, want)
+	}
+	wantBatches := []batchReport{{1, 4, 8}, {2, 4, 8}, {3, 1, 2}}
+	if !slices.Equal(batches, wantBatches) {
+		t.Errorf("migrate() reported batches %+v, want %+v", batches, wantBatches)
+	}
+
+	for _, photo := range photos {
+		data, err := dst.GetPhotoData(photo.CatID, photo.PhotoID)
+		if err != nil || !bytes.Equal(data, photo.PhotoData) {
+			t.Errorf("Migrated photo cat_id=%d photo_id=%d = %v, %v, want %v", photo.CatID, photo.PhotoID, data, err, photo.PhotoData)
+		}
+		meta, err := dst.GetPhotoMeta(photo.CatID, photo.PhotoID)
+		if err != nil || !meta.CreatedAt.Equal(createdAt) {
+			t.Errorf("Migrated photo cat_id=%d photo_id=%d created at %v, %v, want %v", photo.CatID, photo.PhotoID, meta.CreatedAt, err, createdAt)
+		}
+	}
+}
+
+// failingWriter fails all writes
+type failingWriter struct {
+	*memory.MemoryDB
+}
+
+func (w failingWriter) AddPhotosBatch(photos []manul.PhotoItem) error {
+	return errors.New("disk full")
+}
+
+func TestMigrate_WriteError(t *testing.T) {
+	src := memory.New()
+	for photoID := uint64(1); photoID <= 5; photoID++ {
+		if err := src.AddPhoto(1, photoID, []byte("photo")); err != nil {
+			t.Fatalf("AddPhoto() failed: %v", err)
+		}
+	}
+
+	report, err := migrate(src, failingWriter{memory.New()}, migrateOptions{batchSize: 2})
+	if err == nil {
+		t.Fatal("migrate() to a failing writer succeeded")
+	}
+	if report.Photos != 0 || report.Batches != 0 {
+		t.Errorf("migrate() to a failing writer = %+v, want nothing written", report)
+	}
+}
+
+func TestMigrate_SkipExisting(t *testing.T) {
+	src := memory.New()
+	dst := memory.New()
+	for photoID := uint64(1); photoID <= 5; photoID++ {
+		if err := src.AddPhoto(1, photoID, []byte("photo")); err != nil {
+			t.Fatalf("AddPhoto() failed: %v", err)
+		}
+	}
+	// Left by an interrupted migration, kept as is
+	for photoID := uint64(1); photoID <= 2; photoID++ {
+		if err := dst.AddPhoto(1, photoID, []byte("old")); err != nil {
+			t.Fatalf("AddPhoto() failed: %v", err)
+		}
+	}
+
+	report, err := migrate(src, dst, migrateOptions{batchSize: 10, skipExisting: true})
+	if err != nil {
+		t.Fatalf("migrate() failed: %v", err)
+	}
+	want := migrateReport{Photos: 3, Bytes: 15, Skipped: 2, Batches: 1}
+	if report != want {
+		t.Errorf("migrate() = %+v, want %+v", report, want)
+	}
+	if data, _ := dst.GetPhotoData(1, 1); string(data) != "old" {
+		t.Errorf("Existing photo = %q, want it kept", data)
+	}
+	if count, _ := dst.CountAllPhotos(); count != 5 {
+		t.Errorf("Destination has %d photos, want 5", count)
+	}
+}
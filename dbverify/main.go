@@ -0,0 +1,107 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/mhbvr/manul/db/filetree"
+)
+
+func main() {
+	var (
+		dbPath      = flag.String("db", "", "Filetree database directory")
+		list        = flag.Bool("list", false, "List problem keys")
+		maxProblems = flag.Int("max-problems", 1000, "Maximum number of problem keys to list (-1 = all)")
+		integrity   = flag.Bool("integrity", false, "Also find data files without meta entries (orphans), reading the whole data directory")
+		repair      = flag.Bool("repair", false, "Delete orphan data files, implies -integrity and opens the database for writing")
+	)
+	flag.Parse()
+
+	if *dbPath == "" {
+		log.Fatal("Database path must be specified with -db flag")
+	}
+
+	var db *filetree.FileTreeDB
+	var err error
+	if *repair {
+		db, err = filetree.OpenWriter(*dbPath)
+	} else {
+		db, err = filetree.NewReader(*dbPath)
+	}
+	if err != nil {
+		log.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	limit := 0
+	if *list {
+		limit = *maxProblems
+	}
+
+	ok, err := verify(db, limit, *integrity || *repair, *repair)
+	if err != nil {
+		log.Fatalf("Verification failed: %v", err)
+	}
+
+	if !ok {
+		db.Close()
+		os.Exit(1)
+	}
+}
+
+// verify checks that every meta entry has a non-empty data file, finds
+// the data files without meta entries if integrity is set and lists up to
+// limit problems. If repair is set, it removes the orphan files.
+func verify(db *filetree.FileTreeDB, limit int, integrity, repair bool) (bool, error) {
+	if !integrity {
+		report, err := db.Verify(limit)
+		if err != nil {
+			return false, err
+		}
+		printReport(report, limit)
+		return report.Missing+report.Empty == 0, nil
+	}
+
+	// Repair removes every orphan, so all problems are kept in the report
+	maxProblems := limit
+	if repair {
+		maxProblems = -1
+	}
+	report, err := db.VerifyIntegrity(maxProblems)
+	if err != nil {
+		return false, err
+	}
+	printReport(report, limit)
+	fmt.Printf("Orphan files: %d\n", report.Orphans)
+
+	orphans := report.Orphans
+	if repair && orphans > 0 {
+		removed, err := db.RemoveOrphans(report.Problems)
+		if err != nil {
+			return false, err
+		}
+		fmt.Printf("Removed orphan files: %d\n", removed)
+		orphans -= removed
+	}
+
+	return report.Missing+report.Empty+orphans == 0, nil
+}
+
+// printReport prints up to limit problems of report and the problem counts
+func printReport(report *filetree.VerifyReport, limit int) {
+	for i, problem := range report.Problems {
+		if limit >= 0 && i >= limit {
+			break
+		}
+		if problem.Reason == filetree.ProblemOrphan {
+			fmt.Printf("%s: %s\n", problem.Path, problem.Reason)
+		} else {
+			fmt.Printf("cat_id=%d photo_id=%d: %s (%s)\n", problem.CatID, problem.PhotoID, problem.Reason, problem.Path)
+		}
+	}
+
+	fmt.Printf("Checked: %d, missing data files: %d, empty data files: %d\n",
+		report.Checked, report.Missing, report.Empty)
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"time"
 
 	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
 	endpoint "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
@@ -69,15 +70,39 @@ func (cb *EDSCallbacks) OnStreamDeltaResponse(streamID int64, req *discoveryv3.D
 		streamID, req.Node.GetId(), req.TypeUrl)
 }
 
+// EndpointWatcher provides the cluster endpoints and notifies about changes,
+// implemented by k8s_watcher.K8sWatcher and FileWatcher
+type EndpointWatcher interface {
+	NotifChan() chan struct{}
+	GetEndpoints() []k8s_watcher.Endpoint
+}
+
+const (
+	// fullWeight is the load balancing weight of endpoints not in slow start
+	fullWeight = 100
+	// slowStartUpdateInterval is how often weights of endpoints in slow
+	// start are increased
+	slowStartUpdateInterval = time.Second
+)
+
 type EDSServer struct {
 	cache       cache.SnapshotCache
 	server      server.Server
 	nodeID      string
 	clusterName string
 	version     int64
+
+	// slowStartWindow is the time for new endpoints to ramp up to full
+	// weight, 0 disables slow start
+	slowStartWindow time.Duration
+	// firstSeen is when each current endpoint, by address:port, appeared.
+	// Endpoints from the first update have zero time and full weight.
+	firstSeen map[string]time.Time
 }
 
-func NewEDSServer(nodeID, clusterName string) *EDSServer {
+// NewEDSServer creates an EDS server. New endpoints get weight increasing
+// linearly to full weight over slowStartWindow, if it is not 0.
+func NewEDSServer(nodeID, clusterName string, slowStartWindow time.Duration) *EDSServer {
 	callbacks := &EDSCallbacks{}
 	cache := cache.NewSnapshotCache(false, cache.IDHash{}, nil)
 	server := server.NewServer(context.Background(), cache, callbacks)
@@ -88,6 +113,8 @@ func NewEDSServer(nodeID, clusterName string) *EDSServer {
 		nodeID:      nodeID,
 		clusterName: clusterName,
 		version:     1,
+
+		slowStartWindow: slowStartWindow,
 	}
 }
 
@@ -95,8 +122,53 @@ func (eds *EDSServer) GetServer() server.Server {
 	return eds.server
 }
 
+func endpointKey(ep k8s_watcher.Endpoint) string {
+	return fmt.Sprintf("%s:%d", ep.Address, ep.Port)
+}
+
+// trackEndpoints records when endpoints appeared, forgetting removed ones
+func (eds *EDSServer) trackEndpoints(endpoints []k8s_watcher.Endpoint, now time.Time) {
+	firstUpdate := eds.firstSeen == nil
+	firstSeen := make(map[string]time.Time, len(endpoints))
+	for _, ep := range endpoints {
+		key := endpointKey(ep)
+		seen, ok := eds.firstSeen[key]
+		if !ok && !firstUpdate {
+			seen = now
+		}
+		firstSeen[key] = seen
+	}
+	eds.firstSeen = firstSeen
+}
+
+// endpointWeight returns the weight of an endpoint, growing from 1 to
+// fullWeight during the slow start window
+func (eds *EDSServer) endpointWeight(ep k8s_watcher.Endpoint, now time.Time) uint32 {
+	if eds.slowStartWindow <= 0 {
+		return fullWeight
+	}
+
+	elapsed := now.Sub(eds.firstSeen[endpointKey(ep)])
+	if elapsed >= eds.slowStartWindow {
+		return fullWeight
+	}
+	return max(1, uint32(fullWeight*elapsed/eds.slowStartWindow))
+}
+
+// inSlowStart reports whether any endpoint has not reached full weight yet
+func (eds *EDSServer) inSlowStart(now time.Time) bool {
+	for _, seen := range eds.firstSeen {
+		if now.Sub(seen) < eds.slowStartWindow {
+			return true
+		}
+	}
+	return false
+}
+
 func (eds *EDSServer) UpdateEndpoints(endpoints []k8s_watcher.Endpoint) error {
-	clusterLoadAssignment := eds.createClusterLoadAssignment(endpoints)
+	now := time.Now()
+	eds.trackEndpoints(endpoints, now)
+	clusterLoadAssignment := eds.createClusterLoadAssignment(endpoints, now)
 
 	snapshot, err := cache.NewSnapshot(
 		fmt.Sprintf("%d", eds.version),
@@ -119,7 +191,7 @@ func (eds *EDSServer) UpdateEndpoints(endpoints []k8s_watcher.Endpoint) error {
 	return nil
 }
 
-func (eds *EDSServer) createClusterLoadAssignment(endpoints []k8s_watcher.Endpoint) *endpoint.ClusterLoadAssignment {
+func (eds *EDSServer) createClusterLoadAssignment(endpoints []k8s_watcher.Endpoint, now time.Time) *endpoint.ClusterLoadAssignment {
 	var lbEndpoints []*endpoint.LbEndpoint
 
 	for _, ep := range endpoints {
@@ -139,7 +211,7 @@ func (eds *EDSServer) createClusterLoadAssignment(endpoints []k8s_watcher.Endpoi
 				},
 			},
 			HealthStatus:        core.HealthStatus_HEALTHY,
-			LoadBalancingWeight: &wrapperspb.UInt32Value{Value: 1},
+			LoadBalancingWeight: &wrapperspb.UInt32Value{Value: eds.endpointWeight(ep, now)},
 		}
 		lbEndpoints = append(lbEndpoints, lbEndpoint)
 	}
@@ -154,13 +226,28 @@ func (eds *EDSServer) createClusterLoadAssignment(endpoints []k8s_watcher.Endpoi
 	}
 }
 
-func (eds *EDSServer) Start(watcher *k8s_watcher.K8sWatcher) {
+func (eds *EDSServer) Start(watcher EndpointWatcher) {
 	log.Printf("Starting EDS server for cluster: %s", eds.clusterName)
 
-	// Listen for updates
+	// Listen for updates, and increase weights of endpoints in slow start
 	notifChan := watcher.NotifChan()
+	var slowStartTick <-chan time.Time
+	if eds.slowStartWindow > 0 {
+		slowStartTick = time.Tick(slowStartUpdateInterval)
+	}
 	go func() {
-		for range notifChan {
+		for {
+			select {
+			case _, ok := <-notifChan:
+				if !ok {
+					return
+				}
+			case now := <-slowStartTick:
+				if !eds.inSlowStart(now) {
+					continue
+				}
+			}
+
 			endpoints := watcher.GetEndpoints()
 			if err := eds.UpdateEndpoints(endpoints); err != nil {
 				log.Printf("Failed to update endpoints: %v", err)
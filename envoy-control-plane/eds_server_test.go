@@ -0,0 +1,68 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mhbvr/manul/k8s_watcher"
+)
+
+func TestSlowStartWeights(t *testing.T) {
+	eds := NewEDSServer("node", "cluster", 10*time.Second)
+	start := time.Now()
+	old := k8s_watcher.Endpoint{Address: "10.0.0.1", Port: 8081}
+	added := k8s_watcher.Endpoint{Address: "10.0.0.2", Port: 8081}
+
+	// Endpoints from the first update are not ramped
+	eds.trackEndpoints([]k8s_watcher.Endpoint{old}, start)
+	if got := eds.endpointWeight(old, start); got != fullWeight {
+		t.Errorf("Initial endpoint weight = %d, want %d", got, fullWeight)
+	}
+
+	eds.trackEndpoints([]k8s_watcher.Endpoint{old, added}, start)
+	tests := []struct {
+		elapsed time.Duration
+		want    uint32
+	}{
+		{0, 1},
+		{5 * time.Second, fullWeight / 2},
+		{10 * time.Second, fullWeight},
+	}
+	for _, tt := range tests {
+		now := start.Add(tt.elapsed)
+		if got := eds.endpointWeight(added, now); got != tt.want {
+			t.Errorf("Weight after %v = %d, want %d", tt.elapsed, got, tt.want)
+		}
+		if got := eds.endpointWeight(old, now); got != fullWeight {
+			t.Errorf("Old endpoint weight after %v = %d, want %d", tt.elapsed, got, fullWeight)
+		}
+	}
+
+	if !eds.inSlowStart(start.Add(5 * time.Second)) {
+		t.Errorf("inSlowStart() = false during the window")
+	}
+	if eds.inSlowStart(start.Add(10 * time.Second)) {
+		t.Errorf("inSlowStart() = true after the window")
+	}
+
+	// A removed and re-added endpoint ramps up again
+	later := start.Add(time.Minute)
+	eds.trackEndpoints([]k8s_watcher.Endpoint{old}, later)
+	eds.trackEndpoints([]k8s_watcher.Endpoint{old, added}, later)
+	if got := eds.endpointWeight(added, later); got != 1 {
+		t.Errorf("Re-added endpoint weight = %d, want 1", got)
+	}
+}
+
+func TestSlowStartDisabled(t *testing.T) {
+	eds := NewEDSServer("node", "cluster", 0)
+	now := time.Now()
+	ep := k8s_watcher.Endpoint{Address: "10.0.0.1", Port: 8081}
+
+	eds.trackEndpoints(nil, now)
+	eds.trackEndpoints([]k8s_watcher.Endpoint{ep}, now)
+	cla := eds.createClusterLoadAssignment([]k8s_watcher.Endpoint{ep}, now)
+	if got := cla.Endpoints[0].LbEndpoints[0].LoadBalancingWeight.Value; got != fullWeight {
+		t.Errorf("Weight with slow start disabled = %d, want %d", got, fullWeight)
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/mhbvr/manul/k8s_watcher"
+	"sigs.k8s.io/yaml"
+)
+
+// endpointsFile is the format of the static endpoints file, YAML or JSON:
+//
+//	endpoints:
+//	  - address: 10.0.0.1
+//	    port: 8081
+type endpointsFile struct {
+	Endpoints []struct {
+		Address string `json:"address"`
+		Port    int32  `json:"port"`
+	} `json:"endpoints"`
+}
+
+// parseEndpointsFile reads endpoints from a YAML or JSON file
+func parseEndpointsFile(path string) ([]k8s_watcher.Endpoint, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var file endpointsFile
+	if err := yaml.UnmarshalStrict(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+
+	endpoints := make([]k8s_watcher.Endpoint, 0, len(file.Endpoints))
+	for i, ep := range file.Endpoints {
+		if ep.Address == "" {
+			return nil, fmt.Errorf("endpoint %d in %s: address is required", i, path)
+		}
+		if ep.Port <= 0 || ep.Port > 65535 {
+			return nil, fmt.Errorf("endpoint %d in %s: invalid port %d", i, path, ep.Port)
+		}
+		endpoints = append(endpoints, k8s_watcher.Endpoint{Address: ep.Address, Port: ep.Port})
+	}
+	return endpoints, nil
+}
+
+// FileWatcher provides endpoints from a static file instead of Kubernetes,
+// the file is reloaded when it changes
+type FileWatcher struct {
+	ctx     context.Context
+	path    string
+	watcher *fsnotify.Watcher
+
+	mu        sync.RWMutex
+	endpoints []k8s_watcher.Endpoint
+	notifs    []chan struct{}
+}
+
+func NewFileWatcher(ctx context.Context, path string) (*FileWatcher, error) {
+	endpoints, err := parseEndpointsFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create file watcher: %v", err)
+	}
+
+	// Watch the directory, editors often replace the file instead of writing it
+	if err := watcher.Add(filepath.Dir(path)); err != nil {
+		watcher.Close()
+		return nil, fmt.Errorf("failed to watch %s: %v", path, err)
+	}
+
+	res := &FileWatcher{
+		ctx:       ctx,
+		path:      filepath.Clean(path),
+		watcher:   watcher,
+		endpoints: endpoints,
+	}
+
+	go res.watchFile()
+	return res, nil
+}
+
+func (fw *FileWatcher) NotifChan() chan struct{} {
+	res := make(chan struct{}, 1)
+	// Set initial notification
+	res <- struct{}{}
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+	fw.notifs = append(fw.notifs, res)
+	return res
+}
+
+func (fw *FileWatcher) GetEndpoints() []k8s_watcher.Endpoint {
+	fw.mu.RLock()
+	defer fw.mu.RUnlock()
+	return append([]k8s_watcher.Endpoint(nil), fw.endpoints...)
+}
+
+func (fw *FileWatcher) watchFile() {
+	defer fw.watcher.Close()
+
+	for {
+		select {
+		case <-fw.ctx.Done():
+			return
+		case err, ok := <-fw.watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Printf("Endpoints file watcher error: %v", err)
+		case event, ok := <-fw.watcher.Events:
+			if !ok {
+				return
+			}
+			if filepath.Clean(event.Name) != fw.path || !event.Has(fsnotify.Write|fsnotify.Create) {
+				continue
+			}
+			fw.reload()
+		}
+	}
+}
+
+// reload reads the file again, keeping the old endpoints if it is invalid
+func (fw *FileWatcher) reload() {
+	endpoints, err := parseEndpointsFile(fw.path)
+	if err != nil {
+		log.Printf("Failed to reload endpoints file, keeping %d endpoints: %v", len(fw.GetEndpoints()), err)
+		return
+	}
+
+	fw.mu.Lock()
+	fw.endpoints = endpoints
+	fw.mu.Unlock()
+	fw.notify()
+}
+
+func (fw *FileWatcher) notify() {
+	allEndpoints := fw.GetEndpoints()
+	log.Printf("Updated endpoints from %s: %d total endpoints", fw.path, len(allEndpoints))
+	for _, ep := range allEndpoints {
+		log.Printf("  - %s:%d", ep.Address, ep.Port)
+	}
+
+	fw.mu.RLock()
+	defer fw.mu.RUnlock()
+	for _, c := range fw.notifs {
+		select {
+		case c <- struct{}{}:
+		default:
+			log.Printf("Notif still pending")
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mhbvr/manul/k8s_watcher"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write %s: %v", path, err)
+	}
+}
+
+func TestParseEndpointsFile(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := []struct {
+		name    string
+		content string
+		want    []k8s_watcher.Endpoint
+		wantErr bool
+	}{
+		{
+			name:    "yaml",
+			content: "endpoints:\n  - address: 10.0.0.1\n    port: 8081\n  - address: 10.0.0.2\n    port: 8082\n",
+			want:    []k8s_watcher.Endpoint{{Address: "10.0.0.1", Port: 8081}, {Address: "10.0.0.2", Port: 8082}},
+		},
+		{
+			name:    "json",
+			content: `{"endpoints": [{"address": "10.0.0.1", "port": 8081}]}`,
+			want:    []k8s_watcher.Endpoint{{Address: "10.0.0.1", Port: 8081}},
+		},
+		{name: "empty", content: "endpoints: []\n", want: []k8s_watcher.Endpoint{}},
+		{name: "missing address", content: "endpoints:\n  - port: 8081\n", wantErr: true},
+		{name: "invalid port", content: "endpoints:\n  - address: 10.0.0.1\n    port: 0\n", wantErr: true},
+		{name: "unknown field", content: "endpoints:\n  - address: 10.0.0.1\n    prot: 8081\n", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, tt.name)
+			writeFile(t, path, tt.content)
+
+			got, err := parseEndpointsFile(path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseEndpointsFile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseEndpointsFile() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFileWatcher_Reload(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	path := filepath.Join(t.TempDir(), "endpoints.yaml")
+	writeFile(t, path, "endpoints:\n  - address: 10.0.0.1\n    port: 8081\n")
+
+	watcher, err := NewFileWatcher(ctx, path)
+	if err != nil {
+		t.Fatalf("NewFileWatcher() failed: %v", err)
+	}
+
+	notifs := watcher.NotifChan()
+	<-notifs // Initial notification
+
+	// Invalid content keeps the old endpoints
+	writeFile(t, path, "endpoints: [")
+	writeFile(t, path, "endpoints:\n  - address: 10.0.0.2\n    port: 8082\n")
+
+	want := []k8s_watcher.Endpoint{{Address: "10.0.0.2", Port: 8082}}
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case <-notifs:
+			if reflect.DeepEqual(watcher.GetEndpoints(), want) {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("GetEndpoints() = %v after file change, want %v", watcher.GetEndpoints(), want)
+		}
+	}
+}
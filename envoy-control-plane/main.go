@@ -24,12 +24,14 @@ var (
 	clusterName = flag.String("cluster", "", "Envoy cluster name (defaults to service name)")
 	nodeID      = flag.String("node-id", "envoy-node", "Node ID for Envoy")
 	kubeconfig  = flag.String("kubeconfig", "", "Path to kubeconfig file (optional, uses in-cluster config if not provided)")
+	endpoints   = flag.String("endpoints-file", "", "Read endpoints from this YAML/JSON file instead of Kubernetes, reloaded on changes")
+	slowStart   = flag.Duration("slow-start-window", 0, "Time for new endpoints to ramp up from minimal to full load balancing weight (0 = disabled)")
 )
 
 func main() {
 	flag.Parse()
 
-	if *serviceName == "" {
+	if *serviceName == "" && *endpoints == "" {
 		log.Fatal("Service name is required (use -service flag)")
 	}
 
@@ -37,23 +39,44 @@ func main() {
 		*clusterName = *serviceName
 	}
 
-	log.Printf("Starting Envoy Control Plane for service: %s", *serviceName)
+	if *clusterName == "" {
+		log.Fatal("Cluster name is required with -endpoints-file (use -cluster flag)")
+	}
+
+	if *endpoints != "" {
+		log.Printf("Starting Envoy Control Plane for endpoints file: %s", *endpoints)
+	} else {
+		log.Printf("Starting Envoy Control Plane for service: %s", *serviceName)
+		log.Printf("Namespace: %s", *namespace)
+	}
 	log.Printf("Cluster name: %s", *clusterName)
-	log.Printf("Namespace: %s", *namespace)
 	log.Printf("Node ID: %s", *nodeID)
 	log.Printf("Port: %d", *port)
+	if *slowStart > 0 {
+		log.Printf("Slow start window: %v", *slowStart)
+	}
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	// Create Kubernetes watcher
-	watcher, err := k8s_watcher.NewK8sWatcher(ctx, *namespace, *serviceName, *kubeconfig)
-	if err != nil {
-		log.Fatalf("Failed to create Kubernetes watcher: %v", err)
+	var watcher EndpointWatcher
+	var err error
+	if *endpoints != "" {
+		// Create static file watcher
+		watcher, err = NewFileWatcher(ctx, *endpoints)
+		if err != nil {
+			log.Fatalf("Failed to create endpoints file watcher: %v", err)
+		}
+	} else {
+		// Create Kubernetes watcher
+		watcher, err = k8s_watcher.NewK8sWatcher(ctx, *namespace, *serviceName, *kubeconfig)
+		if err != nil {
+			log.Fatalf("Failed to create Kubernetes watcher: %v", err)
+		}
 	}
 
 	// Create EDS server
-	edsServer := NewEDSServer(*nodeID, *clusterName)
+	edsServer := NewEDSServer(*nodeID, *clusterName, *slowStart)
 
 	edsServer.Start(watcher)
 
@@ -0,0 +1,96 @@
+package manul
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// DefaultFilenameFormat is the <cat_id>_<photo_id>.<ext> naming of source
+// photo files
+const DefaultFilenameFormat = "{cat}_{photo}.{ext}"
+
+// formatPlaceholders are the regexps of the placeholders of filename formats
+var formatPlaceholders = map[string]string{
+	"{cat}":   `(?P<cat_id>\d+)`,
+	"{photo}": `(?P<photo_id>\d+)`,
+	"{ext}":   `[a-z0-9]+`,
+}
+
+// placeholderPattern matches the placeholders of filename formats
+var placeholderPattern = regexp.MustCompile(`\{[^{}]*\}`)
+
+// FilenameParser extracts the cat and photo IDs from the paths of source
+// photo files, so datasets with different naming schemes can be ingested
+type FilenameParser struct {
+	re      *regexp.Regexp
+	catID   int // Index of the cat_id group of re
+	photoID int // Index of the photo_id group of re
+}
+
+// NewFilenameParser creates a parser from a format with {cat} and {photo}
+// placeholders for the IDs, e.g. "{cat}_{photo}.jpg" or
+// "cat{cat}/photo{photo}.jpg", and an optional {ext} placeholder matching
+// any file extension. The format matches the last path elements of a file,
+// ignoring case.
+func NewFilenameParser(format string) (*FilenameParser, error) {
+	if !strings.Contains(format, "{cat}") || !strings.Contains(format, "{photo}") {
+		return nil, fmt.Errorf("filename format %q needs {cat} and {photo} placeholders", format)
+	}
+
+	var expr strings.Builder
+	expr.WriteString(`(?i)(?:^|/)`)
+	last := 0
+	for _, loc := range placeholderPattern.FindAllStringIndex(format, -1) {
+		placeholder, ok := formatPlaceholders[format[loc[0]:loc[1]]]
+		if !ok {
+			return nil, fmt.Errorf("unknown placeholder %s in filename format %q (use {cat}, {photo} or {ext})", format[loc[0]:loc[1]], format)
+		}
+		expr.WriteString(regexp.QuoteMeta(format[last:loc[0]]))
+		expr.WriteString(placeholder)
+		last = loc[1]
+	}
+	expr.WriteString(regexp.QuoteMeta(format[last:]))
+	expr.WriteString(`$`)
+
+	return NewFilenameRegexpParser(expr.String())
+}
+
+// NewFilenameRegexpParser creates a parser from a regexp with cat_id and
+// photo_id named groups, e.g. `^(?P<cat_id>\d+)-(?P<photo_id>\d+)\.png$`.
+// The regexp is matched against slash-separated paths.
+func NewFilenameRegexpParser(expr string) (*FilenameParser, error) {
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	p := &FilenameParser{
+		re:      re,
+		catID:   re.SubexpIndex("cat_id"),
+		photoID: re.SubexpIndex("photo_id"),
+	}
+	if p.catID < 0 || p.photoID < 0 {
+		return nil, fmt.Errorf("regexp %q needs cat_id and photo_id named groups, e.g. (?P<cat_id>\\d+)", expr)
+	}
+	return p, nil
+}
+
+// Parse extracts the IDs from the slash-separated path of a file, relative
+// to the directory holding the dataset
+func (p *FilenameParser) Parse(path string) (catID, photoID uint64, ok bool) {
+	match := p.re.FindStringSubmatch(path)
+	if match == nil {
+		return 0, 0, false
+	}
+
+	catID, err := strconv.ParseUint(match[p.catID], 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	photoID, err = strconv.ParseUint(match[p.photoID], 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	return catID, photoID, true
+}
@@ -0,0 +1,63 @@
+package manul_test
+
+import (
+	"testing"
+
+	"github.com/mhbvr/manul"
+)
+
+func TestFilenameParser(t *testing.T) {
+	tests := []struct {
+		format, regexp string
+		path           string
+		catID, photoID uint64
+		ok             bool
+	}{
+		{format: manul.DefaultFilenameFormat, path: "12_345.jpg", catID: 12, photoID: 345, ok: true},
+		{format: manul.DefaultFilenameFormat, path: "nested/dir/1_2.PNG", catID: 1, photoID: 2, ok: true},
+		{format: manul.DefaultFilenameFormat, path: "x1_2.jpg"},
+		{format: manul.DefaultFilenameFormat, path: "1_2_3.jpg"},
+		{format: "{cat}_{photo}.jpg", path: "1_2.jpg", catID: 1, photoID: 2, ok: true},
+		{format: "{cat}_{photo}.jpg", path: "1_2.png"},
+		{format: "{cat}_{photo}.jpg", path: "1_2xjpg"},
+		{format: "cat{cat}/photo{photo}.jpg", path: "cat7/photo9.jpg", catID: 7, photoID: 9, ok: true},
+		{format: "cat{cat}/photo{photo}.jpg", path: "volume1/Cat7/Photo9.JPG", catID: 7, photoID: 9, ok: true},
+		{format: "cat{cat}/photo{photo}.jpg", path: "cat7_photo9.jpg"},
+		{format: "cat{cat}/photo{photo}.jpg", path: "bigcat7/photo9.jpg"},
+		{regexp: `^(?P<photo_id>\d+)-(?P<cat_id>\d+)\.png$`, path: "2-1.png", catID: 1, photoID: 2, ok: true},
+		{regexp: `^(?P<photo_id>\d+)-(?P<cat_id>\d+)\.png$`, path: "dir/2-1.png"},
+		{regexp: `(?P<cat_id>\d+)_(?P<photo_id>\d+)`, path: "99999999999999999999_1.jpg"},
+	}
+
+	for _, tt := range tests {
+		var p *manul.FilenameParser
+		var err error
+		if tt.regexp != "" {
+			p, err = manul.NewFilenameRegexpParser(tt.regexp)
+		} else {
+			p, err = manul.NewFilenameParser(tt.format)
+		}
+		if err != nil {
+			t.Fatalf("Failed to create parser of %q%q: %v", tt.format, tt.regexp, err)
+		}
+
+		catID, photoID, ok := p.Parse(tt.path)
+		if catID != tt.catID || photoID != tt.photoID || ok != tt.ok {
+			t.Errorf("Parse(%q) with %q%q = %d, %d, %v, want %d, %d, %v",
+				tt.path, tt.format, tt.regexp, catID, photoID, ok, tt.catID, tt.photoID, tt.ok)
+		}
+	}
+}
+
+func TestFilenameParser_Invalid(t *testing.T) {
+	for _, format := range []string{"{cat}.jpg", "{cat}_{photo}_{size}.jpg", "photo.jpg"} {
+		if _, err := manul.NewFilenameParser(format); err == nil {
+			t.Errorf("NewFilenameParser(%q) succeeded, want error", format)
+		}
+	}
+	for _, expr := range []string{`(?P<cat_id>\d+)_(\d+)`, `(?P<cat_id>\d+`} {
+		if _, err := manul.NewFilenameRegexpParser(expr); err == nil {
+			t.Errorf("NewFilenameRegexpParser(%q) succeeded, want error", expr)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package manul
+
+import "bytes"
+
+// PhotoFormat is the image format of a stored photo.
+// It is stored as a one-byte tag in the photo metadata.
+type PhotoFormat byte
+
+const (
+	FormatUnknown PhotoFormat = iota
+	FormatJPEG
+	FormatPNG
+	FormatGIF
+	FormatWebP
+)
+
+var formatNames = map[PhotoFormat]string{
+	FormatUnknown: "unknown",
+	FormatJPEG:    "jpeg",
+	FormatPNG:     "png",
+	FormatGIF:     "gif",
+	FormatWebP:    "webp",
+}
+
+func (f PhotoFormat) String() string {
+	if name, ok := formatNames[f]; ok {
+		return name
+	}
+	return formatNames[FormatUnknown]
+}
+
+// DetectFormat detects the image format from the leading magic bytes of data
+func DetectFormat(data []byte) PhotoFormat {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xff, 0xd8, 0xff}):
+		return FormatJPEG
+	case bytes.HasPrefix(data, []byte("\x89PNG\r\n\x1a\n")):
+		return FormatPNG
+	case bytes.HasPrefix(data, []byte("GIF87a")), bytes.HasPrefix(data, []byte("GIF89a")):
+		return FormatGIF
+	case len(data) >= 12 && bytes.Equal(data[:4], []byte("RIFF")) && bytes.Equal(data[8:12], []byte("WEBP")):
+		return FormatWebP
+	}
+	return FormatUnknown
+}
+
+// ContentType returns the MIME type for a format name returned by
+// DBReader.GetPhotoFormat
+func ContentType(format string) string {
+	switch format {
+	case "jpeg":
+		return "image/jpeg"
+	case "png":
+		return "image/png"
+	case "gif":
+		return "image/gif"
+	case "webp":
+		return "image/webp"
+	}
+	return "application/octet-stream"
+}
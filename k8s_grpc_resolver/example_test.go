@@ -10,7 +10,7 @@ import (
 	"google.golang.org/grpc/credentials/insecure"
 )
 
-func ExampleK8sResolver() {
+func Example() {
 	// Import the resolver to register it
 	_ = k8s_grpc_resolver.Package
 
@@ -31,4 +31,18 @@ func ExampleK8sResolver() {
 	defer conn.Close()
 
 	log.Println("Successfully connected to Kubernetes service")
-}
\ No newline at end of file
+}
+
+func ExampleNewBuilder() {
+	// Skip pods that are ready in Kubernetes but do not serve gRPC yet
+	builder := k8s_grpc_resolver.NewBuilder(k8s_grpc_resolver.WithHealthCheck(time.Second))
+
+	conn, err := grpc.NewClient("k8s://my-service.default:8080",
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithResolvers(builder),
+	)
+	if err != nil {
+		log.Fatalf("Failed to create client: %v", err)
+	}
+	defer conn.Close()
+}
@@ -5,24 +5,67 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/mhbvr/manul/k8s_watcher"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/resolver"
+	"google.golang.org/grpc/status"
 )
 
 const k8sScheme = "k8s"
 
-type k8sResolverBuilder struct{}
+// healthRecheckInterval is the interval between probes of endpoints which
+// failed health checks, they are probed again until they pass
+const healthRecheckInterval = 5 * time.Second
+
+type k8sResolverBuilder struct {
+	healthCheckTimeout time.Duration // 0 disables health checks
+}
+
+// Option configures a resolver builder created with NewBuilder
+type Option func(*k8sResolverBuilder)
+
+// WithHealthCheck makes the resolver probe each ready endpoint with the
+// grpc.health.v1.Health service before passing it to the channel, so pods
+// that are ready in Kubernetes but not serving gRPC yet are skipped.
+// Servers without the health service are considered healthy, failing
+// endpoints are probed again every 5 seconds until they pass.
+// Probes use insecure credentials and delay each update by up to timeout.
+func WithHealthCheck(timeout time.Duration) Option {
+	return func(b *k8sResolverBuilder) {
+		b.healthCheckTimeout = timeout
+	}
+}
+
+// NewBuilder creates a k8s resolver builder with options, to be passed to
+// grpc.WithResolvers. The builder registered by default has no options.
+func NewBuilder(opts ...Option) resolver.Builder {
+	b := &k8sResolverBuilder{}
+	for _, opt := range opts {
+		opt(b)
+	}
+	return b
+}
 
 func (k *k8sResolverBuilder) Build(target resolver.Target, cc resolver.ClientConn, opts resolver.BuildOptions) (resolver.Resolver, error) {
+	ctx, cancel := context.WithCancel(context.Background())
 	r := &k8sResolver{
-		target: target,
-		cc:     cc,
-		ctx:    context.Background(),
+		target:                target,
+		cc:                    cc,
+		ctx:                   ctx,
+		cancel:                cancel,
+		resolveNow:            make(chan struct{}, 1),
+		healthCheckTimeout:    k.healthCheckTimeout,
+		healthRecheckInterval: healthRecheckInterval,
 	}
 
 	if err := r.parseTarget(); err != nil {
+		cancel()
 		return nil, err
 	}
 
@@ -45,6 +88,10 @@ type k8sResolver struct {
 	namespace   string
 	port        int
 	watcher     *k8s_watcher.K8sWatcher
+	resolveNow  chan struct{} // Signals ResolveNow to the watch goroutine
+
+	healthCheckTimeout    time.Duration
+	healthRecheckInterval time.Duration
 }
 
 func (r *k8sResolver) parseTarget() error {
@@ -73,30 +120,41 @@ func (r *k8sResolver) parseTarget() error {
 }
 
 func (r *k8sResolver) start() {
-	ctx, cancel := context.WithCancel(r.ctx)
-	r.cancel = cancel
-
 	var err error
-	r.watcher, err = k8s_watcher.NewK8sWatcher(ctx, r.namespace, r.serviceName, "")
+	r.watcher, err = k8s_watcher.NewK8sWatcher(r.ctx, r.namespace, r.serviceName, "")
 	if err != nil {
 		r.cc.ReportError(fmt.Errorf("failed to create k8s watcher: %v", err))
 		return
 	}
 
-	notifChan := r.watcher.NotifChan()
+	r.watch(r.watcher.NotifChan(), r.watcher.GetEndpoints)
+}
 
+// watch updates the addresses on watcher notifications and ResolveNow calls
+// until the resolver is closed. With health checks, endpoints failing them
+// are probed again every healthRecheckInterval, since a pod which becomes
+// serving may not change the endpoints.
+func (r *k8sResolver) watch(notifChan <-chan struct{}, getEndpoints func() []k8s_watcher.Endpoint) {
+	var recheck <-chan time.Time
 	for {
 		select {
-		case <-ctx.Done():
+		case <-r.ctx.Done():
 			return
 		case <-notifChan:
-			r.updateEndpoints()
+		case <-r.resolveNow:
+		case <-recheck:
+		}
+
+		recheck = nil
+		if excluded := r.updateEndpoints(getEndpoints()); excluded {
+			recheck = time.After(r.healthRecheckInterval)
 		}
 	}
 }
 
-func (r *k8sResolver) updateEndpoints() {
-	endpoints := r.watcher.GetEndpoints()
+// updateEndpoints passes the addresses of endpoints to the channel and
+// reports whether some were excluded by health checks
+func (r *k8sResolver) updateEndpoints(endpoints []k8s_watcher.Endpoint) bool {
 	var addrs []resolver.Address
 
 	for _, ep := range endpoints {
@@ -105,21 +163,79 @@ func (r *k8sResolver) updateEndpoints() {
 		})
 	}
 
+	var excluded bool
+	if r.healthCheckTimeout > 0 {
+		healthy := filterHealthy(r.ctx, addrs, r.healthCheckTimeout)
+		excluded = len(healthy) < len(addrs)
+		addrs = healthy
+	}
+	if r.ctx.Err() != nil {
+		// Probes were cancelled by Close
+		return false
+	}
+
 	state := resolver.State{
 		Addresses: addrs,
 	}
 
 	r.cc.UpdateState(state)
+	return excluded
 }
 
+// filterHealthy probes all addresses concurrently and returns the healthy ones
+func filterHealthy(ctx context.Context, addrs []resolver.Address, timeout time.Duration) []resolver.Address {
+	healthy := make([]bool, len(addrs))
+	var wg sync.WaitGroup
+	for i, addr := range addrs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			healthy[i] = checkHealth(ctx, addr.Addr, timeout)
+		}()
+	}
+	wg.Wait()
+
+	var res []resolver.Address
+	for i, addr := range addrs {
+		if healthy[i] {
+			res = append(res, addr)
+		}
+	}
+	return res
+}
+
+// checkHealth reports whether addr serves gRPC and is not reported as not serving
+func checkHealth(ctx context.Context, addr string, timeout time.Duration) bool {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return false
+	}
+	defer conn.Close()
+
+	resp, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{})
+	if status.Code(err) == codes.Unimplemented {
+		// Serves gRPC, but without the health service
+		return true
+	}
+	return err == nil && resp.Status == healthpb.HealthCheckResponse_SERVING
+}
+
+// ResolveNow makes the watch goroutine update the addresses, without
+// waiting for the health probes
 func (r *k8sResolver) ResolveNow(resolver.ResolveNowOptions) {
-	r.updateEndpoints()
+	select {
+	case r.resolveNow <- struct{}{}:
+	default:
+		// An update is already pending
+	}
 }
 
+// Close stops the watcher and cancels running health probes
 func (r *k8sResolver) Close() {
-	if r.cancel != nil {
-		r.cancel()
-	}
+	r.cancel()
 }
 
 // Package is a placeholder to ensure the resolver is registered when imported
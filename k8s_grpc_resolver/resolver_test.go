@@ -0,0 +1,143 @@
+package k8s_grpc_resolver
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/mhbvr/manul/k8s_watcher"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/resolver"
+)
+
+// startServer starts a gRPC server on a local port, with the health
+// service reporting servingStatus unless withHealth is false
+func startServer(t *testing.T, withHealth bool, servingStatus healthpb.HealthCheckResponse_ServingStatus) string {
+	t.Helper()
+
+	lis, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	s := grpc.NewServer()
+	if withHealth {
+		healthServer := health.NewServer()
+		healthServer.SetServingStatus("", servingStatus)
+		healthpb.RegisterHealthServer(s, healthServer)
+	}
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+	return lis.Addr().String()
+}
+
+func TestFilterHealthy(t *testing.T) {
+	serving := startServer(t, true, healthpb.HealthCheckResponse_SERVING)
+	notServing := startServer(t, true, healthpb.HealthCheckResponse_NOT_SERVING)
+	noHealth := startServer(t, false, healthpb.HealthCheckResponse_SERVING)
+
+	// A closed port
+	lis, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	closed := lis.Addr().String()
+	lis.Close()
+
+	addrs := []resolver.Address{{Addr: serving}, {Addr: notServing}, {Addr: noHealth}, {Addr: closed}}
+	got := filterHealthy(context.Background(), addrs, 2*time.Second)
+
+	want := []string{serving, noHealth}
+	if len(got) != len(want) {
+		t.Fatalf("filterHealthy() = %v, want %v", got, want)
+	}
+	for i, addr := range got {
+		if addr.Addr != want[i] {
+			t.Errorf("filterHealthy()[%d] = %s, want %s", i, addr.Addr, want[i])
+		}
+	}
+}
+
+// fakeClientConn passes the states updated by the resolver to states
+type fakeClientConn struct {
+	resolver.ClientConn
+	states chan resolver.State
+}
+
+func (c *fakeClientConn) UpdateState(state resolver.State) error {
+	c.states <- state
+	return nil
+}
+
+func TestWatch_RecheckExcluded(t *testing.T) {
+	lis, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	s := grpc.NewServer()
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus("", healthpb.HealthCheckResponse_NOT_SERVING)
+	healthpb.RegisterHealthServer(s, healthServer)
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+
+	host, portStr, _ := net.SplitHostPort(lis.Addr().String())
+	port, _ := strconv.Atoi(portStr)
+	ctx, cancel := context.WithCancel(context.Background())
+	cc := &fakeClientConn{states: make(chan resolver.State, 100)}
+	r := &k8sResolver{
+		cc:                    cc,
+		ctx:                   ctx,
+		cancel:                cancel,
+		port:                  port,
+		resolveNow:            make(chan struct{}, 1),
+		healthCheckTimeout:    time.Second,
+		healthRecheckInterval: 50 * time.Millisecond,
+	}
+	notif := make(chan struct{}, 1)
+	notif <- struct{}{}
+	done := make(chan struct{})
+	go func() {
+		r.watch(notif, func() []k8s_watcher.Endpoint { return []k8s_watcher.Endpoint{{Address: host}} })
+		close(done)
+	}()
+
+	nextState := func() resolver.State {
+		t.Helper()
+		select {
+		case state := <-cc.states:
+			return state
+		case <-time.After(5 * time.Second):
+			t.Fatalf("No state update")
+			return resolver.State{}
+		}
+	}
+	if state := nextState(); len(state.Addresses) != 0 {
+		t.Fatalf("Addresses of a not serving endpoint = %v, want none", state.Addresses)
+	}
+
+	// The endpoint is probed again without a watcher notification
+	healthServer.SetServingStatus("", healthpb.HealthCheckResponse_SERVING)
+	for {
+		if state := nextState(); len(state.Addresses) == 1 {
+			break
+		}
+	}
+
+	// ResolveNow does not wait for the update
+	r.ResolveNow(resolver.ResolveNowOptions{})
+	r.ResolveNow(resolver.ResolveNowOptions{})
+	if state := nextState(); len(state.Addresses) != 1 {
+		t.Errorf("Addresses after ResolveNow() = %v, want 1", state.Addresses)
+	}
+
+	r.Close()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("watch() did not return after Close()")
+	}
+}
@@ -0,0 +1,197 @@
+package manul
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"time"
+
+	_ "golang.org/x/image/webp"
+)
+
+// Meta values are stored in a versioned fixed layout:
+//
+//	version (1) | format tag (1) | size (8) | SHA-256 (32) | created at (8) |
+//	width (4) | height (4)
+//
+// Integers are big-endian, created at is in Unix nanoseconds, 0 if unknown.
+// Width and height were added in version 2, they are 0 if unknown.
+// Later versions only append fields, so a reader decodes the fields it
+// knows from values of any later version. Values written before versioning
+// are recognized by their length: hashMetaSize or legacyMetaSize bytes.
+const (
+	// metaVersion is the version of the layout written by EncodeMeta
+	metaVersion = 2
+	// metaSize is the length of an encoded PhotoMeta of metaVersion
+	metaSize = metaV1Size + 4 + 4
+	// metaV1Size is the length of an encoded PhotoMeta of version 1
+	metaV1Size = 1 + 1 + 8 + sha256.Size + 8
+
+	// hashMetaSize is the length of unversioned metadata with format tag
+	// and SHA-256
+	hashMetaSize = 1 + sha256.Size
+	// legacyMetaSize is the length of unversioned metadata with format
+	// tag, SHA-256 and creation time
+	legacyMetaSize = hashMetaSize + 8
+)
+
+// PhotoMeta is the photo metadata stored at ingest in the meta value
+type PhotoMeta struct {
+	Format PhotoFormat
+	SHA256 [sha256.Size]byte
+
+	// Size is the photo data length, zero if stored before sizes were added
+	Size int64
+
+	// CreatedAt is the source file modification time, zero if unknown
+	CreatedAt time.Time
+
+	// Width and Height are the image dimensions in pixels, zero if stored
+	// before dimensions were added or if the image header is not readable
+	Width  int
+	Height int
+}
+
+// NewPhotoMeta computes the metadata of photoData. The dimensions are read
+// from the image header without decoding the image.
+func NewPhotoMeta(photoData []byte) PhotoMeta {
+	meta := PhotoMeta{
+		Format: DetectFormat(photoData),
+		SHA256: sha256.Sum256(photoData),
+		Size:   int64(len(photoData)),
+	}
+	meta.Width, meta.Height = DecodeDimensions(photoData)
+	return meta
+}
+
+// DecodeDimensions returns the dimensions of an image read from its
+// header, zeros if the header is not readable
+func DecodeDimensions(photoData []byte) (width, height int) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(photoData))
+	if err != nil {
+		return 0, 0
+	}
+	return cfg.Width, cfg.Height
+}
+
+// HasDimensions reports whether the dimensions are known
+func (m PhotoMeta) HasDimensions() bool {
+	return m.Width > 0 && m.Height > 0
+}
+
+// Hash returns the hex encoded SHA-256 of the photo data
+func (m PhotoMeta) Hash() string {
+	return hex.EncodeToString(m.SHA256[:])
+}
+
+// EncodeMeta encodes metadata for storing as the meta value
+func EncodeMeta(meta PhotoMeta) []byte {
+	value := make([]byte, metaSize)
+	value[0] = metaVersion
+	value[1] = byte(meta.Format)
+	binary.BigEndian.PutUint64(value[2:10], uint64(meta.Size))
+	copy(value[10:10+sha256.Size], meta.SHA256[:])
+	if !meta.CreatedAt.IsZero() {
+		binary.BigEndian.PutUint64(value[10+sha256.Size:], uint64(meta.CreatedAt.UnixNano()))
+	}
+	binary.BigEndian.PutUint32(value[metaV1Size:], uint32(meta.Width))
+	binary.BigEndian.PutUint32(value[metaV1Size+4:], uint32(meta.Height))
+	return value
+}
+
+// DecodeMeta decodes a meta value. It returns false for values written
+// before the full metadata was stored (empty or format tag only),
+// the metadata should be computed from the photo data then.
+// Unversioned values have zero Size, and zero CreatedAt if written before
+// the creation time was stored. Values before version 2 have zero Width
+// and Height.
+func DecodeMeta(value []byte) (PhotoMeta, bool) {
+	var meta PhotoMeta
+	switch {
+	case len(value) == hashMetaSize || len(value) == legacyMetaSize:
+		meta.Format = PhotoFormat(value[0])
+		copy(meta.SHA256[:], value[1:hashMetaSize])
+		if len(value) == legacyMetaSize {
+			meta.CreatedAt = decodeTime(value[hashMetaSize:])
+		}
+		return meta, true
+	case len(value) >= metaV1Size && value[0] == 1, len(value) >= metaSize && value[0] >= 2:
+		meta.Format = PhotoFormat(value[1])
+		meta.Size = int64(binary.BigEndian.Uint64(value[2:10]))
+		copy(meta.SHA256[:], value[10:10+sha256.Size])
+		meta.CreatedAt = decodeTime(value[10+sha256.Size : metaV1Size])
+		if value[0] >= 2 {
+			meta.Width = int(binary.BigEndian.Uint32(value[metaV1Size:]))
+			meta.Height = int(binary.BigEndian.Uint32(value[metaV1Size+4:]))
+		}
+		return meta, true
+	}
+	return meta, false
+}
+
+// MetaCurrent reports whether a meta value has the layout of metaVersion or
+// later. Older values decode with the fields added since then unknown.
+func MetaCurrent(value []byte) bool {
+	return len(value) >= metaSize && value[0] >= metaVersion
+}
+
+// NewMetaValue returns the meta value stored for a new photo,
+// createdAt is zero if unknown
+func NewMetaValue(photoData []byte, createdAt time.Time) []byte {
+	meta := NewPhotoMeta(photoData)
+	meta.CreatedAt = createdAt
+	return EncodeMeta(meta)
+}
+
+// StoredMeta decodes the fields stored in a meta value without computing
+// the missing ones from the photo data: fields not stored are zero, values
+// holding only a format tag keep the format.
+func StoredMeta(value []byte) PhotoMeta {
+	meta, ok := DecodeMeta(value)
+	if !ok && len(value) == 1 {
+		meta.Format = PhotoFormat(value[0])
+	}
+	return meta
+}
+
+// ResolveMeta returns the metadata of a stored meta value. Values written
+// before the current layout are recomputed from the photo data returned by
+// getData, keeping the stored creation time, and the result is passed to
+// store. Read-only databases pass a nil store, they compute only metadata
+// that was not stored at all.
+func ResolveMeta(value []byte, getData func() ([]byte, error), store func(value []byte) error) (PhotoMeta, error) {
+	meta, ok := DecodeMeta(value)
+	if ok && (MetaCurrent(value) || store == nil) {
+		return meta, nil
+	}
+
+	photoData, err := getData()
+	if err != nil {
+		return PhotoMeta{}, err
+	}
+	createdAt := meta.CreatedAt
+	meta = NewPhotoMeta(photoData)
+	meta.CreatedAt = createdAt
+
+	if store != nil {
+		if err := store(EncodeMeta(meta)); err != nil {
+			return PhotoMeta{}, fmt.Errorf("failed to store photo metadata: %w", err)
+		}
+	}
+	return meta, nil
+}
+
+// decodeTime decodes big-endian Unix nanoseconds, 0 is the zero time
+func decodeTime(value []byte) time.Time {
+	nanos := binary.BigEndian.Uint64(value)
+	if nanos == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, int64(nanos))
+}
@@ -0,0 +1,209 @@
+package manul
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"image"
+	"image/png"
+	"testing"
+	"time"
+)
+
+func TestEncodeDecodeMeta(t *testing.T) {
+	meta := NewPhotoMeta([]byte("\xff\xd8\xffphoto"))
+	meta.CreatedAt = time.Unix(1700000000, 123)
+	meta.Width, meta.Height = 640, 480
+
+	got, ok := DecodeMeta(EncodeMeta(meta))
+	if !ok {
+		t.Fatalf("DecodeMeta() failed to decode an encoded value")
+	}
+	if got.Format != FormatJPEG || got.Size != 8 || got.SHA256 != meta.SHA256 || !got.CreatedAt.Equal(meta.CreatedAt) ||
+		got.Width != 640 || got.Height != 480 {
+		t.Errorf("DecodeMeta() = %+v, want %+v", got, meta)
+	}
+
+	meta.CreatedAt = time.Time{}
+	if got, _ := DecodeMeta(EncodeMeta(meta)); !got.CreatedAt.IsZero() {
+		t.Errorf("DecodeMeta() CreatedAt = %v, want zero for unknown creation time", got.CreatedAt)
+	}
+}
+
+func TestDecodeMeta_Versions(t *testing.T) {
+	hash := sha256.Sum256([]byte("photo"))
+	createdAt := time.Unix(1700000000, 0)
+
+	// Unversioned layouts: format tag, SHA-256 and optional creation time
+	hashOnly := append([]byte{byte(FormatPNG)}, hash[:]...)
+	withTime := binary.BigEndian.AppendUint64(append([]byte{}, hashOnly...), uint64(createdAt.UnixNano()))
+
+	// Version 1 without dimensions
+	v1 := EncodeMeta(PhotoMeta{Format: FormatPNG, SHA256: hash, Size: 5, CreatedAt: createdAt})[:metaV1Size]
+	v1[0] = 1
+
+	// A later version with an extra field appended
+	future := append([]byte{}, EncodeMeta(PhotoMeta{Format: FormatPNG, SHA256: hash, Size: 5, CreatedAt: createdAt, Width: 3, Height: 2})...)
+	future[0] = metaVersion + 1
+	future = append(future, 1, 2, 3, 4)
+
+	tests := []struct {
+		name   string
+		value  []byte
+		wantOK bool
+		want   PhotoMeta
+	}{
+		{"empty", nil, false, PhotoMeta{}},
+		{"format only", []byte{byte(FormatPNG)}, false, PhotoMeta{}},
+		{"unversioned hash", hashOnly, true, PhotoMeta{Format: FormatPNG, SHA256: hash}},
+		{"unversioned creation time", withTime, true, PhotoMeta{Format: FormatPNG, SHA256: hash, CreatedAt: createdAt}},
+		{"version 1", v1, true, PhotoMeta{Format: FormatPNG, SHA256: hash, Size: 5, CreatedAt: createdAt}},
+		{"later version", future, true, PhotoMeta{Format: FormatPNG, SHA256: hash, Size: 5, CreatedAt: createdAt, Width: 3, Height: 2}},
+		{"truncated", EncodeMeta(PhotoMeta{})[:metaSize-1], false, PhotoMeta{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := DecodeMeta(tt.value)
+			if ok != tt.wantOK {
+				t.Fatalf("DecodeMeta() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if !ok {
+				return
+			}
+			if got.Format != tt.want.Format || got.SHA256 != tt.want.SHA256 || got.Size != tt.want.Size || !got.CreatedAt.Equal(tt.want.CreatedAt) ||
+				got.Width != tt.want.Width || got.Height != tt.want.Height {
+				t.Errorf("DecodeMeta() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewPhotoMeta_Dimensions(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 3, 2))); err != nil {
+		t.Fatalf("png.Encode() failed: %v", err)
+	}
+
+	meta := NewPhotoMeta(buf.Bytes())
+	if meta.Width != 3 || meta.Height != 2 || !meta.HasDimensions() {
+		t.Errorf("NewPhotoMeta() dimensions = %dx%d, want 3x2", meta.Width, meta.Height)
+	}
+
+	meta = NewPhotoMeta([]byte("not an image"))
+	if meta.HasDimensions() {
+		t.Errorf("NewPhotoMeta() of invalid data dimensions = %dx%d, want unknown", meta.Width, meta.Height)
+	}
+}
+
+func TestMetaCurrent(t *testing.T) {
+	current := EncodeMeta(PhotoMeta{})
+	v1 := append([]byte{}, current[:metaV1Size]...)
+	v1[0] = 1
+
+	tests := []struct {
+		name  string
+		value []byte
+		want  bool
+	}{
+		{"empty", nil, false},
+		{"unversioned", make([]byte, legacyMetaSize), false},
+		{"version 1", v1, false},
+		{"current", current, true},
+	}
+	for _, tt := range tests {
+		if got := MetaCurrent(tt.value); got != tt.want {
+			t.Errorf("MetaCurrent(%s) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestResolveMeta(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 3, 2))); err != nil {
+		t.Fatalf("png.Encode() failed: %v", err)
+	}
+	photoData := buf.Bytes()
+	createdAt := time.Unix(1700000000, 0)
+	current := NewMetaValue(photoData, createdAt)
+
+	// Version 1 values have no dimensions
+	v1 := append([]byte{}, current[:metaV1Size]...)
+	v1[0] = 1
+
+	tests := []struct {
+		name       string
+		value      []byte
+		readOnly   bool
+		wantReads  int
+		wantStored bool
+		wantWidth  int
+	}{
+		{"current", current, false, 0, false, 3},
+		{"older version", v1, false, 1, true, 3},
+		{"older version read-only", v1, true, 0, false, 0},
+		{"not stored", nil, false, 1, true, 3},
+		{"not stored read-only", nil, true, 1, false, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reads := 0
+			getData := func() ([]byte, error) {
+				reads++
+				return photoData, nil
+			}
+			var stored []byte
+			store := func(value []byte) error {
+				stored = value
+				return nil
+			}
+			if tt.readOnly {
+				store = nil
+			}
+
+			meta, err := ResolveMeta(tt.value, getData, store)
+			if err != nil {
+				t.Fatalf("ResolveMeta() failed: %v", err)
+			}
+			if reads != tt.wantReads || (stored != nil) != tt.wantStored {
+				t.Errorf("ResolveMeta() read data %d times, stored %v, want %d, %v", reads, stored != nil, tt.wantReads, tt.wantStored)
+			}
+			if meta.Width != tt.wantWidth {
+				t.Errorf("ResolveMeta() width = %d, want %d", meta.Width, tt.wantWidth)
+			}
+			if tt.value != nil && !meta.CreatedAt.Equal(createdAt) {
+				t.Errorf("ResolveMeta() CreatedAt = %v, want the stored %v", meta.CreatedAt, createdAt)
+			}
+			if tt.wantStored && !MetaCurrent(stored) {
+				t.Errorf("ResolveMeta() stored a value of an older layout")
+			}
+		})
+	}
+}
+
+func TestStoredMeta(t *testing.T) {
+	createdAt := time.Unix(1700000000, 0)
+	current := EncodeMeta(PhotoMeta{Format: FormatPNG, Size: 5, CreatedAt: createdAt, Width: 3, Height: 2})
+	v1 := append([]byte{}, current[:metaV1Size]...)
+	v1[0] = 1
+
+	tests := []struct {
+		name       string
+		value      []byte
+		wantFormat PhotoFormat
+		wantSize   int64
+		wantWidth  int
+	}{
+		{"empty", nil, FormatUnknown, 0, 0},
+		{"format tag", []byte{byte(FormatPNG)}, FormatPNG, 0, 0},
+		{"version 1", v1, FormatPNG, 5, 0},
+		{"current", current, FormatPNG, 5, 3},
+	}
+	for _, tt := range tests {
+		got := StoredMeta(tt.value)
+		if got.Format != tt.wantFormat || got.Size != tt.wantSize || got.Width != tt.wantWidth {
+			t.Errorf("StoredMeta(%s) = %s, %d bytes, width %d, want %s, %d bytes, width %d",
+				tt.name, got.Format, got.Size, got.Width, tt.wantFormat, tt.wantSize, tt.wantWidth)
+		}
+	}
+}
@@ -23,11 +23,14 @@ const (
 type ScalingAlgorithm int32
 
 const (
+	// Do not scale, the original photo is returned even if width is set
 	ScalingAlgorithm_NONE             ScalingAlgorithm = 0
 	ScalingAlgorithm_NEAREST_NEIGHBOR ScalingAlgorithm = 1
 	ScalingAlgorithm_BILINEAR         ScalingAlgorithm = 2
 	ScalingAlgorithm_CATMULL_ROM      ScalingAlgorithm = 3
 	ScalingAlgorithm_APPROX_BILINEAR  ScalingAlgorithm = 4
+	// Let the server choose the algorithm by the downscale ratio
+	ScalingAlgorithm_AUTO ScalingAlgorithm = 5
 )
 
 // Enum value maps for ScalingAlgorithm.
@@ -38,6 +41,7 @@ var (
 		2: "BILINEAR",
 		3: "CATMULL_ROM",
 		4: "APPROX_BILINEAR",
+		5: "AUTO",
 	}
 	ScalingAlgorithm_value = map[string]int32{
 		"NONE":             0,
@@ -45,6 +49,7 @@ var (
 		"BILINEAR":         2,
 		"CATMULL_ROM":      3,
 		"APPROX_BILINEAR":  4,
+		"AUTO":             5,
 	}
 )
 
@@ -75,10 +80,181 @@ func (ScalingAlgorithm) EnumDescriptor() ([]byte, []int) {
 	return file_cat_photos_proto_rawDescGZIP(), []int{0}
 }
 
+// How a photo is scaled into a box when both width and height are set
+type FitMode int32
+
+const (
+	// Keep the aspect ratio and fit within the box, photos are not upscaled
+	FitMode_FIT FitMode = 0
+	// Keep the aspect ratio, cover the box and crop the center to its size
+	FitMode_FILL FitMode = 1
+	// Scale to the box size ignoring the aspect ratio
+	FitMode_STRETCH FitMode = 2
+)
+
+// Enum value maps for FitMode.
+var (
+	FitMode_name = map[int32]string{
+		0: "FIT",
+		1: "FILL",
+		2: "STRETCH",
+	}
+	FitMode_value = map[string]int32{
+		"FIT":     0,
+		"FILL":    1,
+		"STRETCH": 2,
+	}
+)
+
+func (x FitMode) Enum() *FitMode {
+	p := new(FitMode)
+	*p = x
+	return p
+}
+
+func (x FitMode) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (FitMode) Descriptor() protoreflect.EnumDescriptor {
+	return file_cat_photos_proto_enumTypes[1].Descriptor()
+}
+
+func (FitMode) Type() protoreflect.EnumType {
+	return &file_cat_photos_proto_enumTypes[1]
+}
+
+func (x FitMode) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use FitMode.Descriptor instead.
+func (FitMode) EnumDescriptor() ([]byte, []int) {
+	return file_cat_photos_proto_rawDescGZIP(), []int{1}
+}
+
+type OutputFormat int32
+
+const (
+	// Keep the stored format, scaled photos are encoded as JPEG
+	OutputFormat_ORIGINAL OutputFormat = 0
+	OutputFormat_JPEG     OutputFormat = 1
+	OutputFormat_PNG      OutputFormat = 2
+	// Not supported, WebP output returns UNIMPLEMENTED
+	OutputFormat_WEBP OutputFormat = 3
+)
+
+// Enum value maps for OutputFormat.
+var (
+	OutputFormat_name = map[int32]string{
+		0: "ORIGINAL",
+		1: "JPEG",
+		2: "PNG",
+		3: "WEBP",
+	}
+	OutputFormat_value = map[string]int32{
+		"ORIGINAL": 0,
+		"JPEG":     1,
+		"PNG":      2,
+		"WEBP":     3,
+	}
+)
+
+func (x OutputFormat) Enum() *OutputFormat {
+	p := new(OutputFormat)
+	*p = x
+	return p
+}
+
+func (x OutputFormat) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (OutputFormat) Descriptor() protoreflect.EnumDescriptor {
+	return file_cat_photos_proto_enumTypes[2].Descriptor()
+}
+
+func (OutputFormat) Type() protoreflect.EnumType {
+	return &file_cat_photos_proto_enumTypes[2]
+}
+
+func (x OutputFormat) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use OutputFormat.Descriptor instead.
+func (OutputFormat) EnumDescriptor() ([]byte, []int) {
+	return file_cat_photos_proto_rawDescGZIP(), []int{2}
+}
+
+// Named photo sizes, mapped by the server to a width, scaling algorithm
+// and JPEG quality (see the -preset-* server flags). Clients using presets
+// share scaled photo cache entries.
+type SizePreset int32
+
+const (
+	// No preset, the explicit size fields are used
+	SizePreset_NO_PRESET SizePreset = 0
+	// 200 pixels wide by default
+	SizePreset_THUMB SizePreset = 1
+	// 800 pixels wide by default
+	SizePreset_MEDIUM SizePreset = 2
+	// The original size
+	SizePreset_FULL SizePreset = 3
+)
+
+// Enum value maps for SizePreset.
+var (
+	SizePreset_name = map[int32]string{
+		0: "NO_PRESET",
+		1: "THUMB",
+		2: "MEDIUM",
+		3: "FULL",
+	}
+	SizePreset_value = map[string]int32{
+		"NO_PRESET": 0,
+		"THUMB":     1,
+		"MEDIUM":    2,
+		"FULL":      3,
+	}
+)
+
+func (x SizePreset) Enum() *SizePreset {
+	p := new(SizePreset)
+	*p = x
+	return p
+}
+
+func (x SizePreset) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (SizePreset) Descriptor() protoreflect.EnumDescriptor {
+	return file_cat_photos_proto_enumTypes[3].Descriptor()
+}
+
+func (SizePreset) Type() protoreflect.EnumType {
+	return &file_cat_photos_proto_enumTypes[3]
+}
+
+func (x SizePreset) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use SizePreset.Descriptor instead.
+func (SizePreset) EnumDescriptor() ([]byte, []int) {
+	return file_cat_photos_proto_rawDescGZIP(), []int{3}
+}
+
 type ListCatsRequest struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
+
+	// Maximum number of cat IDs returned, the server default is used if <= 0
+	PageSize int32 `protobuf:"varint,1,opt,name=page_size,json=pageSize,proto3" json:"page_size,omitempty"`
+	// next_page_token of the previous page, empty for the first page
+	PageToken string `protobuf:"bytes,2,opt,name=page_token,json=pageToken,proto3" json:"page_token,omitempty"`
 }
 
 func (x *ListCatsRequest) Reset() {
@@ -113,12 +289,29 @@ func (*ListCatsRequest) Descriptor() ([]byte, []int) {
 	return file_cat_photos_proto_rawDescGZIP(), []int{0}
 }
 
+func (x *ListCatsRequest) GetPageSize() int32 {
+	if x != nil {
+		return x.PageSize
+	}
+	return 0
+}
+
+func (x *ListCatsRequest) GetPageToken() string {
+	if x != nil {
+		return x.PageToken
+	}
+	return ""
+}
+
 type ListCatsResponse struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
+	// Cat IDs in ascending order
 	CatIds []uint64 `protobuf:"varint,1,rep,packed,name=cat_ids,json=catIds,proto3" json:"cat_ids,omitempty"`
+	// Token of the next page, empty on the last page
+	NextPageToken string `protobuf:"bytes,2,opt,name=next_page_token,json=nextPageToken,proto3" json:"next_page_token,omitempty"`
 }
 
 func (x *ListCatsResponse) Reset() {
@@ -160,12 +353,23 @@ func (x *ListCatsResponse) GetCatIds() []uint64 {
 	return nil
 }
 
+func (x *ListCatsResponse) GetNextPageToken() string {
+	if x != nil {
+		return x.NextPageToken
+	}
+	return ""
+}
+
 type ListPhotosRequest struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
 	CatId uint64 `protobuf:"varint,1,opt,name=cat_id,json=catId,proto3" json:"cat_id,omitempty"`
+	// Maximum number of photo IDs returned, the server default is used if <= 0
+	PageSize int32 `protobuf:"varint,2,opt,name=page_size,json=pageSize,proto3" json:"page_size,omitempty"`
+	// next_page_token of the previous page, empty for the first page
+	PageToken string `protobuf:"bytes,3,opt,name=page_token,json=pageToken,proto3" json:"page_token,omitempty"`
 }
 
 func (x *ListPhotosRequest) Reset() {
@@ -207,12 +411,29 @@ func (x *ListPhotosRequest) GetCatId() uint64 {
 	return 0
 }
 
+func (x *ListPhotosRequest) GetPageSize() int32 {
+	if x != nil {
+		return x.PageSize
+	}
+	return 0
+}
+
+func (x *ListPhotosRequest) GetPageToken() string {
+	if x != nil {
+		return x.PageToken
+	}
+	return ""
+}
+
 type ListPhotosResponse struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
+	// Photo IDs in ascending order
 	PhotoIds []uint64 `protobuf:"varint,1,rep,packed,name=photo_ids,json=photoIds,proto3" json:"photo_ids,omitempty"`
+	// Token of the next page, empty on the last page
+	NextPageToken string `protobuf:"bytes,2,opt,name=next_page_token,json=nextPageToken,proto3" json:"next_page_token,omitempty"`
 }
 
 func (x *ListPhotosResponse) Reset() {
@@ -254,6 +475,13 @@ func (x *ListPhotosResponse) GetPhotoIds() []uint64 {
 	return nil
 }
 
+func (x *ListPhotosResponse) GetNextPageToken() string {
+	if x != nil {
+		return x.NextPageToken
+	}
+	return ""
+}
+
 type GetPhotoRequest struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
@@ -263,6 +491,26 @@ type GetPhotoRequest struct {
 	PhotoId          uint64           `protobuf:"varint,2,opt,name=photo_id,json=photoId,proto3" json:"photo_id,omitempty"`
 	Width            uint32           `protobuf:"varint,3,opt,name=width,proto3" json:"width,omitempty"`
 	ScalingAlgorithm ScalingAlgorithm `protobuf:"varint,4,opt,name=scaling_algorithm,json=scalingAlgorithm,proto3,enum=catphotos.ScalingAlgorithm" json:"scaling_algorithm,omitempty"`
+	// content_hash of a cached copy, the photo is not sent if it is unchanged
+	IfNoneMatch string `protobuf:"bytes,5,opt,name=if_none_match,json=ifNoneMatch,proto3" json:"if_none_match,omitempty"`
+	// Format of the returned photo, converted from the stored one if needed
+	OutputFormat OutputFormat `protobuf:"varint,6,opt,name=output_format,json=outputFormat,proto3,enum=catphotos.OutputFormat" json:"output_format,omitempty"`
+	// Height of the box to scale into, the height follows the aspect ratio
+	// of width if not set and the width follows this one if only it is set
+	Height uint32 `protobuf:"varint,7,opt,name=height,proto3" json:"height,omitempty"`
+	// Used if both width and height are set
+	Fit FitMode `protobuf:"varint,8,opt,name=fit,proto3,enum=catphotos.FitMode" json:"fit,omitempty"`
+	// JPEG quality 1-100 of scaled or converted photos, 0 means the server
+	// default, larger values are clamped to 100
+	Quality uint32 `protobuf:"varint,9,opt,name=quality,proto3" json:"quality,omitempty"`
+	// Replaces width, height, fit, scaling_algorithm and quality if set,
+	// output_format still applies
+	SizePreset SizePreset `protobuf:"varint,10,opt,name=size_preset,json=sizePreset,proto3,enum=catphotos.SizePreset" json:"size_preset,omitempty"`
+	// Formats accepted by the client, replaces output_format if set. Like
+	// HTTP content negotiation the server picks the first accepted one of
+	// JPEG, PNG and ORIGINAL, so ORIGINAL accepts any stored format. WEBP
+	// is not supported and ignored.
+	AcceptFormats []OutputFormat `protobuf:"varint,11,rep,packed,name=accept_formats,json=acceptFormats,proto3,enum=catphotos.OutputFormat" json:"accept_formats,omitempty"`
 }
 
 func (x *GetPhotoRequest) Reset() {
@@ -325,12 +573,69 @@ func (x *GetPhotoRequest) GetScalingAlgorithm() ScalingAlgorithm {
 	return ScalingAlgorithm_NONE
 }
 
+func (x *GetPhotoRequest) GetIfNoneMatch() string {
+	if x != nil {
+		return x.IfNoneMatch
+	}
+	return ""
+}
+
+func (x *GetPhotoRequest) GetOutputFormat() OutputFormat {
+	if x != nil {
+		return x.OutputFormat
+	}
+	return OutputFormat_ORIGINAL
+}
+
+func (x *GetPhotoRequest) GetHeight() uint32 {
+	if x != nil {
+		return x.Height
+	}
+	return 0
+}
+
+func (x *GetPhotoRequest) GetFit() FitMode {
+	if x != nil {
+		return x.Fit
+	}
+	return FitMode_FIT
+}
+
+func (x *GetPhotoRequest) GetQuality() uint32 {
+	if x != nil {
+		return x.Quality
+	}
+	return 0
+}
+
+func (x *GetPhotoRequest) GetSizePreset() SizePreset {
+	if x != nil {
+		return x.SizePreset
+	}
+	return SizePreset_NO_PRESET
+}
+
+func (x *GetPhotoRequest) GetAcceptFormats() []OutputFormat {
+	if x != nil {
+		return x.AcceptFormats
+	}
+	return nil
+}
+
 type GetPhotoResponse struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
 	PhotoData []byte `protobuf:"bytes,1,opt,name=photo_data,json=photoData,proto3" json:"photo_data,omitempty"`
+	// MIME type of photo_data, e.g. image/jpeg
+	ContentType string `protobuf:"bytes,2,opt,name=content_type,json=contentType,proto3" json:"content_type,omitempty"`
+	// Set instead of photo_data when if_none_match matches content_hash
+	NotModified bool `protobuf:"varint,3,opt,name=not_modified,json=notModified,proto3" json:"not_modified,omitempty"`
+	// Identifies the photo content including scaling, usable as an ETag
+	ContentHash string `protobuf:"bytes,4,opt,name=content_hash,json=contentHash,proto3" json:"content_hash,omitempty"`
+	// Output format chosen from accept_formats, or output_format
+	Format OutputFormat `protobuf:"varint,5,opt,name=format,proto3,enum=catphotos.OutputFormat" json:"format,omitempty"`
 }
 
 func (x *GetPhotoResponse) Reset() {
@@ -372,17 +677,47 @@ func (x *GetPhotoResponse) GetPhotoData() []byte {
 	return nil
 }
 
-type PhotoRequest struct {
+func (x *GetPhotoResponse) GetContentType() string {
+	if x != nil {
+		return x.ContentType
+	}
+	return ""
+}
+
+func (x *GetPhotoResponse) GetNotModified() bool {
+	if x != nil {
+		return x.NotModified
+	}
+	return false
+}
+
+func (x *GetPhotoResponse) GetContentHash() string {
+	if x != nil {
+		return x.ContentHash
+	}
+	return ""
+}
+
+func (x *GetPhotoResponse) GetFormat() OutputFormat {
+	if x != nil {
+		return x.Format
+	}
+	return OutputFormat_ORIGINAL
+}
+
+type GetPhotoChunkedRequest struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	CatId   uint64 `protobuf:"varint,1,opt,name=cat_id,json=catId,proto3" json:"cat_id,omitempty"`
-	PhotoId uint64 `protobuf:"varint,2,opt,name=photo_id,json=photoId,proto3" json:"photo_id,omitempty"`
+	Photo *GetPhotoRequest `protobuf:"bytes,1,opt,name=photo,proto3" json:"photo,omitempty"`
+	// Maximum bytes per chunk, the server default (256KiB) is used if 0,
+	// larger values are capped to the server maximum (1MiB)
+	ChunkSize uint32 `protobuf:"varint,2,opt,name=chunk_size,json=chunkSize,proto3" json:"chunk_size,omitempty"`
 }
 
-func (x *PhotoRequest) Reset() {
-	*x = PhotoRequest{}
+func (x *GetPhotoChunkedRequest) Reset() {
+	*x = GetPhotoChunkedRequest{}
 	if protoimpl.UnsafeEnabled {
 		mi := &file_cat_photos_proto_msgTypes[6]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -390,13 +725,13 @@ func (x *PhotoRequest) Reset() {
 	}
 }
 
-func (x *PhotoRequest) String() string {
+func (x *GetPhotoChunkedRequest) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*PhotoRequest) ProtoMessage() {}
+func (*GetPhotoChunkedRequest) ProtoMessage() {}
 
-func (x *PhotoRequest) ProtoReflect() protoreflect.Message {
+func (x *GetPhotoChunkedRequest) ProtoReflect() protoreflect.Message {
 	mi := &file_cat_photos_proto_msgTypes[6]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -408,37 +743,44 @@ func (x *PhotoRequest) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use PhotoRequest.ProtoReflect.Descriptor instead.
-func (*PhotoRequest) Descriptor() ([]byte, []int) {
+// Deprecated: Use GetPhotoChunkedRequest.ProtoReflect.Descriptor instead.
+func (*GetPhotoChunkedRequest) Descriptor() ([]byte, []int) {
 	return file_cat_photos_proto_rawDescGZIP(), []int{6}
 }
 
-func (x *PhotoRequest) GetCatId() uint64 {
+func (x *GetPhotoChunkedRequest) GetPhoto() *GetPhotoRequest {
 	if x != nil {
-		return x.CatId
+		return x.Photo
 	}
-	return 0
+	return nil
 }
 
-func (x *PhotoRequest) GetPhotoId() uint64 {
+func (x *GetPhotoChunkedRequest) GetChunkSize() uint32 {
 	if x != nil {
-		return x.PhotoId
+		return x.ChunkSize
 	}
 	return 0
 }
 
-type GetPhotosStreamRequest struct {
+type PhotoChunk struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	PhotoRequests    []*PhotoRequest  `protobuf:"bytes,1,rep,name=photo_requests,json=photoRequests,proto3" json:"photo_requests,omitempty"`
-	Width            uint32           `protobuf:"varint,2,opt,name=width,proto3" json:"width,omitempty"`
-	ScalingAlgorithm ScalingAlgorithm `protobuf:"varint,3,opt,name=scaling_algorithm,json=scalingAlgorithm,proto3,enum=catphotos.ScalingAlgorithm" json:"scaling_algorithm,omitempty"`
+	Data []byte `protobuf:"bytes,1,opt,name=data,proto3" json:"data,omitempty"`
+	// The fields below are set in the first chunk only
+	// Size of the whole photo
+	TotalSize uint64 `protobuf:"varint,2,opt,name=total_size,json=totalSize,proto3" json:"total_size,omitempty"`
+	// MIME type of the photo, e.g. image/jpeg
+	ContentType string `protobuf:"bytes,3,opt,name=content_type,json=contentType,proto3" json:"content_type,omitempty"`
+	// Set instead of data when if_none_match matches content_hash
+	NotModified bool `protobuf:"varint,4,opt,name=not_modified,json=notModified,proto3" json:"not_modified,omitempty"`
+	// Identifies the photo content including scaling, usable as an ETag
+	ContentHash string `protobuf:"bytes,5,opt,name=content_hash,json=contentHash,proto3" json:"content_hash,omitempty"`
 }
 
-func (x *GetPhotosStreamRequest) Reset() {
-	*x = GetPhotosStreamRequest{}
+func (x *PhotoChunk) Reset() {
+	*x = PhotoChunk{}
 	if protoimpl.UnsafeEnabled {
 		mi := &file_cat_photos_proto_msgTypes[7]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -446,13 +788,13 @@ func (x *GetPhotosStreamRequest) Reset() {
 	}
 }
 
-func (x *GetPhotosStreamRequest) String() string {
+func (x *PhotoChunk) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*GetPhotosStreamRequest) ProtoMessage() {}
+func (*PhotoChunk) ProtoMessage() {}
 
-func (x *GetPhotosStreamRequest) ProtoReflect() protoreflect.Message {
+func (x *PhotoChunk) ProtoReflect() protoreflect.Message {
 	mi := &file_cat_photos_proto_msgTypes[7]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -464,46 +806,57 @@ func (x *GetPhotosStreamRequest) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use GetPhotosStreamRequest.ProtoReflect.Descriptor instead.
-func (*GetPhotosStreamRequest) Descriptor() ([]byte, []int) {
+// Deprecated: Use PhotoChunk.ProtoReflect.Descriptor instead.
+func (*PhotoChunk) Descriptor() ([]byte, []int) {
 	return file_cat_photos_proto_rawDescGZIP(), []int{7}
 }
 
-func (x *GetPhotosStreamRequest) GetPhotoRequests() []*PhotoRequest {
+func (x *PhotoChunk) GetData() []byte {
 	if x != nil {
-		return x.PhotoRequests
+		return x.Data
 	}
 	return nil
 }
 
-func (x *GetPhotosStreamRequest) GetWidth() uint32 {
+func (x *PhotoChunk) GetTotalSize() uint64 {
 	if x != nil {
-		return x.Width
+		return x.TotalSize
 	}
 	return 0
 }
 
-func (x *GetPhotosStreamRequest) GetScalingAlgorithm() ScalingAlgorithm {
+func (x *PhotoChunk) GetContentType() string {
 	if x != nil {
-		return x.ScalingAlgorithm
+		return x.ContentType
 	}
-	return ScalingAlgorithm_NONE
+	return ""
 }
 
-type GetPhotosStreamResponse struct {
+func (x *PhotoChunk) GetNotModified() bool {
+	if x != nil {
+		return x.NotModified
+	}
+	return false
+}
+
+func (x *PhotoChunk) GetContentHash() string {
+	if x != nil {
+		return x.ContentHash
+	}
+	return ""
+}
+
+type GetPhotoMetadataRequest struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	CatId        uint64 `protobuf:"varint,1,opt,name=cat_id,json=catId,proto3" json:"cat_id,omitempty"`
-	PhotoId      uint64 `protobuf:"varint,2,opt,name=photo_id,json=photoId,proto3" json:"photo_id,omitempty"`
-	PhotoData    []byte `protobuf:"bytes,3,opt,name=photo_data,json=photoData,proto3" json:"photo_data,omitempty"`
-	Success      bool   `protobuf:"varint,4,opt,name=success,proto3" json:"success,omitempty"`
-	ErrorMessage string `protobuf:"bytes,5,opt,name=error_message,json=errorMessage,proto3" json:"error_message,omitempty"`
+	CatId   uint64 `protobuf:"varint,1,opt,name=cat_id,json=catId,proto3" json:"cat_id,omitempty"`
+	PhotoId uint64 `protobuf:"varint,2,opt,name=photo_id,json=photoId,proto3" json:"photo_id,omitempty"`
 }
 
-func (x *GetPhotosStreamResponse) Reset() {
-	*x = GetPhotosStreamResponse{}
+func (x *GetPhotoMetadataRequest) Reset() {
+	*x = GetPhotoMetadataRequest{}
 	if protoimpl.UnsafeEnabled {
 		mi := &file_cat_photos_proto_msgTypes[8]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -511,13 +864,13 @@ func (x *GetPhotosStreamResponse) Reset() {
 	}
 }
 
-func (x *GetPhotosStreamResponse) String() string {
+func (x *GetPhotoMetadataRequest) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*GetPhotosStreamResponse) ProtoMessage() {}
+func (*GetPhotoMetadataRequest) ProtoMessage() {}
 
-func (x *GetPhotosStreamResponse) ProtoReflect() protoreflect.Message {
+func (x *GetPhotoMetadataRequest) ProtoReflect() protoreflect.Message {
 	mi := &file_cat_photos_proto_msgTypes[8]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -529,131 +882,985 @@ func (x *GetPhotosStreamResponse) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use GetPhotosStreamResponse.ProtoReflect.Descriptor instead.
-func (*GetPhotosStreamResponse) Descriptor() ([]byte, []int) {
+// Deprecated: Use GetPhotoMetadataRequest.ProtoReflect.Descriptor instead.
+func (*GetPhotoMetadataRequest) Descriptor() ([]byte, []int) {
 	return file_cat_photos_proto_rawDescGZIP(), []int{8}
 }
 
-func (x *GetPhotosStreamResponse) GetCatId() uint64 {
+func (x *GetPhotoMetadataRequest) GetCatId() uint64 {
 	if x != nil {
 		return x.CatId
 	}
 	return 0
 }
 
-func (x *GetPhotosStreamResponse) GetPhotoId() uint64 {
+func (x *GetPhotoMetadataRequest) GetPhotoId() uint64 {
 	if x != nil {
 		return x.PhotoId
 	}
 	return 0
 }
 
-func (x *GetPhotosStreamResponse) GetPhotoData() []byte {
-	if x != nil {
-		return x.PhotoData
-	}
-	return nil
+type GetPhotoMetadataResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Dimensions of the stored photo in pixels, 0 if its header is not readable
+	Width  uint32 `protobuf:"varint,1,opt,name=width,proto3" json:"width,omitempty"`
+	Height uint32 `protobuf:"varint,2,opt,name=height,proto3" json:"height,omitempty"`
+	// Size of the stored photo data in bytes
+	Size uint64 `protobuf:"varint,3,opt,name=size,proto3" json:"size,omitempty"`
+	// Image format of the stored photo, e.g. jpeg
+	Format string `protobuf:"bytes,4,opt,name=format,proto3" json:"format,omitempty"`
 }
 
-func (x *GetPhotosStreamResponse) GetSuccess() bool {
-	if x != nil {
-		return x.Success
+func (x *GetPhotoMetadataResponse) Reset() {
+	*x = GetPhotoMetadataResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_cat_photos_proto_msgTypes[9]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
 	}
-	return false
 }
 
-func (x *GetPhotosStreamResponse) GetErrorMessage() string {
-	if x != nil {
-		return x.ErrorMessage
+func (x *GetPhotoMetadataResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetPhotoMetadataResponse) ProtoMessage() {}
+
+func (x *GetPhotoMetadataResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_cat_photos_proto_msgTypes[9]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
 	}
-	return ""
+	return mi.MessageOf(x)
 }
 
-var File_cat_photos_proto protoreflect.FileDescriptor
+// Deprecated: Use GetPhotoMetadataResponse.ProtoReflect.Descriptor instead.
+func (*GetPhotoMetadataResponse) Descriptor() ([]byte, []int) {
+	return file_cat_photos_proto_rawDescGZIP(), []int{9}
+}
 
-var file_cat_photos_proto_rawDesc = []byte{
+func (x *GetPhotoMetadataResponse) GetWidth() uint32 {
+	if x != nil {
+		return x.Width
+	}
+	return 0
+}
+
+func (x *GetPhotoMetadataResponse) GetHeight() uint32 {
+	if x != nil {
+		return x.Height
+	}
+	return 0
+}
+
+func (x *GetPhotoMetadataResponse) GetSize() uint64 {
+	if x != nil {
+		return x.Size
+	}
+	return 0
+}
+
+func (x *GetPhotoMetadataResponse) GetFormat() string {
+	if x != nil {
+		return x.Format
+	}
+	return ""
+}
+
+type GetRandomPhotoRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Scaling and format of the returned photo as in GetPhoto, cat_id,
+	// photo_id and if_none_match are ignored. Only the IDs are returned if
+	// not set.
+	Photo *GetPhotoRequest `protobuf:"bytes,1,opt,name=photo,proto3" json:"photo,omitempty"`
+}
+
+func (x *GetRandomPhotoRequest) Reset() {
+	*x = GetRandomPhotoRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_cat_photos_proto_msgTypes[10]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *GetRandomPhotoRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetRandomPhotoRequest) ProtoMessage() {}
+
+func (x *GetRandomPhotoRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_cat_photos_proto_msgTypes[10]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetRandomPhotoRequest.ProtoReflect.Descriptor instead.
+func (*GetRandomPhotoRequest) Descriptor() ([]byte, []int) {
+	return file_cat_photos_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *GetRandomPhotoRequest) GetPhoto() *GetPhotoRequest {
+	if x != nil {
+		return x.Photo
+	}
+	return nil
+}
+
+type GetRandomPhotoResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	CatId   uint64 `protobuf:"varint,1,opt,name=cat_id,json=catId,proto3" json:"cat_id,omitempty"`
+	PhotoId uint64 `protobuf:"varint,2,opt,name=photo_id,json=photoId,proto3" json:"photo_id,omitempty"`
+	// The photo as GetPhoto returns it, not set without a request photo
+	Photo *GetPhotoResponse `protobuf:"bytes,3,opt,name=photo,proto3" json:"photo,omitempty"`
+}
+
+func (x *GetRandomPhotoResponse) Reset() {
+	*x = GetRandomPhotoResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_cat_photos_proto_msgTypes[11]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *GetRandomPhotoResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetRandomPhotoResponse) ProtoMessage() {}
+
+func (x *GetRandomPhotoResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_cat_photos_proto_msgTypes[11]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetRandomPhotoResponse.ProtoReflect.Descriptor instead.
+func (*GetRandomPhotoResponse) Descriptor() ([]byte, []int) {
+	return file_cat_photos_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *GetRandomPhotoResponse) GetCatId() uint64 {
+	if x != nil {
+		return x.CatId
+	}
+	return 0
+}
+
+func (x *GetRandomPhotoResponse) GetPhotoId() uint64 {
+	if x != nil {
+		return x.PhotoId
+	}
+	return 0
+}
+
+func (x *GetRandomPhotoResponse) GetPhoto() *GetPhotoResponse {
+	if x != nil {
+		return x.Photo
+	}
+	return nil
+}
+
+type PhotoRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	CatId   uint64 `protobuf:"varint,1,opt,name=cat_id,json=catId,proto3" json:"cat_id,omitempty"`
+	PhotoId uint64 `protobuf:"varint,2,opt,name=photo_id,json=photoId,proto3" json:"photo_id,omitempty"`
+}
+
+func (x *PhotoRequest) Reset() {
+	*x = PhotoRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_cat_photos_proto_msgTypes[12]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *PhotoRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PhotoRequest) ProtoMessage() {}
+
+func (x *PhotoRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_cat_photos_proto_msgTypes[12]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PhotoRequest.ProtoReflect.Descriptor instead.
+func (*PhotoRequest) Descriptor() ([]byte, []int) {
+	return file_cat_photos_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *PhotoRequest) GetCatId() uint64 {
+	if x != nil {
+		return x.CatId
+	}
+	return 0
+}
+
+func (x *PhotoRequest) GetPhotoId() uint64 {
+	if x != nil {
+		return x.PhotoId
+	}
+	return 0
+}
+
+type GetPhotosStreamRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	PhotoRequests    []*PhotoRequest  `protobuf:"bytes,1,rep,name=photo_requests,json=photoRequests,proto3" json:"photo_requests,omitempty"`
+	Width            uint32           `protobuf:"varint,2,opt,name=width,proto3" json:"width,omitempty"`
+	ScalingAlgorithm ScalingAlgorithm `protobuf:"varint,3,opt,name=scaling_algorithm,json=scalingAlgorithm,proto3,enum=catphotos.ScalingAlgorithm" json:"scaling_algorithm,omitempty"`
+}
+
+func (x *GetPhotosStreamRequest) Reset() {
+	*x = GetPhotosStreamRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_cat_photos_proto_msgTypes[13]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *GetPhotosStreamRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetPhotosStreamRequest) ProtoMessage() {}
+
+func (x *GetPhotosStreamRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_cat_photos_proto_msgTypes[13]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetPhotosStreamRequest.ProtoReflect.Descriptor instead.
+func (*GetPhotosStreamRequest) Descriptor() ([]byte, []int) {
+	return file_cat_photos_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *GetPhotosStreamRequest) GetPhotoRequests() []*PhotoRequest {
+	if x != nil {
+		return x.PhotoRequests
+	}
+	return nil
+}
+
+func (x *GetPhotosStreamRequest) GetWidth() uint32 {
+	if x != nil {
+		return x.Width
+	}
+	return 0
+}
+
+func (x *GetPhotosStreamRequest) GetScalingAlgorithm() ScalingAlgorithm {
+	if x != nil {
+		return x.ScalingAlgorithm
+	}
+	return ScalingAlgorithm_NONE
+}
+
+type GetPhotosStreamResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	CatId        uint64 `protobuf:"varint,1,opt,name=cat_id,json=catId,proto3" json:"cat_id,omitempty"`
+	PhotoId      uint64 `protobuf:"varint,2,opt,name=photo_id,json=photoId,proto3" json:"photo_id,omitempty"`
+	PhotoData    []byte `protobuf:"bytes,3,opt,name=photo_data,json=photoData,proto3" json:"photo_data,omitempty"`
+	Success      bool   `protobuf:"varint,4,opt,name=success,proto3" json:"success,omitempty"`
+	ErrorMessage string `protobuf:"bytes,5,opt,name=error_message,json=errorMessage,proto3" json:"error_message,omitempty"`
+	// MIME type of photo_data, e.g. image/jpeg
+	ContentType string `protobuf:"bytes,6,opt,name=content_type,json=contentType,proto3" json:"content_type,omitempty"`
+	// Length of photo_data, to detect truncated transfers
+	SizeBytes uint64 `protobuf:"varint,7,opt,name=size_bytes,json=sizeBytes,proto3" json:"size_bytes,omitempty"`
+	// Image format of photo_data, e.g. jpeg
+	Format string `protobuf:"bytes,8,opt,name=format,proto3" json:"format,omitempty"`
+}
+
+func (x *GetPhotosStreamResponse) Reset() {
+	*x = GetPhotosStreamResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_cat_photos_proto_msgTypes[14]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *GetPhotosStreamResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetPhotosStreamResponse) ProtoMessage() {}
+
+func (x *GetPhotosStreamResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_cat_photos_proto_msgTypes[14]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetPhotosStreamResponse.ProtoReflect.Descriptor instead.
+func (*GetPhotosStreamResponse) Descriptor() ([]byte, []int) {
+	return file_cat_photos_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *GetPhotosStreamResponse) GetCatId() uint64 {
+	if x != nil {
+		return x.CatId
+	}
+	return 0
+}
+
+func (x *GetPhotosStreamResponse) GetPhotoId() uint64 {
+	if x != nil {
+		return x.PhotoId
+	}
+	return 0
+}
+
+func (x *GetPhotosStreamResponse) GetPhotoData() []byte {
+	if x != nil {
+		return x.PhotoData
+	}
+	return nil
+}
+
+func (x *GetPhotosStreamResponse) GetSuccess() bool {
+	if x != nil {
+		return x.Success
+	}
+	return false
+}
+
+func (x *GetPhotosStreamResponse) GetErrorMessage() string {
+	if x != nil {
+		return x.ErrorMessage
+	}
+	return ""
+}
+
+func (x *GetPhotosStreamResponse) GetContentType() string {
+	if x != nil {
+		return x.ContentType
+	}
+	return ""
+}
+
+func (x *GetPhotosStreamResponse) GetSizeBytes() uint64 {
+	if x != nil {
+		return x.SizeBytes
+	}
+	return 0
+}
+
+func (x *GetPhotosStreamResponse) GetFormat() string {
+	if x != nil {
+		return x.Format
+	}
+	return ""
+}
+
+type BatchGetPhotosRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	PhotoRequests    []*PhotoRequest  `protobuf:"bytes,1,rep,name=photo_requests,json=photoRequests,proto3" json:"photo_requests,omitempty"`
+	Width            uint32           `protobuf:"varint,2,opt,name=width,proto3" json:"width,omitempty"`
+	ScalingAlgorithm ScalingAlgorithm `protobuf:"varint,3,opt,name=scaling_algorithm,json=scalingAlgorithm,proto3,enum=catphotos.ScalingAlgorithm" json:"scaling_algorithm,omitempty"`
+}
+
+func (x *BatchGetPhotosRequest) Reset() {
+	*x = BatchGetPhotosRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_cat_photos_proto_msgTypes[15]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *BatchGetPhotosRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BatchGetPhotosRequest) ProtoMessage() {}
+
+func (x *BatchGetPhotosRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_cat_photos_proto_msgTypes[15]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BatchGetPhotosRequest.ProtoReflect.Descriptor instead.
+func (*BatchGetPhotosRequest) Descriptor() ([]byte, []int) {
+	return file_cat_photos_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *BatchGetPhotosRequest) GetPhotoRequests() []*PhotoRequest {
+	if x != nil {
+		return x.PhotoRequests
+	}
+	return nil
+}
+
+func (x *BatchGetPhotosRequest) GetWidth() uint32 {
+	if x != nil {
+		return x.Width
+	}
+	return 0
+}
+
+func (x *BatchGetPhotosRequest) GetScalingAlgorithm() ScalingAlgorithm {
+	if x != nil {
+		return x.ScalingAlgorithm
+	}
+	return ScalingAlgorithm_NONE
+}
+
+type BatchGetPhotosResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// In the order of the requested photos
+	Photos []*GetPhotosStreamResponse `protobuf:"bytes,1,rep,name=photos,proto3" json:"photos,omitempty"`
+}
+
+func (x *BatchGetPhotosResponse) Reset() {
+	*x = BatchGetPhotosResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_cat_photos_proto_msgTypes[16]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *BatchGetPhotosResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BatchGetPhotosResponse) ProtoMessage() {}
+
+func (x *BatchGetPhotosResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_cat_photos_proto_msgTypes[16]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BatchGetPhotosResponse.ProtoReflect.Descriptor instead.
+func (*BatchGetPhotosResponse) Descriptor() ([]byte, []int) {
+	return file_cat_photos_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *BatchGetPhotosResponse) GetPhotos() []*GetPhotosStreamResponse {
+	if x != nil {
+		return x.Photos
+	}
+	return nil
+}
+
+type DeletePhotosRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	PhotoRequests []*PhotoRequest `protobuf:"bytes,1,rep,name=photo_requests,json=photoRequests,proto3" json:"photo_requests,omitempty"`
+}
+
+func (x *DeletePhotosRequest) Reset() {
+	*x = DeletePhotosRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_cat_photos_proto_msgTypes[17]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *DeletePhotosRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeletePhotosRequest) ProtoMessage() {}
+
+func (x *DeletePhotosRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_cat_photos_proto_msgTypes[17]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeletePhotosRequest.ProtoReflect.Descriptor instead.
+func (*DeletePhotosRequest) Descriptor() ([]byte, []int) {
+	return file_cat_photos_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *DeletePhotosRequest) GetPhotoRequests() []*PhotoRequest {
+	if x != nil {
+		return x.PhotoRequests
+	}
+	return nil
+}
+
+type DeletePhotosResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// For each photo request, whether the photo existed and was deleted
+	Deleted []bool `protobuf:"varint,1,rep,packed,name=deleted,proto3" json:"deleted,omitempty"`
+}
+
+func (x *DeletePhotosResponse) Reset() {
+	*x = DeletePhotosResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_cat_photos_proto_msgTypes[18]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *DeletePhotosResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeletePhotosResponse) ProtoMessage() {}
+
+func (x *DeletePhotosResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_cat_photos_proto_msgTypes[18]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeletePhotosResponse.ProtoReflect.Descriptor instead.
+func (*DeletePhotosResponse) Descriptor() ([]byte, []int) {
+	return file_cat_photos_proto_rawDescGZIP(), []int{18}
+}
+
+func (x *DeletePhotosResponse) GetDeleted() []bool {
+	if x != nil {
+		return x.Deleted
+	}
+	return nil
+}
+
+type DeleteCatRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	CatId uint64 `protobuf:"varint,1,opt,name=cat_id,json=catId,proto3" json:"cat_id,omitempty"`
+}
+
+func (x *DeleteCatRequest) Reset() {
+	*x = DeleteCatRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_cat_photos_proto_msgTypes[19]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *DeleteCatRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteCatRequest) ProtoMessage() {}
+
+func (x *DeleteCatRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_cat_photos_proto_msgTypes[19]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteCatRequest.ProtoReflect.Descriptor instead.
+func (*DeleteCatRequest) Descriptor() ([]byte, []int) {
+	return file_cat_photos_proto_rawDescGZIP(), []int{19}
+}
+
+func (x *DeleteCatRequest) GetCatId() uint64 {
+	if x != nil {
+		return x.CatId
+	}
+	return 0
+}
+
+type DeleteCatResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	DeletedCount uint64 `protobuf:"varint,1,opt,name=deleted_count,json=deletedCount,proto3" json:"deleted_count,omitempty"`
+}
+
+func (x *DeleteCatResponse) Reset() {
+	*x = DeleteCatResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_cat_photos_proto_msgTypes[20]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *DeleteCatResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteCatResponse) ProtoMessage() {}
+
+func (x *DeleteCatResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_cat_photos_proto_msgTypes[20]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteCatResponse.ProtoReflect.Descriptor instead.
+func (*DeleteCatResponse) Descriptor() ([]byte, []int) {
+	return file_cat_photos_proto_rawDescGZIP(), []int{20}
+}
+
+func (x *DeleteCatResponse) GetDeletedCount() uint64 {
+	if x != nil {
+		return x.DeletedCount
+	}
+	return 0
+}
+
+var File_cat_photos_proto protoreflect.FileDescriptor
+
+var file_cat_photos_proto_rawDesc = []byte{
 	0x0a, 0x10, 0x63, 0x61, 0x74, 0x5f, 0x70, 0x68, 0x6f, 0x74, 0x6f, 0x73, 0x2e, 0x70, 0x72, 0x6f,
-	0x74, 0x6f, 0x12, 0x09, 0x63, 0x61, 0x74, 0x70, 0x68, 0x6f, 0x74, 0x6f, 0x73, 0x22, 0x11, 0x0a,
+	0x74, 0x6f, 0x12, 0x09, 0x63, 0x61, 0x74, 0x70, 0x68, 0x6f, 0x74, 0x6f, 0x73, 0x22, 0x4d, 0x0a,
 	0x0f, 0x4c, 0x69, 0x73, 0x74, 0x43, 0x61, 0x74, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74,
-	0x22, 0x2b, 0x0a, 0x10, 0x4c, 0x69, 0x73, 0x74, 0x43, 0x61, 0x74, 0x73, 0x52, 0x65, 0x73, 0x70,
-	0x6f, 0x6e, 0x73, 0x65, 0x12, 0x17, 0x0a, 0x07, 0x63, 0x61, 0x74, 0x5f, 0x69, 0x64, 0x73, 0x18,
-	0x01, 0x20, 0x03, 0x28, 0x04, 0x52, 0x06, 0x63, 0x61, 0x74, 0x49, 0x64, 0x73, 0x22, 0x2a, 0x0a,
-	0x11, 0x4c, 0x69, 0x73, 0x74, 0x50, 0x68, 0x6f, 0x74, 0x6f, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65,
-	0x73, 0x74, 0x12, 0x15, 0x0a, 0x06, 0x63, 0x61, 0x74, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01,
-	0x28, 0x04, 0x52, 0x05, 0x63, 0x61, 0x74, 0x49, 0x64, 0x22, 0x31, 0x0a, 0x12, 0x4c, 0x69, 0x73,
+	0x12, 0x1b, 0x0a, 0x09, 0x70, 0x61, 0x67, 0x65, 0x5f, 0x73, 0x69, 0x7a, 0x65, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x05, 0x52, 0x08, 0x70, 0x61, 0x67, 0x65, 0x53, 0x69, 0x7a, 0x65, 0x12, 0x1d, 0x0a,
+	0x0a, 0x70, 0x61, 0x67, 0x65, 0x5f, 0x74, 0x6f, 0x6b, 0x65, 0x6e, 0x18, 0x02, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x09, 0x70, 0x61, 0x67, 0x65, 0x54, 0x6f, 0x6b, 0x65, 0x6e, 0x22, 0x53, 0x0a, 0x10,
+	0x4c, 0x69, 0x73, 0x74, 0x43, 0x61, 0x74, 0x73, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65,
+	0x12, 0x17, 0x0a, 0x07, 0x63, 0x61, 0x74, 0x5f, 0x69, 0x64, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28,
+	0x04, 0x52, 0x06, 0x63, 0x61, 0x74, 0x49, 0x64, 0x73, 0x12, 0x26, 0x0a, 0x0f, 0x6e, 0x65, 0x78,
+	0x74, 0x5f, 0x70, 0x61, 0x67, 0x65, 0x5f, 0x74, 0x6f, 0x6b, 0x65, 0x6e, 0x18, 0x02, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x0d, 0x6e, 0x65, 0x78, 0x74, 0x50, 0x61, 0x67, 0x65, 0x54, 0x6f, 0x6b, 0x65,
+	0x6e, 0x22, 0x66, 0x0a, 0x11, 0x4c, 0x69, 0x73, 0x74, 0x50, 0x68, 0x6f, 0x74, 0x6f, 0x73, 0x52,
+	0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x15, 0x0a, 0x06, 0x63, 0x61, 0x74, 0x5f, 0x69, 0x64,
+	0x18, 0x01, 0x20, 0x01, 0x28, 0x04, 0x52, 0x05, 0x63, 0x61, 0x74, 0x49, 0x64, 0x12, 0x1b, 0x0a,
+	0x09, 0x70, 0x61, 0x67, 0x65, 0x5f, 0x73, 0x69, 0x7a, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x05,
+	0x52, 0x08, 0x70, 0x61, 0x67, 0x65, 0x53, 0x69, 0x7a, 0x65, 0x12, 0x1d, 0x0a, 0x0a, 0x70, 0x61,
+	0x67, 0x65, 0x5f, 0x74, 0x6f, 0x6b, 0x65, 0x6e, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09,
+	0x70, 0x61, 0x67, 0x65, 0x54, 0x6f, 0x6b, 0x65, 0x6e, 0x22, 0x59, 0x0a, 0x12, 0x4c, 0x69, 0x73,
 	0x74, 0x50, 0x68, 0x6f, 0x74, 0x6f, 0x73, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12,
 	0x1b, 0x0a, 0x09, 0x70, 0x68, 0x6f, 0x74, 0x6f, 0x5f, 0x69, 0x64, 0x73, 0x18, 0x01, 0x20, 0x03,
-	0x28, 0x04, 0x52, 0x08, 0x70, 0x68, 0x6f, 0x74, 0x6f, 0x49, 0x64, 0x73, 0x22, 0xa3, 0x01, 0x0a,
-	0x0f, 0x47, 0x65, 0x74, 0x50, 0x68, 0x6f, 0x74, 0x6f, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74,
-	0x12, 0x15, 0x0a, 0x06, 0x63, 0x61, 0x74, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x04,
-	0x52, 0x05, 0x63, 0x61, 0x74, 0x49, 0x64, 0x12, 0x19, 0x0a, 0x08, 0x70, 0x68, 0x6f, 0x74, 0x6f,
-	0x5f, 0x69, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x04, 0x52, 0x07, 0x70, 0x68, 0x6f, 0x74, 0x6f,
-	0x49, 0x64, 0x12, 0x14, 0x0a, 0x05, 0x77, 0x69, 0x64, 0x74, 0x68, 0x18, 0x03, 0x20, 0x01, 0x28,
-	0x0d, 0x52, 0x05, 0x77, 0x69, 0x64, 0x74, 0x68, 0x12, 0x48, 0x0a, 0x11, 0x73, 0x63, 0x61, 0x6c,
-	0x69, 0x6e, 0x67, 0x5f, 0x61, 0x6c, 0x67, 0x6f, 0x72, 0x69, 0x74, 0x68, 0x6d, 0x18, 0x04, 0x20,
-	0x01, 0x28, 0x0e, 0x32, 0x1b, 0x2e, 0x63, 0x61, 0x74, 0x70, 0x68, 0x6f, 0x74, 0x6f, 0x73, 0x2e,
-	0x53, 0x63, 0x61, 0x6c, 0x69, 0x6e, 0x67, 0x41, 0x6c, 0x67, 0x6f, 0x72, 0x69, 0x74, 0x68, 0x6d,
-	0x52, 0x10, 0x73, 0x63, 0x61, 0x6c, 0x69, 0x6e, 0x67, 0x41, 0x6c, 0x67, 0x6f, 0x72, 0x69, 0x74,
-	0x68, 0x6d, 0x22, 0x31, 0x0a, 0x10, 0x47, 0x65, 0x74, 0x50, 0x68, 0x6f, 0x74, 0x6f, 0x52, 0x65,
-	0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x1d, 0x0a, 0x0a, 0x70, 0x68, 0x6f, 0x74, 0x6f, 0x5f,
-	0x64, 0x61, 0x74, 0x61, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x09, 0x70, 0x68, 0x6f, 0x74,
-	0x6f, 0x44, 0x61, 0x74, 0x61, 0x22, 0x40, 0x0a, 0x0c, 0x50, 0x68, 0x6f, 0x74, 0x6f, 0x52, 0x65,
-	0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x15, 0x0a, 0x06, 0x63, 0x61, 0x74, 0x5f, 0x69, 0x64, 0x18,
-	0x01, 0x20, 0x01, 0x28, 0x04, 0x52, 0x05, 0x63, 0x61, 0x74, 0x49, 0x64, 0x12, 0x19, 0x0a, 0x08,
-	0x70, 0x68, 0x6f, 0x74, 0x6f, 0x5f, 0x69, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x04, 0x52, 0x07,
-	0x70, 0x68, 0x6f, 0x74, 0x6f, 0x49, 0x64, 0x22, 0xb8, 0x01, 0x0a, 0x16, 0x47, 0x65, 0x74, 0x50,
-	0x68, 0x6f, 0x74, 0x6f, 0x73, 0x53, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x52, 0x65, 0x71, 0x75, 0x65,
-	0x73, 0x74, 0x12, 0x3e, 0x0a, 0x0e, 0x70, 0x68, 0x6f, 0x74, 0x6f, 0x5f, 0x72, 0x65, 0x71, 0x75,
-	0x65, 0x73, 0x74, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x17, 0x2e, 0x63, 0x61, 0x74,
-	0x70, 0x68, 0x6f, 0x74, 0x6f, 0x73, 0x2e, 0x50, 0x68, 0x6f, 0x74, 0x6f, 0x52, 0x65, 0x71, 0x75,
-	0x65, 0x73, 0x74, 0x52, 0x0d, 0x70, 0x68, 0x6f, 0x74, 0x6f, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73,
-	0x74, 0x73, 0x12, 0x14, 0x0a, 0x05, 0x77, 0x69, 0x64, 0x74, 0x68, 0x18, 0x02, 0x20, 0x01, 0x28,
-	0x0d, 0x52, 0x05, 0x77, 0x69, 0x64, 0x74, 0x68, 0x12, 0x48, 0x0a, 0x11, 0x73, 0x63, 0x61, 0x6c,
-	0x69, 0x6e, 0x67, 0x5f, 0x61, 0x6c, 0x67, 0x6f, 0x72, 0x69, 0x74, 0x68, 0x6d, 0x18, 0x03, 0x20,
-	0x01, 0x28, 0x0e, 0x32, 0x1b, 0x2e, 0x63, 0x61, 0x74, 0x70, 0x68, 0x6f, 0x74, 0x6f, 0x73, 0x2e,
-	0x53, 0x63, 0x61, 0x6c, 0x69, 0x6e, 0x67, 0x41, 0x6c, 0x67, 0x6f, 0x72, 0x69, 0x74, 0x68, 0x6d,
-	0x52, 0x10, 0x73, 0x63, 0x61, 0x6c, 0x69, 0x6e, 0x67, 0x41, 0x6c, 0x67, 0x6f, 0x72, 0x69, 0x74,
-	0x68, 0x6d, 0x22, 0xa9, 0x01, 0x0a, 0x17, 0x47, 0x65, 0x74, 0x50, 0x68, 0x6f, 0x74, 0x6f, 0x73,
-	0x53, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x15,
-	0x0a, 0x06, 0x63, 0x61, 0x74, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x04, 0x52, 0x05,
-	0x63, 0x61, 0x74, 0x49, 0x64, 0x12, 0x19, 0x0a, 0x08, 0x70, 0x68, 0x6f, 0x74, 0x6f, 0x5f, 0x69,
-	0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x04, 0x52, 0x07, 0x70, 0x68, 0x6f, 0x74, 0x6f, 0x49, 0x64,
-	0x12, 0x1d, 0x0a, 0x0a, 0x70, 0x68, 0x6f, 0x74, 0x6f, 0x5f, 0x64, 0x61, 0x74, 0x61, 0x18, 0x03,
-	0x20, 0x01, 0x28, 0x0c, 0x52, 0x09, 0x70, 0x68, 0x6f, 0x74, 0x6f, 0x44, 0x61, 0x74, 0x61, 0x12,
-	0x18, 0x0a, 0x07, 0x73, 0x75, 0x63, 0x63, 0x65, 0x73, 0x73, 0x18, 0x04, 0x20, 0x01, 0x28, 0x08,
-	0x52, 0x07, 0x73, 0x75, 0x63, 0x63, 0x65, 0x73, 0x73, 0x12, 0x23, 0x0a, 0x0d, 0x65, 0x72, 0x72,
-	0x6f, 0x72, 0x5f, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x18, 0x05, 0x20, 0x01, 0x28, 0x09,
-	0x52, 0x0c, 0x65, 0x72, 0x72, 0x6f, 0x72, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x2a, 0x66,
+	0x28, 0x04, 0x52, 0x08, 0x70, 0x68, 0x6f, 0x74, 0x6f, 0x49, 0x64, 0x73, 0x12, 0x26, 0x0a, 0x0f,
+	0x6e, 0x65, 0x78, 0x74, 0x5f, 0x70, 0x61, 0x67, 0x65, 0x5f, 0x74, 0x6f, 0x6b, 0x65, 0x6e, 0x18,
+	0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0d, 0x6e, 0x65, 0x78, 0x74, 0x50, 0x61, 0x67, 0x65, 0x54,
+	0x6f, 0x6b, 0x65, 0x6e, 0x22, 0xd5, 0x03, 0x0a, 0x0f, 0x47, 0x65, 0x74, 0x50, 0x68, 0x6f, 0x74,
+	0x6f, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x15, 0x0a, 0x06, 0x63, 0x61, 0x74, 0x5f,
+	0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x04, 0x52, 0x05, 0x63, 0x61, 0x74, 0x49, 0x64, 0x12,
+	0x19, 0x0a, 0x08, 0x70, 0x68, 0x6f, 0x74, 0x6f, 0x5f, 0x69, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28,
+	0x04, 0x52, 0x07, 0x70, 0x68, 0x6f, 0x74, 0x6f, 0x49, 0x64, 0x12, 0x14, 0x0a, 0x05, 0x77, 0x69,
+	0x64, 0x74, 0x68, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x05, 0x77, 0x69, 0x64, 0x74, 0x68,
+	0x12, 0x48, 0x0a, 0x11, 0x73, 0x63, 0x61, 0x6c, 0x69, 0x6e, 0x67, 0x5f, 0x61, 0x6c, 0x67, 0x6f,
+	0x72, 0x69, 0x74, 0x68, 0x6d, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x1b, 0x2e, 0x63, 0x61,
+	0x74, 0x70, 0x68, 0x6f, 0x74, 0x6f, 0x73, 0x2e, 0x53, 0x63, 0x61, 0x6c, 0x69, 0x6e, 0x67, 0x41,
+	0x6c, 0x67, 0x6f, 0x72, 0x69, 0x74, 0x68, 0x6d, 0x52, 0x10, 0x73, 0x63, 0x61, 0x6c, 0x69, 0x6e,
+	0x67, 0x41, 0x6c, 0x67, 0x6f, 0x72, 0x69, 0x74, 0x68, 0x6d, 0x12, 0x22, 0x0a, 0x0d, 0x69, 0x66,
+	0x5f, 0x6e, 0x6f, 0x6e, 0x65, 0x5f, 0x6d, 0x61, 0x74, 0x63, 0x68, 0x18, 0x05, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x0b, 0x69, 0x66, 0x4e, 0x6f, 0x6e, 0x65, 0x4d, 0x61, 0x74, 0x63, 0x68, 0x12, 0x3c,
+	0x0a, 0x0d, 0x6f, 0x75, 0x74, 0x70, 0x75, 0x74, 0x5f, 0x66, 0x6f, 0x72, 0x6d, 0x61, 0x74, 0x18,
+	0x06, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x17, 0x2e, 0x63, 0x61, 0x74, 0x70, 0x68, 0x6f, 0x74, 0x6f,
+	0x73, 0x2e, 0x4f, 0x75, 0x74, 0x70, 0x75, 0x74, 0x46, 0x6f, 0x72, 0x6d, 0x61, 0x74, 0x52, 0x0c,
+	0x6f, 0x75, 0x74, 0x70, 0x75, 0x74, 0x46, 0x6f, 0x72, 0x6d, 0x61, 0x74, 0x12, 0x16, 0x0a, 0x06,
+	0x68, 0x65, 0x69, 0x67, 0x68, 0x74, 0x18, 0x07, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x06, 0x68, 0x65,
+	0x69, 0x67, 0x68, 0x74, 0x12, 0x24, 0x0a, 0x03, 0x66, 0x69, 0x74, 0x18, 0x08, 0x20, 0x01, 0x28,
+	0x0e, 0x32, 0x12, 0x2e, 0x63, 0x61, 0x74, 0x70, 0x68, 0x6f, 0x74, 0x6f, 0x73, 0x2e, 0x46, 0x69,
+	0x74, 0x4d, 0x6f, 0x64, 0x65, 0x52, 0x03, 0x66, 0x69, 0x74, 0x12, 0x18, 0x0a, 0x07, 0x71, 0x75,
+	0x61, 0x6c, 0x69, 0x74, 0x79, 0x18, 0x09, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x07, 0x71, 0x75, 0x61,
+	0x6c, 0x69, 0x74, 0x79, 0x12, 0x36, 0x0a, 0x0b, 0x73, 0x69, 0x7a, 0x65, 0x5f, 0x70, 0x72, 0x65,
+	0x73, 0x65, 0x74, 0x18, 0x0a, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x15, 0x2e, 0x63, 0x61, 0x74, 0x70,
+	0x68, 0x6f, 0x74, 0x6f, 0x73, 0x2e, 0x53, 0x69, 0x7a, 0x65, 0x50, 0x72, 0x65, 0x73, 0x65, 0x74,
+	0x52, 0x0a, 0x73, 0x69, 0x7a, 0x65, 0x50, 0x72, 0x65, 0x73, 0x65, 0x74, 0x12, 0x3e, 0x0a, 0x0e,
+	0x61, 0x63, 0x63, 0x65, 0x70, 0x74, 0x5f, 0x66, 0x6f, 0x72, 0x6d, 0x61, 0x74, 0x73, 0x18, 0x0b,
+	0x20, 0x03, 0x28, 0x0e, 0x32, 0x17, 0x2e, 0x63, 0x61, 0x74, 0x70, 0x68, 0x6f, 0x74, 0x6f, 0x73,
+	0x2e, 0x4f, 0x75, 0x74, 0x70, 0x75, 0x74, 0x46, 0x6f, 0x72, 0x6d, 0x61, 0x74, 0x52, 0x0d, 0x61,
+	0x63, 0x63, 0x65, 0x70, 0x74, 0x46, 0x6f, 0x72, 0x6d, 0x61, 0x74, 0x73, 0x22, 0xcb, 0x01, 0x0a,
+	0x10, 0x47, 0x65, 0x74, 0x50, 0x68, 0x6f, 0x74, 0x6f, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73,
+	0x65, 0x12, 0x1d, 0x0a, 0x0a, 0x70, 0x68, 0x6f, 0x74, 0x6f, 0x5f, 0x64, 0x61, 0x74, 0x61, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x09, 0x70, 0x68, 0x6f, 0x74, 0x6f, 0x44, 0x61, 0x74, 0x61,
+	0x12, 0x21, 0x0a, 0x0c, 0x63, 0x6f, 0x6e, 0x74, 0x65, 0x6e, 0x74, 0x5f, 0x74, 0x79, 0x70, 0x65,
+	0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0b, 0x63, 0x6f, 0x6e, 0x74, 0x65, 0x6e, 0x74, 0x54,
+	0x79, 0x70, 0x65, 0x12, 0x21, 0x0a, 0x0c, 0x6e, 0x6f, 0x74, 0x5f, 0x6d, 0x6f, 0x64, 0x69, 0x66,
+	0x69, 0x65, 0x64, 0x18, 0x03, 0x20, 0x01, 0x28, 0x08, 0x52, 0x0b, 0x6e, 0x6f, 0x74, 0x4d, 0x6f,
+	0x64, 0x69, 0x66, 0x69, 0x65, 0x64, 0x12, 0x21, 0x0a, 0x0c, 0x63, 0x6f, 0x6e, 0x74, 0x65, 0x6e,
+	0x74, 0x5f, 0x68, 0x61, 0x73, 0x68, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0b, 0x63, 0x6f,
+	0x6e, 0x74, 0x65, 0x6e, 0x74, 0x48, 0x61, 0x73, 0x68, 0x12, 0x2f, 0x0a, 0x06, 0x66, 0x6f, 0x72,
+	0x6d, 0x61, 0x74, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x17, 0x2e, 0x63, 0x61, 0x74, 0x70,
+	0x68, 0x6f, 0x74, 0x6f, 0x73, 0x2e, 0x4f, 0x75, 0x74, 0x70, 0x75, 0x74, 0x46, 0x6f, 0x72, 0x6d,
+	0x61, 0x74, 0x52, 0x06, 0x66, 0x6f, 0x72, 0x6d, 0x61, 0x74, 0x22, 0x69, 0x0a, 0x16, 0x47, 0x65,
+	0x74, 0x50, 0x68, 0x6f, 0x74, 0x6f, 0x43, 0x68, 0x75, 0x6e, 0x6b, 0x65, 0x64, 0x52, 0x65, 0x71,
+	0x75, 0x65, 0x73, 0x74, 0x12, 0x30, 0x0a, 0x05, 0x70, 0x68, 0x6f, 0x74, 0x6f, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x63, 0x61, 0x74, 0x70, 0x68, 0x6f, 0x74, 0x6f, 0x73, 0x2e,
+	0x47, 0x65, 0x74, 0x50, 0x68, 0x6f, 0x74, 0x6f, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x52,
+	0x05, 0x70, 0x68, 0x6f, 0x74, 0x6f, 0x12, 0x1d, 0x0a, 0x0a, 0x63, 0x68, 0x75, 0x6e, 0x6b, 0x5f,
+	0x73, 0x69, 0x7a, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x09, 0x63, 0x68, 0x75, 0x6e,
+	0x6b, 0x53, 0x69, 0x7a, 0x65, 0x22, 0xa8, 0x01, 0x0a, 0x0a, 0x50, 0x68, 0x6f, 0x74, 0x6f, 0x43,
+	0x68, 0x75, 0x6e, 0x6b, 0x12, 0x12, 0x0a, 0x04, 0x64, 0x61, 0x74, 0x61, 0x18, 0x01, 0x20, 0x01,
+	0x28, 0x0c, 0x52, 0x04, 0x64, 0x61, 0x74, 0x61, 0x12, 0x1d, 0x0a, 0x0a, 0x74, 0x6f, 0x74, 0x61,
+	0x6c, 0x5f, 0x73, 0x69, 0x7a, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x04, 0x52, 0x09, 0x74, 0x6f,
+	0x74, 0x61, 0x6c, 0x53, 0x69, 0x7a, 0x65, 0x12, 0x21, 0x0a, 0x0c, 0x63, 0x6f, 0x6e, 0x74, 0x65,
+	0x6e, 0x74, 0x5f, 0x74, 0x79, 0x70, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0b, 0x63,
+	0x6f, 0x6e, 0x74, 0x65, 0x6e, 0x74, 0x54, 0x79, 0x70, 0x65, 0x12, 0x21, 0x0a, 0x0c, 0x6e, 0x6f,
+	0x74, 0x5f, 0x6d, 0x6f, 0x64, 0x69, 0x66, 0x69, 0x65, 0x64, 0x18, 0x04, 0x20, 0x01, 0x28, 0x08,
+	0x52, 0x0b, 0x6e, 0x6f, 0x74, 0x4d, 0x6f, 0x64, 0x69, 0x66, 0x69, 0x65, 0x64, 0x12, 0x21, 0x0a,
+	0x0c, 0x63, 0x6f, 0x6e, 0x74, 0x65, 0x6e, 0x74, 0x5f, 0x68, 0x61, 0x73, 0x68, 0x18, 0x05, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x0b, 0x63, 0x6f, 0x6e, 0x74, 0x65, 0x6e, 0x74, 0x48, 0x61, 0x73, 0x68,
+	0x22, 0x4b, 0x0a, 0x17, 0x47, 0x65, 0x74, 0x50, 0x68, 0x6f, 0x74, 0x6f, 0x4d, 0x65, 0x74, 0x61,
+	0x64, 0x61, 0x74, 0x61, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x15, 0x0a, 0x06, 0x63,
+	0x61, 0x74, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x04, 0x52, 0x05, 0x63, 0x61, 0x74,
+	0x49, 0x64, 0x12, 0x19, 0x0a, 0x08, 0x70, 0x68, 0x6f, 0x74, 0x6f, 0x5f, 0x69, 0x64, 0x18, 0x02,
+	0x20, 0x01, 0x28, 0x04, 0x52, 0x07, 0x70, 0x68, 0x6f, 0x74, 0x6f, 0x49, 0x64, 0x22, 0x74, 0x0a,
+	0x18, 0x47, 0x65, 0x74, 0x50, 0x68, 0x6f, 0x74, 0x6f, 0x4d, 0x65, 0x74, 0x61, 0x64, 0x61, 0x74,
+	0x61, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x14, 0x0a, 0x05, 0x77, 0x69, 0x64,
+	0x74, 0x68, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x05, 0x77, 0x69, 0x64, 0x74, 0x68, 0x12,
+	0x16, 0x0a, 0x06, 0x68, 0x65, 0x69, 0x67, 0x68, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0d, 0x52,
+	0x06, 0x68, 0x65, 0x69, 0x67, 0x68, 0x74, 0x12, 0x12, 0x0a, 0x04, 0x73, 0x69, 0x7a, 0x65, 0x18,
+	0x03, 0x20, 0x01, 0x28, 0x04, 0x52, 0x04, 0x73, 0x69, 0x7a, 0x65, 0x12, 0x16, 0x0a, 0x06, 0x66,
+	0x6f, 0x72, 0x6d, 0x61, 0x74, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x66, 0x6f, 0x72,
+	0x6d, 0x61, 0x74, 0x22, 0x49, 0x0a, 0x15, 0x47, 0x65, 0x74, 0x52, 0x61, 0x6e, 0x64, 0x6f, 0x6d,
+	0x50, 0x68, 0x6f, 0x74, 0x6f, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x30, 0x0a, 0x05,
+	0x70, 0x68, 0x6f, 0x74, 0x6f, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x63, 0x61,
+	0x74, 0x70, 0x68, 0x6f, 0x74, 0x6f, 0x73, 0x2e, 0x47, 0x65, 0x74, 0x50, 0x68, 0x6f, 0x74, 0x6f,
+	0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x52, 0x05, 0x70, 0x68, 0x6f, 0x74, 0x6f, 0x22, 0x7d,
+	0x0a, 0x16, 0x47, 0x65, 0x74, 0x52, 0x61, 0x6e, 0x64, 0x6f, 0x6d, 0x50, 0x68, 0x6f, 0x74, 0x6f,
+	0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x15, 0x0a, 0x06, 0x63, 0x61, 0x74, 0x5f,
+	0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x04, 0x52, 0x05, 0x63, 0x61, 0x74, 0x49, 0x64, 0x12,
+	0x19, 0x0a, 0x08, 0x70, 0x68, 0x6f, 0x74, 0x6f, 0x5f, 0x69, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28,
+	0x04, 0x52, 0x07, 0x70, 0x68, 0x6f, 0x74, 0x6f, 0x49, 0x64, 0x12, 0x31, 0x0a, 0x05, 0x70, 0x68,
+	0x6f, 0x74, 0x6f, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1b, 0x2e, 0x63, 0x61, 0x74, 0x70,
+	0x68, 0x6f, 0x74, 0x6f, 0x73, 0x2e, 0x47, 0x65, 0x74, 0x50, 0x68, 0x6f, 0x74, 0x6f, 0x52, 0x65,
+	0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x52, 0x05, 0x70, 0x68, 0x6f, 0x74, 0x6f, 0x22, 0x40, 0x0a,
+	0x0c, 0x50, 0x68, 0x6f, 0x74, 0x6f, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x15, 0x0a,
+	0x06, 0x63, 0x61, 0x74, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x04, 0x52, 0x05, 0x63,
+	0x61, 0x74, 0x49, 0x64, 0x12, 0x19, 0x0a, 0x08, 0x70, 0x68, 0x6f, 0x74, 0x6f, 0x5f, 0x69, 0x64,
+	0x18, 0x02, 0x20, 0x01, 0x28, 0x04, 0x52, 0x07, 0x70, 0x68, 0x6f, 0x74, 0x6f, 0x49, 0x64, 0x22,
+	0xb8, 0x01, 0x0a, 0x16, 0x47, 0x65, 0x74, 0x50, 0x68, 0x6f, 0x74, 0x6f, 0x73, 0x53, 0x74, 0x72,
+	0x65, 0x61, 0x6d, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x3e, 0x0a, 0x0e, 0x70, 0x68,
+	0x6f, 0x74, 0x6f, 0x5f, 0x72, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x73, 0x18, 0x01, 0x20, 0x03,
+	0x28, 0x0b, 0x32, 0x17, 0x2e, 0x63, 0x61, 0x74, 0x70, 0x68, 0x6f, 0x74, 0x6f, 0x73, 0x2e, 0x50,
+	0x68, 0x6f, 0x74, 0x6f, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x52, 0x0d, 0x70, 0x68, 0x6f,
+	0x74, 0x6f, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x73, 0x12, 0x14, 0x0a, 0x05, 0x77, 0x69,
+	0x64, 0x74, 0x68, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x05, 0x77, 0x69, 0x64, 0x74, 0x68,
+	0x12, 0x48, 0x0a, 0x11, 0x73, 0x63, 0x61, 0x6c, 0x69, 0x6e, 0x67, 0x5f, 0x61, 0x6c, 0x67, 0x6f,
+	0x72, 0x69, 0x74, 0x68, 0x6d, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x1b, 0x2e, 0x63, 0x61,
+	0x74, 0x70, 0x68, 0x6f, 0x74, 0x6f, 0x73, 0x2e, 0x53, 0x63, 0x61, 0x6c, 0x69, 0x6e, 0x67, 0x41,
+	0x6c, 0x67, 0x6f, 0x72, 0x69, 0x74, 0x68, 0x6d, 0x52, 0x10, 0x73, 0x63, 0x61, 0x6c, 0x69, 0x6e,
+	0x67, 0x41, 0x6c, 0x67, 0x6f, 0x72, 0x69, 0x74, 0x68, 0x6d, 0x22, 0x83, 0x02, 0x0a, 0x17, 0x47,
+	0x65, 0x74, 0x50, 0x68, 0x6f, 0x74, 0x6f, 0x73, 0x53, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x52, 0x65,
+	0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x15, 0x0a, 0x06, 0x63, 0x61, 0x74, 0x5f, 0x69, 0x64,
+	0x18, 0x01, 0x20, 0x01, 0x28, 0x04, 0x52, 0x05, 0x63, 0x61, 0x74, 0x49, 0x64, 0x12, 0x19, 0x0a,
+	0x08, 0x70, 0x68, 0x6f, 0x74, 0x6f, 0x5f, 0x69, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x04, 0x52,
+	0x07, 0x70, 0x68, 0x6f, 0x74, 0x6f, 0x49, 0x64, 0x12, 0x1d, 0x0a, 0x0a, 0x70, 0x68, 0x6f, 0x74,
+	0x6f, 0x5f, 0x64, 0x61, 0x74, 0x61, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x09, 0x70, 0x68,
+	0x6f, 0x74, 0x6f, 0x44, 0x61, 0x74, 0x61, 0x12, 0x18, 0x0a, 0x07, 0x73, 0x75, 0x63, 0x63, 0x65,
+	0x73, 0x73, 0x18, 0x04, 0x20, 0x01, 0x28, 0x08, 0x52, 0x07, 0x73, 0x75, 0x63, 0x63, 0x65, 0x73,
+	0x73, 0x12, 0x23, 0x0a, 0x0d, 0x65, 0x72, 0x72, 0x6f, 0x72, 0x5f, 0x6d, 0x65, 0x73, 0x73, 0x61,
+	0x67, 0x65, 0x18, 0x05, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0c, 0x65, 0x72, 0x72, 0x6f, 0x72, 0x4d,
+	0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x12, 0x21, 0x0a, 0x0c, 0x63, 0x6f, 0x6e, 0x74, 0x65, 0x6e,
+	0x74, 0x5f, 0x74, 0x79, 0x70, 0x65, 0x18, 0x06, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0b, 0x63, 0x6f,
+	0x6e, 0x74, 0x65, 0x6e, 0x74, 0x54, 0x79, 0x70, 0x65, 0x12, 0x1d, 0x0a, 0x0a, 0x73, 0x69, 0x7a,
+	0x65, 0x5f, 0x62, 0x79, 0x74, 0x65, 0x73, 0x18, 0x07, 0x20, 0x01, 0x28, 0x04, 0x52, 0x09, 0x73,
+	0x69, 0x7a, 0x65, 0x42, 0x79, 0x74, 0x65, 0x73, 0x12, 0x16, 0x0a, 0x06, 0x66, 0x6f, 0x72, 0x6d,
+	0x61, 0x74, 0x18, 0x08, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x66, 0x6f, 0x72, 0x6d, 0x61, 0x74,
+	0x22, 0xb7, 0x01, 0x0a, 0x15, 0x42, 0x61, 0x74, 0x63, 0x68, 0x47, 0x65, 0x74, 0x50, 0x68, 0x6f,
+	0x74, 0x6f, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x3e, 0x0a, 0x0e, 0x70, 0x68,
+	0x6f, 0x74, 0x6f, 0x5f, 0x72, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x73, 0x18, 0x01, 0x20, 0x03,
+	0x28, 0x0b, 0x32, 0x17, 0x2e, 0x63, 0x61, 0x74, 0x70, 0x68, 0x6f, 0x74, 0x6f, 0x73, 0x2e, 0x50,
+	0x68, 0x6f, 0x74, 0x6f, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x52, 0x0d, 0x70, 0x68, 0x6f,
+	0x74, 0x6f, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x73, 0x12, 0x14, 0x0a, 0x05, 0x77, 0x69,
+	0x64, 0x74, 0x68, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x05, 0x77, 0x69, 0x64, 0x74, 0x68,
+	0x12, 0x48, 0x0a, 0x11, 0x73, 0x63, 0x61, 0x6c, 0x69, 0x6e, 0x67, 0x5f, 0x61, 0x6c, 0x67, 0x6f,
+	0x72, 0x69, 0x74, 0x68, 0x6d, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x1b, 0x2e, 0x63, 0x61,
+	0x74, 0x70, 0x68, 0x6f, 0x74, 0x6f, 0x73, 0x2e, 0x53, 0x63, 0x61, 0x6c, 0x69, 0x6e, 0x67, 0x41,
+	0x6c, 0x67, 0x6f, 0x72, 0x69, 0x74, 0x68, 0x6d, 0x52, 0x10, 0x73, 0x63, 0x61, 0x6c, 0x69, 0x6e,
+	0x67, 0x41, 0x6c, 0x67, 0x6f, 0x72, 0x69, 0x74, 0x68, 0x6d, 0x22, 0x54, 0x0a, 0x16, 0x42, 0x61,
+	0x74, 0x63, 0x68, 0x47, 0x65, 0x74, 0x50, 0x68, 0x6f, 0x74, 0x6f, 0x73, 0x52, 0x65, 0x73, 0x70,
+	0x6f, 0x6e, 0x73, 0x65, 0x12, 0x3a, 0x0a, 0x06, 0x70, 0x68, 0x6f, 0x74, 0x6f, 0x73, 0x18, 0x01,
+	0x20, 0x03, 0x28, 0x0b, 0x32, 0x22, 0x2e, 0x63, 0x61, 0x74, 0x70, 0x68, 0x6f, 0x74, 0x6f, 0x73,
+	0x2e, 0x47, 0x65, 0x74, 0x50, 0x68, 0x6f, 0x74, 0x6f, 0x73, 0x53, 0x74, 0x72, 0x65, 0x61, 0x6d,
+	0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x52, 0x06, 0x70, 0x68, 0x6f, 0x74, 0x6f, 0x73,
+	0x22, 0x55, 0x0a, 0x13, 0x44, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x50, 0x68, 0x6f, 0x74, 0x6f, 0x73,
+	0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x3e, 0x0a, 0x0e, 0x70, 0x68, 0x6f, 0x74, 0x6f,
+	0x5f, 0x72, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0b, 0x32,
+	0x17, 0x2e, 0x63, 0x61, 0x74, 0x70, 0x68, 0x6f, 0x74, 0x6f, 0x73, 0x2e, 0x50, 0x68, 0x6f, 0x74,
+	0x6f, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x52, 0x0d, 0x70, 0x68, 0x6f, 0x74, 0x6f, 0x52,
+	0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x73, 0x22, 0x30, 0x0a, 0x14, 0x44, 0x65, 0x6c, 0x65, 0x74,
+	0x65, 0x50, 0x68, 0x6f, 0x74, 0x6f, 0x73, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12,
+	0x18, 0x0a, 0x07, 0x64, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x64, 0x18, 0x01, 0x20, 0x03, 0x28, 0x08,
+	0x52, 0x07, 0x64, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x64, 0x22, 0x29, 0x0a, 0x10, 0x44, 0x65, 0x6c,
+	0x65, 0x74, 0x65, 0x43, 0x61, 0x74, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x15, 0x0a,
+	0x06, 0x63, 0x61, 0x74, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x04, 0x52, 0x05, 0x63,
+	0x61, 0x74, 0x49, 0x64, 0x22, 0x38, 0x0a, 0x11, 0x44, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x43, 0x61,
+	0x74, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x23, 0x0a, 0x0d, 0x64, 0x65, 0x6c,
+	0x65, 0x74, 0x65, 0x64, 0x5f, 0x63, 0x6f, 0x75, 0x6e, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x04,
+	0x52, 0x0c, 0x64, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x64, 0x43, 0x6f, 0x75, 0x6e, 0x74, 0x2a, 0x70,
 	0x0a, 0x10, 0x53, 0x63, 0x61, 0x6c, 0x69, 0x6e, 0x67, 0x41, 0x6c, 0x67, 0x6f, 0x72, 0x69, 0x74,
 	0x68, 0x6d, 0x12, 0x08, 0x0a, 0x04, 0x4e, 0x4f, 0x4e, 0x45, 0x10, 0x00, 0x12, 0x14, 0x0a, 0x10,
 	0x4e, 0x45, 0x41, 0x52, 0x45, 0x53, 0x54, 0x5f, 0x4e, 0x45, 0x49, 0x47, 0x48, 0x42, 0x4f, 0x52,
 	0x10, 0x01, 0x12, 0x0c, 0x0a, 0x08, 0x42, 0x49, 0x4c, 0x49, 0x4e, 0x45, 0x41, 0x52, 0x10, 0x02,
 	0x12, 0x0f, 0x0a, 0x0b, 0x43, 0x41, 0x54, 0x4d, 0x55, 0x4c, 0x4c, 0x5f, 0x52, 0x4f, 0x4d, 0x10,
 	0x03, 0x12, 0x13, 0x0a, 0x0f, 0x41, 0x50, 0x50, 0x52, 0x4f, 0x58, 0x5f, 0x42, 0x49, 0x4c, 0x49,
-	0x4e, 0x45, 0x41, 0x52, 0x10, 0x04, 0x32, 0xc3, 0x02, 0x0a, 0x10, 0x43, 0x61, 0x74, 0x50, 0x68,
-	0x6f, 0x74, 0x6f, 0x73, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x12, 0x43, 0x0a, 0x08, 0x4c,
-	0x69, 0x73, 0x74, 0x43, 0x61, 0x74, 0x73, 0x12, 0x1a, 0x2e, 0x63, 0x61, 0x74, 0x70, 0x68, 0x6f,
-	0x74, 0x6f, 0x73, 0x2e, 0x4c, 0x69, 0x73, 0x74, 0x43, 0x61, 0x74, 0x73, 0x52, 0x65, 0x71, 0x75,
-	0x65, 0x73, 0x74, 0x1a, 0x1b, 0x2e, 0x63, 0x61, 0x74, 0x70, 0x68, 0x6f, 0x74, 0x6f, 0x73, 0x2e,
-	0x4c, 0x69, 0x73, 0x74, 0x43, 0x61, 0x74, 0x73, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65,
-	0x12, 0x49, 0x0a, 0x0a, 0x4c, 0x69, 0x73, 0x74, 0x50, 0x68, 0x6f, 0x74, 0x6f, 0x73, 0x12, 0x1c,
-	0x2e, 0x63, 0x61, 0x74, 0x70, 0x68, 0x6f, 0x74, 0x6f, 0x73, 0x2e, 0x4c, 0x69, 0x73, 0x74, 0x50,
-	0x68, 0x6f, 0x74, 0x6f, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x1d, 0x2e, 0x63,
+	0x4e, 0x45, 0x41, 0x52, 0x10, 0x04, 0x12, 0x08, 0x0a, 0x04, 0x41, 0x55, 0x54, 0x4f, 0x10, 0x05,
+	0x2a, 0x29, 0x0a, 0x07, 0x46, 0x69, 0x74, 0x4d, 0x6f, 0x64, 0x65, 0x12, 0x07, 0x0a, 0x03, 0x46,
+	0x49, 0x54, 0x10, 0x00, 0x12, 0x08, 0x0a, 0x04, 0x46, 0x49, 0x4c, 0x4c, 0x10, 0x01, 0x12, 0x0b,
+	0x0a, 0x07, 0x53, 0x54, 0x52, 0x45, 0x54, 0x43, 0x48, 0x10, 0x02, 0x2a, 0x39, 0x0a, 0x0c, 0x4f,
+	0x75, 0x74, 0x70, 0x75, 0x74, 0x46, 0x6f, 0x72, 0x6d, 0x61, 0x74, 0x12, 0x0c, 0x0a, 0x08, 0x4f,
+	0x52, 0x49, 0x47, 0x49, 0x4e, 0x41, 0x4c, 0x10, 0x00, 0x12, 0x08, 0x0a, 0x04, 0x4a, 0x50, 0x45,
+	0x47, 0x10, 0x01, 0x12, 0x07, 0x0a, 0x03, 0x50, 0x4e, 0x47, 0x10, 0x02, 0x12, 0x08, 0x0a, 0x04,
+	0x57, 0x45, 0x42, 0x50, 0x10, 0x03, 0x2a, 0x3c, 0x0a, 0x0a, 0x53, 0x69, 0x7a, 0x65, 0x50, 0x72,
+	0x65, 0x73, 0x65, 0x74, 0x12, 0x0d, 0x0a, 0x09, 0x4e, 0x4f, 0x5f, 0x50, 0x52, 0x45, 0x53, 0x45,
+	0x54, 0x10, 0x00, 0x12, 0x09, 0x0a, 0x05, 0x54, 0x48, 0x55, 0x4d, 0x42, 0x10, 0x01, 0x12, 0x0a,
+	0x0a, 0x06, 0x4d, 0x45, 0x44, 0x49, 0x55, 0x4d, 0x10, 0x02, 0x12, 0x08, 0x0a, 0x04, 0x46, 0x55,
+	0x4c, 0x4c, 0x10, 0x03, 0x32, 0xb6, 0x06, 0x0a, 0x10, 0x43, 0x61, 0x74, 0x50, 0x68, 0x6f, 0x74,
+	0x6f, 0x73, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x12, 0x43, 0x0a, 0x08, 0x4c, 0x69, 0x73,
+	0x74, 0x43, 0x61, 0x74, 0x73, 0x12, 0x1a, 0x2e, 0x63, 0x61, 0x74, 0x70, 0x68, 0x6f, 0x74, 0x6f,
+	0x73, 0x2e, 0x4c, 0x69, 0x73, 0x74, 0x43, 0x61, 0x74, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73,
+	0x74, 0x1a, 0x1b, 0x2e, 0x63, 0x61, 0x74, 0x70, 0x68, 0x6f, 0x74, 0x6f, 0x73, 0x2e, 0x4c, 0x69,
+	0x73, 0x74, 0x43, 0x61, 0x74, 0x73, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x49,
+	0x0a, 0x0a, 0x4c, 0x69, 0x73, 0x74, 0x50, 0x68, 0x6f, 0x74, 0x6f, 0x73, 0x12, 0x1c, 0x2e, 0x63,
 	0x61, 0x74, 0x70, 0x68, 0x6f, 0x74, 0x6f, 0x73, 0x2e, 0x4c, 0x69, 0x73, 0x74, 0x50, 0x68, 0x6f,
-	0x74, 0x6f, 0x73, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x43, 0x0a, 0x08, 0x47,
-	0x65, 0x74, 0x50, 0x68, 0x6f, 0x74, 0x6f, 0x12, 0x1a, 0x2e, 0x63, 0x61, 0x74, 0x70, 0x68, 0x6f,
-	0x74, 0x6f, 0x73, 0x2e, 0x47, 0x65, 0x74, 0x50, 0x68, 0x6f, 0x74, 0x6f, 0x52, 0x65, 0x71, 0x75,
-	0x65, 0x73, 0x74, 0x1a, 0x1b, 0x2e, 0x63, 0x61, 0x74, 0x70, 0x68, 0x6f, 0x74, 0x6f, 0x73, 0x2e,
-	0x47, 0x65, 0x74, 0x50, 0x68, 0x6f, 0x74, 0x6f, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65,
-	0x12, 0x5a, 0x0a, 0x0f, 0x47, 0x65, 0x74, 0x50, 0x68, 0x6f, 0x74, 0x6f, 0x73, 0x53, 0x74, 0x72,
-	0x65, 0x61, 0x6d, 0x12, 0x21, 0x2e, 0x63, 0x61, 0x74, 0x70, 0x68, 0x6f, 0x74, 0x6f, 0x73, 0x2e,
-	0x47, 0x65, 0x74, 0x50, 0x68, 0x6f, 0x74, 0x6f, 0x73, 0x53, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x52,
-	0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x22, 0x2e, 0x63, 0x61, 0x74, 0x70, 0x68, 0x6f, 0x74,
-	0x6f, 0x73, 0x2e, 0x47, 0x65, 0x74, 0x50, 0x68, 0x6f, 0x74, 0x6f, 0x73, 0x53, 0x74, 0x72, 0x65,
-	0x61, 0x6d, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x30, 0x01, 0x42, 0x1e, 0x5a, 0x1c,
-	0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x6d, 0x68, 0x62, 0x76, 0x72,
-	0x2f, 0x6d, 0x61, 0x6e, 0x75, 0x6c, 0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x06, 0x70, 0x72,
-	0x6f, 0x74, 0x6f, 0x33,
+	0x74, 0x6f, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x1d, 0x2e, 0x63, 0x61, 0x74,
+	0x70, 0x68, 0x6f, 0x74, 0x6f, 0x73, 0x2e, 0x4c, 0x69, 0x73, 0x74, 0x50, 0x68, 0x6f, 0x74, 0x6f,
+	0x73, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x43, 0x0a, 0x08, 0x47, 0x65, 0x74,
+	0x50, 0x68, 0x6f, 0x74, 0x6f, 0x12, 0x1a, 0x2e, 0x63, 0x61, 0x74, 0x70, 0x68, 0x6f, 0x74, 0x6f,
+	0x73, 0x2e, 0x47, 0x65, 0x74, 0x50, 0x68, 0x6f, 0x74, 0x6f, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73,
+	0x74, 0x1a, 0x1b, 0x2e, 0x63, 0x61, 0x74, 0x70, 0x68, 0x6f, 0x74, 0x6f, 0x73, 0x2e, 0x47, 0x65,
+	0x74, 0x50, 0x68, 0x6f, 0x74, 0x6f, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x5a,
+	0x0a, 0x0f, 0x47, 0x65, 0x74, 0x50, 0x68, 0x6f, 0x74, 0x6f, 0x73, 0x53, 0x74, 0x72, 0x65, 0x61,
+	0x6d, 0x12, 0x21, 0x2e, 0x63, 0x61, 0x74, 0x70, 0x68, 0x6f, 0x74, 0x6f, 0x73, 0x2e, 0x47, 0x65,
+	0x74, 0x50, 0x68, 0x6f, 0x74, 0x6f, 0x73, 0x53, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x52, 0x65, 0x71,
+	0x75, 0x65, 0x73, 0x74, 0x1a, 0x22, 0x2e, 0x63, 0x61, 0x74, 0x70, 0x68, 0x6f, 0x74, 0x6f, 0x73,
+	0x2e, 0x47, 0x65, 0x74, 0x50, 0x68, 0x6f, 0x74, 0x6f, 0x73, 0x53, 0x74, 0x72, 0x65, 0x61, 0x6d,
+	0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x30, 0x01, 0x12, 0x55, 0x0a, 0x0e, 0x42, 0x61,
+	0x74, 0x63, 0x68, 0x47, 0x65, 0x74, 0x50, 0x68, 0x6f, 0x74, 0x6f, 0x73, 0x12, 0x20, 0x2e, 0x63,
+	0x61, 0x74, 0x70, 0x68, 0x6f, 0x74, 0x6f, 0x73, 0x2e, 0x42, 0x61, 0x74, 0x63, 0x68, 0x47, 0x65,
+	0x74, 0x50, 0x68, 0x6f, 0x74, 0x6f, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x21,
+	0x2e, 0x63, 0x61, 0x74, 0x70, 0x68, 0x6f, 0x74, 0x6f, 0x73, 0x2e, 0x42, 0x61, 0x74, 0x63, 0x68,
+	0x47, 0x65, 0x74, 0x50, 0x68, 0x6f, 0x74, 0x6f, 0x73, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73,
+	0x65, 0x12, 0x4d, 0x0a, 0x0f, 0x47, 0x65, 0x74, 0x50, 0x68, 0x6f, 0x74, 0x6f, 0x43, 0x68, 0x75,
+	0x6e, 0x6b, 0x65, 0x64, 0x12, 0x21, 0x2e, 0x63, 0x61, 0x74, 0x70, 0x68, 0x6f, 0x74, 0x6f, 0x73,
+	0x2e, 0x47, 0x65, 0x74, 0x50, 0x68, 0x6f, 0x74, 0x6f, 0x43, 0x68, 0x75, 0x6e, 0x6b, 0x65, 0x64,
+	0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x15, 0x2e, 0x63, 0x61, 0x74, 0x70, 0x68, 0x6f,
+	0x74, 0x6f, 0x73, 0x2e, 0x50, 0x68, 0x6f, 0x74, 0x6f, 0x43, 0x68, 0x75, 0x6e, 0x6b, 0x30, 0x01,
+	0x12, 0x5b, 0x0a, 0x10, 0x47, 0x65, 0x74, 0x50, 0x68, 0x6f, 0x74, 0x6f, 0x4d, 0x65, 0x74, 0x61,
+	0x64, 0x61, 0x74, 0x61, 0x12, 0x22, 0x2e, 0x63, 0x61, 0x74, 0x70, 0x68, 0x6f, 0x74, 0x6f, 0x73,
+	0x2e, 0x47, 0x65, 0x74, 0x50, 0x68, 0x6f, 0x74, 0x6f, 0x4d, 0x65, 0x74, 0x61, 0x64, 0x61, 0x74,
+	0x61, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x23, 0x2e, 0x63, 0x61, 0x74, 0x70, 0x68,
+	0x6f, 0x74, 0x6f, 0x73, 0x2e, 0x47, 0x65, 0x74, 0x50, 0x68, 0x6f, 0x74, 0x6f, 0x4d, 0x65, 0x74,
+	0x61, 0x64, 0x61, 0x74, 0x61, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x55, 0x0a,
+	0x0e, 0x47, 0x65, 0x74, 0x52, 0x61, 0x6e, 0x64, 0x6f, 0x6d, 0x50, 0x68, 0x6f, 0x74, 0x6f, 0x12,
+	0x20, 0x2e, 0x63, 0x61, 0x74, 0x70, 0x68, 0x6f, 0x74, 0x6f, 0x73, 0x2e, 0x47, 0x65, 0x74, 0x52,
+	0x61, 0x6e, 0x64, 0x6f, 0x6d, 0x50, 0x68, 0x6f, 0x74, 0x6f, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73,
+	0x74, 0x1a, 0x21, 0x2e, 0x63, 0x61, 0x74, 0x70, 0x68, 0x6f, 0x74, 0x6f, 0x73, 0x2e, 0x47, 0x65,
+	0x74, 0x52, 0x61, 0x6e, 0x64, 0x6f, 0x6d, 0x50, 0x68, 0x6f, 0x74, 0x6f, 0x52, 0x65, 0x73, 0x70,
+	0x6f, 0x6e, 0x73, 0x65, 0x12, 0x4f, 0x0a, 0x0c, 0x44, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x50, 0x68,
+	0x6f, 0x74, 0x6f, 0x73, 0x12, 0x1e, 0x2e, 0x63, 0x61, 0x74, 0x70, 0x68, 0x6f, 0x74, 0x6f, 0x73,
+	0x2e, 0x44, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x50, 0x68, 0x6f, 0x74, 0x6f, 0x73, 0x52, 0x65, 0x71,
+	0x75, 0x65, 0x73, 0x74, 0x1a, 0x1f, 0x2e, 0x63, 0x61, 0x74, 0x70, 0x68, 0x6f, 0x74, 0x6f, 0x73,
+	0x2e, 0x44, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x50, 0x68, 0x6f, 0x74, 0x6f, 0x73, 0x52, 0x65, 0x73,
+	0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x46, 0x0a, 0x09, 0x44, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x43,
+	0x61, 0x74, 0x12, 0x1b, 0x2e, 0x63, 0x61, 0x74, 0x70, 0x68, 0x6f, 0x74, 0x6f, 0x73, 0x2e, 0x44,
+	0x65, 0x6c, 0x65, 0x74, 0x65, 0x43, 0x61, 0x74, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a,
+	0x1c, 0x2e, 0x63, 0x61, 0x74, 0x70, 0x68, 0x6f, 0x74, 0x6f, 0x73, 0x2e, 0x44, 0x65, 0x6c, 0x65,
+	0x74, 0x65, 0x43, 0x61, 0x74, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x42, 0x1e, 0x5a,
+	0x1c, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x6d, 0x68, 0x62, 0x76,
+	0x72, 0x2f, 0x6d, 0x61, 0x6e, 0x75, 0x6c, 0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x06, 0x70,
+	0x72, 0x6f, 0x74, 0x6f, 0x33,
 }
 
 var (
@@ -668,37 +1875,76 @@ func file_cat_photos_proto_rawDescGZIP() []byte {
 	return file_cat_photos_proto_rawDescData
 }
 
-var file_cat_photos_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
-var file_cat_photos_proto_msgTypes = make([]protoimpl.MessageInfo, 9)
+var file_cat_photos_proto_enumTypes = make([]protoimpl.EnumInfo, 4)
+var file_cat_photos_proto_msgTypes = make([]protoimpl.MessageInfo, 21)
 var file_cat_photos_proto_goTypes = []interface{}{
-	(ScalingAlgorithm)(0),           // 0: catphotos.ScalingAlgorithm
-	(*ListCatsRequest)(nil),         // 1: catphotos.ListCatsRequest
-	(*ListCatsResponse)(nil),        // 2: catphotos.ListCatsResponse
-	(*ListPhotosRequest)(nil),       // 3: catphotos.ListPhotosRequest
-	(*ListPhotosResponse)(nil),      // 4: catphotos.ListPhotosResponse
-	(*GetPhotoRequest)(nil),         // 5: catphotos.GetPhotoRequest
-	(*GetPhotoResponse)(nil),        // 6: catphotos.GetPhotoResponse
-	(*PhotoRequest)(nil),            // 7: catphotos.PhotoRequest
-	(*GetPhotosStreamRequest)(nil),  // 8: catphotos.GetPhotosStreamRequest
-	(*GetPhotosStreamResponse)(nil), // 9: catphotos.GetPhotosStreamResponse
+	(ScalingAlgorithm)(0),            // 0: catphotos.ScalingAlgorithm
+	(FitMode)(0),                     // 1: catphotos.FitMode
+	(OutputFormat)(0),                // 2: catphotos.OutputFormat
+	(SizePreset)(0),                  // 3: catphotos.SizePreset
+	(*ListCatsRequest)(nil),          // 4: catphotos.ListCatsRequest
+	(*ListCatsResponse)(nil),         // 5: catphotos.ListCatsResponse
+	(*ListPhotosRequest)(nil),        // 6: catphotos.ListPhotosRequest
+	(*ListPhotosResponse)(nil),       // 7: catphotos.ListPhotosResponse
+	(*GetPhotoRequest)(nil),          // 8: catphotos.GetPhotoRequest
+	(*GetPhotoResponse)(nil),         // 9: catphotos.GetPhotoResponse
+	(*GetPhotoChunkedRequest)(nil),   // 10: catphotos.GetPhotoChunkedRequest
+	(*PhotoChunk)(nil),               // 11: catphotos.PhotoChunk
+	(*GetPhotoMetadataRequest)(nil),  // 12: catphotos.GetPhotoMetadataRequest
+	(*GetPhotoMetadataResponse)(nil), // 13: catphotos.GetPhotoMetadataResponse
+	(*GetRandomPhotoRequest)(nil),    // 14: catphotos.GetRandomPhotoRequest
+	(*GetRandomPhotoResponse)(nil),   // 15: catphotos.GetRandomPhotoResponse
+	(*PhotoRequest)(nil),             // 16: catphotos.PhotoRequest
+	(*GetPhotosStreamRequest)(nil),   // 17: catphotos.GetPhotosStreamRequest
+	(*GetPhotosStreamResponse)(nil),  // 18: catphotos.GetPhotosStreamResponse
+	(*BatchGetPhotosRequest)(nil),    // 19: catphotos.BatchGetPhotosRequest
+	(*BatchGetPhotosResponse)(nil),   // 20: catphotos.BatchGetPhotosResponse
+	(*DeletePhotosRequest)(nil),      // 21: catphotos.DeletePhotosRequest
+	(*DeletePhotosResponse)(nil),     // 22: catphotos.DeletePhotosResponse
+	(*DeleteCatRequest)(nil),         // 23: catphotos.DeleteCatRequest
+	(*DeleteCatResponse)(nil),        // 24: catphotos.DeleteCatResponse
 }
 var file_cat_photos_proto_depIdxs = []int32{
-	0, // 0: catphotos.GetPhotoRequest.scaling_algorithm:type_name -> catphotos.ScalingAlgorithm
-	7, // 1: catphotos.GetPhotosStreamRequest.photo_requests:type_name -> catphotos.PhotoRequest
-	0, // 2: catphotos.GetPhotosStreamRequest.scaling_algorithm:type_name -> catphotos.ScalingAlgorithm
-	1, // 3: catphotos.CatPhotosService.ListCats:input_type -> catphotos.ListCatsRequest
-	3, // 4: catphotos.CatPhotosService.ListPhotos:input_type -> catphotos.ListPhotosRequest
-	5, // 5: catphotos.CatPhotosService.GetPhoto:input_type -> catphotos.GetPhotoRequest
-	8, // 6: catphotos.CatPhotosService.GetPhotosStream:input_type -> catphotos.GetPhotosStreamRequest
-	2, // 7: catphotos.CatPhotosService.ListCats:output_type -> catphotos.ListCatsResponse
-	4, // 8: catphotos.CatPhotosService.ListPhotos:output_type -> catphotos.ListPhotosResponse
-	6, // 9: catphotos.CatPhotosService.GetPhoto:output_type -> catphotos.GetPhotoResponse
-	9, // 10: catphotos.CatPhotosService.GetPhotosStream:output_type -> catphotos.GetPhotosStreamResponse
-	7, // [7:11] is the sub-list for method output_type
-	3, // [3:7] is the sub-list for method input_type
-	3, // [3:3] is the sub-list for extension type_name
-	3, // [3:3] is the sub-list for extension extendee
-	0, // [0:3] is the sub-list for field type_name
+	0,  // 0: catphotos.GetPhotoRequest.scaling_algorithm:type_name -> catphotos.ScalingAlgorithm
+	2,  // 1: catphotos.GetPhotoRequest.output_format:type_name -> catphotos.OutputFormat
+	1,  // 2: catphotos.GetPhotoRequest.fit:type_name -> catphotos.FitMode
+	3,  // 3: catphotos.GetPhotoRequest.size_preset:type_name -> catphotos.SizePreset
+	2,  // 4: catphotos.GetPhotoRequest.accept_formats:type_name -> catphotos.OutputFormat
+	2,  // 5: catphotos.GetPhotoResponse.format:type_name -> catphotos.OutputFormat
+	8,  // 6: catphotos.GetPhotoChunkedRequest.photo:type_name -> catphotos.GetPhotoRequest
+	8,  // 7: catphotos.GetRandomPhotoRequest.photo:type_name -> catphotos.GetPhotoRequest
+	9,  // 8: catphotos.GetRandomPhotoResponse.photo:type_name -> catphotos.GetPhotoResponse
+	16, // 9: catphotos.GetPhotosStreamRequest.photo_requests:type_name -> catphotos.PhotoRequest
+	0,  // 10: catphotos.GetPhotosStreamRequest.scaling_algorithm:type_name -> catphotos.ScalingAlgorithm
+	16, // 11: catphotos.BatchGetPhotosRequest.photo_requests:type_name -> catphotos.PhotoRequest
+	0,  // 12: catphotos.BatchGetPhotosRequest.scaling_algorithm:type_name -> catphotos.ScalingAlgorithm
+	18, // 13: catphotos.BatchGetPhotosResponse.photos:type_name -> catphotos.GetPhotosStreamResponse
+	16, // 14: catphotos.DeletePhotosRequest.photo_requests:type_name -> catphotos.PhotoRequest
+	4,  // 15: catphotos.CatPhotosService.ListCats:input_type -> catphotos.ListCatsRequest
+	6,  // 16: catphotos.CatPhotosService.ListPhotos:input_type -> catphotos.ListPhotosRequest
+	8,  // 17: catphotos.CatPhotosService.GetPhoto:input_type -> catphotos.GetPhotoRequest
+	17, // 18: catphotos.CatPhotosService.GetPhotosStream:input_type -> catphotos.GetPhotosStreamRequest
+	19, // 19: catphotos.CatPhotosService.BatchGetPhotos:input_type -> catphotos.BatchGetPhotosRequest
+	10, // 20: catphotos.CatPhotosService.GetPhotoChunked:input_type -> catphotos.GetPhotoChunkedRequest
+	12, // 21: catphotos.CatPhotosService.GetPhotoMetadata:input_type -> catphotos.GetPhotoMetadataRequest
+	14, // 22: catphotos.CatPhotosService.GetRandomPhoto:input_type -> catphotos.GetRandomPhotoRequest
+	21, // 23: catphotos.CatPhotosService.DeletePhotos:input_type -> catphotos.DeletePhotosRequest
+	23, // 24: catphotos.CatPhotosService.DeleteCat:input_type -> catphotos.DeleteCatRequest
+	5,  // 25: catphotos.CatPhotosService.ListCats:output_type -> catphotos.ListCatsResponse
+	7,  // 26: catphotos.CatPhotosService.ListPhotos:output_type -> catphotos.ListPhotosResponse
+	9,  // 27: catphotos.CatPhotosService.GetPhoto:output_type -> catphotos.GetPhotoResponse
+	18, // 28: catphotos.CatPhotosService.GetPhotosStream:output_type -> catphotos.GetPhotosStreamResponse
+	20, // 29: catphotos.CatPhotosService.BatchGetPhotos:output_type -> catphotos.BatchGetPhotosResponse
+	11, // 30: catphotos.CatPhotosService.GetPhotoChunked:output_type -> catphotos.PhotoChunk
+	13, // 31: catphotos.CatPhotosService.GetPhotoMetadata:output_type -> catphotos.GetPhotoMetadataResponse
+	15, // 32: catphotos.CatPhotosService.GetRandomPhoto:output_type -> catphotos.GetRandomPhotoResponse
+	22, // 33: catphotos.CatPhotosService.DeletePhotos:output_type -> catphotos.DeletePhotosResponse
+	24, // 34: catphotos.CatPhotosService.DeleteCat:output_type -> catphotos.DeleteCatResponse
+	25, // [25:35] is the sub-list for method output_type
+	15, // [15:25] is the sub-list for method input_type
+	15, // [15:15] is the sub-list for extension type_name
+	15, // [15:15] is the sub-list for extension extendee
+	0,  // [0:15] is the sub-list for field type_name
 }
 
 func init() { file_cat_photos_proto_init() }
@@ -780,7 +2026,7 @@ func file_cat_photos_proto_init() {
 			}
 		}
 		file_cat_photos_proto_msgTypes[6].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*PhotoRequest); i {
+			switch v := v.(*GetPhotoChunkedRequest); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -792,7 +2038,7 @@ func file_cat_photos_proto_init() {
 			}
 		}
 		file_cat_photos_proto_msgTypes[7].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*GetPhotosStreamRequest); i {
+			switch v := v.(*PhotoChunk); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -804,6 +2050,78 @@ func file_cat_photos_proto_init() {
 			}
 		}
 		file_cat_photos_proto_msgTypes[8].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*GetPhotoMetadataRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_cat_photos_proto_msgTypes[9].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*GetPhotoMetadataResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_cat_photos_proto_msgTypes[10].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*GetRandomPhotoRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_cat_photos_proto_msgTypes[11].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*GetRandomPhotoResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_cat_photos_proto_msgTypes[12].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*PhotoRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_cat_photos_proto_msgTypes[13].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*GetPhotosStreamRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_cat_photos_proto_msgTypes[14].Exporter = func(v interface{}, i int) interface{} {
 			switch v := v.(*GetPhotosStreamResponse); i {
 			case 0:
 				return &v.state
@@ -815,14 +2133,86 @@ func file_cat_photos_proto_init() {
 				return nil
 			}
 		}
+		file_cat_photos_proto_msgTypes[15].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*BatchGetPhotosRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_cat_photos_proto_msgTypes[16].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*BatchGetPhotosResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_cat_photos_proto_msgTypes[17].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*DeletePhotosRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_cat_photos_proto_msgTypes[18].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*DeletePhotosResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_cat_photos_proto_msgTypes[19].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*DeleteCatRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_cat_photos_proto_msgTypes[20].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*DeleteCatResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
 	}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: file_cat_photos_proto_rawDesc,
-			NumEnums:      1,
-			NumMessages:   9,
+			NumEnums:      4,
+			NumMessages:   21,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
@@ -26,6 +26,16 @@ type CatPhotosServiceClient interface {
 	ListPhotos(ctx context.Context, in *ListPhotosRequest, opts ...grpc.CallOption) (*ListPhotosResponse, error)
 	GetPhoto(ctx context.Context, in *GetPhotoRequest, opts ...grpc.CallOption) (*GetPhotoResponse, error)
 	GetPhotosStream(ctx context.Context, in *GetPhotosStreamRequest, opts ...grpc.CallOption) (CatPhotosService_GetPhotosStreamClient, error)
+	// Gets up to 100 photos in one call, failures are reported per photo
+	BatchGetPhotos(ctx context.Context, in *BatchGetPhotosRequest, opts ...grpc.CallOption) (*BatchGetPhotosResponse, error)
+	// Sends a photo in chunks, for photos over the gRPC message size limit
+	GetPhotoChunked(ctx context.Context, in *GetPhotoChunkedRequest, opts ...grpc.CallOption) (CatPhotosService_GetPhotoChunkedClient, error)
+	// Gets the dimensions, size and format of a photo without its data
+	GetPhotoMetadata(ctx context.Context, in *GetPhotoMetadataRequest, opts ...grpc.CallOption) (*GetPhotoMetadataResponse, error)
+	// Picks a random photo without the client listing all photos
+	GetRandomPhoto(ctx context.Context, in *GetRandomPhotoRequest, opts ...grpc.CallOption) (*GetRandomPhotoResponse, error)
+	DeletePhotos(ctx context.Context, in *DeletePhotosRequest, opts ...grpc.CallOption) (*DeletePhotosResponse, error)
+	DeleteCat(ctx context.Context, in *DeleteCatRequest, opts ...grpc.CallOption) (*DeleteCatResponse, error)
 }
 
 type catPhotosServiceClient struct {
@@ -95,6 +105,83 @@ func (x *catPhotosServiceGetPhotosStreamClient) Recv() (*GetPhotosStreamResponse
 	return m, nil
 }
 
+func (c *catPhotosServiceClient) BatchGetPhotos(ctx context.Context, in *BatchGetPhotosRequest, opts ...grpc.CallOption) (*BatchGetPhotosResponse, error) {
+	out := new(BatchGetPhotosResponse)
+	err := c.cc.Invoke(ctx, "/catphotos.CatPhotosService/BatchGetPhotos", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *catPhotosServiceClient) GetPhotoChunked(ctx context.Context, in *GetPhotoChunkedRequest, opts ...grpc.CallOption) (CatPhotosService_GetPhotoChunkedClient, error) {
+	stream, err := c.cc.NewStream(ctx, &CatPhotosService_ServiceDesc.Streams[1], "/catphotos.CatPhotosService/GetPhotoChunked", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &catPhotosServiceGetPhotoChunkedClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type CatPhotosService_GetPhotoChunkedClient interface {
+	Recv() (*PhotoChunk, error)
+	grpc.ClientStream
+}
+
+type catPhotosServiceGetPhotoChunkedClient struct {
+	grpc.ClientStream
+}
+
+func (x *catPhotosServiceGetPhotoChunkedClient) Recv() (*PhotoChunk, error) {
+	m := new(PhotoChunk)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *catPhotosServiceClient) GetPhotoMetadata(ctx context.Context, in *GetPhotoMetadataRequest, opts ...grpc.CallOption) (*GetPhotoMetadataResponse, error) {
+	out := new(GetPhotoMetadataResponse)
+	err := c.cc.Invoke(ctx, "/catphotos.CatPhotosService/GetPhotoMetadata", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *catPhotosServiceClient) GetRandomPhoto(ctx context.Context, in *GetRandomPhotoRequest, opts ...grpc.CallOption) (*GetRandomPhotoResponse, error) {
+	out := new(GetRandomPhotoResponse)
+	err := c.cc.Invoke(ctx, "/catphotos.CatPhotosService/GetRandomPhoto", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *catPhotosServiceClient) DeletePhotos(ctx context.Context, in *DeletePhotosRequest, opts ...grpc.CallOption) (*DeletePhotosResponse, error) {
+	out := new(DeletePhotosResponse)
+	err := c.cc.Invoke(ctx, "/catphotos.CatPhotosService/DeletePhotos", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *catPhotosServiceClient) DeleteCat(ctx context.Context, in *DeleteCatRequest, opts ...grpc.CallOption) (*DeleteCatResponse, error) {
+	out := new(DeleteCatResponse)
+	err := c.cc.Invoke(ctx, "/catphotos.CatPhotosService/DeleteCat", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // CatPhotosServiceServer is the server API for CatPhotosService service.
 // All implementations must embed UnimplementedCatPhotosServiceServer
 // for forward compatibility
@@ -103,6 +190,16 @@ type CatPhotosServiceServer interface {
 	ListPhotos(context.Context, *ListPhotosRequest) (*ListPhotosResponse, error)
 	GetPhoto(context.Context, *GetPhotoRequest) (*GetPhotoResponse, error)
 	GetPhotosStream(*GetPhotosStreamRequest, CatPhotosService_GetPhotosStreamServer) error
+	// Gets up to 100 photos in one call, failures are reported per photo
+	BatchGetPhotos(context.Context, *BatchGetPhotosRequest) (*BatchGetPhotosResponse, error)
+	// Sends a photo in chunks, for photos over the gRPC message size limit
+	GetPhotoChunked(*GetPhotoChunkedRequest, CatPhotosService_GetPhotoChunkedServer) error
+	// Gets the dimensions, size and format of a photo without its data
+	GetPhotoMetadata(context.Context, *GetPhotoMetadataRequest) (*GetPhotoMetadataResponse, error)
+	// Picks a random photo without the client listing all photos
+	GetRandomPhoto(context.Context, *GetRandomPhotoRequest) (*GetRandomPhotoResponse, error)
+	DeletePhotos(context.Context, *DeletePhotosRequest) (*DeletePhotosResponse, error)
+	DeleteCat(context.Context, *DeleteCatRequest) (*DeleteCatResponse, error)
 	mustEmbedUnimplementedCatPhotosServiceServer()
 }
 
@@ -122,6 +219,24 @@ func (UnimplementedCatPhotosServiceServer) GetPhoto(context.Context, *GetPhotoRe
 func (UnimplementedCatPhotosServiceServer) GetPhotosStream(*GetPhotosStreamRequest, CatPhotosService_GetPhotosStreamServer) error {
 	return status.Errorf(codes.Unimplemented, "method GetPhotosStream not implemented")
 }
+func (UnimplementedCatPhotosServiceServer) BatchGetPhotos(context.Context, *BatchGetPhotosRequest) (*BatchGetPhotosResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method BatchGetPhotos not implemented")
+}
+func (UnimplementedCatPhotosServiceServer) GetPhotoChunked(*GetPhotoChunkedRequest, CatPhotosService_GetPhotoChunkedServer) error {
+	return status.Errorf(codes.Unimplemented, "method GetPhotoChunked not implemented")
+}
+func (UnimplementedCatPhotosServiceServer) GetPhotoMetadata(context.Context, *GetPhotoMetadataRequest) (*GetPhotoMetadataResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetPhotoMetadata not implemented")
+}
+func (UnimplementedCatPhotosServiceServer) GetRandomPhoto(context.Context, *GetRandomPhotoRequest) (*GetRandomPhotoResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetRandomPhoto not implemented")
+}
+func (UnimplementedCatPhotosServiceServer) DeletePhotos(context.Context, *DeletePhotosRequest) (*DeletePhotosResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeletePhotos not implemented")
+}
+func (UnimplementedCatPhotosServiceServer) DeleteCat(context.Context, *DeleteCatRequest) (*DeleteCatResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteCat not implemented")
+}
 func (UnimplementedCatPhotosServiceServer) mustEmbedUnimplementedCatPhotosServiceServer() {}
 
 // UnsafeCatPhotosServiceServer may be embedded to opt out of forward compatibility for this service.
@@ -210,6 +325,117 @@ func (x *catPhotosServiceGetPhotosStreamServer) Send(m *GetPhotosStreamResponse)
 	return x.ServerStream.SendMsg(m)
 }
 
+func _CatPhotosService_BatchGetPhotos_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BatchGetPhotosRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CatPhotosServiceServer).BatchGetPhotos(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/catphotos.CatPhotosService/BatchGetPhotos",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CatPhotosServiceServer).BatchGetPhotos(ctx, req.(*BatchGetPhotosRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CatPhotosService_GetPhotoChunked_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(GetPhotoChunkedRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(CatPhotosServiceServer).GetPhotoChunked(m, &catPhotosServiceGetPhotoChunkedServer{stream})
+}
+
+type CatPhotosService_GetPhotoChunkedServer interface {
+	Send(*PhotoChunk) error
+	grpc.ServerStream
+}
+
+type catPhotosServiceGetPhotoChunkedServer struct {
+	grpc.ServerStream
+}
+
+func (x *catPhotosServiceGetPhotoChunkedServer) Send(m *PhotoChunk) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _CatPhotosService_GetPhotoMetadata_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetPhotoMetadataRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CatPhotosServiceServer).GetPhotoMetadata(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/catphotos.CatPhotosService/GetPhotoMetadata",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CatPhotosServiceServer).GetPhotoMetadata(ctx, req.(*GetPhotoMetadataRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CatPhotosService_GetRandomPhoto_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetRandomPhotoRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CatPhotosServiceServer).GetRandomPhoto(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/catphotos.CatPhotosService/GetRandomPhoto",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CatPhotosServiceServer).GetRandomPhoto(ctx, req.(*GetRandomPhotoRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CatPhotosService_DeletePhotos_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeletePhotosRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CatPhotosServiceServer).DeletePhotos(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/catphotos.CatPhotosService/DeletePhotos",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CatPhotosServiceServer).DeletePhotos(ctx, req.(*DeletePhotosRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CatPhotosService_DeleteCat_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteCatRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CatPhotosServiceServer).DeleteCat(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/catphotos.CatPhotosService/DeleteCat",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CatPhotosServiceServer).DeleteCat(ctx, req.(*DeleteCatRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // CatPhotosService_ServiceDesc is the grpc.ServiceDesc for CatPhotosService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -229,6 +455,26 @@ var CatPhotosService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "GetPhoto",
 			Handler:    _CatPhotosService_GetPhoto_Handler,
 		},
+		{
+			MethodName: "BatchGetPhotos",
+			Handler:    _CatPhotosService_BatchGetPhotos_Handler,
+		},
+		{
+			MethodName: "GetPhotoMetadata",
+			Handler:    _CatPhotosService_GetPhotoMetadata_Handler,
+		},
+		{
+			MethodName: "GetRandomPhoto",
+			Handler:    _CatPhotosService_GetRandomPhoto_Handler,
+		},
+		{
+			MethodName: "DeletePhotos",
+			Handler:    _CatPhotosService_DeletePhotos_Handler,
+		},
+		{
+			MethodName: "DeleteCat",
+			Handler:    _CatPhotosService_DeleteCat_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{
 		{
@@ -236,6 +482,11 @@ var CatPhotosService_ServiceDesc = grpc.ServiceDesc{
 			Handler:       _CatPhotosService_GetPhotosStream_Handler,
 			ServerStreams: true,
 		},
+		{
+			StreamName:    "GetPhotoChunked",
+			Handler:       _CatPhotosService_GetPhotoChunked_Handler,
+			ServerStreams: true,
+		},
 	},
 	Metadata: "cat_photos.proto",
 }
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	"slices"
+
+	"github.com/mhbvr/manul"
+	pb "github.com/mhbvr/manul/proto"
+	_ "golang.org/x/image/webp"
+)
+
+// defaultJPEGQuality is the quality of JPEG photos encoded for requests
+// without one
+const defaultJPEGQuality = 85
+
+// clampQuality limits a requested JPEG quality to 100, 0 means the default
+func clampQuality(quality uint32) uint32 {
+	return min(quality, 100)
+}
+
+// errWebPUnsupported is returned for WebP output, the standard library and
+// x/image only decode WebP
+var errWebPUnsupported = errors.New("WebP output is not supported by this server")
+
+// outputSupported reports whether photos can be encoded in format
+func outputSupported(format pb.OutputFormat) bool {
+	return format != pb.OutputFormat_WEBP
+}
+
+// negotiationOrder is the order in which accepted formats are picked,
+// ORIGINAL is only used when the client accepts no specific format
+var negotiationOrder = []pb.OutputFormat{
+	pb.OutputFormat_JPEG,
+	pb.OutputFormat_PNG,
+	pb.OutputFormat_ORIGINAL,
+}
+
+// negotiateFormat picks the output format of a request accepting the
+// accepted formats, ok is false if none of them is supported
+func negotiateFormat(accepted []pb.OutputFormat) (pb.OutputFormat, bool) {
+	for _, format := range negotiationOrder {
+		if slices.Contains(accepted, format) && outputSupported(format) {
+			return format, true
+		}
+	}
+	return pb.OutputFormat_ORIGINAL, false
+}
+
+// outputPhotoFormat returns the stored photo format produced by format,
+// FormatUnknown for ORIGINAL
+func outputPhotoFormat(format pb.OutputFormat) manul.PhotoFormat {
+	switch format {
+	case pb.OutputFormat_JPEG:
+		return manul.FormatJPEG
+	case pb.OutputFormat_PNG:
+		return manul.FormatPNG
+	}
+	return manul.FormatUnknown
+}
+
+// conversionRequested reports whether photoData has to be re-encoded to be
+// returned in format
+func conversionRequested(photoData []byte, format pb.OutputFormat) bool {
+	return format != pb.OutputFormat_ORIGINAL && manul.DetectFormat(photoData) != outputPhotoFormat(format)
+}
+
+// encodeImage encodes img in format, ORIGINAL is encoded as JPEG with
+// quality, or the default quality if it is 0
+func encodeImage(img image.Image, format pb.OutputFormat, quality uint32) ([]byte, error) {
+	if !outputSupported(format) {
+		return nil, errWebPUnsupported
+	}
+
+	pool := scalingImagePool
+	buf := pool.getBuffer()
+	var err error
+	switch format {
+	case pb.OutputFormat_PNG:
+		err = pool.pngEncoder().Encode(buf, img)
+	default:
+		if quality == 0 {
+			quality = defaultJPEGQuality
+		}
+		err = jpeg.Encode(buf, img, &jpeg.Options{Quality: int(clampQuality(quality))})
+	}
+	if err != nil {
+		pool.putBuffer(buf)
+		return nil, fmt.Errorf("failed to encode image as %s: %v", format, err)
+	}
+	if pool == nil {
+		return buf.Bytes(), nil
+	}
+
+	// The buffer is reused, return a copy of the encoded size
+	res := bytes.Clone(buf.Bytes())
+	pool.putBuffer(buf)
+	return res, nil
+}
@@ -0,0 +1,316 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"image/jpeg"
+	"io"
+	"net"
+	"testing"
+
+	pb "github.com/mhbvr/manul/proto"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// newTestClient returns a client of newTestServer, see newTestClientFor
+func newTestClient(t *testing.T) pb.CatPhotosServiceClient {
+	t.Helper()
+	return newTestClientFor(t, newTestServer(t, false))
+}
+
+// newTestClientFor serves s over an in-memory connection and returns a
+// client connected to it
+func newTestClientFor(t *testing.T, s *CatPhotosServer) pb.CatPhotosServiceClient {
+	t.Helper()
+
+	lis := bufconn.Listen(1 << 20)
+	grpcServer := grpc.NewServer()
+	pb.RegisterCatPhotosServiceServer(grpcServer, s)
+	go grpcServer.Serve(lis)
+	t.Cleanup(grpcServer.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return pb.NewCatPhotosServiceClient(conn)
+}
+
+func TestGRPC_ListCats(t *testing.T) {
+	client := newTestClient(t)
+
+	resp, err := client.ListCats(context.Background(), &pb.ListCatsRequest{})
+	if err != nil {
+		t.Fatalf("ListCats() failed: %v", err)
+	}
+	if len(resp.CatIds) != 2 || resp.CatIds[0] != 1 || resp.CatIds[1] != 2 {
+		t.Errorf("ListCats() = %v, want [1 2]", resp.CatIds)
+	}
+}
+
+func TestGRPC_ListPhotos(t *testing.T) {
+	client := newTestClient(t)
+
+	tests := []struct {
+		name     string
+		catID    uint64
+		want     int
+		wantCode codes.Code
+	}{
+		{"found", 1, 2, codes.OK},
+		{"not found", 3, 0, codes.NotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := client.ListPhotos(context.Background(), &pb.ListPhotosRequest{CatId: tt.catID})
+			if status.Code(err) != tt.wantCode {
+				t.Fatalf("ListPhotos() error = %v, want code %v", err, tt.wantCode)
+			}
+			if err == nil && len(resp.PhotoIds) != tt.want {
+				t.Errorf("ListPhotos() = %v, want %d photos", resp.PhotoIds, tt.want)
+			}
+		})
+	}
+}
+
+func TestGRPC_GetPhoto(t *testing.T) {
+	client := newTestClient(t)
+
+	tests := []struct {
+		name      string
+		req       *pb.GetPhotoRequest
+		wantCode  codes.Code
+		wantWidth int
+	}{
+		{"original", &pb.GetPhotoRequest{CatId: 1, PhotoId: 1}, codes.OK, 20},
+		{"scaled", &pb.GetPhotoRequest{CatId: 1, PhotoId: 1, Width: 10, ScalingAlgorithm: pb.ScalingAlgorithm_BILINEAR}, codes.OK, 10},
+		{"photo not found", &pb.GetPhotoRequest{CatId: 1, PhotoId: 3}, codes.NotFound, 0},
+		{"cat not found", &pb.GetPhotoRequest{CatId: 3, PhotoId: 1}, codes.NotFound, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := client.GetPhoto(context.Background(), tt.req)
+			if status.Code(err) != tt.wantCode {
+				t.Fatalf("GetPhoto() error = %v, want code %v", err, tt.wantCode)
+			}
+			if err != nil {
+				return
+			}
+
+			if resp.ContentType != "image/jpeg" {
+				t.Errorf("GetPhoto() content type = %q, want image/jpeg", resp.ContentType)
+			}
+			img, err := jpeg.Decode(bytes.NewReader(resp.PhotoData))
+			if err != nil {
+				t.Fatalf("Failed to decode photo: %v", err)
+			}
+			if img.Bounds().Dx() != tt.wantWidth {
+				t.Errorf("GetPhoto() width = %d, want %d", img.Bounds().Dx(), tt.wantWidth)
+			}
+		})
+	}
+}
+
+func TestGRPC_GetPhoto_NotFoundDetails(t *testing.T) {
+	client := newTestClient(t)
+
+	_, err := client.GetPhoto(context.Background(), &pb.GetPhotoRequest{CatId: 1, PhotoId: 3})
+	if status.Code(err) != codes.NotFound {
+		t.Fatalf("GetPhoto() error = %v, want NotFound", err)
+	}
+
+	var info *errdetails.ErrorInfo
+	for _, detail := range status.Convert(err).Details() {
+		if d, ok := detail.(*errdetails.ErrorInfo); ok {
+			info = d
+		}
+	}
+	if info == nil {
+		t.Fatalf("GetPhoto() error has no ErrorInfo detail: %v", err)
+	}
+	if info.Reason != photoNotFoundReason || info.Metadata["cat_id"] != "1" || info.Metadata["photo_id"] != "3" {
+		t.Errorf("ErrorInfo = %v, want reason %s, cat_id 1 and photo_id 3", info, photoNotFoundReason)
+	}
+}
+
+func TestGRPC_GetPhotosStream(t *testing.T) {
+	client := newTestClient(t)
+
+	stream, err := client.GetPhotosStream(context.Background(), &pb.GetPhotosStreamRequest{
+		PhotoRequests: []*pb.PhotoRequest{
+			{CatId: 1, PhotoId: 1},
+			{CatId: 1, PhotoId: 3},
+			{CatId: 2, PhotoId: 1},
+		},
+		Width:            10,
+		ScalingAlgorithm: pb.ScalingAlgorithm_AUTO,
+	})
+	if err != nil {
+		t.Fatalf("GetPhotosStream() failed: %v", err)
+	}
+
+	var responses []*pb.GetPhotosStreamResponse
+	for {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Recv() failed: %v", err)
+		}
+		responses = append(responses, resp)
+	}
+
+	wantSuccess := []bool{true, false, true}
+	if len(responses) != len(wantSuccess) {
+		t.Fatalf("GetPhotosStream() returned %d responses, want %d", len(responses), len(wantSuccess))
+	}
+	for i, resp := range responses {
+		if resp.Success != wantSuccess[i] {
+			t.Errorf("Response %d success = %v, want %v", i, resp.Success, wantSuccess[i])
+		}
+		if resp.Success && (len(resp.PhotoData) == 0 || resp.ContentType != "image/jpeg") {
+			t.Errorf("Response %d has %d bytes of %q, want a jpeg photo", i, len(resp.PhotoData), resp.ContentType)
+		}
+		if !resp.Success && resp.ErrorMessage == "" {
+			t.Errorf("Response %d failed without an error message", i)
+		}
+	}
+	if responses[1].CatId != 1 || responses[1].PhotoId != 3 {
+		t.Errorf("Failed response is for cat %d photo %d, want cat 1 photo 3", responses[1].CatId, responses[1].PhotoId)
+	}
+}
+
+func TestGRPC_GetPhotosStream_MaxBytes(t *testing.T) {
+	s := newTestServer(t, false)
+	client := newTestClientFor(t, s)
+	ctx := context.Background()
+
+	photo, err := client.GetPhoto(ctx, &pb.GetPhotoRequest{CatId: 1, PhotoId: 1})
+	if err != nil {
+		t.Fatalf("GetPhoto() failed: %v", err)
+	}
+	// Room for two test photos, which all have the same size
+	s.SetMaxStreamBytes(int64(2*len(photo.PhotoData) + 1))
+
+	stream, err := client.GetPhotosStream(ctx, &pb.GetPhotosStreamRequest{
+		PhotoRequests: []*pb.PhotoRequest{
+			{CatId: 1, PhotoId: 1},
+			{CatId: 1, PhotoId: 2},
+			{CatId: 2, PhotoId: 1},
+			{CatId: 1, PhotoId: 1},
+		},
+	})
+	if err != nil {
+		t.Fatalf("GetPhotosStream() failed: %v", err)
+	}
+
+	var responses []*pb.GetPhotosStreamResponse
+	for {
+		resp, err := stream.Recv()
+		if err != nil {
+			if status.Code(err) != codes.ResourceExhausted {
+				t.Errorf("GetPhotosStream() error = %v, want ResourceExhausted", err)
+			}
+			break
+		}
+		responses = append(responses, resp)
+	}
+
+	if len(responses) != 3 {
+		t.Fatalf("GetPhotosStream() returned %d responses, want 3", len(responses))
+	}
+	for i, resp := range responses[:2] {
+		if !resp.Success || len(resp.PhotoData) != len(photo.PhotoData) {
+			t.Errorf("Response %d = %v with %d bytes, want a photo", i, resp.Success, len(resp.PhotoData))
+		}
+	}
+	last := responses[2]
+	if last.Success || len(last.PhotoData) != 0 || last.ErrorMessage == "" {
+		t.Errorf("Last response = %v with %d bytes and error %q, want an error without data", last.Success, len(last.PhotoData), last.ErrorMessage)
+	}
+	if last.CatId != 2 || last.PhotoId != 1 {
+		t.Errorf("Last response is for cat %d photo %d, want cat 2 photo 1", last.CatId, last.PhotoId)
+	}
+}
+
+func TestGRPC_GetPhotoChunked(t *testing.T) {
+	client := newTestClient(t)
+	photoReq := &pb.GetPhotoRequest{CatId: 1, PhotoId: 1}
+
+	want, err := client.GetPhoto(context.Background(), photoReq)
+	if err != nil {
+		t.Fatalf("GetPhoto() failed: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		chunkSize  uint32
+		wantChunks int
+	}{
+		{"small chunks", 100, (len(want.PhotoData) + 99) / 100},
+		{"default", 0, 1},
+		{"capped", 1 << 30, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stream, err := client.GetPhotoChunked(context.Background(), &pb.GetPhotoChunkedRequest{Photo: photoReq, ChunkSize: tt.chunkSize})
+			if err != nil {
+				t.Fatalf("GetPhotoChunked() failed: %v", err)
+			}
+
+			var chunks []*pb.PhotoChunk
+			var data []byte
+			for {
+				chunk, err := stream.Recv()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatalf("Recv() failed: %v", err)
+				}
+				chunks = append(chunks, chunk)
+				data = append(data, chunk.Data...)
+			}
+
+			if len(chunks) != tt.wantChunks {
+				t.Errorf("GetPhotoChunked() sent %d chunks, want %d", len(chunks), tt.wantChunks)
+			}
+			if !bytes.Equal(data, want.PhotoData) {
+				t.Errorf("Reassembled photo differs from GetPhoto()")
+			}
+			if chunks[0].TotalSize != uint64(len(want.PhotoData)) || chunks[0].ContentType != want.ContentType {
+				t.Errorf("First chunk total size = %d, content type = %q, want %d, %q",
+					chunks[0].TotalSize, chunks[0].ContentType, len(want.PhotoData), want.ContentType)
+			}
+		})
+	}
+}
+
+func TestGRPC_GetPhotoChunked_NotFound(t *testing.T) {
+	client := newTestClient(t)
+
+	stream, err := client.GetPhotoChunked(context.Background(), &pb.GetPhotoChunkedRequest{
+		Photo: &pb.GetPhotoRequest{CatId: 1, PhotoId: 3},
+	})
+	if err != nil {
+		t.Fatalf("GetPhotoChunked() failed: %v", err)
+	}
+	if _, err := stream.Recv(); status.Code(err) != codes.NotFound {
+		t.Errorf("Recv() error = %v, want NotFound", err)
+	}
+}
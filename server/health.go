@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	pb "github.com/mhbvr/manul/proto"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// servingStatus mirrors the status reported to gRPC health checks
+var servingStatus = promauto.NewGauge(
+	prometheus.GaugeOpts{
+		Name: "cat_photos_serving",
+		Help: "Status reported to gRPC health checks: 1 if serving, 0 if not",
+	},
+)
+
+// healthReporter reports the server status to gRPC health checks, for the
+// whole server and the CatPhotos service, and to metrics
+type healthReporter struct {
+	server   *health.Server
+	shutdown atomic.Bool
+}
+
+// newHealthReporter returns a reporter of a health server not serving yet
+func newHealthReporter() *healthReporter {
+	h := &healthReporter{server: health.NewServer()}
+	h.SetServing(false)
+	return h
+}
+
+// SetServing sets the reported status, it is ignored after Shutdown
+func (h *healthReporter) SetServing(serving bool) {
+	if h.shutdown.Load() {
+		return
+	}
+
+	status := healthpb.HealthCheckResponse_NOT_SERVING
+	if serving {
+		status = healthpb.HealthCheckResponse_SERVING
+	}
+	h.server.SetServingStatus("", status)
+	h.server.SetServingStatus(pb.CatPhotosService_ServiceDesc.ServiceName, status)
+	if serving {
+		servingStatus.Set(1)
+	} else {
+		servingStatus.Set(0)
+	}
+}
+
+// Shutdown reports NOT_SERVING for good, so clients drain the server
+// before it stops
+func (h *healthReporter) Shutdown() {
+	h.shutdown.Store(true)
+	h.server.Shutdown()
+	servingStatus.Set(0)
+}
+
+// readErrorMonitor reports the server as not serving while photo data
+// reads fail, so load balancers drain a server with a failing disk
+type readErrorMonitor struct {
+	health    *healthReporter
+	logger    *slog.Logger
+	threshold int64
+	interval  time.Duration
+	errors    atomic.Int64 // Read errors in the current interval
+	cancel    context.CancelFunc
+}
+
+// StartReadErrorMonitor makes the server report NOT_SERVING for an interval
+// after one with at least threshold failed reads of photos known to exist
+func (s *CatPhotosServer) StartReadErrorMonitor(h *healthReporter, threshold int, interval time.Duration) {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.readErrors = &readErrorMonitor{
+		health:    h,
+		logger:    s.logger,
+		threshold: int64(threshold),
+		interval:  interval,
+		cancel:    cancel,
+	}
+	go s.readErrors.run(ctx)
+}
+
+func (m *readErrorMonitor) run(ctx context.Context) {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	serving := true
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			serving = m.check(serving)
+		}
+	}
+}
+
+// check updates the reported status with the errors of the past interval
+// and returns the new status
+func (m *readErrorMonitor) check(serving bool) bool {
+	errors := m.errors.Swap(0)
+	healthy := errors < m.threshold
+	if healthy != serving {
+		if healthy {
+			m.logger.Info("Photo reads recovered, reporting SERVING")
+		} else {
+			m.logger.Warn("Photo reads failed, reporting NOT_SERVING", "errors", errors, "interval", m.interval)
+		}
+		m.health.SetServing(healthy)
+	}
+	return healthy
+}
+
+// Record counts a failed read
+func (m *readErrorMonitor) Record() {
+	m.errors.Add(1)
+}
+
+func (m *readErrorMonitor) Stop() {
+	m.cancel()
+}
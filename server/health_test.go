@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func healthStatus(t *testing.T, h *healthReporter) healthpb.HealthCheckResponse_ServingStatus {
+	t.Helper()
+
+	resp, err := h.server.Check(context.Background(), &healthpb.HealthCheckRequest{})
+	if err != nil {
+		t.Fatalf("Check() failed: %v", err)
+	}
+	return resp.Status
+}
+
+func TestReadErrorMonitor(t *testing.T) {
+	h := newHealthReporter()
+	if got := healthStatus(t, h); got != healthpb.HealthCheckResponse_NOT_SERVING {
+		t.Errorf("Initial status = %v, want NOT_SERVING", got)
+	}
+	h.SetServing(true)
+
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&logs, nil))
+	m := &readErrorMonitor{health: h, logger: logger, threshold: 2, interval: time.Second}
+	steps := []struct {
+		errors int
+		want   healthpb.HealthCheckResponse_ServingStatus
+	}{
+		{1, healthpb.HealthCheckResponse_SERVING},
+		{2, healthpb.HealthCheckResponse_NOT_SERVING},
+		{0, healthpb.HealthCheckResponse_SERVING},
+	}
+
+	serving := true
+	for i, step := range steps {
+		for j := 0; j < step.errors; j++ {
+			m.Record()
+		}
+		serving = m.check(serving)
+		if got := healthStatus(t, h); got != step.want {
+			t.Errorf("Step %d: status after %d errors = %v, want %v", i, step.errors, got, step.want)
+		}
+	}
+
+	if !strings.Contains(logs.String(), "reporting NOT_SERVING\" errors=2") || !strings.Contains(logs.String(), "reporting SERVING") {
+		t.Errorf("Status changes were not logged:\n%s", logs.String())
+	}
+
+	// Shutdown is final
+	h.Shutdown()
+	m.check(false)
+	if got := healthStatus(t, h); got != healthpb.HealthCheckResponse_NOT_SERVING {
+		t.Errorf("Status after Shutdown() = %v, want NOT_SERVING", got)
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"image"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// decodedCacheLookups counts decoded image cache lookups by result
+var decodedCacheLookups = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "cat_photos_decoded_cache_lookups_total",
+		Help: "Decoded image cache lookups, by result: hit or miss",
+	},
+	[]string{"result"},
+)
+
+// imageCache is an LRU cache of decoded photos, so photos requested at
+// different widths are decoded once. It is bounded by the number of images
+// and by the approximate pixel memory. Images are stored with the hex
+// SHA-256 of the photo data, so a replaced photo is not served from the
+// cache.
+type imageCache struct {
+	lru *lruCache[photoKey, image.Image]
+}
+
+func newImageCache(maxImages int, maxBytes int64) *imageCache {
+	lru := newLRUCache[photoKey](maxBytes, imageSize, decodedCacheLookups)
+	lru.maxEntries = maxImages
+	return &imageCache{lru: lru}
+}
+
+// imageSize approximates the memory used by img as 4 bytes per pixel
+func imageSize(img image.Image) int64 {
+	bounds := img.Bounds()
+	return int64(bounds.Dx()) * int64(bounds.Dy()) * 4
+}
+
+// Get returns the image decoded from photo data with SHA-256 hash
+func (c *imageCache) Get(catID, photoID uint64, hash string) (image.Image, bool) {
+	return c.lru.Get(photoKey{catID: catID, photoID: photoID}, hash)
+}
+
+// Add caches img decoded from photo data with SHA-256 hash, evicting least
+// recently used images to stay within the limits. Images larger than the
+// memory limit are not cached.
+func (c *imageCache) Add(catID, photoID uint64, hash string, img image.Image) {
+	c.lru.Add(photoKey{catID: catID, photoID: photoID}, hash, img)
+}
+
+// Remove drops a photo from the cache
+func (c *imageCache) Remove(catID, photoID uint64) {
+	c.lru.Remove(photoKey{catID: catID, photoID: photoID})
+}
+
+// RemoveCat drops all photos of a cat from the cache
+func (c *imageCache) RemoveCat(catID uint64) {
+	c.lru.RemoveFunc(func(key photoKey) bool {
+		return key.catID == catID
+	})
+}
+
+// EnableDecodedCache makes the server keep up to maxImages decoded photos
+// using about maxBytes of memory for scaling
+func (s *CatPhotosServer) EnableDecodedCache(maxImages int, maxBytes int64) {
+	s.decoded = newImageCache(maxImages, maxBytes)
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"image"
+	"testing"
+)
+
+// newTestImage returns an image using 4*width*height cache bytes
+func newTestImage(width, height int) image.Image {
+	return image.NewRGBA(image.Rect(0, 0, width, height))
+}
+
+func TestImageCache(t *testing.T) {
+	tests := []struct {
+		name      string
+		maxImages int
+		maxBytes  int64
+		add       []uint64 // Photo IDs of cat 1 added in order, 10x10 images
+		get       uint64   // Photo ID read after the second add
+		want      []uint64 // Cached photo IDs
+	}{
+		{"count limit", 2, 1 << 20, []uint64{1, 2, 3}, 0, []uint64{2, 3}},
+		{"bytes limit", 10, 800, []uint64{1, 2, 3}, 0, []uint64{2, 3}},
+		{"lru order", 2, 1 << 20, []uint64{1, 2, 3}, 1, []uint64{1, 3}},
+		{"too large", 10, 399, []uint64{1}, 0, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newImageCache(tt.maxImages, tt.maxBytes)
+			for i, photoID := range tt.add {
+				c.Add(1, photoID, "h", newTestImage(10, 10))
+				if i == 1 && tt.get != 0 {
+					c.Get(1, tt.get, "h")
+				}
+			}
+
+			cached := make(map[uint64]bool)
+			for _, photoID := range tt.want {
+				cached[photoID] = true
+			}
+			for _, photoID := range tt.add {
+				if _, ok := c.Get(1, photoID, "h"); ok != cached[photoID] {
+					t.Errorf("Get(1, %d) found = %v, want %v", photoID, ok, cached[photoID])
+				}
+			}
+			if c.lru.size != int64(len(tt.want))*400 {
+				t.Errorf("bytes = %d, want %d", c.lru.size, len(tt.want)*400)
+			}
+		})
+	}
+}
+
+func TestImageCache_Remove(t *testing.T) {
+	c := newImageCache(10, 1<<20)
+	c.Add(1, 1, "h", newTestImage(10, 10))
+	c.Add(1, 2, "h", newTestImage(10, 10))
+	c.Add(2, 1, "h", newTestImage(10, 10))
+
+	c.Remove(1, 1)
+	if _, ok := c.Get(1, 1, "h"); ok {
+		t.Errorf("Get(1, 1) found a removed photo")
+	}
+
+	c.RemoveCat(1)
+	if _, ok := c.Get(1, 2, "h"); ok {
+		t.Errorf("Get(1, 2) found a photo of a removed cat")
+	}
+	if _, ok := c.Get(2, 1, "h"); !ok {
+		t.Errorf("Get(2, 1) did not find a photo of another cat")
+	}
+	if c.lru.size != 400 {
+		t.Errorf("bytes = %d, want 400", c.lru.size)
+	}
+}
+
+func TestImageCache_ReplacedPhoto(t *testing.T) {
+	c := newImageCache(10, 1<<20)
+	c.Add(1, 1, "old", newTestImage(10, 10))
+
+	if _, ok := c.Get(1, 1, "new"); ok {
+		t.Errorf("Get(1, 1) found an image of a replaced photo")
+	}
+	if _, ok := c.Get(1, 1, "old"); ok {
+		t.Errorf("Get(1, 1) found an image dropped as replaced")
+	}
+}
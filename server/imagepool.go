@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"sync"
+)
+
+// imagePool reuses the encode buffers, PNG encoder state and scaled image
+// pixels of scaling requests, which are garbage once the scaled photo is
+// encoded, to reduce allocations and GC pressure. Buffers over maxBytes are
+// not kept, so a few huge photos do not pin memory. Methods of a nil pool
+// allocate.
+type imagePool struct {
+	maxBytes int
+	buffers  sync.Pool // *bytes.Buffer
+	pixels   sync.Pool // *[]uint8
+	png      pngBufferPool
+}
+
+// scalingImagePool is used to scale and encode photos, nil unless enabled
+// with enableImagePool
+var scalingImagePool *imagePool
+
+// enableImagePool makes scaling reuse buffers and images of up to maxBytes,
+// it must be called before serving
+func enableImagePool(maxBytes int) {
+	scalingImagePool = &imagePool{maxBytes: maxBytes}
+}
+
+// getBuffer returns an empty buffer
+func (p *imagePool) getBuffer() *bytes.Buffer {
+	if p != nil {
+		if buf, ok := p.buffers.Get().(*bytes.Buffer); ok {
+			buf.Reset()
+			return buf
+		}
+	}
+	return new(bytes.Buffer)
+}
+
+// putBuffer returns buf to the pool, its content must not be used after
+func (p *imagePool) putBuffer(buf *bytes.Buffer) {
+	if p != nil && buf.Cap() <= p.maxBytes {
+		p.buffers.Put(buf)
+	}
+}
+
+// pngEncoder returns a PNG encoder reusing its compression state
+func (p *imagePool) pngEncoder() *png.Encoder {
+	if p == nil {
+		return &png.Encoder{}
+	}
+	return &png.Encoder{BufferPool: &p.png}
+}
+
+// newRGBA returns a transparent black image with bounds r, reusing pooled
+// pixels if they are large enough
+func (p *imagePool) newRGBA(r image.Rectangle) *image.RGBA {
+	if p == nil {
+		return image.NewRGBA(r)
+	}
+	size := 4 * r.Dx() * r.Dy()
+	pix, ok := p.pixels.Get().(*[]uint8)
+	if !ok || cap(*pix) < size {
+		// Smaller pixels are dropped, the pool converges to the usual sizes
+		return image.NewRGBA(r)
+	}
+	buf := (*pix)[:size]
+	clear(buf)
+	return &image.RGBA{Pix: buf, Stride: 4 * r.Dx(), Rect: r}
+}
+
+// putRGBA returns the pixels of img to the pool, img must not be used after
+func (p *imagePool) putRGBA(img *image.RGBA) {
+	if p != nil && cap(img.Pix) <= p.maxBytes {
+		pix := img.Pix
+		p.pixels.Put(&pix)
+	}
+}
+
+// pngBufferPool implements png.EncoderBufferPool
+type pngBufferPool struct {
+	pool sync.Pool
+}
+
+func (p *pngBufferPool) Get() *png.EncoderBuffer {
+	buf, _ := p.pool.Get().(*png.EncoderBuffer)
+	return buf
+}
+
+func (p *pngBufferPool) Put(buf *png.EncoderBuffer) {
+	p.pool.Put(buf)
+}
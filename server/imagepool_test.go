@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"testing"
+
+	pb "github.com/mhbvr/manul/proto"
+)
+
+func TestImagePool_NewRGBACleared(t *testing.T) {
+	p := &imagePool{maxBytes: 1 << 20}
+	img := p.newRGBA(image.Rect(0, 0, 20, 10))
+	for i := range img.Pix {
+		img.Pix[i] = 0xff
+	}
+	p.putRGBA(img)
+
+	// Pooled pixels are reused if large enough, the image must be cleared
+	got := p.newRGBA(image.Rect(0, 0, 10, 10))
+	if got.Bounds() != image.Rect(0, 0, 10, 10) || got.Stride != 40 || len(got.Pix) != 400 {
+		t.Fatalf("newRGBA() = bounds %v, stride %d, %d pixel bytes, want 10x10", got.Bounds(), got.Stride, len(got.Pix))
+	}
+	for i, v := range got.Pix {
+		if v != 0 {
+			t.Fatalf("newRGBA() pixel byte %d = %d, want 0", i, v)
+		}
+	}
+}
+
+func TestImagePool_LargeNotPooled(t *testing.T) {
+	p := &imagePool{maxBytes: 100}
+	p.putRGBA(image.NewRGBA(image.Rect(0, 0, 10, 10)))
+	if pix := p.pixels.Get(); pix != nil {
+		t.Errorf("putRGBA() pooled %d bytes, want at most 100", cap(*pix.(*[]uint8)))
+	}
+
+	p.putBuffer(bytes.NewBuffer(make([]byte, 0, 200)))
+	if buf := p.buffers.Get(); buf != nil {
+		t.Errorf("putBuffer() pooled %d bytes, want at most 100", buf.(*bytes.Buffer).Cap())
+	}
+}
+
+func TestEncodeImage_Pooled(t *testing.T) {
+	defer func(p *imagePool) { scalingImagePool = p }(scalingImagePool)
+	enableImagePool(1 << 20)
+
+	img := image.NewRGBA(image.Rect(0, 0, 20, 10))
+	first, err := encodeImage(img, pb.OutputFormat_PNG, 0)
+	if err != nil {
+		t.Fatalf("encodeImage() failed: %v", err)
+	}
+	want := bytes.Clone(first)
+
+	// Encoding again reuses the buffer, earlier results must not change
+	if _, err := encodeImage(image.NewRGBA(image.Rect(0, 0, 30, 30)), pb.OutputFormat_JPEG, 0); err != nil {
+		t.Fatalf("encodeImage() failed: %v", err)
+	}
+	if !bytes.Equal(first, want) {
+		t.Errorf("encodeImage() result changed by a later encode")
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"net/http"
+	"runtime"
+	runtimedebug "runtime/debug"
+	"strings"
+)
+
+// secretFlagWords mark flags whose values are redacted in /info
+var secretFlagWords = []string{"token", "secret", "password", "auth", "credential"}
+
+const redacted = "REDACTED"
+
+// serverInfo is the /info response: the effective configuration and build
+type serverInfo struct {
+	Flags      map[string]string `json:"flags"`
+	FiletreeIO map[string]string `json:"filetree_io,omitempty"` // Active IO mode of filetree databases, by flag
+	GoVersion  string            `json:"go_version"`
+	Module     string            `json:"module,omitempty"`
+	Version    string            `json:"version,omitempty"`
+	Revision   string            `json:"revision,omitempty"`
+	BuildTime  string            `json:"build_time,omitempty"`
+	Modified   bool              `json:"modified,omitempty"`
+}
+
+// isSecretFlag reports whether the value of flag name must not be shown
+func isSecretFlag(name string) bool {
+	name = strings.ToLower(name)
+	for _, word := range secretFlagWords {
+		if strings.Contains(name, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// collectInfo returns the values of all flags in fs, set or default, and
+// the build info embedded in the binary
+func collectInfo(fs *flag.FlagSet) serverInfo {
+	info := serverInfo{
+		Flags:     make(map[string]string),
+		GoVersion: runtime.Version(),
+	}
+
+	fs.VisitAll(func(f *flag.Flag) {
+		value := f.Value.String()
+		if isSecretFlag(f.Name) && value != "" {
+			value = redacted
+		}
+		info.Flags[f.Name] = value
+	})
+
+	if build, ok := runtimedebug.ReadBuildInfo(); ok {
+		info.Module = build.Main.Path
+		info.Version = build.Main.Version
+		for _, setting := range build.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				info.Revision = setting.Value
+			case "vcs.time":
+				info.BuildTime = setting.Value
+			case "vcs.modified":
+				info.Modified = setting.Value == "true"
+			}
+		}
+	}
+	return info
+}
+
+// infoHandler serves the configuration of s and the build info as JSON
+func infoHandler(s *CatPhotosServer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		info := collectInfo(flag.CommandLine)
+		if len(s.filetreeIO) > 0 {
+			info.FiletreeIO = s.filetreeIO
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(info); err != nil {
+			s.logger.Error("Error writing /info response", "error", err)
+		}
+	}
+}
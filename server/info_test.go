@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+
+	"github.com/mhbvr/manul/db/filetree"
+)
+
+func TestCollectInfo(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.String("db", "/data/cats.db", "")
+	fs.String("auth-token", "", "")
+	fs.String("api-secret", "", "")
+	fs.Int("warm-cache-keys", 100, "")
+	if err := fs.Parse([]string{"-api-secret=hunter2"}); err != nil {
+		t.Fatalf("Parse() failed: %v", err)
+	}
+
+	info := collectInfo(fs)
+	want := map[string]string{
+		"db":              "/data/cats.db",
+		"auth-token":      "", // Unset secrets are shown as empty
+		"api-secret":      redacted,
+		"warm-cache-keys": "100",
+	}
+	for name, value := range want {
+		if got := info.Flags[name]; got != value {
+			t.Errorf("Flag %s = %q, want %q", name, got, value)
+		}
+	}
+	if info.GoVersion != runtime.Version() {
+		t.Errorf("GoVersion = %q, want %q", info.GoVersion, runtime.Version())
+	}
+}
+
+func TestInfoHandler_FiletreeIO(t *testing.T) {
+	dir := t.TempDir()
+	db, err := filetree.New(dir)
+	if err != nil {
+		t.Fatalf("filetree.New() failed: %v", err)
+	}
+	db.Close()
+
+	s, err := NewCatPhotosServer(dir, "filetree", "", "", 0, false, 0, nil, WithFiletreeIO(filetree.IOBuffered))
+	if err != nil {
+		t.Fatalf("NewCatPhotosServer() failed: %v", err)
+	}
+	defer s.Close()
+
+	rec := httptest.NewRecorder()
+	infoHandler(s)(rec, httptest.NewRequest(http.MethodGet, "/info", nil))
+
+	var info serverInfo
+	if err := json.Unmarshal(rec.Body.Bytes(), &info); err != nil {
+		t.Fatalf("Failed to parse /info response: %v", err)
+	}
+	if got := info.FiletreeIO["db"]; got != string(filetree.IOBuffered) {
+		t.Errorf("/info filetree_io db = %q, want %q", got, filetree.IOBuffered)
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// listingCacheLookups counts photo listing cache lookups by result
+var listingCacheLookups = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "cat_photos_listing_cache_lookups_total",
+		Help: "Photo listing cache lookups, by result: hit, miss or expired",
+	},
+	[]string{"result"},
+)
+
+// listingCacheIDs is the number of photo IDs in the listing cache
+var listingCacheIDs = promauto.NewGauge(
+	prometheus.GaugeOpts{
+		Name: "cat_photos_listing_cache_ids",
+		Help: "Number of photo IDs held by the photo listing cache",
+	},
+)
+
+// listingCache is an LRU cache of the photo IDs of cats, so listing pages
+// of popular cats does not scan the database every time. It is bounded by
+// the number of cached IDs. Entries expire after ttl, for changes made by
+// other processes, and are removed when the server deletes photos.
+type listingCache struct {
+	lru *lruCache[uint64, []uint64]
+}
+
+func newListingCache(ttl time.Duration, maxIDs int) *listingCache {
+	size := func(photoIDs []uint64) int64 { return int64(len(photoIDs)) }
+	lru := newLRUCache[uint64](int64(maxIDs), size, listingCacheLookups)
+	lru.ttl = ttl
+	lru.sizeGauge = listingCacheIDs
+	return &listingCache{lru: lru}
+}
+
+// Get returns the sorted photo IDs of a cat, calling load on a miss.
+// Concurrent misses of the same cat call load once, see
+// lruCache.GetOrLoad. The result must not be modified.
+func (c *listingCache) Get(ctx context.Context, catID uint64, load func() ([]uint64, error)) ([]uint64, error) {
+	return c.lru.GetOrLoad(ctx, catID, "", func(context.Context) ([]uint64, error) {
+		photoIDs, err := load()
+		if err == nil && !slices.IsSorted(photoIDs) {
+			slices.Sort(photoIDs)
+		}
+		return photoIDs, err
+	})
+}
+
+// Remove drops the listing of a cat, also one being loaded
+func (c *listingCache) Remove(catID uint64) {
+	c.lru.Remove(catID)
+}
+
+// listingPage returns up to limit photo IDs >= start of sorted photoIDs
+func listingPage(photoIDs []uint64, start uint64, limit int) []uint64 {
+	i, _ := slices.BinarySearch(photoIDs, start)
+	return photoIDs[i:min(i+limit, len(photoIDs))]
+}
+
+// EnableListingCache makes the server cache the photo IDs of cats for ttl,
+// up to maxIDs photo IDs in total
+func (s *CatPhotosServer) EnableListingCache(ttl time.Duration, maxIDs int) {
+	s.listings = newListingCache(ttl, maxIDs)
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	pb "github.com/mhbvr/manul/proto"
+)
+
+func TestListingCache_Get(t *testing.T) {
+	c := newListingCache(time.Hour, 10)
+	loads := 0
+	load := func() ([]uint64, error) {
+		loads++
+		return []uint64{3, 1, 2}, nil
+	}
+
+	for i := 0; i < 2; i++ {
+		if ids, err := c.Get(context.Background(), 1, load); err != nil || !slices.Equal(ids, []uint64{1, 2, 3}) {
+			t.Fatalf("Get() = %v, %v, want [1 2 3]", ids, err)
+		}
+	}
+	if loads != 1 {
+		t.Errorf("load called %d times, want 1", loads)
+	}
+
+	// A removed cat is loaded again
+	c.Remove(1)
+	c.Get(context.Background(), 1, load)
+	if loads != 2 {
+		t.Errorf("load called %d times, want 2", loads)
+	}
+}
+
+func TestListingCache_Expiry(t *testing.T) {
+	c := newListingCache(time.Millisecond, 10)
+	loads := 0
+	load := func() ([]uint64, error) {
+		loads++
+		return []uint64{1}, nil
+	}
+
+	c.Get(context.Background(), 1, load)
+	time.Sleep(5 * time.Millisecond)
+	c.Get(context.Background(), 1, load)
+	if loads != 2 {
+		t.Errorf("load called %d times, want 2 after expiry", loads)
+	}
+}
+
+func TestListingCache_Eviction(t *testing.T) {
+	c := newListingCache(time.Hour, 4)
+	load := func() ([]uint64, error) { return []uint64{1, 2}, nil }
+	for catID := uint64(1); catID <= 3; catID++ {
+		c.Get(context.Background(), catID, load)
+	}
+
+	if c.lru.size != 4 || c.lru.order.Len() != 2 {
+		t.Errorf("Cache has %d cats, %d IDs, want 2 cats, 4 IDs", c.lru.order.Len(), c.lru.size)
+	}
+	if _, ok := c.lru.entries[1]; ok {
+		t.Errorf("Least recently used cat was not evicted")
+	}
+
+	// Cats over the limit and failed loads are not cached
+	c.Get(context.Background(), 4, func() ([]uint64, error) { return []uint64{1, 2, 3, 4, 5}, nil })
+	c.Get(context.Background(), 5, func() ([]uint64, error) { return nil, errors.New("failed") })
+	for _, catID := range []uint64{4, 5} {
+		if _, ok := c.lru.entries[catID]; ok {
+			t.Errorf("Cat %d was cached", catID)
+		}
+	}
+}
+
+func TestListingPage(t *testing.T) {
+	ids := []uint64{1, 3, 5, 7}
+	tests := []struct {
+		start uint64
+		limit int
+		want  []uint64
+	}{
+		{0, 2, []uint64{1, 3}},
+		{3, 2, []uint64{3, 5}},
+		{4, 10, []uint64{5, 7}},
+		{8, 2, []uint64{}},
+	}
+	for _, tt := range tests {
+		if got := listingPage(ids, tt.start, tt.limit); !slices.Equal(got, tt.want) {
+			t.Errorf("listingPage(%d, %d) = %v, want %v", tt.start, tt.limit, got, tt.want)
+		}
+	}
+}
+
+func TestListPhotos_ListingCache(t *testing.T) {
+	s := newTestServer(t, true)
+	s.EnableListingCache(time.Hour, 100)
+	ctx := context.Background()
+
+	first, err := s.ListPhotos(ctx, &pb.ListPhotosRequest{CatId: 1, PageSize: 1})
+	if err != nil {
+		t.Fatalf("ListPhotos() failed: %v", err)
+	}
+	second, err := s.ListPhotos(ctx, &pb.ListPhotosRequest{CatId: 1, PageSize: 1, PageToken: first.NextPageToken})
+	if err != nil {
+		t.Fatalf("ListPhotos() failed: %v", err)
+	}
+	if !slices.Equal(first.PhotoIds, []uint64{1}) || !slices.Equal(second.PhotoIds, []uint64{2}) || second.NextPageToken != "" {
+		t.Errorf("ListPhotos() pages = %v, %v, want [1], [2]", first.PhotoIds, second.PhotoIds)
+	}
+
+	// Deleted photos are not listed from the cache
+	_, err = s.DeletePhotos(ctx, &pb.DeletePhotosRequest{PhotoRequests: []*pb.PhotoRequest{{CatId: 1, PhotoId: 1}}})
+	if err != nil {
+		t.Fatalf("DeletePhotos() failed: %v", err)
+	}
+	resp, err := s.ListPhotos(ctx, &pb.ListPhotosRequest{CatId: 1})
+	if err != nil || !slices.Equal(resp.PhotoIds, []uint64{2}) {
+		t.Errorf("ListPhotos() after delete = %v, %v, want [2]", resp.GetPhotoIds(), err)
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+// newLogger creates a logger writing to w in format text or json, with
+// records below level dropped
+func newLogger(w io.Writer, format, level string) (*slog.Logger, error) {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return nil, fmt.Errorf("invalid log level %q (use debug, info, warn or error)", level)
+	}
+
+	opts := &slog.HandlerOptions{Level: lvl}
+	switch format {
+	case "text":
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	}
+	return nil, fmt.Errorf("invalid log format %q (use text or json)", format)
+}
+
+// fatal logs msg with args at error level and exits
+func fatal(logger *slog.Logger, msg string, args ...any) {
+	logger.Error(msg, args...)
+	os.Exit(1)
+}
+
+// debugUnaryServerInterceptor logs all unary gRPC method calls at debug level
+func debugUnaryServerInterceptor(logger *slog.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		logger.DebugContext(ctx, "gRPC unary request", "method", info.FullMethod, "req", req)
+
+		resp, err := handler(ctx, req)
+		duration := time.Since(start)
+
+		if err != nil {
+			logger.DebugContext(ctx, "gRPC unary response", "method", info.FullMethod, "duration", duration, "error", err)
+		} else {
+			logger.DebugContext(ctx, "gRPC unary response", "method", info.FullMethod, "duration", duration)
+		}
+
+		return resp, err
+	}
+}
+
+// wrappedServerStream wraps grpc.ServerStream to intercept RecvMsg and SendMsg calls
+type wrappedServerStream struct {
+	grpc.ServerStream
+	method string
+	logger *slog.Logger
+}
+
+func (w *wrappedServerStream) RecvMsg(m interface{}) error {
+	err := w.ServerStream.RecvMsg(m)
+	if err != nil {
+		w.logger.DebugContext(w.Context(), "gRPC stream RecvMsg", "method", w.method, "error", err)
+	} else {
+		w.logger.DebugContext(w.Context(), "gRPC stream RecvMsg", "method", w.method, "msg", m)
+	}
+	return err
+}
+
+func (w *wrappedServerStream) SendMsg(m interface{}) error {
+	return w.ServerStream.SendMsg(m)
+}
+
+// debugStreamServerInterceptor logs all streaming gRPC method calls at debug level
+func debugStreamServerInterceptor(logger *slog.Logger) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		logger.DebugContext(ss.Context(), "gRPC stream start", "method", info.FullMethod)
+
+		// Wrap the stream to log all RecvMsg and SendMsg calls
+		wrappedStream := &wrappedServerStream{
+			ServerStream: ss,
+			method:       info.FullMethod,
+			logger:       logger,
+		}
+
+		err := handler(srv, wrappedStream)
+		duration := time.Since(start)
+
+		if err != nil {
+			logger.DebugContext(ss.Context(), "gRPC stream end", "method", info.FullMethod, "duration", duration, "error", err)
+		} else {
+			logger.DebugContext(ss.Context(), "gRPC stream end", "method", info.FullMethod, "duration", duration)
+		}
+
+		return err
+	}
+}
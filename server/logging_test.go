@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc"
+)
+
+func TestNewLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := newLogger(&buf, "json", "warn")
+	if err != nil {
+		t.Fatalf("newLogger() failed: %v", err)
+	}
+	logger.Info("dropped")
+	logger.Warn("kept", "cat_id", 1)
+
+	var record map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("Log output %q is not a single JSON record: %v", buf.String(), err)
+	}
+	if record["msg"] != "kept" || record["cat_id"] != 1.0 {
+		t.Errorf("Logged record = %v, want msg=kept cat_id=1", record)
+	}
+
+	for _, tt := range []struct{ format, level string }{{"xml", "info"}, {"text", "verbose"}} {
+		if _, err := newLogger(&buf, tt.format, tt.level); err == nil {
+			t.Errorf("newLogger(%q, %q) succeeded, want error", tt.format, tt.level)
+		}
+	}
+}
+
+func TestDebugUnaryServerInterceptor(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := newLogger(&buf, "text", "debug")
+	if err != nil {
+		t.Fatalf("newLogger() failed: %v", err)
+	}
+	interceptor := debugUnaryServerInterceptor(logger)
+	info := &grpc.UnaryServerInfo{FullMethod: "/CatPhotosService/GetPhoto"}
+
+	_, err = interceptor(context.Background(), "req", info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, errors.New("photo not found")
+	})
+	if err == nil {
+		t.Fatalf("Interceptor did not return the handler error")
+	}
+
+	logs := buf.String()
+	for _, want := range []string{"level=DEBUG", "method=/CatPhotosService/GetPhoto", `error="photo not found"`} {
+		if !strings.Contains(logs, want) {
+			t.Errorf("Logs do not contain %s:\n%s", want, logs)
+		}
+	}
+
+	// Nothing is logged above debug level
+	buf.Reset()
+	logger, _ = newLogger(&buf, "text", "info")
+	debugUnaryServerInterceptor(logger)(context.Background(), "req", info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, nil
+	})
+	if buf.Len() != 0 {
+		t.Errorf("Interceptor logged at info level:\n%s", buf.String())
+	}
+}
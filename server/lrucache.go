@@ -0,0 +1,203 @@
+package main
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// lruCache is an LRU cache bounded by the total size of its values and
+// optionally by the number of entries. Concurrent misses of a key are
+// loaded once. Values are stored with a version, e.g. a content hash, so
+// an entry or a load of another version is replaced instead of returned.
+type lruCache[K comparable, V any] struct {
+	mu         sync.Mutex
+	maxSize    int64
+	maxEntries int           // 0 means no limit
+	ttl        time.Duration // 0 means entries do not expire
+	sizeOf     func(V) int64
+	lookups    *prometheus.CounterVec // By result: hit, miss or expired
+	sizeGauge  prometheus.Gauge       // nil if the size is not exported
+	size       int64
+	order      *list.List // Most recently used first
+	entries    map[K]*list.Element
+	loading    map[K]*lruLoad[V]
+}
+
+type lruEntry[K comparable, V any] struct {
+	key     K
+	version string
+	value   V
+	size    int64
+	expires time.Time
+}
+
+// lruLoad is a value being loaded for a cache miss, waited for by
+// concurrent lookups of the same key
+type lruLoad[V any] struct {
+	done    chan struct{}
+	version string
+	value   V
+	err     error
+}
+
+func newLRUCache[K comparable, V any](maxSize int64, sizeOf func(V) int64, lookups *prometheus.CounterVec) *lruCache[K, V] {
+	return &lruCache[K, V]{
+		maxSize: maxSize,
+		sizeOf:  sizeOf,
+		lookups: lookups,
+		order:   list.New(),
+		entries: make(map[K]*list.Element),
+		loading: make(map[K]*lruLoad[V]),
+	}
+}
+
+// Get returns the value cached for key with version
+func (c *lruCache[K, V]) Get(key K, version string) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.get(key, version)
+}
+
+func (c *lruCache[K, V]) get(key K, version string) (V, bool) {
+	var zero V
+	elem, ok := c.entries[key]
+	if !ok {
+		c.lookups.WithLabelValues("miss").Inc()
+		return zero, false
+	}
+
+	entry := elem.Value.(*lruEntry[K, V])
+	switch {
+	case c.ttl > 0 && !time.Now().Before(entry.expires):
+		c.lookups.WithLabelValues("expired").Inc()
+	case entry.version != version:
+		// The stored value has changed
+		c.lookups.WithLabelValues("miss").Inc()
+	default:
+		c.lookups.WithLabelValues("hit").Inc()
+		c.order.MoveToFront(elem)
+		return entry.value, true
+	}
+	c.removeElement(elem)
+	return zero, false
+}
+
+// GetOrLoad returns the value cached for key with version, calling load on
+// a miss. Concurrent misses of the same key call load once. The load is
+// not canceled with the ctx of the lookup starting it, since other lookups
+// wait for it; each lookup stops waiting when its ctx is done.
+func (c *lruCache[K, V]) GetOrLoad(ctx context.Context, key K, version string, load func(ctx context.Context) (V, error)) (V, error) {
+	c.mu.Lock()
+	if value, ok := c.get(key, version); ok {
+		c.mu.Unlock()
+		return value, nil
+	}
+
+	l, ok := c.loading[key]
+	if !ok || l.version != version {
+		l = &lruLoad[V]{done: make(chan struct{}), version: version}
+		c.loading[key] = l
+		go c.load(context.WithoutCancel(ctx), key, l, load)
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-l.done:
+		return l.value, l.err
+	case <-ctx.Done():
+		var zero V
+		return zero, ctx.Err()
+	}
+}
+
+// load calls load for a cache miss and caches its result
+func (c *lruCache[K, V]) load(ctx context.Context, key K, l *lruLoad[V], load func(ctx context.Context) (V, error)) {
+	l.value, l.err = load(ctx)
+
+	c.mu.Lock()
+	// A value removed while loading may predate a change, it is not cached
+	if c.loading[key] == l {
+		delete(c.loading, key)
+		if l.err == nil {
+			c.add(key, l.version, l.value)
+		}
+	}
+	c.mu.Unlock()
+	close(l.done)
+}
+
+// Add caches value for key with version
+func (c *lruCache[K, V]) Add(key K, version string, value V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.add(key, version, value)
+}
+
+// add caches value, evicting least recently used entries to stay within
+// the limits. Values larger than the size limit are not cached.
+func (c *lruCache[K, V]) add(key K, version string, value V) {
+	size := c.sizeOf(value)
+	if size > c.maxSize {
+		return
+	}
+
+	if elem, ok := c.entries[key]; ok {
+		c.removeElement(elem)
+	}
+	for c.order.Len() > 0 && ((c.maxEntries > 0 && c.order.Len() >= c.maxEntries) || c.size+size > c.maxSize) {
+		c.removeElement(c.order.Back())
+	}
+
+	entry := &lruEntry[K, V]{key: key, version: version, value: value, size: size}
+	if c.ttl > 0 {
+		entry.expires = time.Now().Add(c.ttl)
+	}
+	c.entries[key] = c.order.PushFront(entry)
+	c.resize(size)
+}
+
+// Remove drops key from the cache, also a value being loaded
+func (c *lruCache[K, V]) Remove(key K) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[key]; ok {
+		c.removeElement(elem)
+	}
+	delete(c.loading, key)
+}
+
+// RemoveFunc drops the keys for which match returns true from the cache,
+// also values being loaded
+func (c *lruCache[K, V]) RemoveFunc(match func(key K) bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key, elem := range c.entries {
+		if match(key) {
+			c.removeElement(elem)
+		}
+	}
+	for key := range c.loading {
+		if match(key) {
+			delete(c.loading, key)
+		}
+	}
+}
+
+func (c *lruCache[K, V]) removeElement(elem *list.Element) {
+	entry := c.order.Remove(elem).(*lruEntry[K, V])
+	delete(c.entries, entry.key)
+	c.resize(-entry.size)
+}
+
+func (c *lruCache[K, V]) resize(delta int64) {
+	c.size += delta
+	if c.sizeGauge != nil {
+		c.sizeGauge.Set(float64(c.size))
+	}
+}
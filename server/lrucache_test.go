@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func newTestLRUCache(maxSize int64) *lruCache[int, string] {
+	lookups := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "test_lookups_total"}, []string{"result"})
+	return newLRUCache[int](maxSize, func(value string) int64 { return int64(len(value)) }, lookups)
+}
+
+func TestLRUCache_RemoveWhileLoading(t *testing.T) {
+	c := newTestLRUCache(10)
+	loading := make(chan struct{})
+	release := make(chan struct{})
+	load := func(context.Context) (string, error) {
+		close(loading)
+		<-release
+		return "old", nil
+	}
+
+	done := make(chan string)
+	go func() {
+		value, _ := c.GetOrLoad(context.Background(), 1, "", load)
+		done <- value
+	}()
+	<-loading
+	c.Remove(1)
+	close(release)
+
+	// The lookup waiting for the load gets its value, it is not cached
+	if value := <-done; value != "old" {
+		t.Errorf("GetOrLoad() = %q, want %q", value, "old")
+	}
+	if _, ok := c.Get(1, ""); ok {
+		t.Errorf("Value removed while loading was cached")
+	}
+}
+
+func TestLRUCache_Version(t *testing.T) {
+	c := newTestLRUCache(10)
+	c.Add(1, "v1", "a")
+
+	if value, ok := c.Get(1, "v1"); !ok || value != "a" {
+		t.Errorf("Get(v1) = %q, %v, want %q", value, ok, "a")
+	}
+	if _, ok := c.Get(1, "v2"); ok {
+		t.Errorf("Get(v2) returned a value of v1")
+	}
+	if c.order.Len() != 0 || c.size != 0 {
+		t.Errorf("Cache has %d entries, size %d after a version change, want empty", c.order.Len(), c.size)
+	}
+}
@@ -1,121 +1,142 @@
 package main
 
 import (
-	"context"
 	"flag"
 	"fmt"
 	"log"
+	"log/slog"
 	"net"
 	"net/http"
 	_ "net/http/pprof"
+	"os"
+	"os/signal"
+	"runtime"
+	"syscall"
 	"time"
 
 	grpc_prometheus "github.com/grpc-ecosystem/go-grpc-prometheus"
+	"github.com/mhbvr/manul/db/filetree"
+	"github.com/mhbvr/manul/db/s3"
 	pb "github.com/mhbvr/manul/proto"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/channelz/service"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/orca"
 )
 
-// debugUnaryServerInterceptor logs all unary gRPC method calls when debug is enabled
-func debugUnaryServerInterceptor(
-	ctx context.Context,
-	req interface{},
-	info *grpc.UnaryServerInfo,
-	handler grpc.UnaryHandler,
-) (interface{}, error) {
-	start := time.Now()
-	log.Printf("[DEBUG] gRPC unary request: method=%s req=%+v", info.FullMethod, req)
-
-	resp, err := handler(ctx, req)
-	duration := time.Since(start)
-
-	if err != nil {
-		log.Printf("[DEBUG] gRPC unary response: method=%s duration=%v error=%v", info.FullMethod, duration, err)
-	} else {
-		log.Printf("[DEBUG] gRPC unary response: method=%s duration=%v", info.FullMethod, duration)
-	}
-
-	return resp, err
-}
+var (
+	host                    = flag.String("host", "localhost", "Server host")
+	port                    = flag.Int("port", 8081, "Server port")
+	metricsPort             = flag.Int("metrics-port", 8082, "Prometheus metrics port")
+	metricsAddr             = flag.String("metrics-addr", "", "Metrics server host:port, e.g. on a management interface separate from -host (empty = -host with -metrics-port)")
+	noMetrics               = flag.Bool("no-metrics", false, "Disable the metrics server, which also serves /info, /tracez and pprof")
+	dbPath                  = flag.String("db", "", "Database path (directory for filetree/s3, file for bolt/pebble/sqlite)")
+	dbType                  = flag.String("db-type", "filetree", "Database type: filetree, bolt, pebble, sqlite, or s3 (filetree meta file in -db, photo data in -s3-bucket)")
+	s3Bucket                = flag.String("s3-bucket", "", "S3 bucket of photo data for -db-type=s3, credentials are read from AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN (unset = anonymous)")
+	s3Prefix                = flag.String("s3-prefix", "", "Key prefix of photo objects in -s3-bucket")
+	s3Endpoint              = flag.String("s3-endpoint", "", "S3 endpoint URL, e.g. for MinIO (empty = AWS endpoint of -s3-region)")
+	s3Region                = flag.String("s3-region", "us-east-1", "S3 region")
+	s3RequestTimeout        = flag.Duration("s3-request-timeout", 10*time.Second, "Max time of a single S3 request attempt, failed attempts are retried")
+	filetreeIO              = flag.String("filetree-io", "auto", "How filetree databases read photo files: direct (O_DIRECT), buffered, or auto (direct if supported, probed at open)")
+	cacheDBPath             = flag.String("cache-db", "", "Cache database path, a fast tier populated on reads from -db (empty = disabled)")
+	cacheDBType             = flag.String("cache-db-type", "pebble", "Cache database type: filetree, bolt, pebble, or sqlite")
+	shadowDBPath            = flag.String("shadow-db", "", "Shadow database path, photos read from -db are also read from it in the background and compared by checksum, mismatches are logged (empty = disabled)")
+	shadowDBType            = flag.String("shadow-db-type", "pebble", "Shadow database type: filetree, bolt, pebble, or sqlite")
+	readWrite               = flag.Bool("read-write", false, "Open the database for writing, enables delete RPCs")
+	dbOpenTimeout           = flag.Duration("db-open-timeout", 10*time.Second, "Max time to wait for the database lock held by another process (0 = wait forever)")
+	orcaEnabled             = flag.Bool("orca", false, "Enable ORCA load reporting")
+	orcaUpdateInterval      = flag.Duration("orca-update-interval", 1*time.Second, "Max interval between utilization updates for ORCA reporting")
+	orcaReportEvery         = flag.Int("orca-report-every-n-requests", 0, "Update ORCA utilization after this many requests, or after -orca-update-interval if it ends first (0 = only every -orca-update-interval)")
+	orcaMemLimit            = flag.Int64("orca-mem-limit", 0, "Heap bytes reported as full ORCA memory utilization (0 = memory utilization not reported)")
+	maxConcurrentReads      = flag.Int("max-concurrent-reads", 0, "Maximum number of concurrent database reads (0 = unlimited), their occupancy is reported as ORCA application utilization")
+	readLimiterMode         = flag.String("read-limiter-mode", "block", "What reads do when all -max-concurrent-reads slots are taken: block (wait for a slot) or reject (fail with RESOURCE_EXHAUSTED)")
+	debug                   = flag.Bool("debug", false, "Enable debug logging for all gRPC requests, implies -log-level=debug")
+	logLevel                = flag.String("log-level", "info", "Minimum level of logged messages: debug, info, warn, or error")
+	logFormat               = flag.String("log-format", "text", "Log format: text or json")
+	tracing                 = flag.Bool("tracing", false, "Enable OpenTelemetry tracing, traces are served at /tracez on the metrics port")
+	warmCacheThreshold      = flag.Float64("warm-cache-threshold", 0, "Read hot photos to warm the DB cache while ORCA CPU utilization is below this value (0 = disabled, requires -orca)")
+	warmCacheInterval       = flag.Duration("warm-cache-interval", 10*time.Second, "Interval between cache warming rounds")
+	warmCacheKeys           = flag.Int("warm-cache-keys", 100, "Maximum number of hot photos to read per cache warming round")
+	decodedCacheImages      = flag.Int("decoded-cache-images", 0, "Maximum number of decoded photos cached for scaling (0 = disabled)")
+	decodedCacheBytes       = flag.Int64("decoded-cache-bytes", 512<<20, "Approximate memory limit of the decoded photo cache, 4 bytes per pixel")
+	photoCacheBytes         = flag.Int64("photo-cache-bytes", 0, "Memory limit of the cache of scaled and converted photos (0 = disabled)")
+	listingCacheTTL         = flag.Duration("listing-cache-ttl", 0, "How long the photo IDs of a cat are cached for ListPhotos, changes by other processes show up after this (0 = disabled)")
+	listingCacheMaxIDs      = flag.Int("listing-cache-ids", 1000000, "Maximum number of photo IDs in the listing cache, cats with more photos are not cached")
+	healthErrorThreshold    = flag.Int("health-read-error-threshold", 0, "Report NOT_SERVING to health checks after this many photo data read errors in -health-check-interval (0 = disabled)")
+	healthCheckInterval     = flag.Duration("health-check-interval", 10*time.Second, "Interval over which photo data read errors are counted for health checks")
+	statsInterval           = flag.Duration("stats-interval", 0, "Interval between logging cat count, photo count and read limiter occupancy (0 = disabled)")
+	scalingWorkers          = flag.Int("scaling-workers", runtime.GOMAXPROCS(0), "Number of goroutines scaling photos for all requests (0 = scale on request goroutines)")
+	presetThumbWidth        = flag.Uint("preset-thumb-width", defaultThumbWidth, "Width in pixels of photos requested with the THUMB size preset")
+	presetMediumWidth       = flag.Uint("preset-medium-width", defaultMediumWidth, "Width in pixels of photos requested with the MEDIUM size preset")
+	presetQuality           = flag.Uint("preset-quality", 0, "JPEG quality 1-100 of photos requested with the THUMB and MEDIUM size presets (0 = default)")
+	maxStreamBytes          = flag.Int64("max-stream-bytes", 0, "Max total photo bytes sent by a GetPhotosStream call, the stream fails with RESOURCE_EXHAUSTED when it is exceeded (0 = no limit)")
+	imagePoolMaxBytes       = flag.Int("image-pool-max-bytes", 16<<20, "Largest encode buffer or scaled image reused across scaling requests to reduce GC pressure (0 = disabled)")
+)
 
-// wrappedServerStream wraps grpc.ServerStream to intercept RecvMsg and SendMsg calls
-type wrappedServerStream struct {
-	grpc.ServerStream
-	method string
+func init() {
+	flag.DurationVar(orcaUpdateInterval, "orca-report-interval", *orcaUpdateInterval, "Alias of -orca-update-interval")
 }
 
-func (w *wrappedServerStream) RecvMsg(m interface{}) error {
-	err := w.ServerStream.RecvMsg(m)
-	if err != nil {
-		log.Printf("[DEBUG] gRPC stream RecvMsg: method=%s error=%v", w.method, err)
-	} else {
-		log.Printf("[DEBUG] gRPC stream RecvMsg: method=%s msg=%+v", w.method, m)
+// openS3 opens the filetree meta file in metaDir reading photo data from
+// -s3-bucket, with credentials from the AWS environment variables if set
+func openS3(metaDir string, openTimeout time.Duration) (*s3.S3DB, error) {
+	opts := []s3.Option{
+		s3.WithEndpoint(*s3Endpoint),
+		s3.WithRegion(*s3Region),
+		s3.WithPrefix(*s3Prefix),
+		s3.WithTimeout(openTimeout),
+		s3.WithRequestTimeout(*s3RequestTimeout),
 	}
-	return err
-}
-
-func (w *wrappedServerStream) SendMsg(m interface{}) error {
-	return w.ServerStream.SendMsg(m)
+	if accessKeyID := os.Getenv("AWS_ACCESS_KEY_ID"); accessKeyID != "" {
+		opts = append(opts, s3.WithCredentials(accessKeyID, os.Getenv("AWS_SECRET_ACCESS_KEY"), os.Getenv("AWS_SESSION_TOKEN")))
+	}
+	return s3.NewReader(metaDir, *s3Bucket, opts...)
 }
 
-// debugStreamServerInterceptor logs all streaming gRPC method calls when debug is enabled
-func debugStreamServerInterceptor(
-	srv interface{},
-	ss grpc.ServerStream,
-	info *grpc.StreamServerInfo,
-	handler grpc.StreamHandler,
-) error {
-	start := time.Now()
-	log.Printf("[DEBUG] gRPC stream start: method=%s", info.FullMethod)
+func main() {
+	flag.Parse()
 
-	// Wrap the stream to log all RecvMsg and SendMsg calls
-	wrappedStream := &wrappedServerStream{
-		ServerStream: ss,
-		method:       info.FullMethod,
+	level := *logLevel
+	if *debug {
+		level = "debug"
 	}
-
-	err := handler(srv, wrappedStream)
-	duration := time.Since(start)
-
+	logger, err := newLogger(os.Stderr, *logFormat, level)
 	if err != nil {
-		log.Printf("[DEBUG] gRPC stream end: method=%s duration=%v error=%v", info.FullMethod, duration, err)
-	} else {
-		log.Printf("[DEBUG] gRPC stream end: method=%s duration=%v", info.FullMethod, duration)
+		log.Fatal(err)
+	}
+	// Libraries logging with the log package write through logger as well
+	slog.SetDefault(logger)
+	if *debug {
+		logger.Debug("Debug mode enabled - logging all gRPC requests")
 	}
 
-	return err
-}
+	if *dbPath == "" {
+		fatal(logger, "Database path must be specified with -db flag")
+	}
 
-var (
-	host                    = flag.String("host", "localhost", "Server host")
-	port                    = flag.Int("port", 8081, "Server port")
-	metricsPort             = flag.Int("metrics-port", 8082, "Prometheus metrics port")
-	dbPath                  = flag.String("db", "", "Database path (directory for filetree, file for bolt/pebble)")
-	dbType                  = flag.String("db-type", "filetree", "Database type: filetree, bolt, or pebble")
-	orcaEnabled             = flag.Bool("orca", false, "Enable ORCA load reporting")
-	orcaUpdateInterval      = flag.Duration("orca-update-interval", 1*time.Second, "Interval between CPU utilization updates for ORCA reporting")
-	maxConcurrentReads      = flag.Int("max-concurrent-reads", 0, "Maximum number of concurrent database reads (0 = unlimited)")
-	debug                   = flag.Bool("debug", false, "Enable debug logging for all gRPC requests")
-)
+	if *noMetrics && *metricsAddr != "" {
+		fatal(logger, "-metrics-addr cannot be used with -no-metrics")
+	}
 
-func main() {
-	flag.Parse()
+	if *readLimiterMode != "block" && *readLimiterMode != "reject" {
+		fatal(logger, "Unknown read limiter mode, use block or reject", "mode", *readLimiterMode)
+	}
 
-	if *debug {
-		log.SetFlags(log.LstdFlags | log.Lmicroseconds)
-		log.Println("Debug mode enabled - logging all gRPC requests")
+	if *presetThumbWidth == 0 || *presetMediumWidth == 0 {
+		fatal(logger, "-preset-thumb-width and -preset-medium-width must be positive")
 	}
 
-	if *dbPath == "" {
-		log.Fatal("Database path must be specified with -db flag")
+	if *warmCacheThreshold > 0 && !*orcaEnabled {
+		fatal(logger, "Cache warming requires ORCA load reporting, use -orca flag")
 	}
 
 	addr := fmt.Sprintf("%s:%d", *host, *port)
 	lis, err := net.Listen("tcp", addr)
 	if err != nil {
-		log.Fatalf("Failed to listen: %v", err)
+		fatal(logger, "Failed to listen", "addr", addr, "error", err)
 	}
 
 	// Create ORCA reporter if enabled
@@ -123,35 +144,127 @@ func main() {
 	var serverOptions []grpc.ServerOption
 
 	if *orcaEnabled {
-		orcaReporter = NewORCAReporter(*orcaUpdateInterval)
+		orcaReporter = NewORCAReporter(*orcaUpdateInterval, logger)
+		if *orcaReportEvery > 0 {
+			orcaReporter.SetReportEveryRequests(*orcaReportEvery)
+		}
+		if *orcaMemLimit > 0 {
+			orcaReporter.SetMemLimit(*orcaMemLimit)
+		}
 
 		// Add call metrics interceptor for trailer-based reporting
 		serverOptions = append(serverOptions, orca.CallMetricsServerOption(orcaReporter.GetServerMetricsProvider()))
 
-		log.Printf("ORCA load reporting enabled (CPU utilization update interval: %v)", *orcaUpdateInterval)
+		logger.Info("ORCA load reporting enabled", "update_interval", *orcaUpdateInterval, "report_every_n_requests", *orcaReportEvery, "mem_limit", *orcaMemLimit)
+	}
+
+	var tracezHandler http.Handler
+	if *tracing {
+		var cleanup func()
+		tracezHandler, cleanup, err = InitializeTracing()
+		if err != nil {
+			fatal(logger, "Failed to initialize tracing", "error", err)
+		}
+		defer cleanup()
+		serverOptions = append(serverOptions, grpc.StatsHandler(otelgrpc.NewServerHandler()))
 	}
 
 	// Build unary interceptor chain
-	unaryInterceptors := []grpc.UnaryServerInterceptor{grpc_prometheus.UnaryServerInterceptor}
+	unaryInterceptors := []grpc.UnaryServerInterceptor{grpc_prometheus.UnaryServerInterceptor, catIDUnaryServerInterceptor}
 	if *debug {
-		unaryInterceptors = append(unaryInterceptors, debugUnaryServerInterceptor)
+		unaryInterceptors = append(unaryInterceptors, debugUnaryServerInterceptor(logger))
 	}
 	serverOptions = append(serverOptions, grpc.ChainUnaryInterceptor(unaryInterceptors...))
 
 	// Build stream interceptor chain
-	streamInterceptors := []grpc.StreamServerInterceptor{grpc_prometheus.StreamServerInterceptor}
+	streamInterceptors := []grpc.StreamServerInterceptor{grpc_prometheus.StreamServerInterceptor, catIDStreamServerInterceptor}
 	if *debug {
-		streamInterceptors = append(streamInterceptors, debugStreamServerInterceptor)
+		streamInterceptors = append(streamInterceptors, debugStreamServerInterceptor(logger))
 	}
 	serverOptions = append(serverOptions, grpc.ChainStreamInterceptor(streamInterceptors...))
 
 	s := grpc.NewServer(serverOptions...)
 
-	catPhotosServer, err := NewCatPhotosServer(*dbPath, *dbType, *maxConcurrentReads, orcaReporter)
+	// Health checks report NOT_SERVING until the database is open
+	healthReporter := newHealthReporter()
+	healthpb.RegisterHealthServer(s, healthReporter.server)
+
+	filetreeIOMode, err := filetree.ParseIOMode(*filetreeIO)
+	if err != nil {
+		fatal(logger, "Invalid -filetree-io", "error", err)
+	}
+	catPhotosOptions := []Option{WithFiletreeIO(filetreeIOMode), WithLogger(logger)}
+	if *shadowDBPath != "" {
+		catPhotosOptions = append(catPhotosOptions, WithShadowDB(*shadowDBPath, *shadowDBType))
+	}
+	if *dbType == "s3" {
+		db, err := openS3(*dbPath, *dbOpenTimeout)
+		if err != nil {
+			fatal(logger, "Failed to open S3 database", "error", err)
+		}
+		catPhotosOptions = append(catPhotosOptions, WithDBReader(db))
+	}
+
+	catPhotosServer, err := NewCatPhotosServer(*dbPath, *dbType, *cacheDBPath, *cacheDBType, *dbOpenTimeout, *readWrite, *maxConcurrentReads, orcaReporter, catPhotosOptions...)
 	if err != nil {
-		log.Fatalf("Failed to create server: %v", err)
+		fatal(logger, "Failed to create server", "error", err)
 	}
 	defer catPhotosServer.Close()
+	healthReporter.SetServing(true)
+
+	if *healthErrorThreshold > 0 {
+		catPhotosServer.StartReadErrorMonitor(healthReporter, *healthErrorThreshold, *healthCheckInterval)
+		logger.Info("Read error health checks enabled", "threshold", *healthErrorThreshold, "interval", *healthCheckInterval)
+	}
+
+	catPhotosServer.SetSizePresets(uint32(*presetThumbWidth), uint32(*presetMediumWidth), uint32(*presetQuality))
+
+	if *maxStreamBytes > 0 {
+		catPhotosServer.SetMaxStreamBytes(*maxStreamBytes)
+		logger.Info("GetPhotosStream byte limit enabled", "bytes", *maxStreamBytes)
+	}
+
+	if *readLimiterMode == "reject" && *maxConcurrentReads > 0 {
+		catPhotosServer.EnableReadRejection()
+		logger.Info("Reads are rejected when all read slots are taken", "slots", *maxConcurrentReads)
+	}
+
+	if *decodedCacheImages > 0 {
+		catPhotosServer.EnableDecodedCache(*decodedCacheImages, *decodedCacheBytes)
+		logger.Info("Decoded photo cache enabled", "images", *decodedCacheImages, "bytes", *decodedCacheBytes)
+	}
+
+	if *photoCacheBytes > 0 {
+		catPhotosServer.EnablePhotoCache(*photoCacheBytes)
+		logger.Info("Scaled photo cache enabled", "bytes", *photoCacheBytes)
+	}
+
+	if *listingCacheTTL > 0 {
+		catPhotosServer.EnableListingCache(*listingCacheTTL, *listingCacheMaxIDs)
+		logger.Info("Listing cache enabled", "ttl", *listingCacheTTL, "ids", *listingCacheMaxIDs)
+	}
+
+	if *scalingWorkers > 0 {
+		catPhotosServer.EnableScalingPool(*scalingWorkers)
+		logger.Info("Scaling pool enabled", "workers", *scalingWorkers)
+	}
+
+	if *imagePoolMaxBytes > 0 {
+		enableImagePool(*imagePoolMaxBytes)
+		logger.Info("Image buffer pool enabled", "max_bytes", *imagePoolMaxBytes)
+	}
+
+	if *statsInterval > 0 {
+		catPhotosServer.StartStatsLogger(*statsInterval)
+		logger.Info("Stats logging enabled", "interval", *statsInterval)
+	}
+
+	if *warmCacheThreshold > 0 {
+		if err := catPhotosServer.StartCacheWarmer(*warmCacheThreshold, *warmCacheInterval, *warmCacheKeys); err != nil {
+			fatal(logger, "Failed to start cache warmer", "error", err)
+		}
+		logger.Info("Cache warming enabled", "cpu_threshold", *warmCacheThreshold, "interval", *warmCacheInterval, "keys", *warmCacheKeys)
+	}
 
 	pb.RegisterCatPhotosServiceServer(s, catPhotosServer)
 
@@ -161,18 +274,51 @@ func main() {
 	grpc_prometheus.Register(s)
 	grpc_prometheus.EnableHandlingTimeHistogram()
 
-	go func() {
-		metricsAddr := fmt.Sprintf("%s:%d", *host, *metricsPort)
-		http.Handle("/metrics", promhttp.Handler())
-		log.Printf("Prometheus metrics server listening on %s", metricsAddr)
-		log.Printf("pprof endpoints available at http://%s/debug/pprof/", metricsAddr)
-		if err := http.ListenAndServe(metricsAddr, nil); err != nil {
-			log.Fatalf("Failed to serve metrics: %v", err)
+	if *noMetrics {
+		logger.Info("Metrics server disabled")
+		if tracezHandler != nil {
+			logger.Warn("Traces are not served at /tracez without the metrics server")
 		}
+	} else {
+		metricsListenAddr := *metricsAddr
+		if metricsListenAddr == "" {
+			metricsListenAddr = fmt.Sprintf("%s:%d", *host, *metricsPort)
+		}
+		go func() {
+			// OpenMetrics format is required to expose exemplars
+			http.Handle("/metrics", promhttp.HandlerFor(prometheus.DefaultGatherer, promhttp.HandlerOpts{EnableOpenMetrics: true}))
+			http.Handle("/info", infoHandler(catPhotosServer))
+			if tracezHandler != nil {
+				http.Handle("/tracez", tracezHandler)
+			}
+			logger.Info("Prometheus metrics server listening", "addr", metricsListenAddr)
+			logger.Info("pprof endpoints available", "url", fmt.Sprintf("http://%s/debug/pprof/", metricsListenAddr))
+			logger.Info("Configuration and build info available", "url", fmt.Sprintf("http://%s/info", metricsListenAddr))
+			if err := http.ListenAndServe(metricsListenAddr, nil); err != nil {
+				fatal(logger, "Failed to serve metrics", "error", err)
+			}
+		}()
+	}
+
+	logger.Info("gRPC server listening", "addr", addr, "db_type", *dbType, "db", *dbPath)
+	if *cacheDBPath != "" {
+		logger.Info("Using cache database", "db_type", *cacheDBType, "db", *cacheDBPath)
+	}
+	if *shadowDBPath != "" {
+		logger.Info("Comparing photos with shadow database", "db_type", *shadowDBType, "db", *shadowDBPath)
+	}
+
+	// Drain clients on shutdown: report NOT_SERVING, then finish requests
+	go func() {
+		sigCh := make(chan os.Signal, 1)
+		signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+		sig := <-sigCh
+		logger.Info("Shutting down", "signal", sig)
+		healthReporter.Shutdown()
+		s.GracefulStop()
 	}()
 
-	log.Printf("gRPC server listening on %s (using %s database: %s)", addr, *dbType, *dbPath)
 	if err := s.Serve(lis); err != nil {
-		log.Fatalf("Failed to serve: %v", err)
+		fatal(logger, "Failed to serve", "error", err)
 	}
 }
@@ -2,30 +2,100 @@ package main
 
 import (
 	"context"
+	"log/slog"
+	"math"
+	"math/rand/v2"
 	"runtime/metrics"
+	"slices"
 	"sync"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
 	"google.golang.org/grpc/orca"
 )
 
+// orcaMemoryUtilization is the memory utilization computed on each ORCA
+// update, including intervals without requests which are not reported
+var orcaMemoryUtilization = promauto.NewGauge(
+	prometheus.GaugeOpts{
+		Name: "cat_photos_orca_memory_utilization",
+		Help: "Heap object bytes as a fraction of the ORCA memory limit, computed on each ORCA update",
+	},
+)
+
+// Named ORCA metrics with the request latency over the last update
+// interval, in milliseconds
+const (
+	orcaLatencyAvg = "latency_avg_ms"
+	orcaLatencyP50 = "latency_p50_ms"
+	orcaLatencyP95 = "latency_p95_ms"
+)
+
+// maxLatencySamples bounds the request durations kept per update interval
+// for the latency percentiles
+const maxLatencySamples = 10000
+
+// namedMetricsRecorder is implemented by the recorder of
+// orca.NewServerMetricsRecorder, named metrics are sent in the per-call
+// trailer but are not part of orca.ServerMetricsRecorder
+type namedMetricsRecorder interface {
+	SetNamedMetric(name string, val float64)
+}
+
+// latencyStats summarizes the request durations of an update interval
+type latencyStats struct {
+	avg, p50, p95 time.Duration
+}
+
 type ORCAReporter struct {
 	serverMetrics  orca.ServerMetricsRecorder
+	logger         *slog.Logger
 	mu             sync.Mutex
 	updateInterval time.Duration
 	requestCount   int
+	cpuUtilization float64
+	memLimit       int64 // Bytes of heap objects reported as full memory utilization, 0 = not reported
 	cancel         context.CancelFunc
+
+	// reportEvery requests trigger an update before updateInterval ends,
+	// 0 = updates only every updateInterval
+	reportEvery int
+	trigger     chan struct{}
+
+	// Request durations since the last update. latencySamples is a uniform
+	// sample of at most maxLatencySamples of them.
+	latencySum     time.Duration
+	latencySamples []time.Duration
+
+	// Read limiter occupancy, reported as application utilization.
+	// readTime accumulates slots in use multiplied by the time they were
+	// in use, so the reported value is the average over the interval.
+	maxReads        int
+	readsInUse      int
+	readTime        time.Duration
+	lastReadUpdate  time.Time
+	readWindowStart time.Time
 }
 
-func NewORCAReporter(updateInterval time.Duration) *ORCAReporter {
+// NewORCAReporter starts updating the reported utilization every
+// updateInterval, or earlier after the number of requests set with
+// SetReportEveryRequests. Updates are logged to logger at debug level, a nil logger
+// means slog.Default().
+func NewORCAReporter(updateInterval time.Duration, logger *slog.Logger) *ORCAReporter {
+	if logger == nil {
+		logger = slog.Default()
+	}
 	ctx, cancel := context.WithCancel(context.Background())
 	reporter := &ORCAReporter{
 		serverMetrics:  orca.NewServerMetricsRecorder(),
+		logger:         logger,
 		updateInterval: updateInterval,
 		cancel:         cancel,
+		trigger:        make(chan struct{}, 1),
 	}
 
-	// Start background goroutine to update CPU utilization
+	// Start background goroutine to update CPU and memory utilization
 	go reporter.updateCPUUtilization(ctx)
 
 	return reporter
@@ -43,6 +113,7 @@ func (o *ORCAReporter) updateCPUUtilization(ctx context.Context) {
 	samples := []metrics.Sample{
 		{Name: "/cpu/classes/user:cpu-seconds"},
 		{Name: "/cpu/classes/total:cpu-seconds"},
+		{Name: "/memory/classes/heap/objects:bytes"},
 	}
 
 	var lastUserCPU, lastTotalCPU float64
@@ -59,45 +130,204 @@ func (o *ORCAReporter) updateCPUUtilization(ctx context.Context) {
 		case <-ctx.Done():
 			return
 		case <-ticker.C:
-			// Updade utilization only if some requests were send
-			o.mu.Lock()
-			numReq := o.requestCount
-			o.requestCount = 0
-			o.mu.Unlock()
-			if numReq == 0 {
-				continue
-			}
-
-			// Read current CPU metrics
-			metrics.Read(samples)
-			userCPU := samples[0].Value.Float64()
-			totalCPU := samples[1].Value.Float64()
-
-			userCPUDelta := userCPU - lastUserCPU
-			totalCPUDelta := totalCPU - lastTotalCPU
-			lastUserCPU = userCPU
-			lastTotalCPU = totalCPU
-
-			intervalDuration := time.Since(lastTime).Seconds()
-			lastTime = time.Now()
-
-			qps := float64(numReq) / intervalDuration
-
-			var cpuUtilization float64
-			if totalCPUDelta > 0 {
-				cpuUtilization = userCPUDelta / totalCPUDelta
-			}
-
-			o.serverMetrics.SetCPUUtilization(cpuUtilization)
-			o.serverMetrics.SetQPS(qps)
+		case <-o.trigger:
+			// Start a new interval after an update triggered by requests
+			ticker.Reset(o.updateInterval)
 		}
+
+		// Read current CPU metrics
+		metrics.Read(samples)
+		userCPU := samples[0].Value.Float64()
+		totalCPU := samples[1].Value.Float64()
+
+		userCPUDelta := userCPU - lastUserCPU
+		totalCPUDelta := totalCPU - lastTotalCPU
+		lastUserCPU = userCPU
+		lastTotalCPU = totalCPU
+
+		intervalDuration := time.Since(lastTime).Seconds()
+		lastTime = time.Now()
+
+		var cpuUtilization float64
+		if totalCPUDelta > 0 {
+			cpuUtilization = userCPUDelta / totalCPUDelta
+		}
+
+		o.mu.Lock()
+		numReq := o.requestCount
+		o.requestCount = 0
+		o.cpuUtilization = cpuUtilization
+		memLimit := o.memLimit
+		maxReads := o.maxReads
+		readUtilization := o.readUtilizationLocked()
+		latency := o.latencyLocked(numReq)
+		o.mu.Unlock()
+
+		var memUtilization float64
+		if memLimit > 0 {
+			memUtilization = memoryUtilization(samples[2].Value.Uint64(), memLimit)
+			orcaMemoryUtilization.Set(memUtilization)
+		}
+
+		// Updade reported utilization only if some requests were send
+		if numReq == 0 {
+			continue
+		}
+
+		qps := float64(numReq) / intervalDuration
+
+		o.serverMetrics.SetCPUUtilization(cpuUtilization)
+		o.serverMetrics.SetQPS(qps)
+		if memLimit > 0 {
+			o.serverMetrics.SetMemoryUtilization(memUtilization)
+		}
+		if maxReads > 0 {
+			o.serverMetrics.SetApplicationUtilization(readUtilization)
+		}
+		o.setLatency(latency)
+		o.logger.Debug("ORCA utilization updated", "cpu", cpuUtilization, "qps", qps, "application", readUtilization, "memory", memUtilization,
+			"latency_avg", latency.avg, "latency_p50", latency.p50, "latency_p95", latency.p95)
 	}
 }
 
-func (o *ORCAReporter) RecordRequest() {
+// RecordRequest records a completed request and its duration
+func (o *ORCAReporter) RecordRequest(d time.Duration) {
 	o.mu.Lock()
 	defer o.mu.Unlock()
 	o.requestCount++
+	o.latencySum += d
+	if len(o.latencySamples) < maxLatencySamples {
+		o.latencySamples = append(o.latencySamples, d)
+	} else if i := rand.IntN(o.requestCount); i < maxLatencySamples {
+		// Reservoir sampling keeps each duration with the same probability
+		o.latencySamples[i] = d
+	}
+
+	if o.reportEvery > 0 && o.requestCount >= o.reportEvery {
+		select {
+		case o.trigger <- struct{}{}:
+		default:
+			// An update is already pending
+		}
+	}
+}
+
+// SetReportEveryRequests makes the reporter update the utilization after
+// every n requests, or after the update interval if it ends first. 0 means
+// updates only every update interval.
+func (o *ORCAReporter) SetReportEveryRequests(n int) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.reportEvery = n
+}
+
+// latencyLocked returns the latency of the numReq requests recorded since
+// the last call and resets the recorded durations. Requires o.mu.
+func (o *ORCAReporter) latencyLocked(numReq int) latencyStats {
+	defer func() {
+		o.latencySum = 0
+		o.latencySamples = o.latencySamples[:0]
+	}()
+	if numReq == 0 || len(o.latencySamples) == 0 {
+		return latencyStats{}
+	}
+	slices.Sort(o.latencySamples)
+	return latencyStats{
+		avg: o.latencySum / time.Duration(numReq),
+		p50: percentile(o.latencySamples, 0.50),
+		p95: percentile(o.latencySamples, 0.95),
+	}
+}
+
+// percentile returns the p-th percentile of sorted durations with the
+// nearest-rank method
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	rank := int(math.Ceil(p * float64(len(sorted))))
+	return sorted[max(rank, 1)-1]
+}
+
+// setLatency reports the latency as named ORCA metrics, so clients can
+// balance on latency as well as utilization
+func (o *ORCAReporter) setLatency(latency latencyStats) {
+	recorder, ok := o.serverMetrics.(namedMetricsRecorder)
+	if !ok {
+		return
+	}
+	recorder.SetNamedMetric(orcaLatencyAvg, milliseconds(latency.avg))
+	recorder.SetNamedMetric(orcaLatencyP50, milliseconds(latency.p50))
+	recorder.SetNamedMetric(orcaLatencyP95, milliseconds(latency.p95))
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// SetMemLimit enables reporting of memory utilization as the bytes of
+// heap objects divided by limit
+func (o *ORCAReporter) SetMemLimit(limit int64) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.memLimit = limit
+}
+
+// memoryUtilization returns heapBytes as a fraction of limit, capped at 1
+// as ORCA memory utilization must be in [0, 1]
+func memoryUtilization(heapBytes uint64, limit int64) float64 {
+	return min(float64(heapBytes)/float64(limit), 1)
+}
+
+// SetMaxReads enables reporting of the read limiter occupancy with maxReads slots
+func (o *ORCAReporter) SetMaxReads(maxReads int) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.maxReads = maxReads
+	o.lastReadUpdate = time.Now()
+	o.readWindowStart = o.lastReadUpdate
+}
+
+// ReadStarted records that a read limiter slot was taken
+func (o *ORCAReporter) ReadStarted() {
+	o.updateReads(1)
+}
+
+// ReadDone records that a read limiter slot was released
+func (o *ORCAReporter) ReadDone() {
+	o.updateReads(-1)
+}
+
+func (o *ORCAReporter) updateReads(delta int) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	now := time.Now()
+	o.readTime += time.Duration(o.readsInUse) * now.Sub(o.lastReadUpdate)
+	o.lastReadUpdate = now
+	o.readsInUse += delta
+}
+
+// readUtilizationLocked returns the average read limiter occupancy since
+// the last call, from 0 (idle) to 1 (all slots in use). Requires o.mu.
+func (o *ORCAReporter) readUtilizationLocked() float64 {
+	if o.maxReads == 0 {
+		return 0
+	}
+	now := time.Now()
+	busy := o.readTime + time.Duration(o.readsInUse)*now.Sub(o.lastReadUpdate)
+	window := now.Sub(o.readWindowStart)
+	o.readTime = 0
+	o.lastReadUpdate = now
+	o.readWindowStart = now
+	if window <= 0 {
+		return 0
+	}
+	return float64(busy) / (float64(window) * float64(o.maxReads))
+}
+
+// CPUUtilization returns the CPU utilization measured over the last update interval,
+// including intervals without requests which are not reported to ORCA.
+func (o *ORCAReporter) CPUUtilization() float64 {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.cpuUtilization
 }
 
 func (o *ORCAReporter) Stop() {
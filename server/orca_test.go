@@ -0,0 +1,162 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/orca"
+)
+
+func TestORCAReporter_ReadUtilization(t *testing.T) {
+	o := &ORCAReporter{}
+	o.SetMaxReads(2)
+
+	// One of two slots in use for the whole interval
+	o.ReadStarted()
+	time.Sleep(50 * time.Millisecond)
+	o.mu.Lock()
+	got := o.readUtilizationLocked()
+	o.mu.Unlock()
+	if got < 0.45 || got > 0.55 {
+		t.Errorf("readUtilizationLocked() = %f, want about 0.5", got)
+	}
+
+	// The slot is released after half of the interval
+	time.Sleep(50 * time.Millisecond)
+	o.ReadDone()
+	time.Sleep(50 * time.Millisecond)
+	o.mu.Lock()
+	got = o.readUtilizationLocked()
+	o.mu.Unlock()
+	if got < 0.2 || got > 0.3 {
+		t.Errorf("readUtilizationLocked() = %f, want about 0.25", got)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	o.mu.Lock()
+	got = o.readUtilizationLocked()
+	o.mu.Unlock()
+	if got != 0 {
+		t.Errorf("readUtilizationLocked() = %f, want 0 without reads", got)
+	}
+}
+
+func TestORCAReporter_Latency(t *testing.T) {
+	o := &ORCAReporter{serverMetrics: orca.NewServerMetricsRecorder()}
+	for i := 100; i >= 1; i-- {
+		o.RecordRequest(time.Duration(i) * time.Millisecond)
+	}
+
+	o.mu.Lock()
+	latency := o.latencyLocked(o.requestCount)
+	o.mu.Unlock()
+	want := latencyStats{avg: 50500 * time.Microsecond, p50: 50 * time.Millisecond, p95: 95 * time.Millisecond}
+	if latency != want {
+		t.Errorf("latencyLocked() = %+v, want %+v", latency, want)
+	}
+
+	o.setLatency(latency)
+	named := o.serverMetrics.ServerMetrics().NamedMetrics
+	for name, want := range map[string]float64{orcaLatencyAvg: 50.5, orcaLatencyP50: 50, orcaLatencyP95: 95} {
+		if named[name] != want {
+			t.Errorf("Named metric %s = %v, want %v", name, named[name], want)
+		}
+	}
+
+	// Durations are reset after each update
+	o.requestCount = 0
+	o.RecordRequest(10 * time.Millisecond)
+	o.mu.Lock()
+	latency = o.latencyLocked(o.requestCount)
+	o.mu.Unlock()
+	if want := (latencyStats{avg: 10 * time.Millisecond, p50: 10 * time.Millisecond, p95: 10 * time.Millisecond}); latency != want {
+		t.Errorf("latencyLocked() = %+v, want %+v", latency, want)
+	}
+}
+
+func TestORCAReporter_LatencySamples(t *testing.T) {
+	o := &ORCAReporter{}
+	for i := 0; i < 3*maxLatencySamples; i++ {
+		o.RecordRequest(time.Millisecond)
+	}
+	if len(o.latencySamples) != maxLatencySamples {
+		t.Errorf("Kept %d latency samples, want %d", len(o.latencySamples), maxLatencySamples)
+	}
+	o.mu.Lock()
+	latency := o.latencyLocked(o.requestCount)
+	o.mu.Unlock()
+	if latency.avg != time.Millisecond || latency.p95 != time.Millisecond {
+		t.Errorf("latencyLocked() = %+v, want 1ms", latency)
+	}
+}
+
+func TestMemoryUtilization(t *testing.T) {
+	if got := memoryUtilization(256<<20, 1<<30); got != 0.25 {
+		t.Errorf("memoryUtilization() = %f, want 0.25", got)
+	}
+	if got := memoryUtilization(2<<30, 1<<30); got != 1 {
+		t.Errorf("memoryUtilization() over the limit = %f, want 1", got)
+	}
+}
+
+func TestORCAReporter_MemoryUtilization(t *testing.T) {
+	o := NewORCAReporter(10*time.Millisecond, nil)
+	defer o.Stop()
+	o.SetMemLimit(1 << 40)
+
+	// Utilization is not reported without requests
+	time.Sleep(50 * time.Millisecond)
+	if got := o.serverMetrics.ServerMetrics().MemUtilization; got != -1 {
+		t.Errorf("MemUtilization = %f without requests, want unset", got)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for o.serverMetrics.ServerMetrics().MemUtilization == -1 && time.Now().Before(deadline) {
+		o.RecordRequest(time.Millisecond)
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := o.serverMetrics.ServerMetrics().MemUtilization; got <= 0 || got >= 1 {
+		t.Errorf("MemUtilization = %f, want heap bytes as a fraction of 1TiB", got)
+	}
+}
+
+// waitForQPS waits until o reports QPS, and returns whether it did within timeout
+func waitForQPS(o *ORCAReporter, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if o.serverMetrics.ServerMetrics().QPS != -1 {
+			return true
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	return false
+}
+
+func TestORCAReporter_UpdateInterval(t *testing.T) {
+	o := NewORCAReporter(10*time.Millisecond, nil)
+	defer o.Stop()
+
+	o.RecordRequest(time.Millisecond)
+	if !waitForQPS(o, 5*time.Second) {
+		t.Errorf("QPS not reported after the update interval")
+	}
+}
+
+func TestORCAReporter_ReportEveryRequests(t *testing.T) {
+	o := NewORCAReporter(time.Hour, nil)
+	defer o.Stop()
+	o.SetReportEveryRequests(3)
+
+	o.RecordRequest(time.Millisecond)
+	o.RecordRequest(time.Millisecond)
+	if waitForQPS(o, 50*time.Millisecond) {
+		t.Fatalf("QPS reported after 2 requests, want after 3")
+	}
+	o.RecordRequest(time.Millisecond)
+	if !waitForQPS(o, 5*time.Second) {
+		t.Fatalf("QPS not reported after 3 requests")
+	}
+	if got := o.serverMetrics.ServerMetrics().QPS; got <= 0 {
+		t.Errorf("QPS = %f, want > 0", got)
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// photoCacheLookups counts scaled photo cache lookups by result
+var photoCacheLookups = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "cat_photos_photo_cache_lookups_total",
+		Help: "Scaled photo cache lookups, by result: hit or miss",
+	},
+	[]string{"result"},
+)
+
+// photoCacheKey identifies a scaled or converted photo
+type photoCacheKey struct {
+	catID   uint64
+	photoID uint64
+	opts    scaleOptions
+}
+
+// photoCache is an LRU cache of scaled and converted photos, so popular
+// photos requested at the same size are read and scaled once. It is bounded
+// by the size of the cached photos. Entries are checked against the content
+// hash of the stored photo, so a replaced photo is not served from the cache.
+type photoCache struct {
+	lru *lruCache[photoCacheKey, []byte]
+}
+
+func newPhotoCache(maxBytes int64) *photoCache {
+	size := func(data []byte) int64 { return int64(len(data)) }
+	return &photoCache{lru: newLRUCache[photoCacheKey](maxBytes, size, photoCacheLookups)}
+}
+
+// Get returns the photo cached for key with contentHash, calling load on
+// a miss. Concurrent misses of the same photo call load once, see
+// lruCache.GetOrLoad.
+func (c *photoCache) Get(ctx context.Context, key photoCacheKey, contentHash string, load func(ctx context.Context) ([]byte, error)) ([]byte, error) {
+	return c.lru.GetOrLoad(ctx, key, contentHash, load)
+}
+
+// Remove drops all sizes and formats of a photo from the cache
+func (c *photoCache) Remove(catID, photoID uint64) {
+	c.lru.RemoveFunc(func(key photoCacheKey) bool {
+		return key.catID == catID && key.photoID == photoID
+	})
+}
+
+// RemoveCat drops all photos of a cat from the cache
+func (c *photoCache) RemoveCat(catID uint64) {
+	c.lru.RemoveFunc(func(key photoCacheKey) bool {
+		return key.catID == catID
+	})
+}
+
+// EnablePhotoCache makes the server keep scaled and converted photos using
+// up to maxBytes of memory
+func (s *CatPhotosServer) EnablePhotoCache(maxBytes int64) {
+	s.photos = newPhotoCache(maxBytes)
+}
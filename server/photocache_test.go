@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	pb "github.com/mhbvr/manul/proto"
+)
+
+func TestPhotoCache_Get(t *testing.T) {
+	c := newPhotoCache(10)
+	key := photoCacheKey{catID: 1, photoID: 1, opts: scaleOptions{width: 50}}
+	loads := 0
+	load := func(context.Context) ([]byte, error) {
+		loads++
+		return []byte("scaled"), nil
+	}
+
+	for i := 0; i < 2; i++ {
+		if data, err := c.Get(context.Background(), key, "hash", load); err != nil || string(data) != "scaled" {
+			t.Fatalf("Get() = %q, %v, want %q", data, err, "scaled")
+		}
+	}
+	if loads != 1 {
+		t.Errorf("load called %d times, want 1", loads)
+	}
+
+	// Another size, a changed photo and a removed photo are loaded again
+	other := key
+	other.opts.format = pb.OutputFormat_PNG
+	c.Get(context.Background(), other, "hash", load)
+	c.Get(context.Background(), key, "new hash", load)
+	c.Remove(1, 1)
+	c.Get(context.Background(), key, "new hash", load)
+	if loads != 4 {
+		t.Errorf("load called %d times, want 4", loads)
+	}
+}
+
+func TestPhotoCache_Eviction(t *testing.T) {
+	c := newPhotoCache(10)
+	load := func(context.Context) ([]byte, error) { return make([]byte, 4), nil }
+	for id := uint64(1); id <= 3; id++ {
+		c.Get(context.Background(), photoCacheKey{catID: 1, photoID: id}, "hash", load)
+	}
+
+	if c.lru.size != 8 || c.lru.order.Len() != 2 {
+		t.Errorf("Cache has %d photos, %d bytes, want 2 photos, 8 bytes", c.lru.order.Len(), c.lru.size)
+	}
+	if _, ok := c.lru.entries[photoCacheKey{catID: 1, photoID: 1}]; ok {
+		t.Errorf("Least recently used photo was not evicted")
+	}
+
+	// Failed loads are not cached
+	failed := photoCacheKey{catID: 2, photoID: 1}
+	c.Get(context.Background(), failed, "hash", func(context.Context) ([]byte, error) { return nil, errors.New("failed") })
+	if _, ok := c.lru.entries[failed]; ok {
+		t.Errorf("Failed load was cached")
+	}
+}
+
+func TestPhotoCache_SingleFlight(t *testing.T) {
+	c := newPhotoCache(10)
+	key := photoCacheKey{catID: 1, photoID: 1}
+	var loads atomic.Int32
+	release := make(chan struct{})
+	load := func(context.Context) ([]byte, error) {
+		loads.Add(1)
+		<-release
+		return []byte("scaled"), nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if data, err := c.Get(context.Background(), key, "hash", load); err != nil || string(data) != "scaled" {
+				t.Errorf("Get() = %q, %v, want %q", data, err, "scaled")
+			}
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if got := loads.Load(); got != 1 {
+		t.Errorf("load called %d times for concurrent gets, want 1", got)
+	}
+}
+
+func TestPhotoCache_Cancel(t *testing.T) {
+	c := newPhotoCache(10)
+	key := photoCacheKey{catID: 1, photoID: 1}
+	release := make(chan struct{})
+	load := func(ctx context.Context) ([]byte, error) {
+		<-release
+		return []byte("scaled"), ctx.Err()
+	}
+
+	// The request starting the load cancels, a waiting request gets the photo
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan error)
+	go func() {
+		_, err := c.Get(ctx, key, "hash", load)
+		first <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	waiter := make(chan error)
+	go func() {
+		data, err := c.Get(context.Background(), key, "hash", load)
+		if err == nil && string(data) != "scaled" {
+			err = errors.New("unexpected data " + string(data))
+		}
+		waiter <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	cancel()
+	if err := <-first; !errors.Is(err, context.Canceled) {
+		t.Errorf("Get() with a canceled context error = %v, want Canceled", err)
+	}
+
+	// A canceled waiter stops waiting
+	waitCtx, waitCancel := context.WithCancel(context.Background())
+	waitCancel()
+	if _, err := c.Get(waitCtx, key, "hash", load); !errors.Is(err, context.Canceled) {
+		t.Errorf("Get() of a waiter with a canceled context error = %v, want Canceled", err)
+	}
+
+	close(release)
+	if err := <-waiter; err != nil {
+		t.Errorf("Get() of a waiter after the first request canceled failed: %v", err)
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// scalingQueueDepth is the number of scaling jobs waiting for a worker
+var scalingQueueDepth = promauto.NewGauge(
+	prometheus.GaugeOpts{
+		Name: "cat_photos_scaling_queue_depth",
+		Help: "Number of photo scaling jobs waiting for a free scaling worker",
+	},
+)
+
+// scalePool runs CPU-bound decode, scale and encode jobs on a fixed number
+// of goroutines shared by all requests, bounding the CPU used for scaling
+type scalePool struct {
+	jobs chan func()
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+func newScalePool(workers int) *scalePool {
+	p := &scalePool{
+		jobs: make(chan func()),
+		stop: make(chan struct{}),
+	}
+	for i := 0; i < workers; i++ {
+		p.wg.Add(1)
+		go p.work()
+	}
+	return p
+}
+
+func (p *scalePool) work() {
+	defer p.wg.Done()
+	for {
+		select {
+		case <-p.stop:
+			return
+		case job := <-p.jobs:
+			job()
+		}
+	}
+}
+
+// Do runs job on a worker and waits for it to finish. It returns the
+// context error if ctx is done before a worker takes the job.
+func (p *scalePool) Do(ctx context.Context, job func()) error {
+	done := make(chan struct{})
+	wrapped := func() {
+		defer close(done)
+		job()
+	}
+
+	scalingQueueDepth.Inc()
+	select {
+	case p.jobs <- wrapped:
+		scalingQueueDepth.Dec()
+	case <-ctx.Done():
+		scalingQueueDepth.Dec()
+		return ctx.Err()
+	}
+
+	<-done
+	return nil
+}
+
+// Stop stops the workers after their current jobs
+func (p *scalePool) Stop() {
+	close(p.stop)
+	p.wg.Wait()
+}
+
+// EnableScalingPool makes the server scale photos on a pool of workers
+// goroutines instead of the request goroutines
+func (s *CatPhotosServer) EnableScalingPool(workers int) {
+	s.scalePool = newScalePool(workers)
+}
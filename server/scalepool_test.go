@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestScalePool_BoundsConcurrency(t *testing.T) {
+	p := newScalePool(2)
+	defer p.Stop()
+
+	var running, maxRunning atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := p.Do(context.Background(), func() {
+				n := running.Add(1)
+				for {
+					m := maxRunning.Load()
+					if n <= m || maxRunning.CompareAndSwap(m, n) {
+						break
+					}
+				}
+				time.Sleep(5 * time.Millisecond)
+				running.Add(-1)
+			})
+			if err != nil {
+				t.Errorf("Do() failed: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := maxRunning.Load(); got > 2 {
+		t.Errorf("%d jobs ran concurrently, want at most 2", got)
+	}
+}
+
+func TestScalePool_ContextDone(t *testing.T) {
+	p := newScalePool(1)
+	defer p.Stop()
+
+	// Occupy the only worker
+	release := make(chan struct{})
+	started := make(chan struct{})
+	go p.Do(context.Background(), func() {
+		close(started)
+		<-release
+	})
+	<-started
+	defer close(release)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	ran := false
+	if err := p.Do(ctx, func() { ran = true }); err != context.DeadlineExceeded {
+		t.Errorf("Do() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if ran {
+		t.Errorf("Do() ran a job after the context was done")
+	}
+}
@@ -3,65 +3,477 @@ package main
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"image"
-	"image/jpeg"
+	"log/slog"
+	"math"
+	"strconv"
+	"sync"
+	"time"
 
 	"github.com/mhbvr/manul"
 	"github.com/mhbvr/manul/db/bolt"
 	"github.com/mhbvr/manul/db/filetree"
 	"github.com/mhbvr/manul/db/pebble"
+	"github.com/mhbvr/manul/db/shadow"
+	"github.com/mhbvr/manul/db/sqlite"
+	"github.com/mhbvr/manul/db/tiered"
 	pb "github.com/mhbvr/manul/proto"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
 	"golang.org/x/image/draw"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/orca"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
 )
 
 type CatPhotosServer struct {
 	pb.UnimplementedCatPhotosServiceServer
 	dbReader     manul.DBReader
+	dbWriter     manul.DBWriter // nil unless opened in read-write mode
 	orcaReporter *ORCAReporter
 	readLimiter  chan struct{}
+	rejectReads  bool // fail reads instead of waiting for a readLimiter slot
+	hotKeys      *hotKeys
+	warmer       *cacheWarmer
+	decoded      *imageCache   // nil if the decoded image cache is disabled
+	photos       *photoCache   // nil if the scaled photo cache is disabled
+	listings     *listingCache // nil if the photo listing cache is disabled
+	scalePool    *scalePool    // nil if photos are scaled on request goroutines
+	stats        *statsLogger
+	readErrors   *readErrorMonitor              // nil if read errors do not affect health
+	filetreeIO   map[string]string              // Active IO modes of filetree databases by flag name
+	presets      map[pb.SizePreset]scaleOptions // Scale options of GetPhoto size presets
+	streamBytes  int64                          // Max photo bytes sent by a GetPhotosStream call, 0 = no limit
+	logger       *slog.Logger
 }
 
-func NewCatPhotosServer(dbPath, dbType string, maxConcurrentReads int, orcaReporter *ORCAReporter) (*CatPhotosServer, error) {
+// openDB opens the database read-only, or for reading and writing if
+// readWrite is set. Only one of the results is set.
+func openDB(dbPath, dbType string, openTimeout time.Duration, readWrite bool, filetreeIO filetree.IOMode) (manul.DBReader, manul.DBReadWriter, error) {
 	var dbReader manul.DBReader
+	var dbWriter manul.DBReadWriter
 	var err error
 
-	switch dbType {
-	case "filetree":
-		dbReader, err = filetree.NewReader(dbPath)
-	case "bolt":
-		dbReader, err = bolt.NewReader(dbPath)
-	case "pebble":
-		dbReader, err = pebble.NewReader(dbPath)
+	switch {
+	case dbType == "filetree" && readWrite:
+		dbWriter, err = filetree.New(dbPath, filetree.WithTimeout(openTimeout), filetree.WithIOMode(filetreeIO))
+	case dbType == "filetree":
+		dbReader, err = filetree.NewReader(dbPath, filetree.WithTimeout(openTimeout), filetree.WithIOMode(filetreeIO))
+	case dbType == "bolt" && readWrite:
+		dbWriter, err = bolt.New(dbPath, bolt.WithTimeout(openTimeout))
+	case dbType == "bolt":
+		dbReader, err = bolt.NewReader(dbPath, bolt.WithTimeout(openTimeout))
+	case dbType == "pebble" && readWrite:
+		dbWriter, err = pebble.New(dbPath, pebble.WithTimeout(openTimeout))
+	case dbType == "pebble":
+		dbReader, err = pebble.NewReader(dbPath, pebble.WithTimeout(openTimeout))
+	case dbType == "sqlite" && readWrite:
+		dbWriter, err = sqlite.New(dbPath, sqlite.WithTimeout(openTimeout))
+	case dbType == "sqlite":
+		dbReader, err = sqlite.NewReader(dbPath, sqlite.WithTimeout(openTimeout))
 	default:
-		return nil, fmt.Errorf("unknown database type: %s (must be 'filetree', 'bolt', or 'pebble')", dbType)
+		return nil, nil, fmt.Errorf("unknown database type: %s (must be 'filetree', 'bolt', 'pebble', or 'sqlite')", dbType)
 	}
 
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
+	return dbReader, dbWriter, nil
+}
 
-	var readLimiter chan struct{}
-	if maxConcurrentReads > 0 {
-		readLimiter = make(chan struct{}, maxConcurrentReads)
+// Option configures a CatPhotosServer
+type Option func(*serverOptions)
+
+type serverOptions struct {
+	dbReader   manul.DBReader
+	filetreeIO filetree.IOMode
+	logger     *slog.Logger
+	shadowPath string
+	shadowType string
+}
+
+// WithDBReader makes the server use an already opened database instead of
+// opening dbPath. In read-write mode db must also implement manul.DBWriter.
+func WithDBReader(db manul.DBReader) Option {
+	return func(o *serverOptions) {
+		o.dbReader = db
+	}
+}
+
+// WithFiletreeIO sets how photo files of filetree databases are read,
+// filetree.IOAuto by default
+func WithFiletreeIO(mode filetree.IOMode) Option {
+	return func(o *serverOptions) {
+		o.filetreeIO = mode
+	}
+}
+
+// WithLogger sets the logger of the server and its background tasks,
+// slog.Default() by default
+func WithLogger(logger *slog.Logger) Option {
+	return func(o *serverOptions) {
+		o.logger = logger
+	}
+}
+
+// WithShadowDB opens the database at dbPath read-only as a shadow of the
+// database, see db/shadow. Photo data is served from the database and
+// compared with the shadow database in the background.
+func WithShadowDB(dbPath, dbType string) Option {
+	return func(o *serverOptions) {
+		o.shadowPath = dbPath
+		o.shadowType = dbType
+	}
+}
+
+var shadowReads = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "cat_photos_shadow_reads_total",
+		Help: "Number of photos compared with the shadow database by result: match, mismatch, secondary_error or dropped",
+	},
+	[]string{"result"},
+)
+
+// recordFiletreeIO adds the active IO mode of db to modes under name if
+// it is a filetree database
+func recordFiletreeIO(modes map[string]string, name string, db manul.DBReader) {
+	if ft, ok := db.(*filetree.FileTreeDB); ok {
+		modes[name] = string(ft.IOMode())
+	}
+}
+
+// NewCatPhotosServer opens the database read-only, or for reading and
+// writing if readWrite is set, which enables the delete RPCs.
+// If cachePath is set, the cache database is opened for writing and used
+// as a fast tier in front of the database, see db/tiered.
+func NewCatPhotosServer(dbPath, dbType, cachePath, cacheType string, openTimeout time.Duration, readWrite bool, maxConcurrentReads int, orcaReporter *ORCAReporter, opts ...Option) (*CatPhotosServer, error) {
+	o := &serverOptions{filetreeIO: filetree.IOAuto, logger: slog.Default()}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	if cachePath != "" && readWrite {
+		// Deletes would leave stale photos in the cache tier
+		return nil, fmt.Errorf("cache database is not supported in read-write mode")
+	}
+	if o.shadowPath != "" && readWrite {
+		// Deleted photos would be reported as mismatches
+		return nil, fmt.Errorf("shadow database is not supported in read-write mode")
+	}
+
+	var dbReader manul.DBReader
+	var dbWriter manul.DBReadWriter
+	var err error
+	switch {
+	case o.dbReader != nil && readWrite:
+		var ok bool
+		if dbWriter, ok = o.dbReader.(manul.DBReadWriter); !ok {
+			return nil, fmt.Errorf("database %T does not support writing", o.dbReader)
+		}
+	case o.dbReader != nil:
+		dbReader = o.dbReader
+	default:
+		dbReader, dbWriter, err = openDB(dbPath, dbType, openTimeout, readWrite, o.filetreeIO)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	filetreeIO := make(map[string]string)
+	recordFiletreeIO(filetreeIO, "db", dbReader)
+	recordFiletreeIO(filetreeIO, "db", dbWriter)
+
+	if cachePath != "" {
+		_, cache, err := openDB(cachePath, cacheType, openTimeout, true, o.filetreeIO)
+		if err != nil {
+			dbReader.Close()
+			return nil, fmt.Errorf("failed to open cache database: %v", err)
+		}
+		recordFiletreeIO(filetreeIO, "cache-db", cache)
+		dbReader = tiered.New(cache, cache, dbReader)
+	}
+
+	if o.shadowPath != "" {
+		secondary, _, err := openDB(o.shadowPath, o.shadowType, openTimeout, false, o.filetreeIO)
+		if err != nil {
+			dbReader.Close()
+			return nil, fmt.Errorf("failed to open shadow database: %v", err)
+		}
+		recordFiletreeIO(filetreeIO, "shadow-db", secondary)
+		dbReader = shadow.New(dbReader, secondary, shadow.WithLogger(o.logger), shadow.WithObserver(func(r shadow.Result) {
+			shadowReads.WithLabelValues(r.String()).Inc()
+		}))
 	}
 
-	return &CatPhotosServer{
-		dbReader:     dbReader,
+	res := &CatPhotosServer{
 		orcaReporter: orcaReporter,
-		readLimiter:  readLimiter,
-	}, nil
+		filetreeIO:   filetreeIO,
+		presets:      newSizePresets(defaultThumbWidth, defaultMediumWidth, 0),
+		logger:       o.logger,
+	}
+	if dbWriter != nil {
+		res.dbReader = dbWriter
+		res.dbWriter = dbWriter
+	} else {
+		res.dbReader = dbReader
+	}
+
+	if maxConcurrentReads > 0 {
+		res.readLimiter = make(chan struct{}, maxConcurrentReads)
+		if orcaReporter != nil {
+			orcaReporter.SetMaxReads(maxConcurrentReads)
+		}
+	}
+
+	return res, nil
+}
+
+// readRejectPushback is the delay before retries suggested to clients
+// whose reads are rejected
+const readRejectPushback = 100 * time.Millisecond
+
+var readRejections = promauto.NewCounter(
+	prometheus.CounterOpts{
+		Name: "cat_photos_read_rejections_total",
+		Help: "Number of reads rejected because all read limiter slots were taken",
+	},
+)
+
+// EnableReadRejection makes reads fail with ResourceExhausted instead of
+// waiting when all read limiter slots are taken
+func (s *CatPhotosServer) EnableReadRejection() {
+	s.rejectReads = true
+}
+
+// acquireRead waits for a read limiter slot if reads are limited, failing
+// with DeadlineExceeded or Canceled when ctx is done first. With read
+// rejection enabled it fails with ResourceExhausted if no slot is free.
+func (s *CatPhotosServer) acquireRead(ctx context.Context) error {
+	if s.readLimiter == nil {
+		return nil
+	}
+	if s.rejectReads {
+		select {
+		case s.readLimiter <- struct{}{}:
+		default:
+			readRejections.Inc()
+			// Clients with a gRPC retry policy wait for the pushback
+			grpc.SetTrailer(ctx, metadata.Pairs("grpc-retry-pushback-ms", strconv.FormatInt(readRejectPushback.Milliseconds(), 10)))
+			return status.Errorf(codes.ResourceExhausted, "too many concurrent reads, retry in %v", readRejectPushback)
+		}
+	} else {
+		select {
+		case s.readLimiter <- struct{}{}:
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		}
+	}
+	if s.orcaReporter != nil {
+		s.orcaReporter.ReadStarted()
+	}
+	return nil
+}
+
+// releaseRead releases the slot taken by acquireRead
+func (s *CatPhotosServer) releaseRead() {
+	if s.readLimiter == nil {
+		return
+	}
+	if s.orcaReporter != nil {
+		s.orcaReporter.ReadDone()
+	}
+	<-s.readLimiter
+}
+
+// readPhotoData reads the photo data with the slot taken by acquireRead and
+// releases the slot, even if the read panics. Databases which are
+// manul.ContextReaders give up when ctx is done.
+func (s *CatPhotosServer) readPhotoData(ctx context.Context, catID, photoID uint64) ([]byte, error) {
+	defer s.releaseRead()
+	return manul.GetPhotoDataContext(ctx, s.dbReader, catID, photoID)
+}
+
+// readPhotoMeta reads the photo metadata with a read slot, databases
+// compute the metadata of legacy photos from the photo data
+func (s *CatPhotosServer) readPhotoMeta(ctx context.Context, catID, photoID uint64) (manul.PhotoMeta, error) {
+	if err := s.acquireRead(ctx); err != nil {
+		return manul.PhotoMeta{}, err
+	}
+	defer s.releaseRead()
+
+	meta, err := s.dbReader.GetPhotoMeta(catID, photoID)
+	if err != nil {
+		return manul.PhotoMeta{}, photoNotFoundError(catID, photoID, err)
+	}
+	return meta, nil
 }
 
 func (s *CatPhotosServer) Close() error {
+	if s.warmer != nil {
+		s.warmer.Stop()
+	}
+	if s.scalePool != nil {
+		s.scalePool.Stop()
+	}
+	if s.stats != nil {
+		s.stats.Stop()
+	}
+	if s.readErrors != nil {
+		s.readErrors.Stop()
+	}
 	return s.dbReader.Close()
 }
 
-func getScaler(algorithm pb.ScalingAlgorithm) draw.Scaler {
+// scaleOptions are the scaling and output format parameters of a request
+type scaleOptions struct {
+	width     uint32
+	height    uint32
+	fit       pb.FitMode
+	algorithm pb.ScalingAlgorithm
+	format    pb.OutputFormat
+	quality   uint32 // JPEG quality, 0 means the default
+}
+
+// Default widths of the THUMB and MEDIUM size presets
+const (
+	defaultThumbWidth  = 200
+	defaultMediumWidth = 800
+)
+
+// newSizePresets returns the scale options of the size presets, quality 0
+// means the default JPEG quality
+func newSizePresets(thumbWidth, mediumWidth, quality uint32) map[pb.SizePreset]scaleOptions {
+	return map[pb.SizePreset]scaleOptions{
+		pb.SizePreset_THUMB:  {width: thumbWidth, algorithm: pb.ScalingAlgorithm_AUTO, quality: clampQuality(quality)},
+		pb.SizePreset_MEDIUM: {width: mediumWidth, algorithm: pb.ScalingAlgorithm_AUTO, quality: clampQuality(quality)},
+		pb.SizePreset_FULL:   {},
+	}
+}
+
+// SetSizePresets sets the widths of the THUMB and MEDIUM size presets and
+// their JPEG quality (0 = default)
+func (s *CatPhotosServer) SetSizePresets(thumbWidth, mediumWidth, quality uint32) {
+	s.presets = newSizePresets(thumbWidth, mediumWidth, quality)
+}
+
+// getPhotoScaleOptions returns the scale options of a GetPhoto request,
+// taken from its size preset if set. Errors are gRPC status errors.
+func (s *CatPhotosServer) getPhotoScaleOptions(req *pb.GetPhotoRequest) (scaleOptions, error) {
+	format := req.OutputFormat
+	if len(req.AcceptFormats) > 0 {
+		var ok bool
+		if format, ok = negotiateFormat(req.AcceptFormats); !ok {
+			return scaleOptions{}, status.Errorf(codes.Unimplemented, "none of the accepted formats %v is supported", req.AcceptFormats)
+		}
+	}
+
+	if req.SizePreset != pb.SizePreset_NO_PRESET {
+		opts, ok := s.presets[req.SizePreset]
+		if !ok {
+			return scaleOptions{}, status.Errorf(codes.InvalidArgument, "unknown size preset %s", req.SizePreset)
+		}
+		opts.format = format
+		return opts, nil
+	}
+
+	// Zero width and height mean the original size, which only the default
+	// fit mode accepts for requests made before the height was added
+	if req.Fit != pb.FitMode_FIT && req.Width == 0 && req.Height == 0 {
+		return scaleOptions{}, status.Errorf(codes.InvalidArgument, "fit mode %s requires width or height", req.Fit)
+	}
+
+	return scaleOptions{
+		width:     req.Width,
+		height:    req.Height,
+		fit:       req.Fit,
+		algorithm: req.ScalingAlgorithm,
+		format:    format,
+		quality:   clampQuality(req.Quality),
+	}, nil
+}
+
+// scalingRequested reports whether a request asks for a scaled photo,
+// NONE algorithm means the original photo even if width or height is set
+func (o scaleOptions) scalingRequested() bool {
+	return (o.width > 0 || o.height > 0) && o.algorithm != pb.ScalingAlgorithm_NONE
+}
+
+// scaleGeometry returns the part of a photo with bounds to scale and the
+// size to scale it to. ok is false if the photo keeps its size: photos are
+// not upscaled, except to fill or stretch to a box with both sides set.
+func (o scaleOptions) scaleGeometry(bounds image.Rectangle) (src image.Rectangle, width, height int, ok bool) {
+	currentWidth := bounds.Dx()
+	currentHeight := bounds.Dy()
+	if !o.scalingRequested() || currentWidth == 0 || currentHeight == 0 {
+		return bounds, 0, 0, false
+	}
+
+	boxWidth, boxHeight := int(o.width), int(o.height)
+	switch {
+	case boxHeight == 0:
+		// Calculate new height maintaining aspect ratio
+		if boxWidth >= currentWidth {
+			return bounds, 0, 0, false
+		}
+		return bounds, boxWidth, currentHeight * boxWidth / currentWidth, true
+	case boxWidth == 0:
+		if boxHeight >= currentHeight {
+			return bounds, 0, 0, false
+		}
+		return bounds, currentWidth * boxHeight / currentHeight, boxHeight, true
+	}
+
+	switch o.fit {
+	case pb.FitMode_STRETCH:
+		return bounds, boxWidth, boxHeight, true
+	case pb.FitMode_FILL:
+		// Crop the center of the photo to the box aspect ratio
+		cropWidth, cropHeight := currentWidth, currentWidth*boxHeight/boxWidth
+		if cropHeight > currentHeight {
+			cropWidth, cropHeight = currentHeight*boxWidth/boxHeight, currentHeight
+		}
+		origin := bounds.Min.Add(image.Pt((currentWidth-cropWidth)/2, (currentHeight-cropHeight)/2))
+		return image.Rectangle{Min: origin, Max: origin.Add(image.Pt(cropWidth, cropHeight))}, boxWidth, boxHeight, true
+	default:
+		// FIT: the side with the larger downscale ratio sets the size
+		if boxWidth >= currentWidth && boxHeight >= currentHeight {
+			return bounds, 0, 0, false
+		}
+		if boxWidth*currentHeight <= boxHeight*currentWidth {
+			return bounds, boxWidth, max(1, currentHeight*boxWidth/currentWidth), true
+		}
+		return bounds, max(1, currentWidth*boxHeight/currentHeight), boxHeight, true
+	}
+}
+
+// autoScaler chooses the algorithm by the downscale ratio: better quality
+// for small reductions, cheaper algorithms for large ones
+func autoScaler(currentWidth, targetWidth int) draw.Scaler {
+	ratio := float64(currentWidth) / float64(targetWidth)
+	switch {
+	case ratio < 2:
+		return draw.CatmullRom
+	case ratio < 8:
+		return draw.BiLinear
+	default:
+		return draw.ApproxBiLinear
+	}
+}
+
+func getScaler(algorithm pb.ScalingAlgorithm, currentWidth, targetWidth int) draw.Scaler {
 	switch algorithm {
+	case pb.ScalingAlgorithm_AUTO:
+		return autoScaler(currentWidth, targetWidth)
 	case pb.ScalingAlgorithm_NEAREST_NEIGHBOR:
 		return draw.NearestNeighbor
 	case pb.ScalingAlgorithm_BILINEAR:
@@ -75,148 +487,506 @@ func getScaler(algorithm pb.ScalingAlgorithm) draw.Scaler {
 	}
 }
 
-func scaleImage(photoData []byte, targetWidth uint32, algorithm pb.ScalingAlgorithm) ([]byte, error) {
-	// Decode the image
+func decodeImage(photoData []byte) (image.Image, error) {
 	img, _, err := image.Decode(bytes.NewReader(photoData))
 	if err != nil {
 		return nil, fmt.Errorf("failed to decode image: %v", err)
 	}
+	return img, nil
+}
 
-	// Get current dimensions
-	bounds := img.Bounds()
-	currentWidth := bounds.Dx()
-	currentHeight := bounds.Dy()
-
-	// If target width is greater than or equal to current width, return original
-	if int(targetWidth) >= currentWidth {
-		return photoData, nil
+func scaleImage(photoData []byte, opts scaleOptions) ([]byte, error) {
+	img, err := decodeImage(photoData)
+	if err != nil {
+		return nil, err
 	}
+	return scaleDecoded(img, photoData, opts)
+}
 
-	// Calculate new height maintaining aspect ratio
-	newWidth := int(targetWidth)
-	newHeight := int(float64(currentHeight) * float64(newWidth) / float64(currentWidth))
+// scaleDecoded scales img, the decoded photoData, and encodes it in the
+// output format. photoData is returned if it needs neither.
+func scaleDecoded(img image.Image, photoData []byte, opts scaleOptions) ([]byte, error) {
+	src, newWidth, newHeight, ok := opts.scaleGeometry(img.Bounds())
+	if !ok {
+		if !conversionRequested(photoData, opts.format) {
+			return photoData, nil
+		}
+		return encodeImage(img, opts.format, opts.quality)
+	}
 
 	// Create a new image with the target dimensions
-	dst := image.NewRGBA(image.Rect(0, 0, newWidth, newHeight))
+	dst := scalingImagePool.newRGBA(image.Rect(0, 0, newWidth, newHeight))
+	defer scalingImagePool.putRGBA(dst)
 
 	// Scale the image using the specified algorithm
-	scaler := getScaler(algorithm)
-	scaler.Scale(dst, dst.Bounds(), img, bounds, draw.Over, nil)
+	scaler := getScaler(opts.algorithm, src.Dx(), newWidth)
+	scaler.Scale(dst, dst.Bounds(), img, src, draw.Over, nil)
 
-	// Encode the scaled image as JPEG
-	var buf bytes.Buffer
-	err = jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 85})
-	if err != nil {
-		return nil, fmt.Errorf("failed to encode scaled image: %v", err)
+	return encodeImage(dst, opts.format, opts.quality)
+}
+
+// scalePhoto scales a photo on the scaling pool if enabled. hash is the
+// hex SHA-256 of photoData as in manul.PhotoMeta, empty if not known.
+func (s *CatPhotosServer) scalePhoto(ctx context.Context, catID, photoID uint64, hash string, photoData []byte, opts scaleOptions) ([]byte, error) {
+	if s.scalePool == nil {
+		return s.scalePhotoCached(catID, photoID, hash, photoData, opts)
+	}
+
+	var res []byte
+	var err error
+	if poolErr := s.scalePool.Do(ctx, func() {
+		res, err = s.scalePhotoCached(catID, photoID, hash, photoData, opts)
+	}); poolErr != nil {
+		return nil, poolErr
+	}
+	return res, err
+}
+
+// scalePhotoCached scales a photo, using the decoded image cache if enabled.
+// Cached images are checked against hash, computed from photoData if empty,
+// so a photo replaced by another writer is decoded again.
+func (s *CatPhotosServer) scalePhotoCached(catID, photoID uint64, hash string, photoData []byte, opts scaleOptions) ([]byte, error) {
+	if s.decoded == nil {
+		return scaleImage(photoData, opts)
+	}
+
+	if hash == "" {
+		sum := sha256.Sum256(photoData)
+		hash = hex.EncodeToString(sum[:])
+	}
+	img, ok := s.decoded.Get(catID, photoID, hash)
+	if !ok {
+		var err error
+		img, err = decodeImage(photoData)
+		if err != nil {
+			return nil, err
+		}
+		s.decoded.Add(catID, photoID, hash, img)
+	}
+	return scaleDecoded(img, photoData, opts)
+}
+
+// photoContentHash identifies the photo content returned for a request:
+// the stored photo checksum extended with the scaling parameters and the
+// output format
+func photoContentHash(meta manul.PhotoMeta, opts scaleOptions) string {
+	hash := meta.Hash()
+	if opts.scalingRequested() {
+		hash = fmt.Sprintf("%s-w%d-%s", hash, opts.width, opts.algorithm)
+		if opts.height > 0 {
+			hash = fmt.Sprintf("%s-h%d-%s", hash, opts.height, opts.fit)
+		}
 	}
+	if opts.format != pb.OutputFormat_ORIGINAL {
+		hash = fmt.Sprintf("%s-%s", hash, opts.format)
+	}
+	// Quality only changes photos encoded by the server
+	if opts.quality > 0 && (opts.scalingRequested() || opts.format != pb.OutputFormat_ORIGINAL) {
+		hash = fmt.Sprintf("%s-q%d", hash, opts.quality)
+	}
+	return hash
+}
 
-	return buf.Bytes(), nil
+// defaultPageSize is the page size of list RPCs requested without one
+const defaultPageSize = 1000
+
+// parsePage returns the page size and the first ID of a page requested
+// with pageToken. done is set if the previous page was the last one.
+func parsePage(pageSize int32, pageToken string) (limit int, start uint64, done bool, err error) {
+	limit = int(pageSize)
+	if limit <= 0 {
+		limit = defaultPageSize
+	}
+	if pageToken == "" {
+		return limit, 0, false, nil
+	}
+
+	// The token is the last ID of the previous page
+	token, err := base64.RawURLEncoding.DecodeString(pageToken)
+	if err != nil || len(token) != 8 {
+		return 0, 0, false, status.Errorf(codes.InvalidArgument, "invalid page token %q", pageToken)
+	}
+	last := binary.BigEndian.Uint64(token)
+	if last == math.MaxUint64 {
+		return limit, 0, true, nil
+	}
+	return limit, last + 1, false, nil
+}
+
+// nextPageToken returns the token of the page after ids, fetched with one
+// ID more than limit to know if there is a next page, and trims ids to limit
+func nextPageToken(ids []uint64, limit int) ([]uint64, string) {
+	if len(ids) <= limit {
+		return ids, ""
+	}
+	ids = ids[:limit]
+	token := make([]byte, 8)
+	binary.BigEndian.PutUint64(token, ids[limit-1])
+	return ids, base64.RawURLEncoding.EncodeToString(token)
 }
 
 func (s *CatPhotosServer) ListCats(ctx context.Context, req *pb.ListCatsRequest) (*pb.ListCatsResponse, error) {
+	limit, start, done, err := parsePage(req.PageSize, req.PageToken)
+	if err != nil {
+		return nil, err
+	}
+	if done {
+		return &pb.ListCatsResponse{}, nil
+	}
 
-	catIds, err := s.dbReader.GetAllCatIDs()
+	catIds, err := s.dbReader.ListCatIDs(start, limit+1)
 	if err != nil {
 		return nil, status.Errorf(codes.Internal, "failed to get cat IDs: %v", err)
 	}
 
+	catIds, nextToken := nextPageToken(catIds, limit)
 	return &pb.ListCatsResponse{
-		CatIds: catIds,
+		CatIds:        catIds,
+		NextPageToken: nextToken,
 	}, nil
 }
 
 func (s *CatPhotosServer) ListPhotos(ctx context.Context, req *pb.ListPhotosRequest) (*pb.ListPhotosResponse, error) {
+	limit, start, done, err := parsePage(req.PageSize, req.PageToken)
+	if err != nil {
+		return nil, err
+	}
+	if done {
+		return &pb.ListPhotosResponse{}, nil
+	}
+
 	var photoIds []uint64
-	var err error
-	photoIds, err = s.dbReader.GetPhotoIDs(req.CatId)
+	if s.listings != nil {
+		var allIds []uint64
+		allIds, err = s.listings.Get(ctx, req.CatId, func() ([]uint64, error) {
+			return s.dbReader.GetPhotoIDs(req.CatId)
+		})
+		photoIds = listingPage(allIds, start, limit+1)
+	} else {
+		photoIds, err = s.dbReader.ListPhotoIDs(req.CatId, start, limit+1)
+	}
 	if err != nil {
 		return nil, status.Errorf(codes.Internal, "failed to get photo IDs: %v", err)
 	}
 
-	if len(photoIds) == 0 {
+	if len(photoIds) == 0 && req.PageToken == "" {
 		return nil, status.Errorf(codes.NotFound, "cat with ID %d not found", req.CatId)
 	}
 
+	photoIds, nextToken := nextPageToken(photoIds, limit)
 	return &pb.ListPhotosResponse{
-		PhotoIds: photoIds,
+		PhotoIds:      photoIds,
+		NextPageToken: nextToken,
 	}, nil
 }
 
+// photoNotFoundReason is the ErrorInfo reason of missing photo errors
+const photoNotFoundReason = "PHOTO_NOT_FOUND"
+
+// photoNotFoundError returns a NotFound error with an ErrorInfo detail
+// holding the photo IDs, so clients do not have to parse the message
+func photoNotFoundError(catID, photoID uint64, err error) error {
+	st := status.Newf(codes.NotFound, "photo with cat_id=%d, photo_id=%d not found: %v", catID, photoID, err)
+	detailed, detailErr := st.WithDetails(&errdetails.ErrorInfo{
+		Reason: photoNotFoundReason,
+		Domain: "catphotos",
+		Metadata: map[string]string{
+			"cat_id":   strconv.FormatUint(catID, 10),
+			"photo_id": strconv.FormatUint(photoID, 10),
+		},
+	})
+	if detailErr != nil {
+		return st.Err()
+	}
+	return detailed.Err()
+}
+
 func (s *CatPhotosServer) GetPhoto(ctx context.Context, req *pb.GetPhotoRequest) (*pb.GetPhotoResponse, error) {
 	orca.CallMetricsRecorderFromContext(ctx)
+	startTime := time.Now()
 	var photoData []byte
 	var err error
 	defer func() {
 		if s.orcaReporter != nil {
-			s.orcaReporter.RecordRequest()
+			s.orcaReporter.RecordRequest(time.Since(startTime))
 		}
 	}()
 
-	if s.readLimiter != nil {
-		s.readLimiter <- struct{}{}
+	opts, err := s.getPhotoScaleOptions(req)
+	if err != nil {
+		return nil, err
 	}
-	photoData, err = s.dbReader.GetPhotoData(req.CatId, req.PhotoId)
-	if s.readLimiter != nil {
-		<-s.readLimiter
+
+	// One read slot covers the metadata and photo data reads of a request,
+	// it is released after the data is read or when the request ends
+	if err := s.acquireRead(ctx); err != nil {
+		return nil, err
 	}
+	var releaseOnce sync.Once
+	release := func() { releaseOnce.Do(s.releaseRead) }
+	defer release()
 
+	meta, photoData, err := s.lookupPhoto(ctx, req.CatId, req.PhotoId, release)
 	if err != nil {
-		return nil, status.Errorf(codes.NotFound, "photo with cat_id=%d, photo_id=%d not found: %v", req.CatId, req.PhotoId, err)
+		return nil, err
 	}
 
-	// Apply scaling if width > 0
-	if req.Width > 0 {
-		scaledData, err := scaleImage(photoData, req.Width, req.ScalingAlgorithm)
-		if err != nil {
-			return nil, status.Errorf(codes.Internal, "failed to scale image: %v", err)
+	if !outputSupported(opts.format) {
+		return nil, status.Errorf(codes.Unimplemented, "output format %s is not supported", opts.format)
+	}
+
+	contentHash := photoContentHash(meta, opts)
+	if req.IfNoneMatch != "" && req.IfNoneMatch == contentHash {
+		return &pb.GetPhotoResponse{
+			NotModified: true,
+			ContentHash: contentHash,
+			Format:      opts.format,
+		}, nil
+	}
+
+	if s.hotKeys != nil {
+		s.hotKeys.Record(req.CatId, req.PhotoId)
+	}
+
+	// Scaled and converted photos are cached, originals are read every time
+	if s.photos != nil && (opts.scalingRequested() || opts.format != pb.OutputFormat_ORIGINAL) {
+		key := photoCacheKey{catID: req.CatId, photoID: req.PhotoId, opts: opts}
+		photoData, err = s.photos.Get(ctx, key, contentHash, func(ctx context.Context) ([]byte, error) {
+			return s.loadPhoto(ctx, req.CatId, req.PhotoId, meta.Hash(), photoData, opts, release)
+		})
+	} else {
+		photoData, err = s.loadPhoto(ctx, req.CatId, req.PhotoId, meta.Hash(), photoData, opts, release)
+	}
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, status.FromContextError(ctxErr).Err()
 		}
-		photoData = scaledData
+		return nil, err
 	}
 
 	return &pb.GetPhotoResponse{
-		PhotoData: photoData,
+		PhotoData:   photoData,
+		ContentType: manul.ContentType(manul.DetectFormat(photoData).String()),
+		ContentHash: contentHash,
+		Format:      opts.format,
 	}, nil
 }
 
+// lookupPhoto reads the photo metadata with the read slot of a request.
+// Photos stored before checksums were added have their metadata computed
+// from the photo data, which is returned then, nil otherwise. release frees
+// the slot once the data is read. Errors are gRPC status errors.
+func (s *CatPhotosServer) lookupPhoto(ctx context.Context, catID, photoID uint64, release func()) (manul.PhotoMeta, []byte, error) {
+	meta, err := s.dbReader.GetPhotoInfo(catID, photoID)
+	if err != nil {
+		return manul.PhotoMeta{}, nil, photoNotFoundError(catID, photoID, err)
+	}
+	if meta.SHA256 != ([sha256.Size]byte{}) {
+		return meta, nil, nil
+	}
+
+	photoData, err := s.readRequestPhoto(ctx, catID, photoID, release)
+	if err != nil {
+		return manul.PhotoMeta{}, nil, err
+	}
+	createdAt := meta.CreatedAt
+	meta = manul.NewPhotoMeta(photoData)
+	meta.CreatedAt = createdAt
+	return meta, photoData, nil
+}
+
+// readRequestPhoto reads the photo data of a photo whose metadata was read
+// with the read slot of a request, and frees the slot with release.
+// Errors are gRPC status errors.
+func (s *CatPhotosServer) readRequestPhoto(ctx context.Context, catID, photoID uint64, release func()) ([]byte, error) {
+	photoData, err := func() ([]byte, error) {
+		defer release()
+		return manul.GetPhotoDataContext(ctx, s.dbReader, catID, photoID)
+	}()
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, status.FromContextError(ctxErr).Err()
+		}
+		// The photo metadata was read, so the data is missing or unreadable
+		if s.readErrors != nil {
+			s.readErrors.Record()
+		}
+		return nil, photoNotFoundError(catID, photoID, err)
+	}
+	return photoData, nil
+}
+
+// loadPhoto reads a photo unless photoData was already read and applies
+// scaling and format conversion if requested, scaled images are JPEG unless
+// another output format is requested. hash is the hex SHA-256 of the photo
+// data from its metadata. release frees the read slot of the
+// request once the data is read, scaling runs without it. Errors are gRPC
+// status errors.
+func (s *CatPhotosServer) loadPhoto(ctx context.Context, catID, photoID uint64, hash string, photoData []byte, opts scaleOptions, release func()) ([]byte, error) {
+	if photoData == nil {
+		var err error
+		photoData, err = s.readRequestPhoto(ctx, catID, photoID, release)
+		if err != nil {
+			return nil, err
+		}
+	}
+	release()
+
+	if !opts.scalingRequested() && !conversionRequested(photoData, opts.format) {
+		return photoData, nil
+	}
+	scaledData, err := s.scalePhoto(ctx, catID, photoID, hash, photoData, opts)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, status.FromContextError(ctx.Err()).Err()
+		}
+		return nil, status.Errorf(codes.Internal, "failed to scale image: %v", err)
+	}
+	return scaledData, nil
+}
+
+const (
+	// defaultChunkSize is the chunk size of GetPhotoChunked requests without one
+	defaultChunkSize = 256 << 10
+	// maxChunkSize caps requested chunk sizes well below the default
+	// 4MiB gRPC message size limit
+	maxChunkSize = 1 << 20
+)
+
+func (s *CatPhotosServer) GetPhotoChunked(req *pb.GetPhotoChunkedRequest, stream pb.CatPhotosService_GetPhotoChunkedServer) error {
+	if req.Photo == nil {
+		return status.Errorf(codes.InvalidArgument, "photo request is required")
+	}
+
+	chunkSize := int(req.ChunkSize)
+	if chunkSize == 0 {
+		chunkSize = defaultChunkSize
+	}
+	chunkSize = min(chunkSize, maxChunkSize)
+
+	resp, err := s.GetPhoto(stream.Context(), req.Photo)
+	if err != nil {
+		return err
+	}
+
+	// The first chunk carries the photo attributes, it is sent even for
+	// empty and not modified photos
+	data := resp.PhotoData
+	first := &pb.PhotoChunk{
+		Data:        data[:min(chunkSize, len(data))],
+		TotalSize:   uint64(len(data)),
+		ContentType: resp.ContentType,
+		NotModified: resp.NotModified,
+		ContentHash: resp.ContentHash,
+	}
+	if err := stream.Send(first); err != nil {
+		return fmt.Errorf("failed to send chunk: %v", err)
+	}
+
+	for offset := len(first.Data); offset < len(data); offset += chunkSize {
+		chunk := &pb.PhotoChunk{Data: data[offset:min(offset+chunkSize, len(data))]}
+		if err := stream.Send(chunk); err != nil {
+			return fmt.Errorf("failed to send chunk: %v", err)
+		}
+	}
+	return nil
+}
+
+// GetPhotoMetadata returns the photo attributes stored at ingest. The size
+// and dimensions of photos stored before they were added are read from the
+// photo data, the database is not written to.
+func (s *CatPhotosServer) GetPhotoMetadata(ctx context.Context, req *pb.GetPhotoMetadataRequest) (*pb.GetPhotoMetadataResponse, error) {
+	meta, err := s.dbReader.GetPhotoInfo(req.CatId, req.PhotoId)
+	if err != nil {
+		return nil, photoNotFoundError(req.CatId, req.PhotoId, err)
+	}
+
+	if meta.Size == 0 || !meta.HasDimensions() {
+		if err := s.acquireRead(ctx); err != nil {
+			return nil, err
+		}
+		photoData, err := s.readPhotoData(ctx, req.CatId, req.PhotoId)
+		if err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return nil, status.FromContextError(ctxErr).Err()
+			}
+			if s.readErrors != nil {
+				s.readErrors.Record()
+			}
+			return nil, photoNotFoundError(req.CatId, req.PhotoId, err)
+		}
+		meta.Size = int64(len(photoData))
+		meta.Width, meta.Height = manul.DecodeDimensions(photoData)
+	}
+
+	return &pb.GetPhotoMetadataResponse{
+		Width:  uint32(meta.Width),
+		Height: uint32(meta.Height),
+		Size:   uint64(meta.Size),
+		Format: meta.Format.String(),
+	}, nil
+}
+
+// GetRandomPhoto picks a random photo with manul.GetRandomPhoto, which
+// seeks instead of listing all keys in databases supporting it
+func (s *CatPhotosServer) GetRandomPhoto(ctx context.Context, req *pb.GetRandomPhotoRequest) (*pb.GetRandomPhotoResponse, error) {
+	catID, photoID, err := manul.GetRandomPhoto(s.dbReader)
+	if errors.Is(err, manul.ErrNoPhotos) {
+		return nil, status.Errorf(codes.NotFound, "database has no photos")
+	}
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to pick a random photo: %v", err)
+	}
+
+	resp := &pb.GetRandomPhotoResponse{CatId: catID, PhotoId: photoID}
+	if req.Photo == nil {
+		return resp, nil
+	}
+
+	photoReq := proto.Clone(req.Photo).(*pb.GetPhotoRequest)
+	photoReq.CatId = catID
+	photoReq.PhotoId = photoID
+	photoReq.IfNoneMatch = ""
+	resp.Photo, err = s.GetPhoto(ctx, photoReq)
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
 func (s *CatPhotosServer) GetPhotosStream(req *pb.GetPhotosStreamRequest, stream pb.CatPhotosService_GetPhotosStreamServer) error {
-	var err error
 	orca.CallMetricsRecorderFromContext(stream.Context())
+	startTime := time.Now()
 	defer func() {
 		if s.orcaReporter != nil {
-			s.orcaReporter.RecordRequest()
+			s.orcaReporter.RecordRequest(time.Since(startTime))
 		}
 	}()
 
+	opts := scaleOptions{width: req.Width, algorithm: req.ScalingAlgorithm}
+	var sent int64
 	for _, photoReq := range req.PhotoRequests {
-		// Get photo data
-		response := &pb.GetPhotosStreamResponse{
-			CatId:   photoReq.CatId,
-			PhotoId: photoReq.PhotoId,
-			Success: true,
-		}
-
-		if s.readLimiter != nil {
-			s.readLimiter <- struct{}{}
-		}
-		response.PhotoData, err = s.dbReader.GetPhotoData(photoReq.CatId, photoReq.PhotoId)
-		if s.readLimiter != nil {
-			<-s.readLimiter
-		}
-
+		response, err := s.getPhotoItem(stream.Context(), photoReq, opts)
 		if err != nil {
-			// Send error response
-			response.Success = false
-			response.ErrorMessage = err.Error()
+			return err
 		}
 
-		// Apply scaling if width > 0
-		if err == nil && req.Width > 0 {
-			response.PhotoData, err = scaleImage(response.PhotoData, req.Width, req.ScalingAlgorithm)
+		// A photo over the byte budget is replaced by a final error
+		// response, the remaining photos are not sent
+		if s.streamBytes > 0 && sent+int64(len(response.PhotoData)) > s.streamBytes {
+			streamBudgetExceeded.Inc()
+			msg := fmt.Sprintf("stream byte limit of %d exceeded after %d bytes, request the remaining photos in another stream", s.streamBytes, sent)
+			err := stream.Send(&pb.GetPhotosStreamResponse{
+				CatId:        photoReq.CatId,
+				PhotoId:      photoReq.PhotoId,
+				ErrorMessage: msg,
+			})
 			if err != nil {
-				response.Success = false
-				response.ErrorMessage = fmt.Sprintf("failed to scale image: %v", err)
+				return fmt.Errorf("failed to send response: %v", err)
 			}
+			return status.Error(codes.ResourceExhausted, msg)
 		}
+		sent += int64(len(response.PhotoData))
 
 		// Send the response
 		if err := stream.Send(response); err != nil {
@@ -226,3 +996,161 @@ func (s *CatPhotosServer) GetPhotosStream(req *pb.GetPhotosStreamRequest, stream
 
 	return nil
 }
+
+var streamBudgetExceeded = promauto.NewCounter(
+	prometheus.CounterOpts{
+		Name: "cat_photos_stream_budget_exceeded_total",
+		Help: "Number of GetPhotosStream calls stopped because they exceeded the stream byte limit",
+	},
+)
+
+// SetMaxStreamBytes limits the total photo data sent by a GetPhotosStream
+// call, 0 means no limit
+func (s *CatPhotosServer) SetMaxStreamBytes(maxBytes int64) {
+	s.streamBytes = maxBytes
+}
+
+// maxBatchPhotos is the maximum number of photos of a BatchGetPhotos request
+const maxBatchPhotos = 100
+
+func (s *CatPhotosServer) BatchGetPhotos(ctx context.Context, req *pb.BatchGetPhotosRequest) (*pb.BatchGetPhotosResponse, error) {
+	orca.CallMetricsRecorderFromContext(ctx)
+	startTime := time.Now()
+	defer func() {
+		if s.orcaReporter != nil {
+			s.orcaReporter.RecordRequest(time.Since(startTime))
+		}
+	}()
+
+	if len(req.PhotoRequests) > maxBatchPhotos {
+		return nil, status.Errorf(codes.ResourceExhausted, "%d photos requested, at most %d are allowed per batch", len(req.PhotoRequests), maxBatchPhotos)
+	}
+
+	opts := scaleOptions{width: req.Width, algorithm: req.ScalingAlgorithm}
+	res := &pb.BatchGetPhotosResponse{
+		Photos: make([]*pb.GetPhotosStreamResponse, 0, len(req.PhotoRequests)),
+	}
+	for _, photoReq := range req.PhotoRequests {
+		response, err := s.getPhotoItem(ctx, photoReq, opts)
+		if err != nil {
+			return nil, err
+		}
+		res.Photos = append(res.Photos, response)
+	}
+	return res, nil
+}
+
+// getPhotoItem reads and scales a photo of a multi-photo request. Failures
+// of the photo are reported in the response, the error is set only if the
+// whole request has to fail.
+func (s *CatPhotosServer) getPhotoItem(ctx context.Context, photoReq *pb.PhotoRequest, opts scaleOptions) (*pb.GetPhotosStreamResponse, error) {
+	// Get photo data
+	response := &pb.GetPhotosStreamResponse{
+		CatId:   photoReq.CatId,
+		PhotoId: photoReq.PhotoId,
+		Success: true,
+	}
+
+	if err := s.acquireRead(ctx); err != nil {
+		return nil, err
+	}
+	var err error
+	response.PhotoData, err = s.readPhotoData(ctx, photoReq.CatId, photoReq.PhotoId)
+
+	if err != nil {
+		// Batches and streams may request missing photos, only failed reads
+		// of photos known to exist affect health
+		if s.readErrors != nil && ctx.Err() == nil {
+			if _, metaErr := s.readPhotoMeta(ctx, photoReq.CatId, photoReq.PhotoId); metaErr == nil {
+				s.readErrors.Record()
+			}
+		}
+		// Send error response
+		response.Success = false
+		response.ErrorMessage = err.Error()
+	} else if s.hotKeys != nil {
+		s.hotKeys.Record(photoReq.CatId, photoReq.PhotoId)
+	}
+
+	// Apply scaling if requested, the scaler may keep the original bytes
+	if err == nil && opts.scalingRequested() {
+		response.PhotoData, err = s.scalePhoto(ctx, photoReq.CatId, photoReq.PhotoId, "", response.PhotoData, opts)
+		if err != nil {
+			response.Success = false
+			response.ErrorMessage = fmt.Sprintf("failed to scale image: %v", err)
+		}
+	}
+
+	if response.Success {
+		format := manul.DetectFormat(response.PhotoData).String()
+		response.SizeBytes = uint64(len(response.PhotoData))
+		response.Format = format
+		response.ContentType = manul.ContentType(format)
+	}
+	return response, nil
+}
+
+func (s *CatPhotosServer) DeletePhotos(ctx context.Context, req *pb.DeletePhotosRequest) (*pb.DeletePhotosResponse, error) {
+	if s.dbWriter == nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "server is read-only")
+	}
+
+	keys := make([]manul.PhotoKey, 0, len(req.PhotoRequests))
+	for _, photoReq := range req.PhotoRequests {
+		keys = append(keys, manul.PhotoKey{CatID: photoReq.CatId, PhotoID: photoReq.PhotoId})
+	}
+
+	deleted, err := s.dbWriter.DeletePhotos(keys)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to delete photos: %v", err)
+	}
+
+	if s.decoded != nil {
+		for _, key := range keys {
+			s.decoded.Remove(key.CatID, key.PhotoID)
+		}
+	}
+	if s.photos != nil {
+		for _, key := range keys {
+			s.photos.Remove(key.CatID, key.PhotoID)
+		}
+	}
+	if s.listings != nil {
+		for _, key := range keys {
+			s.listings.Remove(key.CatID)
+		}
+	}
+
+	return &pb.DeletePhotosResponse{
+		Deleted: deleted,
+	}, nil
+}
+
+func (s *CatPhotosServer) DeleteCat(ctx context.Context, req *pb.DeleteCatRequest) (*pb.DeleteCatResponse, error) {
+	if s.dbWriter == nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "server is read-only")
+	}
+
+	count, err := s.dbWriter.DeleteCat(req.CatId)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to delete cat %d: %v", req.CatId, err)
+	}
+
+	if s.decoded != nil {
+		s.decoded.RemoveCat(req.CatId)
+	}
+	if s.photos != nil {
+		s.photos.RemoveCat(req.CatId)
+	}
+	if s.listings != nil {
+		s.listings.Remove(req.CatId)
+	}
+
+	if count == 0 {
+		return nil, status.Errorf(codes.NotFound, "cat with ID %d not found", req.CatId)
+	}
+
+	return &pb.DeleteCatResponse{
+		DeletedCount: uint64(count),
+	}, nil
+}
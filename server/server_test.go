@@ -0,0 +1,1033 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mhbvr/manul"
+	"github.com/mhbvr/manul/db/bolt"
+	"github.com/mhbvr/manul/db/memory"
+	"github.com/mhbvr/manul/db/shadow"
+	pb "github.com/mhbvr/manul/proto"
+	"golang.org/x/image/draw"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// newTestServer returns a server on a memory database with photos 1 and 2
+// of cat 1 and photo 1 of cat 2
+func newTestServer(t *testing.T, readWrite bool) *CatPhotosServer {
+	t.Helper()
+
+	db := memory.New()
+	for _, key := range []manul.PhotoKey{{CatID: 1, PhotoID: 1}, {CatID: 1, PhotoID: 2}, {CatID: 2, PhotoID: 1}} {
+		if err := db.AddPhoto(key.CatID, key.PhotoID, newTestJPEG(t, 20, 10)); err != nil {
+			t.Fatalf("AddPhoto() failed: %v", err)
+		}
+	}
+
+	s, err := NewCatPhotosServer("", "", "", "", 0, readWrite, 1, nil, WithDBReader(db))
+	if err != nil {
+		t.Fatalf("NewCatPhotosServer() failed: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func newTestJPEG(t testing.TB, width, height int) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, image.NewRGBA(image.Rect(0, 0, width, height)), nil); err != nil {
+		t.Fatalf("jpeg.Encode() failed: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestScaleImage_Algorithms(t *testing.T) {
+	photoData := newTestJPEG(t, 200, 100)
+
+	tests := []struct {
+		algorithm pb.ScalingAlgorithm
+		width     uint32
+		wantWidth int // 0 means the original data is returned
+	}{
+		{pb.ScalingAlgorithm_NONE, 50, 0},
+		{pb.ScalingAlgorithm_NEAREST_NEIGHBOR, 50, 50},
+		{pb.ScalingAlgorithm_BILINEAR, 50, 50},
+		{pb.ScalingAlgorithm_CATMULL_ROM, 50, 50},
+		{pb.ScalingAlgorithm_APPROX_BILINEAR, 50, 50},
+		{pb.ScalingAlgorithm_AUTO, 50, 50},
+		{pb.ScalingAlgorithm_AUTO, 0, 0},
+		{pb.ScalingAlgorithm_BILINEAR, 400, 0}, // No upscaling
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.algorithm.String(), func(t *testing.T) {
+			got, err := scaleImage(photoData, scaleOptions{width: tt.width, algorithm: tt.algorithm})
+			if err != nil {
+				t.Fatalf("scaleImage() failed: %v", err)
+			}
+
+			if tt.wantWidth == 0 {
+				if !bytes.Equal(got, photoData) {
+					t.Errorf("scaleImage() changed the photo, want the original")
+				}
+				return
+			}
+
+			img, err := jpeg.Decode(bytes.NewReader(got))
+			if err != nil {
+				t.Fatalf("Failed to decode scaled image: %v", err)
+			}
+			if img.Bounds().Dx() != tt.wantWidth || img.Bounds().Dy() != tt.wantWidth/2 {
+				t.Errorf("Scaled image size = %v, want %dx%d", img.Bounds().Size(), tt.wantWidth, tt.wantWidth/2)
+			}
+		})
+	}
+}
+
+func BenchmarkScaleDecoded(b *testing.B) {
+	photoData := newTestJPEG(b, 1600, 1200)
+	img, err := jpeg.Decode(bytes.NewReader(photoData))
+	if err != nil {
+		b.Fatalf("Failed to decode photo: %v", err)
+	}
+
+	tests := []struct {
+		algorithm pb.ScalingAlgorithm
+		format    pb.OutputFormat
+	}{
+		{pb.ScalingAlgorithm_APPROX_BILINEAR, pb.OutputFormat_JPEG},
+		{pb.ScalingAlgorithm_APPROX_BILINEAR, pb.OutputFormat_PNG},
+		{pb.ScalingAlgorithm_BILINEAR, pb.OutputFormat_JPEG},
+	}
+	defer func(p *imagePool) { scalingImagePool = p }(scalingImagePool)
+	for _, pool := range []*imagePool{nil, {maxBytes: 16 << 20}} {
+		scalingImagePool = pool
+		for _, tt := range tests {
+			name := fmt.Sprintf("%s/%s/pool=%v", tt.algorithm, tt.format, pool != nil)
+			b.Run(name, func(b *testing.B) {
+				opts := scaleOptions{width: 400, algorithm: tt.algorithm, format: tt.format}
+				b.ReportAllocs()
+				for b.Loop() {
+					if _, err := scaleDecoded(img, photoData, opts); err != nil {
+						b.Fatalf("scaleDecoded() failed: %v", err)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestGetScaler(t *testing.T) {
+	tests := []struct {
+		name         string
+		algorithm    pb.ScalingAlgorithm
+		currentWidth int
+		targetWidth  int
+		want         draw.Scaler
+	}{
+		{"nearest neighbor", pb.ScalingAlgorithm_NEAREST_NEIGHBOR, 100, 50, draw.NearestNeighbor},
+		{"bilinear", pb.ScalingAlgorithm_BILINEAR, 100, 50, draw.BiLinear},
+		{"catmull rom", pb.ScalingAlgorithm_CATMULL_ROM, 100, 50, draw.CatmullRom},
+		{"approx bilinear", pb.ScalingAlgorithm_APPROX_BILINEAR, 100, 50, draw.ApproxBiLinear},
+		{"unknown", pb.ScalingAlgorithm(100), 100, 50, draw.BiLinear},
+		{"auto small ratio", pb.ScalingAlgorithm_AUTO, 100, 60, draw.CatmullRom},
+		{"auto medium ratio", pb.ScalingAlgorithm_AUTO, 100, 25, draw.BiLinear},
+		{"auto large ratio", pb.ScalingAlgorithm_AUTO, 1000, 100, draw.ApproxBiLinear},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := getScaler(tt.algorithm, tt.currentWidth, tt.targetWidth)
+			if got != tt.want {
+				t.Errorf("getScaler() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPhotoContentHash_None(t *testing.T) {
+	meta := manul.NewPhotoMeta([]byte("photo"))
+	if got := photoContentHash(meta, scaleOptions{width: 50, algorithm: pb.ScalingAlgorithm_NONE}); got != meta.Hash() {
+		t.Errorf("photoContentHash() with NONE = %q, want the photo hash %q", got, meta.Hash())
+	}
+	if got := photoContentHash(meta, scaleOptions{width: 50, algorithm: pb.ScalingAlgorithm_AUTO}); got == meta.Hash() {
+		t.Errorf("photoContentHash() with AUTO = the photo hash, want a scaled variant")
+	}
+	if got := photoContentHash(meta, scaleOptions{format: pb.OutputFormat_PNG}); got == meta.Hash() {
+		t.Errorf("photoContentHash() with PNG = the photo hash, want a converted variant")
+	}
+	if got := photoContentHash(meta, scaleOptions{quality: 50}); got != meta.Hash() {
+		t.Errorf("photoContentHash() with only quality = %q, want the photo hash %q", got, meta.Hash())
+	}
+	fit := photoContentHash(meta, scaleOptions{width: 50, height: 50, algorithm: pb.ScalingAlgorithm_AUTO})
+	fill := photoContentHash(meta, scaleOptions{width: 50, height: 50, fit: pb.FitMode_FILL, algorithm: pb.ScalingAlgorithm_AUTO})
+	if fit == fill {
+		t.Errorf("photoContentHash() is the same for FIT and FILL, want different variants")
+	}
+}
+
+func TestScaleGeometry(t *testing.T) {
+	bounds := image.Rect(0, 0, 200, 100)
+	bilinear := pb.ScalingAlgorithm_BILINEAR
+
+	tests := []struct {
+		name    string
+		opts    scaleOptions
+		wantSrc image.Rectangle
+		wantW   int
+		wantH   int
+		wantOK  bool
+	}{
+		{"width only", scaleOptions{algorithm: bilinear, width: 50}, bounds, 50, 25, true},
+		{"height only", scaleOptions{algorithm: bilinear, height: 50}, bounds, 100, 50, true},
+		{"height only no upscaling", scaleOptions{algorithm: bilinear, height: 200}, bounds, 0, 0, false},
+		{"fit by width", scaleOptions{algorithm: bilinear, width: 50, height: 50}, bounds, 50, 25, true},
+		{"fit by height", scaleOptions{algorithm: bilinear, width: 150, height: 50}, bounds, 100, 50, true},
+		{"fit no upscaling", scaleOptions{algorithm: bilinear, width: 400, height: 400}, bounds, 0, 0, false},
+		{"fill crops width", scaleOptions{algorithm: bilinear, width: 50, height: 50, fit: pb.FitMode_FILL}, image.Rect(50, 0, 150, 100), 50, 50, true},
+		{"fill crops height", scaleOptions{algorithm: bilinear, width: 100, height: 25, fit: pb.FitMode_FILL}, image.Rect(0, 25, 200, 75), 100, 25, true},
+		{"stretch", scaleOptions{algorithm: bilinear, width: 50, height: 50, fit: pb.FitMode_STRETCH}, bounds, 50, 50, true},
+		{"none", scaleOptions{width: 50, height: 50, algorithm: pb.ScalingAlgorithm_NONE}, bounds, 0, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src, w, h, ok := tt.opts.scaleGeometry(bounds)
+			if ok != tt.wantOK {
+				t.Fatalf("scaleGeometry() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if !ok {
+				return
+			}
+			if src != tt.wantSrc || w != tt.wantW || h != tt.wantH {
+				t.Errorf("scaleGeometry() = %v %dx%d, want %v %dx%d", src, w, h, tt.wantSrc, tt.wantW, tt.wantH)
+			}
+		})
+	}
+}
+
+func TestGetPhoto(t *testing.T) {
+	s := newTestServer(t, false)
+	ctx := context.Background()
+
+	resp, err := s.GetPhoto(ctx, &pb.GetPhotoRequest{CatId: 1, PhotoId: 2})
+	if err != nil {
+		t.Fatalf("GetPhoto() failed: %v", err)
+	}
+	if resp.ContentType != "image/jpeg" || len(resp.PhotoData) == 0 {
+		t.Errorf("GetPhoto() = %d bytes of %q, want a jpeg photo", len(resp.PhotoData), resp.ContentType)
+	}
+
+	resp, err = s.GetPhoto(ctx, &pb.GetPhotoRequest{CatId: 1, PhotoId: 2, IfNoneMatch: resp.ContentHash})
+	if err != nil {
+		t.Fatalf("GetPhoto() failed: %v", err)
+	}
+	if !resp.NotModified || len(resp.PhotoData) != 0 {
+		t.Errorf("GetPhoto() with matching hash: not_modified = %v, %d bytes, want not modified", resp.NotModified, len(resp.PhotoData))
+	}
+
+	_, err = s.GetPhoto(ctx, &pb.GetPhotoRequest{CatId: 1, PhotoId: 3})
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("GetPhoto() of a missing photo error = %v, want NotFound", err)
+	}
+}
+
+func TestGetPhoto_OutputFormat(t *testing.T) {
+	s := newTestServer(t, false)
+	original := newTestJPEG(t, 20, 10)
+
+	tests := []struct {
+		name            string
+		width           uint32
+		format          pb.OutputFormat
+		wantContentType string
+		wantWidth       int
+	}{
+		{"original", 0, pb.OutputFormat_ORIGINAL, "image/jpeg", 20},
+		{"same format", 0, pb.OutputFormat_JPEG, "image/jpeg", 20},
+		{"converted", 0, pb.OutputFormat_PNG, "image/png", 20},
+		{"scaled and converted", 10, pb.OutputFormat_PNG, "image/png", 10},
+		{"not upscaled", 40, pb.OutputFormat_PNG, "image/png", 20},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := s.GetPhoto(context.Background(), &pb.GetPhotoRequest{
+				CatId:            1,
+				PhotoId:          1,
+				Width:            tt.width,
+				ScalingAlgorithm: pb.ScalingAlgorithm_BILINEAR,
+				OutputFormat:     tt.format,
+			})
+			if err != nil {
+				t.Fatalf("GetPhoto() failed: %v", err)
+			}
+			if resp.ContentType != tt.wantContentType {
+				t.Errorf("ContentType = %q, want %q", resp.ContentType, tt.wantContentType)
+			}
+			if tt.format != pb.OutputFormat_PNG && !bytes.Equal(resp.PhotoData, original) {
+				t.Errorf("GetPhoto() re-encoded the photo, want the original")
+			}
+
+			cfg, _, err := image.DecodeConfig(bytes.NewReader(resp.PhotoData))
+			if err != nil {
+				t.Fatalf("Failed to decode the photo: %v", err)
+			}
+			if cfg.Width != tt.wantWidth {
+				t.Errorf("Photo width = %d, want %d", cfg.Width, tt.wantWidth)
+			}
+		})
+	}
+}
+
+func TestGetPhoto_Quality(t *testing.T) {
+	s := newTestServer(t, false)
+
+	sizes := make(map[uint32]int)
+	for _, quality := range []uint32{10, 100, 1000} {
+		resp, err := s.GetPhoto(context.Background(), &pb.GetPhotoRequest{
+			CatId:            1,
+			PhotoId:          1,
+			Width:            10,
+			ScalingAlgorithm: pb.ScalingAlgorithm_BILINEAR,
+			Quality:          quality,
+		})
+		if err != nil {
+			t.Fatalf("GetPhoto() with quality %d failed: %v", quality, err)
+		}
+		sizes[quality] = len(resp.PhotoData)
+	}
+
+	if sizes[10] >= sizes[100] {
+		t.Errorf("Photo size with quality 10 = %d, want less than %d with quality 100", sizes[10], sizes[100])
+	}
+	if sizes[1000] != sizes[100] {
+		t.Errorf("Photo size with quality 1000 = %d, want %d as with clamped quality 100", sizes[1000], sizes[100])
+	}
+}
+
+func TestGetPhoto_FitWithoutSize(t *testing.T) {
+	s := newTestServer(t, false)
+
+	_, err := s.GetPhoto(context.Background(), &pb.GetPhotoRequest{CatId: 1, PhotoId: 1, Fit: pb.FitMode_FILL})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("GetPhoto() error = %v, want InvalidArgument", err)
+	}
+}
+
+func TestGetPhoto_SizePreset(t *testing.T) {
+	s := newTestServer(t, false)
+	s.SetSizePresets(5, 10, 0)
+	ctx := context.Background()
+
+	tests := []struct {
+		preset    pb.SizePreset
+		wantWidth int
+	}{
+		{pb.SizePreset_THUMB, 5},
+		{pb.SizePreset_MEDIUM, 10},
+		{pb.SizePreset_FULL, 20},
+	}
+	for _, tt := range tests {
+		// Explicit size fields are ignored
+		resp, err := s.GetPhoto(ctx, &pb.GetPhotoRequest{CatId: 1, PhotoId: 1, SizePreset: tt.preset, Width: 15, Fit: pb.FitMode_FILL})
+		if err != nil {
+			t.Fatalf("GetPhoto() with preset %s failed: %v", tt.preset, err)
+		}
+		cfg, _, err := image.DecodeConfig(bytes.NewReader(resp.PhotoData))
+		if err != nil {
+			t.Fatalf("Failed to decode the photo: %v", err)
+		}
+		if cfg.Width != tt.wantWidth {
+			t.Errorf("Photo width with preset %s = %d, want %d", tt.preset, cfg.Width, tt.wantWidth)
+		}
+	}
+
+	// A preset is the same photo as its explicit size
+	thumb, err := s.GetPhoto(ctx, &pb.GetPhotoRequest{CatId: 1, PhotoId: 1, SizePreset: pb.SizePreset_THUMB})
+	if err != nil {
+		t.Fatalf("GetPhoto() failed: %v", err)
+	}
+	explicit, err := s.GetPhoto(ctx, &pb.GetPhotoRequest{CatId: 1, PhotoId: 1, Width: 5, ScalingAlgorithm: pb.ScalingAlgorithm_AUTO})
+	if err != nil {
+		t.Fatalf("GetPhoto() failed: %v", err)
+	}
+	if thumb.ContentHash != explicit.ContentHash {
+		t.Errorf("THUMB content hash = %q, want %q of width 5", thumb.ContentHash, explicit.ContentHash)
+	}
+
+	_, err = s.GetPhoto(ctx, &pb.GetPhotoRequest{CatId: 1, PhotoId: 1, SizePreset: pb.SizePreset(99)})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("GetPhoto() with unknown preset error = %v, want InvalidArgument", err)
+	}
+}
+
+func TestGetPhoto_WebPUnsupported(t *testing.T) {
+	s := newTestServer(t, false)
+
+	_, err := s.GetPhoto(context.Background(), &pb.GetPhotoRequest{CatId: 1, PhotoId: 1, OutputFormat: pb.OutputFormat_WEBP})
+	if status.Code(err) != codes.Unimplemented {
+		t.Errorf("GetPhoto() error = %v, want Unimplemented", err)
+	}
+}
+
+func TestNegotiateFormat(t *testing.T) {
+	tests := []struct {
+		name     string
+		accepted []pb.OutputFormat
+		want     pb.OutputFormat
+		wantOk   bool
+	}{
+		{"webp ignored", []pb.OutputFormat{pb.OutputFormat_WEBP, pb.OutputFormat_JPEG}, pb.OutputFormat_JPEG, true},
+		{"jpeg before png", []pb.OutputFormat{pb.OutputFormat_PNG, pb.OutputFormat_JPEG}, pb.OutputFormat_JPEG, true},
+		{"any format", []pb.OutputFormat{pb.OutputFormat_ORIGINAL, pb.OutputFormat_PNG}, pb.OutputFormat_PNG, true},
+		{"only any format", []pb.OutputFormat{pb.OutputFormat_ORIGINAL}, pb.OutputFormat_ORIGINAL, true},
+		{"only webp", []pb.OutputFormat{pb.OutputFormat_WEBP}, pb.OutputFormat_ORIGINAL, false},
+	}
+
+	for _, tt := range tests {
+		got, ok := negotiateFormat(tt.accepted)
+		if got != tt.want || ok != tt.wantOk {
+			t.Errorf("%s: negotiateFormat(%v) = %s, %v, want %s, %v", tt.name, tt.accepted, got, ok, tt.want, tt.wantOk)
+		}
+	}
+}
+
+func TestGetPhoto_AcceptFormats(t *testing.T) {
+	s := newTestServer(t, false)
+	ctx := context.Background()
+
+	// Accepted formats replace output_format
+	resp, err := s.GetPhoto(ctx, &pb.GetPhotoRequest{
+		CatId:         1,
+		PhotoId:       1,
+		OutputFormat:  pb.OutputFormat_JPEG,
+		AcceptFormats: []pb.OutputFormat{pb.OutputFormat_WEBP, pb.OutputFormat_PNG},
+	})
+	if err != nil {
+		t.Fatalf("GetPhoto() failed: %v", err)
+	}
+	if resp.Format != pb.OutputFormat_PNG || resp.ContentType != "image/png" {
+		t.Errorf("GetPhoto() format = %s of %q, want PNG of image/png", resp.Format, resp.ContentType)
+	}
+
+	_, err = s.GetPhoto(ctx, &pb.GetPhotoRequest{CatId: 1, PhotoId: 1, AcceptFormats: []pb.OutputFormat{pb.OutputFormat_WEBP}})
+	if status.Code(err) != codes.Unimplemented {
+		t.Errorf("GetPhoto() accepting only WebP error = %v, want Unimplemented", err)
+	}
+}
+
+// legacyMetaReader is a database reader returning metadata stored before
+// sizes and dimensions were added
+type legacyMetaReader struct {
+	manul.DBReader
+}
+
+func (r legacyMetaReader) GetPhotoInfo(catID, photoID uint64) (manul.PhotoMeta, error) {
+	meta, err := r.DBReader.GetPhotoInfo(catID, photoID)
+	meta.Size, meta.Width, meta.Height = 0, 0, 0
+	return meta, err
+}
+
+func TestGetPhotoMetadata(t *testing.T) {
+	s := newTestServer(t, false)
+	want := &pb.GetPhotoMetadataResponse{
+		Width:  20,
+		Height: 10,
+		Size:   uint64(len(newTestJPEG(t, 20, 10))),
+		Format: "jpeg",
+	}
+
+	for _, legacy := range []bool{false, true} {
+		if legacy {
+			s.dbReader = legacyMetaReader{s.dbReader}
+		}
+		resp, err := s.GetPhotoMetadata(context.Background(), &pb.GetPhotoMetadataRequest{CatId: 1, PhotoId: 1})
+		if err != nil {
+			t.Fatalf("GetPhotoMetadata(legacy=%v) failed: %v", legacy, err)
+		}
+		if resp.Width != want.Width || resp.Height != want.Height || resp.Size != want.Size || resp.Format != want.Format {
+			t.Errorf("GetPhotoMetadata(legacy=%v) = %v, want %v", legacy, resp, want)
+		}
+	}
+
+	_, err := s.GetPhotoMetadata(context.Background(), &pb.GetPhotoMetadataRequest{CatId: 1, PhotoId: 3})
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("GetPhotoMetadata() of a missing photo error = %v, want NotFound", err)
+	}
+}
+
+func TestGetRandomPhoto(t *testing.T) {
+	s := newTestServer(t, false)
+	ctx := context.Background()
+
+	resp, err := s.GetRandomPhoto(ctx, &pb.GetRandomPhotoRequest{})
+	if err != nil {
+		t.Fatalf("GetRandomPhoto() failed: %v", err)
+	}
+	if exists, _ := s.dbReader.PhotoExists(resp.CatId, resp.PhotoId); !exists || resp.Photo != nil {
+		t.Errorf("GetRandomPhoto() = cat %d, photo %d, photo set %v, want the IDs of a stored photo", resp.CatId, resp.PhotoId, resp.Photo != nil)
+	}
+
+	// The request photo IDs are replaced by the random ones
+	resp, err = s.GetRandomPhoto(ctx, &pb.GetRandomPhotoRequest{Photo: &pb.GetPhotoRequest{CatId: 9, PhotoId: 9, Width: 10, ScalingAlgorithm: pb.ScalingAlgorithm_BILINEAR}})
+	if err != nil {
+		t.Fatalf("GetRandomPhoto() failed: %v", err)
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(resp.Photo.GetPhotoData()))
+	if err != nil {
+		t.Fatalf("Failed to decode the photo: %v", err)
+	}
+	if cfg.Width != 10 {
+		t.Errorf("Photo width = %d, want 10", cfg.Width)
+	}
+
+	empty, err := NewCatPhotosServer("", "", "", "", 0, false, 0, nil, WithDBReader(memory.New()))
+	if err != nil {
+		t.Fatalf("NewCatPhotosServer() failed: %v", err)
+	}
+	defer empty.Close()
+	_, err = empty.GetRandomPhoto(ctx, &pb.GetRandomPhotoRequest{})
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("GetRandomPhoto() of an empty database error = %v, want NotFound", err)
+	}
+}
+
+func TestGetPhoto_ReadRejection(t *testing.T) {
+	s := newTestServer(t, false)
+	s.EnableReadRejection()
+	req := &pb.GetPhotoRequest{CatId: 1, PhotoId: 1}
+
+	// Take the only read slot
+	s.readLimiter <- struct{}{}
+	if _, err := s.GetPhoto(context.Background(), req); status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("GetPhoto() with a full read limiter error = %v, want ResourceExhausted", err)
+	}
+
+	<-s.readLimiter
+	if _, err := s.GetPhoto(context.Background(), req); err != nil {
+		t.Errorf("GetPhoto() with a free read slot failed: %v", err)
+	}
+}
+
+func TestGetPhoto_ReadLimiterContext(t *testing.T) {
+	s := newTestServer(t, false)
+	req := &pb.GetPhotoRequest{CatId: 1, PhotoId: 1}
+
+	// Take the only read slot, reads wait until their context is done
+	s.readLimiter <- struct{}{}
+	defer func() { <-s.readLimiter }()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := s.GetPhoto(ctx, req); status.Code(err) != codes.DeadlineExceeded {
+		t.Errorf("GetPhoto() with an expiring context error = %v, want DeadlineExceeded", err)
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	if _, err := s.GetPhoto(ctx, req); status.Code(err) != codes.Canceled {
+		t.Errorf("GetPhoto() with a cancelled context error = %v, want Canceled", err)
+	}
+}
+
+// limitedMetaReader is a database reader recording the read slots taken
+// while the photo metadata is read. It takes the only read slot from another
+// goroutine as soon as the slot is released after the metadata read.
+type limitedMetaReader struct {
+	manul.DBReader
+	s         *CatPhotosServer
+	slots     int
+	dataReads int
+	taken     chan struct{}
+}
+
+func (r *limitedMetaReader) GetPhotoInfo(catID, photoID uint64) (manul.PhotoMeta, error) {
+	r.slots = len(r.s.readLimiter)
+	go func() {
+		r.s.readLimiter <- struct{}{}
+		close(r.taken)
+	}()
+	// Let the goroutine block on the full read limiter
+	time.Sleep(10 * time.Millisecond)
+	return r.DBReader.GetPhotoInfo(catID, photoID)
+}
+
+func (r *limitedMetaReader) GetPhotoData(catID, photoID uint64) ([]byte, error) {
+	r.dataReads++
+	return r.DBReader.GetPhotoData(catID, photoID)
+}
+
+func TestGetPhoto_MetaReadSlot(t *testing.T) {
+	for _, legacy := range []bool{false, true} {
+		s := newTestServer(t, false)
+		s.EnableReadRejection()
+		var db manul.DBReader = s.dbReader
+		if legacy {
+			db = legacyHashReader{db}
+		}
+		reader := &limitedMetaReader{DBReader: db, s: s, taken: make(chan struct{})}
+		s.dbReader = reader
+
+		// The data is read with the slot of the metadata read, so the
+		// request is not rejected when another one takes the slot after it
+		if _, err := s.GetPhoto(context.Background(), &pb.GetPhotoRequest{CatId: 1, PhotoId: 1, Width: 10}); err != nil {
+			t.Fatalf("GetPhoto(legacy=%v) failed: %v", legacy, err)
+		}
+		if reader.slots != 1 {
+			t.Errorf("GetPhoto(legacy=%v) read slots taken while reading metadata = %d, want 1", legacy, reader.slots)
+		}
+		if reader.dataReads != 1 {
+			t.Errorf("GetPhoto(legacy=%v) read the photo data %d times, want 1", legacy, reader.dataReads)
+		}
+		select {
+		case <-reader.taken:
+			<-s.readLimiter
+		case <-time.After(time.Second):
+			t.Fatalf("GetPhoto(legacy=%v) did not read the metadata with GetPhotoInfo", legacy)
+		}
+	}
+}
+
+// legacyHashReader is a database reader returning metadata stored before
+// checksums were added
+type legacyHashReader struct {
+	manul.DBReader
+}
+
+func (r legacyHashReader) GetPhotoInfo(catID, photoID uint64) (manul.PhotoMeta, error) {
+	meta, err := r.DBReader.GetPhotoInfo(catID, photoID)
+	return manul.PhotoMeta{Format: meta.Format}, err
+}
+
+// panicReader is a database reader whose photo reads panic
+type panicReader struct {
+	manul.DBReader
+}
+
+func (panicReader) GetPhotoData(catID, photoID uint64) ([]byte, error) {
+	panic("read failed")
+}
+
+func TestReadPhotoData_ReleasesOnPanic(t *testing.T) {
+	s := newTestServer(t, false)
+	s.dbReader = panicReader{s.dbReader}
+
+	if err := s.acquireRead(context.Background()); err != nil {
+		t.Fatalf("acquireRead() failed: %v", err)
+	}
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("readPhotoData() did not panic")
+			}
+		}()
+		s.readPhotoData(context.Background(), 1, 1)
+	}()
+
+	if n := len(s.readLimiter); n != 0 {
+		t.Errorf("Read slots taken after a panic = %d, want 0", n)
+	}
+}
+
+// failingDataReader is a database reader whose photo data reads fail
+type failingDataReader struct {
+	manul.DBReader
+}
+
+func (failingDataReader) GetPhotoData(catID, photoID uint64) ([]byte, error) {
+	return nil, errors.New("read failed")
+}
+
+func TestBatchGetPhotos_RecordsReadErrors(t *testing.T) {
+	s := newTestServer(t, false)
+	s.dbReader = failingDataReader{s.dbReader}
+	s.readErrors = &readErrorMonitor{cancel: func() {}}
+
+	_, err := s.BatchGetPhotos(context.Background(), &pb.BatchGetPhotosRequest{
+		PhotoRequests: []*pb.PhotoRequest{
+			{CatId: 1, PhotoId: 1},
+			{CatId: 1, PhotoId: 3},
+			{CatId: 2, PhotoId: 1},
+		},
+	})
+	if err != nil {
+		t.Fatalf("BatchGetPhotos() failed: %v", err)
+	}
+	// The missing photo 1/3 is not a read error
+	if n := s.readErrors.errors.Load(); n != 2 {
+		t.Errorf("Read errors recorded = %d, want 2", n)
+	}
+}
+
+func TestBatchGetPhotos(t *testing.T) {
+	s := newTestServer(t, false)
+
+	resp, err := s.BatchGetPhotos(context.Background(), &pb.BatchGetPhotosRequest{
+		PhotoRequests: []*pb.PhotoRequest{
+			{CatId: 1, PhotoId: 1},
+			{CatId: 1, PhotoId: 3},
+			{CatId: 2, PhotoId: 1},
+		},
+		Width:            10,
+		ScalingAlgorithm: pb.ScalingAlgorithm_BILINEAR,
+	})
+	if err != nil {
+		t.Fatalf("BatchGetPhotos() failed: %v", err)
+	}
+
+	wantSuccess := []bool{true, false, true}
+	if len(resp.Photos) != len(wantSuccess) {
+		t.Fatalf("BatchGetPhotos() returned %d photos, want %d", len(resp.Photos), len(wantSuccess))
+	}
+	for i, photo := range resp.Photos {
+		if photo.Success != wantSuccess[i] {
+			t.Errorf("Photo %d success = %v, want %v", i, photo.Success, wantSuccess[i])
+		}
+		if !photo.Success {
+			if photo.SizeBytes != 0 || photo.Format != "" {
+				t.Errorf("Failed photo %d size = %d, format = %q, want unset", i, photo.SizeBytes, photo.Format)
+			}
+			continue
+		}
+		if photo.SizeBytes != uint64(len(photo.PhotoData)) || photo.Format != "jpeg" {
+			t.Errorf("Photo %d size = %d, format = %q, want %d, jpeg", i, photo.SizeBytes, photo.Format, len(photo.PhotoData))
+		}
+		cfg, err := jpeg.DecodeConfig(bytes.NewReader(photo.PhotoData))
+		if err != nil {
+			t.Fatalf("Failed to decode photo %d: %v", i, err)
+		}
+		if cfg.Width != 10 {
+			t.Errorf("Photo %d width = %d, want 10", i, cfg.Width)
+		}
+	}
+}
+
+func TestBatchGetPhotos_ContentType(t *testing.T) {
+	db := memory.New()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 20, 10))); err != nil {
+		t.Fatalf("png.Encode() failed: %v", err)
+	}
+	if err := db.AddPhoto(1, 1, buf.Bytes()); err != nil {
+		t.Fatalf("AddPhoto() failed: %v", err)
+	}
+	s, err := NewCatPhotosServer("", "", "", "", 0, false, 1, nil, WithDBReader(db))
+	if err != nil {
+		t.Fatalf("NewCatPhotosServer() failed: %v", err)
+	}
+	defer s.Close()
+
+	for _, width := range []uint32{10, 40} {
+		resp, err := s.BatchGetPhotos(context.Background(), &pb.BatchGetPhotosRequest{
+			PhotoRequests:    []*pb.PhotoRequest{{CatId: 1, PhotoId: 1}},
+			Width:            width,
+			ScalingAlgorithm: pb.ScalingAlgorithm_BILINEAR,
+		})
+		if err != nil {
+			t.Fatalf("BatchGetPhotos() failed: %v", err)
+		}
+		photo := resp.Photos[0]
+		want := manul.ContentType(manul.DetectFormat(photo.PhotoData).String())
+		if !photo.Success || photo.ContentType != want {
+			t.Errorf("Photo of width %d content type = %q, want %q", width, photo.ContentType, want)
+		}
+		if width == 40 && photo.ContentType != "image/png" {
+			t.Errorf("Not upscaled photo content type = %q, want image/png", photo.ContentType)
+		}
+	}
+}
+
+// dataOnlyReader fails the meta lookups an unscaled batch item must not make
+type dataOnlyReader struct {
+	manul.DBReader
+}
+
+func (dataOnlyReader) GetPhotoMeta(catID, photoID uint64) (manul.PhotoMeta, error) {
+	return manul.PhotoMeta{}, errors.New("unexpected meta lookup")
+}
+
+func (dataOnlyReader) GetPhotoFormat(catID, photoID uint64) (string, error) {
+	return "", errors.New("unexpected format lookup")
+}
+
+func TestBatchGetPhotos_UnscaledContentType(t *testing.T) {
+	db := memory.New()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 20, 10))); err != nil {
+		t.Fatalf("png.Encode() failed: %v", err)
+	}
+	if err := db.AddPhoto(1, 1, buf.Bytes()); err != nil {
+		t.Fatalf("AddPhoto() failed: %v", err)
+	}
+	s, err := NewCatPhotosServer("", "", "", "", 0, false, 1, nil, WithDBReader(dataOnlyReader{db}))
+	if err != nil {
+		t.Fatalf("NewCatPhotosServer() failed: %v", err)
+	}
+	defer s.Close()
+
+	resp, err := s.BatchGetPhotos(context.Background(), &pb.BatchGetPhotosRequest{
+		PhotoRequests: []*pb.PhotoRequest{{CatId: 1, PhotoId: 1}},
+	})
+	if err != nil {
+		t.Fatalf("BatchGetPhotos() failed: %v", err)
+	}
+	photo := resp.Photos[0]
+	if !photo.Success || photo.ContentType != "image/png" || photo.Format != "png" {
+		t.Errorf("Unscaled photo = %q of %q, want png of image/png", photo.Format, photo.ContentType)
+	}
+}
+
+func TestBatchGetPhotos_TooMany(t *testing.T) {
+	s := newTestServer(t, false)
+
+	req := &pb.BatchGetPhotosRequest{}
+	for i := 0; i <= maxBatchPhotos; i++ {
+		req.PhotoRequests = append(req.PhotoRequests, &pb.PhotoRequest{CatId: 1, PhotoId: 1})
+	}
+	if _, err := s.BatchGetPhotos(context.Background(), req); status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("BatchGetPhotos() with %d photos error = %v, want ResourceExhausted", len(req.PhotoRequests), err)
+	}
+}
+
+func TestListCatsAndPhotos(t *testing.T) {
+	s := newTestServer(t, false)
+	ctx := context.Background()
+
+	cats, err := s.ListCats(ctx, &pb.ListCatsRequest{})
+	if err != nil {
+		t.Fatalf("ListCats() failed: %v", err)
+	}
+	if len(cats.CatIds) != 2 {
+		t.Errorf("ListCats() = %v, want 2 cats", cats.CatIds)
+	}
+
+	photos, err := s.ListPhotos(ctx, &pb.ListPhotosRequest{CatId: 1})
+	if err != nil {
+		t.Fatalf("ListPhotos() failed: %v", err)
+	}
+	if len(photos.PhotoIds) != 2 {
+		t.Errorf("ListPhotos() = %v, want 2 photos", photos.PhotoIds)
+	}
+}
+
+func TestEmptyDatabase(t *testing.T) {
+	s, err := NewCatPhotosServer("", "", "", "", 0, false, 0, nil, WithDBReader(memory.New()))
+	if err != nil {
+		t.Fatalf("NewCatPhotosServer() failed: %v", err)
+	}
+	defer s.Close()
+	s.EnableListingCache(time.Hour, 100)
+	ctx := context.Background()
+
+	cats, err := s.ListCats(ctx, &pb.ListCatsRequest{})
+	if err != nil {
+		t.Fatalf("ListCats() failed: %v", err)
+	}
+	if len(cats.CatIds) != 0 || cats.NextPageToken != "" {
+		t.Errorf("ListCats() = %v, %q, want no cats and no next page", cats.CatIds, cats.NextPageToken)
+	}
+
+	_, err = s.ListPhotos(ctx, &pb.ListPhotosRequest{CatId: 1})
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("ListPhotos() error = %v, want NotFound", err)
+	}
+	_, err = s.GetPhoto(ctx, &pb.GetPhotoRequest{CatId: 1, PhotoId: 1})
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("GetPhoto() error = %v, want NotFound", err)
+	}
+
+	count, err := countCats(s)
+	if err != nil || count != 0 {
+		t.Errorf("countCats() = %d, %v, want 0, nil", count, err)
+	}
+}
+
+func TestShadowDB(t *testing.T) {
+	dir := t.TempDir()
+	primaryPath, shadowPath := filepath.Join(dir, "primary.db"), filepath.Join(dir, "shadow.db")
+	for i, path := range []string{primaryPath, shadowPath} {
+		db, err := bolt.New(path)
+		if err != nil {
+			t.Fatalf("bolt.New() failed: %v", err)
+		}
+		// Photo 2 differs between the databases
+		photos := []manul.PhotoItem{
+			{CatID: 1, PhotoID: 1, PhotoData: newTestJPEG(t, 20, 10)},
+			{CatID: 1, PhotoID: 2, PhotoData: newTestJPEG(t, 20, 10+i)},
+		}
+		if err := db.AddPhotosBatch(photos); err != nil {
+			t.Fatalf("AddPhotosBatch() failed: %v", err)
+		}
+		db.Close()
+	}
+
+	_, err := NewCatPhotosServer(primaryPath, "bolt", "", "", time.Second, true, 0, nil, WithShadowDB(shadowPath, "bolt"))
+	if err == nil {
+		t.Errorf("NewCatPhotosServer() with a shadow database in read-write mode succeeded, want error")
+	}
+
+	s, err := NewCatPhotosServer(primaryPath, "bolt", "", "", time.Second, false, 0, nil, WithShadowDB(shadowPath, "bolt"))
+	if err != nil {
+		t.Fatalf("NewCatPhotosServer() failed: %v", err)
+	}
+	for photoID := uint64(1); photoID <= 2; photoID++ {
+		if _, err := s.GetPhoto(context.Background(), &pb.GetPhotoRequest{CatId: 1, PhotoId: photoID}); err != nil {
+			t.Errorf("GetPhoto() failed: %v", err)
+		}
+	}
+
+	// Close waits for the comparisons
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close() failed: %v", err)
+	}
+	want := shadow.Stats{Matches: 1, Mismatches: 1}
+	if got := s.dbReader.(*shadow.ShadowDB).Stats(); got != want {
+		t.Errorf("Shadow read stats = %+v, want %+v", got, want)
+	}
+}
+
+func TestDeleteRPCs(t *testing.T) {
+	ctx := context.Background()
+
+	readOnly := newTestServer(t, false)
+	_, err := readOnly.DeleteCat(ctx, &pb.DeleteCatRequest{CatId: 1})
+	if status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("DeleteCat() on read-only server error = %v, want FailedPrecondition", err)
+	}
+
+	s := newTestServer(t, true)
+	deleted, err := s.DeletePhotos(ctx, &pb.DeletePhotosRequest{PhotoRequests: []*pb.PhotoRequest{
+		{CatId: 2, PhotoId: 1},
+		{CatId: 2, PhotoId: 2},
+	}})
+	if err != nil {
+		t.Fatalf("DeletePhotos() failed: %v", err)
+	}
+	if len(deleted.Deleted) != 2 || !deleted.Deleted[0] || deleted.Deleted[1] {
+		t.Errorf("DeletePhotos() = %v, want [true false]", deleted.Deleted)
+	}
+
+	resp, err := s.DeleteCat(ctx, &pb.DeleteCatRequest{CatId: 1})
+	if err != nil {
+		t.Fatalf("DeleteCat() failed: %v", err)
+	}
+	if resp.DeletedCount != 2 {
+		t.Errorf("DeleteCat() deleted %d photos, want 2", resp.DeletedCount)
+	}
+
+	_, err = s.DeleteCat(ctx, &pb.DeleteCatRequest{CatId: 1})
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("DeleteCat() of a deleted cat error = %v, want NotFound", err)
+	}
+}
+
+func TestListPhotos_Pagination(t *testing.T) {
+	db := memory.New()
+	for photoID := uint64(1); photoID <= 5; photoID++ {
+		if err := db.AddPhoto(1, photoID, []byte("photo")); err != nil {
+			t.Fatalf("AddPhoto() failed: %v", err)
+		}
+	}
+	s, err := NewCatPhotosServer("", "", "", "", 0, false, 0, nil, WithDBReader(db))
+	if err != nil {
+		t.Fatalf("NewCatPhotosServer() failed: %v", err)
+	}
+	defer s.Close()
+	ctx := context.Background()
+
+	var pages [][]uint64
+	req := &pb.ListPhotosRequest{CatId: 1, PageSize: 2}
+	for {
+		resp, err := s.ListPhotos(ctx, req)
+		if err != nil {
+			t.Fatalf("ListPhotos() failed: %v", err)
+		}
+		pages = append(pages, resp.PhotoIds)
+		if resp.NextPageToken == "" {
+			break
+		}
+		req.PageToken = resp.NextPageToken
+	}
+
+	want := [][]uint64{{1, 2}, {3, 4}, {5}}
+	if len(pages) != len(want) {
+		t.Fatalf("ListPhotos() returned pages %v, want %v", pages, want)
+	}
+	for i := range want {
+		if len(pages[i]) != len(want[i]) || pages[i][0] != want[i][0] {
+			t.Errorf("Page %d = %v, want %v", i, pages[i], want[i])
+		}
+	}
+
+	// Default page size returns everything
+	resp, err := s.ListPhotos(ctx, &pb.ListPhotosRequest{CatId: 1})
+	if err != nil {
+		t.Fatalf("ListPhotos() failed: %v", err)
+	}
+	if len(resp.PhotoIds) != 5 || resp.NextPageToken != "" {
+		t.Errorf("ListPhotos() with default page size = %v, next token %q, want 5 photos on one page", resp.PhotoIds, resp.NextPageToken)
+	}
+
+	_, err = s.ListPhotos(ctx, &pb.ListPhotosRequest{CatId: 1, PageToken: "not a token"})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("ListPhotos() with invalid token error = %v, want InvalidArgument", err)
+	}
+}
+
+func TestListCats_Pagination(t *testing.T) {
+	s := newTestServer(t, false)
+	ctx := context.Background()
+
+	first, err := s.ListCats(ctx, &pb.ListCatsRequest{PageSize: 1})
+	if err != nil {
+		t.Fatalf("ListCats() failed: %v", err)
+	}
+	if len(first.CatIds) != 1 || first.CatIds[0] != 1 || first.NextPageToken == "" {
+		t.Fatalf("First ListCats() page = %v, next token %q, want [1] and a token", first.CatIds, first.NextPageToken)
+	}
+
+	second, err := s.ListCats(ctx, &pb.ListCatsRequest{PageSize: 1, PageToken: first.NextPageToken})
+	if err != nil {
+		t.Fatalf("ListCats() failed: %v", err)
+	}
+	if len(second.CatIds) != 1 || second.CatIds[0] != 2 || second.NextPageToken != "" {
+		t.Errorf("Second ListCats() page = %v, next token %q, want [2] and no token", second.CatIds, second.NextPageToken)
+	}
+}
+
+func TestGetPhoto_ReplacedPhotoDecodedCache(t *testing.T) {
+	s := newTestServer(t, false)
+	s.EnableDecodedCache(10, 1<<20)
+	s.EnablePhotoCache(1 << 20)
+	db := s.dbReader.(*memory.MemoryDB)
+
+	for _, height := range []int{10, 40} {
+		// Another writer replaces the photo
+		if err := db.AddPhoto(1, 1, newTestJPEG(t, 20, height)); err != nil {
+			t.Fatalf("AddPhoto() failed: %v", err)
+		}
+		resp, err := s.GetPhoto(context.Background(), &pb.GetPhotoRequest{CatId: 1, PhotoId: 1, Width: 10, ScalingAlgorithm: pb.ScalingAlgorithm_BILINEAR})
+		if err != nil {
+			t.Fatalf("GetPhoto() failed: %v", err)
+		}
+		cfg, err := jpeg.DecodeConfig(bytes.NewReader(resp.PhotoData))
+		if err != nil {
+			t.Fatalf("Failed to decode photo: %v", err)
+		}
+		if cfg.Height != height/2 {
+			t.Errorf("GetPhoto() of a 20x%d photo height = %d, want %d", height, cfg.Height, height/2)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+)
+
+// statsCatsPage is the number of cat IDs listed at a time to count cats
+const statsCatsPage = 1000
+
+// statsLogger periodically logs database stats as a heartbeat for
+// operators without Prometheus
+type statsLogger struct {
+	server   *CatPhotosServer
+	interval time.Duration
+	cancel   context.CancelFunc
+}
+
+// StartStatsLogger starts logging the cat count, the photo count and the
+// read limiter occupancy every interval
+func (s *CatPhotosServer) StartStatsLogger(interval time.Duration) {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.stats = &statsLogger{
+		server:   s,
+		interval: interval,
+		cancel:   cancel,
+	}
+	go s.stats.run(ctx)
+}
+
+func (l *statsLogger) run(ctx context.Context) {
+	ticker := time.NewTicker(l.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			l.log()
+		}
+	}
+}
+
+func (l *statsLogger) log() {
+	s := l.server
+	cats, err := countCats(s)
+	if err != nil {
+		s.logger.Error("Stats: failed to count cats", "error", err)
+		return
+	}
+
+	photos, err := s.dbReader.CountAllPhotos()
+	if err != nil {
+		s.logger.Error("Stats: failed to count photos", "error", err)
+		return
+	}
+
+	reads := "unlimited"
+	if s.readLimiter != nil {
+		reads = fmt.Sprintf("%d/%d", len(s.readLimiter), cap(s.readLimiter))
+	}
+	s.logger.Info("Stats", "cats", cats, "photos", photos, "reads_in_use", reads)
+}
+
+// countCats counts cats listing statsCatsPage cat IDs at a time, so the IDs
+// of all cats are not held at once
+func countCats(s *CatPhotosServer) (int, error) {
+	count := 0
+	var start uint64
+	for {
+		catIDs, err := s.dbReader.ListCatIDs(start, statsCatsPage)
+		if err != nil {
+			return 0, err
+		}
+		count += len(catIDs)
+		if len(catIDs) < statsCatsPage || catIDs[len(catIDs)-1] == math.MaxUint64 {
+			return count, nil
+		}
+		start = catIDs[len(catIDs)-1] + 1
+	}
+}
+
+func (l *statsLogger) Stop() {
+	l.cancel()
+}
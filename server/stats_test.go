@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/mhbvr/manul/db/memory"
+)
+
+func TestCountCats(t *testing.T) {
+	tests := []struct {
+		name string
+		cats int
+	}{
+		{"empty", 0},
+		{"one page", statsCatsPage - 1},
+		{"full pages", statsCatsPage * 2},
+		{"partial page", statsCatsPage + 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := memory.New()
+			for catID := uint64(1); catID <= uint64(tt.cats); catID++ {
+				if err := db.AddPhoto(catID, 1, []byte("photo")); err != nil {
+					t.Fatalf("AddPhoto() failed: %v", err)
+				}
+			}
+			s := &CatPhotosServer{dbReader: db}
+
+			count, err := countCats(s)
+			if err != nil {
+				t.Fatalf("countCats() failed: %v", err)
+			}
+			if count != tt.cats {
+				t.Errorf("countCats() = %d, want %d", count, tt.cats)
+			}
+		})
+	}
+}
+
+func TestStatsLogger_Log(t *testing.T) {
+	var buf bytes.Buffer
+	s := newTestServer(t, false)
+	s.logger = slog.New(slog.NewTextHandler(&buf, nil))
+
+	(&statsLogger{server: s}).log()
+	if want := "cats=2 photos=3 reads_in_use=0/1"; !strings.Contains(buf.String(), want) {
+		t.Errorf("Stats log = %q, want it to contain %q", buf.String(), want)
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	pb "github.com/mhbvr/manul/proto"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.opentelemetry.io/contrib/zpages"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+)
+
+// requestDuration tracks photo request latency. cat_id is attached only as
+// an exemplar, a label would make the metric cardinality unbounded.
+var requestDuration = promauto.NewHistogramVec(
+	prometheus.HistogramOpts{
+		Name:    "cat_photos_request_duration_seconds",
+		Help:    "Latency of photo requests, with cat_id exemplars",
+		Buckets: prometheus.DefBuckets,
+	},
+	[]string{"method"},
+)
+
+// InitializeTracing sets up OpenTelemetry tracing with zpages
+func InitializeTracing() (http.Handler, func(), error) {
+	res, err := resource.New(context.Background(),
+		resource.WithAttributes(
+			semconv.ServiceNameKey.String("cat-photos-server"),
+		),
+	)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create resource: %v", err)
+	}
+
+	zpagesProcessor := zpages.NewSpanProcessor()
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithResource(res),
+		sdktrace.WithSpanProcessor(zpagesProcessor),
+	)
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	cleanup := func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		tp.Shutdown(ctx)
+	}
+
+	return zpages.NewTracezHandler(zpagesProcessor), cleanup, nil
+}
+
+// observeCatRequest records request latency with the cat_id exemplar
+// and tags the request span with cat_id
+func observeCatRequest(ctx context.Context, method string, catID uint64, duration time.Duration) {
+	span := trace.SpanFromContext(ctx)
+	span.SetAttributes(attribute.Int64("cat_id", int64(catID)))
+
+	exemplar := prometheus.Labels{"cat_id": strconv.FormatUint(catID, 10)}
+	if sc := span.SpanContext(); sc.HasTraceID() {
+		exemplar["trace_id"] = sc.TraceID().String()
+	}
+	requestDuration.WithLabelValues(method).(prometheus.ExemplarObserver).ObserveWithExemplar(duration.Seconds(), exemplar)
+}
+
+// catIDUnaryServerInterceptor tags requests carrying a cat_id in traces and exemplars
+func catIDUnaryServerInterceptor(
+	ctx context.Context,
+	req interface{},
+	info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (interface{}, error) {
+	catReq, ok := req.(interface{ GetCatId() uint64 })
+	if !ok {
+		return handler(ctx, req)
+	}
+
+	start := time.Now()
+	resp, err := handler(ctx, req)
+	observeCatRequest(ctx, info.FullMethod, catReq.GetCatId(), time.Since(start))
+	return resp, err
+}
+
+// catIDServerStream captures the cat_id of the first photo requested in a
+// stream, or of the photo sent in chunks
+type catIDServerStream struct {
+	grpc.ServerStream
+	catID    uint64
+	hasCatID bool
+}
+
+func (s *catIDServerStream) RecvMsg(m interface{}) error {
+	err := s.ServerStream.RecvMsg(m)
+	if err != nil || s.hasCatID {
+		return err
+	}
+	switch req := m.(type) {
+	case interface{ GetPhotoRequests() []*pb.PhotoRequest }:
+		if len(req.GetPhotoRequests()) > 0 {
+			s.catID = req.GetPhotoRequests()[0].GetCatId()
+			s.hasCatID = true
+		}
+	case *pb.GetPhotoChunkedRequest:
+		if req.GetPhoto() != nil {
+			s.catID = req.GetPhoto().GetCatId()
+			s.hasCatID = true
+		}
+	}
+	return err
+}
+
+// catIDStreamServerInterceptor tags photo streams with the cat_id of the first
+// requested photo in traces and exemplars
+func catIDStreamServerInterceptor(
+	srv interface{},
+	ss grpc.ServerStream,
+	info *grpc.StreamServerInfo,
+	handler grpc.StreamHandler,
+) error {
+	start := time.Now()
+	wrapped := &catIDServerStream{ServerStream: ss}
+	err := handler(srv, wrapped)
+	if wrapped.hasCatID {
+		observeCatRequest(ss.Context(), info.FullMethod, wrapped.catID, time.Since(start))
+	}
+	return err
+}
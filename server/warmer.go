@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// maxHotKeys bounds the number of distinct photos tracked by hotKeys
+const maxHotKeys = 10000
+
+type photoKey struct {
+	catID   uint64
+	photoID uint64
+}
+
+// hotKeys counts photo reads to find the most requested photos
+type hotKeys struct {
+	mu     sync.Mutex
+	counts map[photoKey]uint64
+}
+
+func newHotKeys() *hotKeys {
+	return &hotKeys{
+		counts: make(map[photoKey]uint64),
+	}
+}
+
+func (h *hotKeys) Record(catID, photoID uint64) {
+	key := photoKey{catID: catID, photoID: photoID}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.counts[key]; !ok && len(h.counts) >= maxHotKeys {
+		// Do not track new keys when the table is full, decay will free space
+		return
+	}
+	h.counts[key]++
+}
+
+// Top returns up to n most requested photos, most popular first
+func (h *hotKeys) Top(n int) []photoKey {
+	h.mu.Lock()
+	keys := make([]photoKey, 0, len(h.counts))
+	for key := range h.counts {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return h.counts[keys[i]] > h.counts[keys[j]]
+	})
+	h.mu.Unlock()
+
+	if len(keys) > n {
+		keys = keys[:n]
+	}
+	return keys
+}
+
+// Decay halves all counters so the stats follow recent traffic
+func (h *hotKeys) Decay() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for key, count := range h.counts {
+		if count <= 1 {
+			delete(h.counts, key)
+			continue
+		}
+		h.counts[key] = count / 2
+	}
+}
+
+// cacheWarmer periodically reads the hottest photos while the server is idle,
+// so the backend caches (bbolt mmap, pebble block cache) stay warm after quiet periods.
+// It never blocks on the read limiter and stops as soon as utilization rises.
+type cacheWarmer struct {
+	server    *CatPhotosServer
+	hotKeys   *hotKeys
+	threshold float64
+	interval  time.Duration
+	numKeys   int
+	cancel    context.CancelFunc
+}
+
+// StartCacheWarmer starts warming numKeys hottest photos every interval
+// while ORCA CPU utilization is below threshold. Requires ORCA reporter.
+func (s *CatPhotosServer) StartCacheWarmer(threshold float64, interval time.Duration, numKeys int) error {
+	if s.orcaReporter == nil {
+		return errors.New("cache warming requires ORCA load reporting")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	s.hotKeys = newHotKeys()
+	s.warmer = &cacheWarmer{
+		server:    s,
+		hotKeys:   s.hotKeys,
+		threshold: threshold,
+		interval:  interval,
+		numKeys:   numKeys,
+		cancel:    cancel,
+	}
+	go s.warmer.run(ctx)
+	return nil
+}
+
+func (w *cacheWarmer) run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			warmed := w.warm(ctx)
+			if warmed > 0 {
+				w.server.logger.Info("Cache warmer: read hot photos", "photos", warmed)
+			}
+			w.hotKeys.Decay()
+		}
+	}
+}
+
+// warm reads hot photos and returns the number of photos read
+func (w *cacheWarmer) warm(ctx context.Context) int {
+	s := w.server
+	warmed := 0
+	for _, key := range w.hotKeys.Top(w.numKeys) {
+		if ctx.Err() != nil || s.orcaReporter.CPUUtilization() >= w.threshold {
+			break
+		}
+
+		read, err := w.read(key)
+		if !read {
+			// Real traffic is using all read slots
+			return warmed
+		}
+		if err == nil {
+			warmed++
+		}
+	}
+	return warmed
+}
+
+// read reads a photo if a read limiter slot is free, read is false if not.
+// Warming reads are not reported to ORCA, they yield to real traffic.
+func (w *cacheWarmer) read(key photoKey) (read bool, err error) {
+	s := w.server
+	if s.readLimiter != nil {
+		select {
+		case s.readLimiter <- struct{}{}:
+		default:
+			return false, nil
+		}
+		defer func() {
+			<-s.readLimiter
+		}()
+	}
+	_, err = s.dbReader.GetPhotoData(key.catID, key.photoID)
+	return true, err
+}
+
+func (w *cacheWarmer) Stop() {
+	w.cancel()
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestHotKeys(t *testing.T) {
+	h := newHotKeys()
+	for i := 0; i < 4; i++ {
+		h.Record(1, 1)
+	}
+	h.Record(1, 2)
+	h.Record(1, 2)
+	h.Record(2, 1)
+
+	want := []photoKey{{1, 1}, {1, 2}, {2, 1}}
+	if got := h.Top(10); !slices.Equal(got, want) {
+		t.Errorf("Top(10) = %v, want %v", got, want)
+	}
+	if got := h.Top(2); !slices.Equal(got, want[:2]) {
+		t.Errorf("Top(2) = %v, want %v", got, want[:2])
+	}
+
+	// Counters are halved, photos read once are dropped
+	h.Decay()
+	if got := h.counts; len(got) != 2 || got[photoKey{1, 1}] != 2 || got[photoKey{1, 2}] != 1 {
+		t.Errorf("Counts after Decay() = %v, want 1/1: 2, 1/2: 1", got)
+	}
+	h.Decay()
+	if got := h.Top(10); !slices.Equal(got, want[:1]) {
+		t.Errorf("Top(10) after two Decay() = %v, want %v", got, want[:1])
+	}
+}
+
+func TestHotKeys_Full(t *testing.T) {
+	h := newHotKeys()
+	for i := 0; i < maxHotKeys; i++ {
+		h.Record(1, uint64(i))
+	}
+	h.Record(2, 1)
+	h.Record(1, 0)
+
+	if n := len(h.counts); n != maxHotKeys {
+		t.Errorf("Tracked photos = %d, want %d", n, maxHotKeys)
+	}
+	if _, ok := h.counts[photoKey{2, 1}]; ok {
+		t.Errorf("New photo tracked in a full table")
+	}
+	if got := h.counts[photoKey{1, 0}]; got != 2 {
+		t.Errorf("Count of a tracked photo = %d, want 2", got)
+	}
+}
+
+func TestStartCacheWarmer_RequiresORCA(t *testing.T) {
+	s := newTestServer(t, false)
+	if err := s.StartCacheWarmer(0.5, time.Second, 10); err == nil {
+		t.Errorf("StartCacheWarmer() without ORCA reporter succeeded, want error")
+	}
+}
+
+func newTestWarmer(t *testing.T, cpuUtilization float64) *cacheWarmer {
+	t.Helper()
+
+	s := newTestServer(t, false)
+	s.orcaReporter = &ORCAReporter{cpuUtilization: cpuUtilization}
+	w := &cacheWarmer{server: s, hotKeys: newHotKeys(), threshold: 0.5, numKeys: 10}
+	w.hotKeys.Record(1, 1)
+	w.hotKeys.Record(1, 2)
+	w.hotKeys.Record(1, 3) // Missing photo, not counted as warmed
+	return w
+}
+
+func TestCacheWarmer_Warm(t *testing.T) {
+	w := newTestWarmer(t, 0.1)
+	if got := w.warm(context.Background()); got != 2 {
+		t.Errorf("warm() = %d, want 2", got)
+	}
+	if n := len(w.server.readLimiter); n != 0 {
+		t.Errorf("Read slots taken after warm() = %d, want 0", n)
+	}
+}
+
+func TestCacheWarmer_YieldsToReads(t *testing.T) {
+	w := newTestWarmer(t, 0.1)
+
+	// Real traffic holds the only read slot
+	w.server.readLimiter <- struct{}{}
+	defer func() { <-w.server.readLimiter }()
+
+	if got := w.warm(context.Background()); got != 0 {
+		t.Errorf("warm() with a full read limiter = %d, want 0", got)
+	}
+}
+
+func TestCacheWarmer_StopsAboveThreshold(t *testing.T) {
+	w := newTestWarmer(t, 0.5)
+	if got := w.warm(context.Background()); got != 0 {
+		t.Errorf("warm() at the CPU threshold = %d, want 0", got)
+	}
+}